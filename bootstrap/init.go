@@ -4,14 +4,19 @@ import (
 	model "github.com/cloudreve/Cloudreve/v3/models"
 	"github.com/cloudreve/Cloudreve/v3/models/scripts"
 	"github.com/cloudreve/Cloudreve/v3/pkg/aria2"
+	"github.com/cloudreve/Cloudreve/v3/pkg/audit"
 	"github.com/cloudreve/Cloudreve/v3/pkg/auth"
+	"github.com/cloudreve/Cloudreve/v3/pkg/authprovider"
 	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
 	"github.com/cloudreve/Cloudreve/v3/pkg/cluster"
 	"github.com/cloudreve/Cloudreve/v3/pkg/conf"
 	"github.com/cloudreve/Cloudreve/v3/pkg/crontab"
 	"github.com/cloudreve/Cloudreve/v3/pkg/email"
 	"github.com/cloudreve/Cloudreve/v3/pkg/mq"
+	"github.com/cloudreve/Cloudreve/v3/pkg/oidc"
+	"github.com/cloudreve/Cloudreve/v3/pkg/search"
 	"github.com/cloudreve/Cloudreve/v3/pkg/task"
+	"github.com/cloudreve/Cloudreve/v3/pkg/webhook"
 	"github.com/cloudreve/Cloudreve/v3/pkg/wopi"
 	"github.com/gin-gonic/gin"
 	"io/fs"
@@ -73,6 +78,12 @@ func Init(path string, statics fs.FS) {
 				task.Init()
 			},
 		},
+		{
+			"master",
+			func() {
+				mq.Init()
+			},
+		},
 		{
 			"master",
 			func() {
@@ -121,6 +132,42 @@ func Init(path string, statics fs.FS) {
 				wopi.Init()
 			},
 		},
+		{
+			"master",
+			func() {
+				search.Init()
+			},
+		},
+		{
+			"master",
+			func() {
+				oidc.Init()
+			},
+		},
+		{
+			"master",
+			func() {
+				authprovider.Init()
+			},
+		},
+		{
+			"master",
+			func() {
+				audit.Init()
+			},
+		},
+		{
+			"master",
+			func() {
+				webhook.Init()
+			},
+		},
+		{
+			"both",
+			func() {
+				InitMetrics()
+			},
+		},
 	}
 
 	for _, dependency := range dependencies {
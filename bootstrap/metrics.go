@@ -0,0 +1,153 @@
+package bootstrap
+
+import (
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/aria2/common"
+	"github.com/cloudreve/Cloudreve/v3/pkg/aria2/monitor"
+	"github.com/cloudreve/Cloudreve/v3/pkg/cluster"
+	"github.com/cloudreve/Cloudreve/v3/pkg/conf"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem"
+	"github.com/cloudreve/Cloudreve/v3/pkg/metrics"
+	"github.com/cloudreve/Cloudreve/v3/pkg/task"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+)
+
+// aria2StatusNames 离线下载任务状态对应的指标标签
+var aria2StatusNames = map[int]string{
+	common.Ready:       "ready",
+	common.Downloading: "downloading",
+	common.Paused:      "paused",
+	common.Error:       "error",
+	common.Complete:    "complete",
+	common.Canceled:    "canceled",
+	common.Unknown:     "unknown",
+	common.Seeding:     "seeding",
+}
+
+// InitMetrics 注册需在采集时读取的运行状态指标
+func InitMetrics() {
+	if !conf.MetricsConfig.Enabled {
+		return
+	}
+
+	gauges := []struct {
+		mode  string
+		name  string
+		help  string
+		label string
+		fn    func() map[string]float64
+	}{
+		{
+			"both", "task_pool_workers", "Workers of the task pool by state.", "state",
+			func() map[string]float64 {
+				pool, ok := task.TaskPoll.(interface{ Stats() (int, int) })
+				if !ok {
+					return nil
+				}
+				idle, max := pool.Stats()
+				return map[string]float64{"idle": float64(idle), "busy": float64(max - idle)}
+			},
+		},
+		{
+			"both", "task_pool_max_workers", "Capacity of the task pool.", "",
+			func() map[string]float64 {
+				pool, ok := task.TaskPoll.(interface{ Stats() (int, int) })
+				if !ok {
+					return nil
+				}
+				_, max := pool.Stats()
+				return map[string]float64{"": float64(max)}
+			},
+		},
+		{
+			"both", "thumbnail_queue_tasks", "Thumbnail generation tasks by state.", "state",
+			func() map[string]float64 {
+				running, waiting, _ := filesystem.ThumbWorkerStats()
+				return map[string]float64{"running": float64(running), "waiting": float64(waiting)}
+			},
+		},
+		{
+			"both", "thumbnail_max_workers", "Capacity of the thumbnail generation queue.", "",
+			func() map[string]float64 {
+				_, _, max := filesystem.ThumbWorkerStats()
+				return map[string]float64{"": float64(max)}
+			},
+		},
+		{
+			"master", "aria2_monitors", "Monitored remote download tasks by status.", "status",
+			func() map[string]float64 {
+				res := make(map[string]float64, len(aria2StatusNames))
+				for _, name := range aria2StatusNames {
+					res[name] = 0
+				}
+				for status, count := range monitor.Stats() {
+					if name, ok := aria2StatusNames[status]; ok {
+						res[name] = float64(count)
+					}
+				}
+				return res
+			},
+		},
+		{
+			"master", "nodes", "Nodes in the node pool by state.", "state",
+			func() map[string]float64 {
+				if cluster.Default == nil {
+					return nil
+				}
+				active, inactive := cluster.Default.Stats()
+				return map[string]float64{"active": float64(active), "inactive": float64(inactive)}
+			},
+		},
+		{
+			"master", "db_connections", "Database connections by state.", "state",
+			func() map[string]float64 {
+				if model.DB == nil {
+					return nil
+				}
+				stats := model.DB.DB().Stats()
+				return map[string]float64{
+					"open":   float64(stats.OpenConnections),
+					"in_use": float64(stats.InUse),
+					"idle":   float64(stats.Idle),
+				}
+			},
+		},
+		{
+			"master", "db_max_open_connections", "Maximum number of open database connections.", "",
+			func() map[string]float64 {
+				if model.DB == nil {
+					return nil
+				}
+				return map[string]float64{"": float64(model.DB.DB().Stats().MaxOpenConnections)}
+			},
+		},
+		{
+			"master", "db_wait_count", "Total number of connections waited for.", "",
+			func() map[string]float64 {
+				if model.DB == nil {
+					return nil
+				}
+				return map[string]float64{"": float64(model.DB.DB().Stats().WaitCount)}
+			},
+		},
+		{
+			"master", "db_wait_duration_seconds", "Total time blocked waiting for a new connection.", "",
+			func() map[string]float64 {
+				if model.DB == nil {
+					return nil
+				}
+				return map[string]float64{"": model.DB.DB().Stats().WaitDuration.Seconds()}
+			},
+		},
+	}
+
+	for _, gauge := range gauges {
+		if gauge.mode != conf.SystemConfig.Mode && gauge.mode != "both" {
+			continue
+		}
+
+		if err := metrics.RegisterGauge(gauge.name, gauge.help, gauge.label, gauge.fn); err != nil {
+			util.Log().Warning("Failed to register metric %q: %s", gauge.name, err)
+		}
+	}
+}
@@ -15,9 +15,13 @@ import (
 
 	"github.com/cloudreve/Cloudreve/v3/bootstrap"
 	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/audit"
 	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
 	"github.com/cloudreve/Cloudreve/v3/pkg/conf"
+	"github.com/cloudreve/Cloudreve/v3/pkg/metrics"
+	"github.com/cloudreve/Cloudreve/v3/pkg/search"
 	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+	"github.com/cloudreve/Cloudreve/v3/pkg/webhook"
 	"github.com/cloudreve/Cloudreve/v3/routers"
 )
 
@@ -63,7 +67,6 @@ func main() {
 	}
 
 	api := routers.InitRouter()
-	api.TrustedPlatform = conf.SystemConfig.ProxyHeader
 	server := &http.Server{Handler: api}
 
 	// 收到信号后关闭服务器
@@ -71,6 +74,16 @@ func main() {
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGQUIT)
 	go shutdown(sigChan, server)
 
+	// 在独立地址上提供监控指标
+	if conf.MetricsConfig.Enabled && conf.MetricsConfig.Listen != "" {
+		go func() {
+			util.Log().Info("Listening to %q for metrics", conf.MetricsConfig.Listen)
+			if err := http.ListenAndServe(conf.MetricsConfig.Listen, metrics.Handler(conf.MetricsConfig.Token)); err != nil {
+				util.Log().Error("Failed to listen to %q: %s", conf.MetricsConfig.Listen, err)
+			}
+		}()
+	}
+
 	defer func() {
 		<-sigChan
 	}()
@@ -154,5 +167,14 @@ func shutdown(sigChan chan os.Signal, server *http.Server) {
 		util.Log().Warning("Failed to persist cache: %s", err)
 	}
 
+	// Flush content index
+	search.Close()
+
+	// Flush pending audit logs
+	audit.Flush()
+
+	// Record queued webhook events
+	webhook.Flush()
+
 	close(sigChan)
 }
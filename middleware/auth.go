@@ -8,14 +8,17 @@ import (
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/oss"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/upyun"
+	"github.com/cloudreve/Cloudreve/v3/pkg/metrics"
 	"github.com/cloudreve/Cloudreve/v3/pkg/mq"
 	"github.com/cloudreve/Cloudreve/v3/pkg/util"
 	"github.com/qiniu/go-sdk/v7/auth/qbox"
 	"io/ioutil"
 	"net/http"
+	"strings"
 
 	model "github.com/cloudreve/Cloudreve/v3/models"
 	"github.com/cloudreve/Cloudreve/v3/pkg/auth"
+	"github.com/cloudreve/Cloudreve/v3/pkg/authprovider"
 	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
 	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
 	"github.com/gin-contrib/sessions"
@@ -62,6 +65,49 @@ func CurrentUser() gin.HandlerFunc {
 	}
 }
 
+// AccessTokenAuth 使用请求头中的个人访问令牌登录，令牌只能用于其权限范围内的接口，
+// 其他接口中令牌被忽略
+func AccessTokenAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.GetHeader("Authorization")
+		if !strings.HasPrefix(header, "Bearer ") {
+			c.Next()
+			return
+		}
+
+		scope := accessTokenScope(c.Request.Method, c.FullPath())
+		if scope == "" {
+			c.Next()
+			return
+		}
+
+		token, err := model.GetAccessToken(strings.TrimPrefix(header, "Bearer "))
+		if err != nil {
+			c.JSON(200, serializer.Err(serializer.CodeCredentialInvalid, "Invalid or expired access token", err))
+			c.Abort()
+			return
+		}
+
+		if !token.HasScope(scope) {
+			c.JSON(200, serializer.Err(serializer.CodeNoPermissionErr, "Access token does not have scope "+scope, nil))
+			c.Abort()
+			return
+		}
+
+		user, err := model.GetActiveUserByID(token.UserID)
+		if err != nil {
+			c.JSON(200, serializer.Err(serializer.CodeCredentialInvalid, "Invalid or expired access token", err))
+			c.Abort()
+			return
+		}
+
+		token.Touch()
+		c.Set("user", &user)
+		c.Set("access_token", token)
+		c.Next()
+	}
+}
+
 // AuthRequired 需要登录
 func AuthRequired() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -94,17 +140,36 @@ func WebDAVAuth() gin.HandlerFunc {
 			return
 		}
 
+		// 密码正确？
+		var webdav *model.Webdav
 		expectedUser, err := model.GetActiveUserByEmail(username)
-		if err != nil {
-			c.Status(http.StatusUnauthorized)
-			c.Abort()
-			return
+		if err == nil {
+			webdav, err = model.GetWebdavByPassword(password, expectedUser.ID)
 		}
 
-		// 密码正确？
-		webdav, err := model.GetWebdavByPassword(password, expectedUser.ID)
+		// 不是应用密码时，尝试使用目录服务密码登录，权限由管理员设定
 		if err != nil {
-			c.Status(http.StatusUnauthorized)
+			provider, account := authprovider.WebDAV()
+			if provider == nil {
+				c.Status(http.StatusUnauthorized)
+				c.Abort()
+				return
+			}
+
+			user, err := provider.Authenticate(c, username, password)
+			if err != nil || user.Status != model.Active {
+				c.Status(http.StatusUnauthorized)
+				c.Abort()
+				return
+			}
+
+			expectedUser = *user
+			webdav = account
+		}
+
+		// 客户端 IP 在允许列表中？
+		if !webdav.AllowIP(c.ClientIP()) {
+			c.Status(http.StatusForbidden)
 			c.Abort()
 			return
 		}
@@ -163,6 +228,7 @@ func uploadCallbackCheck(c *gin.Context, policyType string) serializer.Response
 
 	// 清理回调会话
 	_ = cache.Deletes([]string{sessionID}, filesystem.UploadSessionCachePrefix)
+	metrics.ObserveUploadSession(policyType, metrics.SessionCompleted)
 
 	// 查找用户
 	user, err := model.GetActiveUserByID(callbackSession.UID)
@@ -199,7 +265,7 @@ func QiniuCallbackAuth() gin.HandlerFunc {
 		mac := qbox.NewMac(session.Policy.AccessKey, session.Policy.SecretKey)
 		ok, err := mac.VerifyCallback(c.Request)
 		if err != nil {
-			util.Log().Debug("Failed to verify callback request: %s", err)
+			util.Log().WithContext(c).Debug("Failed to verify callback request: %s", err)
 			c.JSON(401, serializer.GeneralUploadCallbackFailed{Error: "Failed to verify callback request."})
 			c.Abort()
 			return
@@ -220,7 +286,7 @@ func OSSCallbackAuth() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		err := oss.VerifyCallbackSignature(c.Request)
 		if err != nil {
-			util.Log().Debug("Failed to verify callback request: %s", err)
+			util.Log().WithContext(c).Debug("Failed to verify callback request: %s", err)
 			c.JSON(401, serializer.GeneralUploadCallbackFailed{Error: "Failed to verify callback request."})
 			c.Abort()
 			return
@@ -289,6 +355,16 @@ func OneDriveCallbackAuth() gin.HandlerFunc {
 	}
 }
 
+// GoogleDriveCallbackAuth Google Drive回调签名验证
+func GoogleDriveCallbackAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// 发送回调结束信号
+		mq.GlobalMQ.Publish(c.Param("sessionID"), mq.Message{})
+
+		c.Next()
+	}
+}
+
 // IsAdmin 必须为管理员用户组
 func IsAdmin() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -14,14 +14,15 @@ import (
 	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common/profile"
 	"io"
 	"io/ioutil"
+	"net/http"
 	"strconv"
 	"time"
 )
 
 type req struct {
-	CaptchaCode string `json:"captchaCode"`
-	Ticket      string `json:"ticket"`
-	Randstr     string `json:"randstr"`
+	CaptchaCode string `json:"captchaCode" form:"captchaCode"`
+	Ticket      string `json:"ticket" form:"ticket"`
+	Randstr     string `json:"randstr" form:"randstr"`
 }
 
 const (
@@ -31,9 +32,16 @@ const (
 
 // CaptchaRequired 验证请求签名
 func CaptchaRequired(configName string) gin.HandlerFunc {
+	return CaptchaRequiredWhen(func(c *gin.Context) bool {
+		return model.IsTrueVal(model.GetSettingByName(configName))
+	})
+}
+
+// CaptchaRequiredWhen 在 required 返回真时验证验证码，请求不带 body 时从查询参数中读取验证码
+func CaptchaRequiredWhen(required func(c *gin.Context) bool) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// 相关设定
-		options := model.GetSettingByNames(configName,
+		options := model.GetSettingByNames(
 			"captcha_type",
 			"captcha_ReCaptchaSecret",
 			"captcha_TCaptcha_SecretId",
@@ -41,9 +49,15 @@ func CaptchaRequired(configName string) gin.HandlerFunc {
 			"captcha_TCaptcha_CaptchaAppId",
 			"captcha_TCaptcha_AppSecretKey")
 		// 检查验证码
-		isCaptchaRequired := model.IsTrueVal(options[configName])
+		isCaptchaRequired := required(c)
 
-		if isCaptchaRequired {
+		if isCaptchaRequired && (c.Request.Body == nil || c.Request.Body == http.NoBody) {
+			var service req
+			_ = c.ShouldBindQuery(&service)
+			if !verifyCaptcha(c, options, service) {
+				return
+			}
+		} else if isCaptchaRequired {
 			var service req
 			bodyCopy := new(bytes.Buffer)
 			_, err := io.Copy(bodyCopy, c.Request.Body)
@@ -62,66 +76,69 @@ func CaptchaRequired(configName string) gin.HandlerFunc {
 			}
 
 			c.Request.Body = ioutil.NopCloser(bytes.NewReader(bodyData))
-			switch options["captcha_type"] {
-			case "normal":
-				captchaID := util.GetSession(c, "captchaID")
-				util.DeleteSession(c, "captchaID")
-				if captchaID == nil || !base64Captcha.VerifyCaptcha(captchaID.(string), service.CaptchaCode) {
-					c.JSON(200, serializer.Err(serializer.CodeCaptchaError, captchaNotMatch, err))
-					c.Abort()
-					return
-				}
-
-				break
-			case "recaptcha":
-				reCAPTCHA, err := recaptcha.NewReCAPTCHA(options["captcha_ReCaptchaSecret"], recaptcha.V2, 10*time.Second)
-				if err != nil {
-					util.Log().Warning("reCAPTCHA verification failed, %s", err)
-					c.Abort()
-					break
-				}
-
-				err = reCAPTCHA.Verify(service.CaptchaCode)
-				if err != nil {
-					util.Log().Warning("reCAPTCHA verification failed, %s", err)
-					c.JSON(200, serializer.Err(serializer.CodeCaptchaRefreshNeeded, captchaRefresh, nil))
-					c.Abort()
-					return
-				}
+			if !verifyCaptcha(c, options, service) {
+				return
+			}
+		}
+		c.Next()
+	}
+}
 
-				break
-			case "tcaptcha":
-				credential := common.NewCredential(
-					options["captcha_TCaptcha_SecretId"],
-					options["captcha_TCaptcha_SecretKey"],
-				)
-				cpf := profile.NewClientProfile()
-				cpf.HttpProfile.Endpoint = "captcha.tencentcloudapi.com"
-				client, _ := captcha.NewClient(credential, "", cpf)
-				request := captcha.NewDescribeCaptchaResultRequest()
-				request.CaptchaType = common.Uint64Ptr(9)
-				appid, _ := strconv.Atoi(options["captcha_TCaptcha_CaptchaAppId"])
-				request.CaptchaAppId = common.Uint64Ptr(uint64(appid))
-				request.AppSecretKey = common.StringPtr(options["captcha_TCaptcha_AppSecretKey"])
-				request.Ticket = common.StringPtr(service.Ticket)
-				request.Randstr = common.StringPtr(service.Randstr)
-				request.UserIp = common.StringPtr(c.ClientIP())
-				response, err := client.DescribeCaptchaResult(request)
-				if err != nil {
-					util.Log().Warning("TCaptcha verification failed, %s", err)
-					c.Abort()
-					break
-				}
+// verifyCaptcha 根据站点设定的验证码类型校验验证码，失败时中止请求
+func verifyCaptcha(c *gin.Context, options map[string]string, service req) bool {
+	switch options["captcha_type"] {
+	case "normal":
+		captchaID := util.GetSession(c, "captchaID")
+		util.DeleteSession(c, "captchaID")
+		if captchaID == nil || !base64Captcha.VerifyCaptcha(captchaID.(string), service.CaptchaCode) {
+			c.JSON(200, serializer.Err(serializer.CodeCaptchaError, captchaNotMatch, nil))
+			c.Abort()
+			return false
+		}
+	case "recaptcha":
+		reCAPTCHA, err := recaptcha.NewReCAPTCHA(options["captcha_ReCaptchaSecret"], recaptcha.V2, 10*time.Second)
+		if err != nil {
+			util.Log().WithContext(c).Warning("reCAPTCHA verification failed, %s", err)
+			c.Abort()
+			return false
+		}
 
-				if *response.Response.CaptchaCode != int64(1) {
-					c.JSON(200, serializer.Err(serializer.CodeCaptchaRefreshNeeded, captchaRefresh, nil))
-					c.Abort()
-					return
-				}
+		err = reCAPTCHA.Verify(service.CaptchaCode)
+		if err != nil {
+			util.Log().WithContext(c).Warning("reCAPTCHA verification failed, %s", err)
+			c.JSON(200, serializer.Err(serializer.CodeCaptchaRefreshNeeded, captchaRefresh, nil))
+			c.Abort()
+			return false
+		}
+	case "tcaptcha":
+		credential := common.NewCredential(
+			options["captcha_TCaptcha_SecretId"],
+			options["captcha_TCaptcha_SecretKey"],
+		)
+		cpf := profile.NewClientProfile()
+		cpf.HttpProfile.Endpoint = "captcha.tencentcloudapi.com"
+		client, _ := captcha.NewClient(credential, "", cpf)
+		request := captcha.NewDescribeCaptchaResultRequest()
+		request.CaptchaType = common.Uint64Ptr(9)
+		appid, _ := strconv.Atoi(options["captcha_TCaptcha_CaptchaAppId"])
+		request.CaptchaAppId = common.Uint64Ptr(uint64(appid))
+		request.AppSecretKey = common.StringPtr(options["captcha_TCaptcha_AppSecretKey"])
+		request.Ticket = common.StringPtr(service.Ticket)
+		request.Randstr = common.StringPtr(service.Randstr)
+		request.UserIp = common.StringPtr(c.ClientIP())
+		response, err := client.DescribeCaptchaResult(request)
+		if err != nil {
+			util.Log().WithContext(c).Warning("TCaptcha verification failed, %s", err)
+			c.Abort()
+			return false
+		}
 
-				break
-			}
+		if *response.Response.CaptchaCode != int64(1) {
+			c.JSON(200, serializer.Err(serializer.CodeCaptchaRefreshNeeded, captchaRefresh, nil))
+			c.Abort()
+			return false
 		}
-		c.Next()
 	}
+
+	return true
 }
@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudreve/Cloudreve/v3/pkg/metrics"
+	"github.com/gin-gonic/gin"
+)
+
+// Metrics 记录请求耗时及状态码
+func Metrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		metrics.HTTPRequestDuration.WithLabelValues(
+			routeGroup(c.FullPath(), c.Request.URL.Path),
+			c.Request.Method,
+			strconv.Itoa(c.Writer.Status()),
+		).Observe(time.Since(start).Seconds())
+	}
+}
+
+// routeGroup 根据路由模板取得所属分组，如 /api/v3/file/upload 属于 file 分组，
+// 未匹配路由的请求按是否为 API 请求归为 unmatched 或 frontend，以限制标签取值数量
+func routeGroup(fullPath, path string) string {
+	if fullPath == "" {
+		if strings.HasPrefix(path, "/api/") {
+			return "unmatched"
+		}
+		return "frontend"
+	}
+
+	group := strings.TrimPrefix(strings.TrimPrefix(fullPath, "/api/v3"), "/")
+	if i := strings.Index(group, "/"); i >= 0 {
+		group = group[:i]
+	}
+	if group == "" {
+		return "root"
+	}
+
+	return group
+}
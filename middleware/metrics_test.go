@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cloudreve/Cloudreve/v3/pkg/metrics"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouteGroup(t *testing.T) {
+	a := assert.New(t)
+
+	a.Equal("file", routeGroup("/api/v3/file/upload/:sessionId/:index", "/api/v3/file/upload/abc/0"))
+	a.Equal("slave", routeGroup("/api/v3/slave/upload/:sessionId", "/api/v3/slave/upload/abc"))
+	a.Equal("dav", routeGroup("/dav/*path", "/dav/a/b"))
+	a.Equal("metrics", routeGroup("/metrics", "/metrics"))
+	a.Equal("root", routeGroup("/", "/"))
+	a.Equal("unmatched", routeGroup("", "/api/v3/not/exist"))
+	a.Equal("frontend", routeGroup("", "/static/js/app.js"))
+}
+
+func TestMetrics(t *testing.T) {
+	a := assert.New(t)
+
+	r := gin.New()
+	r.Use(Metrics())
+	r.GET("/api/v3/site/ping", func(c *gin.Context) {
+		c.Status(204)
+	})
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/v3/site/ping", nil))
+
+	a.Equal(1, testutil.CollectAndCount(metrics.HTTPRequestDuration))
+	res := &dto.Metric{}
+	a.NoError(metrics.HTTPRequestDuration.WithLabelValues("site", "GET", "204").(prometheus.Metric).Write(res))
+	a.EqualValues(1, res.GetHistogram().GetSampleCount())
+}
@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/cloudreve/Cloudreve/v3/pkg/request"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
+)
+
+// validRequestID 可沿用的外部请求 ID 格式，避免日志被注入任意内容
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// RequestID 为每个请求分配请求 ID。请求已携带合法的请求 ID 时（如主机发往从机的请求）沿用，
+// 以便关联不同节点的日志
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(request.RequestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = uuid.Must(uuid.NewV4()).String()
+		}
+
+		c.Set(util.RequestIDKey, id)
+		c.Request = c.Request.WithContext(util.WithRequestID(c.Request.Context(), id))
+		c.Header(request.RequestIDHeader, id)
+		c.Next()
+	}
+}
+
+// AccessLog 输出访问日志，日志格式为 json 时每条访问日志输出为一个 JSON 对象
+func AccessLog() gin.HandlerFunc {
+	if util.LogFormat != util.LogFormatJSON {
+		return gin.LoggerWithFormatter(textLogFormatter)
+	}
+
+	return gin.LoggerWithFormatter(func(param gin.LogFormatterParams) string {
+		line, _ := json.Marshal(map[string]interface{}{
+			"time":            param.TimeStamp.Format(time.RFC3339),
+			"level":           "info",
+			"msg":             "access",
+			util.RequestIDKey: param.Keys[util.RequestIDKey],
+			"status":          param.StatusCode,
+			"latency_ms":      float64(param.Latency) / float64(time.Millisecond),
+			"client_ip":       param.ClientIP,
+			"method":          param.Method,
+			"path":            param.Path,
+			"body_size":       param.BodySize,
+			"error":           param.ErrorMessage,
+		})
+		return string(line) + "\n"
+	})
+}
+
+// textLogFormatter 在 Gin 默认访问日志格式后附加请求 ID
+func textLogFormatter(param gin.LogFormatterParams) string {
+	var statusColor, methodColor, resetColor string
+	if param.IsOutputColor() {
+		statusColor = param.StatusCodeColor()
+		methodColor = param.MethodColor()
+		resetColor = param.ResetColor()
+	}
+
+	if param.Latency > time.Minute {
+		param.Latency = param.Latency.Truncate(time.Second)
+	}
+	return fmt.Sprintf("[GIN] %v |%s %3d %s| %13v | %15s |%s %-7s %s %#v %s=%v\n%s",
+		param.TimeStamp.Format("2006/01/02 - 15:04:05"),
+		statusColor, param.StatusCode, resetColor,
+		param.Latency,
+		param.ClientIP,
+		methodColor, param.Method, resetColor,
+		param.Path,
+		util.RequestIDKey, param.Keys[util.RequestIDKey],
+		param.ErrorMessage,
+	)
+}
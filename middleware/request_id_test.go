@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cloudreve/Cloudreve/v3/pkg/request"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestID(t *testing.T) {
+	asserts := assert.New(t)
+	var id, ctxID string
+	r := gin.New()
+	r.Use(RequestID())
+	r.GET("/", func(c *gin.Context) {
+		id = c.GetString(util.RequestIDKey)
+		ctxID = util.RequestID(c.Request.Context())
+	})
+
+	// 未携带请求 ID 时生成新 ID
+	{
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+		asserts.Len(id, 36)
+		asserts.Equal(id, ctxID)
+		asserts.Equal(id, rec.Header().Get(request.RequestIDHeader))
+	}
+
+	// 沿用合法的请求 ID
+	{
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set(request.RequestIDHeader, "master-id_1.2")
+		r.ServeHTTP(httptest.NewRecorder(), req)
+		asserts.Equal("master-id_1.2", id)
+	}
+
+	// 忽略不合法的请求 ID
+	{
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set(request.RequestIDHeader, "bad id\nforged=1")
+		r.ServeHTTP(httptest.NewRecorder(), req)
+		asserts.Len(id, 36)
+	}
+}
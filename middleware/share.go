@@ -1,14 +1,30 @@
 package middleware
 
 import (
+	"errors"
 	"fmt"
 
 	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/audit"
+	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
+	"github.com/cloudreve/Cloudreve/v3/pkg/hashid"
 	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
 	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+	"github.com/cloudreve/Cloudreve/v3/pkg/webhook"
 	"github.com/gin-gonic/gin"
 )
 
+const (
+	// shareAccessAuditPrefix 分享访问审计去重的缓存前缀
+	shareAccessAuditPrefix = "audit_share_access_"
+	// shareAccessAuditTTL 同一访问者重复访问分享不再记录的时间（秒）
+	shareAccessAuditTTL = 600
+	// shareNotFoundAuditPrefix 访问不存在的分享时审计限流的缓存前缀
+	shareNotFoundAuditPrefix = "audit_share_not_found_"
+	// shareNotFoundAuditTTL 同一 IP 访问不存在的分享时，审计事件的最短记录间隔（秒）
+	shareNotFoundAuditTTL = 60
+)
+
 // ShareOwner 检查当前登录用户是否为分享所有者
 func ShareOwner() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -46,17 +62,77 @@ func ShareAvailable() gin.HandlerFunc {
 		share := model.GetShareByHashID(c.Param("id"))
 
 		if share == nil || !share.IsAvailable() {
+			// 同一 IP 在限流周期内只记录一次，以免遍历分享 ID 时写入大量审计事件
+			if audit.Enabled() {
+				notFoundKey := shareNotFoundAuditPrefix + c.ClientIP()
+				if _, ok := cache.Get(notFoundKey); !ok {
+					_ = cache.Set(notFoundKey, true, shareNotFoundAuditTTL)
+					audit.Record(c, audit.Event{
+						Action:     audit.ActionShareAccess,
+						User:       user,
+						TargetType: audit.TargetShare,
+						Target:     c.Param("id"),
+						Err:        errors.New("share link not found or expired"),
+					})
+				}
+			}
 			c.JSON(200, serializer.Err(serializer.CodeShareLinkNotFound, "", nil))
 			c.Abort()
 			return
 		}
 
+		// 内部分享仅授权的用户及用户组成员可访问，同一访问者短时间内的重复访问只记录一次
+		permission := share.PermissionOf(user)
+		if permission == model.SharePermissionNone {
+			if firstShareAccess(c, share, user) {
+				audit.Record(c, audit.Event{
+					Action:     audit.ActionShareAccess,
+					User:       user,
+					TargetType: audit.TargetShare,
+					TargetID:   share.ID,
+					Target:     c.Param("id"),
+					Err:        errors.New("no permission to access internal share"),
+				})
+				share.RecordAccess(c, user, model.ShareAccessDenied, nil, "")
+			}
+			if user.IsAnonymous() {
+				c.JSON(200, serializer.CheckLogin())
+			} else {
+				c.JSON(200, serializer.Err(serializer.CodeShareLinkNotFound, "", nil))
+			}
+			c.Abort()
+			return
+		}
+
+		// 同一访问者短时间内的重复访问只记录一次
+		if audit.Enabled() && firstShareAccess(c, share, user) {
+			audit.Record(c, audit.Event{
+				Action:     audit.ActionShareAccess,
+				User:       user,
+				TargetType: audit.TargetShare,
+				TargetID:   share.ID,
+				Target:     c.Param("id"),
+			})
+		}
+
 		c.Set("user", user)
 		c.Set("share", share)
+		c.Set("share_permission", permission)
 		c.Next()
 	}
 }
 
+// firstShareAccess 返回访问者是否在去重周期内首次访问分享
+func firstShareAccess(c *gin.Context, share *model.Share, user *model.User) bool {
+	accessKey := fmt.Sprintf("%s%d_%d_%s", shareAccessAuditPrefix, share.ID, user.ID, c.ClientIP())
+	if _, ok := cache.Get(accessKey); ok {
+		return false
+	}
+
+	_ = cache.Set(accessKey, true, shareAccessAuditTTL)
+	return true
+}
+
 // ShareCanPreview 检查分享是否可被预览
 func ShareCanPreview() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -74,6 +150,86 @@ func ShareCanPreview() gin.HandlerFunc {
 	}
 }
 
+// ShareCanSave 检查分享是否可被转存，转存需要登录
+func ShareCanSave() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if userCtx, ok := c.Get("user"); ok && !userCtx.(*model.User).IsAnonymous() {
+			c.Next()
+			return
+		}
+
+		c.JSON(200, serializer.CheckLogin())
+		c.Abort()
+	}
+}
+
+// SharePermission 检查当前用户对分享的权限是否不低于给定级别
+func SharePermission(level int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetInt("share_permission") >= level {
+			c.Next()
+			return
+		}
+
+		c.JSON(200, serializer.Err(serializer.CodeNoPermissionErr, "", nil))
+		c.Abort()
+	}
+}
+
+// ShareCanUpload 检查分享是否允许当前用户上传，收集文件分享允许访客上传，内部分享允许有编辑权限的用户上传
+func ShareCanUpload() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if share, ok := c.Get("share"); ok {
+			if share.(*model.Share).IsDir && (share.(*model.Share).UploadEnabled ||
+				c.GetInt("share_permission") >= model.SharePermissionEdit) {
+				c.Next()
+				return
+			}
+			c.JSON(200, serializer.Err(serializer.CodeShareUploadNotAllowed, "", nil))
+			c.Abort()
+			return
+		}
+		c.Abort()
+	}
+}
+
+// ShareContentVisible 检查分享内容是否对当前用户可见，收集文件分享可对访客隐藏目录内容
+func ShareContentVisible() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		shareCtx, shareOk := c.Get("share")
+		userCtx, userOk := c.Get("user")
+		if !shareOk || !userOk {
+			c.Abort()
+			return
+		}
+
+		if !shareCtx.(*model.Share).ContentsVisibleTo(userCtx.(*model.User)) {
+			c.JSON(200, serializer.Err(serializer.CodeNoPermissionErr, "", nil))
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// ShareUnlockCaptcha 访客多次输错分享密码或分享被频繁尝试时，解锁分享前需完成验证码
+func ShareUnlockCaptcha() gin.HandlerFunc {
+	return CaptchaRequiredWhen(func(c *gin.Context) bool {
+		shareCtx, ok := c.Get("share")
+		if !ok || c.Query("password") == "" {
+			return false
+		}
+
+		share := shareCtx.(*model.Share)
+		if share.Password == "" || util.GetSession(c, fmt.Sprintf("share_unlock_%d", share.ID)) != nil {
+			return false
+		}
+
+		return share.UnlockCaptchaRequired(c.ClientIP())
+	})
+}
+
 // CheckShareUnlocked 检查分享是否已解锁
 func CheckShareUnlocked() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -116,6 +272,7 @@ func BeforeShareDownload() gin.HandlerFunc {
 				}
 
 				// 对积分、下载次数进行更新
+				counted := !share.WasDownloadedBy(user, c)
 				err = share.DownloadBy(user, c)
 				if err != nil {
 					c.JSON(200, serializer.Err(serializer.CodeGroupNotAllowed, err.Error(),
@@ -124,6 +281,16 @@ func BeforeShareDownload() gin.HandlerFunc {
 					return
 				}
 
+				// 同一用户重复下载时不重复发布事件
+				if counted {
+					webhook.Publish(c, webhook.EventShareDownloaded, share.UserID, map[string]interface{}{
+						"id":          hashid.HashID(share.ID, hashid.ShareID),
+						"source_name": share.SourceName,
+						"is_dir":      share.IsDir,
+						"downloads":   share.Downloads,
+					})
+				}
+
 				c.Next()
 				return
 			}
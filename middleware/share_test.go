@@ -6,7 +6,10 @@ import (
 
 	"github.com/DATA-DOG/go-sqlmock"
 	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/audit"
+	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
 	"github.com/cloudreve/Cloudreve/v3/pkg/conf"
+	"github.com/cloudreve/Cloudreve/v3/pkg/hashid"
 	"github.com/gin-gonic/gin"
 	"github.com/jinzhu/gorm"
 	"github.com/stretchr/testify/assert"
@@ -51,6 +54,66 @@ func TestShareAvailable(t *testing.T) {
 	}
 }
 
+func TestShareAvailable_DeniedSQLite(t *testing.T) {
+	asserts := assert.New(t)
+	mockDB := model.DB
+	model.DB, _ = gorm.Open("sqlite", ":memory:")
+	defer func() {
+		model.DB = mockDB
+	}()
+	model.DB.AutoMigrate(&model.User{}, &model.Group{}, &model.File{}, &model.Share{}, &model.ShareAccess{})
+	cache.Set("setting_share_access_log_enabled", "1", 0)
+
+	owner := &model.User{Email: "share_owner@cloudreve.org"}
+	asserts.NoError(model.DB.Create(owner).Error)
+	file := &model.File{Name: "a.txt", UserID: owner.ID}
+	asserts.NoError(model.DB.Create(file).Error)
+	share := &model.Share{UserID: owner.ID, SourceID: file.ID, RemainDownloads: -1, Internal: true}
+	asserts.NoError(model.DB.Create(share).Error)
+
+	// 同一访问者重复被拒绝时只记录一次
+	for i := 0; i < 2; i++ {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("GET", "/", nil)
+		c.Params = []gin.Param{{Key: "id", Value: hashid.HashID(share.ID, hashid.ShareID)}}
+		ShareAvailable()(c)
+		asserts.True(c.IsAborted())
+	}
+
+	_, total, err := model.ListShareAccesses(share.ID, model.ShareAccessDenied, 1, 10)
+	asserts.NoError(err)
+	asserts.Equal(1, total)
+}
+
+func TestShareAvailable_NotFoundSQLite(t *testing.T) {
+	asserts := assert.New(t)
+	mockDB := model.DB
+	model.DB, _ = gorm.Open("sqlite", ":memory:")
+	defer func() {
+		model.DB = mockDB
+		cache.Set("setting_audit_enabled", "0", 0)
+		audit.Init()
+	}()
+	model.DB.AutoMigrate(&model.Share{}, &model.AuditLog{})
+	cache.Set("setting_audit_enabled", "1", 0)
+	audit.Init()
+
+	// 同一 IP 遍历分享 ID 时只记录一次
+	for _, id := range []string{"x9T4", "empty", "x9T4"} {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("GET", "/", nil)
+		c.Request.RemoteAddr = "5.6.7.8:80"
+		c.Params = []gin.Param{{Key: "id", Value: id}}
+		ShareAvailable()(c)
+		asserts.True(c.IsAborted())
+	}
+	audit.Flush()
+
+	var count int
+	asserts.NoError(model.DB.Model(&model.AuditLog{}).Count(&count).Error)
+	asserts.Equal(1, count)
+}
+
 func TestShareCanPreview(t *testing.T) {
 	asserts := assert.New(t)
 	rec := httptest.NewRecorder()
@@ -102,6 +165,50 @@ func TestCheckShareUnlocked(t *testing.T) {
 
 }
 
+func TestShareUnlockCaptcha(t *testing.T) {
+	asserts := assert.New(t)
+	rec := httptest.NewRecorder()
+	testFunc := ShareUnlockCaptcha()
+	cache.SetSettings(map[string]string{
+		"captcha_type":                   "normal",
+		"share_unlock_captcha_threshold": "1",
+		"share_unlock_share_limit":       "0",
+	}, "setting_")
+	share := &model.Share{Model: gorm.Model{ID: 200}}
+	share.SetPassword("secret")
+
+	// 未提交密码
+	{
+		c, _ := gin.CreateTestContext(rec)
+		c.Request = httptest.NewRequest("GET", "/", nil)
+		c.Set("share", share)
+		Session("233")(c)
+		testFunc(c)
+		asserts.False(c.IsAborted())
+	}
+
+	// 未达到阈值
+	{
+		c, _ := gin.CreateTestContext(rec)
+		c.Request = httptest.NewRequest("GET", "/?password=wrong", nil)
+		c.Set("share", share)
+		Session("233")(c)
+		testFunc(c)
+		asserts.False(c.IsAborted())
+	}
+
+	// 达到阈值后需要验证码
+	{
+		share.RecordUnlockFailure("192.0.2.1")
+		c, _ := gin.CreateTestContext(rec)
+		c.Request = httptest.NewRequest("GET", "/?password=wrong&captchaCode=1", nil)
+		c.Set("share", share)
+		Session("233")(c)
+		testFunc(c)
+		asserts.True(c.IsAborted())
+	}
+}
+
 func TestBeforeShareDownload(t *testing.T) {
 	asserts := assert.New(t)
 	rec := httptest.NewRecorder()
@@ -188,3 +295,103 @@ func TestShareOwner(t *testing.T) {
 		asserts.False(c.IsAborted())
 	}
 }
+
+func TestShareCanUpload(t *testing.T) {
+	asserts := assert.New(t)
+	rec := httptest.NewRecorder()
+	testFunc := ShareCanUpload()
+
+	// 无分享上下文
+	{
+		c, _ := gin.CreateTestContext(rec)
+		testFunc(c)
+		asserts.True(c.IsAborted())
+	}
+
+	// 允许上传
+	{
+		c, _ := gin.CreateTestContext(rec)
+		c.Set("share", &model.Share{IsDir: true, UploadEnabled: true})
+		testFunc(c)
+		asserts.False(c.IsAborted())
+	}
+
+	// 未开启上传
+	{
+		c, _ := gin.CreateTestContext(rec)
+		c.Set("share", &model.Share{IsDir: true})
+		testFunc(c)
+		asserts.True(c.IsAborted())
+	}
+
+	// 有编辑权限
+	{
+		c, _ := gin.CreateTestContext(rec)
+		c.Set("share", &model.Share{IsDir: true, Internal: true})
+		c.Set("share_permission", model.SharePermissionEdit)
+		testFunc(c)
+		asserts.False(c.IsAborted())
+	}
+}
+
+func TestShareContentVisible(t *testing.T) {
+	asserts := assert.New(t)
+	rec := httptest.NewRecorder()
+	testFunc := ShareContentVisible()
+	share := &model.Share{UserID: 1, IsDir: true, UploadEnabled: true, UploadHideContents: true}
+
+	// 无分享上下文
+	{
+		c, _ := gin.CreateTestContext(rec)
+		c.Set("user", model.NewAnonymousUser())
+		testFunc(c)
+		asserts.True(c.IsAborted())
+	}
+
+	// 访客不可见
+	{
+		c, _ := gin.CreateTestContext(rec)
+		c.Set("share", share)
+		c.Set("user", model.NewAnonymousUser())
+		testFunc(c)
+		asserts.True(c.IsAborted())
+	}
+
+	// 创建者可见
+	{
+		c, _ := gin.CreateTestContext(rec)
+		c.Set("share", share)
+		c.Set("user", &model.User{Model: gorm.Model{ID: 1}})
+		testFunc(c)
+		asserts.False(c.IsAborted())
+	}
+}
+
+func TestSharePermission(t *testing.T) {
+	asserts := assert.New(t)
+	rec := httptest.NewRecorder()
+	testFunc := SharePermission(model.SharePermissionDownload)
+
+	// 无权限上下文
+	{
+		c, _ := gin.CreateTestContext(rec)
+		testFunc(c)
+		asserts.True(c.IsAborted())
+	}
+
+	// 权限不足
+	{
+		c, _ := gin.CreateTestContext(rec)
+		c.Set("share_permission", model.SharePermissionView)
+		testFunc(c)
+		asserts.True(c.IsAborted())
+	}
+
+	// 权限足够
+	{
+		c, _ := gin.CreateTestContext(rec)
+		c.Set("share_permission", model.SharePermissionEdit)
+		testFunc(c)
+		asserts.False(c.IsAborted())
+	}
+}
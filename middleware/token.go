@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"strings"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+)
+
+// accessTokenRoutes 可使用个人访问令牌的接口及所需权限
+var accessTokenRoutes = map[string]string{
+	// 浏览、搜索、下载文件
+	"GET /api/v3/directory/*path":                    model.ScopeFilesRead,
+	"GET /api/v3/object/property/:id":                model.ScopeFilesRead,
+	"GET /api/v3/file/search/:type/:keywords":        model.ScopeFilesRead,
+	"PUT /api/v3/file/download/:id":                  model.ScopeFilesRead,
+	"GET /api/v3/file/preview/:id":                   model.ScopeFilesRead,
+	"GET /api/v3/file/content/:id":                   model.ScopeFilesRead,
+	"GET /api/v3/file/thumb/:id":                     model.ScopeFilesRead,
+	"POST /api/v3/file/archive":                      model.ScopeFilesRead,
+	"GET /api/v3/file/version/:id":                   model.ScopeFilesRead,
+	"PUT /api/v3/file/version/:id/:version/download": model.ScopeFilesRead,
+	"GET /api/v3/file/version/:id/:version/preview":  model.ScopeFilesRead,
+	"GET /api/v3/file/version/:id/:version/content":  model.ScopeFilesRead,
+	// 上传文件、创建目录
+	"PUT /api/v3/directory":                      model.ScopeFilesUpload,
+	"POST /api/v3/file/create":                   model.ScopeFilesUpload,
+	"PUT /api/v3/file/update/:id":                model.ScopeFilesUpload,
+	"PUT /api/v3/file/upload":                    model.ScopeFilesUpload,
+	"POST /api/v3/file/upload/:sessionId/:index": model.ScopeFilesUpload,
+	"DELETE /api/v3/file/upload/:sessionId":      model.ScopeFilesUpload,
+	"DELETE /api/v3/file/upload":                 model.ScopeFilesUpload,
+	// 管理分享
+	"POST /api/v3/share":       model.ScopeShares,
+	"GET /api/v3/share":        model.ScopeShares,
+	"PATCH /api/v3/share/:id":  model.ScopeShares,
+	"DELETE /api/v3/share/:id": model.ScopeShares,
+}
+
+// accessTokenScope 返回接口所需的令牌权限，不能使用令牌时返回空值
+func accessTokenScope(method, path string) string {
+	if strings.HasPrefix(path, "/api/v3/admin/") {
+		return model.ScopeAdmin
+	}
+	return accessTokenRoutes[method+" "+path]
+}
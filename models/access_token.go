@@ -0,0 +1,121 @@
+package model
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+	"github.com/jinzhu/gorm"
+)
+
+// AccessToken 个人访问令牌
+type AccessToken struct {
+	gorm.Model
+	Name       string     // 令牌名称
+	UserID     uint       `gorm:"index:access_token_user"`                         // 创建者ID
+	Token      string     `gorm:"size:64;unique_index:access_token_hash" json:"-"` // 令牌的 SHA-256 摘要
+	Scopes     string     // 权限范围，以逗号分隔
+	ExpiresAt  *time.Time // 过期时间，空值表示永不过期
+	LastUsedAt *time.Time // 最后使用时间
+}
+
+const (
+	// ScopeFilesRead 浏览、搜索、下载文件
+	ScopeFilesRead = "files.read"
+	// ScopeFilesUpload 上传文件、创建目录
+	ScopeFilesUpload = "files.upload"
+	// ScopeShares 管理分享
+	ScopeShares = "shares"
+	// ScopeAdmin 访问管理接口，仅管理员可用
+	ScopeAdmin = "admin"
+
+	// AccessTokenPrefix 个人访问令牌的前缀，便于识别泄露的令牌
+	AccessTokenPrefix = "crpat_"
+
+	// accessTokenTouchInterval 更新最后使用时间的最小间隔
+	accessTokenTouchInterval = time.Minute
+)
+
+// AccessTokenScopes 所有可用的权限范围
+var AccessTokenScopes = []string{ScopeFilesRead, ScopeFilesUpload, ScopeShares, ScopeAdmin}
+
+// hashAccessToken 计算令牌的摘要
+func hashAccessToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Create 生成并保存新的令牌，返回令牌明文，明文仅在创建时可见
+func (token *AccessToken) Create() (string, error) {
+	secret := make([]byte, 24)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+
+	plain := AccessTokenPrefix + hex.EncodeToString(secret)
+	token.Token = hashAccessToken(plain)
+	if err := DB.Create(token).Error; err != nil {
+		util.Log().Warning("Failed to insert access token record: %s", err)
+		return "", err
+	}
+
+	return plain, nil
+}
+
+// ScopeList 返回令牌的权限范围列表
+func (token *AccessToken) ScopeList() []string {
+	if token.Scopes == "" {
+		return []string{}
+	}
+	return strings.Split(token.Scopes, ",")
+}
+
+// HasScope 令牌是否拥有给定权限
+func (token *AccessToken) HasScope(scope string) bool {
+	return util.ContainsString(token.ScopeList(), scope)
+}
+
+// Touch 更新令牌的最后使用时间，短时间内重复使用时不更新
+func (token *AccessToken) Touch() {
+	now := time.Now()
+	if token.LastUsedAt != nil && now.Sub(*token.LastUsedAt) < accessTokenTouchInterval {
+		return
+	}
+
+	token.LastUsedAt = &now
+	DB.Model(token).UpdateColumn("last_used_at", now)
+}
+
+// GetAccessToken 根据令牌明文查找未过期的令牌
+func GetAccessToken(plain string) (*AccessToken, error) {
+	token := &AccessToken{}
+	result := DB.Where("token = ? and (expires_at is null or expires_at > ?)", hashAccessToken(plain), time.Now()).
+		First(token)
+	return token, result.Error
+}
+
+// ListAccessTokens 列出用户的所有令牌
+func ListAccessTokens(uid uint) ([]AccessToken, error) {
+	var tokens []AccessToken
+	result := DB.Where("user_id = ?", uid).Order("created_at desc").Find(&tokens)
+	return tokens, result.Error
+}
+
+// CountAccessTokens 统计用户的令牌数量
+func CountAccessTokens(uid uint) int {
+	var count int
+	DB.Model(&AccessToken{}).Where("user_id = ?", uid).Count(&count)
+	return count
+}
+
+// DeleteAccessTokenByID 根据ID和用户ID删除令牌
+func DeleteAccessTokenByID(id, uid uint) error {
+	result := DB.Where("id = ? and user_id = ?", id, uid).Delete(&AccessToken{})
+	if result.Error == nil && result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return result.Error
+}
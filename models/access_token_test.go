@@ -0,0 +1,65 @@
+package model
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccessTokenLifecycleSQLite(t *testing.T) {
+	asserts := assert.New(t)
+	DB, _ = gorm.Open("sqlite", ":memory:")
+	defer func() {
+		DB = mockDB
+	}()
+	DB.AutoMigrate(&AccessToken{})
+
+	// 创建令牌，仅保存摘要
+	token := &AccessToken{Name: "ci", UserID: 1, Scopes: ScopeFilesRead + "," + ScopeShares}
+	plain, err := token.Create()
+	asserts.NoError(err)
+	asserts.True(strings.HasPrefix(plain, AccessTokenPrefix))
+	asserts.NotContains(token.Token, plain)
+
+	// 根据明文查找
+	found, err := GetAccessToken(plain)
+	asserts.NoError(err)
+	asserts.Equal(token.ID, found.ID)
+	asserts.True(found.HasScope(ScopeShares))
+	asserts.False(found.HasScope(ScopeAdmin))
+	_, err = GetAccessToken(plain + "0")
+	asserts.Error(err)
+
+	// 更新最后使用时间
+	found.Touch()
+	last := found.LastUsedAt
+	asserts.NotNil(last)
+	found.Touch()
+	asserts.Equal(last, found.LastUsedAt)
+	found, _ = GetAccessToken(plain)
+	asserts.NotNil(found.LastUsedAt)
+
+	// 过期令牌
+	expired := time.Now().Add(-time.Minute)
+	expiredToken := &AccessToken{Name: "expired", UserID: 1, ExpiresAt: &expired}
+	expiredPlain, err := expiredToken.Create()
+	asserts.NoError(err)
+	_, err = GetAccessToken(expiredPlain)
+	asserts.Error(err)
+	asserts.Empty(expiredToken.ScopeList())
+
+	tokens, err := ListAccessTokens(1)
+	asserts.NoError(err)
+	asserts.Len(tokens, 2)
+	asserts.Equal(2, CountAccessTokens(1))
+
+	// 撤销
+	asserts.Error(DeleteAccessTokenByID(token.ID, 2))
+	asserts.NoError(DeleteAccessTokenByID(token.ID, 1))
+	_, err = GetAccessToken(plain)
+	asserts.Error(err)
+	asserts.Equal(1, CountAccessTokens(1))
+}
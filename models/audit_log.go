@@ -0,0 +1,47 @@
+package model
+
+import (
+	"time"
+)
+
+// AuditLog 审计日志，记录安全及数据相关的操作
+type AuditLog struct {
+	ID         uint      `gorm:"primary_key"`
+	CreatedAt  time.Time `gorm:"index:audit_log_created_at"`
+	UserID     uint      `gorm:"index:audit_log_user"` // 操作者ID，未登录时为0
+	Actor      string    // 操作者标识，如邮箱或登录时使用的用户名
+	IP         string    // 操作者 IP
+	UserAgent  string    `gorm:"type:text"`
+	Action     string    `gorm:"index:audit_log_action"` // 操作类型
+	TargetType string    // 操作对象的类型
+	TargetID   uint      // 操作对象的ID
+	Target     string    `gorm:"type:text"` // 操作对象的描述，如路径或设置项名称
+	Result     string    // 操作结果
+	Detail     string    `gorm:"type:text"` // 附加信息或失败原因
+}
+
+const (
+	// AuditResultSuccess 操作成功
+	AuditResultSuccess = "success"
+	// AuditResultFailure 操作失败
+	AuditResultFailure = "failure"
+)
+
+// CreateAuditLogs 在同一事务中批量写入审计日志
+func CreateAuditLogs(logs []AuditLog) error {
+	tx := DB.Begin()
+	for i := range logs {
+		if err := tx.Create(&logs[i]).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit().Error
+}
+
+// DeleteAuditLogsBefore 删除给定时间之前的审计日志，返回删除的条目数
+func DeleteAuditLogsBefore(t time.Time) (int64, error) {
+	result := DB.Where("created_at < ?", t).Delete(&AuditLog{})
+	return result.RowsAffected, result.Error
+}
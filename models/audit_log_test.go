@@ -0,0 +1,37 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuditLogLifecycleSQLite(t *testing.T) {
+	asserts := assert.New(t)
+	DB, _ = gorm.Open("sqlite", ":memory:")
+	defer func() {
+		DB = mockDB
+	}()
+	DB.AutoMigrate(&AuditLog{})
+
+	now := time.Now()
+	asserts.NoError(CreateAuditLogs([]AuditLog{
+		{CreatedAt: now.Add(-48 * time.Hour), UserID: 1, Action: "user.login", Result: AuditResultSuccess},
+		{CreatedAt: now, UserID: 1, Action: "file.delete", Result: AuditResultFailure},
+	}))
+
+	var count int
+	DB.Model(&AuditLog{}).Count(&count)
+	asserts.Equal(2, count)
+
+	deleted, err := DeleteAuditLogsBefore(now.Add(-24 * time.Hour))
+	asserts.NoError(err)
+	asserts.EqualValues(1, deleted)
+
+	var logs []AuditLog
+	DB.Find(&logs)
+	asserts.Len(logs, 1)
+	asserts.Equal("file.delete", logs[0].Action)
+}
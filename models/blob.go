@@ -0,0 +1,471 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+	"github.com/jinzhu/gorm"
+)
+
+// Blob 存储策略中的源文件，多个文件或历史版本可通过引用计数共用同一个源文件
+type Blob struct {
+	gorm.Model
+	PolicyID   uint    `gorm:"unique_index:blob_content"`
+	Hash       *string `gorm:"size:64;unique_index:blob_content"` // 内容的 SHA-256 摘要，为空时不参与去重
+	Size       uint64  `gorm:"unique_index:blob_content"`
+	SourceName string  `gorm:"type:text"`
+	RefCount   int
+}
+
+// blobRetries 与并发上传的相同内容冲突时，关联 Blob 的最大尝试次数
+const blobRetries = 3
+
+// errBlobConflict 相同内容的 Blob 被并发创建或释放
+var errBlobConflict = errors.New("blob is modified concurrently")
+
+// CreateWithBlob 创建文件记录，并根据内容摘要关联至同一存储策略下已有的相同内容。
+// 找到相同内容时，文件会改为引用已有的源文件，并返回不再需要的新源文件路径
+func (file *File) CreateWithBlob(hash string) (string, error) {
+	return retryOnBlobConflict(func() (string, error) {
+		return file.createWithBlob(hash)
+	})
+}
+
+func (file *File) createWithBlob(hash string) (string, error) {
+	tx := DB.Begin()
+
+	duplicate, err := file.acquireBlobByHash(tx, hash)
+	if err != nil {
+		tx.Rollback()
+		return "", err
+	}
+
+	if err := tx.Create(file).Error; err != nil {
+		util.Log().Warning("Failed to insert file record: %s", err)
+		tx.Rollback()
+		return "", err
+	}
+
+	user := &User{}
+	user.ID = file.UserID
+	if err := user.ChangeStorage(tx, "+", file.Size); err != nil {
+		tx.Rollback()
+		return "", err
+	}
+
+	return duplicate, tx.Commit().Error
+}
+
+// LinkBlob 为已有的文件记录关联内容摘要，用法同 CreateWithBlob
+func (file *File) LinkBlob(hash string) (string, error) {
+	if file.BlobID != 0 {
+		return "", nil
+	}
+
+	return retryOnBlobConflict(func() (string, error) {
+		return file.linkBlob(hash)
+	})
+}
+
+func (file *File) linkBlob(hash string) (string, error) {
+	tx := DB.Begin()
+
+	sourceName := file.SourceName
+	duplicate, err := file.acquireBlobByHash(tx, hash)
+	if err != nil {
+		tx.Rollback()
+		return "", err
+	}
+
+	res := tx.Model(&File{}).
+		Where("id = ? and source_name = ? and size = ? and blob_id = ?", file.ID, sourceName, file.Size, 0).
+		UpdateColumns(map[string]interface{}{
+			"source_name": file.SourceName,
+			"blob_id":     file.BlobID,
+		})
+	if res.Error != nil {
+		tx.Rollback()
+		return "", res.Error
+	}
+
+	if res.RowsAffected == 0 {
+		tx.Rollback()
+		file.SourceName = sourceName
+		file.BlobID = 0
+		return "", errors.New("file content is dirty")
+	}
+
+	return duplicate, tx.Commit().Error
+}
+
+// retryOnBlobConflict 执行关联 Blob 的事务，与并发上传冲突时重新执行
+func retryOnBlobConflict(fn func() (string, error)) (string, error) {
+	for i := 1; ; i++ {
+		duplicate, err := fn()
+		if err != errBlobConflict || i >= blobRetries {
+			return duplicate, err
+		}
+	}
+}
+
+// acquireBlobByHash 查找或创建与文件内容相同的 Blob 并增加引用，返回不再需要的源文件路径。
+// 相同内容的 Blob 被并发创建或释放时返回 errBlobConflict，调用方应回滚事务后重试
+func (file *File) acquireBlobByHash(tx *gorm.DB, hash string) (string, error) {
+	var (
+		blob      Blob
+		duplicate string
+	)
+	err := tx.Where("policy_id = ? and hash = ? and size = ?", file.PolicyID, hash, file.Size).First(&blob).Error
+	if err == nil {
+		// 引用计数已归零的 Blob 即将被删除，不能再引用
+		res := tx.Model(&Blob{}).Where("id = ? and ref_count > ?", blob.ID, 0).
+			UpdateColumn("ref_count", gorm.Expr("ref_count + ?", 1))
+		if res.Error != nil {
+			return "", res.Error
+		}
+
+		if res.RowsAffected == 0 {
+			return "", errBlobConflict
+		}
+
+		if blob.SourceName != file.SourceName {
+			duplicate = file.SourceName
+			file.SourceName = blob.SourceName
+		}
+	} else if gorm.IsRecordNotFoundError(err) {
+		blob = Blob{
+			PolicyID:   file.PolicyID,
+			Hash:       &hash,
+			Size:       file.Size,
+			SourceName: file.SourceName,
+			RefCount:   1,
+		}
+
+		// 唯一索引保证相同内容只有一个 Blob，插入失败时可能已被并发上传创建
+		if err := tx.Create(&blob).Error; err != nil {
+			if _, found := GetBlobByHash(file.PolicyID, hash, file.Size); found == nil {
+				return "", errBlobConflict
+			}
+			return "", fmt.Errorf("failed to create blob: %w", err)
+		}
+	} else {
+		return "", err
+	}
+
+	file.BlobID = blob.ID
+	return duplicate, nil
+}
+
+// acquireBlob 为文件增加一个对源文件的引用。文件尚未关联 Blob 时，会为源文件创建 Blob，
+// 并将所有使用此源文件的文件关联至新的 Blob
+func (file *File) acquireBlob(tx *gorm.DB) error {
+	if file.BlobID == 0 {
+		return file.createSourceBlob(tx, 1)
+	}
+
+	return tx.Model(&Blob{}).Where("id = ?", file.BlobID).
+		UpdateColumn("ref_count", gorm.Expr("ref_count + ?", 1)).Error
+}
+
+// LinkSharedSource 源文件被多个文件共用但尚未关联 Blob 时，为其创建 Blob，
+// 使历史版本可以通过引用计数继续引用此源文件
+func (file *File) LinkSharedSource() error {
+	if file.BlobID != 0 {
+		return nil
+	}
+
+	// 回收站中的文件仍然占用源文件
+	var count int
+	if err := DB.Unscoped().Model(&File{}).
+		Where("policy_id = ? and source_name = ? and blob_id = ?", file.PolicyID, file.SourceName, 0).
+		Count(&count).Error; err != nil {
+		return err
+	}
+
+	if count <= 1 {
+		return nil
+	}
+
+	tx := DB.Begin()
+	if err := file.createSourceBlob(tx, 0); err != nil {
+		tx.Rollback()
+		file.BlobID = 0
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+// createSourceBlob 为源文件创建 Blob，并将所有使用此源文件的文件关联至新的 Blob。
+// extra 为除这些文件之外新增的引用数
+func (file *File) createSourceBlob(tx *gorm.DB, extra int64) error {
+	blob := &Blob{
+		PolicyID:   file.PolicyID,
+		Size:       file.Size,
+		SourceName: file.SourceName,
+	}
+	if err := tx.Create(blob).Error; err != nil {
+		return err
+	}
+
+	// 回收站中的文件仍然占用源文件
+	res := tx.Unscoped().Model(&File{}).
+		Where("policy_id = ? and source_name = ? and blob_id = ?", file.PolicyID, file.SourceName, 0).
+		UpdateColumn("blob_id", blob.ID)
+	if res.Error != nil {
+		return res.Error
+	}
+
+	file.BlobID = blob.ID
+	return tx.Model(blob).UpdateColumn("ref_count", gorm.Expr("ref_count + ?", res.RowsAffected+extra)).Error
+}
+
+// createCopy 插入复制得到的文件记录，并增加对源文件的引用
+func (file *File) createCopy() error {
+	tx := DB.Begin()
+	if err := file.acquireBlob(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Create(file).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+// releaseBlobs 减少 Blob 的引用计数，并删除已无引用的 Blob 记录。refs 为 Blob ID 到释放数量的映射
+func releaseBlobs(tx *gorm.DB, refs map[uint]int) error {
+	for id, count := range refs {
+		if id == 0 || count == 0 {
+			continue
+		}
+
+		if err := tx.Model(&Blob{}).Where("id = ?", id).
+			UpdateColumn("ref_count", gorm.Expr("ref_count - ?", count)).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Unscoped().Where("id = ? and ref_count <= ?", id, 0).Delete(&Blob{}).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetBlobsByIDs 根据ID批量获取 Blob
+func GetBlobsByIDs(ids []uint) (map[uint]Blob, error) {
+	var blobs []Blob
+	res := make(map[uint]Blob, len(ids))
+	if err := DB.Where("id in (?)", ids).Find(&blobs).Error; err != nil {
+		return res, err
+	}
+
+	for _, blob := range blobs {
+		res[blob.ID] = blob
+	}
+	return res, nil
+}
+
+// GetBlobByHash 根据存储策略、内容摘要和大小查找 Blob
+func GetBlobByHash(policyID uint, hash string, size uint64) (*Blob, error) {
+	var blob Blob
+	result := DB.Where("policy_id = ? and hash = ? and size = ? and ref_count > ?", policyID, hash, size, 0).
+		First(&blob)
+	return &blob, result.Error
+}
+
+// migratedRefs 迁移的文件或历史版本记录
+type migratedRefs struct {
+	model interface{}
+	ids   []uint
+}
+
+// MigrateSource 将使用给定源文件且符合筛选条件的文件、历史版本指向新的存储策略和源文件，
+// 返回迁移的记录数及仍使用原源文件的记录数。源文件的引用全部迁移时，Blob 随之迁移；
+// 否则为迁移的引用创建新的 Blob
+func MigrateSource(policyID uint, sourceName string, dstPolicyID uint, dstSourceName string,
+	scope *MigrateScope) (int64, int64, error) {
+	tx := DB.Begin()
+
+	var fileIDs, versionIDs []uint
+	if err := scope.files(tx.Unscoped().Model(&File{})).
+		Where("files.policy_id = ? and files.source_name = ?", policyID, sourceName).
+		Pluck("files.id", &fileIDs).Error; err != nil {
+		tx.Rollback()
+		return 0, 0, err
+	}
+	if err := scope.versions(tx.Unscoped().Model(&FileVersion{})).
+		Where("file_versions.policy_id = ? and file_versions.source_name = ?", policyID, sourceName).
+		Pluck("file_versions.id", &versionIDs).Error; err != nil {
+		tx.Rollback()
+		return 0, 0, err
+	}
+
+	migrated := int64(len(fileIDs) + len(versionIDs))
+	if migrated == 0 {
+		tx.Rollback()
+		return 0, 0, nil
+	}
+
+	// 筛选条件之外仍使用原源文件的引用
+	refs := []migratedRefs{{&File{}, fileIDs}, {&FileVersion{}, versionIDs}}
+	var remaining int64
+	for _, ref := range refs {
+		var count int64
+		query := tx.Unscoped().Model(ref.model).Where("policy_id = ? and source_name = ?", policyID, sourceName)
+		if len(ref.ids) > 0 {
+			query = query.Where("id not in (?)", ref.ids)
+		}
+		if err := query.Count(&count).Error; err != nil {
+			tx.Rollback()
+			return 0, 0, err
+		}
+		remaining += count
+	}
+
+	var blobs []Blob
+	if err := tx.Where("policy_id = ? and source_name = ?", policyID, sourceName).Find(&blobs).Error; err != nil {
+		tx.Rollback()
+		return 0, 0, err
+	}
+
+	for i := range blobs {
+		hash, err := migratedBlobHash(tx, &blobs[i], dstPolicyID)
+		if err != nil {
+			tx.Rollback()
+			return 0, 0, err
+		}
+
+		if remaining > 0 {
+			err = splitBlob(tx, &blobs[i], &Blob{PolicyID: dstPolicyID, Hash: hash, SourceName: dstSourceName}, refs)
+		} else {
+			err = tx.Model(&blobs[i]).UpdateColumns(map[string]interface{}{
+				"policy_id":   dstPolicyID,
+				"source_name": dstSourceName,
+				"hash":        hash,
+			}).Error
+		}
+		if err != nil {
+			tx.Rollback()
+			return 0, 0, err
+		}
+	}
+
+	for _, ref := range refs {
+		if len(ref.ids) == 0 {
+			continue
+		}
+
+		if err := tx.Unscoped().Model(ref.model).Where("id in (?)", ref.ids).
+			UpdateColumns(map[string]interface{}{
+				"policy_id":   dstPolicyID,
+				"source_name": dstSourceName,
+			}).Error; err != nil {
+			tx.Rollback()
+			return 0, 0, err
+		}
+	}
+
+	if err := resetMigratedThumbs(tx, fileIDs); err != nil {
+		tx.Rollback()
+		return 0, 0, err
+	}
+
+	return migrated, remaining, tx.Commit().Error
+}
+
+// migratedBlobHash 返回 Blob 迁移至目标存储策略后的内容摘要，目标存储策略中已有相同内容时不再参与去重
+func migratedBlobHash(tx *gorm.DB, blob *Blob, dstPolicyID uint) (*string, error) {
+	if blob.Hash == nil {
+		return nil, nil
+	}
+
+	var count int
+	if err := tx.Model(&Blob{}).Where("policy_id = ? and hash = ? and size = ?", dstPolicyID, *blob.Hash, blob.Size).
+		Count(&count).Error; err != nil {
+		return nil, err
+	}
+
+	if count > 0 {
+		return nil, nil
+	}
+	return blob.Hash, nil
+}
+
+// splitBlob 将迁移的记录对原 Blob 的引用转移至新的 Blob
+func splitBlob(tx *gorm.DB, blob, split *Blob, refs []migratedRefs) error {
+	for _, ref := range refs {
+		if len(ref.ids) == 0 {
+			continue
+		}
+
+		var count int
+		if err := tx.Unscoped().Model(ref.model).Where("id in (?) and blob_id = ?", ref.ids, blob.ID).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		split.RefCount += count
+	}
+
+	if split.RefCount == 0 {
+		return nil
+	}
+
+	split.Size = blob.Size
+	if err := tx.Create(split).Error; err != nil {
+		return err
+	}
+
+	for _, ref := range refs {
+		if len(ref.ids) == 0 {
+			continue
+		}
+
+		if err := tx.Unscoped().Model(ref.model).Where("id in (?) and blob_id = ?", ref.ids, blob.ID).
+			UpdateColumn("blob_id", split.ID).Error; err != nil {
+			return err
+		}
+	}
+
+	return releaseBlobs(tx, map[uint]int{blob.ID: split.RefCount})
+}
+
+// resetMigratedThumbs 清除迁移后文件的缩略图状态，以便在目标存储策略中重新生成
+func resetMigratedThumbs(tx *gorm.DB, fileIDs []uint) error {
+	if len(fileIDs) == 0 {
+		return nil
+	}
+
+	var files []File
+	if err := tx.Unscoped().Where("id in (?)", fileIDs).Find(&files).Error; err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		_, status := file.MetadataSerialized[ThumbStatusMetadataKey]
+		_, sidecar := file.MetadataSerialized[ThumbSidecarMetadataKey]
+		if !status && !sidecar {
+			continue
+		}
+
+		delete(file.MetadataSerialized, ThumbStatusMetadataKey)
+		delete(file.MetadataSerialized, ThumbSidecarMetadataKey)
+		metadata, err := json.Marshal(file.MetadataSerialized)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Unscoped().Model(&File{}).Where("id = ?", file.ID).
+			UpdateColumn("metadata", string(metadata)).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
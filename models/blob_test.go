@@ -0,0 +1,155 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlobLifecycleSQLite(t *testing.T) {
+	asserts := assert.New(t)
+	DB, _ = gorm.Open("sqlite", ":memory:")
+	defer func() {
+		DB = mockDB
+	}()
+	DB.AutoMigrate(&User{}, &Folder{}, &File{}, &Blob{})
+
+	user := &User{Email: "1@cloudreve.org"}
+	asserts.NoError(DB.Create(user).Error)
+	src := &Folder{Name: "src", OwnerID: user.ID}
+	asserts.NoError(DB.Create(src).Error)
+	dst := &Folder{Name: "dst", OwnerID: user.ID}
+	asserts.NoError(DB.Create(dst).Error)
+
+	// 首次上传创建 Blob
+	file1 := &File{Name: "1.iso", SourceName: "s1", UserID: user.ID, FolderID: src.ID, PolicyID: 1, Size: 10}
+	duplicate, err := file1.CreateWithBlob("hash")
+	asserts.NoError(err)
+	asserts.Empty(duplicate)
+	asserts.NotZero(file1.BlobID)
+
+	// 相同内容引用已有的源文件
+	file2 := &File{Name: "2.iso", SourceName: "s2", UserID: user.ID, FolderID: src.ID, PolicyID: 1, Size: 10}
+	duplicate, err = file2.CreateWithBlob("hash")
+	asserts.NoError(err)
+	asserts.Equal("s2", duplicate)
+	asserts.Equal("s1", file2.SourceName)
+	asserts.Equal(file1.BlobID, file2.BlobID)
+
+	// 不同存储策略不共用
+	file3 := &File{Name: "3.iso", SourceName: "s3", UserID: user.ID, FolderID: src.ID, PolicyID: 2, Size: 10}
+	duplicate, err = file3.CreateWithBlob("hash")
+	asserts.NoError(err)
+	asserts.Empty(duplicate)
+	asserts.NotEqual(file1.BlobID, file3.BlobID)
+
+	blobs, err := GetBlobsByIDs([]uint{file1.BlobID})
+	asserts.NoError(err)
+	asserts.Equal(2, blobs[file1.BlobID].RefCount)
+
+	// 仍有其他引用的文件被过滤
+	files, err := RemoveFilesWithSoftLinks([]File{*file1})
+	asserts.NoError(err)
+	asserts.Len(files, 0)
+	files, err = RemoveFilesWithSoftLinks([]File{*file1, *file2})
+	asserts.NoError(err)
+	asserts.Len(files, 1)
+
+	// 复制未关联 Blob 的文件
+	legacy := &File{Name: "legacy.txt", SourceName: "legacy", UserID: user.ID, FolderID: src.ID, PolicyID: 1, Size: 1}
+	asserts.NoError(DB.Create(legacy).Error)
+	_, err = src.MoveOrCopyFileTo([]uint{legacy.ID}, dst, true)
+	asserts.NoError(err)
+	copied, err := dst.GetChildFile("legacy.txt")
+	asserts.NoError(err)
+	asserts.NotZero(copied.BlobID)
+	blobs, err = GetBlobsByIDs([]uint{copied.BlobID})
+	asserts.NoError(err)
+	asserts.Equal(2, blobs[copied.BlobID].RefCount)
+	asserts.Equal("legacy", blobs[copied.BlobID].SourceName)
+
+	// 删除文件释放引用
+	asserts.NoError(DeleteFiles([]*File{file1}, 0))
+	blobs, err = GetBlobsByIDs([]uint{file2.BlobID})
+	asserts.NoError(err)
+	asserts.Equal(1, blobs[file2.BlobID].RefCount)
+	files, err = RemoveFilesWithSoftLinks([]File{*file2})
+	asserts.NoError(err)
+	asserts.Len(files, 1)
+
+	// 更换源文件后不再引用
+	asserts.NoError(file2.UpdateSourceName("s4"))
+	blobs, err = GetBlobsByIDs([]uint{file1.BlobID})
+	asserts.NoError(err)
+	asserts.Len(blobs, 0)
+
+	// 已有文件关联内容摘要
+	linked := &File{Name: "5.iso", SourceName: "s5", UserID: user.ID, FolderID: src.ID, PolicyID: 2, Size: 10}
+	asserts.NoError(DB.Create(linked).Error)
+	duplicate, err = linked.LinkBlob("hash")
+	asserts.NoError(err)
+	asserts.Equal("s5", duplicate)
+	asserts.Equal("s3", linked.SourceName)
+	asserts.Equal(file3.BlobID, linked.BlobID)
+	blob, err := GetBlobByHash(2, "hash", 10)
+	asserts.NoError(err)
+	asserts.Equal(2, blob.RefCount)
+	_, err = GetBlobByHash(2, "hash", 11)
+	asserts.Error(err)
+
+	// 相同内容只能有一个 Blob，未计算摘要的 Blob 不受限制
+	hash := "hash"
+	asserts.Error(DB.Create(&Blob{PolicyID: 2, Hash: &hash, Size: 10, RefCount: 1}).Error)
+	asserts.NoError(DB.Create(&Blob{PolicyID: 2, Size: 10, RefCount: 1}).Error)
+	asserts.NoError(DB.Create(&Blob{PolicyID: 2, Size: 10, RefCount: 1}).Error)
+
+	// 内容改变后不再参与去重
+	file6 := &File{Name: "6.iso", SourceName: "s6", UserID: user.ID, FolderID: src.ID, PolicyID: 2, Size: 10}
+	_, err = file6.CreateWithBlob("hash2")
+	asserts.NoError(err)
+	for _, file := range []*File{file3, file6} {
+		asserts.NoError(file.UpdateSize(20))
+		blobs, err = GetBlobsByIDs([]uint{file.BlobID})
+		asserts.NoError(err)
+		asserts.Nil(blobs[file.BlobID].Hash)
+	}
+}
+
+func TestFile_LinkSharedSourceSQLite(t *testing.T) {
+	asserts := assert.New(t)
+	DB, _ = gorm.Open("sqlite", ":memory:")
+	defer func() {
+		DB = mockDB
+	}()
+	DB.AutoMigrate(&File{}, &Blob{})
+
+	// 未被共用的源文件不创建 Blob
+	single := &File{Name: "1.txt", SourceName: "single", PolicyID: 1, Size: 1}
+	asserts.NoError(DB.Create(single).Error)
+	asserts.NoError(single.LinkSharedSource())
+	asserts.Zero(single.BlobID)
+
+	// 被共用的源文件关联至新的 Blob，回收站中的文件同样计入引用
+	file := &File{Name: "2.txt", SourceName: "shared", PolicyID: 1, Size: 1}
+	asserts.NoError(DB.Create(file).Error)
+	copied := &File{Name: "3.txt", SourceName: "shared", PolicyID: 1, Size: 1}
+	asserts.NoError(DB.Create(copied).Error)
+	trashed := &File{Name: "4.txt", SourceName: "shared", PolicyID: 1, Size: 1}
+	asserts.NoError(DB.Create(trashed).Error)
+	asserts.NoError(DB.Delete(trashed).Error)
+	asserts.NoError(file.LinkSharedSource())
+	asserts.NotZero(file.BlobID)
+
+	blobs, err := GetBlobsByIDs([]uint{file.BlobID})
+	asserts.NoError(err)
+	asserts.Equal(3, blobs[file.BlobID].RefCount)
+	res, err := GetFilesByIDs([]uint{copied.ID}, 0)
+	asserts.NoError(err)
+	asserts.Equal(file.BlobID, res[0].BlobID)
+
+	// 已关联 Blob 时不做修改
+	asserts.NoError(file.LinkSharedSource())
+	blobs, _ = GetBlobsByIDs([]uint{file.BlobID})
+	asserts.Equal(3, blobs[file.BlobID].RefCount)
+}
@@ -0,0 +1,263 @@
+package model
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jinzhu/gorm"
+)
+
+// ContentDocument 全文索引中的文件内容，用于计算相关性及生成摘要
+type ContentDocument struct {
+	FileID  uint   `gorm:"primary_key;auto_increment:false"`
+	UserID  uint   `gorm:"index:content_document_user_id"`
+	Name    string `gorm:"type:text"`
+	Content string `gorm:"size:4194304"` // 超过 65532 时在 MySQL 中为 longtext
+	Length  int    // 内容中的词数
+}
+
+// ContentTerm 全文索引中的词项，记录词项在文件名或内容中出现的次数
+type ContentTerm struct {
+	FileID uint   `gorm:"primary_key;auto_increment:false"`
+	Field  int    `gorm:"primary_key;auto_increment:false"`
+	UserID uint   `gorm:"index:content_term_user"`
+	Term   string `gorm:"size:64;primary_key;index:content_term_user;index:content_term"`
+	Freq   int
+}
+
+// 词项所在的字段
+const (
+	ContentFieldContent = iota
+	ContentFieldName
+)
+
+// contentTermBatch 批量插入词项或按文件 ID 查询时每条语句的最大行数
+const contentTermBatch = 200
+
+// ContentStats 全文索引的统计信息
+type ContentStats struct {
+	Count    int // 文档数
+	TotalLen int // 所有文档内容的总词数
+}
+
+// Save 保存文档及其词项，替换文件已有的文档
+func (doc *ContentDocument) Save(terms []ContentTerm) error {
+	tx := DB.Begin()
+
+	if err := deleteContentDocuments(tx, []uint{doc.FileID}); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Create(doc).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := insertContentTerms(tx, terms); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+// RenameContentDocument 更新文档的文件名，并以 terms 替换文件名中的词项，
+// 文档不存在时返回 gorm.ErrRecordNotFound
+func RenameContentDocument(fileID uint, name string, terms []ContentTerm) error {
+	tx := DB.Begin()
+
+	doc := &ContentDocument{}
+	if err := tx.Select("file_id, user_id").Where("file_id = ?", fileID).First(doc).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Model(doc).Update("name", name).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Where("file_id = ? and field = ?", fileID, ContentFieldName).
+		Delete(&ContentTerm{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	for i := range terms {
+		terms[i].FileID = doc.FileID
+		terms[i].UserID = doc.UserID
+	}
+	if err := insertContentTerms(tx, terms); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+// DeleteContentDocuments 删除给定文件的文档及词项
+func DeleteContentDocuments(fileIDs []uint) error {
+	tx := DB.Begin()
+	if err := deleteContentDocuments(tx, fileIDs); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+func deleteContentDocuments(tx *gorm.DB, fileIDs []uint) error {
+	if err := tx.Where("file_id in (?)", fileIDs).Delete(&ContentTerm{}).Error; err != nil {
+		return err
+	}
+
+	return tx.Where("file_id in (?)", fileIDs).Delete(&ContentDocument{}).Error
+}
+
+// insertContentTerms 分批插入词项
+func insertContentTerms(tx *gorm.DB, terms []ContentTerm) error {
+	table := tx.NewScope(&ContentTerm{}).QuotedTableName()
+	for len(terms) > 0 {
+		n := len(terms)
+		if n > contentTermBatch {
+			n = contentTermBatch
+		}
+
+		values := make([]string, 0, n)
+		args := make([]interface{}, 0, n*5)
+		for _, term := range terms[:n] {
+			values = append(values, "(?, ?, ?, ?, ?)")
+			args = append(args, term.FileID, term.Field, term.UserID, term.Term, term.Freq)
+		}
+
+		if err := tx.Exec("INSERT INTO "+table+" (file_id, field, user_id, term, freq) VALUES "+
+			strings.Join(values, ", "), args...).Error; err != nil {
+			return err
+		}
+		terms = terms[n:]
+	}
+
+	return nil
+}
+
+// GetContentStats 获取全文索引的统计信息
+func GetContentStats() (ContentStats, error) {
+	var stats ContentStats
+	err := DB.Model(&ContentDocument{}).
+		Select("count(*) as count, coalesce(sum(length), 0) as total_len").
+		Row().Scan(&stats.Count, &stats.TotalLen)
+	return stats, err
+}
+
+// GetContentTermCounts 获取各词项所在的文档及字段数
+func GetContentTermCounts(terms []string) (map[string]int, error) {
+	rows, err := DB.Model(&ContentTerm{}).Select("term, count(*)").
+		Where("term in (?)", terms).Group("term").Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := make(map[string]int, len(terms))
+	for rows.Next() {
+		var (
+			term  string
+			count int
+		)
+		if err := rows.Scan(&term, &count); err != nil {
+			return nil, err
+		}
+		res[term] = count
+	}
+
+	return res, rows.Err()
+}
+
+// ContentQuery 全文检索条件，按 BM25 计算相关性
+type ContentQuery struct {
+	UserID    uint
+	Terms     []string           // 去重后的检索词项，文档需包含所有词项
+	Weights   map[string]float64 // 各词项的 IDF 权重
+	K1, B     float64            // BM25 参数
+	NameBoost float64            // 词项出现在文件名中时的得分倍数
+	AvgLength float64            // 文档内容的平均词数
+	Limit     int                // 最多返回的文档数，为 0 时不限制
+}
+
+// ContentMatch 全文检索匹配的文档
+type ContentMatch struct {
+	FileID uint
+	Score  float64
+}
+
+// SearchContentDocuments 在数据库中筛选用户文档中包含所有词项的文档，按相关性由高到低排序
+func SearchContentDocuments(query ContentQuery) ([]ContentMatch, error) {
+	// 权重均由服务端计算，以字面量写入语句，避免各数据库对参数类型推断的差异
+	num := func(v float64) string {
+		return strconv.FormatFloat(v, 'f', 6, 64)
+	}
+
+	args := make([]interface{}, 0, len(query.Terms))
+	weight := "CASE ct.term"
+	for _, term := range query.Terms {
+		weight += " WHEN ? THEN " + num(query.Weights[term])
+		args = append(args, term)
+	}
+	weight += " ELSE 0 END"
+
+	score := fmt.Sprintf(
+		"ct.file_id, SUM((%s) * CASE WHEN ct.field = %d THEN ct.freq * %s / (ct.freq + %s * (%s + %s * d.length / %s)) ELSE %s END) AS score",
+		weight, ContentFieldContent, num(query.K1+1), num(query.K1), num(1-query.B), num(query.B),
+		num(query.AvgLength), num(query.NameBoost),
+	)
+
+	db := DB.Table(DB.NewScope(&ContentTerm{}).QuotedTableName()+" ct").
+		Select(score, args...).
+		Joins("JOIN "+DB.NewScope(&ContentDocument{}).QuotedTableName()+" d ON d.file_id = ct.file_id").
+		Where("ct.user_id = ? and ct.term in (?)", query.UserID, query.Terms).
+		Group("ct.file_id").
+		Having("COUNT(DISTINCT ct.term) = ?", len(query.Terms)).
+		Order("score desc, ct.file_id desc")
+	if query.Limit > 0 {
+		db = db.Limit(query.Limit)
+	}
+
+	rows, err := db.Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []ContentMatch
+	for rows.Next() {
+		var match ContentMatch
+		if err := rows.Scan(&match.FileID, &match.Score); err != nil {
+			return nil, err
+		}
+		res = append(res, match)
+	}
+
+	return res, rows.Err()
+}
+
+// GetContentDocuments 根据文件 ID 获取文档，分批查询以免超出数据库的参数数量限制
+func GetContentDocuments(fileIDs []uint) ([]ContentDocument, error) {
+	var res []ContentDocument
+	for len(fileIDs) > 0 {
+		n := len(fileIDs)
+		if n > contentTermBatch {
+			n = contentTermBatch
+		}
+
+		var docs []ContentDocument
+		if err := DB.Where("file_id in (?)", fileIDs[:n]).Find(&docs).Error; err != nil {
+			return nil, err
+		}
+		res = append(res, docs...)
+		fileIDs = fileIDs[n:]
+	}
+
+	return res, nil
+}
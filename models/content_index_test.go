@@ -0,0 +1,69 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContentDocumentSQLite(t *testing.T) {
+	asserts := assert.New(t)
+	DB, _ = gorm.Open("sqlite", ":memory:")
+	defer func() {
+		DB = mockDB
+	}()
+	DB.AutoMigrate(&ContentDocument{}, &ContentTerm{})
+
+	// 保存文档及词项
+	doc := &ContentDocument{FileID: 1, UserID: 1, Name: "a.txt", Content: "hello world", Length: 2}
+	asserts.NoError(doc.Save([]ContentTerm{
+		{FileID: 1, UserID: 1, Field: ContentFieldContent, Term: "hello", Freq: 1},
+		{FileID: 1, UserID: 1, Field: ContentFieldContent, Term: "world", Freq: 1},
+		{FileID: 1, UserID: 1, Field: ContentFieldName, Term: "a", Freq: 1},
+	}))
+	doc = &ContentDocument{FileID: 2, UserID: 2, Name: "b.txt", Content: "hello", Length: 1}
+	asserts.NoError(doc.Save([]ContentTerm{{FileID: 2, UserID: 2, Field: ContentFieldContent, Term: "hello", Freq: 1}}))
+
+	stats, err := GetContentStats()
+	asserts.NoError(err)
+	asserts.Equal(ContentStats{Count: 2, TotalLen: 3}, stats)
+	counts, err := GetContentTermCounts([]string{"hello", "world", "none"})
+	asserts.NoError(err)
+	asserts.Equal(map[string]int{"hello": 2, "world": 1}, counts)
+	query := ContentQuery{UserID: 1, Terms: []string{"hello", "a"}, Weights: map[string]float64{"hello": 1, "a": 1},
+		K1: 1.2, B: 0.75, NameBoost: 2, AvgLength: 1.5}
+	matches, err := SearchContentDocuments(query)
+	asserts.NoError(err)
+	asserts.Len(matches, 1)
+	asserts.EqualValues(1, matches[0].FileID)
+	asserts.InDelta(1*2.2/(1+1.2*(0.25+0.75*2/1.5))+2, matches[0].Score, 0.001)
+
+	// 重新保存时替换已有的文档
+	doc = &ContentDocument{FileID: 1, UserID: 1, Name: "a.txt", Content: "world", Length: 1}
+	asserts.NoError(doc.Save([]ContentTerm{{FileID: 1, UserID: 1, Field: ContentFieldContent, Term: "world", Freq: 1}}))
+	matches, _ = SearchContentDocuments(query)
+	asserts.Empty(matches)
+	query.Terms = []string{"world"}
+	matches, _ = SearchContentDocuments(query)
+	asserts.Len(matches, 1)
+
+	// 重命名
+	asserts.NoError(RenameContentDocument(1, "c.txt", []ContentTerm{{Field: ContentFieldName, Term: "c", Freq: 1}}))
+	query.Terms = []string{"c"}
+	matches, _ = SearchContentDocuments(query)
+	asserts.Len(matches, 1)
+	asserts.EqualValues(1, matches[0].FileID)
+	docs, err := GetContentDocuments([]uint{1})
+	asserts.NoError(err)
+	asserts.Equal("c.txt", docs[0].Name)
+	asserts.Equal(gorm.ErrRecordNotFound, RenameContentDocument(3, "c.txt", nil))
+
+	// 删除
+	asserts.NoError(DeleteContentDocuments([]uint{1, 2}))
+	stats, _ = GetContentStats()
+	asserts.Zero(stats.Count)
+	query.UserID, query.Terms = 2, []string{"hello"}
+	matches, _ = SearchContentDocuments(query)
+	asserts.Empty(matches)
+}
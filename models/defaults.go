@@ -40,11 +40,13 @@ var defaultSettings = []Setting{
 	{Name: "onedrive_monitor_timeout", Value: `600`, Type: "timeout"},
 	{Name: "share_download_session_timeout", Value: `2073600`, Type: "timeout"},
 	{Name: "onedrive_callback_check", Value: `20`, Type: "timeout"},
+	{Name: "googledrive_callback_check", Value: `20`, Type: "timeout"},
 	{Name: "folder_props_timeout", Value: `300`, Type: "timeout"},
 	{Name: "chunk_retries", Value: `5`, Type: "retry"},
 	{Name: "onedrive_source_timeout", Value: `1800`, Type: "timeout"},
 	{Name: "reset_after_upload_failed", Value: `0`, Type: "upload"},
 	{Name: "use_temp_chunk_buffer", Value: `1`, Type: "upload"},
+	{Name: "instant_upload_rehash", Value: `0`, Type: "upload"},
 	{Name: "login_captcha", Value: `0`, Type: "login"},
 	{Name: "reg_captcha", Value: `0`, Type: "login"},
 	{Name: "email_active", Value: `0`, Type: "register"},
@@ -63,12 +65,21 @@ box-sizing: border-box; font-size: 14px; margin: 0;"><td style="font-family: 'He
 solid #e9e9e9;"bgcolor="#fff"><tr style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size:
 14px; margin: 0;"><td class="alert alert-warning"style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 16px; vertical-align: top; color: #fff; font-weight: 500; text-align: center; border-radius: 3px 3px 0 0; background-color: #2196F3; margin: 0; padding: 20px;"align="center"bgcolor="#FF9F00"valign="top">重设{siteTitle}密码</td></tr><tr style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; margin: 0;"><td class="content-wrap"style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; vertical-align: top; margin: 0; padding: 20px;"valign="top"><table width="100%"cellpadding="0"cellspacing="0"style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; margin: 0;"><tr style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; margin: 0;"><td class="content-block"style="font-family: 'Helvetica
 Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; vertical-align: top; margin: 0; padding: 0 0 20px;"valign="top">亲爱的<strong style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; margin: 0;">{userName}</strong>：</td></tr><tr style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; margin: 0;"><td class="content-block"style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; vertical-align: top; margin: 0; padding: 0 0 20px;"valign="top">请点击下方按钮完成密码重设。如果非你本人操作，请忽略此邮件。</td></tr><tr style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; margin: 0;"><td class="content-block"style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; vertical-align: top; margin: 0; padding: 0 0 20px;"valign="top"><a href="{resetUrl}"class="btn-primary"style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; color: #FFF; text-decoration: none; line-height: 2em; font-weight: bold; text-align: center; cursor: pointer; display: inline-block; border-radius: 5px; text-transform: capitalize; background-color: #2196F3; margin: 0; border-color: #2196F3; border-style: solid; border-width: 10px 20px;">重设密码</a></td></tr><tr style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; margin: 0;"><td class="content-block"style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; vertical-align: top; margin: 0; padding: 0 0 20px;"valign="top">感谢您选择{siteTitle}。</td></tr></table></td></tr></table><div class="footer"style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; width: 100%; clear: both; color: #999; margin: 0; padding: 20px;"><table width="100%"style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; margin: 0;"><tr style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; margin: 0;"><td class="aligncenter content-block"style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 12px; vertical-align: top; color: #999; text-align: center; margin: 0; padding: 0 0 20px;"align="center"valign="top">此邮件由系统自动发送，请不要直接回复。</td></tr></table></div></div></td><td style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; vertical-align: top; margin: 0;"valign="top"></td></tr></table></body></html>`, Type: "mail_template"},
+	{Name: "mail_share_upload_template", Value: `<!DOCTYPE html><html><head><meta name="viewport"content="width=device-width"/><meta http-equiv="Content-Type"content="text/html; charset=UTF-8"/><title>收到新文件</title></head><body style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; font-size: 14px; line-height: 1.6em; background-color: #f6f6f6; margin: 0; padding: 20px;"><div style="max-width: 600px; margin: 0 auto; background-color: #fff; border: 1px solid #e9e9e9; border-radius: 3px;"><div style="font-size: 16px; color: #fff; font-weight: 500; text-align: center; border-radius: 3px 3px 0 0; background-color: #2196F3; padding: 20px;">{siteTitle}</div><div style="padding: 20px;"><p>亲爱的<strong>{userName}</strong>：</p><p>有访客向您的收集文件分享 <strong>{shareName}</strong> 上传了文件 <strong>{fileName}</strong>。</p><p><a href="{shareUrl}"style="color: #FFF; text-decoration: none; line-height: 2em; font-weight: bold; text-align: center; cursor: pointer; display: inline-block; border-radius: 5px; background-color: #2196F3; padding: 0 1em;">查看分享</a></p><p>感谢您选择{siteTitle}。</p></div></div><div style="text-align: center; color: #999; font-size: 12px; padding: 20px;">此邮件由系统自动发送，请不要直接回复。</div></body></html>`, Type: "mail_template"},
+	{Name: "mail_share_attack_template", Value: `<!DOCTYPE html><html><head><meta name="viewport"content="width=device-width"/><meta http-equiv="Content-Type"content="text/html; charset=UTF-8"/><title>分享密码被频繁尝试</title></head><body style="font-family: 'Helvetica Neue',Helvetica,Arial,sans-serif; font-size: 14px; line-height: 1.6em; background-color: #f6f6f6; margin: 0; padding: 20px;"><div style="max-width: 600px; margin: 0 auto; background-color: #fff; border: 1px solid #e9e9e9; border-radius: 3px;"><div style="font-size: 16px; color: #fff; font-weight: 500; text-align: center; border-radius: 3px 3px 0 0; background-color: #2196F3; padding: 20px;">{siteTitle}</div><div style="padding: 20px;"><p>亲爱的<strong>{userName}</strong>：</p><p>您的分享 <strong>{shareName}</strong> 在短时间内收到了 <strong>{attempts}</strong> 次错误的密码尝试，最近一次来自 <strong>{ip}</strong>。系统已要求后续访客完成验证码，如非本人操作，建议修改分享密码或取消分享。</p><p><a href="{shareUrl}"style="color: #FFF; text-decoration: none; line-height: 2em; font-weight: bold; text-align: center; cursor: pointer; display: inline-block; border-radius: 5px; background-color: #2196F3; padding: 0 1em;">查看分享</a></p><p>感谢您选择{siteTitle}。</p></div></div><div style="text-align: center; color: #999; font-size: 12px; padding: 20px;">此邮件由系统自动发送，请不要直接回复。</div></body></html>`, Type: "mail_template"},
 	{Name: "db_version_" + conf.RequiredDBVersion, Value: `installed`, Type: "version"},
 	{Name: "hot_share_num", Value: `10`, Type: "share"},
 	{Name: "gravatar_server", Value: `https://www.gravatar.com/`, Type: "avatar"},
 	{Name: "defaultTheme", Value: `#3f51b5`, Type: "basic"},
 	{Name: "themes", Value: `{"#3f51b5":{"palette":{"primary":{"main":"#3f51b5"},"secondary":{"main":"#f50057"}}},"#2196f3":{"palette":{"primary":{"main":"#2196f3"},"secondary":{"main":"#FFC107"}}},"#673AB7":{"palette":{"primary":{"main":"#673AB7"},"secondary":{"main":"#2196F3"}}},"#E91E63":{"palette":{"primary":{"main":"#E91E63"},"secondary":{"main":"#42A5F5","contrastText":"#fff"}}},"#FF5722":{"palette":{"primary":{"main":"#FF5722"},"secondary":{"main":"#3F51B5"}}},"#FFC107":{"palette":{"primary":{"main":"#FFC107"},"secondary":{"main":"#26C6DA"}}},"#8BC34A":{"palette":{"primary":{"main":"#8BC34A","contrastText":"#fff"},"secondary":{"main":"#FF8A65","contrastText":"#fff"}}},"#009688":{"palette":{"primary":{"main":"#009688"},"secondary":{"main":"#4DD0E1","contrastText":"#fff"}}},"#607D8B":{"palette":{"primary":{"main":"#607D8B"},"secondary":{"main":"#F06292"}}},"#795548":{"palette":{"primary":{"main":"#795548"},"secondary":{"main":"#4CAF50","contrastText":"#fff"}}}}`, Type: "basic"},
 	{Name: "max_worker_num", Value: `10`, Type: "task"},
+	{Name: "task_concurrency_compress", Value: `4`, Type: "task"},
+	{Name: "task_concurrency_decompress", Value: `4`, Type: "task"},
+	{Name: "task_concurrency_transfer", Value: `10`, Type: "task"},
+	{Name: "task_concurrency_import", Value: `2`, Type: "task"},
+	{Name: "task_concurrency_recycle", Value: `10`, Type: "task"},
+	{Name: "task_concurrency_migrate", Value: `1`, Type: "task"},
+	{Name: "task_lease_ttl", Value: `60`, Type: "task"},
 	{Name: "max_parallel_transfer", Value: `4`, Type: "task"},
 	{Name: "secret_key", Value: util.RandStringRunes(256), Type: "auth"},
 	{Name: "temp_path", Value: "temp", Type: "path"},
@@ -81,6 +92,11 @@ Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; verti
 	{Name: "share_view_method", Value: "list", Type: "view"},
 	{Name: "cron_garbage_collect", Value: "@hourly", Type: "cron"},
 	{Name: "cron_recycle_upload_session", Value: "@every 1h30m", Type: "cron"},
+	{Name: "cron_purge_trash", Value: "@hourly", Type: "cron"},
+	{Name: "cron_purge_file_version", Value: "@hourly", Type: "cron"},
+	{Name: "cron_purge_audit_log", Value: "@daily", Type: "cron"},
+	{Name: "cron_purge_share_access", Value: "@daily", Type: "cron"},
+	{Name: "cron_purge_webhook_delivery", Value: "@daily", Type: "cron"},
 	{Name: "authn_enabled", Value: "0", Type: "authn"},
 	{Name: "captcha_type", Value: "normal", Type: "captcha"},
 	{Name: "captcha_height", Value: "60", Type: "captcha"},
@@ -134,6 +150,54 @@ Neue',Helvetica,Arial,sans-serif; box-sizing: border-box; font-size: 14px; verti
 	{Name: "wopi_endpoint", Value: "", Type: "wopi"},
 	{Name: "wopi_max_size", Value: "52428800", Type: "wopi"},
 	{Name: "wopi_session_timeout", Value: "36000", Type: "wopi"},
+	{Name: "oidc_enabled", Value: "0", Type: "oidc"},
+	{Name: "oidc_display_name", Value: "SSO", Type: "oidc"},
+	{Name: "oidc_issuer", Value: "", Type: "oidc"},
+	{Name: "oidc_client_id", Value: "", Type: "oidc"},
+	{Name: "oidc_client_secret", Value: "", Type: "oidc"},
+	{Name: "oidc_scopes", Value: "openid profile email", Type: "oidc"},
+	{Name: "oidc_auto_register", Value: "1", Type: "oidc"},
+	{Name: "oidc_default_group", Value: "2", Type: "oidc"},
+	{Name: "oidc_group_claim", Value: "groups", Type: "oidc"},
+	{Name: "oidc_group_mapping", Value: "[]", Type: "oidc"},
+	{Name: "ldap_enabled", Value: "0", Type: "ldap"},
+	{Name: "ldap_issuer", Value: "ldap", Type: "ldap"},
+	{Name: "ldap_url", Value: "", Type: "ldap"},
+	{Name: "ldap_start_tls", Value: "0", Type: "ldap"},
+	{Name: "ldap_skip_tls_verify", Value: "0", Type: "ldap"},
+	{Name: "ldap_bind_dn", Value: "", Type: "ldap"},
+	{Name: "ldap_bind_password", Value: "", Type: "ldap"},
+	{Name: "ldap_user_dn", Value: "", Type: "ldap"},
+	{Name: "ldap_base_dn", Value: "", Type: "ldap"},
+	{Name: "ldap_user_filter", Value: "(&(objectClass=person)(|(uid={username})(mail={username})))", Type: "ldap"},
+	{Name: "ldap_email_attr", Value: "mail", Type: "ldap"},
+	{Name: "ldap_name_attr", Value: "displayName", Type: "ldap"},
+	{Name: "ldap_group_attr", Value: "memberOf", Type: "ldap"},
+	{Name: "ldap_group_mapping", Value: "[]", Type: "ldap"},
+	{Name: "ldap_default_group", Value: "2", Type: "ldap"},
+	{Name: "ldap_webdav", Value: "0", Type: "ldap"},
+	{Name: "ldap_webdav_readonly", Value: "1", Type: "ldap"},
+	{Name: "ldap_webdav_ip_allowlist", Value: "", Type: "ldap"},
+	{Name: "audit_enabled", Value: "1", Type: "audit"},
+	{Name: "audit_retention_days", Value: "180", Type: "audit"},
+	{Name: "share_access_log_enabled", Value: "1", Type: "share"},
+	{Name: "share_access_retention_days", Value: "90", Type: "share"},
+	{Name: "share_access_country_header", Value: "", Type: "share"},
+	{Name: "share_unlock_window", Value: "900", Type: "share"},
+	{Name: "share_unlock_captcha_threshold", Value: "3", Type: "share"},
+	{Name: "share_unlock_ip_limit", Value: "20", Type: "share"},
+	{Name: "share_unlock_share_limit", Value: "50", Type: "share"},
+	{Name: "webhook_enabled", Value: "1", Type: "webhook"},
+	{Name: "webhook_timeout", Value: "10", Type: "webhook"},
+	{Name: "webhook_max_retry", Value: "3", Type: "webhook"},
+	{Name: "webhook_retry_interval", Value: "30", Type: "webhook"},
+	{Name: "webhook_allow_private", Value: "0", Type: "webhook"},
+	{Name: "webhook_delivery_retention_days", Value: "30", Type: "webhook"},
+	{Name: "search_content_enabled", Value: "0", Type: "search"},
+	{Name: "search_content_backend", Value: "database", Type: "search"},
+	{Name: "search_content_exts", Value: "txt,md,markdown,csv,log,json,xml,yaml,yml,ini,conf,pdf,docx,pptx,xlsx,odt,ods,odp", Type: "search"},
+	{Name: "search_content_max_size", Value: "20971520", Type: "search"},
+	{Name: "search_content_max_task_count", Value: "2", Type: "search"},
 }
 
 func InitSlaveDefaults() {
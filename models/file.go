@@ -25,6 +25,7 @@ type File struct {
 	PicInfo         string
 	FolderID        uint `gorm:"index:folder_id;unique_index:idx_only_one"`
 	PolicyID        uint
+	BlobID          uint    `gorm:"index:blob_id"`
 	UploadSessionID *string `gorm:"index:session_id;unique_index:session_only_one"`
 	Metadata        string  `gorm:"type:text"`
 
@@ -211,11 +212,22 @@ func RemoveFilesWithSoftLinks(files []File) ([]File, error) {
 		return filteredFiles, nil
 	}
 
+	// 已关联 Blob 的文件根据引用计数过滤
+	filteredFiles, files, err := filterBlobFiles(files)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(files) == 0 {
+		return filteredFiles, nil
+	}
+
 	// 查询软链接的文件
 	filesWithSoftLinks := make([]File, 0)
 	for _, file := range files {
 		var softLinkFile File
-		res := DB.
+		// 回收站中的文件仍然占用源文件
+		res := DB.Unscoped().
 			Where("source_name = ? and policy_id = ? and id != ?", file.SourceName, file.PolicyID, file.ID).
 			First(&softLinkFile)
 		if res.Error == nil {
@@ -226,7 +238,7 @@ func RemoveFilesWithSoftLinks(files []File) ([]File, error) {
 	// 过滤具有软连接的文件
 	// TODO: 优化复杂度
 	if len(filesWithSoftLinks) == 0 {
-		filteredFiles = files
+		filteredFiles = append(filteredFiles, files...)
 	} else {
 		for i := 0; i < len(files); i++ {
 			finder := false
@@ -247,12 +259,59 @@ func RemoveFilesWithSoftLinks(files []File) ([]File, error) {
 
 }
 
+// filterBlobFiles 从文件列表中分离出已关联 Blob 的文件，返回其中不再有其他引用的文件，
+// 相同源文件只保留一个；以及未关联 Blob 的文件
+func filterBlobFiles(files []File) ([]File, []File, error) {
+	refs := make(map[uint]int)
+	ids := make([]uint, 0)
+	legacy := make([]File, 0, len(files))
+	for _, file := range files {
+		if file.BlobID == 0 {
+			legacy = append(legacy, file)
+			continue
+		}
+
+		if refs[file.BlobID] == 0 {
+			ids = append(ids, file.BlobID)
+		}
+		refs[file.BlobID]++
+	}
+
+	unreferenced := make([]File, 0)
+	if len(ids) == 0 {
+		return unreferenced, legacy, nil
+	}
+
+	blobs, err := GetBlobsByIDs(ids)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	seen := make(map[uint]bool)
+	for _, file := range files {
+		if file.BlobID == 0 || seen[file.BlobID] {
+			continue
+		}
+
+		// 列表之外仍有其他文件或历史版本引用此源文件
+		if blob, ok := blobs[file.BlobID]; ok && blob.RefCount > refs[file.BlobID] {
+			continue
+		}
+
+		seen[file.BlobID] = true
+		unreferenced = append(unreferenced, file)
+	}
+
+	return unreferenced, legacy, nil
+}
+
 // DeleteFiles 批量删除文件记录并归还容量
 func DeleteFiles(files []*File, uid uint) error {
 	tx := DB.Begin()
 	user := &User{}
 	user.ID = uid
 	var size uint64
+	refs := make(map[uint]int)
 	for _, file := range files {
 		if uid > 0 && file.UserID != uid {
 			tx.Rollback()
@@ -271,6 +330,13 @@ func DeleteFiles(files []*File, uid uint) error {
 		}
 
 		size += file.Size
+		refs[file.BlobID]++
+	}
+
+	// 释放对源文件的引用
+	if err := releaseBlobs(tx, refs); err != nil {
+		tx.Rollback()
+		return err
 	}
 
 	if uid > 0 {
@@ -290,6 +356,49 @@ func GetFilesByParentIDs(ids []uint, uid uint) ([]File, error) {
 	return files, result.Error
 }
 
+// MigrateScope 存储策略迁移的筛选条件，零值表示不限
+type MigrateScope struct {
+	UserID  uint   // 只包含此用户的文件
+	GroupID uint   // 只包含此用户组下用户的文件
+	Folders []uint // 只包含这些目录下的文件
+}
+
+// files 为文件查询附加筛选条件
+func (scope *MigrateScope) files(tx *gorm.DB) *gorm.DB {
+	if scope.UserID != 0 {
+		tx = tx.Where("files.user_id = ?", scope.UserID)
+	}
+	if scope.GroupID != 0 {
+		tx = tx.Joins("join users on users.id = files.user_id").Where("users.group_id = ?", scope.GroupID)
+	}
+	if len(scope.Folders) > 0 {
+		tx = tx.Where("files.folder_id in (?)", scope.Folders)
+	}
+	return tx
+}
+
+// versions 为历史版本查询附加筛选条件，目录以所属文件为准
+func (scope *MigrateScope) versions(tx *gorm.DB) *gorm.DB {
+	if scope.UserID != 0 {
+		tx = tx.Where("file_versions.user_id = ?", scope.UserID)
+	}
+	if scope.GroupID != 0 {
+		tx = tx.Joins("join users on users.id = file_versions.user_id").Where("users.group_id = ?", scope.GroupID)
+	}
+	if len(scope.Folders) > 0 {
+		tx = tx.Joins("join files on files.id = file_versions.file_id").Where("files.folder_id in (?)", scope.Folders)
+	}
+	return tx
+}
+
+// GetFilesByPolicy 按ID顺序分批列出使用给定存储策略且符合筛选条件的文件，包括回收站中的文件
+func GetFilesByPolicy(policyID, afterID uint, scope *MigrateScope, limit int) ([]File, error) {
+	var files []File
+	tx := DB.Unscoped().Where("files.policy_id = ? and files.id > ? and files.upload_session_id is null", policyID, afterID)
+	result := scope.files(tx).Select("files.*").Order("files.id asc").Limit(limit).Find(&files)
+	return files, result.Error
+}
+
 // GetFilesByUploadSession 查找上传会话对应的文件
 func GetFilesByUploadSession(sessionID string, uid uint) (*File, error) {
 	file := File{}
@@ -321,10 +430,18 @@ func (file *File) UpdatePicInfo(value string) error {
 
 // UpdateMetadata 新增或修改文件的元信息
 func (file *File) UpdateMetadata(data map[string]string) error {
+	return file.PatchMetadata(data, nil)
+}
+
+// PatchMetadata 新增或修改文件的元信息，并删除 remove 中给定的项
+func (file *File) PatchMetadata(data map[string]string, remove []string) error {
 	if file.MetadataSerialized == nil {
 		file.MetadataSerialized = make(map[string]string)
 	}
 
+	for _, k := range remove {
+		delete(file.MetadataSerialized, k)
+	}
 	for k, v := range data {
 		file.MetadataSerialized[k] = v
 	}
@@ -367,6 +484,15 @@ func (file *File) UpdateSize(value uint64) error {
 		return res.Error
 	}
 
+	// 源文件内容已改变，不再参与去重
+	if file.BlobID != 0 {
+		if err := tx.Model(&Blob{}).Where("id = ?", file.BlobID).
+			UpdateColumns(map[string]interface{}{"hash": nil, "size": value}).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
 	if err := user.ChangeStorage(tx, operator, sizeDelta); err != nil {
 		tx.Rollback()
 		return err
@@ -382,10 +508,30 @@ func (file *File) UpdateSourceName(value string) error {
 		return err
 	}
 
-	return DB.Model(&file).Set("gorm:association_autoupdate", false).Updates(map[string]interface{}{
+	updates := map[string]interface{}{
 		"source_name": value,
 		"metadata":    file.Metadata,
-	}).Error
+	}
+	if file.BlobID == 0 {
+		return DB.Model(&file).Set("gorm:association_autoupdate", false).Updates(updates).Error
+	}
+
+	// 不再引用原有的源文件
+	tx := DB.Begin()
+	replacedBlob := file.BlobID
+	updates["blob_id"] = 0
+	if err := tx.Model(&file).Set("gorm:association_autoupdate", false).Updates(updates).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := releaseBlobs(tx, map[uint]int{replacedBlob: 1}); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	file.BlobID = 0
+	return tx.Commit().Error
 }
 
 func (file *File) PopChunkToFile(lastModified *time.Time, picInfo string) error {
@@ -0,0 +1,235 @@
+package model
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/cloudreve/Cloudreve/v3/pkg/search/query"
+)
+
+// maxTagDepth 分类标签间互相引用的最大深度
+const maxTagDepth = 4
+
+// FileSearchOptions 文件搜索选项
+type FileSearchOptions struct {
+	// Parents 限定文件所在目录，为空时不限定
+	Parents []uint
+	// OrderBy 排序字段，可选 name, size, created_at, updated_at，为空时按ID排序
+	OrderBy string
+	// Desc 是否倒序排列
+	Desc bool
+	// Page 页码，从1开始，为0时返回所有结果
+	Page     int
+	PageSize int
+
+	// ResolvePath 返回路径对应目录及其所有子目录的ID，用于 path: 条件，
+	// 为空时不支持此条件
+	ResolvePath func(path string) ([]uint, error)
+	// ResolveTag 返回给定名称的分类标签对应的查询，用于 tag: 条件，
+	// 为空时不支持此条件
+	ResolveTag func(name string) (query.Expr, error)
+}
+
+// fileSearchOrders 可用于排序的字段
+var fileSearchOrders = map[string]bool{
+	"name":       true,
+	"size":       true,
+	"created_at": true,
+	"updated_at": true,
+}
+
+// SearchFiles 根据查询条件搜索用户的文件，返回当前页的文件和结果总数
+func SearchFiles(uid uint, expr query.Expr, opts *FileSearchOptions) ([]File, int, error) {
+	var (
+		files []File
+		total int
+	)
+
+	compiler := &fileQueryCompiler{uid: uid, opts: opts, tags: make(map[string]bool)}
+	conditions, args, err := compiler.compile(expr)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	dbChain := DB.Where("user_id = ?", uid)
+	if len(opts.Parents) > 0 {
+		dbChain = dbChain.Where("folder_id in (?)", opts.Parents)
+	}
+	dbChain = dbChain.Where(conditions, args...)
+
+	// 计算总数用于分页
+	if err := dbChain.Model(&File{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	direction := "asc"
+	if opts.Desc {
+		direction = "desc"
+	}
+	if opts.OrderBy != "" {
+		if !fileSearchOrders[opts.OrderBy] {
+			return nil, 0, fmt.Errorf("%w: unknown order %q", query.ErrBadValue, opts.OrderBy)
+		}
+		dbChain = dbChain.Order(opts.OrderBy + " " + direction)
+	}
+	dbChain = dbChain.Order("id " + direction)
+
+	if opts.Page > 0 {
+		dbChain = dbChain.Limit(opts.PageSize).Offset((opts.Page - 1) * opts.PageSize)
+	}
+
+	err = dbChain.Find(&files).Error
+	return files, total, err
+}
+
+// fileQueryCompiler 将查询转换为 SQL 条件
+type fileQueryCompiler struct {
+	uid  uint
+	opts *FileSearchOptions
+	// tags 正在展开的分类标签，用于检测循环引用
+	tags map[string]bool
+}
+
+// literalEscaper 转义 LIKE 匹配中的所有特殊字符
+var literalEscaper = strings.NewReplacer("!", "!!", "_", "!_", "%", "!%")
+
+// likeEscaper 转义 LIKE 匹配中的特殊字符，以 ! 作为转义符以兼容各数据库
+var likeEscaper = strings.NewReplacer("!", "!!", "_", "!_", "%", "%", "*", "%", "?", "_")
+
+// likePattern 将通配符转换为 LIKE 匹配模式，% 与 * 等价以兼容旧版分类标签
+func likePattern(glob string, contains bool) string {
+	pattern := likeEscaper.Replace(glob)
+	if contains {
+		pattern = "%" + pattern + "%"
+	}
+	return pattern
+}
+
+func (c *fileQueryCompiler) join(exprs []query.Expr, op string) (string, []interface{}, error) {
+	var (
+		parts = make([]string, 0, len(exprs))
+		args  []interface{}
+	)
+	for _, expr := range exprs {
+		sql, subArgs, err := c.compile(expr)
+		if err != nil {
+			return "", nil, err
+		}
+		parts = append(parts, sql)
+		args = append(args, subArgs...)
+	}
+	return "(" + strings.Join(parts, " "+op+" ") + ")", args, nil
+}
+
+func (c *fileQueryCompiler) compile(expr query.Expr) (string, []interface{}, error) {
+	switch e := expr.(type) {
+	case query.And:
+		return c.join(e, "AND")
+	case query.Or:
+		return c.join(e, "OR")
+	case query.Not:
+		sql, args, err := c.compile(e.Expr)
+		return "NOT " + sql, args, err
+	case query.Name:
+		return "(name LIKE ? ESCAPE '!')", []interface{}{likePattern(e.Glob, e.Contains)}, nil
+	case query.Ext:
+		conditions := make([]string, len(e))
+		args := make([]interface{}, len(e))
+		for i, ext := range e {
+			conditions[i] = "name LIKE ? ESCAPE '!'"
+			args[i] = "%." + literalEscaper.Replace(ext)
+		}
+		return "(" + strings.Join(conditions, " OR ") + ")", args, nil
+	case query.Size:
+		var (
+			conditions []string
+			args       []interface{}
+		)
+		if e.Min != nil {
+			conditions = append(conditions, "size >= ?")
+			args = append(args, *e.Min)
+		}
+		if e.Max != nil {
+			conditions = append(conditions, "size <= ?")
+			args = append(args, *e.Max)
+		}
+		return andConditions(conditions), args, nil
+	case query.Time:
+		column := "updated_at"
+		if e.Field == query.FieldCreated {
+			column = "created_at"
+		}
+
+		var (
+			conditions []string
+			args       []interface{}
+		)
+		if e.From != nil {
+			conditions = append(conditions, column+" >= ?")
+			args = append(args, *e.From)
+		}
+		if e.To != nil {
+			conditions = append(conditions, column+" < ?")
+			args = append(args, *e.To)
+		}
+		return andConditions(conditions), args, nil
+	case query.Policy:
+		if id, err := strconv.ParseUint(string(e), 10, 64); err == nil {
+			return "(policy_id = ?)", []interface{}{id}, nil
+		}
+		policies := DB.NewScope(&Policy{}).TableName()
+		return "(policy_id IN (SELECT id FROM " + policies + " WHERE name = ? AND deleted_at IS NULL))",
+			[]interface{}{string(e)}, nil
+	case query.Path:
+		if c.opts.ResolvePath == nil {
+			return "", nil, fmt.Errorf("%w: path is not supported here", query.ErrBadValue)
+		}
+		folders, err := c.opts.ResolvePath(string(e))
+		if err != nil {
+			return "", nil, err
+		}
+		if len(folders) == 0 {
+			return "(1 = 0)", nil, nil
+		}
+		return "(folder_id IN (?))", []interface{}{folders}, nil
+	case query.Shared:
+		shares := DB.NewScope(&Share{}).TableName()
+		return "(id IN (SELECT source_id FROM " + shares + " WHERE user_id = ? AND is_dir = ? AND deleted_at IS NULL))",
+			[]interface{}{c.uid, false}, nil
+	case query.Tag:
+		return c.compileTag(string(e))
+	}
+
+	return "", nil, fmt.Errorf("%w: unsupported condition %T", query.ErrBadValue, expr)
+}
+
+// andConditions 合并多个条件，没有条件时匹配所有记录
+func andConditions(conditions []string) string {
+	if len(conditions) == 0 {
+		return "(1 = 1)"
+	}
+	return "(" + strings.Join(conditions, " AND ") + ")"
+}
+
+// compileTag 展开分类标签的查询
+func (c *fileQueryCompiler) compileTag(name string) (string, []interface{}, error) {
+	if c.opts.ResolveTag == nil {
+		return "", nil, fmt.Errorf("%w: tag is not supported here", query.ErrBadValue)
+	}
+	if c.tags[name] {
+		return "", nil, fmt.Errorf("%w: tag %q references itself", query.ErrBadValue, name)
+	}
+	if len(c.tags) >= maxTagDepth {
+		return "", nil, fmt.Errorf("%w: tags are nested too deep", query.ErrBadValue)
+	}
+
+	expr, err := c.opts.ResolveTag(name)
+	if err != nil {
+		return "", nil, err
+	}
+
+	c.tags[name] = true
+	defer delete(c.tags, name)
+	return c.compile(expr)
+}
@@ -0,0 +1,160 @@
+package model
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/cloudreve/Cloudreve/v3/pkg/search/query"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchFiles(t *testing.T) {
+	asserts := assert.New(t)
+	expr, _ := query.Parse("report ext:pdf")
+
+	// 成功
+	{
+		mock.ExpectQuery("SELECT count(.+)").WithArgs(1, 2, "%report%", "%.pdf").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+		mock.ExpectQuery("SELECT(.+)ORDER BY size desc,id desc LIMIT 2 OFFSET 2").WithArgs(1, 2, "%report%", "%.pdf").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+		files, total, err := SearchFiles(1, expr, &FileSearchOptions{
+			Parents:  []uint{2},
+			OrderBy:  "size",
+			Desc:     true,
+			Page:     2,
+			PageSize: 2,
+		})
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.Equal(3, total)
+		asserts.Len(files, 1)
+	}
+
+	// 无效排序
+	{
+		mock.ExpectQuery("SELECT count(.+)").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+		_, _, err := SearchFiles(1, expr, &FileSearchOptions{OrderBy: "password"})
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.ErrorIs(err, query.ErrInvalid)
+	}
+
+	// 数据库错误
+	{
+		mock.ExpectQuery("SELECT count(.+)").WillReturnError(errors.New("error"))
+		_, _, err := SearchFiles(1, expr, &FileSearchOptions{})
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Error(err)
+	}
+
+	// 不支持的条件
+	{
+		for _, q := range []string{"path:/docs", "tag:Photos"} {
+			expr, _ := query.Parse(q)
+			_, _, err := SearchFiles(1, expr, &FileSearchOptions{})
+			asserts.ErrorIs(err, query.ErrInvalid)
+		}
+	}
+}
+
+func TestSearchFilesSQLite(t *testing.T) {
+	asserts := assert.New(t)
+	DB, _ = gorm.Open("sqlite", ":memory:")
+	defer func() {
+		DB = mockDB
+	}()
+	DB.AutoMigrate(&File{}, &Share{}, &Policy{})
+
+	policy := &Policy{Name: "Local"}
+	asserts.NoError(DB.Create(policy).Error)
+
+	day := time.Date(2023, 5, 1, 12, 0, 0, 0, time.Local)
+	files := []*File{
+		{Name: "report.pdf", Size: 2 << 20, FolderID: 1, PolicyID: policy.ID},
+		{Name: "photo_1.JPG", Size: 100, FolderID: 2, PolicyID: policy.ID + 1},
+		{Name: "photo%2.png", Size: 2 << 10, FolderID: 2, PolicyID: policy.ID},
+		{Name: "notes.txt", Size: 10, FolderID: 3, PolicyID: policy.ID},
+	}
+	for i, file := range files {
+		file.UserID = 1
+		file.SourceName = file.Name
+		asserts.NoError(DB.Create(file).Error)
+		asserts.NoError(DB.Model(file).UpdateColumn("updated_at", day.AddDate(0, i, 0)).Error)
+	}
+	other := &File{Name: "report.pdf", UserID: 2, SourceName: "other"}
+	asserts.NoError(DB.Create(other).Error)
+	asserts.NoError(DB.Create(&Share{UserID: 1, SourceID: files[3].ID}).Error)
+	asserts.NoError(DB.Create(&Share{UserID: 1, SourceID: files[2].ID, IsDir: true}).Error)
+
+	tags := map[string]string{
+		"Images": "ext:jpg,png",
+		"Loop":   "tag:Loop",
+	}
+	opts := &FileSearchOptions{
+		ResolvePath: func(path string) ([]uint, error) {
+			if path == "/photos" {
+				return []uint{2}, nil
+			}
+			return nil, nil
+		},
+		ResolveTag: func(name string) (query.Expr, error) {
+			return query.Parse(tags[name])
+		},
+	}
+
+	testCases := []struct {
+		query string
+		names []string
+	}{
+		{"report", []string{"report.pdf"}},
+		{"photo_", []string{"photo_1.JPG"}},
+		{`"o%2"`, []string{"photo%2.png"}},
+		{"name:photo?1.*", []string{"photo_1.JPG"}},
+		{"ext:jpg,txt", []string{"photo_1.JPG", "notes.txt"}},
+		{"size:>1K", []string{"report.pdf", "photo%2.png"}},
+		{"size:10..100", []string{"photo_1.JPG", "notes.txt"}},
+		{"modified:2023-06", []string{"photo_1.JPG"}},
+		{"modified:>=2023-07-01 -ext:txt", []string{"photo%2.png"}},
+		{"policy:Local", []string{"report.pdf", "photo%2.png", "notes.txt"}},
+		{"NOT policy:1", []string{"photo_1.JPG"}},
+		{"path:/photos", []string{"photo_1.JPG", "photo%2.png"}},
+		{"path:/missing", nil},
+		{"is:shared", []string{"notes.txt"}},
+		{"tag:Images OR report", []string{"report.pdf", "photo_1.JPG", "photo%2.png"}},
+	}
+
+	for _, testCase := range testCases {
+		expr, err := query.Parse(testCase.query)
+		asserts.NoError(err, testCase.query)
+		res, total, err := SearchFiles(1, expr, opts)
+		asserts.NoError(err, testCase.query)
+		asserts.Equal(len(testCase.names), total, testCase.query)
+
+		var names []string
+		for _, file := range res {
+			names = append(names, file.Name)
+		}
+		asserts.Equal(testCase.names, names, testCase.query)
+	}
+
+	// 分类标签循环引用
+	{
+		expr, _ := query.Parse("tag:Loop")
+		_, _, err := SearchFiles(1, expr, opts)
+		asserts.ErrorIs(err, query.ErrInvalid)
+	}
+
+	// 分页与排序
+	{
+		expr, _ := query.Parse("-report")
+		res, total, err := SearchFiles(1, expr, &FileSearchOptions{OrderBy: "name", Page: 1, PageSize: 2})
+		asserts.NoError(err)
+		asserts.Equal(3, total)
+		asserts.Len(res, 2)
+		asserts.Equal("notes.txt", res[0].Name)
+		asserts.Equal("photo%2.png", res[1].Name)
+	}
+}
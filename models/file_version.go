@@ -0,0 +1,282 @@
+package model
+
+import (
+	"errors"
+	"time"
+
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+	"github.com/jinzhu/gorm"
+)
+
+// FileVersion 文件的历史版本，保存文件被覆盖前的内容
+type FileVersion struct {
+	gorm.Model
+	FileID     uint   `gorm:"index:version_file_id"`
+	UserID     uint   `gorm:"index:version_user_id"`
+	SourceName string `gorm:"type:text"`
+	Size       uint64
+	PicInfo    string
+	PolicyID   uint
+	BlobID     uint
+	CountQuota bool // 是否计入用户已用容量
+}
+
+// NewFileVersion 根据文件当前的内容创建历史版本
+func NewFileVersion(file *File, countQuota bool) *FileVersion {
+	return &FileVersion{
+		FileID:     file.ID,
+		UserID:     file.UserID,
+		SourceName: file.SourceName,
+		Size:       file.Size,
+		PicInfo:    file.PicInfo,
+		PolicyID:   file.PolicyID,
+		BlobID:     file.BlobID,
+		CountQuota: countQuota,
+	}
+}
+
+// quotaSize 返回此版本占用的用户容量
+func (version *FileVersion) quotaSize() uint64 {
+	if version == nil || !version.CountQuota {
+		return 0
+	}
+	return version.Size
+}
+
+// AsFile 以历史版本的内容构建文件对象，用于下载、预览此版本
+func (version *FileVersion) AsFile(file *File) File {
+	res := *file
+	res.SourceName = version.SourceName
+	res.Size = version.Size
+	res.PicInfo = version.PicInfo
+	res.PolicyID = version.PolicyID
+	res.BlobID = version.BlobID
+	res.Policy = Policy{}
+	res.UpdatedAt = version.CreatedAt
+	return res
+}
+
+// ExpiresAt 根据用户组设定的保留天数计算历史版本的过期时间，不限制时返回零值
+func (version *FileVersion) ExpiresAt(group *Group) time.Time {
+	if group.OptionsSerialized.VersionRetention <= 0 {
+		return time.Time{}
+	}
+	return version.CreatedAt.Add(time.Duration(group.OptionsSerialized.VersionRetention) * 24 * time.Hour)
+}
+
+// UpdateWithVersion 将文件内容更新为新的源文件，并将原有内容保存为历史版本
+func (file *File) UpdateWithVersion(version *FileVersion, sourceName string, size uint64) error {
+	tx := DB.Begin()
+
+	if err := tx.Create(version).Error; err != nil {
+		util.Log().Warning("Failed to insert file version: %s", err)
+		tx.Rollback()
+		return err
+	}
+
+	if err := file.resetThumb(); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	delta := int64(size) - int64(file.Size) + int64(version.quotaSize())
+	res := tx.Model(file).
+		Where("size = ? and source_name = ?", file.Size, file.SourceName).
+		Set("gorm:association_autoupdate", false).
+		Updates(map[string]interface{}{
+			"source_name": sourceName,
+			"size":        size,
+			"pic_info":    "",
+			"blob_id":     0,
+			"metadata":    file.Metadata,
+		})
+	if res.Error != nil {
+		tx.Rollback()
+		return res.Error
+	}
+
+	if res.RowsAffected == 0 {
+		tx.Rollback()
+		return errors.New("file content is dirty")
+	}
+
+	if err := changeStorageDelta(tx, file.UserID, delta); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	file.SourceName = sourceName
+	file.Size = size
+	file.PicInfo = ""
+	file.BlobID = 0
+	return tx.Commit().Error
+}
+
+// RestoreVersion 将文件内容还原为给定的历史版本。previous 不为空时，
+// 会将文件当前的内容保存为新的历史版本
+func (file *File) RestoreVersion(version *FileVersion, previous *FileVersion) error {
+	tx := DB.Begin()
+
+	if previous != nil {
+		if err := tx.Create(previous).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if err := file.resetThumb(); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	delta := int64(version.Size) - int64(file.Size) +
+		int64(previous.quotaSize()) - int64(version.quotaSize())
+	replacedBlob := file.BlobID
+	res := tx.Model(file).
+		Where("size = ? and source_name = ?", file.Size, file.SourceName).
+		Set("gorm:association_autoupdate", false).
+		Updates(map[string]interface{}{
+			"source_name": version.SourceName,
+			"size":        version.Size,
+			"pic_info":    version.PicInfo,
+			"policy_id":   version.PolicyID,
+			"blob_id":     version.BlobID,
+			"metadata":    file.Metadata,
+		})
+	if res.Error != nil {
+		tx.Rollback()
+		return res.Error
+	}
+
+	if res.RowsAffected == 0 {
+		tx.Rollback()
+		return errors.New("file content is dirty")
+	}
+
+	if err := tx.Unscoped().Delete(version).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// 当前内容未保存为历史版本时，不再引用其源文件
+	if previous == nil {
+		if err := releaseBlobs(tx, map[uint]int{replacedBlob: 1}); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if err := changeStorageDelta(tx, file.UserID, delta); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	file.SourceName = version.SourceName
+	file.Size = version.Size
+	file.PicInfo = version.PicInfo
+	file.PolicyID = version.PolicyID
+	file.BlobID = version.BlobID
+	file.Policy = Policy{}
+	return tx.Commit().Error
+}
+
+// changeStorageDelta 根据容量变化量更新用户已用容量
+func changeStorageDelta(tx *gorm.DB, uid uint, delta int64) error {
+	if delta == 0 {
+		return nil
+	}
+
+	user := &User{}
+	user.ID = uid
+	if delta > 0 {
+		return user.ChangeStorage(tx, "+", uint64(delta))
+	}
+	return user.ChangeStorage(tx, "-", uint64(-delta))
+}
+
+// DeleteFileVersions 删除历史版本记录，并归还其占用的用户容量
+func DeleteFileVersions(versions []FileVersion) error {
+	tx := DB.Begin()
+	refund := make(map[uint]uint64)
+	refs := make(map[uint]int)
+	for i := range versions {
+		if err := tx.Unscoped().Delete(&versions[i]).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+		refund[versions[i].UserID] += versions[i].quotaSize()
+		refs[versions[i].BlobID]++
+	}
+
+	// 释放对源文件的引用
+	if err := releaseBlobs(tx, refs); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	for uid, size := range refund {
+		if err := changeStorageDelta(tx, uid, -int64(size)); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit().Error
+}
+
+// GetVersionsByFileID 列出文件的全部历史版本，新版本在前
+func GetVersionsByFileID(fileID, uid uint) ([]FileVersion, error) {
+	var versions []FileVersion
+	result := DB.Where("file_id = ? and user_id = ?", fileID, uid).Order("id desc").Find(&versions)
+	return versions, result.Error
+}
+
+// GetVersionsByFileIDs 列出多个文件的全部历史版本
+func GetVersionsByFileIDs(fileIDs []uint) ([]FileVersion, error) {
+	var versions []FileVersion
+	result := DB.Where("file_id in (?)", fileIDs).Find(&versions)
+	return versions, result.Error
+}
+
+// GetFileVersionByID 根据ID、文件ID和用户ID查找历史版本
+func GetFileVersionByID(id, fileID, uid uint) (*FileVersion, error) {
+	var version FileVersion
+	result := DB.Where("id = ? and file_id = ? and user_id = ?", id, fileID, uid).First(&version)
+	return &version, result.Error
+}
+
+// GetVersionsByPolicy 按ID顺序分批列出使用给定存储策略且符合筛选条件的历史版本
+func GetVersionsByPolicy(policyID, afterID uint, scope *MigrateScope, limit int) ([]FileVersion, error) {
+	var versions []FileVersion
+	tx := DB.Where("file_versions.policy_id = ? and file_versions.id > ?", policyID, afterID)
+	result := scope.versions(tx).Select("file_versions.*").Order("file_versions.id asc").Limit(limit).Find(&versions)
+	return versions, result.Error
+}
+
+// GetExpiredFileVersions 列出所有已超过用户组保留期限的历史版本
+func GetExpiredFileVersions() ([]FileVersion, error) {
+	var groups []Group
+	if err := DB.Find(&groups).Error; err != nil {
+		return nil, err
+	}
+
+	res := make([]FileVersion, 0)
+	for _, group := range groups {
+		if group.OptionsSerialized.VersionRetention <= 0 {
+			continue
+		}
+
+		expires := time.Now().Add(-time.Duration(group.OptionsSerialized.VersionRetention) * 24 * time.Hour)
+		var versions []FileVersion
+		if err := DB.Select("file_versions.*").
+			Joins("join users on users.id = file_versions.user_id").
+			Where("users.group_id = ? and file_versions.created_at < ?", group.ID, expires).
+			Find(&versions).Error; err != nil {
+			return nil, err
+		}
+
+		res = append(res, versions...)
+	}
+
+	return res, nil
+}
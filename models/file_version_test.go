@@ -0,0 +1,128 @@
+package model
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFile_UpdateWithVersion(t *testing.T) {
+	asserts := assert.New(t)
+	file := File{SourceName: "old", Size: 1, UserID: 1}
+	file.ID = 1
+
+	// 无法插入版本记录
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)file_versions(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		asserts.Error(file.UpdateWithVersion(NewFileVersion(&file, true), "new", 2))
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 文件已被修改
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)file_versions(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE(.+)files(.+)").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectRollback()
+		asserts.Error(file.UpdateWithVersion(NewFileVersion(&file, true), "new", 2))
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 成功
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)file_versions(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("UPDATE(.+)files(.+)").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("UPDATE(.+)users(.+)storage(.+)").WithArgs(2, sqlmock.AnyArg(), 1).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		asserts.NoError(file.UpdateWithVersion(NewFileVersion(&file, true), "new", 2))
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Equal("new", file.SourceName)
+		asserts.EqualValues(2, file.Size)
+	}
+}
+
+func TestFileVersion_AsFile(t *testing.T) {
+	asserts := assert.New(t)
+	file := &File{Name: "1.txt", SourceName: "current", Size: 10, PolicyID: 1}
+	file.Policy.ID = 1
+	version := &FileVersion{SourceName: "old", Size: 5, PolicyID: 2}
+	version.CreatedAt = time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	res := version.AsFile(file)
+	asserts.Equal("1.txt", res.Name)
+	asserts.Equal("old", res.SourceName)
+	asserts.EqualValues(5, res.Size)
+	asserts.EqualValues(2, res.PolicyID)
+	asserts.EqualValues(0, res.Policy.ID)
+	asserts.Equal(version.CreatedAt, res.UpdatedAt)
+	asserts.Equal("current", file.SourceName)
+}
+
+func TestFileVersion_ExpiresAt(t *testing.T) {
+	asserts := assert.New(t)
+	version := FileVersion{}
+	version.CreatedAt = time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	asserts.True(version.ExpiresAt(&Group{}).IsZero())
+	group := &Group{OptionsSerialized: GroupOption{VersionRetention: 2}}
+	asserts.Equal(time.Date(2022, 1, 3, 0, 0, 0, 0, time.UTC), version.ExpiresAt(group))
+}
+
+func TestFileVersionLifecycleSQLite(t *testing.T) {
+	asserts := assert.New(t)
+	DB, _ = gorm.Open("sqlite", ":memory:")
+	defer func() {
+		DB = mockDB
+	}()
+	DB.AutoMigrate(&User{}, &Group{}, &File{}, &FileVersion{})
+
+	asserts.NoError(DB.Create(&Group{OptionsSerialized: GroupOption{VersionRetention: 1}}).Error)
+	user := &User{Email: "1@cloudreve.org", GroupID: 1, Storage: 1}
+	asserts.NoError(DB.Create(user).Error)
+	file := &File{Name: "1.txt", SourceName: "v1", UserID: user.ID, Size: 1, PolicyID: 1}
+	asserts.NoError(DB.Create(file).Error)
+
+	// 更新内容，不计入容量
+	asserts.NoError(file.UpdateWithVersion(NewFileVersion(file, false), "v2", 3))
+	versions, err := GetVersionsByFileID(file.ID, user.ID)
+	asserts.NoError(err)
+	asserts.Len(versions, 1)
+	asserts.Equal("v1", versions[0].SourceName)
+	refreshed, _ := GetUserByID(user.ID)
+	asserts.EqualValues(3, refreshed.Storage)
+
+	// 还原版本，当前内容计入容量
+	asserts.NoError(file.RestoreVersion(&versions[0], NewFileVersion(file, true)))
+	files, err := GetFilesByIDs([]uint{file.ID}, user.ID)
+	asserts.NoError(err)
+	asserts.Equal("v1", files[0].SourceName)
+	asserts.EqualValues(1, files[0].Size)
+	refreshed, _ = GetUserByID(user.ID)
+	asserts.EqualValues(4, refreshed.Storage)
+
+	// 过期的历史版本
+	versions, err = GetVersionsByFileIDs([]uint{file.ID})
+	asserts.NoError(err)
+	asserts.Len(versions, 1)
+	expired, err := GetExpiredFileVersions()
+	asserts.NoError(err)
+	asserts.Len(expired, 0)
+	asserts.NoError(DB.Model(&versions[0]).UpdateColumn("created_at", time.Now().Add(-48*time.Hour)).Error)
+	expired, err = GetExpiredFileVersions()
+	asserts.NoError(err)
+	asserts.Len(expired, 1)
+
+	// 删除版本，归还容量
+	asserts.NoError(DeleteFileVersions(expired))
+	versions, err = GetVersionsByFileID(file.ID, user.ID)
+	asserts.NoError(err)
+	asserts.Len(versions, 0)
+	refreshed, _ = GetUserByID(user.ID)
+	asserts.EqualValues(1, refreshed.Storage)
+}
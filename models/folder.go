@@ -1,6 +1,7 @@
 package model
 
 import (
+	"encoding/json"
 	"errors"
 	"path"
 	"time"
@@ -16,10 +17,35 @@ type Folder struct {
 	Name     string `gorm:"unique_index:idx_only_one_name"`
 	ParentID *uint  `gorm:"index:parent_id;unique_index:idx_only_one_name"`
 	OwnerID  uint   `gorm:"index:owner_id"`
+	Metadata string `gorm:"type:text"`
 
 	// 数据库忽略字段
-	Position      string `gorm:"-"`
-	WebdavDstName string `gorm:"-"`
+	Position           string            `gorm:"-"`
+	WebdavDstName      string            `gorm:"-"`
+	MetadataSerialized map[string]string `gorm:"-"`
+}
+
+// AfterFind 找到目录后的钩子
+func (folder *Folder) AfterFind() (err error) {
+	// 反序列化目录元数据
+	if folder.Metadata != "" {
+		err = json.Unmarshal([]byte(folder.Metadata), &folder.MetadataSerialized)
+	} else {
+		folder.MetadataSerialized = make(map[string]string)
+	}
+
+	return
+}
+
+// BeforeSave 保存目录前的钩子
+func (folder *Folder) BeforeSave() (err error) {
+	if len(folder.MetadataSerialized) > 0 {
+		metaValue, err := json.Marshal(&folder.MetadataSerialized)
+		folder.Metadata = string(metaValue)
+		return err
+	}
+
+	return nil
 }
 
 // Create 创建目录
@@ -175,7 +201,7 @@ func (folder *Folder) MoveOrCopyFileTo(files []uint, dstFolder *Folder, isCopy b
 				oldFile.Name = dstFolder.WebdavDstName
 			}
 
-			if err := DB.Create(&oldFile).Error; err != nil {
+			if err := oldFile.createCopy(); err != nil {
 				return copiedSize, err
 			}
 
@@ -277,7 +303,7 @@ func (folder *Folder) CopyFolderTo(folderID uint, dstFolder *Folder) (size uint6
 		oldFile.Model = gorm.Model{}
 		oldFile.FolderID = newIDCache[oldFile.FolderID]
 		oldFile.UserID = dstFolder.OwnerID
-		if err := DB.Create(&oldFile).Error; err != nil {
+		if err := oldFile.createCopy(); err != nil {
 			return size, err
 		}
 
@@ -288,6 +314,120 @@ func (folder *Folder) CopyFolderTo(folderID uint, dstFolder *Folder) (size uint6
 
 }
 
+// SaveObjects 在一个事务中将转存的目录及文件记录创建至此目录下，任一记录创建失败时全部回滚。
+// folders 中父目录需排在子目录之前，父目录不在 folders 中的目录及文件直接创建于此目录下；
+// copies 为与原文件共用源文件的记录，uploads 为内容已复制至新源文件的记录，hashes 为其对应的内容摘要，
+// 摘要不为空时关联至相同内容的 Blob。成功时更新传入的文件记录，并返回不再需要的新源文件路径
+func (folder *Folder) SaveObjects(folders []Folder, copies, uploads []File, hashes []string) ([]string, error) {
+	var (
+		savedCopies, savedUploads []File
+		duplicates                []string
+	)
+	_, err := retryOnBlobConflict(func() (string, error) {
+		// 重试时从原始记录开始
+		savedCopies = append([]File(nil), copies...)
+		savedUploads = append([]File(nil), uploads...)
+		var err error
+		duplicates, err = folder.saveObjects(folders, savedCopies, savedUploads, hashes)
+		return "", err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	copy(copies, savedCopies)
+	copy(uploads, savedUploads)
+	return duplicates, nil
+}
+
+func (folder *Folder) saveObjects(folders []Folder, copies, uploads []File, hashes []string) ([]string, error) {
+	tx := DB.Begin()
+
+	// 复制目录结构，记录源目录对应的新目录
+	newIDs := make(map[uint]uint, len(folders))
+	for _, src := range folders {
+		parentID := folder.ID
+		if src.ParentID != nil {
+			if id, ok := newIDs[*src.ParentID]; ok {
+				parentID = id
+			}
+		}
+
+		newFolder := &Folder{Name: src.Name, ParentID: &parentID, OwnerID: folder.OwnerID}
+		if err := tx.Create(newFolder).Error; err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		newIDs[src.ID] = newFolder.ID
+	}
+
+	var size uint64
+	place := func(file *File) {
+		parentID, ok := newIDs[file.FolderID]
+		if !ok {
+			parentID = folder.ID
+		}
+
+		file.Model = gorm.Model{}
+		file.FolderID = parentID
+		file.UserID = folder.OwnerID
+		size += file.Size
+	}
+
+	// 共用源文件的记录，同一源文件在本次转存中只创建一个 Blob
+	sourceBlobs := make(map[string]uint)
+	for i := range copies {
+		file := &copies[i]
+		place(file)
+		if file.BlobID == 0 {
+			file.BlobID = sourceBlobs[file.SourceName]
+		}
+
+		if err := file.acquireBlob(tx); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		sourceBlobs[file.SourceName] = file.BlobID
+
+		if err := tx.Create(file).Error; err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	// 复制了内容的记录，相同内容已存在时改为引用已有的源文件
+	var duplicates []string
+	for i := range uploads {
+		file := &uploads[i]
+		place(file)
+		if hashes[i] != "" {
+			duplicate, err := file.acquireBlobByHash(tx, hashes[i])
+			if err != nil {
+				tx.Rollback()
+				return nil, err
+			}
+
+			if duplicate != "" {
+				duplicates = append(duplicates, duplicate)
+			}
+		}
+
+		if err := tx.Create(file).Error; err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	user := &User{}
+	user.ID = folder.OwnerID
+	if err := user.ChangeStorage(tx, "+", size); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	return duplicates, tx.Commit().Error
+}
+
 // MoveFolderTo 将folder目录下的dirs子目录复制或移动到dstFolder，
 // 返回此过程中增加的容量
 func (folder *Folder) MoveFolderTo(dirs []uint, dstFolder *Folder) error {
@@ -323,6 +463,26 @@ func (folder *Folder) Rename(new string) error {
 	return DB.Model(&folder).UpdateColumn("name", new).Error
 }
 
+// PatchMetadata 新增或修改目录的元信息，并删除 remove 中给定的项
+func (folder *Folder) PatchMetadata(data map[string]string, remove []string) error {
+	if folder.MetadataSerialized == nil {
+		folder.MetadataSerialized = make(map[string]string)
+	}
+
+	for _, k := range remove {
+		delete(folder.MetadataSerialized, k)
+	}
+	for k, v := range data {
+		folder.MetadataSerialized[k] = v
+	}
+	metaValue, err := json.Marshal(&folder.MetadataSerialized)
+	if err != nil {
+		return err
+	}
+
+	return DB.Model(&folder).UpdateColumn("metadata", string(metaValue)).Error
+}
+
 /*
 	实现 FileInfo.FileInfo 接口
 	TODO 测试
@@ -226,15 +226,17 @@ func TestFolder_MoveOrCopyFileTo(t *testing.T) {
 				1,
 				1,
 			).WillReturnRows(
-			sqlmock.NewRows([]string{"id", "size", "upload_session_id"}).
-				AddRow(1, 10, nil).
-				AddRow(2, 20, nil).
-				AddRow(2, 20, &folder.Name),
+			sqlmock.NewRows([]string{"id", "size", "blob_id", "upload_session_id"}).
+				AddRow(1, 10, 1, nil).
+				AddRow(2, 20, 2, nil).
+				AddRow(2, 20, 2, &folder.Name),
 		)
 		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)blobs(.+)ref_count(.+)").WithArgs(1, 1).WillReturnResult(sqlmock.NewResult(0, 1))
 		mock.ExpectExec("INSERT(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
 		mock.ExpectCommit()
 		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)blobs(.+)ref_count(.+)").WithArgs(1, 2).WillReturnResult(sqlmock.NewResult(0, 1))
 		mock.ExpectExec("INSERT(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
 		mock.ExpectCommit()
 		storage, err := folder.MoveOrCopyFileTo(
@@ -276,14 +278,16 @@ func TestFolder_MoveOrCopyFileTo(t *testing.T) {
 				1,
 				1,
 			).WillReturnRows(
-			sqlmock.NewRows([]string{"id", "size"}).
-				AddRow(1, 10).
-				AddRow(2, 20),
+			sqlmock.NewRows([]string{"id", "size", "blob_id"}).
+				AddRow(1, 10, 1).
+				AddRow(2, 20, 2),
 		)
 		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)blobs(.+)ref_count(.+)").WillReturnResult(sqlmock.NewResult(0, 1))
 		mock.ExpectExec("INSERT(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
 		mock.ExpectCommit()
 		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)blobs(.+)ref_count(.+)").WillReturnResult(sqlmock.NewResult(0, 1))
 		mock.ExpectExec("INSERT(.+)").WillReturnError(errors.New("error"))
 		mock.ExpectRollback()
 		storage, err := folder.MoveOrCopyFileTo(
@@ -372,17 +376,19 @@ func TestFolder_CopyFolderTo(t *testing.T) {
 		mock.ExpectQuery("SELECT(.+)").
 			WithArgs(1, 2, 3, 4).
 			WillReturnRows(
-				sqlmock.NewRows([]string{"id", "name", "folder_id", "size", "upload_session_id"}).
-					AddRow(1, "2.txt", 2, 10, nil).
-					AddRow(2, "3.txt", 3, 20, nil).
-					AddRow(3, "5.txt", 3, 20, &dstFolder.Name),
+				sqlmock.NewRows([]string{"id", "name", "folder_id", "size", "blob_id", "upload_session_id"}).
+					AddRow(1, "2.txt", 2, 10, 1, nil).
+					AddRow(2, "3.txt", 3, 20, 2, nil).
+					AddRow(3, "5.txt", 3, 20, 3, &dstFolder.Name),
 			)
 
 		// 复制子文件
 		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)blobs(.+)ref_count(.+)").WillReturnResult(sqlmock.NewResult(0, 1))
 		mock.ExpectExec("INSERT(.+)").WillReturnResult(sqlmock.NewResult(5, 1))
 		mock.ExpectCommit()
 		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)blobs(.+)ref_count(.+)").WillReturnResult(sqlmock.NewResult(0, 1))
 		mock.ExpectExec("INSERT(.+)").WillReturnResult(sqlmock.NewResult(6, 1))
 		mock.ExpectCommit()
 
@@ -475,16 +481,18 @@ func TestFolder_CopyFolderTo(t *testing.T) {
 		mock.ExpectQuery("SELECT(.+)").
 			WithArgs(1, 2, 3, 4).
 			WillReturnRows(
-				sqlmock.NewRows([]string{"id", "name", "folder_id", "size"}).
-					AddRow(1, "2.txt", 2, 10).
-					AddRow(2, "3.txt", 3, 20),
+				sqlmock.NewRows([]string{"id", "name", "folder_id", "size", "blob_id"}).
+					AddRow(1, "2.txt", 2, 10, 1).
+					AddRow(2, "3.txt", 3, 20, 2),
 			)
 
 		// 复制子文件
 		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)blobs(.+)ref_count(.+)").WillReturnResult(sqlmock.NewResult(0, 1))
 		mock.ExpectExec("INSERT(.+)").WillReturnResult(sqlmock.NewResult(5, 1))
 		mock.ExpectCommit()
 		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)blobs(.+)ref_count(.+)").WillReturnResult(sqlmock.NewResult(0, 1))
 		mock.ExpectExec("INSERT(.+)").WillReturnError(errors.New("error"))
 		mock.ExpectRollback()
 
@@ -620,3 +628,29 @@ func TestFolder_Rename(t *testing.T) {
 		asserts.Error(err)
 	}
 }
+
+func TestFolder_AfterFind(t *testing.T) {
+	asserts := assert.New(t)
+	folder := Folder{Metadata: "{\"name\":\"123\"}"}
+	asserts.NoError(folder.AfterFind())
+	asserts.Equal("123", folder.MetadataSerialized["name"])
+
+	folder = Folder{}
+	asserts.NoError(folder.AfterFind())
+	asserts.NotNil(folder.MetadataSerialized)
+}
+
+func TestFolder_PatchMetadata(t *testing.T) {
+	asserts := assert.New(t)
+	folder := &Folder{MetadataSerialized: map[string]string{"old": "1", "keep": "1"}}
+	folder.ID = 1
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE(.+)folders(.+)metadata(.+)").
+		WithArgs(`{"keep":"1","new":"1"}`, 1).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	asserts.NoError(folder.PatchMetadata(map[string]string{"new": "1"}, []string{"old"}))
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.Equal(map[string]string{"keep": "1", "new": "1"}, folder.MetadataSerialized)
+}
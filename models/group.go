@@ -36,6 +36,10 @@ type GroupOption struct {
 	Aria2BatchSize   int                    `json:"aria2_batch,omitempty"`
 	AdvanceDelete    bool                   `json:"advance_delete,omitempty"`
 	WebDAVProxy      bool                   `json:"webdav_proxy,omitempty"`
+	TrashRetention   int                    `json:"trash_retention,omitempty"`   // 回收站保留天数，0 为不启用回收站
+	MaxVersions      int                    `json:"max_versions,omitempty"`      // 每个文件保留的历史版本数，0 为不保留
+	VersionRetention int                    `json:"version_retention,omitempty"` // 历史版本保留天数，0 为不限制
+	VersionQuota     bool                   `json:"version_quota,omitempty"`     // 历史版本是否计入用户容量
 }
 
 // GetGroupByID 用ID获取用户组
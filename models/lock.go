@@ -0,0 +1,168 @@
+package model
+
+import (
+	"errors"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/cloudreve/Cloudreve/v3/pkg/conf"
+	"github.com/jinzhu/gorm"
+)
+
+// Lock 文件或目录上的锁，由 WebDAV 与 WOPI 共用，并在多个主机间共享
+type Lock struct {
+	gorm.Model
+	Token     string     `gorm:"size:255;index:lock_token"`
+	UserID    uint       `gorm:"index:lock_user_id"`
+	Type      int        // 锁的来源
+	Path      string     `gorm:"type:text"` // 被锁定对象在用户文件系统中的完整路径
+	ZeroDepth bool       // 是否只锁定对象本身，否则同时锁定其所有子对象
+	Owner     string     `gorm:"type:text"` // WebDAV 中为 owner XML，WOPI 中为客户端给定的锁 ID
+	Timeout   int64      // 有效时长，单位为秒，最长为 MaxLockTimeout
+	ExpiresAt *time.Time `gorm:"index:lock_expires_at"`
+}
+
+const (
+	// LockTypeWebDAV WebDAV 客户端创建的锁
+	LockTypeWebDAV = iota
+	// LockTypeWopi WOPI 客户端创建的锁
+	LockTypeWopi
+)
+
+// MaxLockTimeout 锁的最长有效时长（秒）。请求无限期或更长的锁时按此时长处理，
+// 避免客户端异常退出后遗留的锁永久锁定文件
+const MaxLockTimeout = 3600
+
+// ErrLockConflict 对象已被其他锁锁定
+var ErrLockConflict = errors.New("object is locked")
+
+// Create 创建锁，与用户已有的有效锁冲突时返回 ErrLockConflict
+func (lock *Lock) Create(now time.Time) error {
+	tx := DB.Begin()
+
+	if err := lockUserRow(tx, lock.UserID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Unscoped().Where("user_id = ? and expires_at <= ?", lock.UserID, now).
+		Delete(&Lock{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	var locks []Lock
+	if err := tx.Where("user_id = ?", lock.UserID).Find(&locks).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	for _, existed := range locks {
+		if lock.conflictsWith(&existed) {
+			tx.Rollback()
+			return ErrLockConflict
+		}
+	}
+
+	lock.setExpiry(now)
+	if err := tx.Create(lock).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+// Refresh 以给定的有效时长刷新锁
+func (lock *Lock) Refresh(timeout int64, now time.Time) error {
+	lock.Timeout = timeout
+	lock.setExpiry(now)
+	return DB.Model(lock).UpdateColumns(map[string]interface{}{
+		"timeout":    lock.Timeout,
+		"expires_at": lock.ExpiresAt,
+	}).Error
+}
+
+// UpdateOwner 更改锁的持有者
+func (lock *Lock) UpdateOwner(owner string) error {
+	lock.Owner = owner
+	return DB.Model(lock).UpdateColumn("owner", owner).Error
+}
+
+// Delete 删除锁
+func (lock *Lock) Delete() error {
+	return DB.Unscoped().Delete(lock).Error
+}
+
+// Covers 返回锁是否作用于给定路径的对象
+func (lock *Lock) Covers(name string) bool {
+	return lock.Path == name || (!lock.ZeroDepth && isSubPath(name, lock.Path))
+}
+
+func (lock *Lock) setExpiry(now time.Time) {
+	if lock.Timeout < 0 || lock.Timeout > MaxLockTimeout {
+		lock.Timeout = MaxLockTimeout
+	}
+
+	expires := now.Add(time.Duration(lock.Timeout) * time.Second)
+	lock.ExpiresAt = &expires
+}
+
+// conflictsWith 返回两个锁是否不能同时存在
+func (lock *Lock) conflictsWith(other *Lock) bool {
+	return other.Covers(lock.Path) || lock.Covers(other.Path)
+}
+
+// GetLockByToken 根据 Token 查找用户未过期的锁
+func GetLockByToken(uid uint, token string, now time.Time) (*Lock, error) {
+	lock := &Lock{}
+	err := DB.Where("user_id = ? and token = ?", uid, token).
+		Where("expires_at is null or expires_at > ?", now).
+		First(lock).Error
+	return lock, err
+}
+
+// GetLocksByPath 列出作用于给定路径对象的所有未过期的锁，包括其父目录上的无限深度锁
+func GetLocksByPath(uid uint, name string, now time.Time) ([]Lock, error) {
+	var (
+		locks     []Lock
+		ancestors []string
+	)
+	for parent := name; parent != "/"; {
+		parent = path.Dir(parent)
+		ancestors = append(ancestors, parent)
+	}
+
+	res := DB.Where("user_id = ?", uid).
+		Where("path = ? or (path in (?) and zero_depth = ?)", name, ancestors, false).
+		Where("expires_at is null or expires_at > ?", now).
+		Order("id asc").
+		Find(&locks)
+	return locks, res.Error
+}
+
+// DeleteExpiredLocks 删除所有已过期的锁
+func DeleteExpiredLocks() error {
+	return DB.Unscoped().Where("expires_at <= ?", time.Now()).Delete(&Lock{}).Error
+}
+
+// lockUserRow 在事务中锁定用户记录，使同一用户的锁操作在多个主机间串行执行。
+// SQLite 中写事务本身即为串行，无需额外处理
+func lockUserRow(tx *gorm.DB, uid uint) error {
+	switch conf.DatabaseConfig.Type {
+	case "mysql", "postgres":
+		return tx.Set("gorm:query_option", "FOR UPDATE").Select("id").
+			Where("id = ?", uid).First(&User{}).Error
+	default:
+		return nil
+	}
+}
+
+// isSubPath 返回 name 是否为 parent 目录下的子路径
+func isSubPath(name, parent string) bool {
+	if parent == "/" {
+		return name != "/"
+	}
+	return strings.HasPrefix(name, parent+"/")
+}
@@ -0,0 +1,85 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cloudreve/Cloudreve/v3/pkg/conf"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLock_Covers(t *testing.T) {
+	asserts := assert.New(t)
+	root := &Lock{Path: "/"}
+	asserts.True(root.Covers("/"))
+	asserts.True(root.Covers("/a/b"))
+
+	infinite := &Lock{Path: "/a"}
+	asserts.True(infinite.Covers("/a"))
+	asserts.True(infinite.Covers("/a/b"))
+	asserts.False(infinite.Covers("/ab"))
+	asserts.False(infinite.Covers("/"))
+
+	zeroDepth := &Lock{Path: "/a", ZeroDepth: true}
+	asserts.True(zeroDepth.Covers("/a"))
+	asserts.False(zeroDepth.Covers("/a/b"))
+}
+
+func TestLockLifecycleSQLite(t *testing.T) {
+	asserts := assert.New(t)
+	dbType := conf.DatabaseConfig.Type
+	conf.DatabaseConfig.Type = "sqlite"
+	DB, _ = gorm.Open("sqlite", ":memory:")
+	defer func() {
+		DB = mockDB
+		conf.DatabaseConfig.Type = dbType
+	}()
+	DB.AutoMigrate(&User{}, &Lock{})
+	now := time.Now()
+
+	// 创建无限深度的锁
+	dir := &Lock{Token: "dir", UserID: 1, Path: "/a", Timeout: 60}
+	asserts.NoError(dir.Create(now))
+	asserts.NotNil(dir.ExpiresAt)
+
+	// 子对象、父目录与其他用户
+	asserts.Equal(ErrLockConflict, (&Lock{Token: "child", UserID: 1, Path: "/a/b", ZeroDepth: true}).Create(now))
+	asserts.Equal(ErrLockConflict, (&Lock{Token: "parent", UserID: 1, Path: "/"}).Create(now))
+	asserts.NoError((&Lock{Token: "parent", UserID: 1, Path: "/", ZeroDepth: true, Timeout: -1}).Create(now))
+	asserts.NoError((&Lock{Token: "other", UserID: 2, Path: "/a/b", ZeroDepth: true, Timeout: 60}).Create(now))
+
+	// 按路径查找
+	locks, err := GetLocksByPath(1, "/a/b/c", now)
+	asserts.NoError(err)
+	asserts.Len(locks, 1)
+	asserts.Equal("dir", locks[0].Token)
+	locks, err = GetLocksByPath(1, "/", now)
+	asserts.NoError(err)
+	asserts.Len(locks, 1)
+	asserts.Equal("parent", locks[0].Token)
+
+	// 无限期的锁按最长有效时长处理
+	asserts.EqualValues(MaxLockTimeout, locks[0].Timeout)
+	asserts.NotNil(locks[0].ExpiresAt)
+	_, err = GetLockByToken(1, "parent", now.Add(MaxLockTimeout*time.Second))
+	asserts.True(gorm.IsRecordNotFoundError(err))
+
+	// 过期后不再生效
+	_, err = GetLockByToken(1, "dir", now.Add(2*time.Minute))
+	asserts.True(gorm.IsRecordNotFoundError(err))
+	asserts.NoError((&Lock{Token: "child", UserID: 1, Path: "/a/b", ZeroDepth: true, Timeout: 60}).Create(now.Add(2 * time.Minute)))
+
+	// 刷新并删除
+	lock, err := GetLockByToken(1, "child", now)
+	asserts.NoError(err)
+	asserts.NoError(lock.Refresh(600, now))
+	lock, err = GetLockByToken(1, "child", now.Add(5*time.Minute))
+	asserts.NoError(err)
+	asserts.EqualValues(600, lock.Timeout)
+	asserts.NoError(lock.Refresh(2*MaxLockTimeout, now))
+	asserts.EqualValues(MaxLockTimeout, lock.Timeout)
+	asserts.NoError(lock.Delete())
+	_, err = GetLockByToken(1, "child", now)
+	asserts.True(gorm.IsRecordNotFoundError(err))
+}
@@ -41,7 +41,8 @@ func migration() {
 	}
 
 	DB.AutoMigrate(&User{}, &Setting{}, &Group{}, &Policy{}, &Folder{}, &File{}, &Share{},
-		&Task{}, &Download{}, &Tag{}, &Webdav{}, &Node{}, &SourceLink{})
+		&Task{}, &Download{}, &Tag{}, &Webdav{}, &Node{}, &SourceLink{}, &Trash{}, &FileVersion{}, &Blob{}, &Lock{}, &AccessToken{}, &UserIdentity{}, &AuditLog{},
+		&Webhook{}, &WebhookDelivery{}, &ShareGrant{}, &ShareAccess{}, &ContentDocument{}, &ContentTerm{})
 
 	// 创建初始存储策略
 	addDefaultPolicy()
@@ -61,6 +62,11 @@ func migration() {
 	// 执行数据库升级脚本
 	execUpgradeScripts()
 
+	// 将旧版本以明文存储的分享密码转换为摘要
+	if err := invoker.RunDBScript("HashSharePasswords", context.Background()); err != nil {
+		util.Log().Warning("Failed to hash share passwords: %s", err)
+	}
+
 	util.Log().Info("Finish initializing database schema.")
 
 }
@@ -112,6 +118,9 @@ func addDefaultGroups() {
 				Aria2BatchSize:   50,
 				RedirectedSource: true,
 				AdvanceDelete:    true,
+				TrashRetention:   30,
+				MaxVersions:      10,
+				VersionRetention: 30,
 			},
 		}
 		if err := DB.Create(&defaultAdminGroup).Error; err != nil {
@@ -134,6 +143,9 @@ func addDefaultGroups() {
 				SourceBatchSize:  10,
 				Aria2BatchSize:   1,
 				RedirectedSource: true,
+				TrashRetention:   30,
+				MaxVersions:      10,
+				VersionRetention: 30,
 			},
 		}
 		if err := DB.Create(&defaultAdminGroup).Error; err != nil {
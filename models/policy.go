@@ -200,7 +200,7 @@ func (policy *Policy) IsUploadPlaceholderWithSize() bool {
 		return true
 	}
 
-	if util.ContainsString([]string{"onedrive", "oss", "qiniu", "cos", "s3"}, policy.Type) {
+	if util.ContainsString([]string{"onedrive", "oss", "qiniu", "cos", "s3", "googledrive"}, policy.Type) {
 		return policy.OptionsSerialized.PlaceholderWithSize
 	}
 
@@ -6,4 +6,6 @@ func Init() {
 	invoker.Register("ResetAdminPassword", ResetAdminPassword(0))
 	invoker.Register("CalibrateUserStorage", UserStorageCalibration(0))
 	invoker.Register("UpgradeTo3.4.0", UpgradeTo340(0))
+	invoker.Register("HashWebDAVPasswords", HashWebDAVPasswords(0))
+	invoker.Register("HashSharePasswords", HashSharePasswords(0))
 }
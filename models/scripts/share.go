@@ -0,0 +1,35 @@
+package scripts
+
+import (
+	"context"
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+)
+
+type HashSharePasswords int
+
+// Run 将所有仍以明文存储的分享密码转换为 bcrypt 摘要
+func (script HashSharePasswords) Run(ctx context.Context) {
+	var shares []model.Share
+	model.DB.Where("password <> ?", "").Find(&shares)
+
+	count := 0
+	for _, share := range shares {
+		if share.IsPasswordHashed() {
+			continue
+		}
+
+		if err := share.SetPassword(share.Password); err != nil {
+			util.Log().Error("Failed to hash password of share %d: %s", share.ID, err)
+			continue
+		}
+
+		if err := model.DB.Model(&share).UpdateColumn("password", share.Password).Error; err != nil {
+			util.Log().Error("Failed to hash password of share %d: %s", share.ID, err)
+			continue
+		}
+		count++
+	}
+
+	util.Log().Info("Hashed passwords of %d shares.", count)
+}
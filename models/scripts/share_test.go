@@ -0,0 +1,26 @@
+package scripts
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestHashSharePasswords_Run(t *testing.T) {
+	asserts := assert.New(t)
+	script := HashSharePasswords(0)
+
+	mock.ExpectQuery("SELECT(.+)shares(.+)").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "password"}).
+			AddRow(1, "plaintext").
+			AddRow(2, "abcdefghijklmnop:2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b").
+			AddRow(3, "$2a$10$N9qo8uLOickgx2ZMRZoMyeIjZAgcfl7p92ldGxad68LJZdL17lhWy"))
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE(.+)shares(.+)").
+		WithArgs(sqlmock.AnyArg(), 1).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	script.Run(context.Background())
+	asserts.NoError(mock.ExpectationsWereMet())
+}
@@ -0,0 +1,31 @@
+package scripts
+
+import (
+	"context"
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+)
+
+type HashWebDAVPasswords int
+
+// Run 将所有仍以明文存储的 WebDAV 应用密码转换为加盐摘要
+func (script HashWebDAVPasswords) Run(ctx context.Context) {
+	var accounts []model.Webdav
+	model.DB.Find(&accounts)
+
+	count := 0
+	for _, account := range accounts {
+		if account.IsPasswordHashed() {
+			continue
+		}
+
+		account.SetPassword(account.Password)
+		if err := model.DB.Model(&account).UpdateColumn("password", account.Password).Error; err != nil {
+			util.Log().Error("Failed to hash password of WebDAV account %d: %s", account.ID, err)
+			continue
+		}
+		count++
+	}
+
+	util.Log().Info("Hashed passwords of %d WebDAV accounts.", count)
+}
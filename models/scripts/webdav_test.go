@@ -0,0 +1,25 @@
+package scripts
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestHashWebDAVPasswords_Run(t *testing.T) {
+	asserts := assert.New(t)
+	script := HashWebDAVPasswords(0)
+
+	mock.ExpectQuery("SELECT(.+)webdavs(.+)").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "password"}).
+			AddRow(1, "plaintext").
+			AddRow(2, "salt:hash"))
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE(.+)webdavs(.+)").
+		WithArgs(sqlmock.AnyArg(), 1).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	script.Run(context.Background())
+	asserts.NoError(mock.ExpectationsWereMet())
+}
@@ -3,6 +3,7 @@ package model
 import (
 	"errors"
 	"fmt"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -26,6 +27,17 @@ type Share struct {
 	Expires         *time.Time // 过期时间，空值表示无过期时间
 	PreviewEnabled  bool       // 是否允许直接预览
 	SourceName      string     `gorm:"index:source"` // 用于搜索的字段
+	Internal        bool       // 是否为内部分享，内部分享仅授权的用户及用户组可访问
+
+	// 收集文件相关，仅目录分享可开启
+	UploadEnabled      bool   // 是否允许访客向分享目录上传文件
+	UploadMaxSize      uint64 // 访客上传文件的总大小上限，0 表示不限制
+	UploadMaxFiles     int    // 访客上传文件的数量上限，0 表示不限制
+	UploadExts         string // 允许上传的扩展名，以逗号分隔，空值表示不限制
+	UploadHideContents bool   // 是否对访客隐藏目录内容
+	UploadNotify       bool   // 访客上传完成后是否邮件通知创建者
+	UploadedFiles      int    // 访客已上传文件数
+	UploadedSize       uint64 // 访客已上传文件大小
 
 	// 数据库忽略字段
 	User   User   `gorm:"PRELOAD:false,association_autoupdate:false"`
@@ -57,6 +69,13 @@ func GetShareByHashID(hashID string) *Share {
 	return &share
 }
 
+// GetShareByID 根据ID查找分享
+func GetShareByID(id uint) (Share, error) {
+	var share Share
+	result := DB.First(&share, id)
+	return share, result.Error
+}
+
 // IsAvailable 返回此分享是否可用（是否过期）
 func (share *Share) IsAvailable() bool {
 	if share.RemainDownloads == 0 {
@@ -181,13 +200,67 @@ func (share *Share) Downloaded() {
 	})
 }
 
+// CanUploadFile 返回访客能否向此分享上传给定文件名的文件
+func (share *Share) CanUploadFile(name string) bool {
+	if !share.IsDir || !share.UploadEnabled {
+		return false
+	}
+
+	if share.UploadExts == "" {
+		return true
+	}
+
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(name), "."))
+	for _, allowed := range strings.Split(share.UploadExts, ",") {
+		if strings.ToLower(strings.TrimSpace(allowed)) == ext && ext != "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ContentsVisibleTo 返回目录内容是否对给定用户可见
+func (share *Share) ContentsVisibleTo(user *User) bool {
+	return !(share.UploadEnabled && share.UploadHideContents) || user.ID == share.UserID
+}
+
+// HasUploadQuota 分享剩余的上传配额是否足够再上传一个给定大小的文件。
+// 配额在上传完成时才计入，同时进行中的上传可能使已上传量略微超出上限
+func (share *Share) HasUploadQuota(size uint64) bool {
+	if share.UploadMaxFiles > 0 && share.UploadedFiles >= share.UploadMaxFiles {
+		return false
+	}
+
+	return share.UploadMaxSize == 0 || share.UploadedSize+size <= share.UploadMaxSize
+}
+
+// CountUpload 将完成的上传计入分享的已上传文件数及大小
+func (share *Share) CountUpload(size uint64) error {
+	err := DB.Model(&Share{}).Where("id = ?", share.ID).
+		Updates(map[string]interface{}{
+			"uploaded_files": gorm.Expr("uploaded_files + ?", 1),
+			"uploaded_size":  gorm.Expr("uploaded_size + ?", size),
+		}).Error
+	if err != nil {
+		return err
+	}
+
+	share.UploadedFiles++
+	share.UploadedSize += size
+	return nil
+}
+
 // Update 更新分享属性
 func (share *Share) Update(props map[string]interface{}) error {
 	return DB.Model(share).Updates(props).Error
 }
 
-// Delete 删除分享
+// Delete 删除分享及其授权
 func (share *Share) Delete() error {
+	if err := DB.Where("share_id = ?", share.ID).Delete(&ShareGrant{}).Error; err != nil {
+		return err
+	}
 	return DB.Model(share).Delete(share).Error
 }
 
@@ -205,7 +278,7 @@ func ListShares(uid uint, page, pageSize int, order string, publicOnly bool) ([]
 	dbChain := DB
 	dbChain = dbChain.Where("user_id = ?", uid)
 	if publicOnly {
-		dbChain = dbChain.Where("password = ?", "")
+		dbChain = dbChain.Where("password = ? and internal = ?", "", false)
 	}
 
 	// 计算总数用于分页
@@ -235,7 +308,7 @@ func SearchShares(page, pageSize int, order, keywords string) ([]Share, int) {
 	}
 
 	dbChain := DB
-	dbChain = dbChain.Where("password = ? and remain_downloads <> 0 and (expires is NULL or expires > ?) and source_name like ?", "", time.Now(), "%"+strings.Join(availableList, "%")+"%")
+	dbChain = dbChain.Where("password = ? and internal = ? and remain_downloads <> 0 and (expires is NULL or expires > ?) and source_name like ?", "", false, time.Now(), "%"+strings.Join(availableList, "%")+"%")
 
 	// 计算总数用于分页
 	dbChain.Model(&Share{}).Count(&total)
@@ -0,0 +1,135 @@
+package model
+
+import (
+	"time"
+
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+	"github.com/gin-gonic/gin"
+)
+
+// ShareAccess 分享访问记录
+type ShareAccess struct {
+	ID        uint      `gorm:"primary_key"`
+	CreatedAt time.Time `gorm:"index:share_access_created_at"`
+	ShareID   uint      `gorm:"index:share_access_share"`
+	UserID    uint      // 访问者ID，未登录时为0
+	IP        string
+	Country   string // 访问者所在国家或地区代码，由配置的反向代理请求头提供
+	UserAgent string `gorm:"type:text"`
+	Action    string // 访问类型
+	FileID    uint   // 访问的文件ID，访问整个分享时为0
+	Path      string `gorm:"type:text"` // 访问的文件或目录在分享中的路径
+}
+
+// 分享访问类型
+const (
+	ShareAccessView     = "view"
+	ShareAccessDownload = "download"
+	ShareAccessPreview  = "preview"
+	ShareAccessArchive  = "archive"
+	ShareAccessSave     = "save"
+	ShareAccessDenied   = "denied"
+)
+
+// ShareAccessDaily 分享访问的每日统计
+type ShareAccessDaily struct {
+	Date     string         `json:"date"`
+	Actions  map[string]int `json:"actions"`  // 各类型的访问次数
+	Visitors int            `json:"visitors"` // 按 IP 去重的访客数
+}
+
+// RecordAccess 记录访问者对分享的访问，file 为空时表示访问整个分享
+func (share *Share) RecordAccess(c *gin.Context, user *User, action string, file *File, path string) {
+	if !IsTrueVal(GetSettingByNameWithDefault("share_access_log_enabled", "1")) {
+		return
+	}
+
+	access := &ShareAccess{
+		ShareID:   share.ID,
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		Action:    action,
+		Path:      path,
+	}
+	if header := GetSettingByNameWithDefault("share_access_country_header", ""); header != "" {
+		access.Country = c.GetHeader(header)
+	}
+	if user != nil && !user.IsAnonymous() {
+		access.UserID = user.ID
+	}
+	if file != nil {
+		access.FileID = file.ID
+	}
+
+	if err := DB.Create(access).Error; err != nil {
+		util.Log().Warning("Failed to insert share access record: %s", err)
+	}
+}
+
+// ListShareAccesses 列出分享的访问记录，新的在前
+func ListShareAccesses(shareID uint, action string, page, pageSize int) ([]ShareAccess, int, error) {
+	var (
+		accesses []ShareAccess
+		total    int
+	)
+
+	dbChain := DB.Model(&ShareAccess{}).Where("share_id = ?", shareID)
+	if action != "" {
+		dbChain = dbChain.Where("action = ?", action)
+	}
+
+	// 计算总数用于分页
+	dbChain.Count(&total)
+
+	err := dbChain.Order("id desc").Limit(pageSize).Offset((page - 1) * pageSize).Find(&accesses).Error
+	return accesses, total, err
+}
+
+// GetShareAccessDaily 按天统计给定时间之后的分享访问，shareID 为0时统计全部分享。
+// 日期按服务器所在时区划分
+func GetShareAccessDaily(shareID uint, since time.Time) ([]ShareAccessDaily, error) {
+	dbChain := DB.Model(&ShareAccess{}).Select("created_at, action, ip").Where("created_at >= ?", since)
+	if shareID > 0 {
+		dbChain = dbChain.Where("share_id = ?", shareID)
+	}
+
+	rows, err := dbChain.Order("created_at").Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := make([]ShareAccessDaily, 0)
+	visitors := make(map[string]bool)
+	for rows.Next() {
+		var (
+			createdAt time.Time
+			action    string
+			ip        string
+		)
+		if err := rows.Scan(&createdAt, &action, &ip); err != nil {
+			return nil, err
+		}
+
+		date := createdAt.Local().Format("2006-01-02")
+		if len(res) == 0 || res[len(res)-1].Date != date {
+			res = append(res, ShareAccessDaily{Date: date, Actions: make(map[string]int)})
+			visitors = make(map[string]bool)
+		}
+
+		day := &res[len(res)-1]
+		day.Actions[action]++
+		if !visitors[ip] {
+			visitors[ip] = true
+			day.Visitors++
+		}
+	}
+
+	return res, rows.Err()
+}
+
+// DeleteShareAccessesBefore 删除给定时间之前的分享访问记录，返回删除的条目数
+func DeleteShareAccessesBefore(t time.Time) (int64, error) {
+	result := DB.Where("created_at < ?", t).Delete(&ShareAccess{})
+	return result.RowsAffected, result.Error
+}
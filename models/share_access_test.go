@@ -0,0 +1,84 @@
+package model
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShareAccessSQLite(t *testing.T) {
+	asserts := assert.New(t)
+	DB, _ = gorm.Open("sqlite", ":memory:")
+	defer func() {
+		DB = mockDB
+	}()
+	DB.AutoMigrate(&ShareAccess{})
+	cache.Set("setting_share_access_log_enabled", "1", 0)
+	cache.Set("setting_share_access_country_header", "CF-IPCountry", 0)
+
+	share := &Share{Model: gorm.Model{ID: 1}}
+	other := &Share{Model: gorm.Model{ID: 2}}
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "/", nil)
+	c.Request.Header.Set("CF-IPCountry", "JP")
+	c.Request.RemoteAddr = "1.2.3.4:80"
+
+	// 记录访问
+	share.RecordAccess(c, nil, ShareAccessView, nil, "")
+	share.RecordAccess(c, &User{Model: gorm.Model{ID: 3}}, ShareAccessDownload, &File{Model: gorm.Model{ID: 4}}, "/a.txt")
+	other.RecordAccess(c, &User{}, ShareAccessView, nil, "")
+
+	accesses, total, err := ListShareAccesses(1, "", 1, 10)
+	asserts.NoError(err)
+	asserts.Equal(2, total)
+	asserts.Equal(ShareAccessDownload, accesses[0].Action)
+	asserts.EqualValues(3, accesses[0].UserID)
+	asserts.EqualValues(4, accesses[0].FileID)
+	asserts.Equal("JP", accesses[0].Country)
+	asserts.Equal("1.2.3.4", accesses[0].IP)
+	asserts.EqualValues(0, accesses[1].UserID)
+
+	accesses, total, err = ListShareAccesses(1, ShareAccessView, 1, 10)
+	asserts.NoError(err)
+	asserts.Equal(1, total)
+	asserts.Len(accesses, 1)
+
+	// 关闭记录
+	cache.Set("setting_share_access_log_enabled", "0", 0)
+	share.RecordAccess(c, nil, ShareAccessView, nil, "")
+	_, total, _ = ListShareAccesses(1, "", 1, 10)
+	asserts.Equal(2, total)
+	cache.Set("setting_share_access_log_enabled", "1", 0)
+
+	// 每日统计
+	yesterday := time.Now().AddDate(0, 0, -1)
+	asserts.NoError(DB.Create(&ShareAccess{ShareID: 1, CreatedAt: yesterday, Action: ShareAccessView, IP: "5.6.7.8"}).Error)
+	daily, err := GetShareAccessDaily(1, yesterday.Add(-time.Hour))
+	asserts.NoError(err)
+	asserts.Len(daily, 2)
+	asserts.Equal(1, daily[0].Actions[ShareAccessView])
+	asserts.Equal(1, daily[1].Visitors)
+	asserts.Equal(1, daily[1].Actions[ShareAccessDownload])
+
+	daily, err = GetShareAccessDaily(0, time.Now().Add(-time.Hour))
+	asserts.NoError(err)
+	asserts.Len(daily, 1)
+	asserts.Equal(2, daily[0].Actions[ShareAccessView])
+
+	// 清理过期记录
+	deleted, err := DeleteShareAccessesBefore(time.Now().Add(-time.Hour))
+	asserts.NoError(err)
+	asserts.EqualValues(1, deleted)
+
+	// 未配置国家代码请求头时不信任客户端提供的值
+	cache.Set("setting_share_access_country_header", "", 0)
+	other.RecordAccess(c, nil, ShareAccessView, nil, "")
+	accesses, _, err = ListShareAccesses(2, "", 1, 10)
+	asserts.NoError(err)
+	asserts.Empty(accesses[0].Country)
+}
@@ -0,0 +1,108 @@
+package model
+
+import (
+	"github.com/jinzhu/gorm"
+)
+
+// ShareGrant 内部分享的授权，授权对象为指定用户或用户组
+type ShareGrant struct {
+	gorm.Model
+	ShareID    uint   `gorm:"index:share_grant_share"`
+	TargetType string `gorm:"index:share_grant_target"` // 授权对象类型，user 或 group
+	TargetID   uint   `gorm:"index:share_grant_target"` // 授权的用户或用户组ID
+	Permission int    // 授权的权限级别
+}
+
+const (
+	// ShareGrantUser 授权给用户
+	ShareGrantUser = "user"
+	// ShareGrantGroup 授权给用户组
+	ShareGrantGroup = "group"
+)
+
+const (
+	// SharePermissionNone 无权访问
+	SharePermissionNone = iota
+	// SharePermissionView 可浏览、预览
+	SharePermissionView
+	// SharePermissionDownload 可下载、转存
+	SharePermissionDownload
+	// SharePermissionEdit 可在分享目录中上传、重命名、删除
+	SharePermissionEdit
+)
+
+// Grants 列出分享的授权
+func (share *Share) Grants() ([]ShareGrant, error) {
+	var grants []ShareGrant
+	err := DB.Where("share_id = ?", share.ID).Order("id").Find(&grants).Error
+	return grants, err
+}
+
+// SetGrants 替换分享的全部授权
+func (share *Share) SetGrants(grants []ShareGrant) error {
+	tx := DB.Begin()
+	if err := tx.Unscoped().Where("share_id = ?", share.ID).Delete(&ShareGrant{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	for i := range grants {
+		grants[i].ID = 0
+		grants[i].ShareID = share.ID
+		if err := tx.Create(&grants[i]).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit().Error
+}
+
+// PermissionOf 返回给定用户对分享的权限级别。非内部分享的访客可浏览、下载；
+// 内部分享仅授权的用户及用户组成员可访问，取各授权中的最高权限
+func (share *Share) PermissionOf(user *User) int {
+	if user.ID != 0 && user.ID == share.UserID {
+		return SharePermissionEdit
+	}
+
+	if !share.Internal {
+		return SharePermissionDownload
+	}
+
+	if user.IsAnonymous() {
+		return SharePermissionNone
+	}
+
+	var grant ShareGrant
+	err := DB.Where("share_id = ?", share.ID).
+		Where("(target_type = ? and target_id = ?) or (target_type = ? and target_id = ?)",
+			ShareGrantUser, user.ID, ShareGrantGroup, user.GroupID).
+		Order("permission desc").
+		First(&grant).Error
+	if err != nil {
+		return SharePermissionNone
+	}
+
+	return grant.Permission
+}
+
+// ListReceivedShares 列出授权给用户或其所在用户组的内部分享
+func ListReceivedShares(user *User, page, pageSize int, order string) ([]Share, int) {
+	var (
+		shares []Share
+		total  int
+	)
+
+	granted := DB.Model(&ShareGrant{}).Select("share_id").
+		Where("(target_type = ? and target_id = ?) or (target_type = ? and target_id = ?)",
+			ShareGrantUser, user.ID, ShareGrantGroup, user.GroupID).
+		QueryExpr()
+	dbChain := DB.Where("internal = ? and user_id <> ? and id in (?)", true, user.ID, granted)
+
+	// 计算总数用于分页
+	dbChain.Model(&Share{}).Count(&total)
+
+	// 查询记录
+	dbChain.Limit(pageSize).Offset((page - 1) * pageSize).Order(order).Find(&shares)
+	return shares, total
+}
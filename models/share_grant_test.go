@@ -0,0 +1,75 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShareGrantSQLite(t *testing.T) {
+	asserts := assert.New(t)
+	DB, _ = gorm.Open("sqlite", ":memory:")
+	defer func() {
+		DB = mockDB
+	}()
+	DB.AutoMigrate(&Share{}, &ShareGrant{})
+
+	owner := &User{Model: gorm.Model{ID: 1}, GroupID: 2}
+	member := &User{Model: gorm.Model{ID: 2}, GroupID: 3}
+	granted := &User{Model: gorm.Model{ID: 3}, GroupID: 2}
+	stranger := &User{Model: gorm.Model{ID: 4}, GroupID: 4}
+
+	public := &Share{UserID: 1, IsDir: true}
+	internal := &Share{UserID: 1, IsDir: true, Internal: true}
+	other := &Share{UserID: 2, Internal: true}
+	for _, share := range []*Share{public, internal, other} {
+		_, err := share.Create()
+		asserts.NoError(err)
+	}
+
+	asserts.NoError(internal.SetGrants([]ShareGrant{
+		{TargetType: ShareGrantUser, TargetID: 3, Permission: SharePermissionEdit},
+		{TargetType: ShareGrantGroup, TargetID: 2, Permission: SharePermissionView},
+		{TargetType: ShareGrantGroup, TargetID: 3, Permission: SharePermissionDownload},
+	}))
+	asserts.NoError(other.SetGrants([]ShareGrant{
+		{TargetType: ShareGrantUser, TargetID: 3, Permission: SharePermissionView},
+	}))
+
+	// 非内部分享
+	asserts.Equal(SharePermissionDownload, public.PermissionOf(stranger))
+	asserts.Equal(SharePermissionEdit, public.PermissionOf(owner))
+
+	// 内部分享取最高权限
+	asserts.Equal(SharePermissionEdit, internal.PermissionOf(owner))
+	asserts.Equal(SharePermissionDownload, internal.PermissionOf(member))
+	asserts.Equal(SharePermissionEdit, internal.PermissionOf(granted))
+	asserts.Equal(SharePermissionNone, internal.PermissionOf(stranger))
+	asserts.Equal(SharePermissionNone, internal.PermissionOf(&User{}))
+
+	// 他人授权给我的分享
+	shares, total := ListReceivedShares(granted, 1, 10, "id asc")
+	asserts.Equal(2, total)
+	asserts.Len(shares, 2)
+	shares, total = ListReceivedShares(member, 1, 10, "id asc")
+	asserts.Equal(1, total)
+	asserts.Equal(internal.ID, shares[0].ID)
+	_, total = ListReceivedShares(owner, 1, 10, "id asc")
+	asserts.Equal(0, total)
+
+	// 替换授权
+	asserts.NoError(internal.SetGrants([]ShareGrant{
+		{TargetType: ShareGrantUser, TargetID: 4, Permission: SharePermissionView},
+	}))
+	grants, err := internal.Grants()
+	asserts.NoError(err)
+	asserts.Len(grants, 1)
+	asserts.Equal(SharePermissionNone, internal.PermissionOf(member))
+	asserts.Equal(SharePermissionView, internal.PermissionOf(stranger))
+
+	// 删除分享后不再列出
+	asserts.NoError(other.Delete())
+	_, total = ListReceivedShares(granted, 1, 10, "id asc")
+	asserts.Equal(0, total)
+}
@@ -274,6 +274,10 @@ func TestShare_UpdateAndDelete(t *testing.T) {
 	}
 
 	{
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)share_grants(.+)").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
 		mock.ExpectBegin()
 		mock.ExpectExec("UPDATE(.+)").
 			WillReturnResult(sqlmock.NewResult(1, 1))
@@ -312,10 +316,70 @@ func TestSearchShares(t *testing.T) {
 
 	mock.ExpectQuery("SELECT(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
 	mock.ExpectQuery("SELECT(.+)").
-		WithArgs("", sqlmock.AnyArg(), "%1%2%").
+		WithArgs("", false, sqlmock.AnyArg(), "%1%2%").
 		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
 	res, total := SearchShares(1, 10, "id", "1 2")
 	asserts.NoError(mock.ExpectationsWereMet())
 	asserts.Len(res, 1)
 	asserts.Equal(1, total)
 }
+
+func TestShare_CanUploadFile(t *testing.T) {
+	asserts := assert.New(t)
+
+	asserts.False((&Share{UploadEnabled: true}).CanUploadFile("a.txt"))
+	asserts.False((&Share{IsDir: true}).CanUploadFile("a.txt"))
+	asserts.True((&Share{IsDir: true, UploadEnabled: true}).CanUploadFile("a"))
+
+	share := &Share{IsDir: true, UploadEnabled: true, UploadExts: "pdf, docx"}
+	asserts.True(share.CanUploadFile("a.PDF"))
+	asserts.True(share.CanUploadFile("a.docx"))
+	asserts.False(share.CanUploadFile("a.doc"))
+	asserts.False(share.CanUploadFile("pdf"))
+}
+
+func TestShare_ContentsVisibleTo(t *testing.T) {
+	asserts := assert.New(t)
+	share := &Share{UserID: 1, UploadEnabled: true, UploadHideContents: true}
+
+	asserts.True(share.ContentsVisibleTo(&User{Model: gorm.Model{ID: 1}}))
+	asserts.False(share.ContentsVisibleTo(&User{Model: gorm.Model{ID: 2}}))
+	asserts.False(share.ContentsVisibleTo(NewAnonymousUser()))
+
+	share.UploadHideContents = false
+	asserts.True(share.ContentsVisibleTo(NewAnonymousUser()))
+}
+
+func TestShare_UploadQuotaSQLite(t *testing.T) {
+	asserts := assert.New(t)
+	DB, _ = gorm.Open("sqlite", ":memory:")
+	defer func() {
+		DB = mockDB
+	}()
+	DB.AutoMigrate(&Share{})
+
+	share := &Share{IsDir: true, UploadEnabled: true, UploadMaxFiles: 2, UploadMaxSize: 10}
+	_, err := share.Create()
+	asserts.NoError(err)
+
+	// 超出大小限制
+	asserts.False(share.HasUploadQuota(11))
+	asserts.True(share.HasUploadQuota(6))
+
+	asserts.NoError(share.CountUpload(6))
+	asserts.False(share.HasUploadQuota(5))
+	asserts.True(share.HasUploadQuota(4))
+	asserts.NoError(share.CountUpload(4))
+
+	// 超出数量限制
+	asserts.False(share.HasUploadQuota(0))
+
+	res, err := GetShareByID(share.ID)
+	asserts.NoError(err)
+	asserts.Equal(2, res.UploadedFiles)
+	asserts.EqualValues(10, res.UploadedSize)
+
+	// 不限制
+	unlimited := &Share{IsDir: true, UploadEnabled: true}
+	asserts.True(unlimited.HasUploadQuota(1 << 40))
+}
@@ -0,0 +1,143 @@
+package model
+
+import (
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// 分享密码错误次数的缓存键
+const (
+	shareUnlockIPKey    = "share_unlock_fail_ip_%s"
+	shareUnlockShareKey = "share_unlock_fail_share_%d"
+	shareUnlockAlertKey = "share_unlock_alert_%d"
+)
+
+// SetPassword 根据明文设定分享密码，密码以 bcrypt 摘要存储，空值表示取消密码
+func (share *Share) SetPassword(password string) error {
+	if password == "" {
+		share.Password = ""
+		return nil
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	share.Password = string(hash)
+	return nil
+}
+
+// isBcryptPassword 分享密码是否为 bcrypt 摘要
+func (share *Share) isBcryptPassword() bool {
+	_, err := bcrypt.Cost([]byte(share.Password))
+	return err == nil
+}
+
+// isSaltedPassword 分享密码是否为旧版本的加盐 SHA-256 摘要，格式为 $SALT:$HASH
+func (share *Share) isSaltedPassword() bool {
+	parts := strings.Split(share.Password, ":")
+	if len(parts) != 2 || len(parts[0]) != 16 || len(parts[1]) != 64 {
+		return false
+	}
+
+	_, err := hex.DecodeString(parts[1])
+	return err == nil
+}
+
+// IsPasswordHashed 分享密码是否已经以摘要形式存储，旧版本的分享密码为明文
+func (share *Share) IsPasswordHashed() bool {
+	return share.isBcryptPassword() || share.isSaltedPassword()
+}
+
+// CheckPassword 校验分享密码，仍以明文或旧版本摘要存储的密码校验通过后会被转换为 bcrypt 摘要
+func (share *Share) CheckPassword(password string) bool {
+	if share.Password == "" {
+		return false
+	}
+
+	if share.isBcryptPassword() {
+		return bcrypt.CompareHashAndPassword([]byte(share.Password), []byte(password)) == nil
+	}
+
+	expected, actual := share.Password, password
+	if share.isSaltedPassword() {
+		actual = hashSaltedPassword(password, strings.SplitN(share.Password, ":", 2)[0])
+	}
+
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(actual)) != 1 {
+		return false
+	}
+
+	if err := share.SetPassword(password); err != nil {
+		util.Log().Warning("Failed to hash password of share %d: %s", share.ID, err)
+		share.Password = expected
+		return true
+	}
+
+	if err := DB.Model(share).UpdateColumn("password", share.Password).Error; err != nil {
+		util.Log().Warning("Failed to hash password of share %d: %s", share.ID, err)
+	}
+
+	return true
+}
+
+// unlockFailures 取得窗口期内的密码错误次数
+func unlockFailures(key string) int {
+	if count, ok := cache.Get(key); ok {
+		if n, ok := count.(int); ok {
+			return n
+		}
+	}
+
+	return 0
+}
+
+// UnlockBlocked 客户端 IP 的密码错误次数是否已达上限，达到上限后在窗口期内拒绝解锁
+func (share *Share) UnlockBlocked(ip string) bool {
+	limit := GetIntSetting("share_unlock_ip_limit", 20)
+	return limit > 0 && unlockFailures(fmt.Sprintf(shareUnlockIPKey, ip)) >= limit
+}
+
+// UnlockCaptchaRequired 解锁分享前是否需要完成验证码。客户端 IP 多次输错密码，
+// 或分享本身被频繁尝试时，所有访客都需要完成验证码
+func (share *Share) UnlockCaptchaRequired(ip string) bool {
+	threshold := GetIntSetting("share_unlock_captcha_threshold", 3)
+	if threshold > 0 && unlockFailures(fmt.Sprintf(shareUnlockIPKey, ip)) >= threshold {
+		return true
+	}
+
+	limit := GetIntSetting("share_unlock_share_limit", 50)
+	return limit > 0 && unlockFailures(fmt.Sprintf(shareUnlockShareKey, share.ID)) >= limit
+}
+
+// RecordUnlockFailure 记录一次密码错误，返回分享在窗口期内被尝试的次数，
+// 以及是否首次达到分享的尝试上限（用于通知创建者）。计数器在首次错误后的窗口期结束时清零，
+// ip 须为经可信代理解析后的客户端 IP
+func (share *Share) RecordUnlockFailure(ip string) (int, bool) {
+	window := GetIntSetting("share_unlock_window", 900)
+	if _, err := cache.IncrBy(fmt.Sprintf(shareUnlockIPKey, ip), 1, window); err != nil {
+		util.Log().Warning("Failed to count unlock failures of IP %q: %s", ip, err)
+	}
+
+	attempts, err := cache.IncrBy(fmt.Sprintf(shareUnlockShareKey, share.ID), 1, window)
+	if err != nil {
+		util.Log().Warning("Failed to count unlock failures of share %d: %s", share.ID, err)
+		return 0, false
+	}
+
+	limit := GetIntSetting("share_unlock_share_limit", 50)
+	if limit <= 0 || attempts < limit {
+		return attempts, false
+	}
+
+	// 同一窗口期内仅通知一次
+	alerts, err := cache.IncrBy(fmt.Sprintf(shareUnlockAlertKey, share.ID), 1, window)
+	return attempts, err == nil && alerts == 1
+}
@@ -0,0 +1,103 @@
+package model
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShare_CheckPassword(t *testing.T) {
+	asserts := assert.New(t)
+	DB, _ = gorm.Open("sqlite", ":memory:")
+	defer func() {
+		DB = mockDB
+	}()
+	DB.AutoMigrate(&Share{})
+
+	// 未设定密码
+	share := &Share{}
+	asserts.NoError(share.SetPassword(""))
+	asserts.Empty(share.Password)
+	asserts.False(share.CheckPassword(""))
+
+	// 摘要
+	asserts.NoError(share.SetPassword("secret"))
+	asserts.True(share.IsPasswordHashed())
+	asserts.True(strings.HasPrefix(share.Password, "$2a$"))
+	asserts.NotContains(share.Password, "secret")
+	asserts.True(share.CheckPassword("secret"))
+	asserts.False(share.CheckPassword("wrong"))
+	asserts.False(share.CheckPassword(share.Password))
+
+	// 旧版本明文，包含分隔符的明文不应被视为摘要
+	legacy := &Share{Password: "a:b"}
+	_, err := legacy.Create()
+	asserts.NoError(err)
+	asserts.False(legacy.IsPasswordHashed())
+	asserts.False(legacy.CheckPassword("wrong"))
+	asserts.True(legacy.CheckPassword("a:b"))
+
+	// 校验通过后转换为摘要
+	stored, err := GetShareByID(legacy.ID)
+	asserts.NoError(err)
+	asserts.True(stored.IsPasswordHashed())
+	asserts.True(strings.HasPrefix(stored.Password, "$2a$"))
+	asserts.True(stored.CheckPassword("a:b"))
+
+	// 旧版本加盐摘要，校验通过后转换为 bcrypt 摘要
+	salted := &Share{Password: hashSaltedPassword("secret", "0123456789abcdef")}
+	_, err = salted.Create()
+	asserts.NoError(err)
+	asserts.True(salted.IsPasswordHashed())
+	asserts.False(salted.CheckPassword("wrong"))
+	asserts.False(salted.CheckPassword(salted.Password))
+	asserts.True(salted.CheckPassword("secret"))
+	stored, err = GetShareByID(salted.ID)
+	asserts.NoError(err)
+	asserts.True(strings.HasPrefix(stored.Password, "$2a$"))
+	asserts.True(stored.CheckPassword("secret"))
+}
+
+func TestShare_RecordUnlockFailure(t *testing.T) {
+	asserts := assert.New(t)
+	cache.SetSettings(map[string]string{
+		"share_unlock_window":            "900",
+		"share_unlock_captcha_threshold": "2",
+		"share_unlock_ip_limit":          "3",
+		"share_unlock_share_limit":       "4",
+	}, "setting_")
+
+	share := &Share{Model: gorm.Model{ID: 100}}
+	other := &Share{Model: gorm.Model{ID: 101}}
+	asserts.False(share.UnlockBlocked("10.0.0.1"))
+	asserts.False(share.UnlockCaptchaRequired("10.0.0.1"))
+
+	// 同一 IP 多次输错后需要验证码，达到上限后拒绝解锁
+	attempts, alert := share.RecordUnlockFailure("10.0.0.1")
+	asserts.Equal(1, attempts)
+	asserts.False(alert)
+	asserts.False(share.UnlockCaptchaRequired("10.0.0.1"))
+	share.RecordUnlockFailure("10.0.0.1")
+	asserts.True(share.UnlockCaptchaRequired("10.0.0.1"))
+	asserts.False(share.UnlockCaptchaRequired("10.0.0.2"))
+	asserts.False(share.UnlockBlocked("10.0.0.1"))
+	other.RecordUnlockFailure("10.0.0.1")
+	asserts.True(share.UnlockBlocked("10.0.0.1"))
+	asserts.True(other.UnlockBlocked("10.0.0.1"))
+	asserts.False(share.UnlockBlocked("10.0.0.2"))
+
+	// 分享被频繁尝试时所有访客都需要验证码，且仅通知一次
+	attempts, alert = share.RecordUnlockFailure("10.0.0.3")
+	asserts.Equal(3, attempts)
+	asserts.False(alert)
+	attempts, alert = share.RecordUnlockFailure("10.0.0.4")
+	asserts.Equal(4, attempts)
+	asserts.True(alert)
+	asserts.True(share.UnlockCaptchaRequired("10.0.0.5"))
+	asserts.False(other.UnlockCaptchaRequired("10.0.0.5"))
+	_, alert = share.RecordUnlockFailure("10.0.0.5")
+	asserts.False(alert)
+}
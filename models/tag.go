@@ -1,6 +1,9 @@
 package model
 
 import (
+	"strings"
+
+	"github.com/cloudreve/Cloudreve/v3/pkg/search/query"
 	"github.com/cloudreve/Cloudreve/v3/pkg/util"
 	"github.com/jinzhu/gorm"
 )
@@ -51,3 +54,36 @@ func GetTagsByID(id, uid uint) (*Tag, error) {
 	result := DB.Where("user_id = ? and id = ?", uid, id).First(&tag)
 	return &tag, result.Error
 }
+
+// GetFilterTagByName 根据名称查找用户的文件分类标签
+func GetFilterTagByName(name string, uid uint) (*Tag, error) {
+	var tag Tag
+	result := DB.Where("user_id = ? and type = ? and name = ?", uid, FileTagType, name).First(&tag)
+	return &tag, result.Error
+}
+
+// IsLegacyExpression 表达式是否为旧版的多行 LIKE 匹配模式，新版表达式中的
+// 通配符均已规范化为 *，不会包含换行和 %
+func IsLegacyExpression(expression string) bool {
+	return strings.ContainsAny(expression, "\n%")
+}
+
+// Query 解析文件分类标签的搜索条件，旧版表达式中任意一行匹配文件名即可，
+// 会被转换为等价的查询
+func (tag *Tag) Query() (query.Expr, error) {
+	if !IsLegacyExpression(tag.Expression) {
+		return query.Parse(tag.Expression)
+	}
+
+	lines := strings.Split(tag.Expression, "\n")
+	res := make(query.Or, 0, len(lines))
+	for _, line := range lines {
+		if line != "" {
+			res = append(res, query.Name{Glob: strings.ReplaceAll(line, "%", "*")})
+		}
+	}
+	if len(res) == 0 {
+		return nil, query.ErrEmpty
+	}
+	return res, nil
+}
@@ -61,3 +61,38 @@ func TestGetTagsByID(t *testing.T) {
 	asserts.NoError(err)
 	asserts.EqualValues("tag", res.Name)
 }
+
+func TestTag_Query(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 查询语法
+	{
+		tag := Tag{Expression: "ext:jpg,png size:>1M"}
+		expr, err := tag.Query()
+		asserts.NoError(err)
+		asserts.Equal("ext:jpg,png size:>=1048577", expr.String())
+	}
+
+	// 旧版多行表达式
+	{
+		tag := Tag{Expression: "%.jpg\n\n*.png"}
+		expr, err := tag.Query()
+		asserts.NoError(err)
+		asserts.Equal("name:*.jpg OR name:*.png", expr.String())
+	}
+
+	// 旧版单行表达式
+	{
+		tag := Tag{Expression: "report%"}
+		expr, err := tag.Query()
+		asserts.NoError(err)
+		asserts.Equal("name:report*", expr.String())
+	}
+
+	// 无效查询
+	{
+		tag := Tag{Expression: "size:big"}
+		_, err := tag.Query()
+		asserts.Error(err)
+	}
+}
@@ -1,6 +1,8 @@
 package model
 
 import (
+	"time"
+
 	"github.com/cloudreve/Cloudreve/v3/pkg/util"
 	"github.com/jinzhu/gorm"
 )
@@ -14,6 +16,14 @@ type Task struct {
 	Progress int    // 进度
 	Error    string `gorm:"type:text"` // 错误信息
 	Props    string `gorm:"type:text"` // 任务属性
+
+	// 任务租约，由领取任务的实例持有并定期续约；为空或过期时可被其他实例领取
+	Owner      string     `json:"-"`
+	LeaseUntil *time.Time `json:"-" gorm:"index:task_lease"`
+	// 依赖本机文件的任务只能由该节点上的实例领取，为空时可由任意实例领取
+	Node string `json:"-"`
+	// 创建任务的请求 ID，用于将任务日志与请求关联
+	RequestID string `json:"-" gorm:"size:64"`
 }
 
 // Create 创建任务记录
@@ -35,6 +45,16 @@ func (task *Task) SetProgress(progress int) error {
 	return DB.Model(task).Select("progress").Updates(map[string]interface{}{"progress": progress}).Error
 }
 
+// SetProps 更新任务属性
+func (task *Task) SetProps(props string) error {
+	return DB.Model(task).Select("props").Updates(map[string]interface{}{"props": props}).Error
+}
+
+// SetRequestID 设定创建任务的请求 ID
+func (task *Task) SetRequestID(id string) error {
+	return DB.Model(task).Select("request_id").Updates(map[string]interface{}{"request_id": id}).Error
+}
+
 // SetError 设定错误信息
 func (task *Task) SetError(err string) error {
 	return DB.Model(task).Select("error").Updates(map[string]interface{}{"error": err}).Error
@@ -47,6 +67,41 @@ func GetTasksByStatus(status ...int) []Task {
 	return tasks
 }
 
+// GetLeasableTasks 检索给定类型中处于给定状态，且未被领取或租约已过期、可由给定节点执行的任务
+func GetLeasableTasks(taskType, limit int, node string, status ...int) []Task {
+	var tasks []Task
+	DB.Where("type = ? AND status in (?) AND (lease_until IS NULL OR lease_until < ?) AND (node = '' OR node = ?)",
+		taskType, status, time.Now(), node).
+		Order("id asc").Limit(limit).Find(&tasks)
+	return tasks
+}
+
+// UpdateUnleased 在任务处于给定状态，且未被领取或租约已过期时更新任务，返回是否更新成功
+func (task *Task) UpdateUnleased(props map[string]interface{}, status ...int) (bool, error) {
+	result := DB.Model(task).
+		Where("status in (?) AND (lease_until IS NULL OR lease_until < ?)", status, time.Now()).
+		Updates(props)
+	return result.RowsAffected > 0, result.Error
+}
+
+// Lease 领取处于给定状态的任务，租约在 until 时到期
+func (task *Task) Lease(owner string, until time.Time, status ...int) (bool, error) {
+	return task.UpdateUnleased(map[string]interface{}{"owner": owner, "lease_until": until}, status...)
+}
+
+// RenewLease 续约任务，返回租约是否仍由 owner 持有
+func (task *Task) RenewLease(owner string, until time.Time) (bool, error) {
+	result := DB.Model(&Task{}).Where("id = ? AND owner = ?", task.ID, owner).
+		Update("lease_until", until)
+	return result.RowsAffected > 0, result.Error
+}
+
+// ReleaseLease 释放 owner 持有的任务租约
+func (task *Task) ReleaseLease(owner string) error {
+	return DB.Model(&Task{}).Where("id = ? AND owner = ?", task.ID, owner).
+		Updates(map[string]interface{}{"owner": "", "lease_until": nil}).Error
+}
+
 // GetTasksByID 根据ID检索任务
 func GetTasksByID(id interface{}) (*Task, error) {
 	task := &Task{}
@@ -6,6 +6,7 @@ import (
 	"github.com/jinzhu/gorm"
 	"github.com/stretchr/testify/assert"
 	"testing"
+	"time"
 )
 
 func TestTask_Create(t *testing.T) {
@@ -71,6 +72,18 @@ func TestTask_SetProgress(t *testing.T) {
 	asserts.NoError(mock.ExpectationsWereMet())
 }
 
+func TestTask_SetRequestID(t *testing.T) {
+	asserts := assert.New(t)
+	task := Task{
+		Model: gorm.Model{ID: 1},
+	}
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE(.+)request_id(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	asserts.NoError(task.SetRequestID("req"))
+	asserts.NoError(mock.ExpectationsWereMet())
+}
+
 func TestGetTasksByID(t *testing.T) {
 	asserts := assert.New(t)
 	mock.ExpectQuery("SELECT(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
@@ -102,3 +115,63 @@ func TestGetTasksByStatus(t *testing.T) {
 	a.NoError(mock.ExpectationsWereMet())
 	a.Len(res, 1)
 }
+
+func TestTaskLeaseSQLite(t *testing.T) {
+	asserts := assert.New(t)
+	DB, _ = gorm.Open("sqlite", ":memory:")
+	defer func() {
+		DB = mockDB
+	}()
+	DB.AutoMigrate(&Task{})
+
+	queued := &Task{Type: 1}
+	processing := &Task{Type: 1, Status: 1}
+	failed := &Task{Type: 1, Status: 2}
+	other := &Task{Type: 2}
+	for _, task := range []*Task{queued, processing, failed, other} {
+		_, err := task.Create()
+		asserts.NoError(err)
+	}
+	asserts.Len(GetLeasableTasks(1, 10, "", 0, 1), 2)
+	asserts.Len(GetLeasableTasks(1, 1, "", 0, 1), 1)
+
+	// 已被领取的任务不能重复领取
+	until := time.Now().Add(time.Minute)
+	ok, err := queued.Lease("a", until, 0, 1)
+	asserts.NoError(err)
+	asserts.True(ok)
+	ok, err = queued.Lease("b", until, 0, 1)
+	asserts.NoError(err)
+	asserts.False(ok)
+	ok, _ = failed.Lease("a", until, 0, 1)
+	asserts.False(ok)
+	leasable := GetLeasableTasks(1, 10, "", 0, 1)
+	asserts.Len(leasable, 1)
+	asserts.Equal(processing.ID, leasable[0].ID)
+
+	// 仅持有者可续约
+	ok, err = queued.RenewLease("b", until)
+	asserts.NoError(err)
+	asserts.False(ok)
+	ok, err = queued.RenewLease("a", time.Now().Add(-time.Second))
+	asserts.NoError(err)
+	asserts.True(ok)
+
+	// 租约过期后可被其他实例领取
+	ok, _ = queued.Lease("b", until, 0, 1)
+	asserts.True(ok)
+	asserts.NoError(queued.ReleaseLease("a"))
+	found, _ := GetTasksByID(queued.ID)
+	asserts.Equal("b", found.Owner)
+	asserts.NoError(queued.ReleaseLease("b"))
+	found, _ = GetTasksByID(queued.ID)
+	asserts.Empty(found.Owner)
+	asserts.Nil(found.LeaseUntil)
+
+	// 固定节点的任务只能由该节点领取
+	pinned := &Task{Type: 3, Node: "node-a"}
+	_, err = pinned.Create()
+	asserts.NoError(err)
+	asserts.Len(GetLeasableTasks(3, 10, "node-b", 0, 1), 0)
+	asserts.Len(GetLeasableTasks(3, 10, "node-a", 0, 1), 1)
+}
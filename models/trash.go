@@ -0,0 +1,216 @@
+package model
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+	"github.com/jinzhu/gorm"
+)
+
+// Trash 回收站中的对象，对应一个被删除的顶层文件或目录。
+// 被删除的对象及其子对象会被软删除，直到被还原或彻底删除
+type Trash struct {
+	gorm.Model
+	UserID   uint   `gorm:"index:trash_user_id"`
+	Name     string // 删除前的对象名
+	IsDir    bool   // 是否为目录
+	ObjectID uint   // 对应的文件或目录ID
+	ParentID uint   // 删除前的父目录ID
+	Position string `gorm:"type:text"` // 删除前的父目录路径
+	Size     uint64 // 包含的文件总大小
+}
+
+// TrashNamePrefix 回收站中顶层对象的临时名称前缀，包含保留字符以避免与正常对象重名
+const TrashNamePrefix = "trash:"
+
+// Create 创建回收站记录，并软删除给定的文件和目录
+func (trash *Trash) Create(files, folders []uint) error {
+	tx := DB.Begin()
+
+	if err := tx.Create(trash).Error; err != nil {
+		util.Log().Warning("Failed to insert trash record: %s", err)
+		tx.Rollback()
+		return err
+	}
+
+	// 重命名顶层对象，释放原有的文件名
+	var object interface{} = &File{}
+	if trash.IsDir {
+		object = &Folder{}
+	}
+	if err := tx.Model(object).Where("id = ?", trash.ObjectID).
+		UpdateColumn("name", trash.TrashName()).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if len(files) > 0 {
+		if err := tx.Where("id in (?)", files).Delete(&File{}).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if len(folders) > 0 {
+		if err := tx.Where("id in (?)", folders).Delete(&Folder{}).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit().Error
+}
+
+// TrashName 返回对象在回收站中的临时名称
+func (trash *Trash) TrashName() string {
+	return fmt.Sprintf("%s%d", TrashNamePrefix, trash.ID)
+}
+
+// ListObjects 列出此回收站对象包含的全部文件和目录，包括自身。
+// 在此之前已被单独删除的子对象不包含在内
+func (trash *Trash) ListObjects() ([]File, []Folder, error) {
+	var (
+		files   []File
+		folders []Folder
+	)
+
+	if !trash.IsDir {
+		err := DB.Unscoped().Where("id = ? and user_id = ?", trash.ObjectID, trash.UserID).Find(&files).Error
+		return files, folders, err
+	}
+
+	if err := DB.Unscoped().Where("id = ? and owner_id = ?", trash.ObjectID, trash.UserID).
+		Find(&folders).Error; err != nil {
+		return nil, nil, err
+	}
+
+	parentIDs := make([]uint, 0, len(folders))
+	for _, folder := range folders {
+		parentIDs = append(parentIDs, folder.ID)
+	}
+
+	// 递归查询子目录，最大递归65535次
+	for i := 0; i < 65535 && len(parentIDs) > 0; i++ {
+		var children []Folder
+		if err := DB.Unscoped().
+			Where("owner_id = ? and parent_id in (?) and deleted_at is not null and name not like ?",
+				trash.UserID, parentIDs, TrashNamePrefix+"%").
+			Find(&children).Error; err != nil {
+			return nil, nil, err
+		}
+
+		parentIDs = make([]uint, 0, len(children))
+		for _, folder := range children {
+			parentIDs = append(parentIDs, folder.ID)
+		}
+		folders = append(folders, children...)
+	}
+
+	folderIDs := make([]uint, 0, len(folders))
+	for _, folder := range folders {
+		folderIDs = append(folderIDs, folder.ID)
+	}
+
+	if len(folderIDs) > 0 {
+		if err := DB.Unscoped().
+			Where("user_id = ? and folder_id in (?) and deleted_at is not null and name not like ?",
+				trash.UserID, folderIDs, TrashNamePrefix+"%").
+			Find(&files).Error; err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return files, folders, nil
+}
+
+// Restore 将回收站对象还原至指定目录，并删除回收站记录
+func (trash *Trash) Restore(parentID uint, files, folders []uint) error {
+	tx := DB.Begin()
+
+	if len(files) > 0 {
+		if err := tx.Unscoped().Model(&File{}).Where("id in (?)", files).
+			UpdateColumn("deleted_at", nil).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if len(folders) > 0 {
+		if err := tx.Unscoped().Model(&Folder{}).Where("id in (?)", folders).
+			UpdateColumn("deleted_at", nil).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	// 恢复顶层对象的名称和父目录
+	var err error
+	if trash.IsDir {
+		err = tx.Model(&Folder{}).Where("id = ?", trash.ObjectID).
+			UpdateColumns(map[string]interface{}{"name": trash.Name, "parent_id": parentID}).Error
+	} else {
+		err = tx.Model(&File{}).Where("id = ?", trash.ObjectID).
+			UpdateColumns(map[string]interface{}{"name": trash.Name, "folder_id": parentID}).Error
+	}
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Unscoped().Delete(trash).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+// Delete 删除回收站记录
+func (trash *Trash) Delete() error {
+	return DB.Unscoped().Delete(trash).Error
+}
+
+// ExpiresAt 根据用户组设定的保留天数计算回收站对象的过期时间
+func (trash *Trash) ExpiresAt(group *Group) time.Time {
+	return trash.CreatedAt.Add(time.Duration(group.OptionsSerialized.TrashRetention) * 24 * time.Hour)
+}
+
+// GetTrashByIDs 根据ID和用户ID查找回收站对象
+func GetTrashByIDs(ids []uint, uid uint) ([]Trash, error) {
+	var trashes []Trash
+	result := DB.Where("id in (?) and user_id = ?", ids, uid).Find(&trashes)
+	return trashes, result.Error
+}
+
+// GetTrashByUID 列出用户回收站中的全部对象
+func GetTrashByUID(uid uint) ([]Trash, error) {
+	var trashes []Trash
+	result := DB.Where("user_id = ?", uid).Order("created_at desc").Find(&trashes)
+	return trashes, result.Error
+}
+
+// GetExpiredTrash 列出所有已超过用户组保留期限的回收站对象
+func GetExpiredTrash() ([]Trash, error) {
+	var groups []Group
+	if err := DB.Find(&groups).Error; err != nil {
+		return nil, err
+	}
+
+	res := make([]Trash, 0)
+	for _, group := range groups {
+		expires := time.Now().Add(-time.Duration(group.OptionsSerialized.TrashRetention) * 24 * time.Hour)
+
+		var trashes []Trash
+		if err := DB.Select("trashes.*").
+			Joins("join users on users.id = trashes.user_id").
+			Where("users.group_id = ? and trashes.created_at < ?", group.ID, expires).
+			Find(&trashes).Error; err != nil {
+			return nil, err
+		}
+
+		res = append(res, trashes...)
+	}
+
+	return res, nil
+}
@@ -0,0 +1,151 @@
+package model
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrash_Create(t *testing.T) {
+	asserts := assert.New(t)
+	trash := Trash{UserID: 1, Name: "1.txt", ObjectID: 2}
+
+	// 无法插入记录
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		asserts.Error(trash.Create([]uint{2}, []uint{}))
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 无法重命名
+	{
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)").WillReturnResult(sqlmock.NewResult(5, 1))
+		mock.ExpectExec("UPDATE(.+)files(.+)").WithArgs("trash:5", 2).WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
+		asserts.Error(trash.Create([]uint{2}, []uint{}))
+		asserts.NoError(mock.ExpectationsWereMet())
+	}
+
+	// 成功
+	{
+		trash.ID = 0
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)").WillReturnResult(sqlmock.NewResult(6, 1))
+		mock.ExpectExec("UPDATE(.+)files(.+)").WithArgs("trash:6", 2).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("UPDATE(.+)files(.+)deleted_at(.+)").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+		asserts.NoError(trash.Create([]uint{2}, []uint{}))
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Equal("trash:6", trash.TrashName())
+	}
+}
+
+func TestGetTrashByIDs(t *testing.T) {
+	asserts := assert.New(t)
+
+	mock.ExpectQuery("SELECT(.+)trashes(.+)").
+		WithArgs(1, 2, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "1.txt"))
+	res, err := GetTrashByIDs([]uint{1, 2}, 3)
+	asserts.NoError(mock.ExpectationsWereMet())
+	asserts.NoError(err)
+	asserts.Len(res, 1)
+}
+
+func TestTrash_ExpiresAt(t *testing.T) {
+	asserts := assert.New(t)
+	trash := Trash{}
+	trash.CreatedAt = time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	group := &Group{OptionsSerialized: GroupOption{TrashRetention: 2}}
+	asserts.Equal(time.Date(2022, 1, 3, 0, 0, 0, 0, time.UTC), trash.ExpiresAt(group))
+}
+
+func TestTrashLifecycleSQLite(t *testing.T) {
+	asserts := assert.New(t)
+	DB, _ = gorm.Open("sqlite", ":memory:")
+	defer func() {
+		DB = mockDB
+	}()
+	DB.AutoMigrate(&User{}, &Group{}, &Folder{}, &File{}, &Trash{})
+
+	// 测试目录结构
+	//   /
+	//   └─ a
+	//      ├─ 1.txt
+	//      └─ b
+	//         └─ 2.txt
+	root := &Folder{Name: "/", OwnerID: 1}
+	asserts.NoError(DB.Create(root).Error)
+	a := &Folder{Name: "a", OwnerID: 1, ParentID: &root.ID}
+	asserts.NoError(DB.Create(a).Error)
+	b := &Folder{Name: "b", OwnerID: 1, ParentID: &a.ID}
+	asserts.NoError(DB.Create(b).Error)
+	file1 := &File{Name: "1.txt", UserID: 1, FolderID: a.ID, Size: 1}
+	asserts.NoError(DB.Create(file1).Error)
+	file2 := &File{Name: "2.txt", UserID: 1, FolderID: b.ID, Size: 2}
+	asserts.NoError(DB.Create(file2).Error)
+
+	// 单独删除 2.txt
+	trashFile := &Trash{UserID: 1, Name: "2.txt", ObjectID: file2.ID, ParentID: b.ID, Position: "/a/b"}
+	asserts.NoError(trashFile.Create([]uint{file2.ID}, nil))
+
+	// 删除目录 a
+	trashDir := &Trash{UserID: 1, Name: "a", IsDir: true, ObjectID: a.ID, ParentID: root.ID, Position: "/"}
+	asserts.NoError(trashDir.Create([]uint{file1.ID}, []uint{a.ID, b.ID}))
+
+	// 已删除的对象不可见，原名称可以重新使用
+	_, err := root.GetChild("a")
+	asserts.Error(err)
+	asserts.NoError(DB.Create(&Folder{Name: "a", OwnerID: 1, ParentID: &root.ID}).Error)
+
+	// 列出对象时不包含单独删除的子对象
+	files, folders, err := trashDir.ListObjects()
+	asserts.NoError(err)
+	asserts.Len(files, 1)
+	asserts.Len(folders, 2)
+
+	files, folders, err = trashFile.ListObjects()
+	asserts.NoError(err)
+	asserts.Len(files, 1)
+	asserts.Len(folders, 0)
+
+	// 还原目录至新的位置
+	asserts.NoError(trashDir.Restore(b.ID, []uint{file1.ID}, []uint{a.ID, b.ID}))
+	restored, err := GetFoldersByIDs([]uint{a.ID}, 1)
+	asserts.NoError(err)
+	asserts.Len(restored, 1)
+	asserts.Equal("a", restored[0].Name)
+	children, err := b.GetChildFiles()
+	asserts.NoError(err)
+	asserts.Len(children, 0)
+
+	trashes, err := GetTrashByUID(1)
+	asserts.NoError(err)
+	asserts.Len(trashes, 1)
+	asserts.Equal(trashFile.ID, trashes[0].ID)
+
+	// 过期的回收站对象
+	asserts.NoError(DB.Create(&Group{OptionsSerialized: GroupOption{TrashRetention: 1}}).Error)
+	user := &User{Email: "1@cloudreve.org", GroupID: 1}
+	asserts.NoError(DB.Create(user).Error)
+	expired, err := GetExpiredTrash()
+	asserts.NoError(err)
+	asserts.Len(expired, 0)
+	asserts.NoError(DB.Model(trashFile).UpdateColumn("created_at", time.Now().Add(-48*time.Hour)).Error)
+	expired, err = GetExpiredTrash()
+	asserts.NoError(err)
+	asserts.Len(expired, 1)
+	asserts.Equal("2.txt", expired[0].Name)
+
+	asserts.NoError(trashFile.Delete())
+	trashes, err = GetTrashByUID(1)
+	asserts.NoError(err)
+	asserts.Len(trashes, 0)
+}
@@ -0,0 +1,53 @@
+package model
+
+import (
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+	"github.com/jinzhu/gorm"
+)
+
+// UserIdentity 用户绑定的外部身份（OpenID Connect）
+type UserIdentity struct {
+	gorm.Model
+	UserID  uint   `gorm:"index:user_identity_user"`                    // 绑定的用户ID
+	Issuer  string `gorm:"size:255;unique_index:user_identity_subject"` // 身份提供方
+	Subject string `gorm:"size:255;unique_index:user_identity_subject"` // 外部身份的唯一标识
+	Email   string // 绑定时外部身份的邮箱，仅用于展示
+}
+
+// Create 创建外部身份绑定
+func (identity *UserIdentity) Create() error {
+	if err := DB.Create(identity).Error; err != nil {
+		util.Log().Warning("Failed to insert user identity record: %s", err)
+		return err
+	}
+	return nil
+}
+
+// GetUserIdentity 根据身份提供方和外部标识查找绑定
+func GetUserIdentity(issuer, subject string) (*UserIdentity, error) {
+	identity := &UserIdentity{}
+	result := DB.Where("issuer = ? and subject = ?", issuer, subject).First(identity)
+	return identity, result.Error
+}
+
+// SetIssuer 更新外部身份的提供方标识
+func (identity *UserIdentity) SetIssuer(issuer string) error {
+	return DB.Model(identity).UpdateColumn("issuer", issuer).Error
+}
+
+// ListUserIdentities 列出用户绑定的所有外部身份
+func ListUserIdentities(uid uint) ([]UserIdentity, error) {
+	var identities []UserIdentity
+	result := DB.Where("user_id = ?", uid).Order("created_at").Find(&identities)
+	return identities, result.Error
+}
+
+// DeleteUserIdentity 解除用户与给定身份提供方的绑定，
+// 记录被彻底删除，以便外部身份之后可重新绑定
+func DeleteUserIdentity(uid uint, issuer string) error {
+	result := DB.Unscoped().Where("user_id = ? and issuer = ?", uid, issuer).Delete(&UserIdentity{})
+	if result.Error == nil && result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return result.Error
+}
@@ -0,0 +1,44 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserIdentityLifecycleSQLite(t *testing.T) {
+	asserts := assert.New(t)
+	DB, _ = gorm.Open("sqlite", ":memory:")
+	defer func() {
+		DB = mockDB
+	}()
+	DB.AutoMigrate(&UserIdentity{})
+
+	identity := &UserIdentity{UserID: 1, Issuer: "https://idp", Subject: "alice", Email: "alice@example.com"}
+	asserts.NoError(identity.Create())
+
+	// 同一外部身份不能重复绑定
+	asserts.Error((&UserIdentity{UserID: 2, Issuer: "https://idp", Subject: "alice"}).Create())
+
+	found, err := GetUserIdentity("https://idp", "alice")
+	asserts.NoError(err)
+	asserts.EqualValues(1, found.UserID)
+	_, err = GetUserIdentity("https://other", "alice")
+	asserts.True(gorm.IsRecordNotFoundError(err))
+
+	// 更新提供方标识
+	asserts.NoError(found.SetIssuer("https://idp2"))
+	_, err = GetUserIdentity("https://idp", "alice")
+	asserts.True(gorm.IsRecordNotFoundError(err))
+	asserts.NoError(found.SetIssuer("https://idp"))
+
+	identities, err := ListUserIdentities(1)
+	asserts.NoError(err)
+	asserts.Len(identities, 1)
+
+	// 解除绑定后可被其他用户重新绑定
+	asserts.Error(DeleteUserIdentity(2, "https://idp"))
+	asserts.NoError(DeleteUserIdentity(1, "https://idp"))
+	asserts.NoError((&UserIdentity{UserID: 2, Issuer: "https://idp", Subject: "alice"}).Create())
+}
@@ -1,18 +1,34 @@
 package model
 
 import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"net"
+	"strings"
+
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
 	"github.com/jinzhu/gorm"
 )
 
 // Webdav 应用账户
 type Webdav struct {
 	gorm.Model
-	Name     string // 应用名称
-	Password string `gorm:"unique_index:password_only_on"` // 应用密码
-	UserID   uint   `gorm:"unique_index:password_only_on"` // 用户ID
-	Root     string `gorm:"type:text"`                     // 根目录
-	Readonly bool   `gorm:"type:bool"`                     // 是否只读
-	UseProxy bool   `gorm:"type:bool"`                     // 是否进行反代
+	Name        string // 应用名称
+	Password    string `gorm:"unique_index:password_only_on" json:"-"` // 应用密码的加盐摘要
+	UserID      uint   `gorm:"unique_index:password_only_on"`          // 用户ID
+	Root        string `gorm:"type:text"`                              // 根目录
+	Readonly    bool   `gorm:"type:bool"`                              // 是否只读
+	UseProxy    bool   `gorm:"type:bool"`                              // 是否进行反代
+	NoDelete    bool   `gorm:"type:bool"`                              // 是否禁止删除
+	UploadOnly  bool   `gorm:"type:bool"`                              // 是否仅允许上传
+	IPAllowlist string `gorm:"type:text"`                              // 允许访问的 IP 或 CIDR，以逗号分隔，为空时不限制
+}
+
+// hashSaltedPassword 计算 Salt 和密码组合的 SHA-256 摘要，存储格式为 $SALT:$HASH
+func hashSaltedPassword(password, salt string) string {
+	sum := sha256.Sum256([]byte(salt + password))
+	return salt + ":" + hex.EncodeToString(sum[:])
 }
 
 // Create 创建账户
@@ -23,11 +39,69 @@ func (webdav *Webdav) Create() (uint, error) {
 	return webdav.ID, nil
 }
 
-// GetWebdavByPassword 根据密码和用户查找Webdav应用
+// SetPassword 根据明文设定应用密码
+func (webdav *Webdav) SetPassword(password string) {
+	webdav.Password = hashSaltedPassword(password, util.RandStringRunes(16))
+}
+
+// IsPasswordHashed 应用密码是否已经以摘要形式存储，旧版本的应用密码为明文
+func (webdav *Webdav) IsPasswordHashed() bool {
+	return strings.Contains(webdav.Password, ":")
+}
+
+// CheckPassword 以恒定时间校验应用密码
+func (webdav *Webdav) CheckPassword(password string) bool {
+	expected := webdav.Password
+	if webdav.IsPasswordHashed() {
+		password = hashSaltedPassword(password, strings.SplitN(webdav.Password, ":", 2)[0])
+	}
+
+	return expected != "" && subtle.ConstantTimeCompare([]byte(expected), []byte(password)) == 1
+}
+
+// AllowIP 客户端 IP 是否在账户的允许列表中
+func (webdav *Webdav) AllowIP(ip string) bool {
+	if strings.TrimSpace(webdav.IPAllowlist) == "" {
+		return true
+	}
+
+	client := net.ParseIP(ip)
+	if client == nil {
+		return false
+	}
+
+	for _, rule := range strings.Split(webdav.IPAllowlist, ",") {
+		rule = strings.TrimSpace(rule)
+		if _, network, err := net.ParseCIDR(rule); err == nil {
+			if network.Contains(client) {
+				return true
+			}
+		} else if allowed := net.ParseIP(rule); allowed != nil && allowed.Equal(client) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// GetWebdavByPassword 根据密码和用户查找Webdav应用，仍以明文存储的密码校验通过后会被转换为摘要
 func GetWebdavByPassword(password string, uid uint) (*Webdav, error) {
-	webdav := &Webdav{}
-	res := DB.Where("user_id = ? and password = ?", uid, password).First(webdav)
-	return webdav, res.Error
+	for _, account := range ListWebDAVAccounts(uid) {
+		if !account.CheckPassword(password) {
+			continue
+		}
+
+		if !account.IsPasswordHashed() {
+			account.SetPassword(password)
+			if err := DB.Model(&account).UpdateColumn("password", account.Password).Error; err != nil {
+				util.Log().Warning("Failed to hash password of WebDAV account %d: %s", account.ID, err)
+			}
+		}
+
+		return &account, nil
+	}
+
+	return nil, gorm.ErrRecordNotFound
 }
 
 // ListWebDAVAccounts 列出用户的所有账号
@@ -34,12 +34,86 @@ func TestWebdav_Create(t *testing.T) {
 	}
 }
 
+func TestWebdav_CheckPassword(t *testing.T) {
+	asserts := assert.New(t)
+	account := Webdav{}
+
+	// 未设定密码
+	asserts.False(account.CheckPassword(""))
+
+	// 摘要
+	account.SetPassword("secret")
+	asserts.True(account.IsPasswordHashed())
+	asserts.NotContains(account.Password, "secret")
+	asserts.True(account.CheckPassword("secret"))
+	asserts.False(account.CheckPassword("wrong"))
+	asserts.False(account.CheckPassword(account.Password))
+
+	// 旧版本明文
+	account.Password = "secret"
+	asserts.False(account.IsPasswordHashed())
+	asserts.True(account.CheckPassword("secret"))
+	asserts.False(account.CheckPassword("wrong"))
+}
+
+func TestWebdav_AllowIP(t *testing.T) {
+	asserts := assert.New(t)
+	account := Webdav{}
+	asserts.True(account.AllowIP("1.1.1.1"))
+
+	account.IPAllowlist = "10.0.0.0/8, 192.168.1.1,::1,invalid"
+	asserts.True(account.AllowIP("10.1.2.3"))
+	asserts.True(account.AllowIP("192.168.1.1"))
+	asserts.True(account.AllowIP("::1"))
+	asserts.False(account.AllowIP("192.168.1.2"))
+	asserts.False(account.AllowIP("invalid"))
+	asserts.False(account.AllowIP(""))
+}
+
 func TestGetWebdavByPassword(t *testing.T) {
 	asserts := assert.New(t)
-	mock.ExpectQuery("SELECT(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
-	_, err := GetWebdavByPassword("e", 1)
-	asserts.NoError(mock.ExpectationsWereMet())
-	asserts.Error(err)
+	hashed := Webdav{}
+	hashed.SetPassword("e")
+
+	// 不存在
+	{
+		mock.ExpectQuery("SELECT(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		_, err := GetWebdavByPassword("e", 1)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Error(err)
+	}
+
+	// 密码错误
+	{
+		mock.ExpectQuery("SELECT(.+)").WillReturnRows(sqlmock.NewRows([]string{"id", "password"}).AddRow(1, hashed.Password))
+		_, err := GetWebdavByPassword("f", 1)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.Error(err)
+	}
+
+	// 成功
+	{
+		mock.ExpectQuery("SELECT(.+)").WillReturnRows(sqlmock.NewRows([]string{"id", "password"}).
+			AddRow(1, "f").
+			AddRow(2, hashed.Password))
+		res, err := GetWebdavByPassword("e", 1)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.EqualValues(2, res.ID)
+	}
+
+	// 旧版本明文密码，成功后转换为摘要
+	{
+		mock.ExpectQuery("SELECT(.+)").WillReturnRows(sqlmock.NewRows([]string{"id", "password"}).AddRow(1, "e"))
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE(.+)webdavs(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		res, err := GetWebdavByPassword("e", 1)
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.True(res.IsPasswordHashed())
+		asserts.True(res.CheckPassword("e"))
+	}
 }
 
 func TestListWebDAVAccounts(t *testing.T) {
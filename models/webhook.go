@@ -0,0 +1,191 @@
+package model
+
+import (
+	"strings"
+	"time"
+
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+	"github.com/jinzhu/gorm"
+)
+
+// Webhook 事件推送订阅
+type Webhook struct {
+	gorm.Model
+	UserID  uint   `gorm:"index:webhook_user"` // 所属用户ID，为0时为站点级订阅，接收所有用户的事件
+	Name    string // 订阅名称
+	URL     string `gorm:"type:text"`
+	Secret  string `json:"-"`         // 签名密钥
+	Events  string `gorm:"type:text"` // 订阅的事件，以逗号分隔
+	Enabled bool
+}
+
+// WebhookDelivery 事件推送记录
+type WebhookDelivery struct {
+	ID            uint      `gorm:"primary_key"`
+	CreatedAt     time.Time `gorm:"index:webhook_delivery_created_at"`
+	UpdatedAt     time.Time
+	WebhookID     uint       `gorm:"index:webhook_delivery_webhook"`
+	Event         string     // 事件类型
+	Payload       string     `gorm:"type:text"` // 推送的消息体
+	Status        string     `gorm:"index:webhook_delivery_status"`
+	Attempts      int        // 已尝试次数
+	ResponseCode  int        // 最后一次尝试的响应状态码
+	Response      string     `gorm:"type:text"` // 最后一次尝试的响应正文，超出长度时截断
+	Error         string     `gorm:"type:text"` // 最后一次尝试的错误信息
+	ReplayOf      uint       // 重放的源推送记录ID，为0时为首次推送
+	DeliveredAt   *time.Time // 最后一次尝试的时间
+	NextAttemptAt *time.Time `gorm:"index:webhook_delivery_next_attempt"` // 下次重试的时间，为空时尽快推送
+}
+
+const (
+	// WebhookDeliveryPending 等待推送
+	WebhookDeliveryPending = "pending"
+	// WebhookDeliverySuccess 推送成功
+	WebhookDeliverySuccess = "success"
+	// WebhookDeliveryFailed 重试后仍推送失败
+	WebhookDeliveryFailed = "failed"
+)
+
+// Create 创建订阅
+func (hook *Webhook) Create() error {
+	if err := DB.Create(hook).Error; err != nil {
+		util.Log().Warning("Failed to insert webhook record: %s", err)
+		return err
+	}
+	return nil
+}
+
+// Update 更新订阅属性
+func (hook *Webhook) Update(props map[string]interface{}) error {
+	return DB.Model(hook).Updates(props).Error
+}
+
+// EventList 返回订阅的事件列表
+func (hook *Webhook) EventList() []string {
+	if hook.Events == "" {
+		return []string{}
+	}
+	return strings.Split(hook.Events, ",")
+}
+
+// HasEvent 是否订阅了给定事件
+func (hook *Webhook) HasEvent(event string) bool {
+	return util.ContainsString(hook.EventList(), event)
+}
+
+// GetWebhookByID 根据ID查找订阅
+func GetWebhookByID(id uint) (*Webhook, error) {
+	hook := &Webhook{}
+	result := DB.Where("id = ?", id).First(hook)
+	return hook, result.Error
+}
+
+// ListWebhooks 列出用户的所有订阅
+func ListWebhooks(uid uint) ([]Webhook, error) {
+	var hooks []Webhook
+	result := DB.Where("user_id = ?", uid).Order("created_at desc").Find(&hooks)
+	return hooks, result.Error
+}
+
+// CountWebhooks 统计用户的订阅数量
+func CountWebhooks(uid uint) int {
+	var count int
+	DB.Model(&Webhook{}).Where("user_id = ?", uid).Count(&count)
+	return count
+}
+
+// GetWebhooksByEvent 查找订阅了给定用户事件的已启用订阅，包括站点级订阅
+func GetWebhooksByEvent(uid uint, event string) ([]Webhook, error) {
+	var hooks []Webhook
+	result := DB.Where("enabled = ? and user_id in (?)", true, []uint{0, uid}).Find(&hooks)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	res := make([]Webhook, 0, len(hooks))
+	for _, hook := range hooks {
+		if hook.HasEvent(event) {
+			res = append(res, hook)
+		}
+	}
+
+	return res, nil
+}
+
+// DeleteWebhookByID 根据ID和所属用户ID删除订阅及其推送记录
+func DeleteWebhookByID(id, uid uint) error {
+	result := DB.Where("id = ? and user_id = ?", id, uid).Delete(&Webhook{})
+	if result.Error == nil && result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	if result.Error != nil {
+		return result.Error
+	}
+
+	return DB.Where("webhook_id = ?", id).Delete(&WebhookDelivery{}).Error
+}
+
+// DeleteWebhooksByUserID 删除用户的所有订阅及其推送记录
+func DeleteWebhooksByUserID(uid uint) error {
+	if err := DB.Where("webhook_id in (?)", DB.Table("webhooks").Select("id").Where("user_id = ?", uid).QueryExpr()).
+		Delete(&WebhookDelivery{}).Error; err != nil {
+		return err
+	}
+
+	return DB.Where("user_id = ?", uid).Delete(&Webhook{}).Error
+}
+
+// Create 创建推送记录
+func (delivery *WebhookDelivery) Create() error {
+	return DB.Create(delivery).Error
+}
+
+// UpdateResult 更新推送记录的状态及最后一次尝试的结果
+func (delivery *WebhookDelivery) UpdateResult() error {
+	return DB.Model(delivery).Updates(map[string]interface{}{
+		"status":          delivery.Status,
+		"attempts":        delivery.Attempts,
+		"response_code":   delivery.ResponseCode,
+		"response":        delivery.Response,
+		"error":           delivery.Error,
+		"delivered_at":    delivery.DeliveredAt,
+		"next_attempt_at": delivery.NextAttemptAt,
+	}).Error
+}
+
+// GetWebhookDeliveryByID 根据ID查找推送记录
+func GetWebhookDeliveryByID(id uint) (*WebhookDelivery, error) {
+	delivery := &WebhookDelivery{}
+	result := DB.Where("id = ?", id).First(delivery)
+	return delivery, result.Error
+}
+
+// ListWebhookDeliveries 分页列出订阅的推送记录，新的在前
+func ListWebhookDeliveries(webhookID uint, page, pageSize int) ([]WebhookDelivery, int, error) {
+	var (
+		deliveries []WebhookDelivery
+		total      int
+	)
+
+	tx := DB.Model(&WebhookDelivery{}).Where("webhook_id = ?", webhookID)
+	if err := tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	result := tx.Order("id desc").Limit(pageSize).Offset((page - 1) * pageSize).Find(&deliveries)
+	return deliveries, total, result.Error
+}
+
+// GetDueWebhookDeliveries 列出尚未完成且已到重试时间的推送记录，先创建的在前
+func GetDueWebhookDeliveries(now time.Time, limit int) ([]WebhookDelivery, error) {
+	var deliveries []WebhookDelivery
+	result := DB.Where("status = ? and (next_attempt_at is null or next_attempt_at <= ?)", WebhookDeliveryPending, now).
+		Order("id asc").Limit(limit).Find(&deliveries)
+	return deliveries, result.Error
+}
+
+// DeleteWebhookDeliveriesBefore 删除给定时间之前的推送记录，返回删除的条目数
+func DeleteWebhookDeliveriesBefore(t time.Time) (int64, error) {
+	result := DB.Where("created_at < ? and status <> ?", t, WebhookDeliveryPending).Delete(&WebhookDelivery{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,108 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebhookLifecycleSQLite(t *testing.T) {
+	asserts := assert.New(t)
+	DB, _ = gorm.Open("sqlite", ":memory:")
+	defer func() {
+		DB = mockDB
+	}()
+	DB.AutoMigrate(&Webhook{}, &WebhookDelivery{})
+
+	userHook := &Webhook{UserID: 1, Name: "user", URL: "https://example.com", Events: "file.uploaded,share.created", Enabled: true}
+	siteHook := &Webhook{Name: "site", URL: "https://example.com", Events: "file.uploaded", Enabled: true}
+	disabled := &Webhook{UserID: 1, Name: "disabled", URL: "https://example.com", Events: "file.uploaded"}
+	otherHook := &Webhook{UserID: 2, Name: "other", URL: "https://example.com", Events: "file.uploaded", Enabled: true}
+	for _, hook := range []*Webhook{userHook, siteHook, disabled, otherHook} {
+		asserts.NoError(hook.Create())
+	}
+
+	// 按事件查找，包括站点级订阅，不包括已停用及其他用户的订阅
+	hooks, err := GetWebhooksByEvent(1, "file.uploaded")
+	asserts.NoError(err)
+	asserts.Len(hooks, 2)
+	hooks, err = GetWebhooksByEvent(1, "share.created")
+	asserts.NoError(err)
+	asserts.Len(hooks, 1)
+	asserts.Equal(userHook.ID, hooks[0].ID)
+	hooks, err = GetWebhooksByEvent(0, "file.uploaded")
+	asserts.NoError(err)
+	asserts.Len(hooks, 1)
+	asserts.Equal(siteHook.ID, hooks[0].ID)
+
+	asserts.Equal([]string{"file.uploaded", "share.created"}, userHook.EventList())
+	asserts.Empty((&Webhook{}).EventList())
+	asserts.Equal(2, CountWebhooks(1))
+	list, err := ListWebhooks(0)
+	asserts.NoError(err)
+	asserts.Len(list, 1)
+
+	// 推送记录
+	delivery := &WebhookDelivery{WebhookID: userHook.ID, Event: "file.uploaded", Status: WebhookDeliveryPending}
+	asserts.NoError(delivery.Create())
+	done := &WebhookDelivery{WebhookID: userHook.ID, Event: "file.uploaded", Status: WebhookDeliverySuccess}
+	asserts.NoError(done.Create())
+
+	pending, err := GetDueWebhookDeliveries(time.Now(), 10)
+	asserts.NoError(err)
+	asserts.Len(pending, 1)
+	asserts.Equal(delivery.ID, pending[0].ID)
+
+	// 未到重试时间
+	now := time.Now()
+	next := now.Add(time.Minute)
+	delivery.NextAttemptAt = &next
+	asserts.NoError(delivery.UpdateResult())
+	pending, err = GetDueWebhookDeliveries(now, 10)
+	asserts.NoError(err)
+	asserts.Len(pending, 0)
+	pending, err = GetDueWebhookDeliveries(next, 10)
+	asserts.NoError(err)
+	asserts.Len(pending, 1)
+
+	delivery.Status = WebhookDeliveryFailed
+	delivery.Attempts = 2
+	delivery.ResponseCode = 500
+	delivery.DeliveredAt = &now
+	asserts.NoError(delivery.UpdateResult())
+	found, err := GetWebhookDeliveryByID(delivery.ID)
+	asserts.NoError(err)
+	asserts.Equal(2, found.Attempts)
+	asserts.Equal(WebhookDeliveryFailed, found.Status)
+
+	deliveries, total, err := ListWebhookDeliveries(userHook.ID, 1, 1)
+	asserts.NoError(err)
+	asserts.Equal(2, total)
+	asserts.Len(deliveries, 1)
+	asserts.Equal(done.ID, deliveries[0].ID)
+
+	// 清理过期记录时保留未完成的推送
+	pendingDelivery := &WebhookDelivery{WebhookID: siteHook.ID, Status: WebhookDeliveryPending}
+	asserts.NoError(pendingDelivery.Create())
+	deleted, err := DeleteWebhookDeliveriesBefore(time.Now().Add(time.Minute))
+	asserts.NoError(err)
+	asserts.EqualValues(2, deleted)
+
+	// 删除订阅
+	asserts.Error(DeleteWebhookByID(siteHook.ID, 1))
+	asserts.NoError(DeleteWebhookByID(siteHook.ID, 0))
+	_, err = GetWebhookByID(siteHook.ID)
+	asserts.Error(err)
+	_, err = GetWebhookDeliveryByID(pendingDelivery.ID)
+	asserts.Error(err)
+
+	// 删除用户的所有订阅
+	asserts.NoError((&WebhookDelivery{WebhookID: userHook.ID}).Create())
+	asserts.NoError(DeleteWebhooksByUserID(1))
+	asserts.Equal(0, CountWebhooks(1))
+	_, total, _ = ListWebhookDeliveries(userHook.ID, 1, 10)
+	asserts.Equal(0, total)
+	asserts.Equal(1, CountWebhooks(2))
+}
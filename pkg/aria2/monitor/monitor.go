@@ -6,6 +6,7 @@ import (
 	"errors"
 	"path/filepath"
 	"strconv"
+	"sync"
 	"time"
 
 	model "github.com/cloudreve/Cloudreve/v3/models"
@@ -17,6 +18,7 @@ import (
 	"github.com/cloudreve/Cloudreve/v3/pkg/mq"
 	"github.com/cloudreve/Cloudreve/v3/pkg/task"
 	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+	"github.com/cloudreve/Cloudreve/v3/pkg/webhook"
 )
 
 // Monitor 离线下载状态监控
@@ -31,6 +33,38 @@ type Monitor struct {
 
 var MAX_RETRY = 10
 
+var (
+	// monitors 监控中的任务及其最近一次的状态
+	monitors     = make(map[*Monitor]int)
+	monitorsLock sync.Mutex
+)
+
+// Stats 返回监控中的离线下载任务数量，按任务状态区分
+func Stats() map[int]int {
+	monitorsLock.Lock()
+	defer monitorsLock.Unlock()
+
+	res := make(map[int]int)
+	for _, status := range monitors {
+		res[status]++
+	}
+	return res
+}
+
+// track 记录监控中任务的当前状态
+func (monitor *Monitor) track() {
+	monitorsLock.Lock()
+	monitors[monitor] = monitor.Task.Status
+	monitorsLock.Unlock()
+}
+
+// untrack 移除已结束的监控
+func (monitor *Monitor) untrack() {
+	monitorsLock.Lock()
+	delete(monitors, monitor)
+	monitorsLock.Unlock()
+}
+
 // NewMonitor 新建离线下载状态监控
 func NewMonitor(task *model.Download, pool cluster.Pool, mqClient mq.MQ) {
 	monitor := &Monitor{
@@ -52,6 +86,8 @@ func NewMonitor(task *model.Download, pool cluster.Pool, mqClient mq.MQ) {
 // Loop 开启监控循环
 func (monitor *Monitor) Loop(mqClient mq.MQ) {
 	defer mqClient.Unsubscribe(monitor.Task.GID, monitor.notifier)
+	monitor.track()
+	defer monitor.untrack()
 
 	// 首次循环立即更新
 	interval := 50 * time.Millisecond
@@ -62,11 +98,13 @@ func (monitor *Monitor) Loop(mqClient mq.MQ) {
 			if monitor.Update() {
 				return
 			}
+			monitor.track()
 		case <-time.After(interval):
 			interval = monitor.Interval
 			if monitor.Update() {
 				return
 			}
+			monitor.track()
 		}
 	}
 }
@@ -304,6 +342,19 @@ func (monitor *Monitor) transfer(pool task.Pool) bool {
 	monitor.Task.TaskID = job.Model().ID
 	monitor.Task.Save()
 
+	names := make([]string, len(file))
+	for i, f := range file {
+		names[i] = filepath.Base(f)
+	}
+	webhook.Publish(context.Background(), webhook.EventDownloadFinished, monitor.Task.UserID, map[string]interface{}{
+		"gid":        monitor.Task.GID,
+		"source":     monitor.Task.Source,
+		"dst":        monitor.Task.Dst,
+		"total_size": monitor.Task.TotalSize,
+		"files":      names,
+		"task_id":    monitor.Task.TaskID,
+	})
+
 	return false
 }
 
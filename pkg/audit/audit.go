@@ -0,0 +1,232 @@
+package audit
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+	"github.com/gin-gonic/gin"
+)
+
+// 操作类型
+const (
+	ActionLogin          = "user.login"
+	ActionLogin2FA       = "user.login_2fa"
+	ActionPasswordChange = "user.password_change"
+	ActionPasswordReset  = "user.password_reset"
+	Action2FAEnable      = "user.2fa_enable"
+	Action2FADisable     = "user.2fa_disable"
+
+	ActionFileRename  = "file.rename"
+	ActionFileCopy    = "file.copy"
+	ActionFileMove    = "file.move"
+	ActionFileDelete  = "file.delete"
+	ActionFileTrash   = "file.trash"
+	ActionFileRestore = "file.restore"
+
+	ActionShareCreate = "share.create"
+	ActionShareAccess = "share.access"
+	ActionShareSave   = "share.save"
+
+	ActionAdminSettingChange = "admin.setting_change"
+	ActionAdminUserSave      = "admin.user_save"
+	ActionAdminUserDelete    = "admin.user_delete"
+	ActionAdminUserBan       = "admin.user_ban"
+	ActionAdminGroupSave     = "admin.group_save"
+	ActionAdminGroupDelete   = "admin.group_delete"
+	ActionAdminPolicySave    = "admin.policy_save"
+	ActionAdminPolicyDelete  = "admin.policy_delete"
+	ActionAdminNodeSave      = "admin.node_save"
+	ActionAdminNodeDelete    = "admin.node_delete"
+	ActionAdminFileDelete    = "admin.file_delete"
+	ActionAdminShareDelete   = "admin.share_delete"
+)
+
+// 操作对象类型
+const (
+	TargetUser    = "user"
+	TargetObjects = "objects"
+	TargetTrash   = "trash"
+	TargetShare   = "share"
+	TargetSetting = "setting"
+	TargetGroup   = "group"
+	TargetPolicy  = "policy"
+	TargetNode    = "node"
+)
+
+const (
+	// queueSize 待写入事件队列的长度，队列满时新事件会被丢弃
+	queueSize = 1024
+	// batchSize 单次写入数据库的最大事件数
+	batchSize = 100
+	// flushInterval 未满一批时的写入间隔
+	flushInterval = time.Second
+)
+
+// Event 审计事件
+type Event struct {
+	Action     string
+	User       *model.User // 操作者，为空时从请求上下文中获取
+	Actor      string      // 操作者标识，为空时使用操作者邮箱
+	TargetType string
+	TargetID   uint
+	Target     string
+	Detail     string
+	Err        error // 非空时事件记录为失败
+}
+
+var (
+	enabled int32
+	started int32
+	queue   = make(chan model.AuditLog, queueSize)
+	flushes = make(chan chan struct{})
+	start   sync.Once
+)
+
+// Init 根据设置启用或停用审计日志，并启动后台写入
+func Init() {
+	if model.IsTrueVal(model.GetSettingByName("audit_enabled")) {
+		atomic.StoreInt32(&enabled, 1)
+	} else {
+		atomic.StoreInt32(&enabled, 0)
+	}
+
+	start.Do(func() {
+		atomic.StoreInt32(&started, 1)
+		go run()
+	})
+}
+
+// Enabled 审计日志是否已启用
+func Enabled() bool {
+	return atomic.LoadInt32(&enabled) == 1
+}
+
+// Record 异步记录审计事件，操作者 IP 和 UA 从请求上下文中获取
+func Record(ctx context.Context, event Event) {
+	if !Enabled() {
+		return
+	}
+
+	select {
+	case queue <- newLog(ctx, event):
+	default:
+		util.Log().Warning("Audit log queue is full, event %q is dropped.", event.Action)
+	}
+}
+
+// Flush 等待已记录的事件全部写入数据库
+func Flush() {
+	if atomic.LoadInt32(&started) == 0 {
+		return
+	}
+
+	done := make(chan struct{})
+	flushes <- done
+	<-done
+}
+
+// newLog 根据事件及请求上下文构建审计日志
+func newLog(ctx context.Context, event Event) model.AuditLog {
+	log := model.AuditLog{
+		CreatedAt:  time.Now(),
+		Actor:      event.Actor,
+		Action:     event.Action,
+		TargetType: event.TargetType,
+		TargetID:   event.TargetID,
+		Target:     event.Target,
+		Detail:     event.Detail,
+		Result:     model.AuditResultSuccess,
+	}
+
+	if event.Err != nil {
+		log.Result = model.AuditResultFailure
+		if log.Detail == "" {
+			log.Detail = event.Err.Error()
+		}
+	}
+
+	user := event.User
+	if c := ginContext(ctx); c != nil {
+		log.IP = c.ClientIP()
+		log.UserAgent = c.Request.UserAgent()
+		if user == nil {
+			if u, ok := c.Get("user"); ok {
+				user, _ = u.(*model.User)
+			}
+		}
+	}
+
+	if user != nil && !user.IsAnonymous() {
+		log.UserID = user.ID
+		if log.Actor == "" {
+			log.Actor = user.Email
+		}
+	}
+
+	return log
+}
+
+// ginContext 从上下文中取得请求的 Gin 上下文
+func ginContext(ctx context.Context) *gin.Context {
+	if ctx == nil {
+		return nil
+	}
+
+	if c, ok := ctx.(*gin.Context); ok && c.Request != nil {
+		return c
+	}
+
+	if c, ok := ctx.Value(fsctx.GinCtx).(*gin.Context); ok && c.Request != nil {
+		return c
+	}
+
+	return nil
+}
+
+// run 在后台批量写入审计日志
+func run() {
+	batch := make([]model.AuditLog, 0, batchSize)
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	write := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := model.CreateAuditLogs(batch); err != nil {
+			util.Log().Warning("Failed to write %d audit logs: %s", len(batch), err)
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case log := <-queue:
+			batch = append(batch, log)
+			if len(batch) >= batchSize {
+				write()
+			}
+		case <-ticker.C:
+			write()
+		case done := <-flushes:
+			for drained := false; !drained; {
+				select {
+				case log := <-queue:
+					batch = append(batch, log)
+					if len(batch) >= batchSize {
+						write()
+					}
+				default:
+					drained = true
+				}
+			}
+			write()
+			close(done)
+		}
+	}
+}
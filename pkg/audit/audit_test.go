@@ -0,0 +1,88 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewLog(t *testing.T) {
+	a := assert.New(t)
+	user := &model.User{Email: "alice@example.com"}
+	user.ID = 1
+
+	// 无请求上下文
+	{
+		log := newLog(context.Background(), Event{Action: ActionFileDelete, User: user})
+		a.EqualValues(1, log.UserID)
+		a.Equal("alice@example.com", log.Actor)
+		a.Equal(model.AuditResultSuccess, log.Result)
+		a.Empty(log.IP)
+	}
+
+	// 从 Gin 上下文获取操作者和客户端信息
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request, _ = http.NewRequest("POST", "/", nil)
+	c.Request.RemoteAddr = "192.168.1.1:1234"
+	c.Request.Header.Set("User-Agent", "test-agent")
+	c.Set("user", user)
+	{
+		log := newLog(c, Event{Action: ActionLogin, Actor: "alice", Err: errors.New("wrong password")})
+		a.EqualValues(1, log.UserID)
+		a.Equal("alice", log.Actor)
+		a.Equal("192.168.1.1", log.IP)
+		a.Equal("test-agent", log.UserAgent)
+		a.Equal(model.AuditResultFailure, log.Result)
+		a.Equal("wrong password", log.Detail)
+	}
+
+	// 从文件系统上下文获取
+	{
+		ctx := context.WithValue(context.Background(), fsctx.GinCtx, c)
+		log := newLog(ctx, Event{Action: ActionFileMove, User: &model.User{}})
+		a.EqualValues(0, log.UserID)
+		a.Empty(log.Actor)
+		a.Equal("192.168.1.1", log.IP)
+	}
+}
+
+func TestRecord(t *testing.T) {
+	a := assert.New(t)
+	mockDB := model.DB
+	model.DB, _ = gorm.Open("sqlite", ":memory:")
+	defer func() {
+		model.DB = mockDB
+		atomic.StoreInt32(&enabled, 0)
+	}()
+	model.DB.AutoMigrate(&model.AuditLog{})
+
+	// 未启用时不记录
+	Record(context.Background(), Event{Action: ActionLogin})
+	a.Len(queue, 0)
+
+	atomic.StoreInt32(&enabled, 1)
+	start.Do(func() {
+		atomic.StoreInt32(&started, 1)
+		go run()
+	})
+
+	Record(context.Background(), Event{Action: ActionLogin, Actor: "alice"})
+	Record(context.Background(), Event{Action: ActionFileDelete, Err: errors.New("failed")})
+	Flush()
+
+	var logs []model.AuditLog
+	model.DB.Order("id").Find(&logs)
+	a.Len(logs, 2)
+	a.Equal(ActionLogin, logs[0].Action)
+	a.Equal("alice", logs[0].Actor)
+	a.Equal(model.AuditResultFailure, logs[1].Result)
+}
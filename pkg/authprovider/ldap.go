@@ -0,0 +1,380 @@
+package authprovider
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+	"github.com/go-ldap/ldap/v3"
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	// ldapTimeout LDAP 请求超时时间
+	ldapTimeout = 10 * time.Second
+	// DefaultLDAPIssuer 未设定时 LDAP 外部身份使用的提供方标识
+	DefaultLDAPIssuer = "ldap"
+)
+
+// LDAPConfig LDAP 目录服务设置
+type LDAPConfig struct {
+	Issuer        string // 绑定的外部身份使用的提供方标识，更换服务器地址后仍保持不变
+	URL           string // 服务器地址，如 ldap://ldap.example.com:389 或 ldaps://...
+	StartTLS      bool   // 是否使用 StartTLS
+	SkipTLSVerify bool   // 是否跳过证书校验
+	BindDN        string // 用于查找用户的服务账号，为空时直接以用户身份绑定
+	BindPassword  string // 服务账号密码
+	UserDN        string // 直接绑定时的用户 DN 模板，如 uid={username},ou=people,dc=example,dc=com
+	BaseDN        string // 查找用户的起始 DN
+	UserFilter    string // 查找用户的过滤器，{username} 会被替换为登录名
+	EmailAttr     string // 邮箱属性
+	NameAttr      string // 昵称属性
+	GroupAttr     string // 用户组成员属性
+	GroupMapping  []GroupMapping
+	DefaultGroup  uint // 自动创建用户时的默认用户组
+}
+
+// GroupMapping LDAP 用户组到 Cloudreve 用户组的映射规则
+type GroupMapping struct {
+	Value string `json:"value"` // LDAP 用户组的 DN 或 CN
+	Group uint   `json:"group"` // 对应的用户组ID
+}
+
+// ldapConn LDAP 连接，便于测试时替换
+type ldapConn interface {
+	StartTLS(config *tls.Config) error
+	Bind(username, password string) error
+	Search(request *ldap.SearchRequest) (*ldap.SearchResult, error)
+	Close()
+}
+
+// LDAP 使用 LDAP 目录服务验证用户，验证成功后同步本地用户的邮箱、昵称和用户组
+type LDAP struct {
+	config LDAPConfig
+	dial   func(url string) (ldapConn, error)
+}
+
+// NewLDAPFromSettings 根据站点设置创建 LDAP 验证提供者
+func NewLDAPFromSettings() (*LDAP, error) {
+	settings := model.GetSettingByNames(
+		"ldap_issuer",
+		"ldap_url",
+		"ldap_start_tls",
+		"ldap_skip_tls_verify",
+		"ldap_bind_dn",
+		"ldap_bind_password",
+		"ldap_user_dn",
+		"ldap_base_dn",
+		"ldap_user_filter",
+		"ldap_email_attr",
+		"ldap_name_attr",
+		"ldap_group_attr",
+		"ldap_group_mapping",
+	)
+
+	var mapping []GroupMapping
+	if err := json.Unmarshal([]byte(settings["ldap_group_mapping"]), &mapping); err != nil {
+		return nil, fmt.Errorf("invalid group mapping: %w", err)
+	}
+
+	return NewLDAP(LDAPConfig{
+		Issuer:        settings["ldap_issuer"],
+		URL:           settings["ldap_url"],
+		StartTLS:      model.IsTrueVal(settings["ldap_start_tls"]),
+		SkipTLSVerify: model.IsTrueVal(settings["ldap_skip_tls_verify"]),
+		BindDN:        settings["ldap_bind_dn"],
+		BindPassword:  settings["ldap_bind_password"],
+		UserDN:        settings["ldap_user_dn"],
+		BaseDN:        settings["ldap_base_dn"],
+		UserFilter:    settings["ldap_user_filter"],
+		EmailAttr:     settings["ldap_email_attr"],
+		NameAttr:      settings["ldap_name_attr"],
+		GroupAttr:     settings["ldap_group_attr"],
+		GroupMapping:  mapping,
+		DefaultGroup:  uint(model.GetIntSetting("ldap_default_group", 2)),
+	})
+}
+
+// NewLDAP 创建 LDAP 验证提供者
+func NewLDAP(config LDAPConfig) (*LDAP, error) {
+	if config.URL == "" {
+		return nil, errors.New("LDAP server URL is not set")
+	}
+	if config.BaseDN == "" || config.UserFilter == "" {
+		return nil, errors.New("base DN and user filter are required")
+	}
+	if config.BindDN == "" && config.UserDN == "" {
+		return nil, errors.New("either bind DN or user DN template is required")
+	}
+	if config.Issuer == "" {
+		config.Issuer = DefaultLDAPIssuer
+	}
+	if config.EmailAttr == "" {
+		config.EmailAttr = "mail"
+	}
+
+	return &LDAP{
+		config: config,
+		dial: func(url string) (ldapConn, error) {
+			conn, err := ldap.DialURL(url, ldap.DialWithTLSConfig(&tls.Config{
+				InsecureSkipVerify: config.SkipTLSVerify,
+			}))
+			if err != nil {
+				return nil, err
+			}
+			conn.SetTimeout(ldapTimeout)
+			return conn, nil
+		},
+	}, nil
+}
+
+// Authenticate 以用户的凭据绑定 LDAP，成功后同步并返回本地用户
+func (l *LDAP) Authenticate(ctx context.Context, username, password string) (*model.User, error) {
+	// 空密码会被服务器视为匿名绑定
+	if username == "" || password == "" {
+		return nil, ErrInvalidCredential
+	}
+
+	conn, err := l.dial(l.config.URL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to LDAP server: %w", err)
+	}
+	defer conn.Close()
+
+	if l.config.StartTLS {
+		if err := conn.StartTLS(&tls.Config{InsecureSkipVerify: l.config.SkipTLSVerify}); err != nil {
+			return nil, fmt.Errorf("failed to start TLS: %w", err)
+		}
+	}
+
+	var entry *ldap.Entry
+	if l.config.BindDN != "" {
+		// 使用服务账号查找用户后，再以用户身份绑定
+		if err := conn.Bind(l.config.BindDN, l.config.BindPassword); err != nil {
+			return nil, fmt.Errorf("failed to bind service account: %w", err)
+		}
+
+		if entry, err = l.search(conn, username); err != nil {
+			return nil, err
+		}
+
+		if err := l.bind(conn, entry.DN, password); err != nil {
+			return nil, err
+		}
+	} else {
+		// 直接以用户身份绑定，再查找用户自身的条目
+		userDN := strings.ReplaceAll(l.config.UserDN, "{username}", escapeDN(username))
+		if err := l.bind(conn, userDN, password); err != nil {
+			return nil, err
+		}
+
+		if entry, err = l.search(conn, username); err != nil {
+			return nil, err
+		}
+	}
+
+	return l.sync(entry)
+}
+
+// bind 以用户身份绑定，凭据错误时返回 ErrInvalidCredential
+func (l *LDAP) bind(conn ldapConn, dn, password string) error {
+	if err := conn.Bind(dn, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return ErrInvalidCredential
+		}
+		return fmt.Errorf("failed to bind user: %w", err)
+	}
+	return nil
+}
+
+// search 根据登录名查找唯一的用户条目
+func (l *LDAP) search(conn ldapConn, username string) (*ldap.Entry, error) {
+	attributes := []string{l.config.EmailAttr}
+	if l.config.NameAttr != "" {
+		attributes = append(attributes, l.config.NameAttr)
+	}
+	if l.config.GroupAttr != "" {
+		attributes = append(attributes, l.config.GroupAttr)
+	}
+
+	res, err := conn.Search(ldap.NewSearchRequest(
+		l.config.BaseDN,
+		ldap.ScopeWholeSubtree,
+		ldap.NeverDerefAliases,
+		2,
+		int(ldapTimeout/time.Second),
+		false,
+		strings.ReplaceAll(l.config.UserFilter, "{username}", ldap.EscapeFilter(username)),
+		attributes,
+		nil,
+	))
+	if err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchObject) {
+			return nil, ErrInvalidCredential
+		}
+		return nil, fmt.Errorf("failed to search user: %w", err)
+	}
+
+	switch len(res.Entries) {
+	case 0:
+		return nil, ErrInvalidCredential
+	case 1:
+		return res.Entries[0], nil
+	default:
+		return nil, fmt.Errorf("multiple entries match user %q", username)
+	}
+}
+
+// sync 查找或创建与 LDAP 条目绑定的本地用户，并同步邮箱、昵称和用户组。
+// 目录中的邮箱与未绑定的本地用户相同时拒绝登录，避免能修改目录邮箱的人接管本地账号
+func (l *LDAP) sync(entry *ldap.Entry) (*model.User, error) {
+	email := entry.GetEqualFoldAttributeValue(l.config.EmailAttr)
+	nick := ""
+	if l.config.NameAttr != "" {
+		nick = entry.GetEqualFoldAttributeValue(l.config.NameAttr)
+	}
+
+	var user model.User
+	identity, err := l.identity(entry.DN)
+	if err == nil {
+		if user, err = model.GetUserByID(identity.UserID); err != nil {
+			return nil, fmt.Errorf("failed to find linked user: %w", err)
+		}
+	} else if gorm.IsRecordNotFoundError(err) {
+		if email == "" {
+			return nil, fmt.Errorf("entry %q has no email attribute %q", entry.DN, l.config.EmailAttr)
+		}
+
+		if _, err := model.GetUserByEmail(email); err == nil {
+			return nil, fmt.Errorf("email %q of entry %q is already used by a local user", email, entry.DN)
+		}
+
+		if user, err = l.register(email, nick); err != nil {
+			return nil, err
+		}
+
+		identity = &model.UserIdentity{
+			UserID:  user.ID,
+			Issuer:  l.config.Issuer,
+			Subject: entry.DN,
+			Email:   email,
+		}
+		if err := identity.Create(); err != nil {
+			return nil, fmt.Errorf("failed to link LDAP entry: %w", err)
+		}
+	} else {
+		return nil, fmt.Errorf("failed to find linked identity: %w", err)
+	}
+
+	// 同步用户属性
+	updates := make(map[string]interface{})
+	if nick != "" && nick != user.Nick {
+		updates["nick"] = nick
+	}
+	if email != "" && email != user.Email {
+		if _, err := model.GetUserByEmail(email); err == nil {
+			util.Log().Warning("Email %q of LDAP entry %q is used by another user, skip syncing.", email, entry.DN)
+		} else {
+			updates["email"] = email
+		}
+	}
+	if l.config.GroupAttr != "" && user.ID != 1 {
+		group := l.mapGroup(entry.GetEqualFoldAttributeValues(l.config.GroupAttr))
+		if group != 0 && group != user.GroupID {
+			if _, err := model.GetGroupByID(group); err == nil {
+				updates["group_id"] = group
+			} else {
+				util.Log().Warning("LDAP group mapping refers to a nonexistent group %d.", group)
+			}
+		}
+	}
+
+	if len(updates) > 0 {
+		if err := user.Update(updates); err != nil {
+			return nil, fmt.Errorf("failed to sync user: %w", err)
+		}
+		if user, err = model.GetUserByID(user.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	return &user, nil
+}
+
+// identity 查找与 LDAP 条目绑定的外部身份。旧版本以服务器地址作为提供方标识，
+// 找到此类绑定时将其更新为当前的提供方标识
+func (l *LDAP) identity(dn string) (*model.UserIdentity, error) {
+	identity, err := model.GetUserIdentity(l.config.Issuer, dn)
+	if !gorm.IsRecordNotFoundError(err) {
+		return identity, err
+	}
+
+	legacy, legacyErr := model.GetUserIdentity(l.config.URL, dn)
+	if legacyErr != nil {
+		return identity, err
+	}
+
+	if err := legacy.SetIssuer(l.config.Issuer); err != nil {
+		return nil, fmt.Errorf("failed to upgrade linked identity: %w", err)
+	}
+	return legacy, nil
+}
+
+// register 为 LDAP 用户创建本地用户
+func (l *LDAP) register(email, nick string) (model.User, error) {
+	user := model.NewUser()
+	user.Email = email
+	user.Nick = nick
+	if user.Nick == "" {
+		user.Nick = strings.Split(email, "@")[0]
+	}
+	user.SetPassword(util.RandStringRunes(32))
+	user.Status = model.Active
+	user.GroupID = l.config.DefaultGroup
+
+	if err := model.DB.Create(&user).Error; err != nil {
+		return user, fmt.Errorf("failed to create user: %w", err)
+	}
+
+	return model.GetUserByID(user.ID)
+}
+
+// mapGroup 返回第一条与用户所属组匹配的映射规则对应的用户组，无匹配时返回0。
+// 规则可以是完整的 DN，也可以是 DN 中第一个 RDN 的值
+func (l *LDAP) mapGroup(groups []string) uint {
+	for _, rule := range l.config.GroupMapping {
+		for _, group := range groups {
+			if strings.EqualFold(group, rule.Value) {
+				return rule.Group
+			}
+
+			if dn, err := ldap.ParseDN(group); err == nil && len(dn.RDNs) > 0 && len(dn.RDNs[0].Attributes) > 0 &&
+				strings.EqualFold(dn.RDNs[0].Attributes[0].Value, rule.Value) {
+				return rule.Group
+			}
+		}
+	}
+
+	return 0
+}
+
+// escapeDN 转义 DN 中属性值的特殊字符
+func escapeDN(value string) string {
+	var builder strings.Builder
+	for i, r := range value {
+		switch {
+		case strings.ContainsRune(`,+"\<>;=`, r),
+			i == 0 && (r == ' ' || r == '#'),
+			i == len(value)-1 && r == ' ':
+			builder.WriteRune('\\')
+		}
+		builder.WriteRune(r)
+	}
+	return builder.String()
+}
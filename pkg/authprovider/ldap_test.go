@@ -0,0 +1,261 @@
+package authprovider
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"testing"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/go-ldap/ldap/v3"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeConn is an in-memory directory holding user DN -> password.
+type fakeConn struct {
+	passwords map[string]string
+	entries   []*ldap.Entry
+	binds     []string
+	filter    string
+}
+
+func (c *fakeConn) StartTLS(config *tls.Config) error { return nil }
+
+func (c *fakeConn) Bind(username, password string) error {
+	c.binds = append(c.binds, username)
+	if expected, ok := c.passwords[username]; ok && expected == password {
+		return nil
+	}
+	return ldap.NewError(ldap.LDAPResultInvalidCredentials, errors.New("invalid credentials"))
+}
+
+func (c *fakeConn) Search(request *ldap.SearchRequest) (*ldap.SearchResult, error) {
+	c.filter = request.Filter
+	return &ldap.SearchResult{Entries: c.entries}, nil
+}
+
+func (c *fakeConn) Close() {}
+
+func newTestLDAP(t *testing.T, config LDAPConfig, conn *fakeConn) *LDAP {
+	l, err := NewLDAP(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.dial = func(url string) (ldapConn, error) { return conn, nil }
+	return l
+}
+
+func TestNewLDAP(t *testing.T) {
+	a := assert.New(t)
+
+	_, err := NewLDAP(LDAPConfig{})
+	a.Error(err)
+
+	_, err = NewLDAP(LDAPConfig{URL: "ldap://localhost", BaseDN: "dc=example", UserFilter: "(uid={username})"})
+	a.Error(err)
+
+	l, err := NewLDAP(LDAPConfig{URL: "ldap://localhost", BaseDN: "dc=example", UserFilter: "(uid={username})", BindDN: "cn=admin"})
+	a.NoError(err)
+	a.Equal("mail", l.config.EmailAttr)
+	a.Equal(DefaultLDAPIssuer, l.config.Issuer)
+}
+
+func TestLDAP_Authenticate(t *testing.T) {
+	a := assert.New(t)
+	config := LDAPConfig{
+		URL:          "ldap://localhost",
+		BaseDN:       "dc=example",
+		UserFilter:   "(uid={username})",
+		BindDN:       "cn=admin",
+		BindPassword: "admin",
+	}
+
+	// 空密码
+	{
+		l := newTestLDAP(t, config, &fakeConn{})
+		_, err := l.Authenticate(context.Background(), "alice", "")
+		a.ErrorIs(err, ErrInvalidCredential)
+	}
+
+	// 服务账号密码错误
+	{
+		l := newTestLDAP(t, config, &fakeConn{})
+		_, err := l.Authenticate(context.Background(), "alice", "secret")
+		a.Error(err)
+		a.NotErrorIs(err, ErrInvalidCredential)
+	}
+
+	// 用户不存在
+	{
+		conn := &fakeConn{passwords: map[string]string{"cn=admin": "admin"}}
+		l := newTestLDAP(t, config, conn)
+		_, err := l.Authenticate(context.Background(), "a*)(uid=*", "secret")
+		a.ErrorIs(err, ErrInvalidCredential)
+		a.Equal(`(uid=a\2a\29\28uid=\2a)`, conn.filter)
+	}
+
+	// 用户密码错误
+	{
+		conn := &fakeConn{
+			passwords: map[string]string{"cn=admin": "admin", "uid=alice,dc=example": "secret"},
+			entries:   []*ldap.Entry{ldap.NewEntry("uid=alice,dc=example", nil)},
+		}
+		l := newTestLDAP(t, config, conn)
+		_, err := l.Authenticate(context.Background(), "alice", "wrong")
+		a.ErrorIs(err, ErrInvalidCredential)
+		a.Equal([]string{"cn=admin", "uid=alice,dc=example"}, conn.binds)
+	}
+
+	// 直接绑定，用户密码错误
+	{
+		config := config
+		config.BindDN = ""
+		config.UserDN = "uid={username},dc=example"
+		conn := &fakeConn{}
+		l := newTestLDAP(t, config, conn)
+		_, err := l.Authenticate(context.Background(), "a,b", "wrong")
+		a.ErrorIs(err, ErrInvalidCredential)
+		a.Equal([]string{`uid=a\,b,dc=example`}, conn.binds)
+	}
+
+	// 多个用户匹配
+	{
+		conn := &fakeConn{
+			passwords: map[string]string{"cn=admin": "admin"},
+			entries:   []*ldap.Entry{ldap.NewEntry("uid=a,dc=example", nil), ldap.NewEntry("uid=b,dc=example", nil)},
+		}
+		l := newTestLDAP(t, config, conn)
+		_, err := l.Authenticate(context.Background(), "alice", "secret")
+		a.Error(err)
+		a.NotErrorIs(err, ErrInvalidCredential)
+	}
+}
+
+func TestLDAP_mapGroup(t *testing.T) {
+	a := assert.New(t)
+	l := &LDAP{config: LDAPConfig{GroupMapping: []GroupMapping{
+		{Value: "cn=admins,ou=groups,dc=example", Group: 1},
+		{Value: "staff", Group: 3},
+	}}}
+
+	a.EqualValues(0, l.mapGroup(nil))
+	a.EqualValues(0, l.mapGroup([]string{"cn=guests,ou=groups,dc=example"}))
+	a.EqualValues(3, l.mapGroup([]string{"CN=Staff,OU=Groups,DC=example"}))
+	a.EqualValues(1, l.mapGroup([]string{"cn=staff,ou=groups,dc=example", "CN=admins,ou=groups,dc=example"}))
+}
+
+func TestEscapeDN(t *testing.T) {
+	a := assert.New(t)
+	a.Equal("alice", escapeDN("alice"))
+	a.Equal(`\#a\,b\+c\=d\ `, escapeDN("#a,b+c=d "))
+	a.Equal(`\ a`, escapeDN(" a"))
+}
+
+type staticProvider struct {
+	user *model.User
+	err  error
+}
+
+func (p staticProvider) Authenticate(ctx context.Context, username, password string) (*model.User, error) {
+	return p.user, p.err
+}
+
+func TestChain_Authenticate(t *testing.T) {
+	a := assert.New(t)
+	user := &model.User{Email: "alice@example.com"}
+
+	res, err := Chain{
+		staticProvider{err: ErrInvalidCredential},
+		staticProvider{err: errors.New("server down")},
+		staticProvider{user: user},
+	}.Authenticate(context.Background(), "alice", "secret")
+	a.NoError(err)
+	a.Equal(user, res)
+
+	_, err = Chain{
+		staticProvider{err: errors.New("server down")},
+		staticProvider{err: ErrInvalidCredential},
+	}.Authenticate(context.Background(), "alice", "secret")
+	a.ErrorIs(err, ErrInvalidCredential)
+}
+
+func TestWebDAV(t *testing.T) {
+	a := assert.New(t)
+	defer func() {
+		Directory, DirectoryWebDAV, DirectoryWebDAVAccount = nil, false, model.Webdav{}
+	}()
+
+	// 未启用目录服务
+	Directory, DirectoryWebDAV = nil, true
+	provider, account := WebDAV()
+	a.Nil(provider)
+	a.Nil(account)
+
+	// 未允许 WebDAV 使用目录服务密码
+	Directory, DirectoryWebDAV = staticProvider{}, false
+	provider, account = WebDAV()
+	a.Nil(provider)
+	a.Nil(account)
+
+	// 使用管理员设定的账户权限，且修改不影响全局设定
+	DirectoryWebDAV = true
+	DirectoryWebDAVAccount = model.Webdav{Name: "Directory", Root: "/", Readonly: true, IPAllowlist: "10.0.0.0/8"}
+	provider, account = WebDAV()
+	a.NotNil(provider)
+	a.True(account.Readonly)
+	a.False(account.UseProxy)
+	a.True(account.AllowIP("10.1.2.3"))
+	a.False(account.AllowIP("192.168.1.1"))
+	account.Readonly = false
+	a.True(DirectoryWebDAVAccount.Readonly)
+}
+
+func TestLDAP_sync(t *testing.T) {
+	a := assert.New(t)
+	mockDB := model.DB
+	model.DB, _ = gorm.Open("sqlite", ":memory:")
+	defer func() {
+		model.DB = mockDB
+	}()
+	model.DB.AutoMigrate(&model.User{}, &model.UserIdentity{})
+
+	config := LDAPConfig{
+		URL:        "ldap://localhost",
+		BaseDN:     "dc=example",
+		UserFilter: "(uid={username})",
+		BindDN:     "cn=admin",
+		EmailAttr:  "mail",
+	}
+	l := newTestLDAP(t, config, &fakeConn{})
+	admin := model.User{Email: "admin@example.com"}
+	a.NoError(model.DB.Create(&admin).Error)
+
+	// 邮箱与未绑定的本地用户相同
+	entry := ldap.NewEntry("uid=admin,dc=example", map[string][]string{"mail": {"admin@example.com"}})
+	_, err := l.sync(entry)
+	a.ErrorContains(err, "already used by a local user")
+	var count int
+	model.DB.Model(&model.UserIdentity{}).Count(&count)
+	a.Equal(0, count)
+
+	// 以服务器地址为提供方标识的旧绑定被更新
+	user := model.User{Email: "bob@example.com"}
+	a.NoError(model.DB.Create(&user).Error)
+	a.NoError((&model.UserIdentity{UserID: user.ID, Issuer: "ldap://localhost", Subject: "uid=bob,dc=example"}).Create())
+	entry = ldap.NewEntry("uid=bob,dc=example", map[string][]string{"mail": {"bob@example.com"}})
+	res, err := l.sync(entry)
+	a.NoError(err)
+	a.Equal(user.ID, res.ID)
+	identity, err := model.GetUserIdentity(DefaultLDAPIssuer, "uid=bob,dc=example")
+	a.NoError(err)
+	a.Equal(user.ID, identity.UserID)
+
+	// 更换服务器地址后仍找到绑定
+	l.config.URL = "ldaps://ldap.example.com"
+	res, err = l.sync(entry)
+	a.NoError(err)
+	a.Equal(user.ID, res.ID)
+	model.DB.Model(&model.UserIdentity{}).Count(&count)
+	a.Equal(1, count)
+}
@@ -0,0 +1,168 @@
+package authprovider
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sync"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+)
+
+// Provider 用户名密码验证提供者
+type Provider interface {
+	// Authenticate 验证用户名和密码，返回对应的本地用户。
+	// 凭据不正确或用户不受此提供者管理时返回 ErrInvalidCredential
+	Authenticate(ctx context.Context, username, password string) (*model.User, error)
+}
+
+var (
+	// ErrInvalidCredential 用户名或密码错误
+	ErrInvalidCredential = errors.New("invalid username or password")
+
+	// Directory 当前启用的目录服务，未启用时为 nil
+	Directory Provider
+	// DirectoryWebDAV 是否允许 WebDAV 客户端使用目录服务密码登录
+	DirectoryWebDAV bool
+	// DirectoryWebDAVAccount 使用目录服务密码登录的 WebDAV 客户端适用的账户权限
+	DirectoryWebDAVAccount model.Webdav
+	// DirectoryMu 保护 Directory、DirectoryWebDAV 和 DirectoryWebDAVAccount
+	DirectoryMu sync.RWMutex
+)
+
+// webDAVCacheTTL WebDAV 目录服务验证结果的缓存时间（秒）
+const webDAVCacheTTL = 60
+
+// Init 根据设置初始化目录服务验证提供者
+func Init() {
+	settings := model.GetSettingByNames(
+		"ldap_enabled",
+		"ldap_webdav",
+		"ldap_webdav_readonly",
+		"ldap_webdav_ip_allowlist",
+	)
+
+	var directory Provider
+	if model.IsTrueVal(settings["ldap_enabled"]) {
+		ldap, err := NewLDAPFromSettings()
+		if err != nil {
+			util.Log().Error("Failed to initialize LDAP authentication: %s", err)
+		} else {
+			directory = ldap
+		}
+	}
+
+	DirectoryMu.Lock()
+	Directory = directory
+	DirectoryWebDAV = directory != nil && model.IsTrueVal(settings["ldap_webdav"])
+	DirectoryWebDAVAccount = model.Webdav{
+		Name:        "Directory",
+		Root:        "/",
+		Readonly:    model.IsTrueVal(settings["ldap_webdav_readonly"]),
+		IPAllowlist: settings["ldap_webdav_ip_allowlist"],
+	}
+	DirectoryMu.Unlock()
+}
+
+// Login 返回网页登录使用的验证提供者，优先使用目录服务，失败后使用本地密码
+func Login() Provider {
+	DirectoryMu.RLock()
+	defer DirectoryMu.RUnlock()
+
+	if Directory == nil {
+		return Local{}
+	}
+	return Chain{Directory, Local{}}
+}
+
+// WebDAV 返回 WebDAV 客户端使用目录服务密码登录时的验证提供者及适用的账户权限，
+// 未允许时返回 nil
+func WebDAV() (Provider, *model.Webdav) {
+	DirectoryMu.RLock()
+	defer DirectoryMu.RUnlock()
+
+	if Directory == nil || !DirectoryWebDAV {
+		return nil, nil
+	}
+
+	account := DirectoryWebDAVAccount
+	return &cached{provider: Directory, prefix: "webdav_directory_", ttl: webDAVCacheTTL}, &account
+}
+
+// Local 使用本地密码验证
+type Local struct{}
+
+// Authenticate 验证本地用户的邮箱和密码
+func (Local) Authenticate(ctx context.Context, username, password string) (*model.User, error) {
+	user, err := model.GetUserByEmail(username)
+	if err != nil {
+		return nil, ErrInvalidCredential
+	}
+
+	if ok, _ := user.CheckPassword(password); !ok {
+		return nil, ErrInvalidCredential
+	}
+
+	return &user, nil
+}
+
+// Chain 依次尝试多个验证提供者，返回第一个验证成功的结果
+type Chain []Provider
+
+// Authenticate 依次尝试验证，除凭据错误外的其他错误会被记录
+func (chain Chain) Authenticate(ctx context.Context, username, password string) (*model.User, error) {
+	for _, provider := range chain {
+		user, err := provider.Authenticate(ctx, username, password)
+		if err == nil {
+			return user, nil
+		}
+
+		if !errors.Is(err, ErrInvalidCredential) {
+			util.Log().Warning("Authentication provider failed: %s", err)
+		}
+	}
+
+	return nil, ErrInvalidCredential
+}
+
+// cacheKey 计算缓存键的密钥，仅在当前进程内有效
+var cacheKey = func() []byte {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(err)
+	}
+	return key
+}()
+
+// cached 将验证成功的结果短暂缓存，避免 WebDAV 的每个请求都访问目录服务
+type cached struct {
+	provider Provider
+	prefix   string
+	ttl      int
+}
+
+// Authenticate 验证用户名和密码，命中缓存时直接返回用户
+func (c *cached) Authenticate(ctx context.Context, username, password string) (*model.User, error) {
+	mac := hmac.New(sha256.New, cacheKey)
+	mac.Write([]byte(username + "\x00" + password))
+	key := c.prefix + hex.EncodeToString(mac.Sum(nil))
+
+	if uid, ok := cache.Get(key); ok {
+		if user, err := model.GetActiveUserByID(uid); err == nil {
+			return &user, nil
+		}
+	}
+
+	user, err := c.provider.Authenticate(ctx, username, password)
+	if err != nil {
+		return nil, err
+	}
+
+	_ = cache.Set(key, user.ID, c.ttl)
+	return user, nil
+}
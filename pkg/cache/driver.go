@@ -3,6 +3,7 @@ package cache
 import (
 	"encoding/gob"
 	"github.com/cloudreve/Cloudreve/v3/pkg/conf"
+	"github.com/cloudreve/Cloudreve/v3/pkg/metrics"
 	"github.com/cloudreve/Cloudreve/v3/pkg/util"
 	"github.com/gin-gonic/gin"
 )
@@ -50,6 +51,9 @@ type Driver interface {
 	// 取值，并返回是否成功
 	Get(key string) (interface{}, bool)
 
+	// 原子地将整数计数器增加 delta 并返回新值，计数器不存在时从0开始并以 ttl 为过期时间
+	IncrBy(key string, delta int, ttl int) (int, error)
+
 	// 批量取值，返回成功取值的map即不存在的值
 	Gets(keys []string, prefix string) (map[string]interface{}, []string)
 
@@ -73,7 +77,14 @@ func Set(key string, value interface{}, ttl int) error {
 
 // Get 获取缓存值
 func Get(key string) (interface{}, bool) {
-	return Store.Get(key)
+	value, ok := Store.Get(key)
+	metrics.ObserveCache(key, ok)
+	return value, ok
+}
+
+// IncrBy 原子地增加计数器
+func IncrBy(key string, delta int, ttl int) (int, error) {
+	return Store.IncrBy(key, delta, ttl)
 }
 
 // Deletes 删除值
@@ -88,6 +99,10 @@ func GetSettings(keys []string, prefix string) (map[string]string, []string) {
 	res := make(map[string]string, len(raw))
 	for k, v := range raw {
 		res[k] = v.(string)
+		metrics.ObserveCache(prefix+k, true)
+	}
+	for _, k := range miss {
+		metrics.ObserveCache(prefix+k, false)
 	}
 
 	return res, miss
@@ -13,6 +13,8 @@ import (
 // MemoStore 内存存储驱动
 type MemoStore struct {
 	Store *sync.Map
+	// incrMu 保证计数器的读取和写入是原子的
+	incrMu sync.Mutex
 }
 
 // item 存储的对象
@@ -84,6 +86,28 @@ func (store *MemoStore) Get(key string) (interface{}, bool) {
 	return getValue(store.Store.Load(key))
 }
 
+// IncrBy 原子地增加计数器，已存在的计数器保持原有的过期时间
+func (store *MemoStore) IncrBy(key string, delta int, ttl int) (int, error) {
+	store.incrMu.Lock()
+	defer store.incrMu.Unlock()
+
+	raw, ok := store.Store.Load(key)
+	if value, ok := getValue(raw, ok); ok {
+		current, isInt := value.(int)
+		if !isInt {
+			return 0, fmt.Errorf("value of %q is not an integer", key)
+		}
+
+		item := raw.(itemWithTTL)
+		item.Value = current + delta
+		store.Store.Store(key, item)
+		return current + delta, nil
+	}
+
+	store.Store.Store(key, newItem(delta, ttl))
+	return delta, nil
+}
+
 // Gets 批量取值
 func (store *MemoStore) Gets(keys []string, prefix string) (map[string]interface{}, []string) {
 	var res = make(map[string]interface{})
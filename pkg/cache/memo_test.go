@@ -27,6 +27,41 @@ func TestMemoStore_Set(t *testing.T) {
 	asserts.Equal("vAL", val.(itemWithTTL).Value)
 }
 
+func TestMemoStore_IncrBy(t *testing.T) {
+	asserts := assert.New(t)
+	store := NewMemoStore()
+
+	// 新计数器
+	val, err := store.IncrBy("counter", 1, 10)
+	asserts.NoError(err)
+	asserts.Equal(1, val)
+	val, err = store.IncrBy("counter", 2, 100)
+	asserts.NoError(err)
+	asserts.Equal(3, val)
+	item, _ := store.Store.Load("counter")
+	asserts.InDelta(time.Now().Unix()+10, item.(itemWithTTL).Expires, 1)
+
+	// 并发增加
+	done := make(chan struct{})
+	for i := 0; i < 10; i++ {
+		go func() {
+			store.IncrBy("counter", 1, 10)
+			done <- struct{}{}
+		}()
+	}
+	for i := 0; i < 10; i++ {
+		<-done
+	}
+	got, ok := store.Get("counter")
+	asserts.True(ok)
+	asserts.Equal(13, got)
+
+	// 非整数值
+	store.Set("str", "value", 0)
+	_, err = store.IncrBy("str", 1, 0)
+	asserts.Error(err)
+}
+
 func TestMemoStore_Get(t *testing.T) {
 	asserts := assert.New(t)
 	store := NewMemoStore()
@@ -117,6 +117,10 @@ func (store *RedisStore) Get(key string) (interface{}, bool) {
 
 	finalValue, err := deserializer(v)
 	if err != nil {
+		// 由 IncrBy 写入的计数器以整数文本存储
+		if counter, err := strconv.Atoi(string(v)); err == nil {
+			return counter, true
+		}
 		return nil, false
 	}
 
@@ -124,6 +128,26 @@ func (store *RedisStore) Get(key string) (interface{}, bool) {
 
 }
 
+// incrScript 增加计数器，仅在计数器没有过期时间时设置过期时间
+var incrScript = redis.NewScript(1, `
+local value = redis.call("INCRBY", KEYS[1], ARGV[1])
+if tonumber(ARGV[2]) > 0 and redis.call("TTL", KEYS[1]) == -1 then
+	redis.call("EXPIRE", KEYS[1], ARGV[2])
+end
+return value
+`)
+
+// IncrBy 原子地增加计数器
+func (store *RedisStore) IncrBy(key string, delta int, ttl int) (int, error) {
+	rc := store.pool.Get()
+	defer rc.Close()
+	if rc.Err() != nil {
+		return 0, rc.Err()
+	}
+
+	return redis.Int(incrScript.Do(rc, key, delta, ttl))
+}
+
 // Gets 批量取值
 func (store *RedisStore) Gets(keys []string, prefix string) (map[string]interface{}, []string) {
 	rc := store.pool.Get()
@@ -154,6 +154,44 @@ func TestRedisStore_Get(t *testing.T) {
 	}
 }
 
+func TestRedisStore_IncrBy(t *testing.T) {
+	asserts := assert.New(t)
+	conn := redigomock.NewConn()
+	pool := &redis.Pool{
+		Dial:    func() (redis.Conn, error) { return conn, nil },
+		MaxIdle: 10,
+	}
+	store := &RedisStore{pool: pool}
+
+	// 正常情况
+	{
+		cmd := conn.GenericCommand("EVALSHA").Expect(int64(3))
+		val, err := store.IncrBy("counter", 1, 10)
+		asserts.Equal(1, conn.Stats(cmd))
+		asserts.NoError(err)
+		asserts.Equal(3, val)
+	}
+
+	// 计数器以整数文本读取
+	{
+		conn.Clear()
+		conn.Command("GET", "counter").Expect([]byte("3"))
+		val, ok := store.Get("counter")
+		asserts.True(ok)
+		asserts.Equal(3, val)
+	}
+
+	// 获取连接失败
+	{
+		store.pool = &redis.Pool{
+			Dial:    func() (redis.Conn, error) { return nil, errors.New("error") },
+			MaxIdle: 10,
+		}
+		_, err := store.IncrBy("counter", 1, 10)
+		asserts.Error(err)
+	}
+}
+
 func TestRedisStore_Gets(t *testing.T) {
 	asserts := assert.New(t)
 	conn := redigomock.NewConn()
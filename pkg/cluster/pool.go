@@ -71,6 +71,14 @@ func (pool *NodePool) buildIndexMap() {
 	pool.lock.Unlock()
 }
 
+// Stats 返回可用及不可用的节点数量
+func (pool *NodePool) Stats() (active, inactive int) {
+	pool.lock.RLock()
+	defer pool.lock.RUnlock()
+
+	return len(pool.active), len(pool.inactive)
+}
+
 func (pool *NodePool) GetNodeByID(id uint) Node {
 	pool.lock.RLock()
 	defer pool.lock.RUnlock()
@@ -29,6 +29,9 @@ type system struct {
 	HashIDSalt    string
 	GracePeriod   int    `validate:"gte=0"`
 	ProxyHeader   string `validate:"required_with=Listen"`
+	// TrustedProxies 可信反向代理的 IP 或 CIDR，仅来自这些地址的请求会使用 ProxyHeader 中的客户端 IP
+	TrustedProxies []string
+	LogFormat      string `validate:"eq=text|eq=json"`
 }
 
 type ssl struct {
@@ -69,6 +72,14 @@ type cors struct {
 	Secure           bool
 }
 
+// metrics 监控指标配置
+type metrics struct {
+	Enabled bool
+	// Listen 非空时在独立地址上提供监控指标，否则挂载于主服务的 /metrics
+	Listen string
+	Token  string
+}
+
 var cfg *ini.File
 
 const defaultConf = `[System]
@@ -116,6 +127,7 @@ func Init(path string) {
 		"Redis":      RedisConfig,
 		"CORS":       CORSConfig,
 		"Slave":      SlaveConfig,
+		"Metrics":    MetricsConfig,
 	}
 	for sectionName, sectionStruct := range sections {
 		err = mapSection(sectionName, sectionStruct)
@@ -129,12 +141,13 @@ func Init(path string) {
 		OptionOverwrite[key.Name()] = key.Value()
 	}
 
-	// 重设log等级
+	// 重设log等级及格式
+	util.LogFormat = SystemConfig.LogFormat
 	if !SystemConfig.Debug {
 		util.Level = util.LevelInformational
-		util.GloablLogger = nil
-		util.Log()
 	}
+	util.GloablLogger = nil
+	util.Log()
 
 }
 
@@ -23,6 +23,7 @@ var SystemConfig = &system{
 	Mode:        "master",
 	Listen:      ":5212",
 	ProxyHeader: "X-Forwarded-For",
+	LogFormat:   "text",
 }
 
 // CORSConfig 跨域配置
@@ -42,6 +43,11 @@ var SlaveConfig = &slave{
 	SignatureTTL:    60,
 }
 
+// MetricsConfig 监控指标配置
+var MetricsConfig = &metrics{
+	Enabled: false,
+}
+
 var SSLConfig = &ssl{
 	Listen:   ":443",
 	CertPath: "",
@@ -22,6 +22,11 @@ func garbageCollect() {
 		collectCache(store)
 	}
 
+	// 清理过期的 WebDAV 与 WOPI 锁
+	if err := model.DeleteExpiredLocks(); err != nil {
+		util.Log().Warning("Failed to delete expired locks: %s", err)
+	}
+
 	util.Log().Info("Crontab job \"cron_garbage_collect\" complete.")
 }
 
@@ -97,3 +102,123 @@ func uploadSessionCollect() {
 
 	util.Log().Info("Crontab job \"cron_recycle_upload_session\" complete.")
 }
+
+func trashCollect() {
+	trashes, err := model.GetExpiredTrash()
+	if err != nil {
+		util.Log().Warning("Failed to list expired trash: %s", err)
+		return
+	}
+
+	// 将过期的回收站对象按照用户分组
+	userToTrashes := make(map[uint][]model.Trash)
+	for _, trash := range trashes {
+		userToTrashes[trash.UserID] = append(userToTrashes[trash.UserID], trash)
+	}
+
+	for uid, items := range userToTrashes {
+		user, err := model.GetUserByID(uid)
+		if err != nil {
+			util.Log().Warning("Owner of the trash cannot be found: %s", err)
+			continue
+		}
+
+		fs, err := filesystem.NewFileSystem(&user)
+		if err != nil {
+			util.Log().Warning("Failed to initialize filesystem: %s", err)
+			continue
+		}
+
+		if err = fs.PurgeTrash(context.Background(), items); err != nil {
+			util.Log().Warning("Failed to purge expired trash: %s", err)
+		}
+
+		fs.Recycle()
+	}
+
+	util.Log().Info("Crontab job \"cron_purge_trash\" complete.")
+}
+
+func fileVersionCollect() {
+	versions, err := model.GetExpiredFileVersions()
+	if err != nil {
+		util.Log().Warning("Failed to list expired file versions: %s", err)
+		return
+	}
+
+	// 将过期的历史版本按照用户分组
+	userToVersions := make(map[uint][]model.FileVersion)
+	for _, version := range versions {
+		userToVersions[version.UserID] = append(userToVersions[version.UserID], version)
+	}
+
+	for uid, items := range userToVersions {
+		user, err := model.GetUserByID(uid)
+		if err != nil {
+			util.Log().Warning("Owner of the file version cannot be found: %s", err)
+			continue
+		}
+
+		fs, err := filesystem.NewFileSystem(&user)
+		if err != nil {
+			util.Log().Warning("Failed to initialize filesystem: %s", err)
+			continue
+		}
+
+		if err = fs.DeleteVersions(context.Background(), items); err != nil {
+			util.Log().Warning("Failed to delete expired file versions: %s", err)
+		}
+
+		fs.Recycle()
+	}
+
+	util.Log().Info("Crontab job \"cron_purge_file_version\" complete.")
+}
+
+func auditLogCollect() {
+	// 保留天数为0时永久保留
+	days := model.GetIntSetting("audit_retention_days", 180)
+	if days <= 0 {
+		return
+	}
+
+	deleted, err := model.DeleteAuditLogsBefore(time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		util.Log().Warning("Failed to purge expired audit logs: %s", err)
+		return
+	}
+
+	util.Log().Info("Crontab job \"cron_purge_audit_log\" complete, %d audit logs purged.", deleted)
+}
+
+func shareAccessCollect() {
+	// 保留天数为0时永久保留
+	days := model.GetIntSetting("share_access_retention_days", 90)
+	if days <= 0 {
+		return
+	}
+
+	deleted, err := model.DeleteShareAccessesBefore(time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		util.Log().Warning("Failed to purge expired share access records: %s", err)
+		return
+	}
+
+	util.Log().Info("Crontab job \"cron_purge_share_access\" complete, %d share access records purged.", deleted)
+}
+
+func webhookDeliveryCollect() {
+	// 保留天数为0时永久保留
+	days := model.GetIntSetting("webhook_delivery_retention_days", 30)
+	if days <= 0 {
+		return
+	}
+
+	deleted, err := model.DeleteWebhookDeliveriesBefore(time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		util.Log().Warning("Failed to purge expired webhook deliveries: %s", err)
+		return
+	}
+
+	util.Log().Info("Crontab job \"cron_purge_webhook_delivery\" complete, %d webhook deliveries purged.", deleted)
+}
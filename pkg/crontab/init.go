@@ -24,6 +24,11 @@ func Init() {
 	options := model.GetSettingByNames(
 		"cron_garbage_collect",
 		"cron_recycle_upload_session",
+		"cron_purge_trash",
+		"cron_purge_file_version",
+		"cron_purge_audit_log",
+		"cron_purge_share_access",
+		"cron_purge_webhook_delivery",
 	)
 	Cron := cron.New()
 	for k, v := range options {
@@ -33,6 +38,16 @@ func Init() {
 			handler = garbageCollect
 		case "cron_recycle_upload_session":
 			handler = uploadSessionCollect
+		case "cron_purge_trash":
+			handler = trashCollect
+		case "cron_purge_file_version":
+			handler = fileVersionCollect
+		case "cron_purge_audit_log":
+			handler = auditLogCollect
+		case "cron_purge_share_access":
+			handler = shareAccessCollect
+		case "cron_purge_webhook_delivery":
+			handler = webhookDeliveryCollect
 		default:
 			util.Log().Warning("Unknown crontab job type %q, skipping...", k)
 			continue
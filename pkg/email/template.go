@@ -2,6 +2,7 @@ package email
 
 import (
 	"fmt"
+	"strconv"
 
 	model "github.com/cloudreve/Cloudreve/v3/models"
 	"github.com/cloudreve/Cloudreve/v3/pkg/util"
@@ -21,6 +22,38 @@ func NewActivationEmail(userName, activateURL string) (string, string) {
 		util.Replace(replace, options["mail_activation_template"])
 }
 
+// NewShareUploadEmail 新建收集文件分享收到上传的通知邮件
+func NewShareUploadEmail(userName, shareName, fileName, shareURL string) (string, string) {
+	options := model.GetSettingByNames("siteName", "siteURL", "siteTitle", "mail_share_upload_template")
+	replace := map[string]string{
+		"{siteTitle}":    options["siteName"],
+		"{userName}":     userName,
+		"{shareName}":    shareName,
+		"{fileName}":     fileName,
+		"{shareUrl}":     shareURL,
+		"{siteUrl}":      options["siteURL"],
+		"{siteSecTitle}": options["siteTitle"],
+	}
+	return fmt.Sprintf("【%s】%s 收到了新文件", options["siteName"], shareName),
+		util.Replace(replace, options["mail_share_upload_template"])
+}
+
+// NewShareAttackEmail 新建分享密码被频繁尝试的通知邮件
+func NewShareAttackEmail(userName, shareName, ip string, attempts int) (string, string) {
+	options := model.GetSettingByNames("siteName", "siteURL", "siteTitle", "mail_share_attack_template")
+	replace := map[string]string{
+		"{siteTitle}":    options["siteName"],
+		"{userName}":     userName,
+		"{shareName}":    shareName,
+		"{ip}":           ip,
+		"{attempts}":     strconv.Itoa(attempts),
+		"{siteUrl}":      options["siteURL"],
+		"{siteSecTitle}": options["siteTitle"],
+	}
+	return fmt.Sprintf("【%s】分享 %s 的密码被频繁尝试", options["siteName"], shareName),
+		util.Replace(replace, options["mail_share_attack_template"])
+}
+
 // NewResetEmail 新建重设密码邮件
 func NewResetEmail(userName, resetURL string) (string, string) {
 	options := model.GetSettingByNames("siteName", "siteURL", "siteTitle", "mail_reset_pwd_template")
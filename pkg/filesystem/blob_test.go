@@ -0,0 +1,104 @@
+package filesystem
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
+	"github.com/cloudreve/Cloudreve/v3/pkg/conf"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileSystem_DeduplicationSQLite(t *testing.T) {
+	asserts := assert.New(t)
+	mockDB := model.DB
+	conf.DatabaseConfig.Type = "sqlite"
+	model.DB, _ = gorm.Open("sqlite", ":memory:")
+	defer func() {
+		model.DB = mockDB
+		conf.DatabaseConfig.Type = "mysql"
+	}()
+	model.DB.AutoMigrate(&model.User{}, &model.Group{}, &model.Policy{}, &model.Folder{}, &model.File{},
+		&model.Share{}, &model.FileVersion{}, &model.Blob{})
+
+	dir := t.TempDir()
+	policy := &model.Policy{
+		Type:         "local",
+		Name:         "TestDedup",
+		DirNameRule:  filepath.ToSlash(dir),
+		AutoRename:   true,
+		FileNameRule: "{randomkey16}_{originname}",
+	}
+	asserts.NoError(model.DB.Create(policy).Error)
+	cache.Deletes([]string{strconv.Itoa(int(policy.ID))}, "policy_")
+
+	newFS := func(email string) *FileSystem {
+		user := &model.User{Email: email}
+		user.Group.MaxStorage = 1024
+		asserts.NoError(model.DB.Create(user).Error)
+		_, err := user.Root()
+		asserts.NoError(err)
+		user.Policy = *policy
+		fs := &FileSystem{User: user}
+		return fs
+	}
+	upload := func(fs *FileSystem, content string) *model.File {
+		file := &fsctx.FileStream{
+			File:        ioutil.NopCloser(strings.NewReader(content)),
+			Size:        uint64(len(content)),
+			Name:        "1.txt",
+			VirtualPath: "/",
+		}
+		fs.CleanHooks("")
+		asserts.NoError(fs.UploadFromStream(context.Background(), file, true))
+		return file.Model.(*model.File)
+	}
+	countObjects := func() int {
+		entries, _ := os.ReadDir(dir)
+		return len(entries)
+	}
+
+	// 不同用户上传相同内容，只保存一份
+	fs1 := newFS("1@cloudreve.org")
+	fs2 := newFS("2@cloudreve.org")
+	file1 := upload(fs1, "content")
+	file2 := upload(fs2, "content")
+	asserts.Equal(file1.SourceName, file2.SourceName)
+	asserts.Equal(file1.BlobID, file2.BlobID)
+	asserts.Equal(1, countObjects())
+	asserts.EqualValues(7, fs2.User.Storage)
+
+	// 复制文件增加引用
+	_, err := fs1.CreateDirectory(context.Background(), "/copied")
+	asserts.NoError(err)
+	fs1.CleanTargets()
+	asserts.NoError(fs1.Copy(context.Background(), []uint{}, []uint{file1.ID}, "/", "/copied"))
+	blobs, err := model.GetBlobsByIDs([]uint{file1.BlobID})
+	asserts.NoError(err)
+	asserts.Equal(3, blobs[file1.BlobID].RefCount)
+
+	// 仍有引用时不删除源文件
+	fs1.CleanTargets()
+	asserts.NoError(fs1.Delete(context.Background(), []uint{}, []uint{file1.ID}, false, false))
+	fs2.CleanTargets()
+	asserts.NoError(fs2.Delete(context.Background(), []uint{}, []uint{file2.ID}, false, false))
+	asserts.Equal(1, countObjects())
+
+	// 最后一个引用被删除时删除源文件
+	fs1.CleanTargets()
+	exist, copied := fs1.IsFileExist("/copied/1.txt")
+	asserts.True(exist)
+	asserts.NoError(fs1.Delete(context.Background(), []uint{}, []uint{copied.ID}, false, false))
+	asserts.Equal(0, countObjects())
+	blobs, err = model.GetBlobsByIDs([]uint{file1.BlobID})
+	asserts.NoError(err)
+	asserts.Len(blobs, 0)
+}
@@ -0,0 +1,223 @@
+package filesystem
+
+import (
+	"context"
+	"time"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/hashid"
+	"github.com/cloudreve/Cloudreve/v3/pkg/search"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+)
+
+/* ================
+	 全文检索相关
+   ================
+*/
+
+const (
+	// contentIndexTimeout 单个文件内容提取的超时时间
+	contentIndexTimeout = 10 * time.Minute
+	// contentSearchLimit 全文检索返回的最大结果数
+	contentSearchLimit = 100
+)
+
+// IndexContent 在后台提取文件内容并更新全文索引，文件类型或大小不符合索引条件时，
+// 移除已有的索引
+func IndexContent(file *model.File) {
+	index := search.Current()
+	if index == nil || file.UploadSessionID != nil {
+		return
+	}
+
+	if !search.ShouldIndex(file.Name, file.Size) {
+		removeContent([]uint{file.ID})
+		return
+	}
+
+	id, uid := file.ID, file.UserID
+	search.Go(func() {
+		if err := indexContent(index, id, uid); err != nil {
+			util.Log().Debug("Failed to index content of file %d: %s", id, err)
+			// 内容无法提取时不保留旧内容
+			removeContent([]uint{id})
+		}
+	})
+}
+
+// indexContent 提取文件内容并写入索引，执行时重新读取文件记录，
+// 以免排队期间文件被重命名、覆盖或删除
+func indexContent(index search.Index, id, uid uint) error {
+	files, err := model.GetFilesByIDs([]uint{id}, uid)
+	if err != nil || len(files) == 0 {
+		return ErrObjectNotExist
+	}
+
+	file := &files[0]
+	if file.UploadSessionID != nil || !search.ShouldIndex(file.Name, file.Size) {
+		return search.ErrUnsupported
+	}
+
+	// 使用文件所在存储策略的适配器读取源文件
+	fs := &FileSystem{Policy: file.GetPolicy()}
+	if err := fs.DispatchHandler(); err != nil {
+		return err
+	}
+	if fs.Handler == nil {
+		return ErrUnknownPolicyType
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), contentIndexTimeout)
+	defer cancel()
+
+	source, err := fs.Handler.Get(ctx, file.SourceName)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	content, err := search.Extract(file.Name, source)
+	if err != nil {
+		return err
+	}
+
+	return index.Index(&search.Document{
+		FileID:  file.ID,
+		UserID:  file.UserID,
+		Name:    file.Name,
+		Content: content,
+	})
+}
+
+// HookIndexContent 上传完成后更新文件的全文索引
+func HookIndexContent(ctx context.Context, fs *FileSystem, fileHeader fsctx.FileHeader) error {
+	if file, ok := fileHeader.Info().Model.(*model.File); ok {
+		IndexContent(file)
+	}
+	return nil
+}
+
+// renameContent 文件重命名后更新全文索引中的文件名
+func renameContent(file *model.File) {
+	index := search.Current()
+	if index == nil {
+		return
+	}
+
+	if search.ShouldIndex(file.Name, file.Size) {
+		err := index.Rename(file.ID, file.Name)
+		if err == nil {
+			return
+		}
+		if err != search.ErrNotIndexed {
+			util.Log().Warning("Failed to rename file %d in content index: %s", file.ID, err)
+			return
+		}
+	}
+
+	// 扩展名变化后需要重新索引或移除
+	IndexContent(file)
+}
+
+// renameContentByIDs 批量更新全文索引中的文件名
+func (fs *FileSystem) renameContentByIDs(ids []uint) {
+	if search.Current() == nil || len(ids) == 0 {
+		return
+	}
+
+	files, err := model.GetFilesByIDs(ids, fs.User.ID)
+	if err != nil {
+		util.Log().Warning("Failed to list renamed files for content index: %s", err)
+		return
+	}
+
+	for i := range files {
+		renameContent(&files[i])
+	}
+}
+
+// removeContent 从全文索引中移除已删除的文件
+func removeContent(fileIDs []uint) {
+	index := search.Current()
+	if index == nil || len(fileIDs) == 0 {
+		return
+	}
+
+	if err := index.Delete(fileIDs...); err != nil {
+		util.Log().Warning("Failed to remove files from content index: %s", err)
+	}
+}
+
+// SearchContent 根据文件内容搜索文件，结果按相关性排序。
+// 文件移动后无需更新索引，所在目录均在查询时从数据库中获取
+func (fs *FileSystem) SearchContent(ctx context.Context, query string) ([]serializer.Object, error) {
+	index := search.Current()
+	if index == nil {
+		return nil, ErrContentSearchDisabled
+	}
+
+	hits, err := index.Search(fs.User.ID, query, contentSearchLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(hits) == 0 {
+		return []serializer.Object{}, nil
+	}
+
+	ids := make([]uint, len(hits))
+	for i, hit := range hits {
+		ids[i] = hit.FileID
+	}
+
+	files, err := model.GetFilesByIDs(ids, fs.User.ID)
+	if err != nil {
+		return nil, ErrDBListObjects.WithError(err)
+	}
+
+	// 如果限定了根目录，则只保留这个根目录下的结果
+	var parents map[uint]bool
+	if fs.Root != nil {
+		allFolders, err := model.GetRecursiveChildFolder([]uint{fs.Root.ID}, fs.User.ID, true)
+		if err != nil {
+			return nil, ErrDBListObjects.WithError(err)
+		}
+
+		parents = make(map[uint]bool, len(allFolders))
+		for _, folder := range allFolders {
+			parents[folder.ID] = true
+		}
+	}
+
+	filesByID := make(map[uint]model.File, len(files))
+	for _, file := range files {
+		filesByID[file.ID] = file
+	}
+
+	matched := make([]model.File, 0, len(files))
+	hitsByID := make(map[string]search.Hit, len(files))
+	for _, hit := range hits {
+		// 回收站中的文件不会被查询到，其索引在彻底删除时才移除
+		file, ok := filesByID[hit.FileID]
+		if !ok || file.UploadSessionID != nil || (parents != nil && !parents[file.FolderID]) {
+			continue
+		}
+
+		matched = append(matched, file)
+		hitsByID[hashid.HashID(file.ID, hashid.FileID)] = hit
+	}
+
+	// 按对象 ID 关联检索结果，不依赖列出对象的顺序
+	fs.SetTargetFile(&matched)
+	objects := fs.listObjects(ctx, "/", matched, nil, nil)
+	for i := range objects {
+		if hit, ok := hitsByID[objects[i].ID]; ok {
+			objects[i].Score = hit.Score
+			objects[i].Snippets = hit.Snippets
+		}
+	}
+
+	return objects, nil
+}
@@ -0,0 +1,70 @@
+package filesystem
+
+import (
+	"context"
+	"testing"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/search"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileSystem_SearchContentSQLite(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	fs := &FileSystem{User: &model.User{}}
+	fs.User.ID = 1
+
+	// 未启用
+	{
+		search.Default = nil
+		_, err := fs.SearchContent(ctx, "keyword")
+		asserts.Equal(ErrContentSearchDisabled, err)
+	}
+
+	mockDB := model.DB
+	model.DB, _ = gorm.Open("sqlite", ":memory:")
+	defer func() {
+		model.DB = mockDB
+	}()
+	model.DB.AutoMigrate(&model.Policy{}, &model.File{}, &model.ContentDocument{}, &model.ContentTerm{})
+
+	index, err := search.NewDatabaseIndex(nil)
+	asserts.NoError(err)
+	search.Default = index
+	defer func() { search.Default = nil }()
+	for _, name := range []string{"a.txt", "keyword.txt", "trashed.txt"} {
+		asserts.NoError(model.DB.Create(&model.File{Name: name, UserID: 1}).Error)
+	}
+	asserts.NoError(model.DB.Delete(&model.File{}, 3).Error)
+	asserts.NoError(index.Index(&search.Document{FileID: 1, UserID: 1, Name: "a.txt", Content: "keyword"}))
+	asserts.NoError(index.Index(&search.Document{FileID: 2, UserID: 1, Name: "keyword.txt", Content: "keyword keyword"}))
+	asserts.NoError(index.Index(&search.Document{FileID: 3, UserID: 1, Name: "trashed.txt", Content: "keyword"}))
+
+	// 无结果
+	{
+		res, err := fs.SearchContent(ctx, "nothing")
+		asserts.NoError(err)
+		asserts.Empty(res)
+	}
+
+	// 按相关性排序，忽略已删除的文件
+	{
+		res, err := fs.SearchContent(ctx, "keyword")
+		asserts.NoError(err)
+		asserts.Len(res, 2)
+		asserts.Equal("keyword.txt", res[0].Name)
+		asserts.Equal("a.txt", res[1].Name)
+		asserts.True(res[0].Score > res[1].Score)
+		asserts.Equal([]string{"<mark>keyword</mark>"}, res[1].Snippets)
+	}
+
+	// 删除
+	{
+		removeContent([]uint{1, 2})
+		hits, _ := index.Search(1, "keyword", 10)
+		asserts.Len(hits, 1)
+		asserts.EqualValues(3, hits[0].FileID)
+	}
+}
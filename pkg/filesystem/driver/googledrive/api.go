@@ -0,0 +1,635 @@
+package googledrive
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
+	"github.com/cloudreve/Cloudreve/v3/pkg/conf"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/chunk"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/chunk/backoff"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/mq"
+	"github.com/cloudreve/Cloudreve/v3/pkg/request"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+)
+
+const (
+	// ChunkSizeUnit 分片大小必须为此值的整数倍
+	ChunkSizeUnit uint64 = 256 << 10
+	// ListRetry 列取请求重试次数
+	ListRetry       = 1
+	chunkRetrySleep = time.Second * 5
+
+	// pathCachePrefix 路径与文件ID映射的缓存前缀
+	pathCachePrefix = "googledrive_path_"
+	pathCacheTTL    = 3600
+
+	metaFields = "id,name,mimeType,size,parents,modifiedTime,md5Checksum,thumbnailLink,imageMediaMetadata(width,height)"
+	listFields = "nextPageToken,files(id,name,mimeType,size,parents,modifiedTime,md5Checksum)"
+)
+
+var queryEscaper = strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+
+func (client *Client) getRequestURL(api string, query url.Values) string {
+	return buildURL(client.Endpoints.EndpointURL, api, query)
+}
+
+func (client *Client) getUploadURL(api string, query url.Values) string {
+	return buildURL(client.Endpoints.UploadEndpointURL, api, query)
+}
+
+func buildURL(endpoint, api string, query url.Values) string {
+	base, _ := url.Parse(endpoint)
+	if base == nil {
+		return ""
+	}
+
+	base.Path = path.Join(base.Path, api)
+	if query != nil {
+		base.RawQuery = query.Encode()
+	}
+
+	return base.String()
+}
+
+// Meta 根据文件路径获取文件元信息
+func (client *Client) Meta(ctx context.Context, dst string) (*FileInfo, error) {
+	id, cached, err := client.resolvePath(ctx, dst, false)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := client.MetaByID(ctx, id)
+	if err != nil && cached && isNotFound(err) {
+		// 缓存的 ID 已失效，重新查找
+		client.invalidatePath(dst)
+		if id, _, err = client.resolvePath(ctx, dst, false); err != nil {
+			return nil, err
+		}
+		info, err = client.MetaByID(ctx, id)
+	}
+
+	if err != nil && isNotFound(err) {
+		return nil, ErrObjectNotFound
+	}
+
+	return info, err
+}
+
+// MetaByID 根据资源ID获取文件元信息
+func (client *Client) MetaByID(ctx context.Context, id string) (*FileInfo, error) {
+	requestURL := client.getRequestURL("files/"+id, url.Values{"fields": {metaFields}})
+	res, err := client.requestWithStr(ctx, "GET", requestURL, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var fileInfo FileInfo
+	if err := json.Unmarshal([]byte(res), &fileInfo); err != nil {
+		return nil, err
+	}
+
+	return &fileInfo, nil
+}
+
+// ListChildren 列取给定ID目录下的全部子对象
+func (client *Client) ListChildren(ctx context.Context, parentID string) ([]FileInfo, error) {
+	query := fmt.Sprintf("'%s' in parents and trashed = false", queryEscaper.Replace(parentID))
+	res := make([]FileInfo, 0)
+	pageToken := ""
+	for {
+		list, err := client.listFiles(ctx, query, pageToken)
+		if err != nil {
+			retried := 0
+			if v, ok := ctx.Value(fsctx.RetryCtx).(int); ok {
+				retried = v
+			}
+			if retried < ListRetry {
+				retried++
+				util.Log().WithContext(ctx).Debug("Failed to list Google Drive folder %q: %s, will retry in 5 seconds.", parentID, err)
+				time.Sleep(time.Duration(5) * time.Second)
+				return client.ListChildren(context.WithValue(ctx, fsctx.RetryCtx, retried), parentID)
+			}
+			return nil, err
+		}
+
+		res = append(res, list.Files...)
+		if list.NextPageToken == "" {
+			return res, nil
+		}
+		pageToken = list.NextPageToken
+	}
+}
+
+func (client *Client) listFiles(ctx context.Context, q, pageToken string) (*ListResponse, error) {
+	query := url.Values{
+		"q":        {q},
+		"fields":   {listFields},
+		"pageSize": {"1000"},
+	}
+	if pageToken != "" {
+		query.Set("pageToken", pageToken)
+	}
+
+	res, err := client.requestWithStr(ctx, "GET", client.getRequestURL("files", query), "")
+	if err != nil {
+		return nil, err
+	}
+
+	var list ListResponse
+	if err := json.Unmarshal([]byte(res), &list); err != nil {
+		return nil, err
+	}
+
+	return &list, nil
+}
+
+// findChild 查找父目录下名为name的子对象
+func (client *Client) findChild(ctx context.Context, parentID, name string) (*FileInfo, error) {
+	q := fmt.Sprintf(
+		"name = '%s' and '%s' in parents and trashed = false",
+		queryEscaper.Replace(name),
+		queryEscaper.Replace(parentID),
+	)
+	list, err := client.listFiles(ctx, q, "")
+	if err != nil {
+		return nil, err
+	}
+
+	if len(list.Files) == 0 {
+		return nil, ErrObjectNotFound
+	}
+
+	return &list.Files[0], nil
+}
+
+// CreateFolder 在父目录下创建子目录
+func (client *Client) CreateFolder(ctx context.Context, parentID, name string) (*FileInfo, error) {
+	body, _ := json.Marshal(map[string]interface{}{
+		"name":     name,
+		"mimeType": folderMimeType,
+		"parents":  []string{parentID},
+	})
+
+	res, err := client.requestWithStr(ctx, "POST", client.getRequestURL("files", url.Values{"fields": {metaFields}}), string(body))
+	if err != nil {
+		return nil, err
+	}
+
+	var folder FileInfo
+	if err := json.Unmarshal([]byte(res), &folder); err != nil {
+		return nil, err
+	}
+
+	return &folder, nil
+}
+
+// resolvePath 将存储路径解析为 Google Drive 中的对象ID，create 为 true 时
+// 会创建不存在的目录。返回值中 cached 表示结果是否来自缓存。
+func (client *Client) resolvePath(ctx context.Context, dst string, create bool) (string, bool, error) {
+	dst = strings.Trim(dst, "/")
+	if dst == "" || dst == "." {
+		return rootFolderID, false, nil
+	}
+
+	cacheKey := client.pathCacheKey(dst)
+	if id, ok := cache.Get(cacheKey); ok {
+		return id.(string), true, nil
+	}
+
+	parentID, _, err := client.resolvePath(ctx, path.Dir(dst), create)
+	if err != nil {
+		return "", false, err
+	}
+
+	name := path.Base(dst)
+	child, err := client.findChild(ctx, parentID, name)
+	if errors.Is(err, ErrObjectNotFound) && create {
+		child, err = client.CreateFolder(ctx, parentID, name)
+	}
+
+	if err != nil {
+		return "", false, err
+	}
+
+	_ = cache.Set(cacheKey, child.ID, pathCacheTTL)
+	return child.ID, false, nil
+}
+
+func (client *Client) pathCacheKey(dst string) string {
+	return fmt.Sprintf("%s%d_%s", pathCachePrefix, client.Policy.ID, strings.Trim(dst, "/"))
+}
+
+func (client *Client) invalidatePath(dst string) {
+	_ = cache.Deletes([]string{client.pathCacheKey(dst)}, "")
+}
+
+// CreateUploadSession 创建可续传的上传会话，返回会话URL
+func (client *Client) CreateUploadSession(ctx context.Context, dst string, size uint64, overwrite bool, origin string) (string, error) {
+	header := http.Header{
+		"X-Upload-Content-Length": {fmt.Sprintf("%d", size)},
+	}
+	if origin != "" {
+		// 客户端直传时需要允许跨域
+		header.Set("Origin", origin)
+	}
+
+	var (
+		method     = "POST"
+		requestURL = client.getUploadURL("files", url.Values{"uploadType": {"resumable"}})
+		metadata   = map[string]interface{}{}
+	)
+
+	existed, err := client.Meta(ctx, dst)
+	if err == nil {
+		if !overwrite {
+			return "", ErrFileExisted
+		}
+
+		// 覆盖已有文件的内容
+		method = "PATCH"
+		requestURL = client.getUploadURL("files/"+existed.ID, url.Values{"uploadType": {"resumable"}})
+	} else if errors.Is(err, ErrObjectNotFound) {
+		parentID, _, err := client.resolvePath(ctx, path.Dir(strings.Trim(dst, "/")), true)
+		if err != nil {
+			return "", err
+		}
+
+		metadata["name"] = path.Base(dst)
+		metadata["parents"] = []string{parentID}
+	} else {
+		return "", err
+	}
+
+	body, _ := json.Marshal(metadata)
+	resp, err := client.requestRaw(ctx, method, requestURL, strings.NewReader(string(body)),
+		request.WithContentLength(int64(len(body))),
+		request.WithHeader(header),
+	)
+	if err != nil {
+		return "", err
+	}
+	_ = resp.Body.Close()
+
+	uploadURL := resp.Header.Get("Location")
+	if uploadURL == "" {
+		return "", errors.New("upload session url not returned")
+	}
+
+	return uploadURL, nil
+}
+
+// UploadChunk 上传分片，上传完成后返回文件元信息
+func (client *Client) UploadChunk(ctx context.Context, uploadURL string, content io.Reader, current *chunk.ChunkGroup) (*FileInfo, error) {
+	resp, err := client.requestRaw(
+		ctx, "PUT", uploadURL, content,
+		request.WithContentLength(current.Length()),
+		request.WithHeader(http.Header{
+			"Content-Range": {current.RangeHeader()},
+		}),
+		request.WithoutHeader([]string{"Authorization", "Content-Type"}),
+		request.WithTimeout(0),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload Google Drive chunk #%d: %w", current.Index(), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusPermanentRedirect {
+		// 308 Resume Incomplete，继续上传下一个分片
+		return nil, nil
+	}
+
+	var info FileInfo
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return nil, err
+	}
+
+	return &info, nil
+}
+
+// GetUploadSessionStatus 查询上传会话已接收的数据大小，会话已完成时返回 -1
+func (client *Client) GetUploadSessionStatus(ctx context.Context, uploadURL string, size uint64) (int64, error) {
+	resp, err := client.requestRaw(ctx, "PUT", uploadURL, nil,
+		request.WithContentLength(0),
+		request.WithHeader(http.Header{
+			"Content-Range": {fmt.Sprintf("bytes */%d", size)},
+		}),
+		request.WithoutHeader([]string{"Authorization", "Content-Type"}),
+	)
+	if err != nil {
+		return 0, err
+	}
+	_ = resp.Body.Close()
+
+	if resp.StatusCode != http.StatusPermanentRedirect {
+		return -1, nil
+	}
+
+	// Range: bytes=0-xxx
+	var start, end int64
+	if _, err := fmt.Sscanf(resp.Header.Get("Range"), "bytes=%d-%d", &start, &end); err != nil {
+		return 0, nil
+	}
+
+	return end + 1, nil
+}
+
+// DeleteUploadSession 取消上传会话
+func (client *Client) DeleteUploadSession(ctx context.Context, uploadURL string) error {
+	resp, err := client.requestRaw(ctx, "DELETE", uploadURL, nil,
+		request.WithoutHeader([]string{"Authorization", "Content-Type"}),
+	)
+	if err != nil {
+		var apiErr *RespError
+		// 会话取消成功时返回 499
+		if errors.As(err, &apiErr) && apiErr.APIError.Code == 499 {
+			return nil
+		}
+		return err
+	}
+
+	return resp.Body.Close()
+}
+
+// Upload 上传文件
+func (client *Client) Upload(ctx context.Context, file fsctx.FileHeader) error {
+	fileInfo := file.Info()
+	overwrite := fileInfo.Mode&fsctx.Overwrite == fsctx.Overwrite
+
+	// 空文件无需上传内容
+	if fileInfo.Size == 0 {
+		return client.createEmptyFile(ctx, fileInfo.SavePath, overwrite)
+	}
+
+	uploadURL, err := client.CreateUploadSession(ctx, fileInfo.SavePath, fileInfo.Size, overwrite, "")
+	if err != nil {
+		return err
+	}
+
+	// Initial chunk groups
+	chunks := chunk.NewChunkGroup(file, client.Policy.OptionsSerialized.ChunkSize, &backoff.ConstantBackoff{
+		Max:   model.GetIntSetting("chunk_retries", 5),
+		Sleep: chunkRetrySleep,
+	}, model.IsTrueVal(model.GetSettingByName("use_temp_chunk_buffer")))
+
+	uploadFunc := func(current *chunk.ChunkGroup, content io.Reader) error {
+		_, err := client.UploadChunk(ctx, uploadURL, content, current)
+		return err
+	}
+
+	// upload chunks
+	for chunks.Next() {
+		if err := chunks.Process(uploadFunc); err != nil {
+			_ = client.DeleteUploadSession(context.Background(), uploadURL)
+			return fmt.Errorf("failed to upload chunk #%d: %w", chunks.Index(), err)
+		}
+	}
+
+	return nil
+}
+
+func (client *Client) createEmptyFile(ctx context.Context, dst string, overwrite bool) error {
+	uploadURL, err := client.CreateUploadSession(ctx, dst, 0, overwrite, "")
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.requestRaw(ctx, "PUT", uploadURL, nil,
+		request.WithContentLength(0),
+		request.WithoutHeader([]string{"Authorization", "Content-Type"}),
+	)
+	if err != nil {
+		return err
+	}
+
+	return resp.Body.Close()
+}
+
+// Download 从 offset 处开始获取文件内容
+func (client *Client) Download(ctx context.Context, id string, offset int64) (*http.Response, error) {
+	header := http.Header{}
+	if offset > 0 {
+		header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	return client.requestRaw(ctx, "GET", client.getRequestURL("files/"+id, url.Values{"alt": {"media"}}), nil,
+		request.WithHeader(header),
+		request.WithTimeout(0),
+	)
+}
+
+// GetThumb 获取缩略图数据流，size 为缩略图最长边的像素值
+func (client *Client) GetThumb(ctx context.Context, dst string, size uint) (*http.Response, error) {
+	info, err := client.Meta(ctx, dst)
+	if err != nil {
+		return nil, err
+	}
+
+	if info.ThumbnailLink == "" {
+		return nil, ErrObjectNotFound
+	}
+
+	// 缩略图地址以 =s220 结尾，替换为所需尺寸
+	thumbURL := info.ThumbnailLink
+	if idx := strings.LastIndex(thumbURL, "=s"); idx > 0 {
+		thumbURL = thumbURL[:idx]
+	}
+	thumbURL = fmt.Sprintf("%s=s%d", thumbURL, size)
+
+	return client.requestRaw(ctx, "GET", thumbURL, nil)
+}
+
+// Delete 删除给定路径的文件，返回删除失败的文件，及遇到的最后一个错误
+func (client *Client) Delete(ctx context.Context, dst []string) ([]string, error) {
+	failed := make([]string, 0, len(dst))
+	var lastErr error
+
+	for _, file := range dst {
+		info, err := client.Meta(ctx, file)
+		if err != nil {
+			if errors.Is(err, ErrObjectNotFound) {
+				continue
+			}
+
+			failed = append(failed, file)
+			lastErr = err
+			continue
+		}
+
+		if _, err := client.requestWithStr(ctx, "DELETE", client.getRequestURL("files/"+info.ID, nil), ""); err != nil && !isNotFound(err) {
+			failed = append(failed, file)
+			lastErr = err
+			continue
+		}
+
+		client.invalidatePath(file)
+	}
+
+	if len(failed) > 0 && lastErr == nil {
+		lastErr = ErrDeleteFile
+	}
+
+	return failed, lastErr
+}
+
+// MonitorUpload 监控客户端直传的上传会话，超时未完成回调时清理会话及已上传的文件
+func (client *Client) MonitorUpload(uploadURL, callbackKey, dst string, size uint64, ttl int64) {
+	// 回调完成通知chan
+	callbackChan := mq.GlobalMQ.Subscribe(callbackKey, 1)
+	defer mq.GlobalMQ.Unsubscribe(callbackKey, callbackChan)
+
+	interval := model.GetIntSetting("googledrive_callback_check", 20)
+
+	select {
+	case <-callbackChan:
+		util.Log().Debug("Client finished Google Drive callback.")
+		return
+	case <-time.After(time.Duration(ttl) * time.Second):
+		// 上传会话到期，仍未完成回调
+		uploaded, err := client.GetUploadSessionStatus(context.Background(), uploadURL, size)
+		if err == nil && uploaded >= 0 {
+			util.Log().Debug("Google Drive upload session expired, canceling...")
+			_ = client.DeleteUploadSession(context.Background(), uploadURL)
+			return
+		}
+
+		// 上传已完成，稍后检查回调
+		select {
+		case <-time.After(time.Duration(interval) * time.Second):
+			util.Log().Warning("No callback is made, file will be deleted.")
+			cache.Deletes([]string{callbackKey}, "callback_")
+			if _, err := client.Delete(context.Background(), []string{dst}); err != nil {
+				util.Log().Warning("Failed to delete file without callback: %s", err)
+			}
+		case <-callbackChan:
+			util.Log().Debug("Client finished callback.")
+		}
+	}
+}
+
+func isNotFound(err error) bool {
+	var apiErr *RespError
+	return errors.Is(err, ErrObjectNotFound) || (errors.As(err, &apiErr) && apiErr.APIError.Code == http.StatusNotFound)
+}
+
+func isRateLimited(resp *http.Response, errResp *RespError) bool {
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return true
+	}
+
+	if resp.StatusCode == http.StatusForbidden {
+		for _, e := range errResp.APIError.Errors {
+			if e.Reason == "rateLimitExceeded" || e.Reason == "userRateLimitExceeded" {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func sysError(err error) *RespError {
+	return &RespError{APIError: APIError{
+		Code:    -1,
+		Message: err.Error(),
+	}}
+}
+
+// requestRaw 发送请求并检查响应状态，成功时返回未读取的响应
+func (client *Client) requestRaw(ctx context.Context, method string, url string, body io.Reader, option ...request.Option) (*http.Response, error) {
+	// 获取凭证
+	err := client.UpdateCredential(ctx, conf.SystemConfig.Mode == "slave")
+	if err != nil {
+		return nil, sysError(err)
+	}
+
+	option = append([]request.Option{
+		request.WithHeader(http.Header{
+			"Authorization": {"Bearer " + client.Credential.AccessToken},
+			"Content-Type":  {"application/json"},
+		}),
+		request.WithContext(ctx),
+		request.WithTPSLimit(
+			fmt.Sprintf("policy_%d", client.Policy.ID),
+			client.Policy.OptionsSerialized.TPSLimit,
+			client.Policy.OptionsSerialized.TPSLimitBurst,
+		),
+	}, option...)
+
+	// 发送请求
+	res := client.Request.Request(
+		method,
+		url,
+		body,
+		option...,
+	)
+
+	if res.Err != nil {
+		return nil, sysError(res.Err)
+	}
+
+	// 308 表示可续传上传尚未完成
+	if (res.Response.StatusCode >= 200 && res.Response.StatusCode < 300) ||
+		res.Response.StatusCode == http.StatusPermanentRedirect {
+		return res.Response, nil
+	}
+
+	// 解析错误响应
+	respBody, err := res.GetResponse()
+	if err != nil {
+		return nil, sysError(err)
+	}
+
+	var errResp RespError
+	if err := json.Unmarshal([]byte(respBody), &errResp); err != nil || errResp.APIError.Message == "" {
+		util.Log().WithContext(ctx).Debug("Google Drive returns unknown response: %s", respBody)
+		errResp = RespError{APIError: APIError{
+			Code:    res.Response.StatusCode,
+			Message: fmt.Sprintf("unexpected status code %d", res.Response.StatusCode),
+		}}
+	}
+
+	if errResp.APIError.Code == 0 {
+		errResp.APIError.Code = res.Response.StatusCode
+	}
+
+	if isRateLimited(res.Response, &errResp) {
+		util.Log().WithContext(ctx).Warning("Google Drive request is throttled.")
+		return nil, backoff.NewRetryableErrorFromHeader(&errResp, res.Response.Header)
+	}
+
+	return nil, &errResp
+}
+
+func (client *Client) request(ctx context.Context, method string, url string, body io.Reader, option ...request.Option) (string, error) {
+	resp, err := client.requestRaw(ctx, method, url, body, option...)
+	if err != nil {
+		return "", err
+	}
+
+	respBody, err := (&request.Response{Response: resp}).GetResponse()
+	if err != nil {
+		return "", sysError(err)
+	}
+
+	return respBody, nil
+}
+
+func (client *Client) requestWithStr(ctx context.Context, method string, url string, body string) (string, error) {
+	// 发送请求
+	bodyReader := io.NopCloser(strings.NewReader(body))
+	return client.request(ctx, method, url, bodyReader,
+		request.WithContentLength(int64(len(body))),
+	)
+}
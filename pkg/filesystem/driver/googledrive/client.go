@@ -22,19 +22,26 @@ type Client struct {
 	ClusterController cluster.Controller
 }
 
-// Endpoints OneDrive客户端相关设置
+// Endpoints Google Drive客户端相关设置
 type Endpoints struct {
 	UserConsentEndpoint string // OAuth认证的基URL
 	TokenEndpoint       string // OAuth token 基URL
 	EndpointURL         string // 接口请求的基URL
+	UploadEndpointURL   string // 上传接口请求的基URL
 }
 
 const (
 	TokenCachePrefix = "googledrive_"
 
-	oauthEndpoint   = "https://oauth2.googleapis.com/token"
-	userConsentBase = "https://accounts.google.com/o/oauth2/auth"
-	v3DriveEndpoint = "https://www.googleapis.com/drive/v3"
+	oauthEndpoint    = "https://oauth2.googleapis.com/token"
+	userConsentBase  = "https://accounts.google.com/o/oauth2/auth"
+	v3DriveEndpoint  = "https://www.googleapis.com/drive/v3"
+	v3UploadEndpoint = "https://www.googleapis.com/upload/drive/v3"
+
+	// rootFolderID 存储策略根目录的别名
+	rootFolderID         = "root"
+	folderMimeType       = "application/vnd.google-apps.folder"
+	nativeMimeTypePrefix = "application/vnd.google-apps."
 )
 
 var (
@@ -48,6 +55,12 @@ var (
 
 	// ErrInvalidRefreshToken 上传策略无有效的RefreshToken
 	ErrInvalidRefreshToken = errors.New("no valid refresh token in this policy")
+	// ErrDeleteFile 无法删除文件
+	ErrDeleteFile = errors.New("cannot delete file")
+	// ErrFileExisted 目标路径已存在文件
+	ErrFileExisted = errors.New("file already exist")
+	// ErrObjectNotFound 路径对应的对象不存在
+	ErrObjectNotFound = errors.New("object not found")
 )
 
 // NewClient 根据存储策略获取新的client
@@ -57,6 +70,7 @@ func NewClient(policy *model.Policy) (*Client, error) {
 			TokenEndpoint:       oauthEndpoint,
 			UserConsentEndpoint: userConsentBase,
 			EndpointURL:         v3DriveEndpoint,
+			UploadEndpointURL:   v3UploadEndpoint,
 		},
 		Credential: &Credential{
 			RefreshToken: policy.AccessKey,
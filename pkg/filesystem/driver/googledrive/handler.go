@@ -2,64 +2,241 @@ package googledrive
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+
 	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/auth"
+	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/response"
 	"github.com/cloudreve/Cloudreve/v3/pkg/request"
 	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
 )
 
 // Driver Google Drive 适配器
 type Driver struct {
 	Policy     *model.Policy
+	Client     *Client
 	HTTPClient request.Client
 }
 
 // NewDriver 从存储策略初始化新的Driver实例
 func NewDriver(policy *model.Policy) (driver.Handler, error) {
-	return &Driver{
+	client, err := NewClient(policy)
+	if err != nil {
+		return Driver{}, err
+	}
+
+	if policy.OptionsSerialized.ChunkSize == 0 {
+		policy.OptionsSerialized.ChunkSize = 50 << 20 // 50MB
+	}
+
+	// 分片大小需为 256KB 的整数倍
+	if policy.OptionsSerialized.ChunkSize%ChunkSizeUnit != 0 {
+		policy.OptionsSerialized.ChunkSize = (policy.OptionsSerialized.ChunkSize/ChunkSizeUnit + 1) * ChunkSizeUnit
+	}
+
+	return Driver{
 		Policy:     policy,
+		Client:     client,
 		HTTPClient: request.NewClient(),
 	}, nil
 }
 
-func (d *Driver) Put(ctx context.Context, file fsctx.FileHeader) error {
-	//TODO implement me
-	panic("implement me")
+// Put 将文件流保存到指定目录
+func (handler Driver) Put(ctx context.Context, file fsctx.FileHeader) error {
+	defer file.Close()
+
+	return handler.Client.Upload(ctx, file)
 }
 
-func (d *Driver) Delete(ctx context.Context, files []string) ([]string, error) {
-	//TODO implement me
-	panic("implement me")
+// Delete 删除一个或多个文件，
+// 返回未删除的文件，及遇到的最后一个错误
+func (handler Driver) Delete(ctx context.Context, files []string) ([]string, error) {
+	return handler.Client.Delete(ctx, files)
 }
 
-func (d *Driver) Get(ctx context.Context, path string) (response.RSCloser, error) {
-	//TODO implement me
-	panic("implement me")
+// Get 获取文件
+func (handler Driver) Get(ctx context.Context, path string) (response.RSCloser, error) {
+	info, err := handler.Client.Meta(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	// 数据流在首次读取时才会发起请求
+	return &rangeReader{
+		ctx:    ctx,
+		client: handler.Client,
+		id:     info.ID,
+		size:   int64(info.Size),
+	}, nil
 }
 
-func (d *Driver) Thumb(ctx context.Context, file *model.File) (*response.ContentResponse, error) {
-	//TODO implement me
-	panic("implement me")
+// Thumb 获取文件缩略图
+func (handler Driver) Thumb(ctx context.Context, file *model.File) (*response.ContentResponse, error) {
+	thumbSize, ok := ctx.Value(fsctx.ThumbSizeCtx).([2]uint)
+	if !ok {
+		return nil, errors.New("failed to get thumbnail size")
+	}
+
+	size := thumbSize[0]
+	if thumbSize[1] > size {
+		size = thumbSize[1]
+	}
+
+	resp, err := handler.Client.GetThumb(ctx, file.SourceName, size)
+	if err != nil {
+		if isNotFound(err) {
+			// Google Drive cannot generate thumbnail for this file
+			return nil, driver.ErrorThumbNotSupported
+		}
+		return nil, err
+	}
+
+	rs, err := (&request.Response{Response: resp}).GetRSCloser()
+	if err != nil {
+		return nil, err
+	}
+
+	return &response.ContentResponse{
+		Redirect: false,
+		Content:  rs,
+	}, nil
 }
 
-func (d *Driver) Source(ctx context.Context, path string, ttl int64, isDownload bool, speed int) (string, error) {
-	//TODO implement me
-	panic("implement me")
+// Source 获取外链URL，Google Drive 不提供免认证的下载地址，
+// 下载请求需由 Cloudreve 中转
+func (handler Driver) Source(ctx context.Context, path string, ttl int64, isDownload bool, speed int) (string, error) {
+	file, ok := ctx.Value(fsctx.FileModelCtx).(model.File)
+	if !ok {
+		return "", errors.New("failed to read file model context")
+	}
+
+	var (
+		signedURI *url.URL
+		err       error
+	)
+	if isDownload {
+		// 创建下载会话，将文件信息写入缓存
+		downloadSessionID := util.RandStringRunes(16)
+		err = cache.Set("download_"+downloadSessionID, file, int(ttl))
+		if err != nil {
+			return "", serializer.NewError(serializer.CodeCacheOperation, "Failed to create download session", err)
+		}
+
+		signedURI, err = auth.SignURI(
+			auth.General,
+			fmt.Sprintf("/api/v3/file/download/%s", downloadSessionID),
+			ttl,
+		)
+	} else {
+		signedURI, err = auth.SignURI(
+			auth.General,
+			fmt.Sprintf("/api/v3/file/get/%d/%s", file.ID, file.Name),
+			ttl,
+		)
+	}
+
+	if err != nil {
+		return "", serializer.NewError(serializer.CodeEncryptError, "Failed to sign url", err)
+	}
+
+	return signedURI.String(), nil
 }
 
-func (d *Driver) Token(ctx context.Context, ttl int64, uploadSession *serializer.UploadSession, file fsctx.FileHeader) (*serializer.UploadCredential, error) {
-	//TODO implement me
-	panic("implement me")
+// Token 获取上传会话URL
+func (handler Driver) Token(ctx context.Context, ttl int64, uploadSession *serializer.UploadSession, file fsctx.FileHeader) (*serializer.UploadCredential, error) {
+	fileInfo := file.Info()
+
+	siteURL := model.GetSiteURL()
+	origin := fmt.Sprintf("%s://%s", siteURL.Scheme, siteURL.Host)
+	overwrite := fileInfo.Mode&fsctx.Overwrite == fsctx.Overwrite
+	uploadURL, err := handler.Client.CreateUploadSession(ctx, fileInfo.SavePath, fileInfo.Size, overwrite, origin)
+	if err != nil {
+		return nil, err
+	}
+
+	// 监控回调及上传
+	go handler.Client.MonitorUpload(uploadURL, uploadSession.Key, fileInfo.SavePath, fileInfo.Size, ttl)
+
+	uploadSession.UploadURL = uploadURL
+	return &serializer.UploadCredential{
+		SessionID:  uploadSession.Key,
+		ChunkSize:  handler.Policy.OptionsSerialized.ChunkSize,
+		UploadURLs: []string{uploadURL},
+	}, nil
 }
 
-func (d *Driver) CancelToken(ctx context.Context, uploadSession *serializer.UploadSession) error {
-	//TODO implement me
-	panic("implement me")
+// CancelToken 取消上传凭证
+func (handler Driver) CancelToken(ctx context.Context, uploadSession *serializer.UploadSession) error {
+	return handler.Client.DeleteUploadSession(ctx, uploadSession.UploadURL)
 }
 
-func (d *Driver) List(ctx context.Context, path string, recursive bool) ([]response.Object, error) {
-	//TODO implement me
-	panic("implement me")
+// List 列取项目
+func (handler Driver) List(ctx context.Context, base string, recursive bool) ([]response.Object, error) {
+	base = strings.Trim(base, "/")
+
+	// 获取真实的列取起始根目录
+	rootPath := base
+	if realBase, ok := ctx.Value(fsctx.PathCtx).(string); ok {
+		rootPath = realBase
+	} else {
+		ctx = context.WithValue(ctx, fsctx.PathCtx, base)
+	}
+
+	parentID, _, err := handler.Client.resolvePath(ctx, base, false)
+	if err != nil {
+		return nil, err
+	}
+
+	objects, err := handler.Client.ListChildren(ctx, parentID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 整理结果
+	res := make([]response.Object, 0, len(objects))
+	for _, object := range objects {
+		// Google 文档等原生格式无法直接下载，跳过
+		if object.IsNativeDocument() {
+			continue
+		}
+
+		source := path.Join(base, object.Name)
+		rel, err := filepath.Rel(rootPath, source)
+		if err != nil {
+			continue
+		}
+		res = append(res, response.Object{
+			Name:         object.Name,
+			RelativePath: filepath.ToSlash(rel),
+			Source:       source,
+			Size:         object.Size,
+			IsDir:        object.IsDir(),
+			LastModify:   object.ModifiedTime,
+		})
+	}
+
+	// 递归列取子目录
+	if recursive {
+		for _, object := range objects {
+			if object.IsDir() {
+				sub, err := handler.List(ctx, path.Join(base, object.Name), recursive)
+				if err != nil {
+					util.Log().WithContext(ctx).Warning("Failed to list Google Drive folder %q: %s", path.Join(base, object.Name), err)
+					continue
+				}
+				res = append(res, sub...)
+			}
+		}
+	}
+
+	return res, nil
 }
@@ -0,0 +1,570 @@
+package googledrive
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/auth"
+	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeObject 模拟 Google Drive 中的对象
+type fakeObject struct {
+	ID       string
+	Name     string
+	Parent   string
+	MimeType string
+	Content  []byte
+}
+
+// fakeSession 模拟可续传上传会话
+type fakeSession struct {
+	Target *fakeObject
+	Size   int64
+	Data   []byte
+}
+
+// fakeDrive 本地的 Google Drive API 替身
+type fakeDrive struct {
+	mu       sync.Mutex
+	server   *httptest.Server
+	objects  map[string]*fakeObject
+	sessions map[string]*fakeSession
+	nextID   int
+	tokens   int
+}
+
+var (
+	nameQuery   = regexp.MustCompile(`^name = '((?:[^'\\]|\\.)*)' and '([^']*)' in parents`)
+	parentQuery = regexp.MustCompile(`^'([^']*)' in parents`)
+)
+
+func newFakeDrive() *fakeDrive {
+	d := &fakeDrive{
+		objects:  make(map[string]*fakeObject),
+		sessions: make(map[string]*fakeSession),
+	}
+	d.server = httptest.NewServer(http.HandlerFunc(d.serve))
+	return d
+}
+
+func (d *fakeDrive) newID() string {
+	d.nextID++
+	return fmt.Sprintf("obj%d", d.nextID)
+}
+
+func (d *fakeDrive) add(parent, name, mimeType string, content []byte) *fakeObject {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	obj := &fakeObject{ID: d.newID(), Name: name, Parent: parent, MimeType: mimeType, Content: content}
+	d.objects[obj.ID] = obj
+	return obj
+}
+
+func (d *fakeDrive) find(parent, name string) *fakeObject {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, obj := range d.objects {
+		if obj.Parent == parent && obj.Name == name {
+			return obj
+		}
+	}
+	return nil
+}
+
+func (d *fakeDrive) meta(obj *fakeObject) map[string]interface{} {
+	res := map[string]interface{}{
+		"id":           obj.ID,
+		"name":         obj.Name,
+		"mimeType":     obj.MimeType,
+		"parents":      []string{obj.Parent},
+		"modifiedTime": "2022-01-01T00:00:00.000Z",
+	}
+	if obj.MimeType != folderMimeType {
+		res["size"] = strconv.Itoa(len(obj.Content))
+	}
+	if obj.MimeType == "image/png" {
+		res["thumbnailLink"] = d.server.URL + "/thumb/" + obj.ID + "=s220"
+		res["imageMediaMetadata"] = map[string]int{"width": 10, "height": 20}
+	}
+	return res
+}
+
+func (d *fakeDrive) writeError(w http.ResponseWriter, code int, message string) {
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": map[string]interface{}{"code": code, "message": message},
+	})
+}
+
+func (d *fakeDrive) serve(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/token":
+		d.mu.Lock()
+		d.tokens++
+		d.mu.Unlock()
+		json.NewEncoder(w).Encode(map[string]interface{}{"access_token": "token", "expires_in": 3600})
+		return
+	case strings.HasPrefix(r.URL.Path, "/thumb/"):
+		w.Write([]byte("thumb:" + strings.TrimPrefix(r.URL.Path, "/thumb/")))
+		return
+	case strings.HasPrefix(r.URL.Path, "/session/"):
+		d.serveSession(w, r, strings.TrimPrefix(r.URL.Path, "/session/"))
+		return
+	}
+
+	if r.Header.Get("Authorization") != "Bearer token" {
+		d.writeError(w, 401, "unauthorized")
+		return
+	}
+
+	switch {
+	case r.URL.Path == "/drive/v3/files" && r.Method == "GET":
+		d.serveList(w, r)
+	case r.URL.Path == "/drive/v3/files" && r.Method == "POST":
+		var body struct {
+			Name     string   `json:"name"`
+			MimeType string   `json:"mimeType"`
+			Parents  []string `json:"parents"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		obj := d.add(body.Parents[0], body.Name, body.MimeType, nil)
+		json.NewEncoder(w).Encode(d.meta(obj))
+	case strings.HasPrefix(r.URL.Path, "/drive/v3/files/"):
+		id := strings.TrimPrefix(r.URL.Path, "/drive/v3/files/")
+		d.mu.Lock()
+		obj, ok := d.objects[id]
+		if ok && r.Method == "DELETE" {
+			delete(d.objects, id)
+		}
+		d.mu.Unlock()
+		if !ok {
+			d.writeError(w, 404, "File not found: "+id)
+			return
+		}
+
+		switch {
+		case r.Method == "DELETE":
+			w.WriteHeader(204)
+		case r.URL.Query().Get("alt") == "media":
+			http.ServeContent(w, r, obj.Name, time.Time{}, bytes.NewReader(obj.Content))
+		default:
+			json.NewEncoder(w).Encode(d.meta(obj))
+		}
+	case strings.HasPrefix(r.URL.Path, "/upload/drive/v3/files"):
+		var body struct {
+			Name    string   `json:"name"`
+			Parents []string `json:"parents"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		size, _ := strconv.ParseInt(r.Header.Get("X-Upload-Content-Length"), 10, 64)
+
+		session := &fakeSession{Size: size}
+		if r.Method == "PATCH" {
+			id := strings.TrimPrefix(r.URL.Path, "/upload/drive/v3/files/")
+			d.mu.Lock()
+			session.Target = d.objects[id]
+			d.mu.Unlock()
+		} else {
+			session.Target = &fakeObject{Name: body.Name, Parent: body.Parents[0], MimeType: "application/octet-stream"}
+			if strings.HasSuffix(body.Name, ".png") {
+				session.Target.MimeType = "image/png"
+			}
+		}
+
+		d.mu.Lock()
+		sid := d.newID()
+		d.sessions[sid] = session
+		d.mu.Unlock()
+		w.Header().Set("Location", d.server.URL+"/session/"+sid)
+	default:
+		d.writeError(w, 404, "not found")
+	}
+}
+
+func (d *fakeDrive) serveList(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query().Get("q")
+	var (
+		parent string
+		name   string
+		byName bool
+	)
+	if m := nameQuery.FindStringSubmatch(q); m != nil {
+		name = strings.NewReplacer(`\'`, `'`, `\\`, `\`).Replace(m[1])
+		parent = m[2]
+		byName = true
+	} else if m := parentQuery.FindStringSubmatch(q); m != nil {
+		parent = m[1]
+	} else {
+		d.writeError(w, 400, "invalid query")
+		return
+	}
+
+	files := make([]map[string]interface{}, 0)
+	d.mu.Lock()
+	for _, obj := range d.objects {
+		if obj.Parent == parent && (!byName || obj.Name == name) {
+			files = append(files, d.meta(obj))
+		}
+	}
+	d.mu.Unlock()
+	sort.Slice(files, func(i, j int) bool {
+		return files[i]["id"].(string) < files[j]["id"].(string)
+	})
+
+	// 模拟分页
+	res := map[string]interface{}{"files": files}
+	if r.URL.Query().Get("pageToken") == "" && len(files) > 1 && !byName {
+		res["files"] = files[:1]
+		res["nextPageToken"] = "next"
+	} else if r.URL.Query().Get("pageToken") == "next" {
+		res["files"] = files[1:]
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+func (d *fakeDrive) serveSession(w http.ResponseWriter, r *http.Request, sid string) {
+	d.mu.Lock()
+	session, ok := d.sessions[sid]
+	d.mu.Unlock()
+	if !ok {
+		d.writeError(w, 404, "session not found")
+		return
+	}
+
+	if r.Method == "DELETE" {
+		d.mu.Lock()
+		delete(d.sessions, sid)
+		d.mu.Unlock()
+		w.WriteHeader(499)
+		return
+	}
+
+	data, _ := io.ReadAll(r.Body)
+	session.Data = append(session.Data, data...)
+	if int64(len(session.Data)) < session.Size {
+		if len(session.Data) > 0 {
+			w.Header().Set("Range", fmt.Sprintf("bytes=0-%d", len(session.Data)-1))
+		}
+		w.WriteHeader(http.StatusPermanentRedirect)
+		return
+	}
+
+	d.mu.Lock()
+	if session.Target.ID == "" {
+		session.Target.ID = d.newID()
+	}
+	session.Target.Content = session.Data
+	d.objects[session.Target.ID] = session.Target
+	delete(d.sessions, sid)
+	d.mu.Unlock()
+	json.NewEncoder(w).Encode(d.meta(session.Target))
+}
+
+func newTestDriver(d *fakeDrive) Driver {
+	policy := &model.Policy{AccessKey: "refresh", BucketName: "client_id", SecretKey: "secret"}
+	policy.ID = uint(time.Now().UnixNano() % 100000)
+	h, _ := NewDriver(policy)
+	handler := h.(Driver)
+	handler.Client.Endpoints.TokenEndpoint = d.server.URL + "/token"
+	handler.Client.Endpoints.EndpointURL = d.server.URL + "/drive/v3"
+	handler.Client.Endpoints.UploadEndpointURL = d.server.URL + "/upload/drive/v3"
+	return handler
+}
+
+func init() {
+	cache.Set("setting_chunk_retries", "1", 0)
+	cache.Set("setting_use_temp_chunk_buffer", "false", 0)
+}
+
+func TestNewDriver(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 默认分片大小
+	{
+		h, err := NewDriver(&model.Policy{})
+		asserts.NoError(err)
+		asserts.EqualValues(50<<20, h.(Driver).Policy.OptionsSerialized.ChunkSize)
+	}
+
+	// 分片大小向上取整至 256KB 的倍数
+	{
+		h, err := NewDriver(&model.Policy{OptionsSerialized: model.PolicyOption{ChunkSize: 300 << 10}})
+		asserts.NoError(err)
+		asserts.EqualValues(512<<10, h.(Driver).Policy.OptionsSerialized.ChunkSize)
+	}
+}
+
+func TestDriver_PutAndGet(t *testing.T) {
+	asserts := assert.New(t)
+	d := newFakeDrive()
+	defer d.server.Close()
+	handler := newTestDriver(d)
+	handler.Policy.OptionsSerialized.ChunkSize = ChunkSizeUnit
+
+	content := bytes.Repeat([]byte("0123456789"), int(ChunkSizeUnit)/4)
+
+	// 分片上传，自动创建父目录
+	{
+		err := handler.Put(context.Background(), &fsctx.FileStream{
+			File:     io.NopCloser(bytes.NewReader(content)),
+			Size:     uint64(len(content)),
+			SavePath: "/uploads/1/file.bin",
+		})
+		asserts.NoError(err)
+		asserts.Equal(1, d.tokens)
+
+		folder := d.find(rootFolderID, "uploads")
+		asserts.NotNil(folder)
+		sub := d.find(folder.ID, "1")
+		asserts.NotNil(sub)
+		file := d.find(sub.ID, "file.bin")
+		asserts.NotNil(file)
+		asserts.Equal(content, file.Content)
+	}
+
+	// 已存在文件，不覆盖
+	{
+		err := handler.Put(context.Background(), &fsctx.FileStream{
+			File:     io.NopCloser(bytes.NewReader(content)),
+			Size:     uint64(len(content)),
+			SavePath: "uploads/1/file.bin",
+		})
+		asserts.ErrorIs(err, ErrFileExisted)
+	}
+
+	// 覆盖已有文件
+	{
+		err := handler.Put(context.Background(), &fsctx.FileStream{
+			File:     io.NopCloser(strings.NewReader("new content")),
+			Size:     11,
+			SavePath: "uploads/1/file.bin",
+			Mode:     fsctx.Overwrite,
+		})
+		asserts.NoError(err)
+		info, err := handler.Client.Meta(context.Background(), "uploads/1/file.bin")
+		asserts.NoError(err)
+		asserts.EqualValues(11, info.Size)
+	}
+
+	// 空文件
+	{
+		err := handler.Put(context.Background(), &fsctx.FileStream{
+			File:     io.NopCloser(strings.NewReader("")),
+			SavePath: "uploads/1/empty.txt",
+		})
+		asserts.NoError(err)
+		info, err := handler.Client.Meta(context.Background(), "uploads/1/empty.txt")
+		asserts.NoError(err)
+		asserts.EqualValues(0, info.Size)
+	}
+
+	// 读取文件，支持 Seek
+	{
+		rs, err := handler.Get(context.Background(), "uploads/1/file.bin")
+		asserts.NoError(err)
+		size, err := rs.Seek(0, io.SeekEnd)
+		asserts.NoError(err)
+		asserts.EqualValues(11, size)
+		_, err = rs.Seek(4, io.SeekStart)
+		asserts.NoError(err)
+		res, err := io.ReadAll(rs)
+		asserts.NoError(err)
+		asserts.Equal("content", string(res))
+		asserts.NoError(rs.Close())
+	}
+
+	// 文件不存在
+	{
+		rs, err := handler.Get(context.Background(), "uploads/1/not_exist.bin")
+		asserts.ErrorIs(err, ErrObjectNotFound)
+		asserts.Nil(rs)
+	}
+}
+
+func TestDriver_Delete(t *testing.T) {
+	asserts := assert.New(t)
+	d := newFakeDrive()
+	defer d.server.Close()
+	handler := newTestDriver(d)
+
+	folder := d.add(rootFolderID, "dir", folderMimeType, nil)
+	d.add(folder.ID, "a.txt", "text/plain", []byte("a"))
+	d.add(folder.ID, "b'c.txt", "text/plain", []byte("b"))
+
+	failed, err := handler.Delete(context.Background(), []string{"dir/a.txt", "dir/b'c.txt", "dir/not_exist.txt"})
+	asserts.NoError(err)
+	asserts.Empty(failed)
+	asserts.Nil(d.find(folder.ID, "a.txt"))
+	asserts.Nil(d.find(folder.ID, "b'c.txt"))
+
+	// 缓存的路径失效后重新查找
+	d.add(folder.ID, "a.txt", "text/plain", []byte("new"))
+	rs, err := handler.Get(context.Background(), "dir/a.txt")
+	asserts.NoError(err)
+	res, _ := io.ReadAll(rs)
+	asserts.Equal("new", string(res))
+}
+
+func TestDriver_List(t *testing.T) {
+	asserts := assert.New(t)
+	d := newFakeDrive()
+	defer d.server.Close()
+	handler := newTestDriver(d)
+
+	root := d.add(rootFolderID, "root", folderMimeType, nil)
+	sub := d.add(root.ID, "sub", folderMimeType, nil)
+	d.add(root.ID, "1.txt", "text/plain", []byte("1"))
+	d.add(sub.ID, "2.txt", "text/plain", []byte("22"))
+	d.add(sub.ID, "doc", "application/vnd.google-apps.document", nil)
+
+	// 非递归
+	{
+		res, err := handler.List(context.Background(), "/root", false)
+		asserts.NoError(err)
+		asserts.Len(res, 2)
+	}
+
+	// 递归
+	{
+		res, err := handler.List(context.Background(), "/root", true)
+		asserts.NoError(err)
+		asserts.Len(res, 3)
+		paths := make(map[string]uint64)
+		for _, obj := range res {
+			paths[obj.RelativePath] = obj.Size
+		}
+		asserts.Contains(paths, "sub")
+		asserts.EqualValues(1, paths["1.txt"])
+		asserts.EqualValues(2, paths["sub/2.txt"])
+	}
+
+	// 目录不存在
+	{
+		res, err := handler.List(context.Background(), "/not_exist", true)
+		asserts.Error(err)
+		asserts.Nil(res)
+	}
+}
+
+func TestDriver_Thumb(t *testing.T) {
+	asserts := assert.New(t)
+	d := newFakeDrive()
+	defer d.server.Close()
+	handler := newTestDriver(d)
+	img := d.add(rootFolderID, "1.png", "image/png", []byte("png"))
+	d.add(rootFolderID, "1.txt", "text/plain", []byte("txt"))
+	ctx := context.WithValue(context.Background(), fsctx.ThumbSizeCtx, [2]uint{400, 300})
+
+	// 未指定尺寸
+	{
+		res, err := handler.Thumb(context.Background(), &model.File{SourceName: "1.png"})
+		asserts.Error(err)
+		asserts.Nil(res)
+	}
+
+	// 成功
+	{
+		res, err := handler.Thumb(ctx, &model.File{SourceName: "1.png"})
+		asserts.NoError(err)
+		asserts.False(res.Redirect)
+		content, _ := io.ReadAll(res.Content)
+		asserts.Equal("thumb:"+img.ID+"=s400", string(content))
+	}
+
+	// 不支持缩略图
+	{
+		res, err := handler.Thumb(ctx, &model.File{SourceName: "1.txt"})
+		asserts.ErrorIs(err, driver.ErrorThumbNotSupported)
+		asserts.Nil(res)
+	}
+}
+
+func TestDriver_Source(t *testing.T) {
+	asserts := assert.New(t)
+	handler := Driver{Policy: &model.Policy{}}
+	auth.General = auth.HMACAuth{SecretKey: []byte("test")}
+
+	// 缺少文件上下文
+	{
+		res, err := handler.Source(context.Background(), "1.txt", 10, false, 0)
+		asserts.Error(err)
+		asserts.Empty(res)
+	}
+
+	// 下载
+	{
+		ctx := context.WithValue(context.Background(), fsctx.FileModelCtx, model.File{Name: "1.txt"})
+		res, err := handler.Source(ctx, "1.txt", 10, true, 0)
+		asserts.NoError(err)
+		asserts.Contains(res, "/api/v3/file/download/")
+	}
+
+	// 预览
+	{
+		ctx := context.WithValue(context.Background(), fsctx.FileModelCtx, model.File{Name: "1.txt"})
+		res, err := handler.Source(ctx, "1.txt", 10, false, 0)
+		asserts.NoError(err)
+		asserts.Contains(res, "/api/v3/file/get/0/1.txt")
+	}
+}
+
+func TestDriver_TokenAndCancel(t *testing.T) {
+	asserts := assert.New(t)
+	d := newFakeDrive()
+	defer d.server.Close()
+	handler := newTestDriver(d)
+	cache.Set("setting_siteURL", "http://test.cloudreve.org", 0)
+
+	session := &serializer.UploadSession{Key: "TestGoogleDriveToken"}
+	res, err := handler.Token(context.Background(), 10, session, &fsctx.FileStream{
+		Size:     10,
+		SavePath: "uploads/direct.txt",
+	})
+	asserts.NoError(err)
+	asserts.Len(res.UploadURLs, 1)
+	asserts.Equal(session.UploadURL, res.UploadURLs[0])
+	asserts.Len(d.sessions, 1)
+
+	asserts.NoError(handler.CancelToken(context.Background(), session))
+	asserts.Len(d.sessions, 0)
+
+	// 已存在文件，不覆盖
+	asserts.NoError(handler.Put(context.Background(), &fsctx.FileStream{
+		File:     io.NopCloser(strings.NewReader("existed")),
+		Size:     7,
+		SavePath: "uploads/existed.txt",
+	}))
+	_, err = handler.Token(context.Background(), 10, session, &fsctx.FileStream{
+		Size:     10,
+		SavePath: "uploads/existed.txt",
+	})
+	asserts.ErrorIs(err, ErrFileExisted)
+
+	// 覆盖已有文件
+	res, err = handler.Token(context.Background(), 10, session, &fsctx.FileStream{
+		Size:     10,
+		SavePath: "uploads/existed.txt",
+		Mode:     fsctx.Overwrite,
+	})
+	asserts.NoError(err)
+	asserts.Equal(session.UploadURL, res.UploadURLs[0])
+	asserts.NoError(handler.CancelToken(context.Background(), session))
+}
@@ -116,7 +116,7 @@ func (client *Client) UpdateCredential(ctx context.Context, isSlave bool) error
 	// 获取新的凭证
 	if client.Credential == nil || client.Credential.RefreshToken == "" {
 		// 无有效的RefreshToken
-		util.Log().Error("Failed to refresh credential for policy %q, please login your Google account again.", client.Policy.Name)
+		util.Log().WithContext(ctx).Error("Failed to refresh credential for policy %q, please login your Google account again.", client.Policy.Name)
 		return ErrInvalidRefreshToken
 	}
 
@@ -1,6 +1,10 @@
 package googledrive
 
-import "encoding/gob"
+import (
+	"encoding/gob"
+	"strings"
+	"time"
+)
 
 // RespError 接口返回错误
 type RespError struct {
@@ -9,7 +13,15 @@ type RespError struct {
 
 // APIError 接口返回的错误内容
 type APIError struct {
-	Code    string `json:"code"`
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Errors  []APIErrorEntry `json:"errors"`
+}
+
+// APIErrorEntry 错误详情
+type APIErrorEntry struct {
+	Domain  string `json:"domain"`
+	Reason  string `json:"reason"`
 	Message string `json:"message"`
 }
 
@@ -38,6 +50,40 @@ func (err OAuthError) Error() string {
 	return err.ErrorDescription
 }
 
+// FileInfo 文件元信息
+type FileInfo struct {
+	ID                 string     `json:"id"`
+	Name               string     `json:"name"`
+	MimeType           string     `json:"mimeType"`
+	Size               uint64     `json:"size,string"`
+	Parents            []string   `json:"parents"`
+	ModifiedTime       time.Time  `json:"modifiedTime"`
+	ThumbnailLink      string     `json:"thumbnailLink"`
+	ImageMediaMetadata *imageInfo `json:"imageMediaMetadata"`
+	Md5Checksum        string     `json:"md5Checksum"`
+}
+
+type imageInfo struct {
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// IsDir 返回此对象是否为目录
+func (info *FileInfo) IsDir() bool {
+	return info.MimeType == folderMimeType
+}
+
+// IsNativeDocument 返回此对象是否为 Google 文档等无法直接下载的原生格式
+func (info *FileInfo) IsNativeDocument() bool {
+	return !info.IsDir() && strings.HasPrefix(info.MimeType, nativeMimeTypePrefix)
+}
+
+// ListResponse 列取子项目响应
+type ListResponse struct {
+	NextPageToken string     `json:"nextPageToken"`
+	Files         []FileInfo `json:"files"`
+}
+
 func init() {
 	gob.Register(Credential{})
 }
@@ -45,7 +45,7 @@ func (handler Driver) List(ctx context.Context, path string, recursive bool) ([]
 			}
 
 			if err != nil {
-				util.Log().Warning("Failed to walk folder %q: %s", path, err)
+				util.Log().WithContext(ctx).Warning("Failed to walk folder %q: %s", path, err)
 				return filepath.SkipDir
 			}
 
@@ -80,7 +80,7 @@ func (handler Driver) Get(ctx context.Context, path string) (response.RSCloser,
 	// 打开文件
 	file, err := os.Open(util.RelativePath(path))
 	if err != nil {
-		util.Log().Debug("Failed to open file: %s", err)
+		util.Log().WithContext(ctx).Debug("Failed to open file: %s", err)
 		return nil, err
 	}
 
@@ -96,7 +96,7 @@ func (handler Driver) Put(ctx context.Context, file fsctx.FileHeader) error {
 	// 如果非 Overwrite，则检查是否有重名冲突
 	if fileInfo.Mode&fsctx.Overwrite != fsctx.Overwrite {
 		if util.Exists(dst) {
-			util.Log().Warning("File with the same name existed or unavailable: %s", dst)
+			util.Log().WithContext(ctx).Warning("File with the same name existed or unavailable: %s", dst)
 			return errors.New("file with the same name existed or unavailable")
 		}
 	}
@@ -106,7 +106,7 @@ func (handler Driver) Put(ctx context.Context, file fsctx.FileHeader) error {
 	if !util.Exists(basePath) {
 		err := os.MkdirAll(basePath, Perm)
 		if err != nil {
-			util.Log().Warning("Failed to create directory: %s", err)
+			util.Log().WithContext(ctx).Warning("Failed to create directory: %s", err)
 			return err
 		}
 	}
@@ -125,7 +125,7 @@ func (handler Driver) Put(ctx context.Context, file fsctx.FileHeader) error {
 
 	out, err = os.OpenFile(dst, openMode, Perm)
 	if err != nil {
-		util.Log().Warning("Failed to open or create file: %s", err)
+		util.Log().WithContext(ctx).Warning("Failed to open or create file: %s", err)
 		return err
 	}
 	defer out.Close()
@@ -133,7 +133,7 @@ func (handler Driver) Put(ctx context.Context, file fsctx.FileHeader) error {
 	if fileInfo.Mode&fsctx.Append == fsctx.Append {
 		stat, err := out.Stat()
 		if err != nil {
-			util.Log().Warning("Failed to read file info: %s", err)
+			util.Log().WithContext(ctx).Warning("Failed to read file info: %s", err)
 			return err
 		}
 
@@ -148,7 +148,7 @@ func (handler Driver) Put(ctx context.Context, file fsctx.FileHeader) error {
 			out, err = os.OpenFile(dst, openMode, Perm)
 			defer out.Close()
 			if err != nil {
-				util.Log().Warning("Failed to create or open file: %s", err)
+				util.Log().WithContext(ctx).Warning("Failed to create or open file: %s", err)
 				return err
 			}
 		}
@@ -160,10 +160,10 @@ func (handler Driver) Put(ctx context.Context, file fsctx.FileHeader) error {
 }
 
 func (handler Driver) Truncate(ctx context.Context, src string, size uint64) error {
-	util.Log().Warning("Truncate file %q to [%d].", src, size)
+	util.Log().WithContext(ctx).Warning("Truncate file %q to [%d].", src, size)
 	out, err := os.OpenFile(src, os.O_WRONLY, Perm)
 	if err != nil {
-		util.Log().Warning("Failed to open file: %s", err)
+		util.Log().WithContext(ctx).Warning("Failed to open file: %s", err)
 		return err
 	}
 
@@ -182,7 +182,7 @@ func (handler Driver) Delete(ctx context.Context, files []string) ([]string, err
 		if util.Exists(filePath) {
 			err := os.Remove(filePath)
 			if err != nil {
-				util.Log().Warning("Failed to delete file: %s", err)
+				util.Log().WithContext(ctx).Warning("Failed to delete file: %s", err)
 				retErr = err
 				deleteFailed = append(deleteFailed, value)
 			}
@@ -101,7 +101,7 @@ func (c *remoteClient) Upload(ctx context.Context, file fsctx.FileHeader) error
 	for chunks.Next() {
 		if err := chunks.Process(uploadFunc); err != nil {
 			if err := c.DeleteUploadSession(ctx, session.Key); err != nil {
-				util.Log().Warning("failed to delete upload session: %s", err)
+				util.Log().WithContext(ctx).Warning("failed to delete upload session: %s", err)
 			}
 
 			return fmt.Errorf("failed to upload chunk #%d: %w", chunks.Index(), err)
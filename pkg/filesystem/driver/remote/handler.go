@@ -68,6 +68,7 @@ func (handler *Driver) List(ctx context.Context, path string, recursive bool) ([
 		handler.getAPIUrl("list"),
 		bodyReader,
 		request.WithCredential(handler.AuthInstance, int64(signTTL)),
+		request.WithContext(ctx),
 		request.WithMasterMeta(),
 	).CheckHTTPResponse(200).DecodeResponse()
 	if err != nil {
@@ -179,6 +180,7 @@ func (handler *Driver) Delete(ctx context.Context, files []string) ([]string, er
 		handler.getAPIUrl("delete"),
 		bodyReader,
 		request.WithCredential(handler.AuthInstance, int64(signTTL)),
+		request.WithContext(ctx),
 		request.WithMasterMeta(),
 		request.WithSlaveMeta(handler.Policy.AccessKey),
 	).CheckHTTPResponse(200).GetResponse()
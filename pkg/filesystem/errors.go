@@ -22,5 +22,13 @@ var (
 	ErrIO                       = serializer.NewError(serializer.CodeIOFailed, "Failed to read file data", nil)
 	ErrDBListObjects            = serializer.NewError(serializer.CodeDBError, "Failed to list object records", nil)
 	ErrDBDeleteObjects          = serializer.NewError(serializer.CodeDBError, "Failed to delete object records", nil)
+	ErrDBTrashObjects           = serializer.NewError(serializer.CodeDBError, "Failed to update trash records", nil)
+	ErrDBVersionObjects         = serializer.NewError(serializer.CodeDBError, "Failed to update file versions", nil)
+	ErrDBMigrateObjects         = serializer.NewError(serializer.CodeDBError, "Failed to update migrated objects", nil)
+	ErrMigrateVerifyFailed      = serializer.NewError(serializer.CodeIOFailed, "Migrated file does not match the original", nil)
+	ErrUploadChallengeExpired   = serializer.NewError(serializer.CodeUploadSessionExpired, "Upload challenge expired", nil)
+	ErrInvalidUploadProof       = serializer.NewError(serializer.CodeInvalidUploadProof, "Invalid upload proof", nil)
+	ErrContentSearchDisabled    = serializer.NewError(serializer.CodeFeatureNotEnabled, "Content search is not enabled", nil)
+	ErrInvalidQuery             = serializer.NewError(serializer.CodeParamErr, "Invalid search query", nil)
 	ErrOneObjectOnly            = serializer.ParamErr("You can only copy one object at the same time", nil)
 )
@@ -2,14 +2,17 @@ package filesystem
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"path"
 
 	model "github.com/cloudreve/Cloudreve/v3/models"
 	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
 	"github.com/cloudreve/Cloudreve/v3/pkg/conf"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/response"
+	"github.com/cloudreve/Cloudreve/v3/pkg/search/query"
 	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
 	"github.com/cloudreve/Cloudreve/v3/pkg/util"
 	"github.com/juju/ratelimit"
@@ -64,15 +67,28 @@ func (fs *FileSystem) AddFile(ctx context.Context, parent *model.Folder, file fs
 		UploadSessionID:    uploadInfo.UploadSessionID,
 	}
 
-	err = newFile.Create()
+	// 内容摘要已知时，关联至存储策略中相同内容的源文件
+	var duplicate string
+	if uploadInfo.Hash != "" && uploadInfo.UploadSessionID == nil {
+		duplicate, err = newFile.CreateWithBlob(uploadInfo.Hash)
+	} else {
+		err = newFile.Create()
+	}
 
 	if err != nil {
 		if err := fs.Trigger(ctx, "AfterValidateFailed", file); err != nil {
-			util.Log().Debug("AfterValidateFailed hook execution failed: %s", err)
+			util.Log().WithContext(ctx).Debug("AfterValidateFailed hook execution failed: %s", err)
 		}
 		return nil, ErrFileExisted.WithError(err)
 	}
 
+	// 删除重复保存的源文件
+	if duplicate != "" {
+		if _, err := fs.Handler.Delete(ctx, []string{duplicate}); err != nil {
+			util.Log().WithContext(ctx).Warning("Failed to delete duplicated file %q: %s", duplicate, err)
+		}
+	}
+
 	fs.User.Storage += newFile.Size
 	return &newFile, nil
 }
@@ -205,7 +221,7 @@ func (fs *FileSystem) deleteGroupedFile(ctx context.Context, files map[uint][]*m
 		// 取消上传会话
 		for _, upSession := range uploadSessions {
 			if err := fs.Handler.CancelToken(ctx, upSession); err != nil {
-				util.Log().Warning("Failed to cancel upload session for %q: %s", upSession.Name, err)
+				util.Log().WithContext(ctx).Warning("Failed to cancel upload session for %q: %s", upSession.Name, err)
 			}
 
 			cache.Deletes([]string{upSession.Key}, UploadSessionCachePrefix)
@@ -366,6 +382,45 @@ func (fs *FileSystem) resetPolicyToFirstFile(ctx context.Context) error {
 
 // Search 搜索文件
 func (fs *FileSystem) Search(ctx context.Context, keywords ...interface{}) ([]serializer.Object, error) {
+	parents, err := fs.searchParents()
+	if err != nil {
+		return nil, err
+	}
+
+	files, _ := model.GetFilesByKeywords(fs.User.ID, parents, keywords...)
+	fs.SetTargetFile(&files)
+
+	return fs.listObjects(ctx, "/", files, nil, nil), nil
+}
+
+// SearchQuery 根据查询条件搜索文件，返回当前页的文件和结果总数。
+// 查询中的路径相对于文件系统根目录；在分享中搜索时不能使用分类标签
+func (fs *FileSystem) SearchQuery(ctx context.Context, expr query.Expr, opts model.FileSearchOptions) ([]serializer.Object, int, error) {
+	parents, err := fs.searchParents()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	opts.Parents = parents
+	opts.ResolvePath = fs.resolveSearchPath
+	if ctx.Value(fsctx.ShareKeyCtx) == nil {
+		opts.ResolveTag = fs.resolveSearchTag
+	}
+
+	files, total, err := model.SearchFiles(fs.User.ID, expr, &opts)
+	if err != nil {
+		if errors.Is(err, query.ErrInvalid) {
+			return nil, 0, ErrInvalidQuery.WithError(err)
+		}
+		return nil, 0, ErrDBListObjects.WithError(err)
+	}
+
+	fs.SetTargetFile(&files)
+	return fs.listObjects(ctx, "/", files, nil, nil), total, nil
+}
+
+// searchParents 如果限定了根目录，返回根目录及其所有子目录的ID
+func (fs *FileSystem) searchParents() ([]uint, error) {
 	parents := make([]uint, 0)
 
 	// 如果限定了根目录，则只在这个根目录下搜索。
@@ -380,8 +435,33 @@ func (fs *FileSystem) Search(ctx context.Context, keywords ...interface{}) ([]se
 		}
 	}
 
-	files, _ := model.GetFilesByKeywords(fs.User.ID, parents, keywords...)
-	fs.SetTargetFile(&files)
+	return parents, nil
+}
 
-	return fs.listObjects(ctx, "/", files, nil, nil), nil
+// resolveSearchPath 返回路径对应目录及其所有子目录的ID，目录不存在时返回空列表
+func (fs *FileSystem) resolveSearchPath(dir string) ([]uint, error) {
+	exist, folder := fs.IsPathExist(path.Clean("/" + dir))
+	if !exist {
+		return nil, nil
+	}
+
+	folders, err := model.GetRecursiveChildFolder([]uint{folder.ID}, fs.User.ID, true)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]uint, len(folders))
+	for i, folder := range folders {
+		ids[i] = folder.ID
+	}
+	return ids, nil
+}
+
+// resolveSearchTag 返回用户分类标签对应的查询
+func (fs *FileSystem) resolveSearchTag(name string) (query.Expr, error) {
+	tag, err := model.GetFilterTagByName(name, fs.User.ID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: tag %q not found", query.ErrBadValue, name)
+	}
+	return tag.Query()
 }
@@ -11,6 +11,7 @@ import (
 	"github.com/cloudreve/Cloudreve/v3/pkg/auth"
 	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/search/query"
 	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
 	"github.com/cloudreve/Cloudreve/v3/pkg/util"
 	"github.com/jinzhu/gorm"
@@ -667,3 +668,46 @@ func TestFileSystem_Search(t *testing.T) {
 	asserts.NoError(err)
 	asserts.Len(res, 1)
 }
+
+func TestFileSystem_SearchQuery(t *testing.T) {
+	asserts := assert.New(t)
+	ctx := context.Background()
+	fs := &FileSystem{
+		User: &model.User{},
+	}
+	fs.User.ID = 1
+
+	// 成功
+	{
+		expr, _ := query.Parse("ext:pdf tag:Docs")
+		mock.ExpectQuery("SELECT(.+)tags(.+)").WithArgs(1, model.FileTagType, "Docs").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "expression"}).AddRow(1, "%.doc\n%.docx"))
+		mock.ExpectQuery("SELECT count(.+)").WithArgs(1, "%.pdf", "%.doc", "%.docx").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+		mock.ExpectQuery("SELECT(.+)").WithArgs(1, "%.pdf", "%.doc", "%.docx").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		res, total, err := fs.SearchQuery(ctx, expr, model.FileSearchOptions{})
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.NoError(err)
+		asserts.Equal(1, total)
+		asserts.Len(res, 1)
+	}
+
+	// 分享中不能使用分类标签
+	{
+		expr, _ := query.Parse("tag:Docs")
+		shareCtx := context.WithValue(ctx, fsctx.ShareKeyCtx, "share")
+		_, _, err := fs.SearchQuery(shareCtx, expr, model.FileSearchOptions{})
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.ErrorIs(err, ErrInvalidQuery)
+	}
+
+	// 分类标签不存在
+	{
+		expr, _ := query.Parse("tag:Docs")
+		mock.ExpectQuery("SELECT(.+)tags(.+)").WillReturnError(errors.New("not found"))
+		_, _, err := fs.SearchQuery(ctx, expr, model.FileSearchOptions{})
+		asserts.NoError(mock.ExpectationsWereMet())
+		asserts.ErrorIs(err, ErrInvalidQuery)
+	}
+}
@@ -233,7 +233,7 @@ func (fs *FileSystem) SwitchToShadowHandler(master cluster.Node, masterURL, mast
 		fs.Policy.AccessKey = fmt.Sprintf("%d", master.ID())
 		fs.Policy.SecretKey = master.DBModel().MasterKey
 		fs.DispatchHandler()
-	case "onedrive":
+	case "onedrive", "googledrive":
 		fs.Policy.MasterID = masterID
 	}
 
@@ -290,3 +290,11 @@ func (fs *FileSystem) CleanTargets() {
 	fs.FileTarget = fs.FileTarget[:0]
 	fs.DirTarget = fs.DirTarget[:0]
 }
+
+// PolicyType 返回当前存储策略类型，未设置存储策略时（如从机）均直接操作本机存储
+func (fs *FileSystem) PolicyType() string {
+	if fs.Policy == nil {
+		return "local"
+	}
+	return fs.Policy.Type
+}
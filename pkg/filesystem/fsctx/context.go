@@ -41,4 +41,6 @@ const (
 	WebDAVCtx
 	// WebDAV反代Url
 	WebDAVProxyUrlCtx
+	// UploadShareCtx 发起上传的收集文件分享ID
+	UploadShareCtx
 )
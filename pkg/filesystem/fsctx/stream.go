@@ -1,8 +1,12 @@
 package fsctx
 
 import (
+	"crypto/sha256"
+	"encoding"
+	"encoding/hex"
 	"errors"
 	"github.com/HFO4/aliyun-oss-go-sdk/oss"
+	"hash"
 	"io"
 	"time"
 )
@@ -29,6 +33,7 @@ type UploadTaskInfo struct {
 	AppendStart     uint64
 	Model           interface{}
 	Src             string
+	Hash            string // 内容的 SHA-256 摘要，未知时为空
 }
 
 // Get mimetype of uploaded file, if it's not defined, detect it from file name
@@ -67,16 +72,67 @@ type FileStream struct {
 	AppendStart     uint64
 	Model           interface{}
 	Src             string
+	Hash            string // 已校验的内容摘要，用于秒传
+
+	hasher  hash.Hash
+	hashed  uint64
+	resumed bool // 摘要从分片上传的中间状态继续计算，不是本次文件流内容的摘要
 }
 
 func (file *FileStream) Read(p []byte) (n int, err error) {
 	if file.File != nil {
-		return file.File.Read(p)
+		n, err = file.File.Read(p)
+		if file.hasher != nil {
+			file.hasher.Write(p[:n])
+			file.hashed += uint64(n)
+		}
+		return n, err
 	}
 
 	return 0, io.EOF
 }
 
+// ComputeHash 在读取文件流的同时计算内容的 SHA-256 摘要
+func (file *FileStream) ComputeHash() {
+	file.hasher = sha256.New()
+	file.hashed = 0
+	file.resumed = false
+}
+
+// ResumeHash 从分片上传已保存的摘要状态继续计算内容摘要，state 为空时从头计算
+func (file *FileStream) ResumeHash(state []byte) error {
+	hasher := sha256.New()
+	if len(state) > 0 {
+		if err := hasher.(encoding.BinaryUnmarshaler).UnmarshalBinary(state); err != nil {
+			return err
+		}
+	}
+
+	file.hasher = hasher
+	file.hashed = 0
+	file.resumed = true
+	return nil
+}
+
+// HashState 返回完整读取文件流后的摘要状态，供后续分片继续计算
+func (file *FileStream) HashState() ([]byte, bool) {
+	if file.hasher == nil || file.hashed != file.Size {
+		return nil, false
+	}
+
+	state, err := file.hasher.(encoding.BinaryMarshaler).MarshalBinary()
+	return state, err == nil
+}
+
+// contentHash 返回已完整读取的内容摘要，未读取完整内容时返回已校验的摘要
+func (file *FileStream) contentHash() string {
+	if file.hasher == nil || file.resumed || file.hashed != file.Size {
+		return file.Hash
+	}
+
+	return hex.EncodeToString(file.hasher.Sum(nil))
+}
+
 func (file *FileStream) Close() error {
 	if file.File != nil {
 		return file.File.Close()
@@ -87,6 +143,14 @@ func (file *FileStream) Close() error {
 
 func (file *FileStream) Seek(offset int64, whence int) (int64, error) {
 	if file.Seekable() {
+		// 回到开头时重新计算摘要，其他跳转无法得到完整摘要
+		if file.hasher != nil {
+			if offset == 0 && whence == io.SeekStart && !file.resumed {
+				file.ComputeHash()
+			} else {
+				file.hasher = nil
+			}
+		}
 		return file.Seeker.Seek(offset, whence)
 	}
 
@@ -111,6 +175,7 @@ func (file *FileStream) Info() *UploadTaskInfo {
 		AppendStart:     file.AppendStart,
 		Model:           file.Model,
 		Src:             file.Src,
+		Hash:            file.contentHash(),
 	}
 }
 
@@ -1,6 +1,9 @@
 package fsctx
 
 import (
+	"crypto/sha256"
+	"encoding"
+	"encoding/hex"
 	model "github.com/cloudreve/Cloudreve/v3/models"
 	"github.com/stretchr/testify/assert"
 	"io"
@@ -76,3 +79,59 @@ func TestFileStream_Info(t *testing.T) {
 	file.SetModel(&model.File{})
 	a.NotNil(file.Info().Model)
 }
+
+func TestFileStream_ComputeHash(t *testing.T) {
+	asserts := assert.New(t)
+	f, _ := os.CreateTemp("", "*")
+	defer func() {
+		f.Close()
+		os.Remove(f.Name())
+	}()
+	f.WriteString("123")
+
+	// 未完整读取
+	file := FileStream{File: f, Seeker: f, Size: 3}
+	file.ComputeHash()
+	file.Seek(0, io.SeekStart)
+	p := make([]byte, 2)
+	file.Read(p)
+	asserts.Empty(file.Info().Hash)
+
+	// 回到开头后完整读取
+	file.Seek(0, io.SeekStart)
+	ioutil.ReadAll(&file)
+	asserts.Equal("a665a45920422f9d417e4867efdc4fb8a04a1f3fff1fa07e998e86f7f7a27ae3", file.Info().Hash)
+
+	// 跳转后摘要不可用
+	file.Seek(1, io.SeekStart)
+	asserts.Empty(file.Info().Hash)
+}
+
+func TestFileStream_ResumeHash(t *testing.T) {
+	asserts := assert.New(t)
+
+	// 状态无效
+	file := FileStream{File: ioutil.NopCloser(strings.NewReader("12")), Size: 2}
+	asserts.Error(file.ResumeHash([]byte("invalid")))
+
+	// 首个分片
+	asserts.NoError(file.ResumeHash(nil))
+	_, ok := file.HashState()
+	asserts.False(ok)
+	ioutil.ReadAll(&file)
+	state, ok := file.HashState()
+	asserts.True(ok)
+	asserts.Empty(file.Info().Hash)
+
+	// 后续分片从已保存的状态继续计算
+	file = FileStream{File: ioutil.NopCloser(strings.NewReader("3")), Size: 1}
+	asserts.NoError(file.ResumeHash(state))
+	ioutil.ReadAll(&file)
+	state, ok = file.HashState()
+	asserts.True(ok)
+	asserts.Empty(file.Info().Hash)
+
+	hasher := sha256.New()
+	asserts.NoError(hasher.(encoding.BinaryUnmarshaler).UnmarshalBinary(state))
+	asserts.Equal("a665a45920422f9d417e4867efdc4fb8a04a1f3fff1fa07e998e86f7f7a27ae3", hex.EncodeToString(hasher.Sum(nil)))
+}
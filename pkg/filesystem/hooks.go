@@ -5,12 +5,17 @@ import (
 	model "github.com/cloudreve/Cloudreve/v3/models"
 	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
 	"github.com/cloudreve/Cloudreve/v3/pkg/cluster"
+	"github.com/cloudreve/Cloudreve/v3/pkg/email"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/local"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/hashid"
+	"github.com/cloudreve/Cloudreve/v3/pkg/metrics"
 	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
 	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+	"github.com/cloudreve/Cloudreve/v3/pkg/webhook"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
@@ -47,7 +52,7 @@ func (fs *FileSystem) Trigger(ctx context.Context, name string, file fsctx.FileH
 		for _, hook := range hooks {
 			err := hook(ctx, fs, file)
 			if err != nil {
-				util.Log().Warning("Failed to execute hook：%s", err)
+				util.Log().WithContext(ctx).Warning("Failed to execute hook：%s", err)
 				return err
 			}
 		}
@@ -115,7 +120,7 @@ func HookDeleteTempFile(ctx context.Context, fs *FileSystem, file fsctx.FileHead
 	// 删除临时文件
 	_, err := fs.Handler.Delete(ctx, []string{file.Info().SavePath})
 	if err != nil {
-		util.Log().Warning("Failed to clean-up temp files: %s", err)
+		util.Log().WithContext(ctx).Warning("Failed to clean-up temp files: %s", err)
 	}
 
 	return nil
@@ -174,6 +179,8 @@ func GenericAfterUpdate(ctx context.Context, fs *FileSystem, newFile fsctx.FileH
 		return err
 	}
 
+	IndexContent(&originFile)
+	publishFile(ctx, webhook.EventFileOverwritten, &originFile, newFile.Info().VirtualPath)
 	return nil
 }
 
@@ -184,8 +191,10 @@ func SlaveAfterUpload(session *serializer.UploadSession) Hook {
 			return nil
 		}
 
-		// 发送回调请求
-		callbackBody := serializer.UploadCallback{}
+		// 发送回调请求，附带分片上传过程中计算的内容摘要
+		callbackBody := serializer.UploadCallback{
+			Hash: ChunkHash(session.Key, session.Size),
+		}
 		return cluster.RemoteCallback(session.Callback, callbackBody)
 	}
 }
@@ -219,6 +228,12 @@ func GenericAfterUpload(ctx context.Context, fs *FileSystem, fileHeader fsctx.Fi
 	}
 	fileHeader.SetModel(file)
 
+	// 上传会话的占位文件在上传完成后再索引及发布事件
+	IndexContent(file)
+	if file.UploadSessionID == nil {
+		publishFile(ctx, webhook.EventFileUploaded, file, fileInfo.VirtualPath)
+	}
+
 	return nil
 }
 
@@ -260,7 +275,52 @@ func HookPopPlaceholderToFile(picInfo string) Hook {
 	return func(ctx context.Context, fs *FileSystem, fileHeader fsctx.FileHeader) error {
 		fileInfo := fileHeader.Info()
 		fileModel := fileInfo.Model.(*model.File)
-		return fileModel.PopChunkToFile(fileInfo.LastModified, picInfo)
+		if err := fileModel.PopChunkToFile(fileInfo.LastModified, picInfo); err != nil {
+			return err
+		}
+
+		publishFile(ctx, webhook.EventFileUploaded, fileModel, fileInfo.VirtualPath)
+		return nil
+	}
+}
+
+// HookShareUploaded 访客通过收集文件分享上传完成后通知分享创建者
+func HookShareUploaded(shareID uint) Hook {
+	return func(ctx context.Context, fs *FileSystem, fileHeader fsctx.FileHeader) error {
+		share, err := model.GetShareByID(shareID)
+		if err != nil {
+			util.Log().WithContext(ctx).Warning("Failed to find share #%d of uploaded file: %s", shareID, err)
+			return nil
+		}
+
+		// 上传完成后才计入分享的上传配额
+		fileInfo := fileHeader.Info()
+		if err := share.CountUpload(fileInfo.Size); err != nil {
+			util.Log().WithContext(ctx).Warning("Failed to count upload of share #%d: %s", shareID, err)
+		}
+
+		shareKey := hashid.HashID(share.ID, hashid.ShareID)
+		webhook.Publish(ctx, webhook.EventShareUploaded, share.UserID, map[string]interface{}{
+			"id":          shareKey,
+			"source_name": share.SourceName,
+			"name":        fileInfo.FileName,
+			"size":        fileInfo.Size,
+			"path":        fileInfo.VirtualPath,
+		})
+
+		if share.UploadNotify {
+			owner := share.Creator()
+			sharePath, _ := url.Parse("/s/" + shareKey)
+			title, body := email.NewShareUploadEmail(owner.Nick, share.SourceName, fileInfo.FileName,
+				model.GetSiteURL().ResolveReference(sharePath).String())
+			go func() {
+				if err := email.Send(owner.Email, title, body); err != nil {
+					util.Log().Warning("Failed to send share upload notification to %q: %s", owner.Email, err)
+				}
+			}()
+		}
+
+		return nil
 	}
 }
 
@@ -268,6 +328,9 @@ func HookPopPlaceholderToFile(picInfo string) Hook {
 func HookDeleteUploadSession(id string) Hook {
 	return func(ctx context.Context, fs *FileSystem, fileHeader fsctx.FileHeader) error {
 		cache.Deletes([]string{id}, UploadSessionCachePrefix)
+		cache.Deletes([]string{id}, ChunkHashCachePrefix)
+		cache.Deletes([]string{id}, ChunkSeqCachePrefix)
+		metrics.ObserveUploadSession(fs.PolicyType(), metrics.SessionCompleted)
 		return nil
 	}
 }
@@ -669,6 +669,25 @@ func TestHookPopPlaceholderToFileBySuffix(t *testing.T) {
 	a.NoError(mock.ExpectationsWereMet())
 }
 
+func TestHookShareUploaded(t *testing.T) {
+	a := assert.New(t)
+	fs := &FileSystem{}
+	file := &fsctx.FileStream{Name: "1.txt", Size: 1}
+
+	// 分享不存在时忽略
+	mock.ExpectQuery("SELECT(.+)shares(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	a.NoError(HookShareUploaded(1)(context.Background(), fs, file))
+	a.NoError(mock.ExpectationsWereMet())
+
+	// 未开启通知，仍计入上传配额
+	mock.ExpectQuery("SELECT(.+)shares(.+)").WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "upload_notify"}).AddRow(1, 1, false))
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE(.+)shares(.+)uploaded_files(.+)").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	a.NoError(HookShareUploaded(1)(context.Background(), fs, file))
+	a.NoError(mock.ExpectationsWereMet())
+}
+
 func TestHookDeleteUploadSession(t *testing.T) {
 	a := assert.New(t)
 	fs := &FileSystem{}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"sync"
+	"sync/atomic"
 
 	"runtime"
 
@@ -85,6 +86,8 @@ var once sync.Once
 type Pool struct {
 	// 容量
 	worker chan int
+	// 等待空闲 Worker 的任务数
+	waiting int32
 }
 
 // Init 初始化任务池
@@ -102,9 +105,18 @@ func getThumbWorker() *Pool {
 	return thumbPool
 }
 func (pool *Pool) addWorker() {
+	atomic.AddInt32(&pool.waiting, 1)
 	pool.worker <- 1
+	atomic.AddInt32(&pool.waiting, -1)
 	util.Log().Debug("Worker added to thumbnails task queue.")
 }
+
+// ThumbWorkerStats 返回缩略图生成队列中运行中、等待中的任务数及 Worker 总数
+func ThumbWorkerStats() (running, waiting, max int) {
+	pool := getThumbWorker()
+	return len(pool.worker), int(atomic.LoadInt32(&pool.waiting)), cap(pool.worker)
+}
+
 func (pool *Pool) releaseWorker() {
 	util.Log().Debug("Worker released from thumbnails task queue.")
 	<-pool.worker
@@ -175,7 +187,7 @@ func (fs *FileSystem) generateThumbnail(ctx context.Context, file *model.File) e
 	}
 
 	if model.IsTrueVal(model.GetSettingByName("thumb_gc_after_gen")) {
-		util.Log().Debug("generateThumbnail runtime.GC")
+		util.Log().WithContext(ctx).Debug("generateThumbnail runtime.GC")
 		runtime.GC()
 	}
 
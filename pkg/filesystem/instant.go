@@ -0,0 +1,368 @@
+package filesystem
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math/rand"
+	"sync"
+	"time"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+	"github.com/gofrs/uuid"
+	"github.com/jinzhu/gorm"
+)
+
+/* ================
+	 秒传相关
+   ================
+*/
+
+const (
+	// InstantUploadCachePrefix 秒传挑战会话缓存前缀
+	InstantUploadCachePrefix = "instant_upload_"
+	// instantUploadProofSize 秒传时客户端需要校验的最大内容长度
+	instantUploadProofSize = 64 << 10
+	// instantUploadChallengeTTL 秒传挑战有效期，单位为秒
+	instantUploadChallengeTTL = 300
+	// ChunkHashCachePrefix 分片上传内容摘要状态缓存前缀
+	ChunkHashCachePrefix = "chunk_hash_"
+	// ChunkSeqCachePrefix 分片上传次数计数缓存前缀
+	ChunkSeqCachePrefix = "chunk_seq_"
+	// chunkHashTTL 分片上传内容摘要状态的有效期，单位为秒
+	chunkHashTTL = 86400
+	// blobIndexTimeout 计算单个文件内容摘要的超时时间
+	blobIndexTimeout = time.Hour
+	// blobIndexQueueSize 后台计算内容摘要的队列长度，队列已满时放弃计算
+	blobIndexQueueSize = 64
+	// blobIndexWorkers 后台同时计算内容摘要的文件数
+	blobIndexWorkers = 2
+)
+
+var (
+	blobIndexQueue = make(chan blobIndexJob, blobIndexQueueSize)
+	blobIndexOnce  sync.Once
+)
+
+// blobIndexJob 后台计算内容摘要的文件
+type blobIndexJob struct {
+	ID     uint
+	UserID uint
+}
+
+// chunkHashState 分片上传中已按顺序写入内容的摘要状态
+type chunkHashState struct {
+	State  []byte
+	Hashed uint64
+}
+
+func init() {
+	gob.Register(chunkHashState{})
+}
+
+// CreateUploadChallenge 根据客户端提供的内容摘要返回要求客户端证明持有文件内容的挑战。
+// 为避免泄露其他用户是否存储了相同内容，无论当前存储策略中是否存在相同内容都会返回挑战，
+// 仅在应答校验通过后才会完成秒传
+func (fs *FileSystem) CreateUploadChallenge(ctx context.Context, file *fsctx.FileStream, hash string) (*serializer.UploadCredential, error) {
+	if file.Size == 0 {
+		return nil, nil
+	}
+
+	var blobID uint
+	blob, err := model.GetBlobByHash(fs.Policy.ID, hash, file.Size)
+	if err == nil {
+		blobID = blob.ID
+	} else if !gorm.IsRecordNotFoundError(err) {
+		return nil, ErrDBListObjects.WithError(err)
+	}
+
+	// 随机选取需要校验的内容范围
+	length := uint64(instantUploadProofSize)
+	if length > file.Size {
+		length = file.Size
+	}
+	session := serializer.InstantUploadSession{
+		UID:      fs.User.ID,
+		PolicyID: fs.Policy.ID,
+		BlobID:   blobID,
+		Hash:     hash,
+		Size:     file.Size,
+		Offset:   uint64(rand.Int63n(int64(file.Size-length) + 1)),
+		Length:   length,
+		Nonce:    util.RandStringRunes(32),
+	}
+
+	challengeID := uuid.Must(uuid.NewV4()).String()
+	if err := cache.Set(InstantUploadCachePrefix+challengeID, session, instantUploadChallengeTTL); err != nil {
+		return nil, err
+	}
+
+	return &serializer.UploadCredential{
+		Expires: time.Now().Add(instantUploadChallengeTTL * time.Second).Unix(),
+		Challenge: &serializer.UploadChallenge{
+			ID:     challengeID,
+			Offset: session.Offset,
+			Length: session.Length,
+			Nonce:  session.Nonce,
+		},
+	}, nil
+}
+
+// InstantUpload 校验客户端对秒传挑战的应答，通过后直接创建引用已有内容的文件。
+// 不存在相同内容或应答错误时返回 nil，由客户端正常上传
+func (fs *FileSystem) InstantUpload(ctx context.Context, file *fsctx.FileStream, hash, challengeID, proof string) (*serializer.UploadCredential, error) {
+	sessionRaw, ok := cache.Get(InstantUploadCachePrefix + challengeID)
+	if !ok {
+		return nil, ErrUploadChallengeExpired
+	}
+
+	// 每个挑战只能应答一次
+	_ = cache.Deletes([]string{challengeID}, InstantUploadCachePrefix)
+
+	session := sessionRaw.(serializer.InstantUploadSession)
+	if session.UID != fs.User.ID || session.PolicyID != fs.Policy.ID || session.Hash != hash || session.Size != file.Size {
+		return nil, ErrInvalidUploadProof
+	}
+
+	if session.BlobID == 0 {
+		return nil, nil
+	}
+
+	blob, err := model.GetBlobByHash(session.PolicyID, session.Hash, session.Size)
+	if err != nil || blob.ID != session.BlobID {
+		return nil, nil
+	}
+
+	expected, err := fs.uploadProof(ctx, blob.SourceName, &session)
+	if err != nil {
+		return nil, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(proof)) != 1 {
+		return nil, nil
+	}
+
+	// 创建引用已有源文件的文件记录
+	file.Mode = fsctx.Nop
+	file.SavePath = blob.SourceName
+	file.Hash = *blob.Hash
+	fs.Use("BeforeUpload", HookValidateFile)
+	fs.Use("BeforeUpload", HookValidateCapacity)
+	fs.Use("AfterUpload", GenericAfterUpload)
+	if err := fs.Upload(ctx, file); err != nil {
+		return nil, err
+	}
+
+	return &serializer.UploadCredential{Completed: true}, nil
+}
+
+// uploadProof 读取源文件中挑战指定的内容，计算期望的应答
+func (fs *FileSystem) uploadProof(ctx context.Context, source string, session *serializer.InstantUploadSession) (string, error) {
+	content, err := fs.Handler.Get(ctx, source)
+	if err != nil {
+		return "", ErrIO.WithError(err)
+	}
+	defer content.Close()
+
+	if _, err := content.Seek(int64(session.Offset), io.SeekStart); err != nil {
+		return "", ErrIO.WithError(err)
+	}
+
+	h := sha256.New()
+	h.Write([]byte(session.Nonce))
+	if _, err := io.CopyN(h, content, int64(session.Length)); err != nil {
+		return "", ErrIO.WithError(err)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// HookResumeChunkHash 在分片上传的文件流中继续计算整个文件的内容摘要。每次分片上传都会占用一个序号，
+// 分片重传、乱序或并发上传时无法确定最终写入的内容，此后的分片不再计算摘要
+func HookResumeChunkHash(sessionID string, index int) Hook {
+	return func(ctx context.Context, fs *FileSystem, fileHeader fsctx.FileHeader) error {
+		file, ok := fileHeader.(*fsctx.FileStream)
+		if !ok {
+			return nil
+		}
+
+		seq, err := cache.IncrBy(ChunkSeqCachePrefix+sessionID, 1, chunkHashTTL)
+		if err != nil || seq != index+1 {
+			return nil
+		}
+
+		var state []byte
+		if index > 0 {
+			saved, ok := cache.Get(ChunkHashCachePrefix + sessionID)
+			if !ok || saved.(chunkHashState).Hashed != file.AppendStart {
+				return nil
+			}
+			state = saved.(chunkHashState).State
+		}
+
+		if err := file.ResumeHash(state); err != nil {
+			util.Log().WithContext(ctx).Warning("Failed to resume hash of upload session %q: %s", sessionID, err)
+		}
+
+		return nil
+	}
+}
+
+// HookSaveChunkHash 分片上传完成后保存内容摘要状态，供后续分片继续计算
+func HookSaveChunkHash(sessionID string) Hook {
+	return func(ctx context.Context, fs *FileSystem, fileHeader fsctx.FileHeader) error {
+		file, ok := fileHeader.(*fsctx.FileStream)
+		if !ok {
+			return nil
+		}
+
+		state, ok := file.HashState()
+		if !ok {
+			return nil
+		}
+
+		saved := chunkHashState{State: state, Hashed: file.AppendStart + file.Size}
+		if err := cache.Set(ChunkHashCachePrefix+sessionID, saved, chunkHashTTL); err != nil {
+			util.Log().WithContext(ctx).Warning("Failed to save hash of upload session %q: %s", sessionID, err)
+		}
+
+		return nil
+	}
+}
+
+// ChunkHash 返回分片上传完成后整个文件的内容摘要，未能按顺序计算全部内容时返回空
+func ChunkHash(sessionID string, size uint64) string {
+	saved, ok := cache.Get(ChunkHashCachePrefix + sessionID)
+	if !ok || saved.(chunkHashState).Hashed != size {
+		return ""
+	}
+
+	hasher := sha256.New()
+	if err := hasher.(encoding.BinaryUnmarshaler).UnmarshalBinary(saved.(chunkHashState).State); err != nil {
+		return ""
+	}
+
+	return hex.EncodeToString(hasher.Sum(nil))
+}
+
+// HookIndexBlob 上传完成后将文件关联至内容摘要对应的 Blob，hash 为空时使用分片上传过程中计算的摘要，
+// 仍未知时交由 IndexBlob 在后台计算
+func HookIndexBlob(sessionID, hash string) Hook {
+	return func(ctx context.Context, fs *FileSystem, fileHeader fsctx.FileHeader) error {
+		file, ok := fileHeader.Info().Model.(*model.File)
+		if !ok || file.Size == 0 || file.BlobID != 0 {
+			return nil
+		}
+
+		if hash == "" {
+			hash = ChunkHash(sessionID, file.Size)
+		}
+
+		if hash == "" {
+			IndexBlob(file)
+			return nil
+		}
+
+		if err := fs.linkBlob(ctx, file, hash); err != nil {
+			util.Log().WithContext(ctx).Warning("Failed to index blob of file %d: %s", file.ID, err)
+		}
+
+		return nil
+	}
+}
+
+// IndexBlob 开启后台计算内容摘要时，重新读取源文件计算摘要并关联至 Blob，以便后续秒传。
+// 计算会产生与文件大小相同的下载流量，默认关闭；队列已满时放弃计算
+func IndexBlob(file *model.File) {
+	if file.Size == 0 || file.BlobID != 0 || !model.IsTrueVal(model.GetSettingByName("instant_upload_rehash")) {
+		return
+	}
+
+	blobIndexOnce.Do(func() {
+		for i := 0; i < blobIndexWorkers; i++ {
+			go func() {
+				for job := range blobIndexQueue {
+					if err := indexBlob(job.ID, job.UserID); err != nil {
+						util.Log().Warning("Failed to index blob of file %d: %s", job.ID, err)
+					}
+				}
+			}()
+		}
+	})
+
+	select {
+	case blobIndexQueue <- blobIndexJob{ID: file.ID, UserID: file.UserID}:
+	default:
+		util.Log().Warning("Blob index queue is full, skip file %d.", file.ID)
+	}
+}
+
+// indexBlob 读取源文件计算摘要并关联至 Blob，执行时重新读取文件记录，
+// 以免排队期间文件被覆盖或删除
+func indexBlob(id, uid uint) error {
+	files, err := model.GetFilesByIDs([]uint{id}, uid)
+	if err != nil || len(files) == 0 {
+		return ErrObjectNotExist
+	}
+
+	file := &files[0]
+	if file.UploadSessionID != nil || file.BlobID != 0 || file.Size == 0 {
+		return nil
+	}
+
+	// 使用文件所在存储策略的适配器读取源文件
+	fs := &FileSystem{Policy: file.GetPolicy()}
+	if err := fs.DispatchHandler(); err != nil {
+		return err
+	}
+	if fs.Handler == nil {
+		return ErrUnknownPolicyType
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), blobIndexTimeout)
+	defer cancel()
+
+	content, err := fs.Handler.Get(ctx, file.SourceName)
+	if err != nil {
+		return ErrIO.WithError(err)
+	}
+
+	h := sha256.New()
+	n, err := io.Copy(h, content)
+	content.Close()
+	if err != nil {
+		return ErrIO.WithError(err)
+	}
+
+	// 读取期间文件被覆盖时，摘要与记录不符
+	if uint64(n) != file.Size {
+		return ErrIO.WithError(fmt.Errorf("read %d bytes, expected %d", n, file.Size))
+	}
+
+	return fs.linkBlob(ctx, file, hex.EncodeToString(h.Sum(nil)))
+}
+
+// linkBlob 将文件关联至内容摘要对应的 Blob，存储策略中已有相同内容时，删除文件的源文件
+func (fs *FileSystem) linkBlob(ctx context.Context, file *model.File, hash string) error {
+	duplicate, err := file.LinkBlob(hash)
+	if err != nil {
+		return err
+	}
+
+	if duplicate != "" {
+		if _, err := fs.Handler.Delete(ctx, []string{duplicate}); err != nil {
+			util.Log().WithContext(ctx).Warning("Failed to delete duplicated file %q: %s", duplicate, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,214 @@
+package filesystem
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
+	"github.com/cloudreve/Cloudreve/v3/pkg/conf"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileSystem_InstantUploadSQLite(t *testing.T) {
+	asserts := assert.New(t)
+	mockDB := model.DB
+	conf.DatabaseConfig.Type = "sqlite"
+	model.DB, _ = gorm.Open("sqlite", ":memory:")
+	defer func() {
+		model.DB = mockDB
+		conf.DatabaseConfig.Type = "mysql"
+	}()
+	model.DB.AutoMigrate(&model.User{}, &model.Group{}, &model.Policy{}, &model.Folder{}, &model.File{},
+		&model.Share{}, &model.Blob{})
+
+	dir := t.TempDir()
+	policy := &model.Policy{
+		Type:         "local",
+		Name:         "TestInstant",
+		DirNameRule:  filepath.ToSlash(dir),
+		AutoRename:   true,
+		FileNameRule: "{randomkey16}_{originname}",
+	}
+	asserts.NoError(model.DB.Create(policy).Error)
+	cache.Deletes([]string{strconv.Itoa(int(policy.ID))}, "policy_")
+
+	newFS := func(email string) *FileSystem {
+		user := &model.User{Email: email}
+		user.Group.MaxStorage = 1024
+		asserts.NoError(model.DB.Create(user).Error)
+		_, err := user.Root()
+		asserts.NoError(err)
+		user.Policy = *policy
+		fs := &FileSystem{User: user, Policy: policy}
+		asserts.NoError(fs.DispatchHandler())
+		return fs
+	}
+	stream := func(name, content string) *fsctx.FileStream {
+		return &fsctx.FileStream{
+			File:        ioutil.NopCloser(strings.NewReader(content)),
+			Size:        uint64(len(content)),
+			Name:        name,
+			VirtualPath: "/",
+		}
+	}
+	prove := func(content, nonce string, offset, length uint64) string {
+		sum := sha256.Sum256([]byte(nonce + content[offset:offset+length]))
+		return hex.EncodeToString(sum[:])
+	}
+
+	content := strings.Repeat("cloudreve", 10)
+	sum := sha256.Sum256([]byte(content))
+	hash := hex.EncodeToString(sum[:])
+	ctx := context.Background()
+
+	// 尚无相同内容时同样返回挑战，应答后正常上传
+	fs1 := newFS("1@cloudreve.org")
+	credential, err := fs1.CreateUploadChallenge(ctx, stream("1.txt", content), hash)
+	asserts.NoError(err)
+	asserts.NotNil(credential.Challenge)
+	challenge := credential.Challenge
+	credential, err = fs1.InstantUpload(ctx, stream("1.txt", content), hash, challenge.ID,
+		prove(content, challenge.Nonce, challenge.Offset, challenge.Length))
+	asserts.NoError(err)
+	asserts.Nil(credential)
+	file := stream("1.txt", content)
+	asserts.NoError(fs1.UploadFromStream(ctx, file, true))
+	origin := file.Model.(*model.File)
+
+	// 应答错误
+	fs2 := newFS("2@cloudreve.org")
+	credential, err = fs2.CreateUploadChallenge(ctx, stream("2.txt", content), hash)
+	asserts.NoError(err)
+	asserts.NotNil(credential.Challenge)
+	challenge = credential.Challenge
+	credential, err = fs2.InstantUpload(ctx, stream("2.txt", content), hash, challenge.ID, "wrong")
+	asserts.NoError(err)
+	asserts.Nil(credential)
+
+	// 挑战只能应答一次
+	_, err = fs2.InstantUpload(ctx, stream("2.txt", content), hash, challenge.ID,
+		prove(content, challenge.Nonce, challenge.Offset, challenge.Length))
+	asserts.Equal(ErrUploadChallengeExpired, err)
+
+	// 应答正确，直接创建文件
+	credential, err = fs2.CreateUploadChallenge(ctx, stream("2.txt", content), hash)
+	asserts.NoError(err)
+	challenge = credential.Challenge
+	asserts.EqualValues(len(content), challenge.Length)
+	credential, err = fs2.InstantUpload(ctx, stream("2.txt", content), hash, challenge.ID,
+		prove(content, challenge.Nonce, challenge.Offset, challenge.Length))
+	asserts.NoError(err)
+	asserts.True(credential.Completed)
+	exist, instant := fs2.IsFileExist("/2.txt")
+	asserts.True(exist)
+	asserts.Equal(origin.SourceName, instant.SourceName)
+	asserts.Equal(origin.BlobID, instant.BlobID)
+	asserts.EqualValues(len(content), fs2.User.Storage)
+
+	// 其他用户的挑战无效
+	credential, err = fs2.CreateUploadChallenge(ctx, stream("3.txt", content), hash)
+	asserts.NoError(err)
+	challenge = credential.Challenge
+	_, err = fs1.InstantUpload(ctx, stream("3.txt", content), hash, challenge.ID,
+		prove(content, challenge.Nonce, challenge.Offset, challenge.Length))
+	asserts.Equal(ErrInvalidUploadProof, err)
+
+	// 分片上传完成后在后台计算摘要，相同内容去重
+	source := filepath.ToSlash(filepath.Join(dir, "chunked.txt"))
+	asserts.NoError(ioutil.WriteFile(source, []byte(content), 0644))
+	root, err := fs1.User.Root()
+	asserts.NoError(err)
+	chunked := &model.File{Name: "chunked.txt", SourceName: source, UserID: fs1.User.ID, FolderID: root.ID,
+		PolicyID: policy.ID, Size: uint64(len(content))}
+	asserts.NoError(model.DB.Create(chunked).Error)
+	asserts.NoError(indexBlob(chunked.ID, chunked.UserID))
+	files, err := model.GetFilesByIDs([]uint{chunked.ID}, chunked.UserID)
+	asserts.NoError(err)
+	asserts.Equal(origin.SourceName, files[0].SourceName)
+	asserts.False(util.Exists(source))
+	blob, err := model.GetBlobByHash(policy.ID, hash, uint64(len(content)))
+	asserts.NoError(err)
+	asserts.Equal(3, blob.RefCount)
+
+	// 上传过程中已计算摘要时直接关联
+	source = filepath.ToSlash(filepath.Join(dir, "remote.txt"))
+	asserts.NoError(ioutil.WriteFile(source, []byte(content), 0644))
+	remote := &model.File{Name: "remote.txt", SourceName: source, UserID: fs1.User.ID, FolderID: root.ID,
+		PolicyID: policy.ID, Size: uint64(len(content))}
+	asserts.NoError(model.DB.Create(remote).Error)
+	asserts.NoError(HookIndexBlob("", hash)(ctx, fs1, &fsctx.FileStream{Model: remote}))
+	asserts.Equal(origin.SourceName, remote.SourceName)
+	asserts.False(util.Exists(source))
+	blob, err = model.GetBlobByHash(policy.ID, hash, uint64(len(content)))
+	asserts.NoError(err)
+	asserts.Equal(4, blob.RefCount)
+}
+
+func TestHookChunkHash(t *testing.T) {
+	asserts := assert.New(t)
+	fs := &FileSystem{}
+	ctx := context.Background()
+	content := strings.Repeat("cloudreve", 10)
+	sum := sha256.Sum256([]byte(content))
+	upload := func(session string, index int, chunk string, start uint64) {
+		file := &fsctx.FileStream{
+			File:        ioutil.NopCloser(strings.NewReader(chunk)),
+			Size:        uint64(len(chunk)),
+			AppendStart: start,
+		}
+		asserts.NoError(HookResumeChunkHash(session, index)(ctx, fs, file))
+		ioutil.ReadAll(file)
+		asserts.NoError(HookSaveChunkHash(session)(ctx, fs, file))
+	}
+
+	// 按顺序上传
+	upload("TestHookChunkHash1", 0, content[:40], 0)
+	asserts.Empty(ChunkHash("TestHookChunkHash1", uint64(len(content))))
+	upload("TestHookChunkHash1", 1, content[40:80], 40)
+	upload("TestHookChunkHash1", 2, content[80:], 80)
+	asserts.Equal(hex.EncodeToString(sum[:]), ChunkHash("TestHookChunkHash1", uint64(len(content))))
+
+	// 分片重传后不再计算摘要
+	upload("TestHookChunkHash2", 0, content[:40], 0)
+	upload("TestHookChunkHash2", 1, content[40:80], 40)
+	upload("TestHookChunkHash2", 1, content[40:80], 40)
+	upload("TestHookChunkHash2", 2, content[80:], 80)
+	asserts.Empty(ChunkHash("TestHookChunkHash2", uint64(len(content))))
+
+	// 跳过分片
+	upload("TestHookChunkHash3", 0, content[:40], 0)
+	cache.IncrBy(ChunkSeqCachePrefix+"TestHookChunkHash3", 1, 0)
+	upload("TestHookChunkHash3", 2, content[80:], 80)
+	asserts.Empty(ChunkHash("TestHookChunkHash3", uint64(len(content))))
+}
+
+func TestIndexBlob(t *testing.T) {
+	asserts := assert.New(t)
+	file := &model.File{Model: gorm.Model{ID: 1}, UserID: 1, Size: 10}
+
+	// 未开启后台计算
+	cache.Set("setting_instant_upload_rehash", "0", 0)
+	IndexBlob(file)
+	asserts.Len(blobIndexQueue, 0)
+
+	// 队列已满时放弃计算
+	cache.Set("setting_instant_upload_rehash", "1", 0)
+	blobIndexOnce.Do(func() {})
+	for i := 0; i < blobIndexQueueSize+1; i++ {
+		IndexBlob(file)
+	}
+	asserts.Len(blobIndexQueue, blobIndexQueueSize)
+	for len(blobIndexQueue) > 0 {
+		<-blobIndexQueue
+	}
+}
@@ -2,15 +2,20 @@ package filesystem
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"path"
 	"strings"
 
 	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/audit"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
 	"github.com/cloudreve/Cloudreve/v3/pkg/hashid"
+	"github.com/cloudreve/Cloudreve/v3/pkg/metrics"
 	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
 	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+	"github.com/cloudreve/Cloudreve/v3/pkg/webhook"
 )
 
 /* =================
@@ -20,6 +25,10 @@ import (
 
 // Rename 重命名对象
 func (fs *FileSystem) Rename(ctx context.Context, dir, file []uint, new string) (err error) {
+	defer func() {
+		fs.auditObjects(ctx, audit.ActionFileRename, dir, file, new, err)
+	}()
+
 	// 验证新名字
 	if !fs.ValidateLegalName(ctx, new) || (len(file) > 0 && !fs.ValidateExtension(ctx, new)) {
 		return ErrIllegalObjectName
@@ -36,6 +45,9 @@ func (fs *FileSystem) Rename(ctx context.Context, dir, file []uint, new string)
 		if err != nil {
 			return ErrFileExisted
 		}
+
+		fileObject[0].Name = new
+		renameContent(&fileObject[0])
 		return nil
 	}
 
@@ -57,7 +69,11 @@ func (fs *FileSystem) Rename(ctx context.Context, dir, file []uint, new string)
 
 // Copy 复制src目录下的文件或目录到dst，
 // 暂时只支持单文件
-func (fs *FileSystem) Copy(ctx context.Context, dirs, files []uint, src, dst string) error {
+func (fs *FileSystem) Copy(ctx context.Context, dirs, files []uint, src, dst string) (err error) {
+	defer func() {
+		fs.auditObjects(ctx, audit.ActionFileCopy, dirs, files, src+" -> "+dst, err)
+	}()
+
 	// 获取目的目录
 	isDstExist, dstFolder := fs.IsPathExist(dst)
 	isSrcExist, srcFolder := fs.IsPathExist(src)
@@ -99,7 +115,14 @@ func (fs *FileSystem) Copy(ctx context.Context, dirs, files []uint, src, dst str
 }
 
 // Move 移动文件和目录, 将id列表dirs和files从src移动至dst
-func (fs *FileSystem) Move(ctx context.Context, dirs, files []uint, src, dst string) error {
+func (fs *FileSystem) Move(ctx context.Context, dirs, files []uint, src, dst string) (err error) {
+	defer func() {
+		fs.auditObjects(ctx, audit.ActionFileMove, dirs, files, src+" -> "+dst, err)
+		if err == nil {
+			fs.publishObjects(ctx, webhook.EventFileMoved, dirs, files, map[string]interface{}{"src": src, "dst": dst})
+		}
+	}()
+
 	// 获取目的目录
 	isDstExist, dstFolder := fs.IsPathExist(dst)
 	isSrcExist, srcFolder := fs.IsPathExist(src)
@@ -114,7 +137,7 @@ func (fs *FileSystem) Move(ctx context.Context, dirs, files []uint, src, dst str
 	}
 
 	// 处理目录及子文件移动
-	err := srcFolder.MoveFolderTo(dirs, dstFolder)
+	err = srcFolder.MoveFolderTo(dirs, dstFolder)
 	if err != nil {
 		return ErrFileExisted.WithError(err)
 	}
@@ -125,6 +148,11 @@ func (fs *FileSystem) Move(ctx context.Context, dirs, files []uint, src, dst str
 		return ErrFileExisted.WithError(err)
 	}
 
+	// WebDAV 移动时可能同时重命名文件
+	if dstFolder.WebdavDstName != "" {
+		fs.renameContentByIDs(files)
+	}
+
 	// 移动文件
 
 	return err
@@ -132,13 +160,13 @@ func (fs *FileSystem) Move(ctx context.Context, dirs, files []uint, src, dst str
 
 // Delete 递归删除对象, force 为 true 时强制删除文件记录，忽略物理删除是否成功;
 // unlink 为 true 时只删除虚拟文件系统的文件记录，不删除物理文件。
-func (fs *FileSystem) Delete(ctx context.Context, dirs, files []uint, force, unlink bool) error {
-	// 已删除的文件ID
-	var deletedFiles = make([]*model.File, 0, len(fs.FileTarget))
-	// 删除失败的文件的父目录ID
-
-	// 所有文件的ID
-	var allFiles = make([]*model.File, 0, len(fs.FileTarget))
+func (fs *FileSystem) Delete(ctx context.Context, dirs, files []uint, force, unlink bool) (err error) {
+	defer func() {
+		fs.auditObjects(ctx, audit.ActionFileDelete, dirs, files, fmt.Sprintf("force=%t unlink=%t", force, unlink), err)
+		if err == nil {
+			fs.publishObjects(ctx, webhook.EventFileDeleted, dirs, files, nil)
+		}
+	}()
 
 	// 列出要删除的目录
 	if len(dirs) > 0 {
@@ -156,6 +184,16 @@ func (fs *FileSystem) Delete(ctx context.Context, dirs, files []uint, force, unl
 		}
 	}
 
+	return fs.deleteTargets(ctx, force, unlink)
+}
+
+// deleteTargets 删除当前设定的全部目标文件和目录
+func (fs *FileSystem) deleteTargets(ctx context.Context, force, unlink bool) error {
+	// 已删除的文件ID
+	var deletedFiles = make([]*model.File, 0, len(fs.FileTarget))
+	// 所有文件的ID
+	var allFiles = make([]*model.File, 0, len(fs.FileTarget))
+
 	// 去除待删除文件中包含软连接的部分
 	filesToBeDelete, err := model.RemoveFilesWithSoftLinks(fs.FileTarget)
 	if err != nil {
@@ -201,6 +239,16 @@ func (fs *FileSystem) Delete(ctx context.Context, dirs, files []uint, force, unl
 	}
 
 	model.DeleteShareBySourceIDs(deletedFileIDs, false)
+	removeContent(deletedFileIDs)
+
+	// 删除文件的历史版本
+	if len(deletedFileIDs) > 0 {
+		if versions, err := model.GetVersionsByFileIDs(deletedFileIDs); err == nil {
+			if err := fs.deleteVersions(ctx, versions, unlink); err != nil {
+				util.Log().WithContext(ctx).Warning("Failed to delete versions of deleted files: %s", err)
+			}
+		}
+	}
 
 	// 如果文件全部删除成功，继续删除目录
 	if len(deletedFiles) == len(allFiles) {
@@ -446,34 +494,230 @@ func (fs *FileSystem) CreateDirectory(ctx context.Context, fullPath string) (*mo
 	return &newFolder, nil
 }
 
-// SaveTo 将别人分享的文件转存到目标路径下
-func (fs *FileSystem) SaveTo(ctx context.Context, path string) error {
-	// 获取父目录
-	isExist, folder := fs.IsPathExist(path)
+// SaveTo 将别人分享的文件转存到目标路径下，fs.DirTarget 及 fs.FileTarget 为要转存的对象。
+// 与用户当前存储策略相同的文件共用源文件，其余文件复制至用户当前的存储策略
+func (fs *FileSystem) SaveTo(ctx context.Context, dst string) (err error) {
+	dirIDs := make([]uint, 0, len(fs.DirTarget))
+	for _, dir := range fs.DirTarget {
+		dirIDs = append(dirIDs, dir.ID)
+	}
+	fileIDs := make([]uint, 0, len(fs.FileTarget))
+	for _, file := range fs.FileTarget {
+		fileIDs = append(fileIDs, file.ID)
+	}
+	defer func() {
+		fs.auditObjects(ctx, audit.ActionShareSave, dirIDs, fileIDs, dst, err)
+	}()
+
+	// 获取目的目录
+	isExist, dstFolder := fs.IsPathExist(dst)
 	if !isExist {
 		return ErrPathNotExist
 	}
 
+	// 目的目录下不能存在同名对象
+	for _, dir := range fs.DirTarget {
+		if _, err := dstFolder.GetChild(dir.Name); err == nil {
+			return ErrFileExisted
+		}
+	}
+	for _, file := range fs.FileTarget {
+		if ok, _ := fs.IsChildFileExist(dstFolder, file.Name); ok {
+			return ErrFileExisted
+		}
+	}
+
+	// 整理要转存的目录及文件
+	folders := make([]model.Folder, 0)
+	files := make([]model.File, 0, len(fs.FileTarget))
+	for _, dir := range fs.DirTarget {
+		subFolders, err := model.GetRecursiveChildFolder([]uint{dir.ID}, dir.OwnerID, true)
+		if err != nil {
+			return ErrDBListObjects.WithError(err)
+		}
+
+		ids := make([]uint, 0, len(subFolders))
+		for _, folder := range subFolders {
+			ids = append(ids, folder.ID)
+		}
+
+		subFiles, err := model.GetFilesByParentIDs(ids, dir.OwnerID)
+		if err != nil {
+			return ErrDBListObjects.WithError(err)
+		}
+
+		folders = append(folders, subFolders...)
+		files = append(files, subFiles...)
+	}
+	files = append(files, fs.FileTarget...)
+
+	// 按用户当前的存储策略及容量校验文件
+	var totalSize uint64
+	for i := 0; i < len(files); i++ {
+		if !files[i].CanCopy() {
+			util.Log().WithContext(ctx).Warning("Cannot save file %q because it's being uploaded now, skipping...", files[i].Name)
+			files = append(files[:i], files[i+1:]...)
+			i--
+			continue
+		}
+
+		if err := HookValidateFile(ctx, fs, &fsctx.FileStream{Name: files[i].Name, Size: files[i].Size}); err != nil {
+			return err
+		}
+		totalSize += files[i].Size
+	}
+
+	if fs.User.GetRemainingCapacity() < totalSize {
+		return ErrInsufficientCapacity
+	}
+
+	// 计算源目录复制后的路径
+	paths := make(map[uint]string, len(folders))
+	for _, folder := range folders {
+		parentPath := dst
+		if folder.ParentID != nil {
+			if p, ok := paths[*folder.ParentID]; ok {
+				parentPath = p
+			}
+		}
+		paths[folder.ID] = path.Join(parentPath, folder.Name)
+	}
+	dirOf := func(file *model.File) string {
+		if p, ok := paths[file.FolderID]; ok {
+			return p
+		}
+		return dst
+	}
+
+	// 与用户存储策略不同的文件先复制内容，记录创建失败时删除已复制的内容
+	fs.Policy = &fs.User.Policy
+	if err := fs.DispatchHandler(); err != nil {
+		return err
+	}
+
 	var (
-		totalSize uint64
-		err       error
+		copies  []model.File
+		uploads []model.File
+		hashes  []string
 	)
+	handlers := make(map[uint]driver.Handler)
+	for _, file := range files {
+		if file.PolicyID == fs.User.Policy.ID {
+			copies = append(copies, file)
+			continue
+		}
 
-	if len(fs.DirTarget) > 0 {
-		totalSize, err = fs.DirTarget[0].CopyFolderTo(fs.DirTarget[0].ID, folder)
-	} else {
-		parent := model.Folder{
-			OwnerID: fs.FileTarget[0].UserID,
+		saved, hash, err := fs.saveContent(ctx, handlers, &file, dirOf(&file))
+		if err != nil {
+			fs.deleteSavedContent(ctx, uploads)
+			return err
 		}
-		parent.ID = fs.FileTarget[0].FolderID
-		totalSize, err = parent.MoveOrCopyFileTo([]uint{fs.FileTarget[0].ID}, folder, true)
+		uploads = append(uploads, *saved)
+		hashes = append(hashes, hash)
 	}
 
-	// 扣除用户容量
-	fs.User.IncreaseStorageWithoutCheck(totalSize)
+	// 在一个事务中创建所有目录及文件记录
+	dirs := make([]string, 0, len(uploads))
+	for i := range uploads {
+		dirs = append(dirs, dirOf(&uploads[i]))
+	}
+	duplicates, err := dstFolder.SaveObjects(folders, copies, uploads, hashes)
 	if err != nil {
-		return ErrFileExisted.WithError(err)
+		fs.deleteSavedContent(ctx, uploads)
+		return ErrInsertFileRecord.WithError(err)
+	}
+	fs.User.Storage += totalSize
+
+	// 删除重复保存的源文件
+	if len(duplicates) > 0 {
+		if _, err := fs.Handler.Delete(ctx, duplicates); err != nil {
+			util.Log().WithContext(ctx).Warning("Failed to delete duplicated files: %s", err)
+		}
+	}
+
+	// 索引复制了内容的文件并发布事件
+	for i := range uploads {
+		IndexContent(&uploads[i])
+		publishFile(ctx, webhook.EventFileUploaded, &uploads[i], dirs[i])
 	}
 
 	return nil
 }
+
+// saveContent 将其他存储策略下的文件内容复制至用户当前的存储策略，返回待创建的文件记录及内容摘要，
+// handlers 缓存各存储策略的适配器
+func (fs *FileSystem) saveContent(ctx context.Context, handlers map[uint]driver.Handler, file *model.File, dst string) (*model.File, string, error) {
+	handler, ok := handlers[file.PolicyID]
+	if !ok {
+		policy, err := model.GetPolicyByID(file.PolicyID)
+		if err != nil {
+			return nil, "", serializer.NewError(serializer.CodePolicyNotExist, "", err)
+		}
+
+		src := &FileSystem{Policy: &policy}
+		if err := src.DispatchHandler(); err != nil {
+			return nil, "", err
+		}
+		handler = src.Handler
+		handlers[file.PolicyID] = handler
+	}
+
+	content, err := handler.Get(context.WithValue(ctx, fsctx.FileModelCtx, *file), file.SourceName)
+	if err != nil {
+		return nil, "", ErrIO.WithError(err)
+	}
+	defer content.Close()
+
+	stream := &fsctx.FileStream{
+		File:        content,
+		Seeker:      content,
+		Size:        file.Size,
+		Name:        file.Name,
+		VirtualPath: dst,
+	}
+	stream.SavePath = fs.GenerateSavePath(ctx, stream)
+	if file.Size > 0 {
+		stream.ComputeHash()
+	}
+
+	if err := fs.Handler.Put(ctx, stream); err != nil {
+		return nil, "", err
+	}
+	metrics.ObserveTransfer(fs.PolicyType(), metrics.DirectionUploaded, file.Size)
+
+	return &model.File{
+		Name:       file.Name,
+		SourceName: stream.SavePath,
+		Size:       file.Size,
+		FolderID:   file.FolderID,
+		PolicyID:   fs.Policy.ID,
+	}, stream.Info().Hash, nil
+}
+
+// deleteSavedContent 删除已复制但未创建记录的文件内容
+func (fs *FileSystem) deleteSavedContent(ctx context.Context, files []model.File) {
+	if len(files) == 0 {
+		return
+	}
+
+	sources := make([]string, 0, len(files))
+	for _, file := range files {
+		sources = append(sources, file.SourceName)
+	}
+	if _, err := fs.Handler.Delete(ctx, sources); err != nil {
+		util.Log().WithContext(ctx).Warning("Failed to delete saved files: %s", err)
+	}
+}
+
+// auditObjects 记录对目录和文件的操作，操作对象以ID列表表示
+func (fs *FileSystem) auditObjects(ctx context.Context, action string, dirs, files []uint, detail string, err error) {
+	target, _ := json.Marshal(map[string][]uint{"dirs": dirs, "files": files})
+	audit.Record(ctx, audit.Event{
+		Action:     action,
+		User:       fs.User,
+		TargetType: audit.TargetObjects,
+		Target:     string(target),
+		Detail:     detail,
+		Err:        err,
+	})
+}
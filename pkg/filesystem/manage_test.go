@@ -4,7 +4,11 @@ import (
 	"context"
 	"errors"
 	"github.com/DATA-DOG/go-sqlmock"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/response"
@@ -807,42 +811,190 @@ func TestFileSystem_SaveTo(t *testing.T) {
 		Model: gorm.Model{
 			ID: 1,
 		},
+		Policy: model.Policy{Type: "mock"},
 	}}
 	ctx := context.Background()
 
-	// 单文件 失败
+	// 父目录不存在
 	{
 		// 根目录
 		mock.ExpectQuery("SELECT(.+)").
 			WithArgs(1).
-			WillReturnRows(sqlmock.NewRows([]string{"id", "owner_id"}).AddRow(1, 1))
-		mock.ExpectQuery("SELECT(.+)").WillReturnError(errors.New("error"))
-		fs.SetTargetFile(&[]model.File{{Name: "test.txt"}})
+			WillReturnRows(sqlmock.NewRows([]string{"id", "owner_id"}))
+		fs.SetTargetDir(&[]model.Folder{{Name: "folder"}})
 		err := fs.SaveTo(ctx, "/")
 		asserts.NoError(mock.ExpectationsWereMet())
-		asserts.Error(err)
+		asserts.Equal(ErrPathNotExist, err)
 	}
-	// 目录 成功
+
+	// 列出文件失败
 	{
 		// 根目录
 		mock.ExpectQuery("SELECT(.+)").
 			WithArgs(1).
 			WillReturnRows(sqlmock.NewRows([]string{"id", "owner_id"}).AddRow(1, 1))
+		mock.ExpectQuery("SELECT(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectQuery("SELECT(.+)").WillReturnRows(sqlmock.NewRows([]string{"id", "owner_id"}).AddRow(2, 1))
+		mock.ExpectQuery("SELECT(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
 		mock.ExpectQuery("SELECT(.+)").WillReturnError(errors.New("error"))
-		fs.SetTargetDir(&[]model.Folder{{Name: "folder"}})
 		err := fs.SaveTo(ctx, "/")
 		asserts.NoError(mock.ExpectationsWereMet())
-		asserts.Error(err)
+		asserts.ErrorIs(err, ErrDBListObjects)
 	}
-	// 父目录不存在
+
+	// 创建记录失败，回滚事务
 	{
 		// 根目录
 		mock.ExpectQuery("SELECT(.+)").
 			WithArgs(1).
-			WillReturnRows(sqlmock.NewRows([]string{"id", "owner_id"}))
-		fs.SetTargetDir(&[]model.Folder{{Name: "folder"}})
+			WillReturnRows(sqlmock.NewRows([]string{"id", "owner_id"}).AddRow(1, 1))
+		mock.ExpectQuery("SELECT(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectQuery("SELECT(.+)").WillReturnRows(sqlmock.NewRows([]string{"id", "owner_id"}).AddRow(2, 1))
+		mock.ExpectQuery("SELECT(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectQuery("SELECT(.+)").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT(.+)folders(.+)").WillReturnError(errors.New("error"))
+		mock.ExpectRollback()
 		err := fs.SaveTo(ctx, "/")
 		asserts.NoError(mock.ExpectationsWereMet())
-		asserts.Error(err)
+		asserts.ErrorIs(err, ErrInsertFileRecord)
 	}
 }
+
+func TestFileSystem_SaveToSQLite(t *testing.T) {
+	asserts := assert.New(t)
+	mockDB := model.DB
+	dbType := conf.DatabaseConfig.Type
+	conf.DatabaseConfig.Type = "sqlite"
+	model.DB, _ = gorm.Open("sqlite", ":memory:")
+	defer func() {
+		model.DB = mockDB
+		conf.DatabaseConfig.Type = dbType
+	}()
+	model.DB.AutoMigrate(&model.User{}, &model.Group{}, &model.Policy{}, &model.Folder{}, &model.File{},
+		&model.Share{}, &model.FileVersion{}, &model.Blob{})
+	ctx := context.Background()
+
+	newPolicy := func(name string) (*model.Policy, string) {
+		dir := t.TempDir()
+		policy := &model.Policy{
+			Type:         "local",
+			Name:         name,
+			DirNameRule:  filepath.ToSlash(dir),
+			AutoRename:   true,
+			FileNameRule: "{randomkey16}_{originname}",
+		}
+		asserts.NoError(model.DB.Create(policy).Error)
+		cache.Deletes([]string{strconv.Itoa(int(policy.ID))}, "policy_")
+		return policy, dir
+	}
+	newFS := func(email string, policy *model.Policy, capacity uint64) *FileSystem {
+		user := &model.User{Email: email}
+		user.Group.MaxStorage = capacity
+		asserts.NoError(model.DB.Create(user).Error)
+		_, err := user.Root()
+		asserts.NoError(err)
+		user.Policy = *policy
+		fs, err := NewFileSystem(user)
+		asserts.NoError(err)
+		return fs
+	}
+	upload := func(fs *FileSystem, dir, name, content string) *model.File {
+		file := &fsctx.FileStream{
+			File:        ioutil.NopCloser(strings.NewReader(content)),
+			Size:        uint64(len(content)),
+			Name:        name,
+			VirtualPath: dir,
+		}
+		fs.CleanHooks("")
+		asserts.NoError(fs.UploadFromStream(ctx, file, true))
+		return file.Model.(*model.File)
+	}
+
+	// 分享者的目录中包含两个存储策略下的文件
+	policy, policyDir := newPolicy("Shared")
+	otherPolicy, _ := newPolicy("Other")
+	sharer := newFS("sharer@cloudreve.org", policy, 1024)
+	a := upload(sharer, "/share", "a.txt", "aaa")
+	upload(sharer, "/share/sub", "b.txt", "bb")
+	sharer.User.Policy = *otherPolicy
+	c := upload(sharer, "/share/sub", "c.txt", "c")
+	_, shared := sharer.IsPathExist("/share")
+
+	// 转存整个目录，相同存储策略的文件共用源文件，其余文件复制至转存者的存储策略
+	visitor := newFS("visitor@cloudreve.org", policy, 1024)
+	visitor.SetTargetDir(&[]model.Folder{*shared})
+	asserts.NoError(visitor.SaveTo(ctx, "/"))
+	exist, savedA := visitor.IsFileExist("/share/a.txt")
+	asserts.True(exist)
+	asserts.Equal(a.SourceName, savedA.SourceName)
+	asserts.Equal(visitor.User.ID, savedA.UserID)
+	exist, _ = visitor.IsFileExist("/share/sub/b.txt")
+	asserts.True(exist)
+	exist, savedC := visitor.IsFileExist("/share/sub/c.txt")
+	asserts.True(exist)
+	asserts.Equal(policy.ID, savedC.PolicyID)
+	asserts.NotEqual(c.SourceName, savedC.SourceName)
+	content, err := ioutil.ReadFile(savedC.SourceName)
+	asserts.NoError(err)
+	asserts.Equal("c", string(content))
+	asserts.True(strings.HasPrefix(filepath.ToSlash(savedC.SourceName), filepath.ToSlash(policyDir)))
+	user, _ := model.GetUserByID(visitor.User.ID)
+	asserts.EqualValues(6, user.Storage)
+	asserts.EqualValues(6, visitor.User.Storage)
+
+	// 目标位置已存在同名对象
+	asserts.ErrorIs(visitor.SaveTo(ctx, "/"), ErrFileExisted)
+
+	// 只转存选定的文件
+	_, err = visitor.CreateDirectory(ctx, "/selected")
+	asserts.NoError(err)
+	visitor.CleanTargets()
+	visitor.SetTargetFile(&[]model.File{*a})
+	asserts.NoError(visitor.SaveTo(ctx, "/selected"))
+	exist, _ = visitor.IsFileExist("/selected/a.txt")
+	asserts.True(exist)
+
+	// 目标目录不存在
+	asserts.ErrorIs(visitor.SaveTo(ctx, "/not_exist"), ErrPathNotExist)
+
+	// 创建文件记录失败时回滚已创建的目录，并删除已复制的内容
+	countSources := func() int {
+		count := 0
+		filepath.Walk(policyDir, func(path string, info os.FileInfo, err error) error {
+			if err == nil && !info.IsDir() {
+				count++
+			}
+			return nil
+		})
+		return count
+	}
+	sources := countSources()
+	model.DB.Callback().Create().Before("gorm:create").Register("test:fail_files", func(scope *gorm.Scope) {
+		if scope.TableName() == "files" {
+			scope.Err(errors.New("error"))
+		}
+	})
+	failed := newFS("failed@cloudreve.org", policy, 1024)
+	failed.SetTargetDir(&[]model.Folder{*shared})
+	asserts.ErrorIs(failed.SaveTo(ctx, "/"), ErrInsertFileRecord)
+	model.DB.Callback().Create().Remove("test:fail_files")
+	exist, _ = failed.IsPathExist("/share")
+	asserts.False(exist)
+	user, _ = model.GetUserByID(failed.User.ID)
+	asserts.EqualValues(0, user.Storage)
+	asserts.Equal(sources, countSources())
+
+	// 容量不足
+	poor := newFS("poor@cloudreve.org", policy, 5)
+	poor.SetTargetDir(&[]model.Folder{*shared})
+	asserts.ErrorIs(poor.SaveTo(ctx, "/"), ErrInsufficientCapacity)
+	exist, _ = poor.IsPathExist("/share")
+	asserts.False(exist)
+
+	// 转存者的存储策略不允许的文件
+	policy.MaxSize = 2
+	limited := newFS("limited@cloudreve.org", policy, 1024)
+	limited.SetTargetDir(&[]model.Folder{*shared})
+	asserts.ErrorIs(limited.SaveTo(ctx, "/"), ErrFileSizeTooBig)
+}
@@ -0,0 +1,183 @@
+package filesystem
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+)
+
+/* ================
+	 存储策略迁移相关
+   ================
+*/
+
+// MigrateSource 将文件的源文件迁移至目标存储策略，使用相同源文件且符合 scope 筛选条件的其他文件、
+// 历史版本会一同迁移，源文件不再被使用时删除源文件及其缩略图。
+// fs.User 需为文件所有者，用于生成新的存储路径；verifyHash 为 true 时校验内容摘要，否则只校验大小
+func (fs *FileSystem) MigrateSource(ctx context.Context, file *model.File, dst *model.Policy, scope *model.MigrateScope,
+	verifyHash bool) error {
+	if file.PolicyID == dst.ID {
+		return nil
+	}
+
+	srcPolicy, err := model.GetPolicyByID(file.PolicyID)
+	if err != nil {
+		return serializer.NewError(serializer.CodePolicyNotExist, "", err)
+	}
+
+	fs.Policy = &srcPolicy
+	if err := fs.DispatchHandler(); err != nil {
+		return err
+	}
+	srcHandler := fs.Handler
+
+	fs.Policy = dst
+	if err := fs.DispatchHandler(); err != nil {
+		return err
+	}
+	dstHandler := fs.Handler
+
+	// 复制源文件
+	content, err := srcHandler.Get(ctx, file.SourceName)
+	if err != nil {
+		return ErrIO.WithError(err)
+	}
+
+	stream := &fsctx.FileStream{
+		File:        content,
+		Seeker:      content,
+		Size:        file.Size,
+		Name:        file.Name,
+		VirtualPath: file.Position,
+	}
+	stream.SavePath = fs.GenerateSavePath(ctx, stream)
+	stream.ComputeHash()
+	err = dstHandler.Put(ctx, stream)
+	content.Close()
+	if err != nil {
+		return err
+	}
+
+	// 校验副本
+	if err := verifyMigratedSource(ctx, srcHandler, dstHandler, file, stream, verifyHash); err != nil {
+		deleteMigratedCopy(ctx, dstHandler, stream.SavePath)
+		return err
+	}
+
+	migrated, remaining, err := model.MigrateSource(file.PolicyID, file.SourceName, dst.ID, stream.SavePath, scope)
+	if err != nil {
+		deleteMigratedCopy(ctx, dstHandler, stream.SavePath)
+		return ErrDBMigrateObjects.WithError(err)
+	}
+
+	// 迁移过程中源文件已不再被使用
+	if migrated == 0 {
+		deleteMigratedCopy(ctx, dstHandler, stream.SavePath)
+		return nil
+	}
+
+	// 筛选条件之外的文件仍使用原源文件
+	if remaining == 0 {
+		// 缩略图可能不存在，忽略其删除失败
+		thumb := file.ThumbFile()
+		failed, err := srcHandler.Delete(ctx, []string{file.SourceName, thumb})
+		if len(util.SliceDifference(failed, []string{thumb})) > 0 {
+			util.Log().WithContext(ctx).Warning("Failed to delete migrated source %q: %s", file.SourceName, err)
+		}
+	}
+
+	file.PolicyID = dst.ID
+	file.SourceName = stream.SavePath
+	file.Policy = *dst
+	return nil
+}
+
+// verifyMigratedSource 校验迁移后的源文件大小，verifyHash 为 true 时读取完整内容校验摘要
+func verifyMigratedSource(ctx context.Context, src, dst driver.Handler, file *model.File, stream *fsctx.FileStream, verifyHash bool) error {
+	if !verifyHash {
+		dstSize, err := objectSize(ctx, dst, stream.SavePath)
+		if err != nil {
+			return err
+		}
+
+		if dstSize != file.Size {
+			return ErrMigrateVerifyFailed
+		}
+		return nil
+	}
+
+	dstHash, dstSize, err := hashObject(ctx, dst, stream.SavePath)
+	if err != nil {
+		return err
+	}
+
+	if dstSize != file.Size {
+		return ErrMigrateVerifyFailed
+	}
+
+	// 复制时未能完整计算摘要的，重新读取源文件
+	srcHash := stream.Info().Hash
+	if srcHash == "" {
+		if srcHash, _, err = hashObject(ctx, src, file.SourceName); err != nil {
+			return err
+		}
+	}
+
+	if srcHash != dstHash {
+		return ErrMigrateVerifyFailed
+	}
+
+	return nil
+}
+
+// hashObject 读取存储策略中的文件，返回内容摘要及大小
+func hashObject(ctx context.Context, handler driver.Handler, source string) (string, uint64, error) {
+	content, err := handler.Get(ctx, source)
+	if err != nil {
+		return "", 0, ErrIO.WithError(err)
+	}
+	defer content.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(h, content)
+	if err != nil {
+		return "", 0, ErrIO.WithError(err)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), uint64(size), nil
+}
+
+// objectSize 返回存储策略中文件的大小，无法从响应中直接获取时读取完整内容
+func objectSize(ctx context.Context, handler driver.Handler, source string) (uint64, error) {
+	content, err := handler.Get(ctx, source)
+	if err != nil {
+		return 0, ErrIO.WithError(err)
+	}
+	defer content.Close()
+
+	if size, err := content.Seek(0, io.SeekEnd); err == nil && size >= 0 {
+		return uint64(size), nil
+	}
+
+	size, err := io.Copy(ioutil.Discard, content)
+	if err != nil {
+		return 0, ErrIO.WithError(err)
+	}
+
+	return uint64(size), nil
+}
+
+// deleteMigratedCopy 删除迁移失败或不再需要的副本
+func deleteMigratedCopy(ctx context.Context, handler driver.Handler, source string) {
+	if _, err := handler.Delete(ctx, []string{source}); err != nil {
+		util.Log().WithContext(ctx).Warning("Failed to delete migrated copy %q: %s", source, err)
+	}
+}
@@ -0,0 +1,249 @@
+package filesystem
+
+import (
+	"context"
+	"encoding/json"
+	"path"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/audit"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+)
+
+/* ================
+	 回收站相关
+   ================
+*/
+
+// Trash 将目录及文件移入回收站，用户组未启用回收站时直接删除
+func (fs *FileSystem) Trash(ctx context.Context, dirs, files []uint) (err error) {
+	if fs.User.Group.OptionsSerialized.TrashRetention <= 0 {
+		return fs.Delete(ctx, dirs, files, false, false)
+	}
+
+	defer func() {
+		fs.auditObjects(ctx, audit.ActionFileTrash, dirs, files, "", err)
+	}()
+
+	if len(dirs) > 0 {
+		folders, err := model.GetFoldersByIDs(dirs, fs.User.ID)
+		if err != nil {
+			return ErrDBListObjects.WithError(err)
+		}
+
+		for _, folder := range folders {
+			// 忽略根目录
+			if folder.ParentID == nil {
+				continue
+			}
+
+			if err := fs.trashFolder(ctx, &folder); err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(files) > 0 {
+		targets, err := model.GetFilesByIDs(files, fs.User.ID)
+		if err != nil {
+			return ErrDBListObjects.WithError(err)
+		}
+
+		// 上传中的占位文件直接删除
+		placeholders := make([]uint, 0)
+		for _, file := range targets {
+			if file.UploadSessionID != nil {
+				placeholders = append(placeholders, file.ID)
+				continue
+			}
+
+			if err := fs.trashFile(ctx, &file); err != nil {
+				return err
+			}
+		}
+
+		if len(placeholders) > 0 {
+			return fs.Delete(ctx, []uint{}, placeholders, false, false)
+		}
+	}
+
+	return nil
+}
+
+// trashFolder 将目录及其全部子对象移入回收站
+func (fs *FileSystem) trashFolder(ctx context.Context, folder *model.Folder) error {
+	if err := folder.TraceRoot(); err != nil {
+		return ErrDBListObjects.WithError(err)
+	}
+
+	folders, err := model.GetRecursiveChildFolder([]uint{folder.ID}, fs.User.ID, true)
+	if err != nil {
+		return ErrDBListObjects.WithError(err)
+	}
+
+	files, err := model.GetChildFilesOfFolders(&folders)
+	if err != nil {
+		return ErrDBListObjects.WithError(err)
+	}
+
+	trash := &model.Trash{
+		UserID:   fs.User.ID,
+		Name:     folder.Name,
+		IsDir:    true,
+		ObjectID: folder.ID,
+		ParentID: *folder.ParentID,
+		Position: folder.Position,
+	}
+
+	fileIDs := make([]uint, 0, len(files))
+	for _, file := range files {
+		fileIDs = append(fileIDs, file.ID)
+		trash.Size += file.Size
+	}
+
+	folderIDs := make([]uint, 0, len(folders))
+	for _, child := range folders {
+		folderIDs = append(folderIDs, child.ID)
+	}
+
+	if err := trash.Create(fileIDs, folderIDs); err != nil {
+		return ErrDBTrashObjects.WithError(err)
+	}
+
+	return nil
+}
+
+// trashFile 将文件移入回收站
+func (fs *FileSystem) trashFile(ctx context.Context, file *model.File) error {
+	parents, err := model.GetFoldersByIDs([]uint{file.FolderID}, fs.User.ID)
+	if err != nil || len(parents) == 0 {
+		return ErrObjectNotExist.WithError(err)
+	}
+
+	if err := parents[0].TraceRoot(); err != nil {
+		return ErrDBListObjects.WithError(err)
+	}
+
+	trash := &model.Trash{
+		UserID:   fs.User.ID,
+		Name:     file.Name,
+		ObjectID: file.ID,
+		ParentID: file.FolderID,
+		Position: path.Join(parents[0].Position, parents[0].Name),
+		Size:     file.Size,
+	}
+
+	if err := trash.Create([]uint{file.ID}, []uint{}); err != nil {
+		return ErrDBTrashObjects.WithError(err)
+	}
+
+	return nil
+}
+
+// RestoreTrash 还原回收站中的对象。dst 为空时还原至原位置，原目录不存在时
+// 会重新创建；dst 不为空时还原至指定目录
+func (fs *FileSystem) RestoreTrash(ctx context.Context, ids []uint, dst string) (err error) {
+	defer func() {
+		target, _ := json.Marshal(ids)
+		audit.Record(ctx, audit.Event{
+			Action:     audit.ActionFileRestore,
+			User:       fs.User,
+			TargetType: audit.TargetTrash,
+			Target:     string(target),
+			Detail:     dst,
+			Err:        err,
+		})
+	}()
+
+	trashes, err := model.GetTrashByIDs(ids, fs.User.ID)
+	if err != nil {
+		return ErrDBListObjects.WithError(err)
+	}
+
+	if len(trashes) == 0 {
+		return ErrObjectNotExist
+	}
+
+	for _, trash := range trashes {
+		parent, err := fs.getRestoreParent(ctx, &trash, dst)
+		if err != nil {
+			return err
+		}
+
+		// 目标目录下已有同名对象
+		if ok, _ := fs.IsChildFileExist(parent, trash.Name); ok {
+			return ErrFileExisted
+		}
+		if _, err := parent.GetChild(trash.Name); err == nil {
+			return ErrFileExisted
+		}
+
+		files, folders, err := trash.ListObjects()
+		if err != nil {
+			return ErrDBListObjects.WithError(err)
+		}
+
+		fileIDs := make([]uint, 0, len(files))
+		for _, file := range files {
+			fileIDs = append(fileIDs, file.ID)
+		}
+
+		folderIDs := make([]uint, 0, len(folders))
+		for _, folder := range folders {
+			folderIDs = append(folderIDs, folder.ID)
+		}
+
+		if err := trash.Restore(parent.ID, fileIDs, folderIDs); err != nil {
+			return ErrDBTrashObjects.WithError(err)
+		}
+	}
+
+	return nil
+}
+
+// getRestoreParent 获取回收站对象的还原目标目录
+func (fs *FileSystem) getRestoreParent(ctx context.Context, trash *model.Trash, dst string) (*model.Folder, error) {
+	if dst != "" {
+		exist, parent := fs.IsPathExist(dst)
+		if !exist {
+			return nil, ErrPathNotExist
+		}
+		return parent, nil
+	}
+
+	// 原目录仍存在
+	if parents, err := model.GetFoldersByIDs([]uint{trash.ParentID}, fs.User.ID); err == nil && len(parents) > 0 {
+		return &parents[0], nil
+	}
+
+	if trash.Position == "/" {
+		return fs.User.Root()
+	}
+
+	return fs.CreateDirectory(ctx, trash.Position)
+}
+
+// PurgeTrash 彻底删除回收站中的对象
+func (fs *FileSystem) PurgeTrash(ctx context.Context, trashes []model.Trash) error {
+	for _, trash := range trashes {
+		files, folders, err := trash.ListObjects()
+		if err != nil {
+			return ErrDBListObjects.WithError(err)
+		}
+
+		fs.CleanTargets()
+		fs.SetTargetFile(&files)
+		fs.SetTargetDir(&folders)
+		if err := fs.deleteTargets(ctx, false, false); err != nil {
+			util.Log().WithContext(ctx).Warning("Failed to purge trash %d: %s", trash.ID, err)
+			return err
+		}
+
+		if err := trash.Delete(); err != nil {
+			return ErrDBDeleteObjects.WithError(err)
+		}
+	}
+
+	fs.CleanTargets()
+	return nil
+}
@@ -0,0 +1,103 @@
+package filesystem
+
+import (
+	"context"
+	"testing"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/conf"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileSystem_TrashLifecycleSQLite(t *testing.T) {
+	asserts := assert.New(t)
+	mockDB := model.DB
+	conf.DatabaseConfig.Type = "sqlite"
+	model.DB, _ = gorm.Open("sqlite", ":memory:")
+	defer func() {
+		model.DB = mockDB
+		conf.DatabaseConfig.Type = "mysql"
+	}()
+	model.DB.AutoMigrate(&model.User{}, &model.Group{}, &model.Policy{}, &model.Folder{}, &model.File{},
+		&model.Share{}, &model.Trash{})
+
+	policy := &model.Policy{Type: "local", Name: "TestTrash"}
+	asserts.NoError(model.DB.Create(policy).Error)
+	user := &model.User{Email: "trash@cloudreve.org", Storage: 3}
+	user.Group.OptionsSerialized.TrashRetention = 7
+	asserts.NoError(model.DB.Create(user).Error)
+	root, err := user.Root()
+	asserts.NoError(err)
+
+	fs := &FileSystem{User: user, Policy: policy}
+	ctx := context.Background()
+
+	// 准备目录结构 /dir/1.txt, /2.txt
+	dir, err := fs.CreateDirectory(ctx, "/dir")
+	asserts.NoError(err)
+	file1 := &model.File{Name: "1.txt", SourceName: "not_exist_1", UserID: user.ID, FolderID: dir.ID, PolicyID: policy.ID, Size: 1}
+	asserts.NoError(model.DB.Create(file1).Error)
+	file2 := &model.File{Name: "2.txt", SourceName: "not_exist_2", UserID: user.ID, FolderID: root.ID, PolicyID: policy.ID, Size: 2}
+	asserts.NoError(model.DB.Create(file2).Error)
+
+	// 移入回收站
+	asserts.NoError(fs.Trash(ctx, []uint{dir.ID}, []uint{file2.ID}))
+	exist, _ := fs.IsPathExist("/dir")
+	asserts.False(exist)
+	exist, _ = fs.IsFileExist("/2.txt")
+	asserts.False(exist)
+
+	trashes, err := model.GetTrashByUID(user.ID)
+	asserts.NoError(err)
+	asserts.Len(trashes, 2)
+	var dirTrash, fileTrash model.Trash
+	for _, trash := range trashes {
+		if trash.IsDir {
+			dirTrash = trash
+		} else {
+			fileTrash = trash
+		}
+	}
+	asserts.EqualValues(1, dirTrash.Size)
+	asserts.Equal("/", dirTrash.Position)
+	asserts.Equal("/", fileTrash.Position)
+
+	// 原位置已被占用
+	_, err = fs.CreateDirectory(ctx, "/dir")
+	asserts.NoError(err)
+	asserts.Equal(ErrFileExisted, fs.RestoreTrash(ctx, []uint{dirTrash.ID}, ""))
+
+	// 还原至指定目录
+	_, err = fs.CreateDirectory(ctx, "/restored")
+	asserts.NoError(err)
+	asserts.NoError(fs.RestoreTrash(ctx, []uint{dirTrash.ID}, "/restored"))
+	exist, _ = fs.IsFileExist("/restored/dir/1.txt")
+	asserts.True(exist)
+
+	// 不存在的回收站对象
+	asserts.Equal(ErrObjectNotExist, fs.RestoreTrash(ctx, []uint{dirTrash.ID}, ""))
+
+	// 彻底删除，归还容量
+	asserts.NoError(fs.PurgeTrash(ctx, []model.Trash{fileTrash}))
+	trashes, err = model.GetTrashByUID(user.ID)
+	asserts.NoError(err)
+	asserts.Len(trashes, 0)
+	var count int
+	asserts.NoError(model.DB.Unscoped().Model(&model.File{}).Where("id = ?", file2.ID).Count(&count).Error)
+	asserts.Equal(0, count)
+	refreshed, err := model.GetUserByID(user.ID)
+	asserts.NoError(err)
+	asserts.EqualValues(1, refreshed.Storage)
+
+	// 用户组未启用回收站时直接删除
+	fs.User.Group.OptionsSerialized.TrashRetention = 0
+	exist, restored := fs.IsFileExist("/restored/dir/1.txt")
+	asserts.True(exist)
+	asserts.NoError(fs.Trash(ctx, []uint{}, []uint{restored.ID}))
+	trashes, err = model.GetTrashByUID(user.ID)
+	asserts.NoError(err)
+	asserts.Len(trashes, 0)
+	exist, _ = fs.IsFileExist("/restored/dir/1.txt")
+	asserts.False(exist)
+}
@@ -9,6 +9,7 @@ import (
 	model "github.com/cloudreve/Cloudreve/v3/models"
 	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/metrics"
 	"github.com/cloudreve/Cloudreve/v3/pkg/request"
 	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
 	"github.com/cloudreve/Cloudreve/v3/pkg/util"
@@ -51,6 +52,11 @@ func (fs *FileSystem) Upload(ctx context.Context, file *fsctx.FileStream) (err e
 
 	// 保存文件
 	if file.Mode&fsctx.Nop != fsctx.Nop {
+		// 完整写入的文件计算内容摘要，用于去重
+		if file.Mode&fsctx.Append != fsctx.Append && file.Size > 0 {
+			file.ComputeHash()
+		}
+
 		// 处理客户端未完成上传时，关闭连接
 		go fs.CancelUpload(ctx, savePath, file)
 
@@ -59,6 +65,7 @@ func (fs *FileSystem) Upload(ctx context.Context, file *fsctx.FileStream) (err e
 			fs.Trigger(ctx, "AfterUploadFailed", file)
 			return err
 		}
+		metrics.ObserveTransfer(fs.PolicyType(), metrics.DirectionUploaded, file.Size)
 	}
 
 	// 上传完成后的钩子
@@ -69,7 +76,7 @@ func (fs *FileSystem) Upload(ctx context.Context, file *fsctx.FileStream) (err e
 		followUpErr := fs.Trigger(ctx, "AfterValidateFailed", file)
 		// 失败后再失败...
 		if followUpErr != nil {
-			util.Log().Debug("AfterValidateFailed hook execution failed: %s", followUpErr)
+			util.Log().WithContext(ctx).Debug("AfterValidateFailed hook execution failed: %s", followUpErr)
 		}
 
 		return err
@@ -113,13 +120,13 @@ func (fs *FileSystem) CancelUpload(ctx context.Context, path string, file fsctx.
 			// 客户端正常关闭，不执行操作
 		default:
 			// 客户端取消上传，删除临时文件
-			util.Log().Debug("Client canceled upload.")
+			util.Log().WithContext(ctx).Debug("Client canceled upload.")
 			if fs.Hooks["AfterUploadCanceled"] == nil {
 				return
 			}
 			err := fs.Trigger(ctx, "AfterUploadCanceled", file)
 			if err != nil {
-				util.Log().Debug("AfterUploadCanceled hook execution failed: %s", err)
+				util.Log().WithContext(ctx).Debug("AfterUploadCanceled hook execution failed: %s", err)
 			}
 		}
 
@@ -159,6 +166,9 @@ func (fs *FileSystem) CreateUploadSession(ctx context.Context, file *fsctx.FileS
 		LastModified:   file.LastModified,
 		CallbackSecret: util.RandStringRunes(32),
 	}
+	if shareID, ok := ctx.Value(fsctx.UploadShareCtx).(uint); ok {
+		uploadSession.ShareID = shareID
+	}
 
 	// 获取上传凭证
 	credential, err := fs.Handler.Token(ctx, int64(callBackSessionTTL), uploadSession, file)
@@ -186,6 +196,8 @@ func (fs *FileSystem) CreateUploadSession(ctx context.Context, file *fsctx.FileS
 		return nil, err
 	}
 
+	metrics.ObserveUploadSession(fs.Policy.Type, metrics.SessionCreated)
+
 	// 补全上传凭证其他信息
 	credential.Expires = time.Now().Add(time.Duration(callBackSessionTTL) * time.Second).Unix()
 
@@ -0,0 +1,244 @@
+package filesystem
+
+import (
+	"context"
+	"fmt"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+	"github.com/cloudreve/Cloudreve/v3/pkg/webhook"
+)
+
+/* ================
+	 历史版本相关
+   ================
+*/
+
+// UseVersionHooks 在覆盖文件前尝试为原有内容保留历史版本。用户组启用了历史版本时，
+// 新内容会被保存至新的源文件，并挂载更新文件所需的全部钩子，返回 true；
+// 否则不做任何修改，返回 false，由调用方按原有方式覆盖文件。
+// 原有内容被其他文件共用时，历史版本通过 Blob 的引用计数与其他文件共用源文件
+func (fs *FileSystem) UseVersionHooks(ctx context.Context, originFile *model.File, file *fsctx.FileStream) bool {
+	if fs.User.Group.OptionsSerialized.MaxVersions <= 0 {
+		return false
+	}
+
+	if err := originFile.LinkSharedSource(); err != nil {
+		util.Log().WithContext(ctx).Warning("Failed to link shared source of file %d: %s", originFile.ID, err)
+		return false
+	}
+
+	previous := *originFile
+	fs.Policy = originFile.GetPolicy()
+	originFile.SourceName = fs.GenerateSavePath(ctx, file)
+	file.Mode &= ^fsctx.Overwrite
+
+	fs.Use("BeforeUpload", HookResetPolicy)
+	fs.Use("BeforeUpload", HookValidateFile)
+	if fs.User.Group.OptionsSerialized.VersionQuota {
+		fs.Use("BeforeUpload", HookValidateCapacity)
+	} else {
+		fs.Use("BeforeUpload", HookValidateCapacityDiff)
+	}
+	fs.Use("AfterUpload", NewKeepVersionHook(&previous))
+	fs.Use("AfterUploadCanceled", HookDeleteVersionTempFile)
+	fs.Use("AfterValidateFailed", HookDeleteVersionTempFile)
+
+	return true
+}
+
+// NewKeepVersionHook 返回更新文件内容并将 previous 的内容保存为历史版本的钩子
+func NewKeepVersionHook(previous *model.File) Hook {
+	return func(ctx context.Context, fs *FileSystem, newFile fsctx.FileHeader) error {
+		originFile, ok := ctx.Value(fsctx.FileModelCtx).(model.File)
+		if !ok {
+			return ErrObjectNotExist
+		}
+
+		version := model.NewFileVersion(previous, fs.User.Group.OptionsSerialized.VersionQuota)
+		originFile.SourceName = previous.SourceName
+		if err := originFile.UpdateWithVersion(version, newFile.Info().SavePath, newFile.Info().Size); err != nil {
+			return err
+		}
+
+		newFile.SetModel(&originFile)
+		fs.pruneVersions(ctx, originFile.ID)
+		IndexContent(&originFile)
+		publishFile(ctx, webhook.EventFileOverwritten, &originFile, newFile.Info().VirtualPath)
+		return nil
+	}
+}
+
+// HookDeleteVersionTempFile 文件记录尚未指向新内容时，删除已保存的新内容
+func HookDeleteVersionTempFile(ctx context.Context, fs *FileSystem, file fsctx.FileHeader) error {
+	originFile, ok := ctx.Value(fsctx.FileModelCtx).(model.File)
+	if !ok {
+		return ErrObjectNotExist
+	}
+
+	current, err := model.GetFilesByIDs([]uint{originFile.ID}, originFile.UserID)
+	if err != nil || len(current) == 0 || current[0].SourceName == file.Info().SavePath {
+		return nil
+	}
+
+	return HookDeleteTempFile(ctx, fs, file)
+}
+
+// pruneVersions 删除超出用户组数量限制的历史版本
+func (fs *FileSystem) pruneVersions(ctx context.Context, fileID uint) {
+	versions, err := model.GetVersionsByFileID(fileID, fs.User.ID)
+	if err != nil {
+		util.Log().WithContext(ctx).Warning("Failed to list versions of file %d: %s", fileID, err)
+		return
+	}
+
+	limit := fs.User.Group.OptionsSerialized.MaxVersions
+	if len(versions) <= limit {
+		return
+	}
+
+	if err := fs.DeleteVersions(ctx, versions[limit:]); err != nil {
+		util.Log().WithContext(ctx).Warning("Failed to prune versions of file %d: %s", fileID, err)
+	}
+}
+
+// RestoreVersion 将文件内容还原为给定的历史版本。用户组启用了历史版本时，
+// 文件当前的内容会被保存为新的历史版本，否则会被删除
+func (fs *FileSystem) RestoreVersion(ctx context.Context, file *model.File, version *model.FileVersion) error {
+	// 当前内容被其他文件共用时，只需解除引用
+	fileList, err := model.RemoveFilesWithSoftLinks([]model.File{*file})
+	if err != nil {
+		return ErrDBListObjects.WithError(err)
+	}
+	shared := len(fileList) == 0
+
+	var previous *model.FileVersion
+	if fs.User.Group.OptionsSerialized.MaxVersions > 0 {
+		if err := file.LinkSharedSource(); err != nil {
+			return ErrDBVersionObjects.WithError(err)
+		}
+		previous = model.NewFileVersion(file, fs.User.Group.OptionsSerialized.VersionQuota)
+	}
+
+	// 验证用户容量
+	required := version.Size
+	if previous != nil && previous.CountQuota {
+		required += file.Size
+	}
+	released := file.Size
+	if version.CountQuota {
+		released += version.Size
+	}
+	if required > released && fs.User.GetRemainingCapacity() < required-released {
+		return ErrInsufficientCapacity
+	}
+
+	origin := *file
+	if err := file.RestoreVersion(version, previous); err != nil {
+		return ErrDBVersionObjects.WithError(err)
+	}
+
+	if previous == nil && !shared {
+		fs.FileTarget = []model.File{origin}
+		if err := fs.resetPolicyToFirstFile(ctx); err != nil {
+			return err
+		}
+		if _, err := fs.Handler.Delete(ctx, []string{origin.SourceName}); err != nil {
+			util.Log().WithContext(ctx).Warning("Failed to delete replaced content of file %d: %s", file.ID, err)
+		}
+		fs.CleanTargets()
+	}
+
+	IndexContent(file)
+	fs.pruneVersions(ctx, file.ID)
+	return nil
+}
+
+// DeleteVersions 删除历史版本及其对应的源文件，源文件删除失败的版本会被保留
+func (fs *FileSystem) DeleteVersions(ctx context.Context, versions []model.FileVersion) error {
+	return fs.deleteVersions(ctx, versions, false)
+}
+
+// deleteVersions 删除历史版本，unlink 为 true 时只删除记录
+func (fs *FileSystem) deleteVersions(ctx context.Context, versions []model.FileVersion, unlink bool) error {
+	if len(versions) == 0 {
+		return nil
+	}
+
+	// 统计对源文件的引用
+	refs := make(map[uint]int)
+	blobIDs := make([]uint, 0)
+	for _, version := range versions {
+		if version.BlobID != 0 {
+			if refs[version.BlobID] == 0 {
+				blobIDs = append(blobIDs, version.BlobID)
+			}
+			refs[version.BlobID]++
+		}
+	}
+
+	blobs := make(map[uint]model.Blob)
+	if len(blobIDs) > 0 {
+		var err error
+		if blobs, err = model.GetBlobsByIDs(blobIDs); err != nil {
+			return ErrDBListObjects.WithError(err)
+		}
+	}
+
+	// 根据存储策略将不再被引用的源文件分组
+	policyGroup := make(map[uint][]string)
+	for _, version := range versions {
+		if blob, ok := blobs[version.BlobID]; ok && blob.RefCount > refs[version.BlobID] {
+			continue
+		}
+		if !util.ContainsString(policyGroup[version.PolicyID], version.SourceName) {
+			policyGroup[version.PolicyID] = append(policyGroup[version.PolicyID], version.SourceName)
+		}
+	}
+
+	failed := make(map[uint][]string)
+	if !unlink {
+		for policyID, sources := range policyGroup {
+			policy, err := model.GetPolicyByID(policyID)
+			if err != nil {
+				failed[policyID] = sources
+				continue
+			}
+
+			fs.Policy = &policy
+			if err := fs.DispatchHandler(); err != nil {
+				failed[policyID] = sources
+				continue
+			}
+
+			failedSources, err := fs.Handler.Delete(ctx, sources)
+			if err != nil {
+				util.Log().WithContext(ctx).Warning("Failed to delete version sources: %s", err)
+			}
+			failed[policyID] = failedSources
+		}
+	}
+
+	deleted := make([]model.FileVersion, 0, len(versions))
+	for _, version := range versions {
+		if !util.ContainsString(failed[version.PolicyID], version.SourceName) {
+			deleted = append(deleted, version)
+		}
+	}
+
+	if err := model.DeleteFileVersions(deleted); err != nil {
+		return ErrDBVersionObjects.WithError(err)
+	}
+
+	if notDeleted := len(versions) - len(deleted); notDeleted > 0 {
+		return serializer.NewError(
+			serializer.CodeNotFullySuccess,
+			fmt.Sprintf("Failed to delete %d version(s).", notDeleted),
+			nil,
+		)
+	}
+
+	return nil
+}
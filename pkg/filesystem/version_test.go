@@ -0,0 +1,160 @@
+package filesystem
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
+	"github.com/cloudreve/Cloudreve/v3/pkg/conf"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileSystem_VersionLifecycleSQLite(t *testing.T) {
+	asserts := assert.New(t)
+	mockDB := model.DB
+	dbType := conf.DatabaseConfig.Type
+	conf.DatabaseConfig.Type = "sqlite"
+	model.DB, _ = gorm.Open("sqlite", ":memory:")
+	defer func() {
+		model.DB = mockDB
+		conf.DatabaseConfig.Type = dbType
+	}()
+	model.DB.AutoMigrate(&model.User{}, &model.Group{}, &model.Policy{}, &model.Folder{}, &model.File{},
+		&model.Share{}, &model.FileVersion{}, &model.Blob{})
+
+	dir := t.TempDir()
+	policy := &model.Policy{
+		Type:         "local",
+		Name:         "TestVersion",
+		DirNameRule:  filepath.ToSlash(dir),
+		AutoRename:   true,
+		FileNameRule: "{randomkey16}_{originname}",
+	}
+	asserts.NoError(model.DB.Create(policy).Error)
+	cache.Deletes([]string{strconv.Itoa(int(policy.ID))}, "policy_")
+	user := &model.User{Email: "version@cloudreve.org", Storage: 1}
+	user.Group.MaxStorage = 1024
+	user.Group.OptionsSerialized.MaxVersions = 2
+	user.Group.OptionsSerialized.VersionQuota = true
+	asserts.NoError(model.DB.Create(user).Error)
+	root, err := user.Root()
+	asserts.NoError(err)
+
+	source := filepath.ToSlash(filepath.Join(dir, "origin.txt"))
+	asserts.NoError(ioutil.WriteFile(source, []byte("a"), 0644))
+	file := &model.File{Name: "1.txt", SourceName: source, UserID: user.ID, FolderID: root.ID, PolicyID: policy.ID, Size: 1}
+	asserts.NoError(model.DB.Create(file).Error)
+
+	ctx := context.Background()
+	update := func(file *model.File, content string) {
+		fs := &FileSystem{User: user, Policy: policy}
+		files, err := model.GetFilesByIDs([]uint{file.ID}, user.ID)
+		asserts.NoError(err)
+		origin := files[0]
+		fileData := &fsctx.FileStream{
+			File: ioutil.NopCloser(strings.NewReader(content)),
+			Size: uint64(len(content)),
+			Name: origin.Name,
+			Mode: fsctx.Overwrite,
+		}
+		asserts.True(fs.UseVersionHooks(ctx, &origin, fileData))
+		asserts.NoError(fs.Upload(context.WithValue(ctx, fsctx.FileModelCtx, origin), fileData))
+	}
+	current := func(file *model.File) (*model.File, string) {
+		files, err := model.GetFilesByIDs([]uint{file.ID}, user.ID)
+		asserts.NoError(err)
+		content, _ := ioutil.ReadFile(files[0].SourceName)
+		return &files[0], string(content)
+	}
+	storage := func() uint64 {
+		refreshed, err := model.GetUserByID(user.ID)
+		asserts.NoError(err)
+		return refreshed.Storage
+	}
+
+	// 覆盖时保留原有内容
+	update(file, "bb")
+	update(file, "ccc")
+	_, content := current(file)
+	asserts.Equal("ccc", content)
+	versions, err := model.GetVersionsByFileID(file.ID, user.ID)
+	asserts.NoError(err)
+	asserts.Len(versions, 2)
+	asserts.EqualValues(2, versions[0].Size)
+	asserts.Equal(source, versions[1].SourceName)
+	asserts.EqualValues(6, storage())
+
+	// 超出数量限制的旧版本被删除
+	update(file, "dddd")
+	versions, err = model.GetVersionsByFileID(file.ID, user.ID)
+	asserts.NoError(err)
+	asserts.Len(versions, 2)
+	asserts.EqualValues(3, versions[0].Size)
+	asserts.EqualValues(2, versions[1].Size)
+	_, err = os.Stat(source)
+	asserts.True(os.IsNotExist(err))
+	asserts.EqualValues(9, storage())
+
+	// 还原版本，当前内容成为新的历史版本
+	fs := &FileSystem{User: user, Policy: policy}
+	latest, _ := current(file)
+	asserts.NoError(fs.RestoreVersion(ctx, latest, &versions[1]))
+	_, content = current(file)
+	asserts.Equal("bb", content)
+	versions, err = model.GetVersionsByFileID(file.ID, user.ID)
+	asserts.NoError(err)
+	asserts.Len(versions, 2)
+	asserts.EqualValues(4, versions[0].Size)
+	asserts.EqualValues(9, storage())
+
+	// 删除历史版本
+	asserts.NoError(fs.DeleteVersions(ctx, []model.FileVersion{versions[1]}))
+	_, err = os.Stat(versions[1].SourceName)
+	asserts.True(os.IsNotExist(err))
+	asserts.EqualValues(6, storage())
+
+	// 删除文件时一并删除历史版本
+	asserts.NoError(fs.Delete(ctx, []uint{}, []uint{file.ID}, false, false))
+	versions, err = model.GetVersionsByFileID(file.ID, user.ID)
+	asserts.NoError(err)
+	asserts.Len(versions, 0)
+	asserts.EqualValues(0, storage())
+
+	// 与其他文件共用的内容同样保留为历史版本
+	shared := filepath.ToSlash(filepath.Join(dir, "shared.txt"))
+	asserts.NoError(ioutil.WriteFile(shared, []byte("s"), 0644))
+	file = &model.File{Name: "2.txt", SourceName: shared, UserID: user.ID, FolderID: root.ID, PolicyID: policy.ID, Size: 1}
+	asserts.NoError(model.DB.Create(file).Error)
+	copied := &model.File{Name: "3.txt", SourceName: shared, UserID: user.ID, FolderID: root.ID, PolicyID: policy.ID, Size: 1}
+	asserts.NoError(model.DB.Create(copied).Error)
+	update(file, "ee")
+	_, content = current(file)
+	asserts.Equal("ee", content)
+	_, content = current(copied)
+	asserts.Equal("s", content)
+	versions, err = model.GetVersionsByFileID(file.ID, user.ID)
+	asserts.NoError(err)
+	asserts.Len(versions, 1)
+	asserts.Equal(shared, versions[0].SourceName)
+	asserts.NotZero(versions[0].BlobID)
+
+	// 删除历史版本时保留仍被其他文件使用的源文件
+	asserts.NoError(fs.DeleteVersions(ctx, versions))
+	_, content = current(copied)
+	asserts.Equal("s", content)
+	blobs, err := model.GetBlobsByIDs([]uint{versions[0].BlobID})
+	asserts.NoError(err)
+	asserts.Equal(1, blobs[versions[0].BlobID].RefCount)
+
+	// 用户组未启用历史版本
+	fs.User.Group.OptionsSerialized.MaxVersions = 0
+	asserts.False(fs.UseVersionHooks(ctx, file, &fsctx.FileStream{}))
+}
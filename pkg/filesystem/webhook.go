@@ -0,0 +1,57 @@
+package filesystem
+
+import (
+	"context"
+	"path"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/hashid"
+	"github.com/cloudreve/Cloudreve/v3/pkg/webhook"
+)
+
+// publishFile 发布单个文件的事件，dir 为文件所在目录，为空时使用文件记录中的位置
+func publishFile(ctx context.Context, name string, file *model.File, dir string) {
+	if !webhook.Enabled() {
+		return
+	}
+
+	if dir == "" {
+		dir = file.Position
+	}
+
+	data := map[string]interface{}{
+		"id":   hashid.HashID(file.ID, hashid.FileID),
+		"name": file.Name,
+		"size": file.Size,
+	}
+	if dir != "" {
+		data["path"] = path.Join(dir, file.Name)
+	}
+
+	webhook.Publish(ctx, name, file.UserID, data)
+}
+
+// publishObjects 发布对目录和文件操作的事件，操作对象以 HashID 列表表示
+func (fs *FileSystem) publishObjects(ctx context.Context, name string, dirs, files []uint, extra map[string]interface{}) {
+	if !webhook.Enabled() {
+		return
+	}
+
+	data := map[string]interface{}{
+		"dirs":  hashIDs(dirs, hashid.FolderID),
+		"files": hashIDs(files, hashid.FileID),
+	}
+	for k, v := range extra {
+		data[k] = v
+	}
+
+	webhook.Publish(ctx, name, fs.User.ID, data)
+}
+
+func hashIDs(ids []uint, t int) []string {
+	res := make([]string, len(ids))
+	for i, id := range ids {
+		res[i] = hashid.HashID(id, t)
+	}
+	return res
+}
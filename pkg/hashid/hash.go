@@ -16,6 +16,8 @@ const (
 	TagID           // 标签ID
 	PolicyID        // 存储策略ID
 	SourceLinkID
+	TrashID       // 回收站对象ID
+	FileVersionID // 文件历史版本ID
 )
 
 var (
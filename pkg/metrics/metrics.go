@@ -0,0 +1,193 @@
+package metrics
+
+import (
+	"crypto/subtle"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "cloudreve"
+
+// 传输方向
+const (
+	DirectionServed   = "served"
+	DirectionUploaded = "uploaded"
+)
+
+// 上传会话事件
+const (
+	SessionCreated   = "created"
+	SessionCompleted = "completed"
+)
+
+// Registry 监控指标注册表
+var Registry = prometheus.NewRegistry()
+
+var (
+	// HTTPRequestDuration HTTP 请求耗时，按路由分组、方法及状态码区分
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "HTTP request latency by route group, method and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"group", "method", "status"})
+
+	// TransferBytes 经由本机中转的文件字节数，按存储策略类型及方向区分
+	TransferBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "transfer_bytes_total",
+		Help:      "Bytes of file content served or uploaded through this instance, by policy type.",
+	}, []string{"policy", "direction"})
+
+	// UploadSessions 上传会话创建及完成次数，按存储策略类型区分
+	UploadSessions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "upload_sessions_total",
+		Help:      "Upload sessions created or completed, by policy type.",
+	}, []string{"policy", "event"})
+
+	// CacheRequests 缓存读取次数，按键前缀及是否命中区分
+	CacheRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "cache",
+		Name:      "requests_total",
+		Help:      "Cache lookups by key prefix and result.",
+	}, []string{"prefix", "result"})
+)
+
+func init() {
+	Registry.MustRegister(
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+		HTTPRequestDuration,
+		TransferBytes,
+		UploadSessions,
+		CacheRequests,
+	)
+}
+
+// gaugeCollector 在采集时才求值的指标
+type gaugeCollector struct {
+	desc  *prometheus.Desc
+	label string
+	fn    func() map[string]float64
+}
+
+func (g *gaugeCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- g.desc
+}
+
+func (g *gaugeCollector) Collect(ch chan<- prometheus.Metric) {
+	for value, v := range g.fn() {
+		if g.label == "" {
+			ch <- prometheus.MustNewConstMetric(g.desc, prometheus.GaugeValue, v)
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(g.desc, prometheus.GaugeValue, v, value)
+	}
+}
+
+// RegisterGauge 注册采集时求值的指标。label 为空时 fn 返回的 map 应只包含一项，
+// 否则 map 的键作为 label 的值。
+func RegisterGauge(name, help, label string, fn func() map[string]float64) error {
+	var labels []string
+	if label != "" {
+		labels = []string{label}
+	}
+
+	return Registry.Register(&gaugeCollector{
+		desc:  prometheus.NewDesc(prometheus.BuildFQName(namespace, "", name), help, labels, nil),
+		label: label,
+		fn:    fn,
+	})
+}
+
+// ObserveTransfer 记录经由本机中转的文件字节数
+func ObserveTransfer(policyType, direction string, size uint64) {
+	if size == 0 {
+		return
+	}
+	TransferBytes.WithLabelValues(policyType, direction).Add(float64(size))
+}
+
+// ObserveUploadSession 记录上传会话事件
+func ObserveUploadSession(policyType, event string) {
+	UploadSessions.WithLabelValues(policyType, event).Inc()
+}
+
+// ObserveCache 记录缓存读取结果，以键的首个下划线前的部分作为前缀
+func ObserveCache(key string, hit bool) {
+	prefix := "other"
+	if i := strings.Index(key, "_"); i > 0 {
+		prefix = key[:i]
+	}
+
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	CacheRequests.WithLabelValues(prefix, result).Inc()
+}
+
+// Handler 返回输出监控指标的 HTTP 处理器，token 非空时要求请求携带对应的 Bearer Token
+func Handler(token string) http.Handler {
+	handler := promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+	if token == "" {
+		return handler
+	}
+
+	expected := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="metrics"`)
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// servedWriter 统计写出字节数的 ResponseWriter
+type servedWriter struct {
+	http.ResponseWriter
+	policyType string
+}
+
+func (w *servedWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	ObserveTransfer(w.policyType, DirectionServed, uint64(n))
+	return n, err
+}
+
+// Flush 透传 http.Flusher
+func (w *servedWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// ReadFrom 透传 io.ReaderFrom，底层不支持时退化为逐块写出
+func (w *servedWriter) ReadFrom(r io.Reader) (int64, error) {
+	if readerFrom, ok := w.ResponseWriter.(io.ReaderFrom); ok {
+		n, err := readerFrom.ReadFrom(r)
+		ObserveTransfer(w.policyType, DirectionServed, uint64(n))
+		return n, err
+	}
+
+	return io.Copy(writerOnly{w}, r)
+}
+
+// writerOnly 隐藏 ReadFrom，避免 io.Copy 递归调用
+type writerOnly struct {
+	io.Writer
+}
+
+// ServedWriter 包装 ResponseWriter，将写出的文件内容计入指定存储策略类型的发送字节数
+func ServedWriter(w http.ResponseWriter, policyType string) http.ResponseWriter {
+	return &servedWriter{ResponseWriter: w, policyType: policyType}
+}
@@ -0,0 +1,104 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandler(t *testing.T) {
+	a := assert.New(t)
+
+	// 未设置 Token
+	{
+		rec := httptest.NewRecorder()
+		Handler("").ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+		a.Equal(http.StatusOK, rec.Code)
+		a.Contains(rec.Body.String(), "go_goroutines")
+	}
+
+	// Token 错误
+	{
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/metrics", nil)
+		req.Header.Set("Authorization", "Bearer wrong")
+		Handler("secret").ServeHTTP(rec, req)
+		a.Equal(http.StatusUnauthorized, rec.Code)
+	}
+
+	// Token 正确
+	{
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/metrics", nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		Handler("secret").ServeHTTP(rec, req)
+		a.Equal(http.StatusOK, rec.Code)
+	}
+}
+
+func TestRegisterGauge(t *testing.T) {
+	a := assert.New(t)
+
+	a.NoError(RegisterGauge("test_gauge", "Test gauge.", "state", func() map[string]float64 {
+		return map[string]float64{"idle": 1, "busy": 2}
+	}))
+	a.NoError(RegisterGauge("test_gauge_single", "Test gauge.", "", func() map[string]float64 {
+		return map[string]float64{"": 3}
+	}))
+	// 重复注册
+	a.Error(RegisterGauge("test_gauge", "Test gauge.", "state", func() map[string]float64 {
+		return nil
+	}))
+
+	a.NoError(testutil.GatherAndCompare(Registry, strings.NewReader(`
+# HELP cloudreve_test_gauge Test gauge.
+# TYPE cloudreve_test_gauge gauge
+cloudreve_test_gauge{state="busy"} 2
+cloudreve_test_gauge{state="idle"} 1
+# HELP cloudreve_test_gauge_single Test gauge.
+# TYPE cloudreve_test_gauge_single gauge
+cloudreve_test_gauge_single 3
+`), "cloudreve_test_gauge", "cloudreve_test_gauge_single"))
+}
+
+func TestObserveCache(t *testing.T) {
+	a := assert.New(t)
+
+	ObserveCache("setting_siteName", true)
+	ObserveCache("setting_siteURL", false)
+	ObserveCache("nounderscore", false)
+
+	a.EqualValues(1, testutil.ToFloat64(CacheRequests.WithLabelValues("setting", "hit")))
+	a.EqualValues(1, testutil.ToFloat64(CacheRequests.WithLabelValues("setting", "miss")))
+	a.EqualValues(1, testutil.ToFloat64(CacheRequests.WithLabelValues("other", "miss")))
+}
+
+func TestServedWriter(t *testing.T) {
+	a := assert.New(t)
+
+	rec := httptest.NewRecorder()
+	w := ServedWriter(rec, "test")
+	w.Write([]byte("hello"))
+	w.Write([]byte("world"))
+
+	a.Equal("helloworld", rec.Body.String())
+	a.EqualValues(10, testutil.ToFloat64(TransferBytes.WithLabelValues("test", DirectionServed)))
+
+	// 透传 Flusher 和 ReaderFrom
+	w.(http.Flusher).Flush()
+	a.True(rec.Flushed)
+	n, err := w.(io.ReaderFrom).ReadFrom(strings.NewReader("!!"))
+	a.NoError(err)
+	a.EqualValues(2, n)
+	a.Equal("helloworld!!", rec.Body.String())
+	a.EqualValues(12, testutil.ToFloat64(TransferBytes.WithLabelValues("test", DirectionServed)))
+
+	// 空内容不计入
+	ObserveTransfer("test", DirectionUploaded, 0)
+	a.EqualValues(0, testutil.ToFloat64(TransferBytes.WithLabelValues("test", DirectionUploaded)))
+}
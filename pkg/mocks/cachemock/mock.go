@@ -15,6 +15,11 @@ func (c CacheClientMock) Get(key string) (interface{}, bool) {
 	return args.Get(0), args.Bool(1)
 }
 
+func (c CacheClientMock) IncrBy(key string, delta int, ttl int) (int, error) {
+	args := c.Called(key, delta, ttl)
+	return args.Int(0), args.Error(1)
+}
+
 func (c CacheClientMock) Gets(keys []string, prefix string) (map[string]interface{}, []string) {
 	args := c.Called(keys, prefix)
 	return args.Get(0).(map[string]interface{}), args.Get(1).([]string)
@@ -2,11 +2,13 @@ package mq
 
 import (
 	"encoding/gob"
-	"github.com/cloudreve/Cloudreve/v3/pkg/aria2/common"
-	"github.com/cloudreve/Cloudreve/v3/pkg/aria2/rpc"
 	"strconv"
 	"sync"
 	"time"
+
+	"github.com/cloudreve/Cloudreve/v3/pkg/aria2/common"
+	"github.com/cloudreve/Cloudreve/v3/pkg/aria2/rpc"
+	"github.com/cloudreve/Cloudreve/v3/pkg/conf"
 )
 
 // Message 消息事件正文
@@ -38,15 +40,37 @@ type MQ interface {
 
 	// 取消订阅一个消息主题
 	Unsubscribe(string, <-chan Message)
+
+	// 移除一个消息主题上注册的所有回调函数
+	UnsubscribeCallback(string)
 }
 
 var GlobalMQ = NewMQ()
 
+// Init 初始化全局消息队列，配置了 Redis 时使用 Redis 在多个主机实例间分发消息
+func Init() {
+	if conf.RedisConfig.Server != "" {
+		GlobalMQ = NewRedisMQ(
+			conf.RedisConfig.Network,
+			conf.RedisConfig.Server,
+			conf.RedisConfig.User,
+			conf.RedisConfig.Password,
+			conf.RedisConfig.DB,
+		)
+	}
+}
+
 func NewMQ() MQ {
-	return &inMemoryMQ{
+	return newInMemoryMQ()
+}
+
+func newInMemoryMQ() *inMemoryMQ {
+	mq := &inMemoryMQ{
 		topics:    make(map[string][]chan Message),
 		callbacks: make(map[string][]CallbackFunc),
 	}
+	mq.aria2Notifier = aria2Notifier{publish: mq.Publish}
+	return mq
 }
 
 func init() {
@@ -55,6 +79,7 @@ func init() {
 }
 
 type inMemoryMQ struct {
+	aria2Notifier
 	topics    map[string][]chan Message
 	callbacks map[string][]CallbackFunc
 	sync.RWMutex
@@ -100,12 +125,31 @@ func (i *inMemoryMQ) SubscribeCallback(topic string, callbackFunc CallbackFunc)
 }
 
 func (i *inMemoryMQ) Unsubscribe(topic string, sub <-chan Message) {
+	i.unsubscribe(topic, sub)
+}
+
+func (i *inMemoryMQ) UnsubscribeCallback(topic string) {
+	i.unsubscribeCallback(topic)
+}
+
+// unsubscribeCallback 移除主题上的所有回调函数，返回移除的数量
+func (i *inMemoryMQ) unsubscribeCallback(topic string) int {
+	i.Lock()
+	defer i.Unlock()
+
+	n := len(i.callbacks[topic])
+	delete(i.callbacks, topic)
+	return n
+}
+
+// unsubscribe 取消订阅，返回给定的订阅是否存在
+func (i *inMemoryMQ) unsubscribe(topic string, sub <-chan Message) bool {
 	i.Lock()
 	defer i.Unlock()
 
 	subscribers, ok := i.topics[topic]
 	if !ok {
-		return
+		return false
 	}
 
 	var newSubs []chan Message
@@ -117,11 +161,17 @@ func (i *inMemoryMQ) Unsubscribe(topic string, sub <-chan Message) {
 	}
 
 	i.topics[topic] = newSubs
+	return len(newSubs) < len(subscribers)
+}
+
+// aria2Notifier 以 GID 为主题发布 aria2 事件
+type aria2Notifier struct {
+	publish func(string, Message)
 }
 
-func (i *inMemoryMQ) Aria2Notify(events []rpc.Event, status int) {
+func (n aria2Notifier) Aria2Notify(events []rpc.Event, status int) {
 	for _, event := range events {
-		i.Publish(event.Gid, Message{
+		n.publish(event.Gid, Message{
 			TriggeredBy: event.Gid,
 			Event:       strconv.FormatInt(int64(status), 10),
 			Content:     events,
@@ -130,31 +180,31 @@ func (i *inMemoryMQ) Aria2Notify(events []rpc.Event, status int) {
 }
 
 // OnDownloadStart 下载开始
-func (i *inMemoryMQ) OnDownloadStart(events []rpc.Event) {
-	i.Aria2Notify(events, common.Downloading)
+func (n aria2Notifier) OnDownloadStart(events []rpc.Event) {
+	n.Aria2Notify(events, common.Downloading)
 }
 
 // OnDownloadPause 下载暂停
-func (i *inMemoryMQ) OnDownloadPause(events []rpc.Event) {
-	i.Aria2Notify(events, common.Paused)
+func (n aria2Notifier) OnDownloadPause(events []rpc.Event) {
+	n.Aria2Notify(events, common.Paused)
 }
 
 // OnDownloadStop 下载停止
-func (i *inMemoryMQ) OnDownloadStop(events []rpc.Event) {
-	i.Aria2Notify(events, common.Canceled)
+func (n aria2Notifier) OnDownloadStop(events []rpc.Event) {
+	n.Aria2Notify(events, common.Canceled)
 }
 
 // OnDownloadComplete 下载完成
-func (i *inMemoryMQ) OnDownloadComplete(events []rpc.Event) {
-	i.Aria2Notify(events, common.Complete)
+func (n aria2Notifier) OnDownloadComplete(events []rpc.Event) {
+	n.Aria2Notify(events, common.Complete)
 }
 
 // OnDownloadError 下载出错
-func (i *inMemoryMQ) OnDownloadError(events []rpc.Event) {
-	i.Aria2Notify(events, common.Error)
+func (n aria2Notifier) OnDownloadError(events []rpc.Event) {
+	n.Aria2Notify(events, common.Error)
 }
 
 // OnBtDownloadComplete BT下载完成
-func (i *inMemoryMQ) OnBtDownloadComplete(events []rpc.Event) {
-	i.Aria2Notify(events, common.Complete)
+func (n aria2Notifier) OnBtDownloadComplete(events []rpc.Event) {
+	n.Aria2Notify(events, common.Complete)
 }
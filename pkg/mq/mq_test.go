@@ -99,6 +99,23 @@ func TestPublishAndSubscribe(t *testing.T) {
 		default:
 		}
 	}
+
+	// callback unsubscribe
+	{
+		topic := "callback unsubscribe"
+		called := make(chan struct{}, 1)
+		mq.SubscribeCallback(topic, func(message Message) {
+			called <- struct{}{}
+		})
+		mq.UnsubscribeCallback(topic)
+		mq.Publish(topic, Message{})
+
+		select {
+		case <-called:
+			t.Error()
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
 }
 
 func TestAria2Interface(t *testing.T) {
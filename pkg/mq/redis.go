@@ -0,0 +1,256 @@
+package mq
+
+import (
+	"bytes"
+	"encoding/gob"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+	"github.com/gomodule/redigo/redis"
+)
+
+const (
+	// redisChannelPrefix Redis 频道名前缀，其后附加数据库编号，避免共用 Redis 的站点互相干扰
+	redisChannelPrefix = "cloudreve:mq:"
+	// redisSubscribeTimeout 等待订阅生效的最长时间
+	redisSubscribeTimeout = 5 * time.Second
+	// redisReconnectInterval 订阅连接断开后重连的间隔
+	redisReconnectInterval = time.Second
+)
+
+// redisMQ 基于 Redis 发布订阅的消息队列。消息经 Redis 分发至所有实例，
+// 再由各实例分发给本地的订阅者；连接断开期间发布的消息会丢失
+type redisMQ struct {
+	aria2Notifier
+	local  *inMemoryMQ
+	pool   *redis.Pool
+	prefix string
+
+	mu      sync.Mutex
+	conn    *redis.PubSubConn          // 订阅连接，重连期间为空
+	refs    map[string]int             // 各主题的本地订阅数
+	active  map[string]bool            // 已在 Redis 上生效的订阅
+	waiters map[string][]chan struct{} // 等待订阅生效的调用方
+	closed  bool
+}
+
+// NewRedisMQ 创建基于 Redis 发布订阅的消息队列
+func NewRedisMQ(network, address, user, password, database string) MQ {
+	pool := &redis.Pool{
+		MaxIdle:     10,
+		IdleTimeout: 240 * time.Second,
+		TestOnBorrow: func(c redis.Conn, t time.Time) error {
+			_, err := c.Do("PING")
+			return err
+		},
+		Dial: func() (redis.Conn, error) {
+			db, err := strconv.Atoi(database)
+			if err != nil {
+				return nil, err
+			}
+
+			return redis.Dial(
+				network,
+				address,
+				redis.DialDatabase(db),
+				redis.DialUsername(user),
+				redis.DialPassword(password),
+			)
+		},
+	}
+
+	return newRedisMQ(pool, redisChannelPrefix+database+":")
+}
+
+func newRedisMQ(pool *redis.Pool, prefix string) *redisMQ {
+	mq := &redisMQ{
+		local:   newInMemoryMQ(),
+		pool:    pool,
+		prefix:  prefix,
+		refs:    make(map[string]int),
+		active:  make(map[string]bool),
+		waiters: make(map[string][]chan struct{}),
+	}
+	mq.aria2Notifier = aria2Notifier{publish: mq.Publish}
+
+	go mq.run()
+	return mq
+}
+
+func (r *redisMQ) Publish(topic string, message Message) {
+	var buffer bytes.Buffer
+	if err := gob.NewEncoder(&buffer).Encode(message); err != nil {
+		util.Log().Warning("Failed to encode message of topic %q, delivering to local subscribers only: %s", topic, err)
+		r.local.Publish(topic, message)
+		return
+	}
+
+	conn := r.pool.Get()
+	defer conn.Close()
+	if _, err := conn.Do("PUBLISH", r.prefix+topic, buffer.Bytes()); err != nil {
+		util.Log().Warning("Failed to publish message to Redis, delivering to local subscribers only: %s", err)
+		r.local.Publish(topic, message)
+	}
+}
+
+func (r *redisMQ) Subscribe(topic string, buffer int) <-chan Message {
+	ch := r.local.Subscribe(topic, buffer)
+	r.ref(topic)
+	return ch
+}
+
+func (r *redisMQ) SubscribeCallback(topic string, callbackFunc CallbackFunc) {
+	r.local.SubscribeCallback(topic, callbackFunc)
+	r.ref(topic)
+}
+
+func (r *redisMQ) Unsubscribe(topic string, sub <-chan Message) {
+	if r.local.unsubscribe(topic, sub) {
+		r.unref(topic)
+	}
+}
+
+func (r *redisMQ) UnsubscribeCallback(topic string) {
+	for n := r.local.unsubscribeCallback(topic); n > 0; n-- {
+		r.unref(topic)
+	}
+}
+
+// ref 增加主题的本地订阅数，并等待 Redis 上的订阅生效
+func (r *redisMQ) ref(topic string) {
+	r.mu.Lock()
+	r.refs[topic]++
+	if r.active[topic] {
+		r.mu.Unlock()
+		return
+	}
+
+	wait := make(chan struct{})
+	r.waiters[topic] = append(r.waiters[topic], wait)
+	if r.refs[topic] == 1 && r.conn != nil {
+		// 发送失败时连接会断开，由重连后的订阅恢复
+		_ = r.conn.Subscribe(r.prefix + topic)
+	}
+	r.mu.Unlock()
+
+	select {
+	case <-wait:
+	case <-time.After(redisSubscribeTimeout):
+		util.Log().Warning("Timed out waiting for Redis subscription of topic %q.", topic)
+	}
+}
+
+// unref 减少主题的本地订阅数，没有订阅者时取消 Redis 上的订阅
+func (r *redisMQ) unref(topic string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.refs[topic]--
+	if r.refs[topic] > 0 {
+		return
+	}
+
+	delete(r.refs, topic)
+	delete(r.active, topic)
+	if r.conn != nil {
+		_ = r.conn.Unsubscribe(r.prefix + topic)
+	}
+}
+
+// confirm 标记 Redis 上的订阅已生效，并唤醒等待的调用方
+func (r *redisMQ) confirm(topic string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.refs[topic] > 0 {
+		r.active[topic] = true
+	}
+
+	for _, wait := range r.waiters[topic] {
+		close(wait)
+	}
+	delete(r.waiters, topic)
+}
+
+// run 维持订阅连接，连接断开时重连并恢复所有订阅
+func (r *redisMQ) run() {
+	for {
+		conn, err := r.pool.Dial()
+		if err == nil {
+			psc := &redis.PubSubConn{Conn: conn}
+
+			r.mu.Lock()
+			if r.closed {
+				r.mu.Unlock()
+				conn.Close()
+				return
+			}
+
+			channels := make([]interface{}, 0, len(r.refs))
+			for topic := range r.refs {
+				channels = append(channels, r.prefix+topic)
+			}
+			if len(channels) > 0 {
+				err = psc.Subscribe(channels...)
+			}
+			r.conn = psc
+			r.mu.Unlock()
+
+			if err == nil {
+				err = r.receive(psc)
+			}
+
+			r.mu.Lock()
+			r.conn = nil
+			r.active = make(map[string]bool)
+			r.mu.Unlock()
+			psc.Close()
+		}
+
+		r.mu.Lock()
+		closed := r.closed
+		r.mu.Unlock()
+		if closed {
+			return
+		}
+
+		util.Log().Warning("Redis message queue connection lost, reconnecting: %s", err)
+		time.Sleep(redisReconnectInterval)
+	}
+}
+
+// receive 接收订阅的消息并分发给本地订阅者，直到连接出错
+func (r *redisMQ) receive(psc *redis.PubSubConn) error {
+	for {
+		switch v := psc.Receive().(type) {
+		case redis.Message:
+			var message Message
+			if err := gob.NewDecoder(bytes.NewReader(v.Data)).Decode(&message); err != nil {
+				util.Log().Warning("Failed to decode message from Redis channel %q: %s", v.Channel, err)
+				continue
+			}
+
+			r.local.Publish(strings.TrimPrefix(v.Channel, r.prefix), message)
+		case redis.Subscription:
+			if v.Kind == "subscribe" {
+				r.confirm(strings.TrimPrefix(v.Channel, r.prefix))
+			}
+		case error:
+			return v
+		}
+	}
+}
+
+// close 关闭订阅连接并停止重连
+func (r *redisMQ) close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.closed = true
+	if r.conn != nil {
+		r.conn.Close()
+	}
+}
@@ -0,0 +1,120 @@
+package mq
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/cloudreve/Cloudreve/v3/pkg/aria2/rpc"
+	"github.com/cloudreve/Cloudreve/v3/pkg/conf"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestRedisMQ(t *testing.T, s *miniredis.Miniredis) *redisMQ {
+	mq := NewRedisMQ("tcp", s.Addr(), "", "", "0").(*redisMQ)
+	t.Cleanup(mq.close)
+	return mq
+}
+
+func receive(t *testing.T, ch <-chan Message) Message {
+	select {
+	case msg := <-ch:
+		return msg
+	case <-time.After(3 * time.Second):
+		t.Fatal("message not received")
+	}
+	return Message{}
+}
+
+func TestRedisMQ_PublishAcrossInstances(t *testing.T) {
+	asserts := assert.New(t)
+	s := miniredis.RunT(t)
+	a := newTestRedisMQ(t, s)
+	b := newTestRedisMQ(t, s)
+
+	// 订阅返回时已在 Redis 上生效
+	notifier := b.Subscribe("topic", 1)
+	asserts.Equal(1, s.PubSubNumSub("cloudreve:mq:0:topic")["cloudreve:mq:0:topic"])
+
+	a.Publish("topic", Message{TriggeredBy: "a", Event: "event", Content: []rpc.Event{{Gid: "gid"}}})
+	msg := receive(t, notifier)
+	asserts.Equal("a", msg.TriggeredBy)
+	asserts.Equal("event", msg.Event)
+	asserts.Equal([]rpc.Event{{Gid: "gid"}}, msg.Content)
+
+	// 回调订阅，通过 aria2 事件发布
+	callback := make(chan Message, 2)
+	b.SubscribeCallback("gid", func(message Message) {
+		callback <- message
+	})
+	a.OnDownloadComplete([]rpc.Event{{Gid: "gid"}})
+	msg = receive(t, callback)
+	asserts.Equal("gid", msg.TriggeredBy)
+	asserts.Equal("4", msg.Event)
+
+	// 移除回调后取消 Redis 上的订阅
+	b.UnsubscribeCallback("gid")
+	asserts.Eventually(func() bool {
+		return s.PubSubNumSub("cloudreve:mq:0:gid")["cloudreve:mq:0:gid"] == 0
+	}, 3*time.Second, 10*time.Millisecond)
+	a.OnDownloadComplete([]rpc.Event{{Gid: "gid"}})
+	select {
+	case <-callback:
+		t.Fatal("callback should be removed")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// 同一主题多个订阅者，全部取消后取消 Redis 上的订阅
+	notifier2 := b.Subscribe("topic", 1)
+	b.Unsubscribe("topic", notifier)
+	b.Unsubscribe("topic", notifier)
+	a.Publish("topic", Message{TriggeredBy: "a"})
+	asserts.Equal("a", receive(t, notifier2).TriggeredBy)
+	b.Unsubscribe("topic", notifier2)
+	asserts.Eventually(func() bool {
+		return s.PubSubNumSub("cloudreve:mq:0:topic")["cloudreve:mq:0:topic"] == 0
+	}, 3*time.Second, 10*time.Millisecond)
+	asserts.Len(notifier, 0)
+}
+
+func TestInit(t *testing.T) {
+	asserts := assert.New(t)
+	s := miniredis.RunT(t)
+	server := conf.RedisConfig.Server
+	defer func() {
+		conf.RedisConfig.Server = server
+		GlobalMQ = NewMQ()
+	}()
+
+	// 未配置 Redis 时使用内存队列
+	conf.RedisConfig.Server = ""
+	GlobalMQ = NewMQ()
+	Init()
+	asserts.IsType(&inMemoryMQ{}, GlobalMQ)
+
+	conf.RedisConfig.Server = s.Addr()
+	Init()
+	asserts.IsType(&redisMQ{}, GlobalMQ)
+	GlobalMQ.(*redisMQ).close()
+}
+
+func TestRedisMQ_Reconnect(t *testing.T) {
+	asserts := assert.New(t)
+	s := miniredis.RunT(t)
+	a := newTestRedisMQ(t, s)
+	b := newTestRedisMQ(t, s)
+	notifier := b.Subscribe("topic", 1)
+
+	// Redis 不可用时仅分发给本地订阅者
+	s.Close()
+	b.Publish("topic", Message{TriggeredBy: "local"})
+	asserts.Equal("local", receive(t, notifier).TriggeredBy)
+
+	// 重连后恢复订阅
+	asserts.NoError(s.Restart())
+	asserts.Eventually(func() bool {
+		return s.PubSubNumSub("cloudreve:mq:0:topic")["cloudreve:mq:0:topic"] == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	a.Publish("topic", Message{TriggeredBy: "a"})
+	asserts.Equal("a", receive(t, notifier).TriggeredBy)
+}
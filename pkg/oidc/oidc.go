@@ -0,0 +1,224 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/request"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+	gooidc "github.com/coreos/go-oidc/v3/oidc"
+)
+
+// Client 使用授权码及 PKCE 流程的 OpenID Connect 客户端
+type Client interface {
+	// Issuer 返回身份提供方的标识
+	Issuer() string
+	// AuthURL 返回用户登录时需重定向到的提供方地址
+	AuthURL(ctx context.Context, state, nonce, codeVerifier string) (string, error)
+	// Exchange 使用授权码换取令牌，返回校验后的 ID Token 声明，
+	// 并合并用户信息端点返回的声明
+	Exchange(ctx context.Context, code, codeVerifier, nonce string) (*Claims, error)
+}
+
+// Config 客户端配置
+type Config struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
+var (
+	ErrInvalidToken = errors.New("invalid ID token")
+
+	Default   Client
+	DefaultMu sync.Mutex
+)
+
+const (
+	// CallbackPath 需在提供方处登记的回调地址路径
+	CallbackPath = "/api/v3/callback/oidc"
+
+	discoveryPath = "/.well-known/openid-configuration"
+)
+
+// Init 初始化全局 OIDC 客户端
+func Init() {
+	settings := model.GetSettingByNames(
+		"oidc_enabled",
+		"oidc_issuer",
+		"oidc_client_id",
+		"oidc_client_secret",
+		"oidc_scopes",
+	)
+	if !model.IsTrueVal(settings["oidc_enabled"]) {
+		DefaultMu.Lock()
+		Default = nil
+		DefaultMu.Unlock()
+		return
+	}
+
+	redirect, _ := url.Parse(CallbackPath)
+	oidcClient, err := NewClient(Config{
+		Issuer:       settings["oidc_issuer"],
+		ClientID:     settings["oidc_client_id"],
+		ClientSecret: settings["oidc_client_secret"],
+		RedirectURL:  model.GetSiteURL().ResolveReference(redirect).String(),
+		Scopes:       strings.Fields(settings["oidc_scopes"]),
+	}, request.NewClient())
+	if err != nil {
+		util.Log().Error("Failed to initialize OIDC client: %s", err)
+		return
+	}
+
+	DefaultMu.Lock()
+	Default = oidcClient
+	DefaultMu.Unlock()
+}
+
+// Current 返回当前使用的 OIDC 客户端，未启用 OIDC 登录时返回 nil
+func Current() Client {
+	DefaultMu.Lock()
+	defer DefaultMu.Unlock()
+	return Default
+}
+
+type client struct {
+	http   request.Client
+	config Config
+
+	mu       sync.RWMutex
+	metadata *metadata
+	keys     *keySet
+	verifier *gooidc.IDTokenVerifier
+}
+
+// metadata 发现文档中的提供方配置
+type metadata struct {
+	Issuer                string   `json:"issuer"`
+	AuthorizationEndpoint string   `json:"authorization_endpoint"`
+	TokenEndpoint         string   `json:"token_endpoint"`
+	UserInfoEndpoint      string   `json:"userinfo_endpoint"`
+	JWKSURI               string   `json:"jwks_uri"`
+	TokenAuthMethods      []string `json:"token_endpoint_auth_methods_supported"`
+	SigningAlgs           []string `json:"id_token_signing_alg_values_supported"`
+}
+
+// NewClient 创建 OIDC 客户端，提供方配置在首次使用时获取
+func NewClient(config Config, http request.Client) (Client, error) {
+	if _, err := url.Parse(config.Issuer); err != nil || config.Issuer == "" {
+		return nil, fmt.Errorf("invalid issuer %q", config.Issuer)
+	}
+	if config.ClientID == "" {
+		return nil, errors.New("client ID is not set")
+	}
+
+	config.Issuer = strings.TrimSuffix(config.Issuer, "/")
+	if !util.ContainsString(config.Scopes, "openid") {
+		config.Scopes = append([]string{"openid"}, config.Scopes...)
+	}
+
+	return &client{
+		http:   http,
+		config: config,
+	}, nil
+}
+
+func (c *client) Issuer() string {
+	return c.config.Issuer
+}
+
+// getJSON 请求给定地址，并将 JSON 响应解析至 v
+func (c *client) getJSON(ctx context.Context, target string, v interface{}, header http.Header) error {
+	res, err := c.http.Request("GET", target, nil, request.WithContext(ctx), request.WithHeader(header)).
+		CheckHTTPResponse(http.StatusOK).GetResponse()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(res), v)
+}
+
+// discover 返回提供方配置及 ID Token 校验器，尚未加载时从发现文档获取
+func (c *client) discover(ctx context.Context) (*metadata, *gooidc.IDTokenVerifier, error) {
+	c.mu.RLock()
+	meta, verifier := c.metadata, c.verifier
+	c.mu.RUnlock()
+	if meta != nil {
+		return meta, verifier, nil
+	}
+
+	meta = &metadata{}
+	if err := c.getJSON(ctx, c.config.Issuer+discoveryPath, meta, nil); err != nil {
+		return nil, nil, fmt.Errorf("failed to request discovery document: %w", err)
+	}
+
+	if strings.TrimSuffix(meta.Issuer, "/") != c.config.Issuer {
+		return nil, nil, fmt.Errorf("issuer %q in discovery document does not match %q", meta.Issuer, c.config.Issuer)
+	}
+	if meta.AuthorizationEndpoint == "" || meta.TokenEndpoint == "" || meta.JWKSURI == "" {
+		return nil, nil, errors.New("discovery document is missing required endpoints")
+	}
+
+	// ID Token 中的 iss 须与发现文档中的完全一致
+	keys := &keySet{client: c, uri: meta.JWKSURI}
+	verifier = gooidc.NewVerifier(meta.Issuer, keys, &gooidc.Config{
+		ClientID:             c.config.ClientID,
+		SupportedSigningAlgs: signingAlgs(meta.SigningAlgs),
+	})
+
+	c.mu.Lock()
+	c.metadata, c.keys, c.verifier = meta, keys, verifier
+	c.mu.Unlock()
+	return meta, verifier, nil
+}
+
+func (c *client) AuthURL(ctx context.Context, state, nonce, codeVerifier string) (string, error) {
+	meta, _, err := c.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	authURL, err := url.Parse(meta.AuthorizationEndpoint)
+	if err != nil {
+		return "", fmt.Errorf("invalid authorization endpoint: %w", err)
+	}
+
+	queries := authURL.Query()
+	queries.Set("response_type", "code")
+	queries.Set("client_id", c.config.ClientID)
+	queries.Set("redirect_uri", c.config.RedirectURL)
+	queries.Set("scope", strings.Join(c.config.Scopes, " "))
+	queries.Set("state", state)
+	queries.Set("nonce", nonce)
+	queries.Set("code_challenge", CodeChallenge(codeVerifier))
+	queries.Set("code_challenge_method", "S256")
+	authURL.RawQuery = queries.Encode()
+
+	return authURL.String(), nil
+}
+
+// RandomString 返回用作 state、nonce 及 code verifier 的 URL 安全随机字符串
+func RandomString() string {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
+
+// CodeChallenge 返回 code verifier 对应的 S256 PKCE code challenge
+func CodeChallenge(codeVerifier string) string {
+	sum := sha256.Sum256([]byte(codeVerifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,349 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/cloudreve/Cloudreve/v3/pkg/request"
+	"github.com/stretchr/testify/assert"
+)
+
+// mockProvider 提供发现文档、JWKS、令牌及用户信息端点的最简 OpenID 提供方
+type mockProvider struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+	kid    string
+	alg    string
+
+	// 已签发的授权码 -> PKCE challenge
+	codes map[string]string
+	// 下一个 ID Token 中的声明
+	claims   map[string]interface{}
+	userInfo map[string]interface{}
+	// JWKS 端点的请求次数
+	jwksRequests int
+}
+
+func newMockProvider(t *testing.T) *mockProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := &mockProvider{key: key, kid: "key1", alg: "RS256", codes: make(map[string]string)}
+	mux := http.NewServeMux()
+	mux.HandleFunc(discoveryPath, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                 p.server.URL,
+			"authorization_endpoint": p.server.URL + "/authorize",
+			"token_endpoint":         p.server.URL + "/token",
+			"userinfo_endpoint":      p.server.URL + "/userinfo",
+			"jwks_uri":               p.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		p.jwksRequests++
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kid": p.kid,
+				"kty": "RSA",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(p.key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(p.key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		id, secret, ok := r.BasicAuth()
+		if !ok || id != "client" || secret != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_client"})
+			return
+		}
+
+		r.ParseForm()
+		challenge, ok := p.codes[r.PostForm.Get("code")]
+		if !ok || r.PostForm.Get("grant_type") != "authorization_code" ||
+			CodeChallenge(r.PostForm.Get("code_verifier")) != challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]string{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"id_token":     p.sign(t, p.claims),
+		})
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer access" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(p.userInfo)
+	})
+
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+	return p
+}
+
+func (p *mockProvider) sign(t *testing.T, claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": p.alg, "typ": "JWT", "kid": p.kid})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+
+	var (
+		signature []byte
+		err       error
+	)
+	switch p.alg {
+	case "RS256":
+		signature, err = rsa.SignPKCS1v15(rand.Reader, p.key, crypto.SHA256, digest[:])
+	case "PS256":
+		signature, err = rsa.SignPSS(rand.Reader, p.key, crypto.SHA256, digest[:], nil)
+	case "HS256":
+		// 以公钥作为 HMAC 密钥的算法混淆攻击
+		mac := hmac.New(sha256.New, p.key.N.Bytes())
+		mac.Write([]byte(signed))
+		signature = mac.Sum(nil)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func (p *mockProvider) idClaims(nonce string) map[string]interface{} {
+	return map[string]interface{}{
+		"iss":            p.server.URL,
+		"sub":            "alice",
+		"aud":            []string{"client", "other"},
+		"exp":            time.Now().Add(time.Hour).Unix(),
+		"iat":            time.Now().Unix(),
+		"nonce":          nonce,
+		"email":          "alice@example.com",
+		"email_verified": true,
+		"name":           "Alice",
+		"groups":         []string{"staff", "admins"},
+	}
+}
+
+func (p *mockProvider) client(t *testing.T) Client {
+	c, err := NewClient(Config{
+		Issuer:       p.server.URL + "/",
+		ClientID:     "client",
+		ClientSecret: "secret",
+		RedirectURL:  "http://cloudreve" + CallbackPath,
+		Scopes:       []string{"email"},
+	}, request.NewClient())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestNewClient(t *testing.T) {
+	a := assert.New(t)
+
+	_, err := NewClient(Config{ClientID: "client"}, request.NewClient())
+	a.Error(err)
+	_, err = NewClient(Config{Issuer: "https://idp"}, request.NewClient())
+	a.Error(err)
+
+	c, err := NewClient(Config{Issuer: "https://idp/", ClientID: "client"}, request.NewClient())
+	a.NoError(err)
+	a.Equal("https://idp", c.Issuer())
+	a.Equal([]string{"openid"}, c.(*client).config.Scopes)
+}
+
+func TestClient_AuthURL(t *testing.T) {
+	a := assert.New(t)
+	p := newMockProvider(t)
+	c := p.client(t)
+
+	res, err := c.AuthURL(context.Background(), "state", "nonce", "verifier")
+	a.NoError(err)
+	authURL, err := url.Parse(res)
+	a.NoError(err)
+	a.Equal(p.server.URL+"/authorize", authURL.Scheme+"://"+authURL.Host+authURL.Path)
+
+	queries := authURL.Query()
+	a.Equal("code", queries.Get("response_type"))
+	a.Equal("client", queries.Get("client_id"))
+	a.Equal("http://cloudreve"+CallbackPath, queries.Get("redirect_uri"))
+	a.Equal("openid email", queries.Get("scope"))
+	a.Equal("state", queries.Get("state"))
+	a.Equal("nonce", queries.Get("nonce"))
+	a.Equal(CodeChallenge("verifier"), queries.Get("code_challenge"))
+	a.Equal("S256", queries.Get("code_challenge_method"))
+
+	// 签发方不匹配
+	{
+		c, _ := NewClient(Config{Issuer: p.server.URL + "/realm", ClientID: "client"}, request.NewClient())
+		_, err := c.AuthURL(context.Background(), "state", "nonce", "verifier")
+		a.Error(err)
+	}
+}
+
+func TestClient_Exchange(t *testing.T) {
+	a := assert.New(t)
+	p := newMockProvider(t)
+	p.codes["code"] = CodeChallenge("verifier")
+	ctx := context.Background()
+
+	// 成功
+	{
+		p.claims = p.idClaims("nonce")
+		claims, err := p.client(t).Exchange(ctx, "code", "verifier", "nonce")
+		a.NoError(err)
+		a.Equal("alice", claims.Subject)
+		a.Equal("alice@example.com", claims.Email)
+		a.True(*claims.EmailVerified)
+		a.Equal("Alice", claims.Name)
+		a.Equal([]string{"staff", "admins"}, claims.Values("groups"))
+	}
+
+	// code verifier 错误
+	{
+		p.claims = p.idClaims("nonce")
+		_, err := p.client(t).Exchange(ctx, "code", "other", "nonce")
+		a.Error(err)
+	}
+
+	// nonce 不匹配
+	{
+		p.claims = p.idClaims("other")
+		_, err := p.client(t).Exchange(ctx, "code", "verifier", "nonce")
+		a.ErrorIs(err, ErrInvalidToken)
+	}
+
+	// 受众不匹配
+	{
+		p.claims = p.idClaims("nonce")
+		p.claims["aud"] = "other"
+		_, err := p.client(t).Exchange(ctx, "code", "verifier", "nonce")
+		a.ErrorIs(err, ErrInvalidToken)
+	}
+
+	// 已过期
+	{
+		p.claims = p.idClaims("nonce")
+		p.claims["exp"] = time.Now().Add(-time.Hour).Unix()
+		_, err := p.client(t).Exchange(ctx, "code", "verifier", "nonce")
+		a.ErrorIs(err, ErrInvalidToken)
+	}
+
+	// 尚未生效
+	{
+		p.claims = p.idClaims("nonce")
+		p.claims["nbf"] = time.Now().Add(time.Hour).Unix()
+		_, err := p.client(t).Exchange(ctx, "code", "verifier", "nonce")
+		a.ErrorIs(err, ErrInvalidToken)
+	}
+
+	// 签发时间缺失或在未来
+	{
+		p.claims = p.idClaims("nonce")
+		delete(p.claims, "iat")
+		_, err := p.client(t).Exchange(ctx, "code", "verifier", "nonce")
+		a.ErrorIs(err, ErrInvalidToken)
+
+		p.claims["iat"] = time.Now().Add(time.Hour).Unix()
+		_, err = p.client(t).Exchange(ctx, "code", "verifier", "nonce")
+		a.ErrorIs(err, ErrInvalidToken)
+	}
+
+	// 签名算法不在允许列表中
+	{
+		p.claims = p.idClaims("nonce")
+		for _, alg := range []string{"HS256", "PS256"} {
+			p.alg = alg
+			_, err := p.client(t).Exchange(ctx, "code", "verifier", "nonce")
+			a.ErrorIs(err, ErrInvalidToken)
+		}
+		p.alg = "RS256"
+	}
+
+	// 签发方不匹配
+	{
+		p.claims = p.idClaims("nonce")
+		p.claims["iss"] = "https://evil"
+		_, err := p.client(t).Exchange(ctx, "code", "verifier", "nonce")
+		a.ErrorIs(err, ErrInvalidToken)
+	}
+
+	// 其他密钥签名
+	{
+		c := p.client(t)
+		p.claims = p.idClaims("nonce")
+		p.jwksRequests = 0
+		_, err := c.Exchange(ctx, "code", "verifier", "nonce")
+		a.NoError(err)
+		a.Equal(1, p.jwksRequests)
+
+		key := p.key
+		p.key, _ = rsa.GenerateKey(rand.Reader, 2048)
+		_, err = c.Exchange(ctx, "code", "verifier", "nonce")
+		a.ErrorIs(err, ErrInvalidToken)
+
+		// 间隔内不重新获取 JWKS
+		p.kid = "key2"
+		_, err = c.Exchange(ctx, "code", "verifier", "nonce")
+		a.ErrorIs(err, ErrInvalidToken)
+		a.Equal(1, p.jwksRequests)
+
+		// 间隔过后获取轮换后的密钥
+		c.(*client).keys.refreshed = time.Now().Add(-keyRefreshInterval)
+		_, err = c.Exchange(ctx, "code", "verifier", "nonce")
+		a.NoError(err)
+		a.Equal(2, p.jwksRequests)
+		p.key, p.kid = key, "key1"
+	}
+
+	// 从用户信息获取邮箱
+	{
+		p.claims = p.idClaims("nonce")
+		delete(p.claims, "email")
+		p.userInfo = map[string]interface{}{"sub": "alice", "email": "info@example.com", "name": "Other"}
+		claims, err := p.client(t).Exchange(ctx, "code", "verifier", "nonce")
+		a.NoError(err)
+		a.Equal("info@example.com", claims.Email)
+		a.Equal("Alice", claims.Name)
+
+		// 主体不匹配
+		p.userInfo["sub"] = "bob"
+		_, err = p.client(t).Exchange(ctx, "code", "verifier", "nonce")
+		a.ErrorIs(err, ErrInvalidToken)
+	}
+}
+
+func TestClaims_Values(t *testing.T) {
+	a := assert.New(t)
+	claims := newClaims(map[string]interface{}{
+		"role":         "admin",
+		"groups":       []interface{}{"a", 1, "b"},
+		"realm_access": map[string]interface{}{"roles": []interface{}{"user"}},
+	})
+
+	a.Equal([]string{"admin"}, claims.Values("role"))
+	a.Equal([]string{"a", "b"}, claims.Values("groups"))
+	a.Equal([]string{"user"}, claims.Values("realm_access.roles"))
+	a.Nil(claims.Values("realm_access.roles.x"))
+	a.Nil(claims.Values("missing"))
+	a.Nil(claims.EmailVerified)
+}
@@ -0,0 +1,276 @@
+package oidc
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudreve/Cloudreve/v3/pkg/request"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+	gooidc "github.com/coreos/go-oidc/v3/oidc"
+	jose "github.com/go-jose/go-jose/v3"
+)
+
+const (
+	// clockSkew 签发时间允许的时钟偏差
+	clockSkew = time.Minute
+	// keyRefreshInterval 两次获取 JWKS 之间的最短间隔
+	keyRefreshInterval = time.Minute
+)
+
+// supportedAlgs 允许的 ID Token 签名算法
+var supportedAlgs = []string{
+	gooidc.RS256, gooidc.RS384, gooidc.RS512,
+	gooidc.ES256, gooidc.ES384, gooidc.ES512,
+	gooidc.PS256, gooidc.PS384, gooidc.PS512,
+}
+
+// Claims 已认证用户的身份信息
+type Claims struct {
+	Subject           string
+	Email             string
+	EmailVerified     *bool
+	Name              string
+	PreferredUsername string
+	// Raw ID Token 及用户信息中的全部声明
+	Raw map[string]interface{}
+}
+
+// Values 以字符串列表返回声明的值，嵌套的声明以点分隔，如 "realm_access.roles"
+func (c *Claims) Values(name string) []string {
+	var value interface{} = c.Raw
+	for _, key := range strings.Split(name, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = object[key]
+	}
+
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		res := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				res = append(res, s)
+			}
+		}
+		return res
+	}
+	return nil
+}
+
+type tokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	IDToken          string `json:"id_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+func (c *client) Exchange(ctx context.Context, code, codeVerifier, nonce string) (*Claims, error) {
+	meta, verifier, err := c.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {c.config.RedirectURL},
+		"code_verifier": {codeVerifier},
+	}
+	header := http.Header{"Content-Type": {"application/x-www-form-urlencoded"}}
+
+	// 默认使用 client_secret_basic 认证
+	if len(meta.TokenAuthMethods) == 0 || util.ContainsString(meta.TokenAuthMethods, "client_secret_basic") {
+		credential := url.QueryEscape(c.config.ClientID) + ":" + url.QueryEscape(c.config.ClientSecret)
+		header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(credential)))
+	} else {
+		form.Set("client_id", c.config.ClientID)
+		form.Set("client_secret", c.config.ClientSecret)
+	}
+
+	body := form.Encode()
+	res, err := c.http.Request("POST", meta.TokenEndpoint, strings.NewReader(body),
+		request.WithContext(ctx),
+		request.WithHeader(header),
+		request.WithContentLength(int64(len(body))),
+	).GetResponse()
+	if err != nil {
+		return nil, fmt.Errorf("failed to request token endpoint: %w", err)
+	}
+
+	var token tokenResponse
+	if err := json.Unmarshal([]byte(res), &token); err != nil {
+		return nil, fmt.Errorf("failed to parse token response: %w", err)
+	}
+	if token.Error != "" {
+		return nil, fmt.Errorf("token endpoint returned error %s: %s", token.Error, token.ErrorDescription)
+	}
+	if token.IDToken == "" {
+		return nil, fmt.Errorf("%w: token response has no ID token", ErrInvalidToken)
+	}
+
+	raw, err := c.verify(ctx, verifier, token.IDToken, token.AccessToken, nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	// 部分提供方仅在用户信息中返回个人资料
+	if meta.UserInfoEndpoint != "" && token.AccessToken != "" {
+		if _, ok := raw["email"]; !ok {
+			userInfo := make(map[string]interface{})
+			err := c.getJSON(ctx, meta.UserInfoEndpoint, &userInfo,
+				http.Header{"Authorization": {"Bearer " + token.AccessToken}})
+			if err != nil {
+				return nil, fmt.Errorf("failed to request user info: %w", err)
+			}
+
+			if userInfo["sub"] != raw["sub"] {
+				return nil, fmt.Errorf("%w: subject of user info does not match", ErrInvalidToken)
+			}
+			for k, v := range userInfo {
+				if _, ok := raw[k]; !ok {
+					raw[k] = v
+				}
+			}
+		}
+	}
+
+	return newClaims(raw), nil
+}
+
+func newClaims(raw map[string]interface{}) *Claims {
+	claims := &Claims{Raw: raw}
+	claims.Subject, _ = raw["sub"].(string)
+	claims.Email, _ = raw["email"].(string)
+	claims.Name, _ = raw["name"].(string)
+	claims.PreferredUsername, _ = raw["preferred_username"].(string)
+	if verified, ok := raw["email_verified"].(bool); ok {
+		claims.EmailVerified = &verified
+	}
+	return claims
+}
+
+// verify 校验 ID Token 的签名、签名算法、签发方、受众及有效期，返回其中的声明
+func (c *client) verify(ctx context.Context, verifier *gooidc.IDTokenVerifier, rawToken, accessToken, nonce string) (map[string]interface{}, error) {
+	idToken, err := verifier.Verify(ctx, rawToken)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidToken, err)
+	}
+
+	if idToken.IssuedAt.IsZero() || idToken.IssuedAt.After(time.Now().Add(clockSkew)) {
+		return nil, fmt.Errorf("%w: invalid issued at time", ErrInvalidToken)
+	}
+	if idToken.Nonce != nonce {
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrInvalidToken)
+	}
+	if idToken.Subject == "" {
+		return nil, fmt.Errorf("%w: missing subject", ErrInvalidToken)
+	}
+	if idToken.AccessTokenHash != "" {
+		if err := idToken.VerifyAccessToken(accessToken); err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidToken, err)
+		}
+	}
+
+	claims := make(map[string]interface{})
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidToken, err)
+	}
+	if azp, ok := claims["azp"].(string); ok && azp != c.config.ClientID {
+		return nil, fmt.Errorf("%w: unexpected authorized party", ErrInvalidToken)
+	}
+
+	return claims, nil
+}
+
+// signingAlgs 返回提供方声明支持且在允许列表中的签名算法，
+// 提供方未声明时仅允许 RS256
+func signingAlgs(advertised []string) []string {
+	if len(advertised) == 0 {
+		return []string{gooidc.RS256}
+	}
+
+	res := make([]string, 0, len(advertised))
+	for _, alg := range advertised {
+		if util.ContainsString(supportedAlgs, alg) {
+			res = append(res, alg)
+		}
+	}
+	return res
+}
+
+// keySet 缓存提供方的签名公钥。签名无法以缓存的公钥校验时（如密钥轮换）重新获取 JWKS，
+// 两次获取之间至少间隔 keyRefreshInterval，避免伪造的令牌触发大量请求
+type keySet struct {
+	client *client
+	uri    string
+
+	mu        sync.Mutex
+	keys      []jose.JSONWebKey
+	refreshed time.Time
+}
+
+// VerifySignature 校验 JWT 的签名，返回其载荷
+func (s *keySet) VerifySignature(ctx context.Context, jwt string) ([]byte, error) {
+	jws, err := jose.ParseSigned(jwt)
+	if err != nil {
+		return nil, fmt.Errorf("malformed JWT: %w", err)
+	}
+	if len(jws.Signatures) != 1 {
+		return nil, fmt.Errorf("unexpected number of signatures %d", len(jws.Signatures))
+	}
+	kid := jws.Signatures[0].Header.KeyID
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if payload, ok := verifyWithKeys(jws, s.keys, kid); ok {
+		return payload, nil
+	}
+
+	if !s.refreshed.IsZero() && time.Since(s.refreshed) < keyRefreshInterval {
+		return nil, fmt.Errorf("no valid key found for key ID %q", kid)
+	}
+
+	// 无论成功与否均记录获取时间，以限制请求频率
+	s.refreshed = time.Now()
+	var set jose.JSONWebKeySet
+	if err := s.client.getJSON(ctx, s.uri, &set, nil); err != nil {
+		return nil, fmt.Errorf("failed to request JWKS: %w", err)
+	}
+
+	s.keys = s.keys[:0]
+	for _, key := range set.Keys {
+		if key.Valid() && key.IsPublic() && (key.Use == "" || key.Use == "sig") {
+			s.keys = append(s.keys, key)
+		}
+	}
+
+	if payload, ok := verifyWithKeys(jws, s.keys, kid); ok {
+		return payload, nil
+	}
+	return nil, fmt.Errorf("no valid key found for key ID %q", kid)
+}
+
+// verifyWithKeys 使用密钥 ID 匹配的公钥校验签名，令牌未指定密钥 ID 时尝试所有公钥
+func verifyWithKeys(jws *jose.JSONWebSignature, keys []jose.JSONWebKey, kid string) ([]byte, bool) {
+	for i := range keys {
+		if kid != "" && keys[i].KeyID != kid {
+			continue
+		}
+		if payload, err := jws.Verify(&keys[i]); err == nil {
+			return payload, true
+		}
+	}
+	return nil, false
+}
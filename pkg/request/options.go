@@ -27,6 +27,7 @@ type options struct {
 	tpsLimiterToken string
 	tps             float64
 	tpsBurst        int
+	transport       http.RoundTripper
 }
 
 type optionFunc func(*options)
@@ -50,6 +51,13 @@ func (o *options) clone() options {
 	return newOptions
 }
 
+// WithTransport 设置发送请求使用的 Transport
+func WithTransport(t http.RoundTripper) Option {
+	return optionFunc(func(o *options) {
+		o.transport = t
+	})
+}
+
 // WithTimeout 设置请求超时
 func WithTimeout(t time.Duration) Option {
 	return optionFunc(func(o *options) {
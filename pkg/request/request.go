@@ -18,6 +18,9 @@ import (
 	"github.com/cloudreve/Cloudreve/v3/pkg/util"
 )
 
+// RequestIDHeader 携带请求 ID 的 Header
+const RequestIDHeader = "X-Request-Id"
+
 // GeneralClient 通用 HTTP Client
 var GeneralClient Client = NewClient()
 
@@ -63,7 +66,7 @@ func (c *HTTPClient) Request(method, target string, body io.Reader, opts ...Opti
 	}
 
 	// 创建请求客户端
-	client := &http.Client{Timeout: options.timeout}
+	client := &http.Client{Timeout: options.timeout, Transport: options.transport}
 
 	// size为0时将body设为nil
 	if options.contentLength == 0 {
@@ -102,6 +105,11 @@ func (c *HTTPClient) Request(method, target string, body io.Reader, opts ...Opti
 		}
 	}
 
+	// 传递请求 ID，便于关联主从机及存储服务的日志
+	if id := util.RequestID(options.ctx); id != "" && req.Header.Get(RequestIDHeader) == "" {
+		req.Header.Set(RequestIDHeader, id)
+	}
+
 	if options.masterMeta && conf.SystemConfig.Mode == "master" {
 		req.Header.Add(auth.CrHeaderPrefix+"Site-Url", model.GetSiteURL().String())
 		req.Header.Add(auth.CrHeaderPrefix+"Site-Id", model.GetSettingByName("siteID"))
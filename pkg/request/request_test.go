@@ -7,11 +7,13 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/cloudreve/Cloudreve/v3/pkg/auth"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
 	"github.com/stretchr/testify/assert"
 	testMock "github.com/stretchr/testify/mock"
 )
@@ -91,6 +93,26 @@ func TestHTTPClient_Request(t *testing.T) {
 
 }
 
+func TestHTTPClient_RequestID(t *testing.T) {
+	asserts := assert.New(t)
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Get(RequestIDHeader)
+	}))
+	defer server.Close()
+
+	client := NewClient()
+	ctx := util.WithRequestID(context.Background(), "req-1")
+	resp := client.Request("GET", server.URL, nil, WithContext(ctx))
+	asserts.NoError(resp.Err)
+	asserts.Equal("req-1", received)
+
+	// 上下文中无请求 ID
+	resp = client.Request("GET", server.URL, nil)
+	asserts.NoError(resp.Err)
+	asserts.Empty(received)
+}
+
 func TestResponse_GetResponse(t *testing.T) {
 	asserts := assert.New(t)
 
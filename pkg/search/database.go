@@ -0,0 +1,263 @@
+package search
+
+import (
+	"errors"
+	"html"
+	"math"
+	"strings"
+	"unicode/utf8"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	// BM25 参数
+	bm25K1 = 1.2
+	bm25B  = 0.75
+	// nameBoost 文件名中匹配的词项相对内容的权重
+	nameBoost = 2.0
+
+	// maxQueryTerms 单次检索最多使用的词项数
+	maxQueryTerms = 32
+
+	maxSnippets      = 3
+	snippetLeading   = 60
+	snippetMaxLength = 200
+)
+
+// databaseIndex 存储在数据库中的倒排索引，由所有主机实例共享，
+// 仅在生成结果摘要时读取文档内容
+type databaseIndex struct{}
+
+// NewDatabaseIndex 创建存储在数据库中的索引
+func NewDatabaseIndex(settings map[string]string) (Index, error) {
+	return databaseIndex{}, nil
+}
+
+func (databaseIndex) Index(doc *Document) error {
+	var length int
+	content := termFrequencies(doc.Content)
+	terms := make([]model.ContentTerm, 0, len(content))
+	for term, tf := range content {
+		terms = append(terms, model.ContentTerm{FileID: doc.FileID, Field: model.ContentFieldContent, UserID: doc.UserID, Term: term, Freq: tf})
+		length += tf
+	}
+	terms = append(terms, nameTerms(doc.FileID, doc.UserID, doc.Name)...)
+
+	return (&model.ContentDocument{
+		FileID:  doc.FileID,
+		UserID:  doc.UserID,
+		Name:    doc.Name,
+		Content: doc.Content,
+		Length:  length,
+	}).Save(terms)
+}
+
+func (databaseIndex) Rename(fileID uint, name string) error {
+	err := model.RenameContentDocument(fileID, name, nameTerms(fileID, 0, name))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotIndexed
+	}
+	return err
+}
+
+func (databaseIndex) Delete(fileIDs ...uint) error {
+	if len(fileIDs) == 0 {
+		return nil
+	}
+	return model.DeleteContentDocuments(fileIDs)
+}
+
+func (databaseIndex) Search(uid uint, query string, limit int) ([]Hit, error) {
+	queryTerms := terms(query)
+	if len(queryTerms) == 0 {
+		return nil, nil
+	}
+	if len(queryTerms) > maxQueryTerms {
+		queryTerms = queryTerms[:maxQueryTerms]
+	}
+
+	stats, err := model.GetContentStats()
+	if err != nil || stats.Count == 0 {
+		return nil, err
+	}
+	dfs, err := model.GetContentTermCounts(queryTerms)
+	if err != nil {
+		return nil, err
+	}
+
+	n := float64(stats.Count)
+	avgLen := float64(stats.TotalLen) / n
+	if avgLen == 0 {
+		avgLen = 1
+	}
+
+	weights := make(map[string]float64, len(queryTerms))
+	for _, term := range queryTerms {
+		df := float64(dfs[term])
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+		if idf < 0.01 {
+			idf = 0.01
+		}
+		weights[term] = idf
+	}
+
+	// 筛选、评分及排序均在数据库中完成，只取回前 limit 个文档
+	matches, err := model.SearchContentDocuments(model.ContentQuery{
+		UserID:    uid,
+		Terms:     queryTerms,
+		Weights:   weights,
+		K1:        bm25K1,
+		B:         bm25B,
+		NameBoost: nameBoost,
+		AvgLength: avgLen,
+		Limit:     limit,
+	})
+	if err != nil || len(matches) == 0 {
+		return nil, err
+	}
+
+	hits := make([]Hit, len(matches))
+	for i, match := range matches {
+		hits[i] = Hit{FileID: match.FileID, Score: match.Score}
+	}
+
+	// 仅读取返回结果的内容以生成摘要
+	ids := make([]uint, len(hits))
+	for i, hit := range hits {
+		ids[i] = hit.FileID
+	}
+	docs, err := model.GetContentDocuments(ids)
+	if err != nil {
+		return nil, err
+	}
+	contents := make(map[uint]string, len(docs))
+	for _, doc := range docs {
+		contents[doc.FileID] = doc.Content
+	}
+
+	termSet := make(map[string]bool, len(queryTerms))
+	for _, term := range queryTerms {
+		termSet[term] = true
+	}
+	for i := range hits {
+		hits[i].Snippets = snippets(contents[hits[i].FileID], termSet)
+	}
+
+	return hits, nil
+}
+
+func (databaseIndex) Close() error {
+	return nil
+}
+
+// nameTerms 返回文件名中的词项记录
+func nameTerms(fileID, uid uint, name string) []model.ContentTerm {
+	names := termFrequencies(name)
+	res := make([]model.ContentTerm, 0, len(names))
+	for term, tf := range names {
+		res = append(res, model.ContentTerm{FileID: fileID, Field: model.ContentFieldName, UserID: uid, Term: term, Freq: tf})
+	}
+	return res
+}
+
+func termFrequencies(text string) map[string]int {
+	res := make(map[string]int)
+	eachToken(text, false, func(t token) bool {
+		res[t.term]++
+		return true
+	})
+	return res
+}
+
+// snippets 返回内容中词项附近的 HTML 片段，词项以高亮标记
+func snippets(content string, termSet map[string]bool) []string {
+	var (
+		res   []string
+		start = -1
+		end   int
+		marks [][2]int
+	)
+
+	build := func() {
+		if start < 0 {
+			return
+		}
+
+		var b strings.Builder
+		if start > 0 {
+			b.WriteString("…")
+		}
+		last := start
+		for _, mark := range marks {
+			b.WriteString(html.EscapeString(content[last:mark[0]]))
+			b.WriteString("<mark>")
+			b.WriteString(html.EscapeString(content[mark[0]:mark[1]]))
+			b.WriteString("</mark>")
+			last = mark[1]
+		}
+		b.WriteString(html.EscapeString(content[last:end]))
+		if end < len(content) {
+			b.WriteString("…")
+		}
+
+		res = append(res, strings.Join(strings.Fields(b.String()), " "))
+		start, marks = -1, nil
+	}
+
+	eachToken(content, false, func(t token) bool {
+		if !termSet[t.term] {
+			return true
+		}
+
+		if start >= 0 && t.end > end {
+			build()
+			if len(res) >= maxSnippets {
+				return false
+			}
+		}
+
+		if start < 0 {
+			if t.start < end {
+				// 与上一个片段重叠
+				return true
+			}
+			start = runeStart(content, t.start-snippetLeading)
+			if start < end {
+				start = end
+			}
+			end = runeStart(content, start+snippetMaxLength)
+			if end < t.end {
+				end = t.end
+			}
+		}
+
+		// 合并重叠的高亮范围，如 CJK 二元词项
+		if n := len(marks); n > 0 && t.start <= marks[n-1][1] {
+			if t.end > marks[n-1][1] {
+				marks[n-1][1] = t.end
+			}
+		} else {
+			marks = append(marks, [2]int{t.start, t.end})
+		}
+		return true
+	})
+	build()
+
+	return res
+}
+
+// runeStart 将 i 限制在 s 的范围内，并回退至字符的起始位置
+func runeStart(s string, i int) int {
+	if i <= 0 {
+		return 0
+	}
+	if i >= len(s) {
+		return len(s)
+	}
+	for i > 0 && !utf8.RuneStart(s[i]) {
+		i--
+	}
+	return i
+}
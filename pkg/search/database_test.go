@@ -0,0 +1,176 @@
+package search
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestIndex(t *testing.T) Index {
+	mockDB := model.DB
+	model.DB, _ = gorm.Open("sqlite", ":memory:")
+	t.Cleanup(func() {
+		model.DB.Close()
+		model.DB = mockDB
+	})
+	model.DB.AutoMigrate(&model.ContentDocument{}, &model.ContentTerm{})
+
+	index, err := NewDatabaseIndex(nil)
+	assert.NoError(t, err)
+	return index
+}
+
+func TestEachToken(t *testing.T) {
+	a := assert.New(t)
+	collect := func(text string, query bool) []string {
+		var res []string
+		eachToken(text, query, func(t token) bool {
+			res = append(res, t.term)
+			return true
+		})
+		return res
+	}
+
+	a.Equal([]string{"hello", "world", "2023"}, collect("Hello, World! 2023", false))
+	a.Equal([]string{"云", "云盘", "盘", "file"}, collect("云盘file", false))
+	a.Equal([]string{"云盘", "盘系", "系统"}, collect("云盘系统", true))
+	a.Equal([]string{"云"}, collect("云", true))
+	a.Empty(collect(" ,.!", false))
+}
+
+func TestDatabaseIndex_Search(t *testing.T) {
+	a := assert.New(t)
+	index := newTestIndex(t)
+
+	a.NoError(index.Index(&Document{FileID: 1, UserID: 1, Name: "notes.md", Content: "Cloudreve supports <full-text> search of documents."}))
+	a.NoError(index.Index(&Document{FileID: 2, UserID: 1, Name: "search.txt", Content: "search search search"}))
+	a.NoError(index.Index(&Document{FileID: 3, UserID: 2, Name: "other.txt", Content: "search of another user"}))
+	a.NoError(index.Index(&Document{FileID: 4, UserID: 1, Name: "云盘.txt", Content: "这是一个云盘系统的说明文档"}))
+
+	// 仅返回用户自己的文档，并按相关性排序
+	{
+		hits, err := index.Search(1, "search", 10)
+		a.NoError(err)
+		a.Len(hits, 2)
+		a.EqualValues(2, hits[0].FileID)
+		a.EqualValues(1, hits[1].FileID)
+		a.Equal([]string{"Cloudreve supports &lt;full-text&gt; <mark>search</mark> of documents."}, hits[1].Snippets)
+	}
+
+	// 需匹配所有词项
+	{
+		hits, err := index.Search(1, "search documents", 10)
+		a.NoError(err)
+		a.Len(hits, 1)
+		a.EqualValues(1, hits[0].FileID)
+
+		hits, err = index.Search(1, "search nothing", 10)
+		a.NoError(err)
+		a.Empty(hits)
+	}
+
+	// 中日韩文字
+	{
+		hits, err := index.Search(1, "云盘系统", 10)
+		a.NoError(err)
+		a.Len(hits, 1)
+		a.Equal([]string{"这是一个<mark>云盘系统</mark>的说明文档"}, hits[0].Snippets)
+	}
+
+	// 结果数量限制
+	{
+		hits, err := index.Search(1, "search", 1)
+		a.NoError(err)
+		a.Len(hits, 1)
+	}
+
+	// 重命名
+	{
+		a.NoError(index.Rename(1, "readme.md"))
+		hits, err := index.Search(1, "readme", 10)
+		a.NoError(err)
+		a.Len(hits, 1)
+		hits, err = index.Search(1, "notes", 10)
+		a.NoError(err)
+		a.Empty(hits)
+		a.Equal(ErrNotIndexed, index.Rename(100, "readme.md"))
+	}
+
+	// 删除
+	{
+		a.NoError(index.Delete(1, 2))
+		hits, err := index.Search(1, "search", 10)
+		a.NoError(err)
+		a.Empty(hits)
+		var count int
+		model.DB.Model(&model.ContentTerm{}).Where("file_id in (?)", []uint{1, 2}).Count(&count)
+		a.Zero(count)
+	}
+}
+
+func TestSnippets(t *testing.T) {
+	a := assert.New(t)
+	content := "start " + strings.Repeat("filler ", 30) + "target " + strings.Repeat("filler ", 60) + "target end"
+
+	res := snippets(content, map[string]bool{"target": true})
+	a.Len(res, 2)
+	a.Contains(res[0], "<mark>target</mark>")
+	a.Equal("…", res[0][:len("…")])
+	a.Contains(res[1], "<mark>target</mark> end")
+}
+
+func TestDatabaseIndex_Shared(t *testing.T) {
+	a := assert.New(t)
+	index := newTestIndex(t)
+	other, err := NewDatabaseIndex(nil)
+	a.NoError(err)
+
+	// 一个实例索引的文档对其他实例可见
+	a.NoError(index.Index(&Document{FileID: 1, UserID: 1, Name: "a.txt", Content: "shared content"}))
+	hits, err := other.Search(1, "shared", 10)
+	a.NoError(err)
+	a.Len(hits, 1)
+	a.EqualValues(1, hits[0].FileID)
+
+	// 重新索引时替换已有文档，词项分批插入
+	words := make([]string, 0, 500)
+	for i := 0; i < 500; i++ {
+		words = append(words, "word"+strconv.Itoa(i))
+	}
+	a.NoError(other.Index(&Document{FileID: 1, UserID: 1, Name: "a.txt", Content: "replaced " + strings.Join(words, " ")}))
+	hits, err = index.Search(1, "shared", 10)
+	a.NoError(err)
+	a.Empty(hits)
+	hits, err = index.Search(1, "replaced word499", 10)
+	a.NoError(err)
+	a.Len(hits, 1)
+	a.NoError(index.Close())
+}
+
+func TestDatabaseIndex_SearchMany(t *testing.T) {
+	a := assert.New(t)
+	index := newTestIndex(t)
+
+	// 匹配的文档数超过 SQLite 的参数数量限制
+	for i := 1; i <= 1100; i++ {
+		a.NoError(index.Index(&Document{FileID: uint(i), UserID: 1, Name: strconv.Itoa(i) + ".txt",
+			Content: "common " + strings.Repeat("rare ", i%7)}))
+	}
+
+	hits, err := index.Search(1, "common rare", 10)
+	a.NoError(err)
+	a.Len(hits, 10)
+	a.EqualValues(1098, hits[0].FileID)
+	for _, hit := range hits {
+		a.EqualValues(6, hit.FileID%7)
+		a.NotEmpty(hit.Snippets)
+	}
+
+	hits, err = index.Search(1, "common", 0)
+	a.NoError(err)
+	a.Len(hits, 1100)
+}
@@ -0,0 +1,196 @@
+package search
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxContentLength 索引中保存的提取文本最大长度
+const maxContentLength = 1 << 20
+
+type extractor func(data []byte) (string, error)
+
+var extractors = map[string]extractor{
+	"txt":      extractPlain,
+	"md":       extractPlain,
+	"markdown": extractPlain,
+	"csv":      extractPlain,
+	"log":      extractPlain,
+	"json":     extractPlain,
+	"xml":      extractPlain,
+	"yaml":     extractPlain,
+	"yml":      extractPlain,
+	"ini":      extractPlain,
+	"conf":     extractPlain,
+	"pdf":      extractPDF,
+	"docx":     extractDocx,
+	"pptx":     extractPptx,
+	"xlsx":     extractXlsx,
+	"odt":      extractODF,
+	"ods":      extractODF,
+	"odp":      extractODF,
+}
+
+// Extract 从 r 中读取给定文件名的文件，返回其纯文本内容
+func Extract(name string, r io.Reader) (string, error) {
+	extract, ok := extractors[ext(name)]
+	if !ok {
+		return "", ErrUnsupported
+	}
+
+	if maxSize := currentConfig().maxSize; maxSize > 0 {
+		r = io.LimitReader(r, int64(maxSize))
+	}
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+
+	text, err := extract(data)
+	if err != nil {
+		return "", fmt.Errorf("failed to extract text from %q: %w", name, err)
+	}
+
+	return truncate(text, maxContentLength), nil
+}
+
+// truncate 将 s 截断至最多 n 字节，不截断字符
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+func extractPlain(data []byte) (string, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	return strings.ToValidUTF8(string(data), ""), nil
+}
+
+// xmlText 收集 texts 中给定元素的文本，段落以换行结尾，texts 为 nil 时收集所有元素
+func xmlText(r io.Reader, texts, paragraphs map[string]bool, out *strings.Builder) error {
+	decoder := xml.NewDecoder(r)
+	depth := 0
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			if texts[t.Name.Local] {
+				depth++
+			} else if t.Name.Local == "tab" {
+				out.WriteByte('\t')
+			}
+		case xml.EndElement:
+			if texts[t.Name.Local] {
+				depth--
+			}
+			if paragraphs[t.Name.Local] {
+				out.WriteByte('\n')
+			}
+		case xml.CharData:
+			if texts == nil || depth > 0 {
+				out.Write(t)
+			}
+		}
+	}
+}
+
+// zipText 按给定顺序提取 zip 容器中 XML 部件的文本
+func zipText(data []byte, parts []string, texts, paragraphs map[string]bool) (string, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", err
+	}
+
+	files := make(map[string]*zip.File, len(archive.File))
+	for _, f := range archive.File {
+		files[f.Name] = f
+	}
+
+	var out strings.Builder
+	for _, name := range parts {
+		f, ok := files[name]
+		if !ok {
+			continue
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			return "", err
+		}
+		err = xmlText(io.LimitReader(rc, int64(maxContentLength)*8), texts, paragraphs, &out)
+		rc.Close()
+		if err != nil {
+			return "", err
+		}
+
+		if out.Len() > maxContentLength {
+			break
+		}
+	}
+
+	return out.String(), nil
+}
+
+// numberedParts 按数字顺序列出 prefix1.xml、prefix2.xml 等 zip 条目
+func numberedParts(data []byte, prefix string) []string {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil
+	}
+
+	type part struct {
+		name string
+		n    int
+	}
+	var parts []part
+	for _, f := range archive.File {
+		if !strings.HasPrefix(f.Name, prefix) || !strings.HasSuffix(f.Name, ".xml") {
+			continue
+		}
+		if n, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(f.Name, prefix), ".xml")); err == nil {
+			parts = append(parts, part{f.Name, n})
+		}
+	}
+
+	sort.Slice(parts, func(i, j int) bool { return parts[i].n < parts[j].n })
+	res := make([]string, len(parts))
+	for i, p := range parts {
+		res[i] = p.name
+	}
+	return res
+}
+
+func extractDocx(data []byte) (string, error) {
+	return zipText(data, []string{"word/document.xml"}, map[string]bool{"t": true}, map[string]bool{"p": true})
+}
+
+func extractPptx(data []byte) (string, error) {
+	return zipText(data, numberedParts(data, "ppt/slides/slide"), map[string]bool{"t": true}, map[string]bool{"p": true})
+}
+
+func extractXlsx(data []byte) (string, error) {
+	return zipText(data, []string{"xl/sharedStrings.xml"}, map[string]bool{"t": true}, map[string]bool{"si": true})
+}
+
+func extractODF(data []byte) (string, error) {
+	return zipText(data, []string{"content.xml"}, nil, map[string]bool{"p": true, "h": true})
+}
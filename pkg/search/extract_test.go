@@ -0,0 +1,149 @@
+package search
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func zipOf(files map[string]string) []byte {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, content := range files {
+		f, _ := w.Create(name)
+		f.Write([]byte(content))
+	}
+	w.Close()
+	return buf.Bytes()
+}
+
+func TestExtract(t *testing.T) {
+	a := assert.New(t)
+
+	// 纯文本
+	{
+		text, err := Extract("a.md", strings.NewReader("\xef\xbb\xbf# Title\ninvalid\xff"))
+		a.NoError(err)
+		a.Equal("# Title\ninvalid", text)
+	}
+
+	// 不支持的格式
+	{
+		_, err := Extract("a.exe", strings.NewReader(""))
+		a.Equal(ErrUnsupported, err)
+	}
+
+	// Word 文档
+	{
+		data := zipOf(map[string]string{
+			"word/document.xml": `<w:document xmlns:w="w"><w:body><w:p><w:r><w:t>Hello</w:t></w:r><w:r><w:t xml:space="preserve"> World</w:t></w:r></w:p><w:p><w:r><w:instrText>ignored</w:instrText><w:t>Second</w:t></w:r></w:p></w:body></w:document>`,
+		})
+		text, err := Extract("a.docx", bytes.NewReader(data))
+		a.NoError(err)
+		a.Equal("Hello World\nSecond\n", text)
+	}
+
+	// 演示文稿按幻灯片顺序读取
+	{
+		data := zipOf(map[string]string{
+			"ppt/slides/slide10.xml": `<p:sld xmlns:a="a" xmlns:p="p"><a:p><a:r><a:t>Ten</a:t></a:r></a:p></p:sld>`,
+			"ppt/slides/slide2.xml":  `<p:sld xmlns:a="a" xmlns:p="p"><a:p><a:r><a:t>Two</a:t></a:r></a:p></p:sld>`,
+		})
+		text, err := Extract("a.pptx", bytes.NewReader(data))
+		a.NoError(err)
+		a.Equal("Two\nTen\n", text)
+	}
+
+	// Excel 表格
+	{
+		data := zipOf(map[string]string{
+			"xl/sharedStrings.xml": `<sst><si><t>Cell</t></si><si><r><t>Rich</t></r><r><t>Text</t></r></si></sst>`,
+		})
+		text, err := Extract("a.xlsx", bytes.NewReader(data))
+		a.NoError(err)
+		a.Equal("Cell\nRichText\n", text)
+	}
+
+	// OpenDocument 文档
+	{
+		data := zipOf(map[string]string{
+			"content.xml": `<office:document-content xmlns:office="o" xmlns:text="t"><office:body><text:h>Heading</text:h><text:p>Para<text:tab/>graph</text:p></office:body></office:document-content>`,
+		})
+		text, err := Extract("a.odt", bytes.NewReader(data))
+		a.NoError(err)
+		a.Equal("Heading\nPara\tgraph\n", text)
+	}
+
+	// 损坏的容器
+	{
+		_, err := Extract("a.docx", strings.NewReader("not a zip"))
+		a.Error(err)
+	}
+}
+
+func testPDF(trailer string, objects ...string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R %s >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, trailer, xref)
+	return buf.Bytes()
+}
+
+func pdfStreamOf(dict, data string, compress bool) string {
+	if compress {
+		var buf bytes.Buffer
+		w := zlib.NewWriter(&buf)
+		w.Write([]byte(data))
+		w.Close()
+		data = buf.String()
+		dict += " /Filter /FlateDecode"
+	}
+	return fmt.Sprintf("<< %s /Length %d >>\nstream\n%s\nendstream", dict, len(data), data)
+}
+
+func TestExtractPDF(t *testing.T) {
+	a := assert.New(t)
+
+	data := testPDF("",
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R 6 0 R] /Count 2 /Resources << /Font << /F1 4 0 R /F2 7 0 R >> >> >>",
+		"<< /Type /Page /Parent 2 0 R /Contents 5 0 R >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+		pdfStreamOf("", "BT /F1 12 Tf 72 712 Td (Hello \\(World\\)) Tj 0 -14 Td [(Sec) 20 (ond) -300 (line)] TJ ET", false),
+		"<< /Type /Page /Parent 2 0 R /Contents [8 0 R] >>",
+		"<< /Type /Font /Subtype /Type0 /BaseFont /CJK /ToUnicode 9 0 R >>",
+		pdfStreamOf("", "BT /F2 12 Tf <00010002> Tj ET", true),
+		pdfStreamOf("", "begincmap 1 begincodespacerange <0000> <FFFF> endcodespacerange 1 beginbfchar <0001> <4E91> endbfchar 1 beginbfrange <0002> <0003> <76D8> endbfrange endcmap", true),
+	)
+
+	text, err := Extract("a.pdf", bytes.NewReader(data))
+	a.NoError(err)
+	a.Equal("Hello (World)\nSecond line\n云盘", text)
+
+	// 加密文档
+	{
+		_, err := Extract("a.pdf", bytes.NewReader(testPDF("/Encrypt 2 0 R", "<< /Type /Catalog >>", "<< /Filter /Standard /V 1 /R 2 /O <00> /U <00> /P -4 >>")))
+		a.Error(err)
+	}
+
+	// 无效内容
+	{
+		_, err := Extract("a.pdf", strings.NewReader("1 0 obj << /Type /Page /Contents [ <<"))
+		a.Error(err)
+	}
+}
@@ -0,0 +1,132 @@
+package search
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// pdfWordSpacing 视为空格的 TJ 负向调整量下限
+const pdfWordSpacing = 200
+
+var errPDFNoPages = errors.New("no page found")
+
+func extractPDF(data []byte) (text string, err error) {
+	defer func() {
+		// 格式错误的文件不能导致索引任务崩溃
+		if r := recover(); r != nil {
+			err = fmt.Errorf("malformed PDF: %v", r)
+		}
+	}()
+
+	reader, err := pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", err
+	}
+
+	pages := reader.NumPage()
+	if pages == 0 {
+		return "", errPDFNoPages
+	}
+
+	out := &pdfText{}
+	for i := 1; i <= pages && out.Len() <= maxContentLength; i++ {
+		page := reader.Page(i)
+		if page.V.IsNull() {
+			continue
+		}
+
+		out.page(page)
+		out.newLine()
+	}
+
+	return strings.TrimSpace(out.String()), nil
+}
+
+// pdfText 收集内容流中显示的文本，PDF 解析及字体解码由 pdf 包完成
+type pdfText struct {
+	strings.Builder
+	enc pdf.TextEncoding
+}
+
+// page 解释页面所有内容流中的文本操作符
+func (out *pdfText) page(page pdf.Page) {
+	fonts := make(map[string]pdf.TextEncoding)
+	out.enc = nil
+
+	contents := page.V.Key("Contents")
+	streams := []pdf.Value{contents}
+	if contents.Kind() == pdf.Array {
+		streams = streams[:0]
+		for i := 0; i < contents.Len(); i++ {
+			streams = append(streams, contents.Index(i))
+		}
+	}
+
+	for _, stream := range streams {
+		pdf.Interpret(stream, func(stk *pdf.Stack, op string) {
+			args := make([]pdf.Value, stk.Len())
+			for i := len(args) - 1; i >= 0; i-- {
+				args[i] = stk.Pop()
+			}
+
+			switch op {
+			case "Tf":
+				if len(args) == 2 {
+					name := args[0].Name()
+					enc, ok := fonts[name]
+					if !ok {
+						enc = page.Font(name).Encoder()
+						fonts[name] = enc
+					}
+					out.enc = enc
+				}
+			case "T*", "Tm":
+				out.newLine()
+			case "Td", "TD":
+				if len(args) == 2 && args[1].Float64() != 0 {
+					out.newLine()
+				}
+			case "'", "\"":
+				out.newLine()
+				if len(args) > 0 {
+					out.show(args[len(args)-1])
+				}
+			case "Tj":
+				if len(args) == 1 {
+					out.show(args[0])
+				}
+			case "TJ":
+				if len(args) == 1 {
+					for i := 0; i < args[0].Len(); i++ {
+						item := args[0].Index(i)
+						if item.Kind() == pdf.String {
+							out.show(item)
+						} else if -item.Float64() >= pdfWordSpacing {
+							out.WriteString(" ")
+						}
+					}
+				}
+			}
+		})
+	}
+}
+
+// show 写入以当前字体解码的字符串
+func (out *pdfText) show(s pdf.Value) {
+	if out.enc == nil {
+		out.WriteString(s.RawString())
+		return
+	}
+	out.WriteString(out.enc.Decode(s.RawString()))
+}
+
+// newLine 当前行非空时换行
+func (out *pdfText) newLine() {
+	if text := out.String(); text != "" && !strings.HasSuffix(text, "\n") {
+		out.WriteString("\n")
+	}
+}
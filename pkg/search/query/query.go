@@ -0,0 +1,593 @@
+// Package query 解析文件搜索的查询语法。
+//
+// 查询由若干条件组成，条件之间以 AND（相邻条件之间默认为 AND）、OR 及 NOT（或前缀 "-"）连接，
+// 可用括号分组。支持的条件有：
+//
+//	report            文件名包含 "report"
+//	"annual report"   文件名包含 "annual report"
+//	name:*.pdf        文件名匹配通配符，"*" 和 "?" 为通配符，"%" 等同于 "*"
+//	ext:jpg,png       扩展名为列表中的一个
+//	size:>10MB        大小范围，也可使用 <、>=、<=、精确值或 1MB..2MB
+//	modified:>=2023-01-01
+//	created:2023-05   时间范围，运算符同大小，日期格式为 2006、2006-01、2006-01-02 或 RFC 3339
+//	policy:3          存储策略 ID 或名称
+//	path:/docs        文件位于给定目录下
+//	is:shared         文件已被分享
+//	tag:Photos        文件匹配给定名称的标签
+package query
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// MaxLength 查询的最大长度，单位为字节
+	MaxLength = 2048
+	// MaxTerms 查询中条件的最大数量
+	MaxTerms = 64
+)
+
+var (
+	// ErrInvalid 所有无效查询错误均包装此错误
+	ErrInvalid  = errors.New("invalid query")
+	ErrEmpty    = fmt.Errorf("%w: empty query", ErrInvalid)
+	ErrTooLong  = fmt.Errorf("%w: longer than %d bytes", ErrInvalid, MaxLength)
+	ErrTooMany  = fmt.Errorf("%w: more than %d terms", ErrInvalid, MaxTerms)
+	ErrSyntax   = fmt.Errorf("%w: syntax error", ErrInvalid)
+	ErrBadValue = fmt.Errorf("%w: bad value", ErrInvalid)
+)
+
+// Expr 解析后的查询中的节点
+type Expr interface {
+	// String 以规范的查询语法返回表达式
+	String() string
+}
+
+type (
+	// And 所有子表达式均匹配时匹配
+	And []Expr
+	// Or 任一子表达式匹配时匹配
+	Or []Expr
+	// Not 子表达式不匹配时匹配
+	Not struct{ Expr Expr }
+
+	// Name 以通配符匹配文件名，Contains 为 true 时可匹配文件名的任意部分
+	Name struct {
+		Glob     string
+		Contains bool
+	}
+	// Ext 匹配扩展名，小写且不含点
+	Ext []string
+	// Size 匹配闭区间 [Min, Max] 内的大小，nil 表示不限
+	Size struct{ Min, Max *uint64 }
+	// Time 匹配半开区间 [From, To) 内的修改或创建时间
+	Time struct {
+		Field    string
+		From, To *time.Time
+	}
+	// Policy 按 ID 或名称匹配存储策略
+	Policy string
+	// Path 匹配目录下的文件
+	Path string
+	// Shared 匹配已被分享的文件
+	Shared struct{}
+	// Tag 匹配标签的条件
+	Tag string
+)
+
+const (
+	FieldModified = "modified"
+	FieldCreated  = "created"
+)
+
+// Parse 解析查询字符串
+func Parse(s string) (Expr, error) {
+	if len(s) > MaxLength {
+		return nil, ErrTooLong
+	}
+
+	tokens, err := lex(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, ErrEmpty
+	}
+
+	p := &parser{tokens: tokens}
+	expr, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("%w: unexpected %q", ErrSyntax, p.tokens[p.pos].text)
+	}
+	if p.terms > MaxTerms {
+		return nil, ErrTooMany
+	}
+
+	return expr, nil
+}
+
+// Walk 以深度优先顺序对 expr 的每个节点调用 fn
+func Walk(expr Expr, fn func(Expr)) {
+	fn(expr)
+	switch e := expr.(type) {
+	case And:
+		for _, sub := range e {
+			Walk(sub, fn)
+		}
+	case Or:
+		for _, sub := range e {
+			Walk(sub, fn)
+		}
+	case Not:
+		Walk(e.Expr, fn)
+	}
+}
+
+type tokenKind int
+
+const (
+	tokenWord tokenKind = iota
+	tokenLParen
+	tokenRParen
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	// quoted 单词中是否有被引号包围的部分，此时 AND、OR 及 NOT 视为普通单词
+	quoted bool
+	// colon 第一个不在引号中的冒号在 text 中的偏移，没有时为 -1
+	colon int
+	// negated 单词是否以不在引号中的减号开头
+	negated bool
+}
+
+func lex(s string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(s); {
+		switch c := s[i]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			tokens = append(tokens, token{kind: tokenLParen, text: "("})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{kind: tokenRParen, text: ")"})
+			i++
+		default:
+			var word strings.Builder
+			quoted, colon, negated := false, -1, false
+			if c == '-' && i+1 < len(s) && !strings.ContainsRune(" \t\n\r()", rune(s[i+1])) {
+				negated = true
+				i++
+			}
+			for i < len(s) && !strings.ContainsRune(" \t\n\r()", rune(s[i])) {
+				if s[i] != '"' {
+					if s[i] == ':' && colon < 0 && !quoted {
+						colon = word.Len()
+					}
+					word.WriteByte(s[i])
+					i++
+					continue
+				}
+
+				quoted = true
+				i++
+				for {
+					if i >= len(s) {
+						return nil, fmt.Errorf("%w: unterminated quote", ErrSyntax)
+					}
+					if s[i] == '"' {
+						i++
+						break
+					}
+					if s[i] == '\\' && i+1 < len(s) {
+						i++
+					}
+					word.WriteByte(s[i])
+					i++
+				}
+			}
+			tokens = append(tokens, token{kind: tokenWord, text: word.String(), quoted: quoted, colon: colon, negated: negated})
+		}
+	}
+	return tokens, nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+	terms  int
+}
+
+func (p *parser) peek() *token {
+	if p.pos < len(p.tokens) {
+		return &p.tokens[p.pos]
+	}
+	return nil
+}
+
+func (p *parser) isKeyword(t *token, keyword string) bool {
+	return t != nil && t.kind == tokenWord && !t.quoted && t.text == keyword
+}
+
+func (p *parser) or() (Expr, error) {
+	var res Or
+	for {
+		expr, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, expr)
+
+		if !p.isKeyword(p.peek(), "OR") {
+			break
+		}
+		p.pos++
+	}
+
+	if len(res) == 1 {
+		return res[0], nil
+	}
+	return res, nil
+}
+
+func (p *parser) and() (Expr, error) {
+	var res And
+	for {
+		t := p.peek()
+		if t == nil || t.kind == tokenRParen || p.isKeyword(t, "OR") {
+			break
+		}
+		if p.isKeyword(t, "AND") {
+			p.pos++
+			continue
+		}
+
+		expr, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, expr)
+	}
+
+	switch len(res) {
+	case 0:
+		if t := p.peek(); t != nil {
+			return nil, fmt.Errorf("%w: unexpected %q", ErrSyntax, t.text)
+		}
+		return nil, fmt.Errorf("%w: unexpected end of query", ErrSyntax)
+	case 1:
+		return res[0], nil
+	}
+	return res, nil
+}
+
+func (p *parser) unary() (Expr, error) {
+	t := p.peek()
+	if t == nil {
+		return nil, fmt.Errorf("%w: unexpected end of query", ErrSyntax)
+	}
+
+	switch {
+	case p.isKeyword(t, "NOT"):
+		p.pos++
+		expr, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return Not{expr}, nil
+	case t.kind == tokenLParen:
+		p.pos++
+		expr, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if t := p.peek(); t == nil || t.kind != tokenRParen {
+			return nil, fmt.Errorf("%w: missing )", ErrSyntax)
+		}
+		p.pos++
+		return expr, nil
+	}
+
+	p.pos++
+	p.terms++
+	expr, err := parseTerm(t.text, t.colon)
+	if err != nil {
+		return nil, err
+	}
+	if t.negated {
+		return Not{expr}, nil
+	}
+	return expr, nil
+}
+
+func parseTerm(text string, colon int) (Expr, error) {
+	if colon < 0 {
+		if text == "" {
+			return nil, fmt.Errorf("%w: empty term", ErrBadValue)
+		}
+		return Name{Glob: normalizeGlob(text), Contains: true}, nil
+	}
+	field, value := text[:colon], text[colon+1:]
+
+	if value == "" {
+		return nil, fmt.Errorf("%w: empty value of %q", ErrBadValue, field)
+	}
+
+	switch strings.ToLower(field) {
+	case "name":
+		return Name{Glob: normalizeGlob(value)}, nil
+	case "ext":
+		var exts Ext
+		for _, ext := range strings.Split(value, ",") {
+			if ext = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(ext), ".")); ext != "" {
+				exts = append(exts, ext)
+			}
+		}
+		if len(exts) == 0 {
+			return nil, fmt.Errorf("%w: empty extension list", ErrBadValue)
+		}
+		return exts, nil
+	case "size":
+		return parseSize(value)
+	case FieldModified, FieldCreated:
+		return parseTime(strings.ToLower(field), value)
+	case "policy":
+		return Policy(value), nil
+	case "path":
+		return Path(value), nil
+	case "is":
+		if strings.ToLower(value) == "shared" {
+			return Shared{}, nil
+		}
+		return nil, fmt.Errorf("%w: unknown flag %q", ErrBadValue, value)
+	case "tag":
+		return Tag(value), nil
+	}
+
+	return nil, fmt.Errorf("%w: unknown field %q", ErrBadValue, field)
+}
+
+// normalizeGlob 将通配符中的 "%" 替换为 "*"
+func normalizeGlob(glob string) string {
+	return strings.ReplaceAll(glob, "%", "*")
+}
+
+// splitRange 将范围值拆分为上下界，op 为 "="、">"、">="、"<"、"<=" 或 ".." 之一
+func splitRange(value string) (op, from, to string) {
+	if from, to, ok := strings.Cut(value, ".."); ok {
+		return "..", from, to
+	}
+	for _, op := range []string{">=", "<=", ">", "<", "="} {
+		if strings.HasPrefix(value, op) {
+			return op, strings.TrimPrefix(value, op), ""
+		}
+	}
+	return "=", value, ""
+}
+
+var sizeUnits = []struct {
+	suffix string
+	scale  uint64
+}{
+	{"TB", 1 << 40}, {"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10},
+	{"T", 1 << 40}, {"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10}, {"B", 1},
+}
+
+// ParseSize 解析 10、1.5MB 或 2G 等大小，返回字节数
+func ParseSize(s string) (uint64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	scale := uint64(1)
+	for _, unit := range sizeUnits {
+		if strings.HasSuffix(s, unit.suffix) {
+			s, scale = strings.TrimSuffix(s, unit.suffix), unit.scale
+			break
+		}
+	}
+
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || n < 0 || n*float64(scale) > float64(1<<63) {
+		return 0, fmt.Errorf("%w: size %q", ErrBadValue, s)
+	}
+	return uint64(n * float64(scale)), nil
+}
+
+func parseSize(value string) (Expr, error) {
+	op, from, to := splitRange(value)
+	a, err := ParseSize(from)
+	if err != nil && !(op == ".." && from == "") {
+		return nil, err
+	}
+
+	var res Size
+	switch op {
+	case "=":
+		res.Min, res.Max = &a, &a
+	case ">":
+		a++
+		res.Min = &a
+	case ">=":
+		res.Min = &a
+	case "<":
+		if a == 0 {
+			return nil, fmt.Errorf("%w: size below zero", ErrBadValue)
+		}
+		a--
+		res.Max = &a
+	case "<=":
+		res.Max = &a
+	case "..":
+		if from != "" {
+			res.Min = &a
+		}
+		if to != "" {
+			b, err := ParseSize(to)
+			if err != nil {
+				return nil, err
+			}
+			res.Max = &b
+		}
+	}
+	return res, nil
+}
+
+var timeLayouts = []struct {
+	layout string
+	next   func(time.Time) time.Time
+}{
+	{time.RFC3339, func(t time.Time) time.Time { return t.Add(time.Second) }},
+	{"2006-01-02T15:04:05", func(t time.Time) time.Time { return t.Add(time.Second) }},
+	{"2006-01-02", func(t time.Time) time.Time { return t.AddDate(0, 0, 1) }},
+	{"2006-01", func(t time.Time) time.Time { return t.AddDate(0, 1, 0) }},
+	{"2006", func(t time.Time) time.Time { return t.AddDate(1, 0, 0) }},
+}
+
+// parsePeriod 将时间值解析为其覆盖的时间段，未指定时区的值使用本地时间
+func parsePeriod(s string) (start, end time.Time, err error) {
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout.layout, s, time.Local); err == nil {
+			return t, layout.next(t), nil
+		}
+	}
+	return start, end, fmt.Errorf("%w: time %q", ErrBadValue, s)
+}
+
+func parseTime(field, value string) (Expr, error) {
+	op, from, to := splitRange(value)
+	res := Time{Field: field}
+
+	var start, end time.Time
+	if from != "" || op != ".." {
+		var err error
+		if start, end, err = parsePeriod(from); err != nil {
+			return nil, err
+		}
+	}
+
+	switch op {
+	case "=":
+		res.From, res.To = &start, &end
+	case ">":
+		res.From = &end
+	case ">=":
+		res.From = &start
+	case "<":
+		res.To = &start
+	case "<=":
+		res.To = &end
+	case "..":
+		if from != "" {
+			res.From = &start
+		}
+		if to != "" {
+			_, toEnd, err := parsePeriod(to)
+			if err != nil {
+				return nil, err
+			}
+			res.To = &toEnd
+		}
+	}
+	return res, nil
+}
+
+// quote 在 s 不能直接作为值使用时为其加上引号
+func quote(s string) string {
+	if s != "" && !strings.ContainsAny(s, " \t\n\r()\"\\") {
+		return s
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+func (e And) String() string {
+	parts := make([]string, len(e))
+	for i, sub := range e {
+		parts[i] = sub.String()
+		if _, ok := sub.(Or); ok {
+			parts[i] = "(" + parts[i] + ")"
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func (e Or) String() string {
+	parts := make([]string, len(e))
+	for i, sub := range e {
+		parts[i] = sub.String()
+	}
+	return strings.Join(parts, " OR ")
+}
+
+func (e Not) String() string {
+	switch e.Expr.(type) {
+	case And, Or:
+		return "NOT (" + e.Expr.String() + ")"
+	}
+	return "NOT " + e.Expr.String()
+}
+
+func (e Name) String() string {
+	if e.Contains {
+		s := quote(e.Glob)
+		// 形如条件或关键字的单词需保持为普通单词
+		if s == e.Glob && (strings.Contains(s, ":") || s == "AND" || s == "OR" || s == "NOT" || strings.HasPrefix(s, "-")) {
+			s = `"` + s + `"`
+		}
+		return s
+	}
+	return "name:" + quote(e.Glob)
+}
+
+func (e Ext) String() string {
+	return "ext:" + quote(strings.Join(e, ","))
+}
+
+func formatSize(n uint64) string {
+	return strconv.FormatUint(n, 10)
+}
+
+func (e Size) String() string {
+	switch {
+	case e.Min != nil && e.Max != nil && *e.Min == *e.Max:
+		return "size:" + formatSize(*e.Min)
+	case e.Min != nil && e.Max != nil:
+		return "size:" + formatSize(*e.Min) + ".." + formatSize(*e.Max)
+	case e.Min != nil:
+		return "size:>=" + formatSize(*e.Min)
+	case e.Max != nil:
+		return "size:<=" + formatSize(*e.Max)
+	}
+	return "size:>=0"
+}
+
+func (e Time) String() string {
+	format := func(t *time.Time) string {
+		return t.Format(time.RFC3339)
+	}
+	switch {
+	case e.From != nil && e.To != nil:
+		// 语法中范围的结束值包含在内
+		return e.Field + ":" + format(e.From) + ".." + format(timePtr(e.To.Add(-time.Second)))
+	case e.From != nil:
+		return e.Field + ":>=" + format(e.From)
+	case e.To != nil:
+		return e.Field + ":<" + format(e.To)
+	}
+	return e.Field + ":>=0001"
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
+
+func (e Policy) String() string { return "policy:" + quote(string(e)) }
+func (e Path) String() string   { return "path:" + quote(string(e)) }
+func (e Shared) String() string { return "is:shared" }
+func (e Tag) String() string    { return "tag:" + quote(string(e)) }
@@ -0,0 +1,136 @@
+package query
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func size(n uint64) *uint64 {
+	return &n
+}
+
+func date(s string) *time.Time {
+	t, err := time.ParseInLocation("2006-01-02", s, time.Local)
+	if err != nil {
+		panic(err)
+	}
+	return &t
+}
+
+func TestParse(t *testing.T) {
+	asserts := assert.New(t)
+
+	testCases := []struct {
+		query string
+		expr  Expr
+	}{
+		{"report", Name{Glob: "report", Contains: true}},
+		{`"annual report"`, Name{Glob: "annual report", Contains: true}},
+		{`"a:b"`, Name{Glob: "a:b", Contains: true}},
+		{`"OR"`, Name{Glob: "OR", Contains: true}},
+		{"name:*.pdf", Name{Glob: "*.pdf"}},
+		{`name:"my file?.txt"`, Name{Glob: "my file?.txt"}},
+		{"ext:JPG,.png", Ext{"jpg", "png"}},
+		{"size:>10MB", Size{Min: size(10<<20 + 1)}},
+		{"size:<=1.5K", Size{Max: size(1536)}},
+		{"size:1m..2m", Size{Min: size(1 << 20), Max: size(2 << 20)}},
+		{"size:..2g", Size{Max: size(2 << 30)}},
+		{"size:100", Size{Min: size(100), Max: size(100)}},
+		{"modified:2023-01-02", Time{Field: FieldModified, From: date("2023-01-02"), To: date("2023-01-03")}},
+		{"modified:>2023-01", Time{Field: FieldModified, From: date("2023-02-01")}},
+		{"created:<2023", Time{Field: FieldCreated, To: date("2023-01-01")}},
+		{"created:2022..2023-06", Time{Field: FieldCreated, From: date("2022-01-01"), To: date("2023-07-01")}},
+		{"policy:2", Policy("2")},
+		{`path:"/my docs"`, Path("/my docs")},
+		{"is:shared", Shared{}},
+		{"tag:Photos", Tag("Photos")},
+		{"a b", And{Name{Glob: "a", Contains: true}, Name{Glob: "b", Contains: true}}},
+		{"a AND b", And{Name{Glob: "a", Contains: true}, Name{Glob: "b", Contains: true}}},
+		{"a OR b c", Or{Name{Glob: "a", Contains: true}, And{Name{Glob: "b", Contains: true}, Name{Glob: "c", Contains: true}}}},
+		{"(a OR b) c", And{Or{Name{Glob: "a", Contains: true}, Name{Glob: "b", Contains: true}}, Name{Glob: "c", Contains: true}}},
+		{"-ext:tmp", Not{Ext{"tmp"}}},
+		{"NOT (a OR b)", Not{Or{Name{Glob: "a", Contains: true}, Name{Glob: "b", Contains: true}}}},
+		{`"-draft"`, Name{Glob: "-draft", Contains: true}},
+	}
+
+	for _, testCase := range testCases {
+		expr, err := Parse(testCase.query)
+		asserts.NoError(err, testCase.query)
+		asserts.Equal(testCase.expr, expr, testCase.query)
+	}
+}
+
+func TestParse_Error(t *testing.T) {
+	asserts := assert.New(t)
+
+	testCases := []struct {
+		query string
+		err   error
+	}{
+		{"", ErrEmpty},
+		{"   ", ErrEmpty},
+		{strings.Repeat("a", MaxLength+1), ErrTooLong},
+		{strings.Repeat("a ", MaxTerms+1), ErrTooMany},
+		{`"unterminated`, ErrSyntax},
+		{"(a", ErrSyntax},
+		{"a)", ErrSyntax},
+		{"a OR", ErrSyntax},
+		{"NOT", ErrSyntax},
+		{"a AND NOT", ErrSyntax},
+		{"()", ErrSyntax},
+		{"foo:bar", ErrBadValue},
+		{"ext:", ErrBadValue},
+		{"size:big", ErrBadValue},
+		{"size:<0", ErrBadValue},
+		{"modified:yesterday", ErrBadValue},
+		{"is:starred", ErrBadValue},
+	}
+
+	for _, testCase := range testCases {
+		_, err := Parse(testCase.query)
+		asserts.True(errors.Is(err, testCase.err), "%s: %v", testCase.query, err)
+	}
+}
+
+func TestExpr_String(t *testing.T) {
+	asserts := assert.New(t)
+
+	testCases := []string{
+		`report "annual report" "a:b" "OR" "-draft"`,
+		`name:*.pdf OR name:"my file?.txt"`,
+		`ext:jpg,png -size:>1M`,
+		`(a OR b) NOT (c d)`,
+		`size:1..2 size:..2g size:100`,
+		`modified:2023-01-02 created:>2023-01 created:<2023 modified:2022..2023-06`,
+		`policy:"my policy" path:"/my docs" is:shared tag:Photos`,
+	}
+
+	for _, testCase := range testCases {
+		expr, err := Parse(testCase)
+		asserts.NoError(err)
+
+		// 规范形式解析后得到相同的查询
+		reparsed, err := Parse(expr.String())
+		asserts.NoError(err, expr.String())
+		asserts.Equal(expr.String(), reparsed.String())
+	}
+}
+
+func TestWalk(t *testing.T) {
+	asserts := assert.New(t)
+
+	expr, err := Parse("tag:a (b OR NOT tag:c)")
+	asserts.NoError(err)
+
+	var tags []Tag
+	Walk(expr, func(e Expr) {
+		if tag, ok := e.(Tag); ok {
+			tags = append(tags, tag)
+		}
+	})
+	asserts.Equal([]Tag{"a", "c"}, tags)
+}
@@ -0,0 +1,187 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"runtime"
+	"strings"
+	"sync"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+)
+
+// Index 存储提取的文件内容并处理全文检索
+type Index interface {
+	// Index 添加或替换文件的文档
+	Index(doc *Document) error
+	// Rename 更新已索引文档的文件名，文件未被索引时返回 ErrNotIndexed
+	Rename(fileID uint, name string) error
+	// Delete 从索引中删除给定文件的文档
+	Delete(fileIDs ...uint) error
+	// Search 返回用户文档中匹配 query 所有词项的文档，按相关性排序，最多 limit 个
+	Search(uid uint, query string, limit int) ([]Hit, error)
+	// Close 写入未保存的更改并释放资源
+	Close() error
+}
+
+// Document 文件被索引的内容
+type Document struct {
+	FileID  uint
+	UserID  uint
+	Name    string
+	Content string
+}
+
+// Hit 匹配检索条件的文件
+type Hit struct {
+	FileID uint
+	Score  float64
+	// Snippets 内容中匹配词项附近的片段，已进行 HTML 转义，匹配的词项以 <mark> 标签包裹
+	Snippets []string
+}
+
+// Factory 创建索引后端
+type Factory func(settings map[string]string) (Index, error)
+
+var (
+	// Default 当前使用的索引，未开启全文检索时为 nil
+	Default   Index
+	DefaultMu sync.RWMutex
+
+	// ErrNotIndexed 请求的文件未被索引
+	ErrNotIndexed = errors.New("file is not indexed")
+	// ErrUnsupported 没有可以处理该文件的内容提取器
+	ErrUnsupported = errors.New("file type is not supported by content index")
+
+	backends = map[string]Factory{}
+
+	// cfg 当前的索引配置，在 DefaultMu 保护下整体替换
+	cfg = &config{
+		exts:    map[string]bool{},
+		workers: make(chan struct{}, runtime.GOMAXPROCS(0)),
+	}
+)
+
+// config 索引配置，创建后不再修改
+type config struct {
+	// exts 需要索引的小写扩展名
+	exts map[string]bool
+	// maxSize 需要索引的源文件最大大小，单位为字节
+	maxSize uint64
+	// workers 限制同时执行的索引任务数
+	workers chan struct{}
+}
+
+// RegisterBackend 以给定名称注册索引后端，可通过 `search_content_backend` 设置选用
+func RegisterBackend(name string, factory Factory) {
+	backends[name] = factory
+}
+
+func init() {
+	RegisterBackend("database", NewDatabaseIndex)
+}
+
+// Init 初始化设置中指定的索引后端
+func Init() {
+	settings := model.GetSettingByNames(
+		"search_content_enabled",
+		"search_content_backend",
+		"search_content_exts",
+		"search_content_max_size",
+	)
+
+	DefaultMu.Lock()
+	defer DefaultMu.Unlock()
+
+	if Default != nil {
+		if err := Default.Close(); err != nil {
+			util.Log().Warning("Failed to close content index: %s", err)
+		}
+		Default = nil
+	}
+
+	if !model.IsTrueVal(settings["search_content_enabled"]) {
+		return
+	}
+
+	factory, ok := backends[settings["search_content_backend"]]
+	if !ok {
+		util.Log().Error("Unknown content index backend %q.", settings["search_content_backend"])
+		return
+	}
+
+	newCfg := &config{exts: make(map[string]bool)}
+	for _, ext := range strings.Split(settings["search_content_exts"], ",") {
+		if ext = strings.ToLower(strings.TrimSpace(ext)); ext != "" {
+			newCfg.exts[ext] = true
+		}
+	}
+	fmt.Sscan(settings["search_content_max_size"], &newCfg.maxSize)
+
+	maxWorker := model.GetIntSetting("search_content_max_task_count", 2)
+	if maxWorker <= 0 {
+		maxWorker = runtime.GOMAXPROCS(0)
+	}
+	newCfg.workers = make(chan struct{}, maxWorker)
+	cfg = newCfg
+
+	index, err := factory(settings)
+	if err != nil {
+		util.Log().Error("Failed to initialize content index: %s", err)
+		return
+	}
+
+	Default = index
+}
+
+// Close 写入并关闭当前使用的索引
+func Close() {
+	DefaultMu.Lock()
+	defer DefaultMu.Unlock()
+
+	if Default != nil {
+		if err := Default.Close(); err != nil {
+			util.Log().Warning("Failed to close content index: %s", err)
+		}
+		Default = nil
+	}
+}
+
+// Current 返回当前使用的索引，未开启全文检索时返回 nil
+func Current() Index {
+	DefaultMu.RLock()
+	defer DefaultMu.RUnlock()
+	return Default
+}
+
+// currentConfig 返回当前的索引配置
+func currentConfig() *config {
+	DefaultMu.RLock()
+	defer DefaultMu.RUnlock()
+	return cfg
+}
+
+// Go 在后台执行索引任务，限制并发数
+func Go(task func()) {
+	pool := currentConfig().workers
+	go func() {
+		pool <- struct{}{}
+		defer func() { <-pool }()
+		task()
+	}()
+}
+
+// ShouldIndex 返回给定文件名及大小的文件是否需要索引
+func ShouldIndex(name string, size uint64) bool {
+	c := currentConfig()
+	if size == 0 || (c.maxSize > 0 && size > c.maxSize) {
+		return false
+	}
+	return c.exts[ext(name)] && extractors[ext(name)] != nil
+}
+
+func ext(name string) string {
+	return strings.TrimPrefix(strings.ToLower(path.Ext(name)), ".")
+}
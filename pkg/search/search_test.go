@@ -0,0 +1,41 @@
+package search
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInit(t *testing.T) {
+	a := assert.New(t)
+	cache.SetSettings(map[string]string{
+		"search_content_enabled":        "1",
+		"search_content_backend":        "database",
+		"search_content_exts":           "txt, PDF",
+		"search_content_max_size":       "10",
+		"search_content_max_task_count": "1",
+	}, "setting_")
+	defer Close()
+
+	// 初始化期间并发读取配置
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			ShouldIndex("a.txt", 5)
+		}
+	}()
+	Init()
+	wg.Wait()
+
+	a.NotNil(Current())
+	a.True(ShouldIndex("a.txt", 5))
+	a.True(ShouldIndex("a.PDF", 5))
+	a.False(ShouldIndex("a.txt", 0))
+	a.False(ShouldIndex("a.txt", 11))
+	a.False(ShouldIndex("a.md", 5))
+	a.Equal(1, cap(currentConfig().workers))
+}
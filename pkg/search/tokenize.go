@@ -0,0 +1,109 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxTermLength 索引词项的最大长度，更长的单词将被忽略
+const maxTermLength = 64
+
+// token 文本中的词项，start 和 end 为其在文本中的字节偏移
+type token struct {
+	term       string
+	start, end int
+}
+
+// isCJK 返回 r 是否属于单词间不以空格分隔的文字，这类文本以重叠的二元字符组索引
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
+
+// eachToken 按顺序对文本中的词项调用 fn，直到 fn 返回 false。
+// 文档中的 CJK 字符同时产生单字及二元词项；检索条件中超过一个字符的 CJK 文本
+// 只产生二元词项，以匹配文档中的二元词项
+func eachToken(text string, query bool, fn func(t token) bool) {
+	wordStart := -1
+	cjkStart, cjkCount := -1, 0
+	var prevCJK, prevSize int
+
+	emitWord := func(end int) bool {
+		if wordStart < 0 {
+			return true
+		}
+		start := wordStart
+		wordStart = -1
+		if end-start > maxTermLength {
+			return true
+		}
+		return fn(token{term: strings.ToLower(text[start:end]), start: start, end: end})
+	}
+
+	emitSingle := func() bool {
+		// 检索条件中单独的 CJK 字符没有可匹配的二元词项
+		if query && cjkCount == 1 {
+			return fn(token{term: text[cjkStart : cjkStart+prevSize], start: cjkStart, end: cjkStart + prevSize})
+		}
+		return true
+	}
+
+	for i, r := range text {
+		size := utf8.RuneLen(r)
+		switch {
+		case isCJK(r):
+			if !emitWord(i) {
+				return
+			}
+			if cjkCount == 0 {
+				cjkStart = i
+			} else if !fn(token{term: text[prevCJK : i+size], start: prevCJK, end: i + size}) {
+				return
+			}
+			if !query && !fn(token{term: text[i : i+size], start: i, end: i + size}) {
+				return
+			}
+			cjkCount++
+			prevCJK, prevSize = i, size
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r):
+			if cjkCount > 0 {
+				if !emitSingle() {
+					return
+				}
+				cjkCount = 0
+			}
+			if wordStart < 0 {
+				wordStart = i
+			}
+		default:
+			if cjkCount > 0 {
+				if !emitSingle() {
+					return
+				}
+				cjkCount = 0
+			}
+			if !emitWord(i) {
+				return
+			}
+		}
+	}
+
+	if cjkCount > 0 && !emitSingle() {
+		return
+	}
+	emitWord(len(text))
+}
+
+// terms 按顺序返回检索条件中不重复的词项
+func terms(query string) []string {
+	var res []string
+	seen := make(map[string]bool)
+	eachToken(query, true, func(t token) bool {
+		if !seen[t.term] {
+			seen[t.term] = true
+			res = append(res, t.term)
+		}
+		return true
+	})
+	return res
+}
@@ -192,6 +192,20 @@ const (
 	CodeDisabledSharePreview = 40070
 	// 签名无效
 	CodeInvalidSign = 40071
+	// 秒传内容校验失败
+	CodeInvalidUploadProof = 40072
+	// 已绑定了外部身份
+	CodeIdentityBindConflict = 40073
+	// 外部身份已被绑定其他账号
+	CodeIdentityBindOtherAccount = 40074
+	// 外部身份未绑定对应账号
+	CodeIdentityNotLinked = 40075
+	// 分享不允许上传或文件不符合分享的上传限制
+	CodeShareUploadNotAllowed = 40076
+	// 分享的上传配额已用尽
+	CodeShareUploadQuotaExceeded = 40077
+	// 分享密码错误次数过多
+	CodeShareUnlockLimited = 40078
 	// CodeDBError 数据库操作失败
 	CodeDBError = 50001
 	// CodeEncryptError 加密失败
@@ -43,6 +43,8 @@ type Object struct {
 	CreateDate    time.Time `json:"create_date"`
 	Key           string    `json:"key,omitempty"`
 	SourceEnabled bool      `json:"source_enabled"`
+	Score         float64   `json:"score,omitempty"`
+	Snippets      []string  `json:"snippets,omitempty"`
 }
 
 // PolicySummary 用于前端组件使用的存储策略概况
@@ -24,9 +24,12 @@ type SiteConfig struct {
 	RegisterEnabled      bool     `json:"registerEnabled"`
 	AppPromotion         bool     `json:"app_promotion"`
 	WopiExts             []string `json:"wopi_exts"`
+	OIDC                 bool     `json:"oidc"`
+	OIDCDisplayName      string   `json:"oidc_display_name"`
 }
 
 type task struct {
+	ID         uint      `json:"id"`
 	Status     int       `json:"status"`
 	Type       int       `json:"type"`
 	CreateDate time.Time `json:"create_date"`
@@ -39,6 +42,7 @@ func BuildTaskList(tasks []model.Task, total int) Response {
 	res := make([]task, 0, len(tasks))
 	for _, t := range tasks {
 		res = append(res, task{
+			ID:         t.ID,
 			Status:     t.Status,
 			Type:       t.Type,
 			CreateDate: t.CreatedAt,
@@ -87,6 +91,8 @@ func BuildSiteConfig(settings map[string]string, user *model.User, wopiExts []st
 			RegisterEnabled:      model.IsTrueVal(checkSettingValue(settings, "register_enabled")),
 			AppPromotion:         model.IsTrueVal(checkSettingValue(settings, "show_app_promotion")),
 			WopiExts:             wopiExts,
+			OIDC:                 model.IsTrueVal(checkSettingValue(settings, "oidc_enabled")),
+			OIDCDisplayName:      checkSettingValue(settings, "oidc_display_name"),
 		}}
 	return res
 }
@@ -1,6 +1,7 @@
 package serializer
 
 import (
+	"strconv"
 	"time"
 
 	model "github.com/cloudreve/Cloudreve/v3/models"
@@ -19,6 +20,62 @@ type Share struct {
 	Preview    bool          `json:"preview"`
 	Creator    *shareCreator `json:"creator,omitempty"`
 	Source     *shareSource  `json:"source,omitempty"`
+	Upload     *shareUpload  `json:"upload,omitempty"`
+	Internal   bool          `json:"internal"`
+	Permission string        `json:"permission,omitempty"`
+}
+
+// SharePermissionNames 分享权限级别的名称
+var SharePermissionNames = map[int]string{
+	model.SharePermissionView:     "view",
+	model.SharePermissionDownload: "download",
+	model.SharePermissionEdit:     "edit",
+}
+
+// shareGrant 内部分享授权
+type shareGrant struct {
+	Type       string `json:"type"`
+	Target     string `json:"target"`
+	Name       string `json:"name"`
+	Permission string `json:"permission"`
+}
+
+// receivedShareItem 他人授权给我的分享列表条目
+type receivedShareItem struct {
+	Key        string        `json:"key"`
+	IsDir      bool          `json:"is_dir"`
+	CreateDate time.Time     `json:"create_date,omitempty"`
+	Expire     int64         `json:"expire"`
+	Permission string        `json:"permission"`
+	Creator    *shareCreator `json:"creator,omitempty"`
+	Source     *shareSource  `json:"source,omitempty"`
+}
+
+// shareUpload 收集文件分享的上传限制
+type shareUpload struct {
+	MaxSize       uint64 `json:"max_size"`
+	MaxFiles      int    `json:"max_files"`
+	Exts          string `json:"exts"`
+	HideContents  bool   `json:"hide_contents"`
+	Notify        bool   `json:"notify,omitempty"`
+	UploadedFiles int    `json:"uploaded_files"`
+	UploadedSize  uint64 `json:"uploaded_size"`
+}
+
+// buildShareUpload 构建收集文件分享的上传限制，非收集文件分享返回 nil
+func buildShareUpload(share *model.Share) *shareUpload {
+	if !share.UploadEnabled {
+		return nil
+	}
+
+	return &shareUpload{
+		MaxSize:       share.UploadMaxSize,
+		MaxFiles:      share.UploadMaxFiles,
+		Exts:          share.UploadExts,
+		HideContents:  share.UploadHideContents,
+		UploadedFiles: share.UploadedFiles,
+		UploadedSize:  share.UploadedSize,
+	}
 }
 
 type shareCreator struct {
@@ -36,7 +93,7 @@ type shareSource struct {
 type myShareItem struct {
 	Key             string       `json:"key"`
 	IsDir           bool         `json:"is_dir"`
-	Password        string       `json:"password"`
+	Locked          bool         `json:"locked"`
 	CreateDate      time.Time    `json:"create_date,omitempty"`
 	Downloads       int          `json:"downloads"`
 	RemainDownloads int          `json:"remain_downloads"`
@@ -44,6 +101,8 @@ type myShareItem struct {
 	Expire          int64        `json:"expire"`
 	Preview         bool         `json:"preview"`
 	Source          *shareSource `json:"source,omitempty"`
+	Upload          *shareUpload `json:"upload,omitempty"`
+	Internal        bool         `json:"internal"`
 }
 
 // BuildShareList 构建我的分享列表响应
@@ -54,13 +113,18 @@ func BuildShareList(shares []model.Share, total int) Response {
 		item := myShareItem{
 			Key:             hashid.HashID(shares[i].ID, hashid.ShareID),
 			IsDir:           shares[i].IsDir,
-			Password:        shares[i].Password,
+			Locked:          shares[i].Password != "",
 			CreateDate:      shares[i].CreatedAt,
 			Downloads:       shares[i].Downloads,
 			Views:           shares[i].Views,
 			Preview:         shares[i].PreviewEnabled,
 			Expire:          -1,
 			RemainDownloads: shares[i].RemainDownloads,
+			Upload:          buildShareUpload(&shares[i]),
+			Internal:        shares[i].Internal,
+		}
+		if item.Upload != nil {
+			item.Upload.Notify = shares[i].UploadNotify
 		}
 		if shares[i].Expires != nil {
 			item.Expire = shares[i].Expires.Unix() - now
@@ -111,6 +175,8 @@ func BuildShareResponse(share *model.Share, unlocked bool) Share {
 	resp.Downloads = share.Downloads
 	resp.Views = share.Views
 	resp.Preview = share.PreviewEnabled
+	resp.Upload = buildShareUpload(share)
+	resp.Internal = share.Internal
 
 	if share.Expires != nil {
 		resp.Expire = share.Expires.Unix() - time.Now().Unix()
@@ -133,3 +199,112 @@ func BuildShareResponse(share *model.Share, unlocked bool) Share {
 	return resp
 
 }
+
+// BuildShareGrants 构建内部分享授权列表响应
+func BuildShareGrants(grants []model.ShareGrant) Response {
+	res := make([]shareGrant, 0, len(grants))
+	for _, grant := range grants {
+		item := shareGrant{
+			Type:       grant.TargetType,
+			Permission: SharePermissionNames[grant.Permission],
+		}
+
+		switch grant.TargetType {
+		case model.ShareGrantUser:
+			if user, err := model.GetUserByID(grant.TargetID); err == nil {
+				item.Target = user.Email
+				item.Name = user.Nick
+			}
+		case model.ShareGrantGroup:
+			item.Target = strconv.FormatUint(uint64(grant.TargetID), 10)
+			if group, err := model.GetGroupByID(grant.TargetID); err == nil {
+				item.Name = group.Name
+			}
+		}
+
+		res = append(res, item)
+	}
+
+	return Response{Data: res}
+}
+
+// BuildReceivedShareList 构建他人授权给我的分享列表响应
+func BuildReceivedShareList(shares []model.Share, total int, user *model.User) Response {
+	res := make([]receivedShareItem, 0, len(shares))
+	now := time.Now().Unix()
+	for i := 0; i < len(shares); i++ {
+		creator := shares[i].Creator()
+		item := receivedShareItem{
+			Key:        hashid.HashID(shares[i].ID, hashid.ShareID),
+			IsDir:      shares[i].IsDir,
+			CreateDate: shares[i].CreatedAt,
+			Expire:     -1,
+			Permission: SharePermissionNames[shares[i].PermissionOf(user)],
+			Creator: &shareCreator{
+				Key:       hashid.HashID(creator.ID, hashid.UserID),
+				Nick:      creator.Nick,
+				GroupName: creator.Group.Name,
+			},
+		}
+		if shares[i].Expires != nil {
+			item.Expire = shares[i].Expires.Unix() - now
+		}
+		if shares[i].File.ID != 0 {
+			item.Source = &shareSource{
+				Name: shares[i].File.Name,
+				Size: shares[i].File.Size,
+			}
+		} else if shares[i].Folder.ID != 0 {
+			item.Source = &shareSource{
+				Name: shares[i].Folder.Name,
+			}
+		}
+
+		res = append(res, item)
+	}
+
+	return Response{Data: map[string]interface{}{
+		"total": total,
+		"items": res,
+	}}
+}
+
+// shareAccessItem 分享访问记录条目
+type shareAccessItem struct {
+	Date      time.Time `json:"date"`
+	User      string    `json:"user,omitempty"`
+	IP        string    `json:"ip"`
+	Country   string    `json:"country"`
+	UserAgent string    `json:"user_agent"`
+	Action    string    `json:"action"`
+	File      string    `json:"file,omitempty"`
+	Path      string    `json:"path"`
+}
+
+// BuildShareAccessList 构建分享访问记录列表响应
+func BuildShareAccessList(accesses []model.ShareAccess, total int) Response {
+	res := make([]shareAccessItem, 0, len(accesses))
+	for _, access := range accesses {
+		item := shareAccessItem{
+			Date:      access.CreatedAt,
+			IP:        access.IP,
+			Country:   access.Country,
+			UserAgent: access.UserAgent,
+			Action:    access.Action,
+			Path:      access.Path,
+		}
+		if access.UserID != 0 {
+			item.User = hashid.HashID(access.UserID, hashid.UserID)
+		}
+		if access.FileID != 0 {
+			item.File = hashid.HashID(access.FileID, hashid.FileID)
+		}
+
+		res = append(res, item)
+	}
+
+	return Response{Data: map[string]interface{}{
+		"total": total,
+		"items": res,
+	}}
+}
@@ -0,0 +1,37 @@
+package serializer
+
+import (
+	"time"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/hashid"
+)
+
+// TrashItem 回收站对象条目
+type TrashItem struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Path      string    `json:"path"`
+	IsDir     bool      `json:"is_dir"`
+	Size      uint64    `json:"size"`
+	DeleteAt  time.Time `json:"delete_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// BuildTrashList 构建回收站列表响应
+func BuildTrashList(trashes []model.Trash, group *model.Group) Response {
+	res := make([]TrashItem, 0, len(trashes))
+	for i := 0; i < len(trashes); i++ {
+		res = append(res, TrashItem{
+			ID:        hashid.HashID(trashes[i].ID, hashid.TrashID),
+			Name:      trashes[i].Name,
+			Path:      trashes[i].Position,
+			IsDir:     trashes[i].IsDir,
+			Size:      trashes[i].Size,
+			DeleteAt:  trashes[i].CreatedAt,
+			ExpiresAt: trashes[i].ExpiresAt(group),
+		})
+	}
+
+	return Response{Data: res}
+}
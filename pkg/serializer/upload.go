@@ -30,6 +30,30 @@ type UploadCredential struct {
 	KeyTime     string   `json:"keyTime,omitempty"` // COS用有效期
 	Policy      string   `json:"policy,omitempty"`
 	CompleteURL string   `json:"completeURL,omitempty"`
+	// 秒传相关
+	Challenge *UploadChallenge `json:"challenge,omitempty"`
+	Completed bool             `json:"completed,omitempty"`
+}
+
+// UploadChallenge 秒传时要求客户端证明持有文件内容的挑战，客户端需返回
+// Nonce 与文件中 [Offset, Offset+Length) 范围内容拼接后的 SHA-256 摘要
+type UploadChallenge struct {
+	ID     string `json:"id"`
+	Offset uint64 `json:"offset"`
+	Length uint64 `json:"length"`
+	Nonce  string `json:"nonce"`
+}
+
+// InstantUploadSession 秒传挑战会话
+type InstantUploadSession struct {
+	UID      uint   // 发起者
+	PolicyID uint   // 存储策略
+	BlobID   uint   // 匹配到的 Blob，为 0 时表示没有相同内容
+	Hash     string // 文件内容摘要
+	Size     uint64 // 文件大小
+	Offset   uint64 // 校验范围起点
+	Length   uint64 // 校验范围长度
+	Nonce    string
 }
 
 // UploadSession 上传会话
@@ -47,11 +71,13 @@ type UploadSession struct {
 	UploadURL      string
 	UploadID       string
 	Credential     string
+	ShareID        uint // 通过收集文件分享发起的上传对应的分享ID
 }
 
 // UploadCallback 上传回调正文
 type UploadCallback struct {
 	PicInfo string `json:"pic_info"`
+	Hash    string `json:"hash,omitempty"` // 从机上传完成后计算的内容摘要
 }
 
 // GeneralUploadCallbackFailed 存储策略上传回调失败响应
@@ -61,4 +87,5 @@ type GeneralUploadCallbackFailed struct {
 
 func init() {
 	gob.Register(UploadSession{})
+	gob.Register(InstantUploadSession{})
 }
@@ -0,0 +1,34 @@
+package serializer
+
+import (
+	"time"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/hashid"
+)
+
+// FileVersion 文件历史版本条目
+type FileVersion struct {
+	ID        string     `json:"id"`
+	Size      uint64     `json:"size"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// BuildFileVersionList 构建文件历史版本列表响应
+func BuildFileVersionList(versions []model.FileVersion, group *model.Group) Response {
+	res := make([]FileVersion, 0, len(versions))
+	for i := 0; i < len(versions); i++ {
+		item := FileVersion{
+			ID:        hashid.HashID(versions[i].ID, hashid.FileVersionID),
+			Size:      versions[i].Size,
+			CreatedAt: versions[i].CreatedAt,
+		}
+		if expires := versions[i].ExpiresAt(group); !expires.IsZero() {
+			item.ExpiresAt = &expires
+		}
+		res = append(res, item)
+	}
+
+	return Response{Data: res}
+}
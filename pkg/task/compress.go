@@ -1,7 +1,6 @@
 package task
 
 import (
-	"context"
 	"encoding/json"
 	"fmt"
 	"os"
@@ -20,6 +19,8 @@ type CompressTask struct {
 	TaskProps CompressProps
 	Err       *JobError
 
+	jobContext
+
 	zipPath string
 }
 
@@ -56,6 +57,11 @@ func (job *CompressTask) SetStatus(status int) {
 	job.TaskModel.SetStatus(status)
 }
 
+// Local 压缩文件暂存在本机临时目录中，任务只能在创建任务的节点上执行
+func (job *CompressTask) Local() bool {
+	return true
+}
+
 // SetError 设定任务失败信息
 func (job *CompressTask) SetError(err *JobError) {
 	job.Err = err
@@ -113,7 +119,7 @@ func (job *CompressTask) Do() {
 	defer zipFile.Close()
 
 	// 开始压缩
-	ctx := context.Background()
+	ctx := job.Context()
 	err = fs.Compress(ctx, zipFile, job.TaskProps.Dirs, job.TaskProps.Files, false)
 	if err != nil {
 		job.SetErrorMsg(err.Error())
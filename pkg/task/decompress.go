@@ -1,7 +1,6 @@
 package task
 
 import (
-	"context"
 	"encoding/json"
 
 	model "github.com/cloudreve/Cloudreve/v3/models"
@@ -15,6 +14,8 @@ type DecompressTask struct {
 	TaskProps DecompressProps
 	Err       *JobError
 
+	jobContext
+
 	zipPath string
 }
 
@@ -51,6 +52,11 @@ func (job *DecompressTask) SetStatus(status int) {
 	job.TaskModel.SetStatus(status)
 }
 
+// Local 压缩包下载至本机临时目录后解压，任务只能在创建任务的节点上执行
+func (job *DecompressTask) Local() bool {
+	return true
+}
+
 // SetError 设定任务失败信息
 func (job *DecompressTask) SetError(err *JobError) {
 	job.Err = err
@@ -83,7 +89,7 @@ func (job *DecompressTask) Do() {
 
 	job.TaskModel.SetProgress(DecompressingProgress)
 
-	err = fs.Decompress(context.Background(), job.TaskProps.Src, job.TaskProps.Dst, job.TaskProps.Encoding)
+	err = fs.Decompress(job.Context(), job.TaskProps.Src, job.TaskProps.Dst, job.TaskProps.Encoding)
 	if err != nil {
 		job.SetErrorMsg("Failed to decompress file.", err)
 		return
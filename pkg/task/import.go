@@ -17,6 +17,8 @@ type ImportTask struct {
 	TaskModel *model.Task
 	TaskProps ImportProps
 	Err       *JobError
+
+	jobContext
 }
 
 // ImportProps 导入任务属性
@@ -76,7 +78,7 @@ func (job *ImportTask) GetError() *JobError {
 
 // Do 开始执行任务
 func (job *ImportTask) Do() {
-	ctx := context.Background()
+	ctx := job.Context()
 
 	// 查找存储策略
 	policy, err := model.GetPolicyByID(job.TaskProps.PolicyID)
@@ -106,7 +108,7 @@ func (job *ImportTask) Do() {
 
 	// 列取目录、对象
 	job.TaskModel.SetProgress(ListingProgress)
-	coxIgnoreConflict := context.WithValue(context.Background(), fsctx.IgnoreDirectoryConflictCtx,
+	coxIgnoreConflict := context.WithValue(job.Context(), fsctx.IgnoreDirectoryConflictCtx,
 		true)
 	objects, err := fs.Handler.List(ctx, job.TaskProps.Src, job.TaskProps.Recursive)
 	if err != nil {
@@ -150,7 +152,7 @@ func (job *ImportTask) Do() {
 			if parent, ok := pathCache[virtualPath]; ok {
 				parentFolder = parent
 			} else {
-				folder, err := fs.CreateDirectory(context.Background(), virtualPath)
+				folder, err := fs.CreateDirectory(job.Context(), virtualPath)
 				if err != nil {
 					util.Log().Warning("Importing task cannot create user directory %q: %s",
 						virtualPath, err)
@@ -161,7 +163,7 @@ func (job *ImportTask) Do() {
 			}
 
 			// 插入文件记录
-			_, err := fs.AddFile(context.Background(), parentFolder, &fileHeader)
+			file, err := fs.AddFile(job.Context(), parentFolder, &fileHeader)
 			if err != nil {
 				util.Log().Warning("Importing task cannot insert user file %q: %s",
 					object.RelativePath, err)
@@ -169,8 +171,12 @@ func (job *ImportTask) Do() {
 					job.SetErrorMsg("Insufficient storage capacity.", err)
 					return
 				}
+				continue
 			}
 
+			// 索引文件内容
+			filesystem.IndexContent(file)
+
 		}
 	}
 }
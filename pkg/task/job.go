@@ -1,6 +1,9 @@
 package task
 
 import (
+	"context"
+	"os"
+
 	model "github.com/cloudreve/Cloudreve/v3/models"
 	"github.com/cloudreve/Cloudreve/v3/pkg/util"
 )
@@ -17,6 +20,8 @@ const (
 	ImportTaskType
 	// RecycleTaskType 回收任务
 	RecycleTaskType
+	// MigrateTaskType 存储策略迁移任务
+	MigrateTaskType
 )
 
 // 任务状态
@@ -63,6 +68,38 @@ type Job interface {
 	GetError() *JobError // 获取任务执行结果，返回nil表示成功完成执行
 }
 
+// CancelableJob 可取消的任务，任务池在执行前注入 context，并在任务被其他实例接管时取消
+type CancelableJob interface {
+	SetContext(ctx context.Context) // 设定任务执行的 context
+	Context() context.Context       // 返回任务执行的 context
+}
+
+// LocalJob 依赖本机文件的任务，只能在创建任务的节点上执行
+type LocalJob interface {
+	Local() bool // 返回任务是否依赖本机文件
+}
+
+// NodeName 当前节点的名称，用于固定依赖本机文件的任务，同一节点上的实例重启后可继续执行
+var NodeName, _ = os.Hostname()
+
+// jobContext 任务执行的 context，嵌入任务以实现 CancelableJob
+type jobContext struct {
+	ctx context.Context
+}
+
+// SetContext 设定任务执行的 context
+func (c *jobContext) SetContext(ctx context.Context) {
+	c.ctx = ctx
+}
+
+// Context 返回任务执行的 context，未设定时返回 context.Background()
+func (c *jobContext) Context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
 // JobError 任务失败信息
 type JobError struct {
 	Msg   string `json:"msg,omitempty"`
@@ -79,6 +116,9 @@ func Record(job Job) (*model.Task, error) {
 		Error:    "",
 		Props:    job.Props(),
 	}
+	if local, ok := job.(LocalJob); ok && local.Local() {
+		record.Node = NodeName
+	}
 	_, err := record.Create()
 	return &record, err
 }
@@ -117,6 +157,8 @@ func GetJobFromModel(task *model.Task) (Job, error) {
 		return NewImportTaskFromModel(task)
 	case RecycleTaskType:
 		return NewRecycleTaskFromModel(task)
+	case MigrateTaskType:
+		return NewMigrateTaskFromModel(task)
 	default:
 		return nil, ErrUnknownTaskType
 	}
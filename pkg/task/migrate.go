@@ -0,0 +1,237 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+)
+
+// migrateBatchSize 每批次读取的文件数量
+const migrateBatchSize = 100
+
+// MigrateTask 存储策略迁移任务
+type MigrateTask struct {
+	User      *model.User
+	TaskModel *model.Task
+	TaskProps MigrateProps
+	Err       *JobError
+
+	jobContext
+}
+
+// MigrateProps 迁移任务属性
+type MigrateProps struct {
+	SrcPolicyID uint `json:"src_policy_id"`       // 源存储策略ID
+	DstPolicyID uint `json:"dst_policy_id"`       // 目标存储策略ID
+	UserID      uint `json:"user_id,omitempty"`   // 只迁移此用户的文件
+	GroupID     uint `json:"group_id,omitempty"`  // 只迁移此用户组下用户的文件
+	FolderID    uint `json:"folder_id,omitempty"` // 只迁移此目录及其子目录下的文件，需同时指定用户
+	VerifyHash  bool `json:"verify_hash"`         // 是否校验内容摘要，否则只校验大小
+
+	// 执行进度，用于恢复任务
+	LastFileID    uint   `json:"last_file_id"`
+	LastVersionID uint   `json:"last_version_id"`
+	Migrated      int    `json:"migrated"`
+	Failed        []uint `json:"failed,omitempty"` // 迁移失败的文件ID
+}
+
+// Props 获取任务属性
+func (job *MigrateTask) Props() string {
+	res, _ := json.Marshal(job.TaskProps)
+	return string(res)
+}
+
+// Type 获取任务类型
+func (job *MigrateTask) Type() int {
+	return MigrateTaskType
+}
+
+// Creator 获取创建者ID
+func (job *MigrateTask) Creator() uint {
+	return job.User.ID
+}
+
+// Model 获取任务的数据库模型
+func (job *MigrateTask) Model() *model.Task {
+	return job.TaskModel
+}
+
+// SetStatus 设定状态
+func (job *MigrateTask) SetStatus(status int) {
+	job.TaskModel.SetStatus(status)
+}
+
+// SetError 设定任务失败信息
+func (job *MigrateTask) SetError(err *JobError) {
+	job.Err = err
+	res, _ := json.Marshal(job.Err)
+	job.TaskModel.SetError(string(res))
+}
+
+// SetErrorMsg 设定任务失败信息
+func (job *MigrateTask) SetErrorMsg(msg string, err error) {
+	jobErr := &JobError{Msg: msg}
+	if err != nil {
+		jobErr.Error = err.Error()
+	}
+	job.SetError(jobErr)
+}
+
+// GetError 返回任务失败信息
+func (job *MigrateTask) GetError() *JobError {
+	return job.Err
+}
+
+// Do 开始执行任务
+func (job *MigrateTask) Do() {
+	ctx := job.Context()
+
+	dst, err := model.GetPolicyByID(job.TaskProps.DstPolicyID)
+	if err != nil {
+		job.SetErrorMsg("Policy not exist.", err)
+		return
+	}
+
+	// 整理需要迁移的目录
+	scope := &model.MigrateScope{UserID: job.TaskProps.UserID, GroupID: job.TaskProps.GroupID}
+	if job.TaskProps.FolderID != 0 {
+		children, err := model.GetRecursiveChildFolder([]uint{job.TaskProps.FolderID}, job.TaskProps.UserID, true)
+		if err != nil || len(children) == 0 {
+			job.SetErrorMsg("Folder not exist.", err)
+			return
+		}
+		for _, folder := range children {
+			scope.Folders = append(scope.Folders, folder.ID)
+		}
+	}
+
+	job.User.Policy = dst
+	fs, err := filesystem.NewFileSystem(job.User)
+	if err != nil {
+		job.SetErrorMsg(err.Error(), nil)
+		return
+	}
+	defer fs.Recycle()
+
+	// 迁移文件，moved 记录已迁移的源文件
+	props := &job.TaskProps
+	moved := make(map[string]bool)
+	for {
+		files, err := model.GetFilesByPolicy(props.SrcPolicyID, props.LastFileID, scope, migrateBatchSize)
+		if err != nil {
+			job.SetErrorMsg("Failed to list files.", err)
+			return
+		}
+		if len(files) == 0 {
+			break
+		}
+
+		for i := range files {
+			job.migrate(ctx, fs, &files[i], &dst, scope, moved)
+			props.LastFileID = files[i].ID
+			job.saveProgress()
+		}
+	}
+
+	// 迁移历史版本
+	for {
+		versions, err := model.GetVersionsByPolicy(props.SrcPolicyID, props.LastVersionID, scope, migrateBatchSize)
+		if err != nil {
+			job.SetErrorMsg("Failed to list file versions.", err)
+			return
+		}
+		if len(versions) == 0 {
+			break
+		}
+
+		for _, version := range versions {
+			file := version.AsFile(&model.File{Name: path.Base(version.SourceName), UserID: version.UserID})
+			file.ID = version.FileID
+			job.migrate(ctx, fs, &file, &dst, scope, moved)
+			props.LastVersionID = version.ID
+			job.saveProgress()
+		}
+	}
+
+	if len(props.Failed) > 0 {
+		job.SetErrorMsg(fmt.Sprintf("Failed to migrate %d file(s).", len(props.Failed)), nil)
+	}
+}
+
+// migrate 迁移单个文件的源文件
+func (job *MigrateTask) migrate(ctx context.Context, fs *filesystem.FileSystem, file *model.File, dst *model.Policy,
+	scope *model.MigrateScope, moved map[string]bool) {
+	// 已随共用源文件的其他文件一同迁移
+	if file.PolicyID != job.TaskProps.SrcPolicyID || moved[file.SourceName] {
+		return
+	}
+
+	owner, err := model.GetUserByID(file.UserID)
+	if err != nil {
+		util.Log().Warning("Migrate task cannot find owner of file %d: %s", file.ID, err)
+		job.TaskProps.Failed = append(job.TaskProps.Failed, file.ID)
+		return
+	}
+	fs.User = &owner
+
+	source := file.SourceName
+	if err := fs.MigrateSource(ctx, file, dst, scope, job.TaskProps.VerifyHash); err != nil {
+		util.Log().Warning("Migrate task cannot migrate file %d: %s", file.ID, err)
+		job.TaskProps.Failed = append(job.TaskProps.Failed, file.ID)
+		return
+	}
+
+	moved[source] = true
+	job.TaskProps.Migrated++
+}
+
+// saveProgress 保存执行进度
+func (job *MigrateTask) saveProgress() {
+	job.TaskModel.SetProps(job.Props())
+	job.TaskModel.SetProgress(job.TaskProps.Migrated)
+}
+
+// NewMigrateTask 新建存储策略迁移任务
+func NewMigrateTask(user uint, props MigrateProps) (Job, error) {
+	creator, err := model.GetActiveUserByID(user)
+	if err != nil {
+		return nil, err
+	}
+
+	newTask := &MigrateTask{
+		User:      &creator,
+		TaskProps: props,
+	}
+
+	record, err := Record(newTask)
+	if err != nil {
+		return nil, err
+	}
+	newTask.TaskModel = record
+
+	return newTask, nil
+}
+
+// NewMigrateTaskFromModel 从数据库记录中恢复迁移任务
+func NewMigrateTaskFromModel(task *model.Task) (Job, error) {
+	user, err := model.GetActiveUserByID(task.UserID)
+	if err != nil {
+		return nil, err
+	}
+	newTask := &MigrateTask{
+		User:      &user,
+		TaskModel: task,
+	}
+
+	err = json.Unmarshal([]byte(task.Props), &newTask.TaskProps)
+	if err != nil {
+		return nil, err
+	}
+
+	return newTask, nil
+}
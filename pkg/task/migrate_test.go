@@ -0,0 +1,174 @@
+package task
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
+	"github.com/cloudreve/Cloudreve/v3/pkg/conf"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrateTask_Props(t *testing.T) {
+	asserts := assert.New(t)
+	task := &MigrateTask{
+		User: &model.User{},
+	}
+	asserts.NotEmpty(task.Props())
+	asserts.Equal(MigrateTaskType, task.Type())
+	asserts.EqualValues(0, task.Creator())
+	asserts.Nil(task.Model())
+}
+
+func TestMigrateTask_DoSQLite(t *testing.T) {
+	asserts := assert.New(t)
+	mockDB := model.DB
+	dbType := conf.DatabaseConfig.Type
+	conf.DatabaseConfig.Type = "sqlite"
+	model.DB, _ = gorm.Open("sqlite", ":memory:")
+	defer func() {
+		model.DB = mockDB
+		conf.DatabaseConfig.Type = dbType
+	}()
+	model.DB.AutoMigrate(&model.User{}, &model.Group{}, &model.Policy{}, &model.Folder{}, &model.File{},
+		&model.FileVersion{}, &model.Blob{}, &model.Task{})
+
+	newPolicy := func(name string) *model.Policy {
+		policy := &model.Policy{
+			Type:         "local",
+			Name:         name,
+			DirNameRule:  filepath.ToSlash(t.TempDir()),
+			FileNameRule: "{randomkey16}_{originname}",
+		}
+		asserts.NoError(model.DB.Create(policy).Error)
+		cache.Deletes([]string{strconv.Itoa(int(policy.ID))}, "policy_")
+		return policy
+	}
+	src := newPolicy("src")
+	dst := newPolicy("dst")
+
+	user := &model.User{Email: "migrate@cloudreve.org"}
+	asserts.NoError(model.DB.Create(user).Error)
+	root, err := user.Root()
+	asserts.NoError(err)
+	other := &model.User{Email: "other@cloudreve.org"}
+	asserts.NoError(model.DB.Create(other).Error)
+	otherRoot, err := other.Root()
+	asserts.NoError(err)
+
+	newFile := func(name, content string) *model.File {
+		source := filepath.ToSlash(filepath.Join(src.DirNameRule, name))
+		asserts.NoError(ioutil.WriteFile(source, []byte(content), 0644))
+		file := &model.File{Name: name, SourceName: source, UserID: user.ID, FolderID: root.ID,
+			PolicyID: src.ID, Size: uint64(len(content))}
+		asserts.NoError(model.DB.Create(file).Error)
+		return file
+	}
+
+	// 已处理过的文件不再迁移
+	skipped := newFile("skipped.txt", "skipped")
+	file1 := newFile("1.txt", "content1")
+	asserts.NoError(ioutil.WriteFile(file1.ThumbFile(), []byte("thumb"), 0644))
+	asserts.NoError(file1.UpdateMetadata(map[string]string{model.ThumbStatusMetadataKey: model.ThumbStatusExist}))
+	file2 := newFile("2.txt", "content2")
+	shared := &model.File{Name: "shared.txt", SourceName: file2.SourceName, UserID: user.ID, FolderID: root.ID,
+		PolicyID: src.ID, Size: file2.Size}
+	asserts.NoError(model.DB.Create(shared).Error)
+	previous := filepath.ToSlash(filepath.Join(src.DirNameRule, "previous.txt"))
+	asserts.NoError(ioutil.WriteFile(previous, []byte("previous"), 0644))
+	version := &model.FileVersion{FileID: file1.ID, UserID: user.ID, SourceName: previous, Size: 8, PolicyID: src.ID}
+	asserts.NoError(model.DB.Create(version).Error)
+	// 与筛选条件之外的文件共用的源文件
+	deduplicated := &model.File{Name: "3.txt", SourceName: filepath.ToSlash(filepath.Join(src.DirNameRule, "3.txt")),
+		UserID: user.ID, FolderID: root.ID, PolicyID: src.ID, Size: 8}
+	asserts.NoError(ioutil.WriteFile(deduplicated.SourceName, []byte("content3"), 0644))
+	_, err = deduplicated.CreateWithBlob("hash3")
+	asserts.NoError(err)
+	outside := &model.File{Name: "3.txt", SourceName: "duplicated", UserID: other.ID, FolderID: otherRoot.ID,
+		PolicyID: src.ID, Size: 8}
+	_, err = outside.CreateWithBlob("hash3")
+	asserts.NoError(err)
+	asserts.Equal(deduplicated.SourceName, outside.SourceName)
+
+	missing := &model.File{Name: "missing.txt", SourceName: "not_exist", UserID: user.ID, FolderID: root.ID,
+		PolicyID: src.ID, Size: 1}
+	asserts.NoError(model.DB.Create(missing).Error)
+
+	job, err := NewMigrateTask(user.ID, MigrateProps{
+		SrcPolicyID: src.ID,
+		DstPolicyID: dst.ID,
+		UserID:      user.ID,
+		VerifyHash:  true,
+		LastFileID:  skipped.ID,
+	})
+	asserts.NoError(err)
+	job.Do()
+
+	props := job.(*MigrateTask).TaskProps
+	asserts.Equal(4, props.Migrated)
+	asserts.Equal([]uint{missing.ID}, props.Failed)
+	asserts.Equal(missing.ID, props.LastFileID)
+	asserts.Equal(version.ID, props.LastVersionID)
+	asserts.NotNil(job.GetError())
+
+	files, err := model.GetFilesByIDs([]uint{skipped.ID, file1.ID, file2.ID, shared.ID}, user.ID)
+	asserts.NoError(err)
+	for _, file := range files {
+		if file.ID == skipped.ID {
+			asserts.Equal(src.ID, file.PolicyID)
+			continue
+		}
+
+		asserts.Equal(dst.ID, file.PolicyID)
+		asserts.True(util.Exists(file.SourceName))
+		if file.ID == shared.ID {
+			asserts.Equal(files[2].SourceName, file.SourceName)
+		}
+	}
+	asserts.False(util.Exists(file1.SourceName))
+	asserts.False(util.Exists(file1.ThumbFile()))
+	asserts.False(util.Exists(file2.SourceName))
+	asserts.NotContains(files[1].MetadataSerialized, model.ThumbStatusMetadataKey)
+
+	// 筛选条件之外的文件仍使用原源文件
+	files, err = model.GetFilesByIDs([]uint{deduplicated.ID}, user.ID)
+	asserts.NoError(err)
+	asserts.Equal(dst.ID, files[0].PolicyID)
+	migrated := files[0]
+	files, err = model.GetFilesByIDs([]uint{outside.ID}, other.ID)
+	asserts.NoError(err)
+	asserts.Equal(src.ID, files[0].PolicyID)
+	asserts.Equal(outside.SourceName, files[0].SourceName)
+	asserts.True(util.Exists(outside.SourceName))
+
+	// 迁移的引用关联至新的 Blob
+	blob, err := model.GetBlobByHash(src.ID, "hash3", 8)
+	asserts.NoError(err)
+	asserts.Equal(outside.BlobID, blob.ID)
+	asserts.Equal(1, blob.RefCount)
+	blob, err = model.GetBlobByHash(dst.ID, "hash3", 8)
+	asserts.NoError(err)
+	asserts.Equal(migrated.BlobID, blob.ID)
+	asserts.Equal(migrated.SourceName, blob.SourceName)
+	asserts.Equal(1, blob.RefCount)
+
+	// 迁移历史版本
+	versions, err := model.GetVersionsByFileID(file1.ID, user.ID)
+	asserts.NoError(err)
+	asserts.Equal(dst.ID, versions[0].PolicyID)
+	asserts.True(util.Exists(versions[0].SourceName))
+	asserts.False(util.Exists(previous))
+
+	// 记录了执行进度
+	record, err := model.GetTasksByID(job.Model().ID)
+	asserts.NoError(err)
+	asserts.Equal(4, record.Progress)
+	resumed, err := NewMigrateTaskFromModel(record)
+	asserts.NoError(err)
+	asserts.Equal(props, resumed.(*MigrateTask).TaskProps)
+}
@@ -1,6 +1,9 @@
 package task
 
 import (
+	"context"
+	"time"
+
 	model "github.com/cloudreve/Cloudreve/v3/models"
 	"github.com/cloudreve/Cloudreve/v3/pkg/conf"
 	"github.com/cloudreve/Cloudreve/v3/pkg/util"
@@ -41,6 +44,11 @@ func (pool *AsyncPool) freeWorker() {
 	pool.Add(1)
 }
 
+// Stats 返回空闲 Worker 数量及 Worker 总数
+func (pool *AsyncPool) Stats() (idle, max int) {
+	return len(pool.idleWorker), cap(pool.idleWorker)
+}
+
 // Submit 开始提交任务
 func (pool *AsyncPool) Submit(job Job) {
 	go func() {
@@ -53,16 +61,40 @@ func (pool *AsyncPool) Submit(job Job) {
 	}()
 }
 
-// Init 初始化任务池
+// Submit 向任务池提交任务，并在任务记录及日志中记录任务与发起请求的关联
+func Submit(ctx context.Context, pool Pool, job Job) {
+	if record := job.Model(); record != nil {
+		if id := util.RequestID(ctx); id != "" {
+			record.RequestID = id
+			if err := record.SetRequestID(id); err != nil {
+				util.Log().WithContext(ctx).Warning("Failed to save request ID of task #%d: %s", record.ID, err)
+			}
+		}
+		util.Log().WithContext(ctx).Info("Task #%d submitted.", record.ID)
+	}
+	pool.Submit(job)
+}
+
+// Init 初始化任务池。主机模式下使用持久化任务队列，各类型任务分别限制并发数
 func Init() {
 	maxWorker := model.GetIntSetting("max_worker_num", 10)
-	TaskPoll = &AsyncPool{
-		idleWorker: make(chan int, maxWorker),
+	if conf.SystemConfig.Mode != "master" {
+		TaskPoll = &AsyncPool{
+			idleWorker: make(chan int, maxWorker),
+		}
+		TaskPoll.Add(maxWorker)
+		util.Log().Info("Initialize task queue with WorkerNum = %d", maxWorker)
+		return
 	}
-	TaskPoll.Add(maxWorker)
-	util.Log().Info("Initialize task queue with WorkerNum = %d", maxWorker)
 
-	if conf.SystemConfig.Mode == "master" {
-		Resume(TaskPoll)
+	limits := make(map[int]int, len(typeNames))
+	for t, name := range typeNames {
+		limits[t] = model.GetIntSetting("task_concurrency_"+name, maxWorker)
 	}
+
+	ttl := time.Duration(model.GetIntSetting("task_lease_ttl", 60)) * time.Second
+	queue := NewQueuePool(limits, ttl)
+	TaskPoll = queue
+	queue.Start()
+	util.Log().Info("Initialize persistent task queue with concurrency %v", limits)
 }
@@ -7,6 +7,7 @@ import (
 	"github.com/DATA-DOG/go-sqlmock"
 	model "github.com/cloudreve/Cloudreve/v3/models"
 	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
+	"github.com/cloudreve/Cloudreve/v3/pkg/conf"
 	"github.com/jinzhu/gorm"
 	"github.com/stretchr/testify/assert"
 )
@@ -29,10 +30,29 @@ func TestMain(m *testing.M) {
 func TestInit(t *testing.T) {
 	asserts := assert.New(t)
 	cache.Set("setting_max_worker_num", "10", 0)
-	mock.ExpectQuery("SELECT(.+)").WithArgs(Queued, Processing).WillReturnRows(sqlmock.NewRows([]string{"type"}).AddRow(-1))
-	Init()
-	asserts.NoError(mock.ExpectationsWereMet())
-	asserts.Len(TaskPoll.(*AsyncPool).idleWorker, 10)
+
+	// 从机模式
+	{
+		conf.SystemConfig.Mode = "slave"
+		defer func() {
+			conf.SystemConfig.Mode = "master"
+		}()
+		Init()
+		asserts.Len(TaskPoll.(*AsyncPool).idleWorker, 10)
+	}
+
+	// 主机模式，各类型分别限制并发数
+	{
+		conf.SystemConfig.Mode = "master"
+		defer setupQueueDB()()
+		cache.Set("setting_task_concurrency_migrate", "1", 0)
+		Init()
+		pool := TaskPoll.(*QueuePool)
+		pool.Stop()
+		asserts.Equal(1, pool.limits[MigrateTaskType])
+		asserts.Equal(10, pool.limits[CompressTaskType])
+		asserts.Len(pool.limits, len(typeNames))
+	}
 }
 
 func TestPool_Submit(t *testing.T) {
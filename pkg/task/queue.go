@@ -0,0 +1,288 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+	"github.com/gofrs/uuid"
+)
+
+var (
+	// ErrTaskNotCancelable 任务正在执行或已结束，无法取消
+	ErrTaskNotCancelable = errors.New("task is running or already finished")
+	// ErrTaskNotRetryable 仅失败或已取消的任务可以重试
+	ErrTaskNotRetryable = errors.New("only failed or canceled tasks can be retried")
+)
+
+const (
+	// queuePollInterval 轮询数据库中待执行任务的间隔
+	queuePollInterval = 5 * time.Second
+)
+
+// typeNames 各类型任务在并发设置项中的名称
+var typeNames = map[int]string{
+	CompressTaskType:   "compress",
+	DecompressTaskType: "decompress",
+	TransferTaskType:   "transfer",
+	ImportTaskType:     "import",
+	RecycleTaskType:    "recycle",
+	MigrateTaskType:    "migrate",
+}
+
+// QueuePool 基于数据库的持久化任务队列。各主机实例通过租约领取任务并在执行期间定期续约，
+// 实例崩溃后租约过期，任务由其他实例重新执行；依赖本机文件的任务只由创建任务的节点领取。
+// 各实例的时钟需保持同步
+type QueuePool struct {
+	owner    string
+	node     string
+	ttl      time.Duration
+	interval time.Duration
+	newJob   func(*model.Task) (Job, error)
+
+	mu      sync.Mutex
+	limits  map[int]int // 各类型任务的并发上限
+	running map[int]int // 各类型正在执行的任务数
+	wake    chan struct{}
+	quit    chan struct{}
+	stopped chan struct{}
+}
+
+// NewQueuePool 创建持久化任务队列，limits 为各类型任务的并发上限
+func NewQueuePool(limits map[int]int, ttl time.Duration) *QueuePool {
+	return &QueuePool{
+		owner:    NodeName + "-" + uuid.Must(uuid.NewV4()).String(),
+		node:     NodeName,
+		ttl:      ttl,
+		interval: queuePollInterval,
+		newJob:   GetJobFromModel,
+		limits:   limits,
+		running:  make(map[int]int),
+		wake:     make(chan struct{}, 1),
+		quit:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+}
+
+// Add 为每种类型的任务增加并发上限
+func (pool *QueuePool) Add(num int) {
+	pool.mu.Lock()
+	for t := range pool.limits {
+		pool.limits[t] += num
+	}
+	pool.mu.Unlock()
+	pool.notify()
+}
+
+// Submit 提交任务。任务已记录在数据库中，由轮询领取后执行；没有数据库记录的任务直接在本实例执行
+func (pool *QueuePool) Submit(job Job) {
+	if job.Model() == nil {
+		go (&GeneralWorker{}).Do(job)
+		return
+	}
+
+	pool.notify()
+}
+
+// Stats 返回空闲 Worker 数量及 Worker 总数
+func (pool *QueuePool) Stats() (idle, max int) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	for t, limit := range pool.limits {
+		max += limit
+		idle += limit - pool.running[t]
+	}
+	return
+}
+
+// Start 开始轮询并执行队列中的任务
+func (pool *QueuePool) Start() {
+	go func() {
+		defer close(pool.stopped)
+		ticker := time.NewTicker(pool.interval)
+		defer ticker.Stop()
+		for {
+			pool.poll()
+			select {
+			case <-ticker.C:
+			case <-pool.wake:
+			case <-pool.quit:
+				return
+			}
+		}
+	}()
+}
+
+// Stop 停止领取新任务，已领取的任务继续执行
+func (pool *QueuePool) Stop() {
+	close(pool.quit)
+	<-pool.stopped
+}
+
+// notify 唤醒轮询
+func (pool *QueuePool) notify() {
+	select {
+	case pool.wake <- struct{}{}:
+	default:
+	}
+}
+
+// poll 按各类型的空闲配额领取待执行或租约已过期的任务
+func (pool *QueuePool) poll() {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	types := make([]int, 0, len(pool.limits))
+	for t := range pool.limits {
+		types = append(types, t)
+	}
+	sort.Ints(types)
+
+	for _, t := range types {
+		free := pool.limits[t] - pool.running[t]
+		if free <= 0 {
+			continue
+		}
+
+		tasks := model.GetLeasableTasks(t, free, pool.node, Queued, Processing)
+		for i := range tasks {
+			record := &tasks[i]
+			ok, err := record.Lease(pool.owner, time.Now().Add(pool.ttl), Queued, Processing)
+			if err != nil {
+				util.Log().Warning("Failed to lease task #%d: %s", record.ID, err)
+				continue
+			}
+
+			// 已被其他实例领取
+			if !ok {
+				continue
+			}
+
+			pool.running[t]++
+			go pool.execute(record)
+		}
+	}
+}
+
+// execute 执行已领取的任务，执行期间定期续约，租约丢失时取消任务
+func (pool *QueuePool) execute(record *model.Task) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	defer func() {
+		close(done)
+		cancel()
+		if err := record.ReleaseLease(pool.owner); err != nil {
+			util.Log().Warning("Failed to release lease of task #%d: %s", record.ID, err)
+		}
+
+		pool.mu.Lock()
+		pool.running[record.Type]--
+		pool.mu.Unlock()
+		pool.notify()
+	}()
+
+	go pool.heartbeat(record, done, cancel)
+
+	job, err := pool.newJob(record)
+	if err != nil {
+		// 无法恢复的任务标记为失败，避免被反复领取
+		util.Log().Warning("Failed to restore task #%d: %s", record.ID, err)
+		res, _ := json.Marshal(&JobError{Msg: "Failed to restore task.", Error: err.Error()})
+		record.SetError(string(res))
+		record.SetStatus(Error)
+		return
+	}
+
+	if cancelable, ok := job.(CancelableJob); ok {
+		cancelable.SetContext(ctx)
+	}
+
+	(&GeneralWorker{}).Do(job)
+}
+
+// heartbeat 定期续约，直到任务执行结束。租约被其他实例接管，或续约失败且租约将在下次续约前过期时取消任务
+func (pool *QueuePool) heartbeat(record *model.Task, done <-chan struct{}, cancel context.CancelFunc) {
+	interval := pool.ttl / 3
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	until := time.Now().Add(pool.ttl)
+	if record.LeaseUntil != nil {
+		until = *record.LeaseUntil
+	}
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			next := time.Now().Add(pool.ttl)
+			ok, err := record.RenewLease(pool.owner, next)
+			if err != nil {
+				util.Log().Warning("Failed to renew lease of task #%d: %s", record.ID, err)
+				if time.Now().Add(interval).Before(until) {
+					continue
+				}
+
+				util.Log().Warning("Lease of task #%d is expiring, cancel the task.", record.ID)
+			} else if ok {
+				until = next
+				continue
+			} else {
+				util.Log().Warning("Lease of task #%d is taken over by another instance, cancel the task.", record.ID)
+			}
+
+			cancel()
+			return
+		}
+	}
+}
+
+// Cancel 取消尚未开始执行的任务，正在执行中的任务无法取消
+func Cancel(record *model.Task) error {
+	ok, err := record.UpdateUnleased(map[string]interface{}{"status": Canceled}, Queued, Processing)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return ErrTaskNotCancelable
+	}
+
+	return nil
+}
+
+// Retry 将失败或已取消的任务重新加入队列
+func Retry(pool Pool, record *model.Task) error {
+	ok, err := record.UpdateUnleased(map[string]interface{}{
+		"status":   Queued,
+		"progress": PendingProgress,
+		"error":    "",
+	}, Error, Canceled)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return ErrTaskNotRetryable
+	}
+
+	if queue, ok := pool.(*QueuePool); ok {
+		queue.notify()
+		return nil
+	}
+
+	job, err := GetJobFromModel(record)
+	if err != nil {
+		return err
+	}
+
+	pool.Submit(job)
+	return nil
+}
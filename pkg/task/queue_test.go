@@ -0,0 +1,271 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+type queueJob struct {
+	MockJob
+	jobContext
+	record *model.Task
+}
+
+func (job *queueJob) Type() int {
+	return job.record.Type
+}
+
+func (job *queueJob) Model() *model.Task {
+	return job.record
+}
+
+func (job *queueJob) SetStatus(status int) {
+	job.record.SetStatus(status)
+}
+
+func setupQueueDB() func() {
+	mockDB := model.DB
+	model.DB, _ = gorm.Open("sqlite", ":memory:")
+	// 内存数据库的每个连接相互独立
+	model.DB.DB().SetMaxOpenConns(1)
+	model.DB.AutoMigrate(&model.Task{})
+	return func() {
+		model.DB = mockDB
+	}
+}
+
+func newTestQueuePool(limits map[int]int, do func(*model.Task)) *QueuePool {
+	pool := NewQueuePool(limits, time.Second)
+	pool.interval = 50 * time.Millisecond
+	pool.newJob = func(record *model.Task) (Job, error) {
+		if record.Props == "invalid" {
+			return nil, errors.New("invalid")
+		}
+		return &queueJob{record: record, MockJob: MockJob{DoFunc: func() { do(record) }}}, nil
+	}
+	return pool
+}
+
+func status(id uint) int {
+	record, _ := model.GetTasksByID(id)
+	return record.Status
+}
+
+func TestQueuePool(t *testing.T) {
+	a := assert.New(t)
+	defer setupQueueDB()()
+
+	var records []*model.Task
+	for _, taskType := range []int{CompressTaskType, CompressTaskType, MigrateTaskType, ImportTaskType} {
+		record := &model.Task{Type: taskType}
+		_, err := record.Create()
+		a.NoError(err)
+		records = append(records, record)
+	}
+
+	release := make(map[uint]chan struct{})
+	for _, record := range records {
+		release[record.ID] = make(chan struct{})
+	}
+	started := make(chan uint, 10)
+	pool := newTestQueuePool(map[int]int{CompressTaskType: 1, MigrateTaskType: 1}, func(record *model.Task) {
+		started <- record.ID
+		<-release[record.ID]
+	})
+	pool.Start()
+	defer pool.Stop()
+
+	// 各类型分别受并发上限限制，未配置的类型不执行
+	a.ElementsMatch([]uint{records[0].ID, records[2].ID}, []uint{<-started, <-started})
+	idle, max := pool.Stats()
+	a.Equal(0, idle)
+	a.Equal(2, max)
+	a.Equal(Processing, status(records[0].ID))
+	a.Equal(Queued, status(records[1].ID))
+
+	// 执行中的任务不能取消
+	a.ErrorIs(Cancel(records[0]), ErrTaskNotCancelable)
+
+	close(release[records[0].ID])
+	a.Equal(records[1].ID, <-started)
+	close(release[records[1].ID])
+	close(release[records[2].ID])
+	a.Eventually(func() bool {
+		return status(records[1].ID) == Complete && status(records[2].ID) == Complete
+	}, 5*time.Second, 10*time.Millisecond)
+	a.Equal(Queued, status(records[3].ID))
+
+	// 租约已释放
+	record, _ := model.GetTasksByID(records[0].ID)
+	a.Equal(Complete, record.Status)
+	a.Empty(record.Owner)
+	a.Nil(record.LeaseUntil)
+	a.Eventually(func() bool {
+		idle, _ := pool.Stats()
+		return idle == 2
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestQueuePool_Takeover(t *testing.T) {
+	a := assert.New(t)
+	defer setupQueueDB()()
+
+	// 崩溃实例持有的租约已过期
+	expired := time.Now().Add(-time.Second)
+	crashed := &model.Task{Type: CompressTaskType, Status: Processing, Owner: "crashed", LeaseUntil: &expired}
+	_, err := crashed.Create()
+	a.NoError(err)
+
+	// 其他实例持有的有效租约
+	valid := time.Now().Add(time.Hour)
+	alive := &model.Task{Type: CompressTaskType, Status: Processing, Owner: "alive", LeaseUntil: &valid}
+	_, err = alive.Create()
+	a.NoError(err)
+
+	// 无法恢复的任务
+	invalid := &model.Task{Type: CompressTaskType, Props: "invalid"}
+	_, err = invalid.Create()
+	a.NoError(err)
+
+	renewed := make(chan struct{})
+	pool := newTestQueuePool(map[int]int{CompressTaskType: 5}, func(record *model.Task) {
+		if record.ID != crashed.ID {
+			return
+		}
+
+		// 执行期间持续续约
+		before := *record.LeaseUntil
+		a.Eventually(func() bool {
+			found, _ := model.GetTasksByID(record.ID)
+			return found.LeaseUntil != nil && found.LeaseUntil.After(before)
+		}, 5*time.Second, 50*time.Millisecond)
+		close(renewed)
+	})
+	pool.Start()
+	defer pool.Stop()
+
+	<-renewed
+	a.Eventually(func() bool {
+		return status(crashed.ID) == Complete && status(invalid.ID) == Error
+	}, 5*time.Second, 10*time.Millisecond)
+	a.Equal(Processing, status(alive.ID))
+
+	found, _ := model.GetTasksByID(invalid.ID)
+	a.Contains(found.Error, "Failed to restore task.")
+}
+
+func TestQueuePool_LeaseLost(t *testing.T) {
+	a := assert.New(t)
+	defer setupQueueDB()()
+
+	record := &model.Task{Type: CompressTaskType}
+	_, err := record.Create()
+	a.NoError(err)
+
+	canceled := make(chan struct{})
+	pool := newTestQueuePool(map[int]int{CompressTaskType: 1}, nil)
+	pool.newJob = func(record *model.Task) (Job, error) {
+		job := &queueJob{record: record}
+		job.DoFunc = func() {
+			// 租约被其他实例接管后取消任务
+			model.DB.Model(&model.Task{}).Where("id = ?", record.ID).Update("owner", "other")
+			select {
+			case <-job.Context().Done():
+				close(canceled)
+			case <-time.After(5 * time.Second):
+			}
+		}
+		return job, nil
+	}
+	pool.Start()
+	defer pool.Stop()
+
+	select {
+	case <-canceled:
+	case <-time.After(5 * time.Second):
+		a.FailNow("task is not canceled")
+	}
+
+	// 不再更新任务状态
+	a.Eventually(func() bool {
+		idle, _ := pool.Stats()
+		return idle == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	a.Equal(Processing, status(record.ID))
+}
+
+func TestQueuePool_Node(t *testing.T) {
+	a := assert.New(t)
+	defer setupQueueDB()()
+
+	// 固定在其他节点的任务不会被领取
+	remote := &model.Task{Type: CompressTaskType, Node: "other-node"}
+	_, err := remote.Create()
+	a.NoError(err)
+	local := &model.Task{Type: CompressTaskType, Node: NodeName}
+	_, err = local.Create()
+	a.NoError(err)
+
+	started := make(chan uint, 10)
+	pool := newTestQueuePool(map[int]int{CompressTaskType: 5}, func(record *model.Task) {
+		started <- record.ID
+	})
+	pool.Start()
+	defer pool.Stop()
+
+	a.Equal(local.ID, <-started)
+	a.Eventually(func() bool {
+		return status(local.ID) == Complete
+	}, 5*time.Second, 10*time.Millisecond)
+	a.Len(started, 0)
+	a.Equal(Queued, status(remote.ID))
+}
+
+func TestSubmit(t *testing.T) {
+	a := assert.New(t)
+	defer setupQueueDB()()
+
+	record := &model.Task{Type: CompressTaskType}
+	_, err := record.Create()
+	a.NoError(err)
+
+	// 记录创建任务的请求 ID
+	pool := newTestQueuePool(map[int]int{}, nil)
+	Submit(util.WithRequestID(context.Background(), "req-1"), pool, &queueJob{record: record})
+	found, _ := model.GetTasksByID(record.ID)
+	a.Equal("req-1", found.RequestID)
+	a.Len(pool.wake, 1)
+}
+
+func TestCancelAndRetry(t *testing.T) {
+	a := assert.New(t)
+	defer setupQueueDB()()
+
+	record := &model.Task{Type: CompressTaskType}
+	_, err := record.Create()
+	a.NoError(err)
+
+	// 重试仅限失败或已取消的任务
+	a.ErrorIs(Retry(&QueuePool{}, record), ErrTaskNotRetryable)
+
+	a.NoError(Cancel(record))
+	a.Equal(Canceled, status(record.ID))
+	a.ErrorIs(Cancel(record), ErrTaskNotCancelable)
+
+	a.NoError(record.SetProgress(TransferringProgress))
+	a.NoError(record.SetError("error"))
+	pool := newTestQueuePool(map[int]int{}, nil)
+	a.NoError(Retry(pool, record))
+	found, _ := model.GetTasksByID(record.ID)
+	a.Equal(Queued, found.Status)
+	a.Equal(PendingProgress, found.Progress)
+	a.Empty(found.Error)
+	a.Len(pool.wake, 1)
+}
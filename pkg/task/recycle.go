@@ -14,6 +14,8 @@ type RecycleTask struct {
 	TaskModel *model.Task
 	TaskProps RecycleProps
 	Err       *JobError
+
+	jobContext
 }
 
 // RecycleProps 回收任务属性
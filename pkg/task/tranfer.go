@@ -1,7 +1,6 @@
 package task
 
 import (
-	"context"
 	"encoding/json"
 	"fmt"
 	"path"
@@ -22,6 +21,8 @@ type TransferTask struct {
 	TaskProps TransferProps
 	Err       *JobError
 
+	jobContext
+
 	zipPath string
 }
 
@@ -63,6 +64,11 @@ func (job *TransferTask) SetStatus(status int) {
 	job.TaskModel.SetStatus(status)
 }
 
+// Local 由主机中转时，待中转的文件位于本机离线下载目录中，任务只能在创建任务的节点上执行
+func (job *TransferTask) Local() bool {
+	return job.TaskProps.NodeID <= 1
+}
+
 // SetError 设定任务失败信息
 func (job *TransferTask) SetError(err *JobError) {
 	job.Err = err
@@ -116,7 +122,7 @@ func (job *TransferTask) Do() {
 
 			// 切换为从机节点处理上传
 			fs.SwitchToSlaveHandler(node)
-			err = fs.UploadFromStream(context.Background(), &fsctx.FileStream{
+			err = fs.UploadFromStream(job.Context(), &fsctx.FileStream{
 				File:        nil,
 				Size:        job.TaskProps.SrcSizes[file],
 				Name:        path.Base(dst),
@@ -125,7 +131,7 @@ func (job *TransferTask) Do() {
 			}, false)
 		} else {
 			// 主机节点中转
-			err = fs.UploadFromPath(context.Background(), file, dst, 0)
+			err = fs.UploadFromPath(job.Context(), file, dst, 0)
 		}
 
 		if err != nil {
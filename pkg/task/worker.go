@@ -1,8 +1,11 @@
 package task
 
 import (
+	"context"
 	"fmt"
+
 	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+	"github.com/cloudreve/Cloudreve/v3/pkg/webhook"
 )
 
 // Worker 处理任务的对象
@@ -16,29 +19,64 @@ type GeneralWorker struct {
 
 // Do 执行任务
 func (worker *GeneralWorker) Do(job Job) {
-	util.Log().Debug("Start executing task.")
+	// 任务日志附加任务 ID 及创建任务的请求 ID，便于与创建任务的请求关联
+	log := util.Log().With("task_type", job.Type())
+	if record := job.Model(); record != nil {
+		log = log.With("task_id", record.ID)
+		if record.RequestID != "" {
+			log = log.With(util.RequestIDKey, record.RequestID)
+		}
+	}
+
+	log.Debug("Start executing task.")
 	job.SetStatus(Processing)
 
 	defer func() {
 		// 致命错误捕获
 		if err := recover(); err != nil {
-			util.Log().Debug("Failed to execute task: %s", err)
+			log.Debug("Failed to execute task: %s", err)
 			job.SetError(&JobError{Msg: "Fatal error.", Error: fmt.Sprintf("%s", err)})
 			job.SetStatus(Error)
+			publish(job, webhook.EventTaskFailed)
 		}
 	}()
 
 	// 开始执行任务
 	job.Do()
 
+	// 任务已被取消，租约已由其他实例接管，不再更新任务状态
+	if cancelable, ok := job.(CancelableJob); ok && cancelable.Context().Err() != nil {
+		log.Warning("Task is canceled, result is discarded.")
+		return
+	}
+
 	// 任务执行失败
 	if err := job.GetError(); err != nil {
-		util.Log().Debug("Failed to execute task.")
+		log.Debug("Failed to execute task.")
 		job.SetStatus(Error)
+		publish(job, webhook.EventTaskFailed)
 		return
 	}
 
-	util.Log().Debug("Task finished.")
+	log.Debug("Task finished.")
 	// 执行完成
 	job.SetStatus(Complete)
+	publish(job, webhook.EventTaskCompleted)
+}
+
+// publish 发布任务结束事件
+func publish(job Job, name string) {
+	if !webhook.Enabled() {
+		return
+	}
+
+	data := map[string]interface{}{
+		"type":  job.Type(),
+		"error": job.GetError(),
+	}
+	if record := job.Model(); record != nil {
+		data["id"] = record.ID
+	}
+
+	webhook.Publish(context.Background(), name, job.Creator(), data)
 }
@@ -14,7 +14,7 @@ type MockJob struct {
 }
 
 func (job *MockJob) Type() int {
-	panic("implement me")
+	return CompressTaskType
 }
 
 func (job *MockJob) Creator() uint {
@@ -26,7 +26,7 @@ func (job *MockJob) Props() string {
 }
 
 func (job *MockJob) Model() *model.Task {
-	panic("implement me")
+	return &model.Task{}
 }
 
 func (job *MockJob) SetStatus(status int) {
@@ -1,10 +1,16 @@
 package util
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
-	"github.com/fatih/color"
+	"sort"
+	"strings"
 	"sync"
 	"time"
+
+	"github.com/fatih/color"
+	"github.com/gin-gonic/gin"
 )
 
 const (
@@ -18,13 +24,31 @@ const (
 	LevelDebug
 )
 
+// 日志格式
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+// RequestIDKey 请求 ID 在 Gin 上下文及日志字段中的键名
+const RequestIDKey = "request_id"
+
+type requestIDCtx struct{}
+
 var GloablLogger *Logger
 var Level = LevelDebug
 
+// LogFormat 日志输出格式，text 或 json
+var LogFormat = LogFormatText
+
+// 所有 Logger 共用输出锁，避免并发输出的日志交错
+var logMu sync.Mutex
+
 // Logger 日志
 type Logger struct {
-	level int
-	mu    sync.Mutex
+	level  int
+	json   bool
+	fields map[string]interface{}
 }
 
 // 日志颜色
@@ -45,25 +69,80 @@ var spaces = map[string]string{
 	"Debug":   "  ",
 }
 
+// With 返回附加了给定字段的 Logger
+func (ll *Logger) With(key string, value interface{}) *Logger {
+	fields := make(map[string]interface{}, len(ll.fields)+1)
+	for k, v := range ll.fields {
+		fields[k] = v
+	}
+	fields[key] = value
+
+	return &Logger{level: ll.level, json: ll.json, fields: fields}
+}
+
+// WithContext 返回附加了上下文中请求 ID 的 Logger
+func (ll *Logger) WithContext(ctx context.Context) *Logger {
+	if id := RequestID(ctx); id != "" {
+		return ll.With(RequestIDKey, id)
+	}
+	return ll
+}
+
 // Println 打印
 func (ll *Logger) Println(prefix string, msg string) {
 	// TODO Release时去掉
 	// color.NoColor = false
 
-	c := color.New()
+	logMu.Lock()
+	defer logMu.Unlock()
 
-	ll.mu.Lock()
-	defer ll.mu.Unlock()
+	if ll.json {
+		entry := make(map[string]interface{}, len(ll.fields)+3)
+		for k, v := range ll.fields {
+			entry[k] = v
+		}
+		entry["time"] = time.Now().Format(time.RFC3339)
+		entry["level"] = strings.ToLower(prefix)
+		entry["msg"] = msg
 
+		line, err := json.Marshal(entry)
+		if err != nil {
+			line, _ = json.Marshal(map[string]string{"level": "error", "msg": err.Error()})
+		}
+		fmt.Fprintln(color.Output, string(line))
+		return
+	}
+
+	c := color.New()
 	_, _ = c.Printf(
-		"%s%s %s %s\n",
+		"%s%s %s %s%s\n",
 		colors[prefix]("["+prefix+"]"),
 		spaces[prefix],
 		time.Now().Format("2006-01-02 15:04:05"),
 		msg,
+		ll.textFields(),
 	)
 }
 
+// textFields 将字段格式化为按键名排序的 key=value 形式
+func (ll *Logger) textFields() string {
+	if len(ll.fields) == 0 {
+		return ""
+	}
+
+	keys := make([]string, 0, len(ll.fields))
+	for k := range ll.fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, k := range keys {
+		fmt.Fprintf(&b, " %s=%v", k, ll.fields[k])
+	}
+	return b.String()
+}
+
 // Panic 极端错误
 func (ll *Logger) Panic(format string, v ...interface{}) {
 	if LevelError > ll.level {
@@ -134,6 +213,7 @@ func BuildLogger(level string) {
 	}
 	l := Logger{
 		level: intLevel,
+		json:  LogFormat == LogFormatJSON,
 	}
 	GloablLogger = &l
 }
@@ -143,8 +223,33 @@ func Log() *Logger {
 	if GloablLogger == nil {
 		l := Logger{
 			level: Level,
+			json:  LogFormat == LogFormatJSON,
 		}
 		GloablLogger = &l
 	}
 	return GloablLogger
 }
+
+// WithRequestID 返回携带请求 ID 的上下文
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDCtx{}, id)
+}
+
+// RequestID 从上下文中取得请求 ID，不存在时返回空字符串
+func RequestID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+
+	if c, ok := ctx.(*gin.Context); ok {
+		return c.GetString(RequestIDKey)
+	}
+
+	id, _ := ctx.Value(requestIDCtx{}).(string)
+	return id
+}
+
+// RequestContext 返回携带当前请求 ID 的后台上下文，其生命周期不受请求影响
+func RequestContext(c *gin.Context) context.Context {
+	return WithRequestID(context.Background(), c.GetString(RequestIDKey))
+}
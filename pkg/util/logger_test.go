@@ -3,8 +3,15 @@
 package util
 
 import (
-	"github.com/stretchr/testify/assert"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
 	"testing"
+
+	"github.com/fatih/color"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestBuildLogger(t *testing.T) {
@@ -101,3 +108,46 @@ func TestLogger_Panic(t *testing.T) {
 		l.Error("123")
 	})
 }
+
+func TestLogger_JSON(t *testing.T) {
+	asserts := assert.New(t)
+	output := color.Output
+	defer func() { color.Output = output }()
+	buf := &bytes.Buffer{}
+	color.Output = buf
+
+	l := &Logger{level: LevelDebug, json: true}
+	ctx := WithRequestID(context.Background(), "req-1")
+	l.WithContext(ctx).With("task_id", 2).Info("hello %s", "world")
+
+	var entry map[string]interface{}
+	asserts.NoError(json.Unmarshal(buf.Bytes(), &entry))
+	asserts.Equal("info", entry["level"])
+	asserts.Equal("hello world", entry["msg"])
+	asserts.Equal("req-1", entry[RequestIDKey])
+	asserts.EqualValues(2, entry["task_id"])
+	asserts.NotEmpty(entry["time"])
+
+	// 附加字段不影响原 Logger
+	asserts.Empty(l.fields)
+}
+
+func TestLogger_TextFields(t *testing.T) {
+	asserts := assert.New(t)
+	l := &Logger{level: LevelDebug}
+	asserts.Equal("", l.textFields())
+	asserts.Equal(" a=1 b=x", l.With("b", "x").With("a", 1).textFields())
+}
+
+func TestRequestID(t *testing.T) {
+	asserts := assert.New(t)
+
+	asserts.Equal("", RequestID(nil))
+	asserts.Equal("", RequestID(context.Background()))
+	asserts.Equal("id", RequestID(WithRequestID(context.Background(), "id")))
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Set(RequestIDKey, "gin-id")
+	asserts.Equal("gin-id", RequestID(c))
+	asserts.Equal("gin-id", RequestID(RequestContext(c)))
+}
@@ -142,12 +142,12 @@ func _checkOverwriteFile(ctx context.Context, fs *filesystem.FileSystem, src Fil
 	if src.IsDir() {
 		ok, folder := fs.IsPathExist(dst)
 		if ok {
-			return fs.Delete(ctx, []uint{folder.ID}, []uint{}, false, false)
+			return fs.Trash(ctx, []uint{folder.ID}, []uint{})
 		}
 	} else {
 		ok, file := fs.IsFileExist(dst)
 		if ok {
-			return fs.Delete(ctx, []uint{}, []uint{file.ID}, false, false)
+			return fs.Trash(ctx, []uint{}, []uint{file.ID})
 		}
 	}
 	return nil
@@ -0,0 +1,190 @@
+package webdav
+
+import (
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/gofrs/uuid"
+	"github.com/jinzhu/gorm"
+)
+
+// heldLocks 记录本机上正在被 Confirm 持有的锁
+var heldLocks = struct {
+	sync.Mutex
+	tokens map[uint]map[string]bool
+}{tokens: make(map[uint]map[string]bool)}
+
+// NewDBLS 返回基于数据库的 LockSystem，锁在重启后依然有效，并在多个主机间共享。
+// root 为 WebDAV 根目录在用户文件系统中的路径，锁的名称均相对于此目录
+func NewDBLS(uid uint, root string) LockSystem {
+	return &dbLS{uid: uid, root: slashClean(root)}
+}
+
+type dbLS struct {
+	uid  uint
+	root string
+}
+
+// abs 将相对于 WebDAV 根目录的名称转换为用户文件系统中的完整路径
+func (m *dbLS) abs(name string) string {
+	return path.Join(m.root, slashClean(name))
+}
+
+// rel 将用户文件系统中的完整路径转换为相对于 WebDAV 根目录的名称
+func (m *dbLS) rel(name string) string {
+	if m.root == "/" {
+		return name
+	}
+	return slashClean(strings.TrimPrefix(name, m.root))
+}
+
+func (m *dbLS) details(lock *model.Lock) LockDetails {
+	return LockDetails{
+		Root:      m.rel(lock.Path),
+		Duration:  time.Duration(lock.Timeout) * time.Second,
+		OwnerXML:  lock.Owner,
+		ZeroDepth: lock.ZeroDepth,
+	}
+}
+
+// find 根据 Token 查找锁，不存在时返回 nil
+func (m *dbLS) find(now time.Time, token string) (*model.Lock, error) {
+	lock, err := model.GetLockByToken(m.uid, token, now)
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, nil
+	}
+	return lock, err
+}
+
+func (m *dbLS) Confirm(now time.Time, name0, name1 string, conditions ...Condition) (func(), error) {
+	heldLocks.Lock()
+	defer heldLocks.Unlock()
+
+	var n0, n1 string
+	var err error
+	if name0 != "" {
+		if n0, err = m.lookup(now, m.abs(name0), conditions...); n0 == "" {
+			return nil, err
+		}
+	}
+	if name1 != "" {
+		if n1, err = m.lookup(now, m.abs(name1), conditions...); n1 == "" {
+			return nil, err
+		}
+	}
+
+	// Don't hold the same lock twice.
+	if n1 == n0 {
+		n1 = ""
+	}
+
+	held := heldLocks.tokens[m.uid]
+	if held == nil {
+		held = make(map[string]bool)
+		heldLocks.tokens[m.uid] = held
+	}
+	for _, token := range []string{n0, n1} {
+		if token != "" {
+			held[token] = true
+		}
+	}
+
+	return func() {
+		heldLocks.Lock()
+		defer heldLocks.Unlock()
+		for _, token := range []string{n0, n1} {
+			delete(held, token)
+		}
+		if len(held) == 0 {
+			delete(heldLocks.tokens, m.uid)
+		}
+	}, nil
+}
+
+// lookup 返回作用于 name 并满足任一条件、且未被持有的锁的 Token。
+// 找不到时返回空 Token 以及 ErrConfirmationFailed 或查询时发生的错误
+func (m *dbLS) lookup(now time.Time, name string, conditions ...Condition) (string, error) {
+	// TODO: support Condition.Not and Condition.ETag.
+	for _, c := range conditions {
+		if c.Token == "" || heldLocks.tokens[m.uid][c.Token] {
+			continue
+		}
+		lock, err := m.find(now, c.Token)
+		if err != nil {
+			return "", err
+		}
+		if lock != nil && lock.Covers(name) {
+			return lock.Token, nil
+		}
+	}
+	return "", ErrConfirmationFailed
+}
+
+// isHeld 返回锁是否正被本机上的 Confirm 持有
+func (m *dbLS) isHeld(token string) bool {
+	heldLocks.Lock()
+	defer heldLocks.Unlock()
+	return heldLocks.tokens[m.uid][token]
+}
+
+func (m *dbLS) Create(now time.Time, details LockDetails) (string, error) {
+	lock := &model.Lock{
+		Token:     "opaquelocktoken:" + uuid.Must(uuid.NewV4()).String(),
+		UserID:    m.uid,
+		Type:      model.LockTypeWebDAV,
+		Path:      m.abs(details.Root),
+		ZeroDepth: details.ZeroDepth,
+		Owner:     details.OwnerXML,
+		Timeout:   int64(details.Duration / time.Second),
+	}
+	if details.Duration < 0 {
+		lock.Timeout = infiniteTimeout
+	}
+
+	if err := lock.Create(now); err != nil {
+		if err == model.ErrLockConflict {
+			return "", ErrLocked
+		}
+		return "", err
+	}
+	return lock.Token, nil
+}
+
+func (m *dbLS) Refresh(now time.Time, token string, duration time.Duration) (LockDetails, error) {
+	lock, err := m.find(now, token)
+	if err != nil {
+		return LockDetails{}, err
+	}
+	if lock == nil {
+		return LockDetails{}, ErrNoSuchLock
+	}
+	if m.isHeld(token) {
+		return LockDetails{}, ErrLocked
+	}
+
+	timeout := int64(duration / time.Second)
+	if duration < 0 {
+		timeout = infiniteTimeout
+	}
+	if err := lock.Refresh(timeout, now); err != nil {
+		return LockDetails{}, err
+	}
+	return m.details(lock), nil
+}
+
+func (m *dbLS) Unlock(now time.Time, token string) error {
+	lock, err := m.find(now, token)
+	if err != nil {
+		return err
+	}
+	if lock == nil {
+		return ErrNoSuchLock
+	}
+	if m.isHeld(token) {
+		return ErrLocked
+	}
+	return lock.Delete()
+}
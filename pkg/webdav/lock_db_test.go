@@ -0,0 +1,73 @@
+package webdav
+
+import (
+	"testing"
+	"time"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDBLS(t *testing.T) {
+	a := assert.New(t)
+	mockDB := model.DB
+	model.DB, _ = gorm.Open("sqlite", ":memory:")
+	defer func() {
+		model.DB = mockDB
+	}()
+	model.DB.AutoMigrate(&model.User{}, &model.Lock{})
+
+	now := time.Now()
+	ls := NewDBLS(1, "/dav")
+
+	// 创建锁，锁的路径相对于 WebDAV 根目录
+	token, err := ls.Create(now, LockDetails{Root: "/a", Duration: time.Minute})
+	a.NoError(err)
+	lock, err := model.GetLockByToken(1, token, now)
+	a.NoError(err)
+	a.Equal("/dav/a", lock.Path)
+	a.EqualValues(60, lock.Timeout)
+
+	// 冲突的锁
+	_, err = ls.Create(now, LockDetails{Root: "/a/b", Duration: time.Minute})
+	a.Equal(ErrLocked, err)
+
+	// 其他用户及其他根目录下的锁互不影响
+	_, err = NewDBLS(2, "/dav").Create(now, LockDetails{Root: "/a", Duration: time.Minute})
+	a.NoError(err)
+	_, err = NewDBLS(1, "/other").Create(now, LockDetails{Root: "/a", Duration: time.Minute, ZeroDepth: true})
+	a.NoError(err)
+
+	// 需要持有锁才能操作被锁定的对象
+	_, err = ls.Confirm(now, "/a/b", "")
+	a.Equal(ErrConfirmationFailed, err)
+	_, err = ls.Confirm(now, "/c", "", Condition{Token: token})
+	a.Equal(ErrConfirmationFailed, err)
+	release, err := ls.Confirm(now, "/a/b", "", Condition{Token: token})
+	a.NoError(err)
+
+	// 锁被持有时不能重复持有、刷新或解锁
+	_, err = ls.Confirm(now, "/a", "", Condition{Token: token})
+	a.Equal(ErrConfirmationFailed, err)
+	_, err = ls.Refresh(now, token, time.Minute)
+	a.Equal(ErrLocked, err)
+	a.Equal(ErrLocked, ls.Unlock(now, token))
+	release()
+
+	// 刷新时无限期的锁按最长有效时长处理
+	details, err := ls.Refresh(now, token, -1)
+	a.NoError(err)
+	a.Equal("/a", details.Root)
+	a.Equal(time.Duration(model.MaxLockTimeout)*time.Second, details.Duration)
+	_, err = ls.Confirm(now.Add(model.MaxLockTimeout*time.Second), "/a", "", Condition{Token: token})
+	a.Equal(ErrConfirmationFailed, err)
+
+	// 解锁
+	a.NoError(ls.Unlock(now, token))
+	a.Equal(ErrNoSuchLock, ls.Unlock(now, token))
+	_, err = ls.Refresh(now, token, time.Minute)
+	a.Equal(ErrNoSuchLock, err)
+	_, err = ls.Create(now, LockDetails{Root: "/a/b", Duration: time.Minute})
+	a.NoError(err)
+}
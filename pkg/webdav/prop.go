@@ -14,47 +14,142 @@ import (
 	"net/http"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
 	model "github.com/cloudreve/Cloudreve/v3/models"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem"
 )
 
+const (
+	// deadPropMetadataPrefix 是死属性在元信息中的键名前缀，其后为 Clark 表示法的属性名
+	deadPropMetadataPrefix = "webdav_prop:"
+	// maxDeadProps 是单个文件或目录上死属性的最大数量
+	maxDeadProps = 64
+	// maxDeadPropsSize 是单个文件或目录上死属性名称与内容的最大总长度
+	maxDeadPropsSize = 64 << 10
+)
+
+// deadPropsFromMetadata 从元信息中读取所有死属性
+func deadPropsFromMetadata(metadata map[string]string) map[xml.Name]Property {
+	props := make(map[xml.Name]Property)
+	for k, v := range metadata {
+		if !strings.HasPrefix(k, deadPropMetadataPrefix) {
+			continue
+		}
+
+		name, ok := parseClarkName(strings.TrimPrefix(k, deadPropMetadataPrefix))
+		if !ok {
+			continue
+		}
+
+		props[name] = Property{XMLName: name, InnerXML: []byte(v)}
+	}
+	return props
+}
+
+// patchDeadProps 将 PROPPATCH 请求转换为元信息的修改，超出大小限制时返回 false。
+// DAV:lastmodified 不会被保存，而是作为修改时间返回
+func patchDeadProps(metadata map[string]string, proppatches []Proppatch) (set map[string]string, remove []string, modtime *time.Time, ok bool) {
+	props := deadPropsFromMetadata(metadata)
+	for _, patch := range proppatches {
+		for _, prop := range patch.Props {
+			if prop.XMLName.Space == "DAV:" && prop.XMLName.Local == "lastmodified" {
+				if patch.Remove {
+					continue
+				}
+				if modtimeUnix, err := strconv.ParseInt(string(prop.InnerXML), 10, 64); err == nil {
+					t := time.Unix(modtimeUnix, 0)
+					modtime = &t
+				}
+				continue
+			}
+
+			if patch.Remove {
+				delete(props, prop.XMLName)
+			} else {
+				props[prop.XMLName] = prop
+			}
+		}
+	}
+
+	size := 0
+	set = make(map[string]string, len(props))
+	for name, prop := range props {
+		key := deadPropMetadataPrefix + clarkName(name)
+		size += len(key) + len(prop.InnerXML)
+		set[key] = string(prop.InnerXML)
+	}
+	if len(props) > maxDeadProps || size > maxDeadPropsSize {
+		return nil, nil, nil, false
+	}
+
+	for k := range metadata {
+		if _, exist := set[k]; !exist && strings.HasPrefix(k, deadPropMetadataPrefix) {
+			remove = append(remove, k)
+		}
+	}
+	return set, remove, modtime, true
+}
+
+// patchResult 返回 PROPPATCH 中所有属性的处理结果，它们共享同一个状态
+func patchResult(proppatches []Proppatch, status int) []Propstat {
+	stat := Propstat{Status: status}
+	for _, patch := range proppatches {
+		for _, prop := range patch.Props {
+			stat.Props = append(stat.Props, Property{XMLName: prop.XMLName})
+		}
+	}
+	return []Propstat{stat}
+}
+
+// clarkName 返回属性名的 Clark 表示法，即 {namespace}local
+func clarkName(name xml.Name) string {
+	return "{" + name.Space + "}" + name.Local
+}
+
+func parseClarkName(s string) (xml.Name, bool) {
+	if !strings.HasPrefix(s, "{") {
+		return xml.Name{}, false
+	}
+	end := strings.Index(s, "}")
+	if end < 0 || end == len(s)-1 {
+		return xml.Name{}, false
+	}
+	return xml.Name{Space: s[1:end], Local: s[end+1:]}, true
+}
+
 type FileDeadProps struct {
 	*model.File
 }
 
 // 实现 webdav.DeadPropsHolder 接口，不能在models.file里面定义
 func (file *FileDeadProps) DeadProps() (map[xml.Name]Property, error) {
-	return map[xml.Name]Property{
-		xml.Name{Space: "http://owncloud.org/ns", Local: "checksums"}: {
-			XMLName: xml.Name{
-				Space: "http://owncloud.org/ns", Local: "checksums",
-			},
-			InnerXML: []byte("<checksum>" + file.MetadataSerialized[model.ChecksumMetadataKey] + "</checksum>"),
+	props := deadPropsFromMetadata(file.MetadataSerialized)
+	props[xml.Name{Space: "http://owncloud.org/ns", Local: "checksums"}] = Property{
+		XMLName: xml.Name{
+			Space: "http://owncloud.org/ns", Local: "checksums",
 		},
-	}, nil
+		InnerXML: []byte("<checksum>" + file.MetadataSerialized[model.ChecksumMetadataKey] + "</checksum>"),
+	}
+	return props, nil
 }
 
 func (file *FileDeadProps) Patch(proppatches []Proppatch) ([]Propstat, error) {
-	var (
-		stat Propstat
-		err  error
-	)
-	stat.Status = http.StatusOK
-	for _, patch := range proppatches {
-		for _, prop := range patch.Props {
-			stat.Props = append(stat.Props, Property{XMLName: prop.XMLName})
-			if prop.XMLName.Space == "DAV:" && prop.XMLName.Local == "lastmodified" {
-				var modtimeUnix int64
-				modtimeUnix, err = strconv.ParseInt(string(prop.InnerXML), 10, 64)
-				if err == nil {
-					err = model.DB.Model(file.File).UpdateColumn("updated_at", time.Unix(modtimeUnix, 0)).Error
-				}
-			}
+	set, remove, modtime, ok := patchDeadProps(file.MetadataSerialized, proppatches)
+	if !ok {
+		return patchResult(proppatches, http.StatusInsufficientStorage), nil
+	}
+
+	if err := file.PatchMetadata(set, remove); err != nil {
+		return nil, err
+	}
+	if modtime != nil {
+		if err := model.DB.Model(file.File).UpdateColumn("updated_at", *modtime).Error; err != nil {
+			return nil, err
 		}
 	}
-	return []Propstat{stat}, err
+	return patchResult(proppatches, http.StatusOK), nil
 }
 
 type FolderDeadProps struct {
@@ -62,28 +157,35 @@ type FolderDeadProps struct {
 }
 
 func (folder *FolderDeadProps) DeadProps() (map[xml.Name]Property, error) {
-	return nil, nil
+	return deadPropsFromMetadata(folder.MetadataSerialized), nil
 }
 
 func (folder *FolderDeadProps) Patch(proppatches []Proppatch) ([]Propstat, error) {
-	var (
-		stat Propstat
-		err  error
-	)
-	stat.Status = http.StatusOK
-	for _, patch := range proppatches {
-		for _, prop := range patch.Props {
-			stat.Props = append(stat.Props, Property{XMLName: prop.XMLName})
-			if prop.XMLName.Space == "DAV:" && prop.XMLName.Local == "lastmodified" {
-				var modtimeUnix int64
-				modtimeUnix, err = strconv.ParseInt(string(prop.InnerXML), 10, 64)
-				if err == nil {
-					err = model.DB.Model(folder.Folder).UpdateColumn("updated_at", time.Unix(modtimeUnix, 0)).Error
-				}
-			}
+	set, remove, modtime, ok := patchDeadProps(folder.MetadataSerialized, proppatches)
+	if !ok {
+		return patchResult(proppatches, http.StatusInsufficientStorage), nil
+	}
+
+	if err := folder.PatchMetadata(set, remove); err != nil {
+		return nil, err
+	}
+	if modtime != nil {
+		if err := model.DB.Model(folder.Folder).UpdateColumn("updated_at", *modtime).Error; err != nil {
+			return nil, err
 		}
 	}
-	return []Propstat{stat}, err
+	return patchResult(proppatches, http.StatusOK), nil
+}
+
+// deadPropsHolder 返回文件或目录对应的 DeadPropsHolder
+func deadPropsHolder(fi FileInfo) DeadPropsHolder {
+	switch info := fi.(type) {
+	case *model.File:
+		return &FileDeadProps{info}
+	case *model.Folder:
+		return &FolderDeadProps{info}
+	}
+	return nil
 }
 
 type FileInfo interface {
@@ -244,12 +346,8 @@ var liveProps = map[xml.Name]struct {
 // of one Propstat element.
 func props(ctx context.Context, fs *filesystem.FileSystem, ls LockSystem, fi FileInfo, pnames []xml.Name) ([]Propstat, error) {
 	isDir := fi.IsDir()
-	if !isDir {
-		fi = &FileDeadProps{fi.(*model.File)}
-	}
-
 	var deadProps map[xml.Name]Property
-	if dph, ok := fi.(DeadPropsHolder); ok {
+	if dph := deadPropsHolder(fi); dph != nil {
 		var err error
 		deadProps, err = dph.DeadProps()
 		if err != nil {
@@ -287,12 +385,8 @@ func props(ctx context.Context, fs *filesystem.FileSystem, ls LockSystem, fi Fil
 // Propnames returns the property names defined for resource name.
 func propnames(ctx context.Context, fs *filesystem.FileSystem, ls LockSystem, fi FileInfo) ([]xml.Name, error) {
 	isDir := fi.IsDir()
-	if !isDir {
-		fi = &FileDeadProps{fi.(*model.File)}
-	}
-
 	var deadProps map[xml.Name]Property
-	if dph, ok := fi.(DeadPropsHolder); ok {
+	if dph := deadPropsHolder(fi); dph != nil {
 		var err error
 		deadProps, err = dph.DeadProps()
 		if err != nil {
@@ -374,13 +468,7 @@ loop:
 	// very unlikely to be false
 	exist, info := isPathExist(ctx, fs, name)
 	if exist {
-		var dph DeadPropsHolder
-		if info.IsDir() {
-			dph = &FolderDeadProps{info.(*model.Folder)}
-		} else {
-			dph = &FileDeadProps{info.(*model.File)}
-		}
-		ret, err := dph.Patch(patches)
+		ret, err := deadPropsHolder(info).Patch(patches)
 		if err != nil {
 			return nil, err
 		}
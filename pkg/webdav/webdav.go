@@ -15,24 +15,24 @@ import (
 	"path"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	model "github.com/cloudreve/Cloudreve/v3/models"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/metrics"
 	"github.com/cloudreve/Cloudreve/v3/pkg/util"
 )
 
 type Handler struct {
 	// Prefix is the URL path prefix to strip from WebDAV resource paths.
 	Prefix string
-	// LockSystem is the lock management system.
-	LockSystem map[uint]LockSystem
+	// LockSystem returns the lock management system of the given user, lock
+	// names are resolved relative to root.
+	LockSystem func(uid uint, root string) LockSystem
 	// Logger is an optional error logger. If non-nil, it will be called
 	// for all HTTP requests.
 	Logger func(*http.Request, error)
-	Mutex  *sync.Mutex
 }
 
 func (h *Handler) stripPrefix(p string, uid uint) (string, int, error) {
@@ -63,19 +63,10 @@ func isPathExist(ctx context.Context, fs *filesystem.FileSystem, path string) (b
 
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request, fs *filesystem.FileSystem) {
 	status, err := http.StatusBadRequest, errUnsupportedMethod
-	h.Mutex.Lock()
 	if h.LockSystem == nil {
-		h.Mutex.Unlock()
 		status, err = http.StatusInternalServerError, errNoLockSystem
 	} else {
-		// 检查并新建 LockSystem
-		ls, ok := h.LockSystem[fs.User.ID]
-		if !ok {
-			h.LockSystem[fs.User.ID] = NewMemLS()
-			ls = h.LockSystem[fs.User.ID]
-		}
-		h.Mutex.Unlock()
-
+		ls := h.lockSystem(r, fs)
 		switch r.Method {
 		case "OPTIONS":
 			status, err = h.handleOptions(w, r, fs)
@@ -111,112 +102,109 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request, fs *filesyst
 	}
 }
 
-// OK
-func (h *Handler) lock(now time.Time, root string, fs *filesystem.FileSystem, ls LockSystem) (token string, status int, err error) {
-	//token, err = ls.Create(now, LockDetails{
-	//	Root:      root,
-	//	Duration:  infiniteTimeout,
-	//	ZeroDepth: true,
-	//})
-	//if err != nil {
-	//	if err == ErrLocked {
-	//		return "", StatusLocked, err
-	//	}
-	//	return "", http.StatusInternalServerError, err
-	//}
+// lockSystem 返回当前用户的 LockSystem，锁的名称相对于 WebDAV 应用的根目录
+func (h *Handler) lockSystem(r *http.Request, fs *filesystem.FileSystem) LockSystem {
+	root := "/"
+	if application, ok := r.Context().Value(fsctx.WebDAVCtx).(*model.Webdav); ok && fs.Root != nil {
+		root = application.Root
+	}
+	return h.LockSystem(fs.User.ID, root)
+}
 
-	return fmt.Sprintf("%d", time.Now().Unix()), 0, nil
+// temporaryLockTimeout 是请求期间临时锁的有效期，避免主机异常退出后临时锁一直存在
+const temporaryLockTimeout = time.Hour
+
+// OK
+func (h *Handler) lock(now time.Time, root string, ls LockSystem) (token string, status int, err error) {
+	token, err = ls.Create(now, LockDetails{
+		Root:      root,
+		Duration:  temporaryLockTimeout,
+		ZeroDepth: true,
+	})
+	if err != nil {
+		if err == ErrLocked {
+			return "", StatusLocked, err
+		}
+		return "", http.StatusInternalServerError, err
+	}
+	return token, 0, nil
 }
 
 // ok
 func (h *Handler) confirmLocks(r *http.Request, src, dst string, fs *filesystem.FileSystem) (release func(), status int, err error) {
+	hdr := r.Header.Get("If")
+	ls := h.lockSystem(r, fs)
+	if hdr == "" {
+		// An empty If header means that the client hasn't previously created locks.
+		// Even if this client doesn't care about locks, we still need to check that
+		// the resources aren't locked by another client, so we create temporary
+		// locks that would conflict with another client's locks. These temporary
+		// locks are unlocked at the end of the HTTP request.
+		now, srcToken, dstToken := time.Now(), "", ""
+		if src != "" {
+			srcToken, status, err = h.lock(now, src, ls)
+			if err != nil {
+				return nil, status, err
+			}
+		}
+		if dst != "" {
+			dstToken, status, err = h.lock(now, dst, ls)
+			if err != nil {
+				if srcToken != "" {
+					ls.Unlock(now, srcToken)
+				}
+				return nil, status, err
+			}
+		}
 
-	//hdr := r.Header.Get("If")
-	//h.Mutex.Lock()
-	//ls,ok := h.LockSystem[fs.User.ID]
-	//h.Mutex.Unlock()
-	//if !ok{
-	//	return nil, http.StatusInternalServerError, errNoLockSystem
-	//}
-	//
-	//if hdr == "" {
-	//	// An empty If header means that the client hasn't previously created locks.
-	//	// Even if this client doesn't care about locks, we still need to check that
-	//	// the resources aren't locked by another client, so we create temporary
-	//	// locks that would conflict with another client's locks. These temporary
-	//	// locks are unlocked at the end of the HTTP request.
-	//	now, srcToken, dstToken := time.Now(), "", ""
-	//	if src != "" {
-	//		srcToken, status, err = h.lock(now, src, fs,ls)
-	//		if err != nil {
-	//			return nil, status, err
-	//		}
-	//	}
-	//	if dst != "" {
-	//		dstToken, status, err = h.lock(now, dst, fs,ls)
-	//		if err != nil {
-	//			if srcToken != "" {
-	//				ls.Unlock(now, srcToken)
-	//			}
-	//			return nil, status, err
-	//		}
-	//	}
-	//
-	//	return func() {
-	//		if dstToken != "" {
-	//			ls.Unlock(now, dstToken)
-	//		}
-	//		if srcToken != "" {
-	//			ls.Unlock(now, srcToken)
-	//		}
-	//	}, 0, nil
-	//}
-	//
-	//ih, ok := parseIfHeader(hdr)
-	//if !ok {
-	//	return nil, http.StatusBadRequest, errInvalidIfHeader
-	//}
-	//// ih is a disjunction (OR) of ifLists, so any ifList will do.
-	//for _, l := range ih.lists {
-	//	lsrc := l.resourceTag
-	//	if lsrc == "" {
-	//		lsrc = src
-	//	} else {
-	//		u, err := url.Parse(lsrc)
-	//		if err != nil {
-	//			continue
-	//		}
-	//		//if u.Host != r.Host {
-	//		//	continue
-	//		//}
-	//		lsrc, status, err = h.stripPrefix(u.Path, fs.User.ID)
-	//		if err != nil {
-	//			return nil, status, err
-	//		}
-	//	}
-	//	release, err = ls.Confirm(
-	//		time.Now(),
-	//		lsrc,
-	//		dst,
-	//		l.conditions...,
-	//	)
-	//	if err == ErrConfirmationFailed {
-	//		continue
-	//	}
-	//	if err != nil {
-	//		return nil, http.StatusInternalServerError, err
-	//	}
-	//	return release, 0, nil
-	//}
-	//// Section 10.4.1 says that "If this header is evaluated and all state lists
-	//// fail, then the request must fail with a 412 (Precondition Failed) status."
-	//// We follow the spec even though the cond_put_corrupt_token test case from
-	//// the litmus test warns on seeing a 412 instead of a 423 (Locked).
-	//return nil, http.StatusPreconditionFailed, ErrLocked
-
-	return func() {
+		return func() {
+			if dstToken != "" {
+				ls.Unlock(now, dstToken)
+			}
+			if srcToken != "" {
+				ls.Unlock(now, srcToken)
+			}
+		}, 0, nil
+	}
 
-	}, 0, nil
+	ih, ok := parseIfHeader(hdr)
+	if !ok {
+		return nil, http.StatusBadRequest, errInvalidIfHeader
+	}
+	// ih is a disjunction (OR) of ifLists, so any ifList will do.
+	for _, l := range ih.lists {
+		lsrc := l.resourceTag
+		if lsrc == "" {
+			lsrc = src
+		} else {
+			u, err := url.Parse(lsrc)
+			if err != nil {
+				continue
+			}
+			lsrc, status, err = h.stripPrefix(u.Path, fs.User.ID)
+			if err != nil {
+				return nil, status, err
+			}
+		}
+		release, err = ls.Confirm(
+			time.Now(),
+			lsrc,
+			dst,
+			l.conditions...,
+		)
+		if err == ErrConfirmationFailed {
+			continue
+		}
+		if err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+		return release, 0, nil
+	}
+	// Section 10.4.1 says that "If this header is evaluated and all state lists
+	// fail, then the request must fail with a 412 (Precondition Failed) status."
+	// We follow the spec even though the cond_put_corrupt_token test case from
+	// the litmus test warns on seeing a 412 instead of a 423 (Locked).
+	return nil, http.StatusPreconditionFailed, ErrLocked
 }
 
 // OK
@@ -293,7 +281,7 @@ func (h *Handler) handleGetHeadPost(w http.ResponseWriter, r *http.Request, fs *
 	if !rs.Redirect {
 		defer rs.Content.Close()
 		// 获取文件内容
-		http.ServeContent(w, r, reqPath, fs.FileTarget[0].UpdatedAt, rs.Content)
+		http.ServeContent(metrics.ServedWriter(w, fs.PolicyType()), r, reqPath, fs.FileTarget[0].UpdatedAt, rs.Content)
 		return 0, nil
 	}
 
@@ -337,7 +325,7 @@ func (h *Handler) handleDelete(w http.ResponseWriter, r *http.Request, fs *files
 
 	// 尝试作为文件删除
 	if ok, file := fs.IsFileExist(reqPath); ok {
-		if err := fs.Delete(ctx, []uint{}, []uint{file.ID}, false, false); err != nil {
+		if err := fs.Trash(ctx, []uint{}, []uint{file.ID}); err != nil {
 			return http.StatusMethodNotAllowed, err
 		}
 		return http.StatusNoContent, nil
@@ -345,7 +333,7 @@ func (h *Handler) handleDelete(w http.ResponseWriter, r *http.Request, fs *files
 
 	// 尝试作为目录删除
 	if ok, folder := fs.IsPathExist(reqPath); ok {
-		if err := fs.Delete(ctx, []uint{folder.ID}, []uint{}, false, false); err != nil {
+		if err := fs.Trash(ctx, []uint{folder.ID}, []uint{}); err != nil {
 			return http.StatusMethodNotAllowed, err
 		}
 		return http.StatusNoContent, nil
@@ -367,7 +355,7 @@ func (h *Handler) handlePut(w http.ResponseWriter, r *http.Request, fs *filesyst
 	defer release()
 	// TODO(rost): Support the If-Match, If-None-Match headers? See bradfitz'
 	// comments in http.checkEtag.
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(util.WithRequestID(context.Background(), util.RequestID(r.Context())))
 	defer cancel()
 	ctx = context.WithValue(ctx, fsctx.HTTPCtx, r.Context())
 	ctx = context.WithValue(ctx, fsctx.CancelFuncCtx, cancel)
@@ -388,31 +376,40 @@ func (h *Handler) handlePut(w http.ResponseWriter, r *http.Request, fs *filesyst
 
 	// 判断文件是否已存在
 	exist, originFile := fs.IsFileExist(reqPath)
+	if exist && !allowOverwrite(r) {
+		return http.StatusForbidden, errOverwriteForbidden
+	}
+
 	if exist {
 		// 已存在，为更新操作
 
-		// 检查此文件是否有软链接
-		fileList, err := model.RemoveFilesWithSoftLinks([]model.File{*originFile})
-		if err == nil && len(fileList) == 0 {
-			// 如果包含软连接，应重新生成新文件副本，并更新source_name
-			originFile.SourceName = fs.GenerateSavePath(ctx, &fileData)
-			fileData.Mode &= ^fsctx.Overwrite
-			fs.Use("AfterUpload", filesystem.HookUpdateSourceName)
-			fs.Use("AfterUploadCanceled", filesystem.HookUpdateSourceName)
-			fs.Use("AfterValidateFailed", filesystem.HookUpdateSourceName)
-		}
+		if fs.UseVersionHooks(ctx, originFile, &fileData) {
+			// 保留历史版本，新内容写入新的源文件，原有内容被其他文件共用时同样保留
+			fs.Use("AfterUploadCanceled", filesystem.HookCancelContext)
+		} else {
+			// 检查此文件是否有软链接
+			fileList, err := model.RemoveFilesWithSoftLinks([]model.File{*originFile})
+			if err == nil && len(fileList) == 0 {
+				// 如果包含软连接，应重新生成新文件副本，并更新source_name
+				originFile.SourceName = fs.GenerateSavePath(ctx, &fileData)
+				fileData.Mode &= ^fsctx.Overwrite
+				fs.Use("AfterUpload", filesystem.HookUpdateSourceName)
+				fs.Use("AfterUploadCanceled", filesystem.HookUpdateSourceName)
+				fs.Use("AfterValidateFailed", filesystem.HookUpdateSourceName)
+			}
 
-		fs.Use("BeforeUpload", filesystem.HookResetPolicy)
-		fs.Use("BeforeUpload", filesystem.HookValidateFile)
-		fs.Use("BeforeUpload", filesystem.HookValidateCapacityDiff)
-		fs.Use("AfterUploadCanceled", filesystem.HookCleanFileContent)
-		fs.Use("AfterUploadCanceled", filesystem.HookClearFileSize)
-		fs.Use("AfterUploadCanceled", filesystem.HookCancelContext)
-		fs.Use("AfterUpload", filesystem.GenericAfterUpdate)
-		fs.Use("AfterValidateFailed", filesystem.HookCleanFileContent)
-		fs.Use("AfterValidateFailed", filesystem.HookClearFileSize)
+			fs.Use("BeforeUpload", filesystem.HookResetPolicy)
+			fs.Use("BeforeUpload", filesystem.HookValidateFile)
+			fs.Use("BeforeUpload", filesystem.HookValidateCapacityDiff)
+			fs.Use("AfterUploadCanceled", filesystem.HookCleanFileContent)
+			fs.Use("AfterUploadCanceled", filesystem.HookClearFileSize)
+			fs.Use("AfterUploadCanceled", filesystem.HookCancelContext)
+			fs.Use("AfterUpload", filesystem.GenericAfterUpdate)
+			fs.Use("AfterValidateFailed", filesystem.HookCleanFileContent)
+			fs.Use("AfterValidateFailed", filesystem.HookClearFileSize)
+			fileData.Mode |= fsctx.Overwrite
+		}
 		ctx = context.WithValue(ctx, fsctx.FileModelCtx, *originFile)
-		fileData.Mode |= fsctx.Overwrite
 	} else {
 		// 给文件系统分配钩子
 		fs.Use("BeforeUpload", filesystem.HookValidateFile)
@@ -466,6 +463,13 @@ func (h *Handler) handleMkcol(w http.ResponseWriter, r *http.Request, fs *filesy
 	return http.StatusCreated, nil
 }
 
+// allowOverwrite 请求是否可以覆盖已存在的文件，WebDAV 应用禁止删除或仅允许上传时，
+// 覆盖同样会丢失原有数据，因此不允许覆盖
+func allowOverwrite(r *http.Request) bool {
+	application, ok := r.Context().Value(fsctx.WebDAVCtx).(*model.Webdav)
+	return !ok || (!application.NoDelete && !application.UploadOnly)
+}
+
 // OK
 func (h *Handler) handleCopyMove(w http.ResponseWriter, r *http.Request, fs *filesystem.FileSystem) (status int, err error) {
 	defer fs.Recycle()
@@ -507,6 +511,13 @@ func (h *Handler) handleCopyMove(w http.ResponseWriter, r *http.Request, fs *fil
 		return http.StatusNotFound, nil
 	}
 
+	// 不允许覆盖时，拒绝写入已存在的目标
+	if !allowOverwrite(r) {
+		if exist, _ := isPathExist(ctx, fs, dst); exist {
+			return http.StatusForbidden, errOverwriteForbidden
+		}
+	}
+
 	if r.Method == "COPY" {
 		// Section 7.5.1 says that a COPY only needs to lock the destination,
 		// not both destination and source. Strictly speaking, this is racy,
@@ -579,125 +590,114 @@ func (h *Handler) handleLock(w http.ResponseWriter, r *http.Request, fs *filesys
 		return http.StatusBadRequest, err
 	}
 
-	reqPath, status, err := h.stripPrefix(r.URL.Path, fs.User.ID)
+	// 锁的有效时长不超过 model.MaxLockTimeout，客户端请求无限期的锁时同样按最长时长处理
+	if maxDuration := time.Duration(model.MaxLockTimeout) * time.Second; duration < 0 || duration > maxDuration {
+		duration = maxDuration
+	}
+
+	li, status, err := readLockInfo(r.Body)
 	if err != nil {
 		return status, err
 	}
 
-	////ctx := r.Context()
-	//token, ld, now, created := "", LockDetails{}, time.Now(), false
-	//if li == (lockInfo{}) {
-	//	// An empty lockInfo means to refresh the lock.
-	//	ih, ok := parseIfHeader(r.Header.Get("If"))
-	//	if !ok {
-	//		return http.StatusBadRequest, errInvalidIfHeader
-	//	}
-	//	if len(ih.lists) == 1 && len(ih.lists[0].conditions) == 1 {
-	//		token = ih.lists[0].conditions[0].Token
-	//	}
-	//	if token == "" {
-	//		return http.StatusBadRequest, errInvalidLockToken
-	//	}
-	//	ld, err = ls.Refresh(now, token, duration)
-	//	if err != nil {
-	//		if err == ErrNoSuchLock {
-	//			return http.StatusPreconditionFailed, err
-	//		}
-	//		return http.StatusInternalServerError, err
-	//	}
-	//
-	//} else {
-	//	// Section 9.10.3 says that "If no Depth header is submitted on a LOCK request,
-	//	// then the request MUST act as if a "Depth:infinity" had been submitted."
-	//	depth := infiniteDepth
-	//	if hdr := r.Header.Get("Depth"); hdr != "" {
-	//		depth = parseDepth(hdr)
-	//		if depth != 0 && depth != infiniteDepth {
-	//			// Section 9.10.3 says that "Values other than 0 or infinity must not be
-	//			// used with the Depth header on a LOCK method".
-	//			return http.StatusBadRequest, errInvalidDepth
-	//		}
-	//	}
-	//	reqPath, status, err := h.stripPrefix(r.URL.Path, fs.User.ID)
-	//	if err != nil {
-	//		return status, err
-	//	}
-	//	ld = LockDetails{
-	//		Root:      reqPath,
-	//		Duration:  duration,
-	//		OwnerXML:  li.Owner.InnerXML,
-	//		ZeroDepth: depth == 0,
-	//	}
-	//	token, err = ls.Create(now, ld)
-	//	if err != nil {
-	//		if err == ErrLocked {
-	//			return StatusLocked, err
-	//		}
-	//		return http.StatusInternalServerError, err
-	//	}
-	//	defer func() {
-	//		if retErr != nil {
-	//			ls.Unlock(now, token)
-	//		}
-	//	}()
-	//
-	//	// Create the resource if it didn't previously exist.
-	//	//if _, err := h.FileSystem.Stat(ctx, reqPath); err != nil {
-	//	//	f, err := h.FileSystem.OpenFile(ctx, reqPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
-	//	//	if err != nil {
-	//	//		// TODO: detect missing intermediate dirs and return http.StatusConflict?
-	//	//		return http.StatusInternalServerError, err
-	//	//	}
-	//	//	f.Close()
-	//	//	created = true
-	//	//}
-	//
-	//	// http://www.webdav.org/specs/rfc4918.html#HEADER_Lock-Token says that the
-	//	// Lock-Token value is a Coded-URL. We add angle brackets.
-	//	w.Header().Set("Lock-Token", "<"+token+">")
-	//}
-	//
-	//w.Header().Set("Content-Type", "application/xml; charset=utf-8")
-	//if created {
-	//	// This is "w.WriteHeader(http.StatusCreated)" and not "return
-	//	// http.StatusCreated, nil" because we write our own (XML) response to w
-	//	// and Handler.ServeHTTP would otherwise write "Created".
-	//	w.WriteHeader(http.StatusCreated)
-	//}
+	token, ld, now := "", LockDetails{}, time.Now()
+	if li == (lockInfo{}) {
+		// An empty lockInfo means to refresh the lock.
+		ih, ok := parseIfHeader(r.Header.Get("If"))
+		if !ok {
+			return http.StatusBadRequest, errInvalidIfHeader
+		}
+		if len(ih.lists) == 1 && len(ih.lists[0].conditions) == 1 {
+			token = ih.lists[0].conditions[0].Token
+		}
+		if token == "" {
+			return http.StatusBadRequest, errInvalidLockToken
+		}
+		ld, err = ls.Refresh(now, token, duration)
+		if err != nil {
+			if err == ErrNoSuchLock {
+				return http.StatusPreconditionFailed, err
+			}
+			if err == ErrLocked {
+				return StatusLocked, err
+			}
+			return http.StatusInternalServerError, err
+		}
 
-	writeLockInfo(w, fmt.Sprintf("%d", time.Now().UnixNano()), LockDetails{
-		Duration: duration,
-		OwnerXML: fs.User.Email,
-		Root:     reqPath,
-	})
+	} else {
+		// Section 9.10.3 says that "If no Depth header is submitted on a LOCK request,
+		// then the request MUST act as if a "Depth:infinity" had been submitted."
+		depth := infiniteDepth
+		if hdr := r.Header.Get("Depth"); hdr != "" {
+			depth = parseDepth(hdr)
+			if depth != 0 && depth != infiniteDepth {
+				// Section 9.10.3 says that "Values other than 0 or infinity must not be
+				// used with the Depth header on a LOCK method".
+				return http.StatusBadRequest, errInvalidDepth
+			}
+		}
+		reqPath, status, err := h.stripPrefix(r.URL.Path, fs.User.ID)
+		if err != nil {
+			return status, err
+		}
+		ld = LockDetails{
+			Root:      reqPath,
+			Duration:  duration,
+			OwnerXML:  li.Owner.InnerXML,
+			ZeroDepth: depth == 0,
+		}
+		token, err = ls.Create(now, ld)
+		if err != nil {
+			if err == ErrLocked {
+				return StatusLocked, err
+			}
+			return http.StatusInternalServerError, err
+		}
+		defer func() {
+			if retErr != nil {
+				ls.Unlock(now, token)
+			}
+		}()
+
+		// Unlike x/net/webdav, the resource is not created here. A lock on an
+		// unmapped URL reserves the name until the client PUTs its content.
+
+		// http://www.webdav.org/specs/rfc4918.html#HEADER_Lock-Token says that the
+		// Lock-Token value is a Coded-URL. We add angle brackets.
+		w.Header().Set("Lock-Token", "<"+token+">")
+	}
+
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	if _, err = writeLockInfo(w, token, ld); err != nil {
+		return http.StatusInternalServerError, err
+	}
 	return 0, nil
 }
 
 // OK
 func (h *Handler) handleUnlock(w http.ResponseWriter, r *http.Request, fs *filesystem.FileSystem, ls LockSystem) (status int, err error) {
 	defer fs.Recycle()
-	return http.StatusNoContent, err
 
-	//// http://www.webdav.org/specs/rfc4918.html#HEADER_Lock-Token says that the
-	//// Lock-Token value is a Coded-URL. We strip its angle brackets.
-	//t := r.Header.Get("Lock-Token")
-	//if len(t) < 2 || t[0] != '<' || t[len(t)-1] != '>' {
-	//	return http.StatusBadRequest, errInvalidLockToken
-	//}
-	//t = t[1 : len(t)-1]
-	//
-	//switch err = ls.Unlock(time.Now(), t); err {
-	//case nil:
-	//	return http.StatusNoContent, err
-	//case ErrForbidden:
-	//	return http.StatusForbidden, err
-	//case ErrLocked:
-	//	return StatusLocked, err
-	//case ErrNoSuchLock:
-	//	return http.StatusConflict, err
-	//default:
-	//	return http.StatusInternalServerError, err
-	//}
+	// http://www.webdav.org/specs/rfc4918.html#HEADER_Lock-Token says that the
+	// Lock-Token value is a Coded-URL. We strip its angle brackets.
+	t := r.Header.Get("Lock-Token")
+	if len(t) < 2 || t[0] != '<' || t[len(t)-1] != '>' {
+		return http.StatusBadRequest, errInvalidLockToken
+	}
+	t = t[1 : len(t)-1]
+
+	switch err = ls.Unlock(time.Now(), t); err {
+	case nil:
+		return http.StatusNoContent, err
+	case ErrForbidden:
+		return http.StatusForbidden, err
+	case ErrLocked:
+		return StatusLocked, err
+	case ErrNoSuchLock:
+		return http.StatusConflict, err
+	default:
+		return http.StatusInternalServerError, err
+	}
 }
 
 // OK
@@ -895,6 +895,7 @@ var (
 	errNoFileSystem            = errors.New("webdav: no file system")
 	errNoLockSystem            = errors.New("webdav: no lock system")
 	errNotADirectory           = errors.New("webdav: not a directory")
+	errOverwriteForbidden      = errors.New("webdav: overwriting existing file is forbidden")
 	errPrefixMismatch          = errors.New("webdav: prefix mismatch")
 	errRecursionTooDeep        = errors.New("webdav: recursion too deep")
 	errUnsupportedLockInfo     = errors.New("webdav: unsupported lock info")
@@ -0,0 +1,412 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/auth"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/chunk/backoff"
+	"github.com/cloudreve/Cloudreve/v3/pkg/hashid"
+	"github.com/cloudreve/Cloudreve/v3/pkg/request"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+	"github.com/gofrs/uuid"
+)
+
+// 事件类型
+const (
+	EventFileUploaded     = "file.uploaded"
+	EventFileOverwritten  = "file.overwritten"
+	EventFileDeleted      = "file.deleted"
+	EventFileMoved        = "file.moved"
+	EventShareCreated     = "share.created"
+	EventShareDownloaded  = "share.downloaded"
+	EventShareUploaded    = "share.uploaded"
+	EventShareAttacked    = "share.attacked"
+	EventTaskCompleted    = "task.completed"
+	EventTaskFailed       = "task.failed"
+	EventDownloadFinished = "download.finished"
+)
+
+// Events 所有可订阅的事件
+var Events = []string{
+	EventFileUploaded, EventFileOverwritten, EventFileDeleted, EventFileMoved,
+	EventShareCreated, EventShareDownloaded, EventShareUploaded, EventShareAttacked,
+	EventTaskCompleted, EventTaskFailed, EventDownloadFinished,
+}
+
+// 推送请求携带的 Header
+const (
+	// EventHeader 事件类型
+	EventHeader = "X-Cr-Webhook-Event"
+	// DeliveryHeader 推送记录ID
+	DeliveryHeader = "X-Cr-Webhook-Delivery"
+	// SignatureHeader 请求正文的签名，格式与 auth.HMACAuth 相同，即
+	// base64url(HMAC-SHA256(secret, body + ":" + expires)) + ":" + expires
+	SignatureHeader = "X-Cr-Webhook-Signature"
+)
+
+const (
+	// queueSize 待处理事件及待推送记录队列的长度
+	queueSize = 1024
+	// workerNum 并发推送的数量
+	workerNum = 4
+	// signatureTTL 签名有效期（秒）
+	signatureTTL = 300
+	// maxResponseSize 推送记录中保存的响应正文最大长度
+	maxResponseSize = 2048
+	// sweepInterval 扫描到期待推送记录的间隔
+	sweepInterval = 10 * time.Second
+	// sweepLimit 每次扫描加入推送队列的最大数量
+	sweepLimit = 1000
+)
+
+// ErrPrivateAddress 用户级订阅的推送地址指向内网
+var ErrPrivateAddress = errors.New("webhook destination is a private or loopback address")
+
+// Payload 推送的消息体
+type Payload struct {
+	ID    string      `json:"id"` // 事件ID，重放时保持不变，可用于去重
+	Event string      `json:"event"`
+	Time  time.Time   `json:"time"`
+	User  string      `json:"user,omitempty"` // 触发事件的用户的 HashID
+	Data  interface{} `json:"data"`
+}
+
+// event 待分发的事件
+type event struct {
+	userID  uint
+	payload Payload
+}
+
+var (
+	enabled    int32
+	started    int32
+	events     = make(chan event, queueSize)
+	deliveries = make(chan uint, queueSize)
+	flushes    = make(chan chan struct{})
+	start      sync.Once
+
+	// queued 已在推送队列中的推送记录，避免扫描时重复加入
+	queued sync.Map
+
+	client = request.NewClient()
+
+	// publicTransport 拒绝连接内网地址的 Transport，用于用户级订阅的推送
+	publicTransport http.RoundTripper = &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+			Control:   denyPrivateAddress,
+		}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}
+)
+
+// Init 根据设置启用或停用事件推送，并启动后台分发及推送
+func Init() {
+	if model.IsTrueVal(model.GetSettingByName("webhook_enabled")) {
+		atomic.StoreInt32(&enabled, 1)
+	} else {
+		atomic.StoreInt32(&enabled, 0)
+	}
+
+	start.Do(func() {
+		atomic.StoreInt32(&started, 1)
+		go dispatch()
+		for i := 0; i < workerNum; i++ {
+			go work()
+		}
+		go sweep()
+	})
+}
+
+// Enabled 事件推送是否已启用
+func Enabled() bool {
+	return atomic.LoadInt32(&enabled) == 1
+}
+
+// Publish 异步发布用户事件，事件会推送至该用户及站点级的订阅。uid 为触发事件的用户，
+// data 为事件的详细信息
+func Publish(ctx context.Context, name string, uid uint, data interface{}) {
+	if !Enabled() {
+		return
+	}
+
+	payload := Payload{
+		ID:    uuid.Must(uuid.NewV4()).String(),
+		Event: name,
+		Time:  time.Now(),
+		Data:  data,
+	}
+	if uid != 0 {
+		payload.User = hashid.HashID(uid, hashid.UserID)
+	}
+
+	select {
+	case events <- event{userID: uid, payload: payload}:
+	default:
+		util.Log().WithContext(ctx).Warning("Webhook event queue is full, event %q is dropped.", name)
+	}
+}
+
+// Flush 等待已发布的事件全部生成推送记录，未完成的推送由后台扫描恢复
+func Flush() {
+	if atomic.LoadInt32(&started) == 0 {
+		return
+	}
+
+	done := make(chan struct{})
+	flushes <- done
+	<-done
+}
+
+// Replay 以原消息体重新推送给定的推送记录，返回新的推送记录
+func Replay(src *model.WebhookDelivery) (*model.WebhookDelivery, error) {
+	delivery := &model.WebhookDelivery{
+		WebhookID: src.WebhookID,
+		Event:     src.Event,
+		Payload:   src.Payload,
+		Status:    model.WebhookDeliveryPending,
+		ReplayOf:  src.ID,
+	}
+	if err := delivery.Create(); err != nil {
+		return nil, err
+	}
+
+	enqueue(delivery.ID)
+	return delivery, nil
+}
+
+// dispatch 在后台为事件查找订阅并生成推送记录
+func dispatch() {
+	for {
+		select {
+		case e := <-events:
+			record(e)
+		case done := <-flushes:
+			for drained := false; !drained; {
+				select {
+				case e := <-events:
+					record(e)
+				default:
+					drained = true
+				}
+			}
+			close(done)
+		}
+	}
+}
+
+// record 为订阅了事件的订阅生成推送记录并加入推送队列
+func record(e event) {
+	hooks, err := model.GetWebhooksByEvent(e.userID, e.payload.Event)
+	if err != nil {
+		util.Log().Warning("Failed to list webhooks of event %q: %s", e.payload.Event, err)
+		return
+	}
+
+	if len(hooks) == 0 {
+		return
+	}
+
+	body, err := json.Marshal(e.payload)
+	if err != nil {
+		util.Log().Warning("Failed to encode payload of webhook event %q: %s", e.payload.Event, err)
+		return
+	}
+
+	for _, hook := range hooks {
+		delivery := &model.WebhookDelivery{
+			WebhookID: hook.ID,
+			Event:     e.payload.Event,
+			Payload:   string(body),
+			Status:    model.WebhookDeliveryPending,
+		}
+		if err := delivery.Create(); err != nil {
+			util.Log().Warning("Failed to insert webhook delivery record: %s", err)
+			continue
+		}
+
+		enqueue(delivery.ID)
+	}
+}
+
+// enqueue 将推送记录加入推送队列，队列已满时留待下次扫描
+func enqueue(id uint) {
+	if _, loaded := queued.LoadOrStore(id, struct{}{}); loaded {
+		return
+	}
+
+	select {
+	case deliveries <- id:
+	default:
+		queued.Delete(id)
+		util.Log().Warning("Webhook delivery queue is full, delivery #%d is postponed.", id)
+	}
+}
+
+// sweep 定期将到期的未完成推送加入推送队列，包括上次退出时未完成、队列已满时推迟及等待重试的推送
+func sweep() {
+	for {
+		requeue()
+		time.Sleep(sweepInterval)
+	}
+}
+
+// requeue 将到期的未完成推送加入推送队列
+func requeue() {
+	due, err := model.GetDueWebhookDeliveries(time.Now(), sweepLimit)
+	if err != nil {
+		util.Log().Warning("Failed to list pending webhook deliveries: %s", err)
+		return
+	}
+
+	for _, delivery := range due {
+		enqueue(delivery.ID)
+	}
+}
+
+// work 在后台逐个完成推送
+func work() {
+	for id := range deliveries {
+		deliver(id)
+		queued.Delete(id)
+	}
+}
+
+// deliver 尝试推送一次给定的推送记录，失败且可重试时记录下次重试的时间，由后台扫描重新加入队列
+func deliver(id uint) {
+	delivery, err := model.GetWebhookDeliveryByID(id)
+	if err != nil || delivery.Status != model.WebhookDeliveryPending {
+		return
+	}
+
+	if delivery.NextAttemptAt != nil && delivery.NextAttemptAt.After(time.Now()) {
+		return
+	}
+
+	log := util.Log().With("webhook_id", delivery.WebhookID).With("delivery_id", delivery.ID)
+	hook, err := model.GetWebhookByID(delivery.WebhookID)
+	if err != nil {
+		delivery.Status = model.WebhookDeliveryFailed
+		delivery.Error = "webhook not found"
+		saveResult(log, delivery)
+		return
+	}
+
+	err = attempt(hook, delivery)
+	if err == nil {
+		delivery.Status = model.WebhookDeliverySuccess
+		delivery.Error = ""
+		delivery.NextAttemptAt = nil
+		saveResult(log, delivery)
+		return
+	}
+
+	delivery.Error = err.Error()
+	var retryable *backoff.RetryableError
+	if errors.As(err, &retryable) && delivery.Attempts <= model.GetIntSetting("webhook_max_retry", 3) {
+		interval := time.Duration(model.GetIntSetting("webhook_retry_interval", 30)) * time.Second
+		if retryable.RetryAfter > 0 {
+			interval = retryable.RetryAfter
+		}
+
+		next := time.Now().Add(interval)
+		delivery.NextAttemptAt = &next
+		saveResult(log, delivery)
+		return
+	}
+
+	log.Warning("Failed to deliver webhook event %q after %d attempts: %s", delivery.Event, delivery.Attempts, delivery.Error)
+	delivery.Status = model.WebhookDeliveryFailed
+	delivery.NextAttemptAt = nil
+	saveResult(log, delivery)
+}
+
+// attempt 发送一次推送请求，响应记录在推送记录中。网络错误及 429、5xx 响应可重试
+func attempt(hook *model.Webhook, delivery *model.WebhookDelivery) error {
+	now := time.Now()
+	delivery.Attempts++
+	delivery.DeliveredAt = &now
+	delivery.ResponseCode = 0
+	delivery.Response = ""
+
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	header.Set(EventHeader, delivery.Event)
+	header.Set(DeliveryHeader, strconv.FormatUint(uint64(delivery.ID), 10))
+	header.Set(SignatureHeader, auth.HMACAuth{SecretKey: []byte(hook.Secret)}.Sign(delivery.Payload, now.Unix()+signatureTTL))
+
+	opts := []request.Option{
+		request.WithHeader(header),
+		request.WithContentLength(int64(len(delivery.Payload))),
+		request.WithTimeout(time.Duration(model.GetIntSetting("webhook_timeout", 10)) * time.Second),
+	}
+	if hook.UserID != 0 && !model.IsTrueVal(model.GetSettingByName("webhook_allow_private")) {
+		opts = append(opts, request.WithTransport(publicTransport))
+	}
+
+	resp := client.Request("POST", hook.URL, strings.NewReader(delivery.Payload), opts...)
+	if resp.Err != nil {
+		if errors.Is(resp.Err, ErrPrivateAddress) {
+			return resp.Err
+		}
+		return &backoff.RetryableError{Err: resp.Err}
+	}
+	defer resp.Response.Body.Close()
+
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Response.Body, maxResponseSize))
+	delivery.ResponseCode = resp.Response.StatusCode
+	delivery.Response = string(body)
+
+	if resp.Response.StatusCode >= 200 && resp.Response.StatusCode < 300 {
+		return nil
+	}
+
+	err := fmt.Errorf("unexpected status code %d", resp.Response.StatusCode)
+	if resp.Response.StatusCode == http.StatusTooManyRequests || resp.Response.StatusCode >= 500 {
+		return backoff.NewRetryableErrorFromHeader(err, resp.Response.Header)
+	}
+
+	return err
+}
+
+// saveResult 保存推送记录的状态
+func saveResult(log *util.Logger, delivery *model.WebhookDelivery) {
+	if err := delivery.UpdateResult(); err != nil {
+		log.Warning("Failed to update webhook delivery record: %s", err)
+	}
+}
+
+// denyPrivateAddress 拒绝连接内网、回环及链路本地地址
+func denyPrivateAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsPrivate() || ip.IsLoopback() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() {
+		return ErrPrivateAddress
+	}
+
+	return nil
+}
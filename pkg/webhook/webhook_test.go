@@ -0,0 +1,249 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/auth"
+	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupDB(t *testing.T) func() {
+	mockDB := model.DB
+	model.DB, _ = gorm.Open("sqlite", ":memory:")
+	model.DB.AutoMigrate(&model.Webhook{}, &model.WebhookDelivery{})
+	cache.Set("setting_webhook_retry_interval", "0", 0)
+	cache.Set("setting_webhook_max_retry", "2", 0)
+	return func() {
+		model.DB = mockDB
+	}
+}
+
+func newDelivery(a *assert.Assertions, hook *model.Webhook) *model.WebhookDelivery {
+	a.NoError(hook.Create())
+	delivery := &model.WebhookDelivery{
+		WebhookID: hook.ID,
+		Event:     EventFileUploaded,
+		Payload:   `{"event":"file.uploaded"}`,
+		Status:    model.WebhookDeliveryPending,
+	}
+	a.NoError(delivery.Create())
+	return delivery
+}
+
+func TestDeliver(t *testing.T) {
+	a := assert.New(t)
+	defer setupDB(t)()
+
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		a.Equal(EventFileUploaded, r.Header.Get(EventHeader))
+		a.NotEmpty(r.Header.Get(DeliveryHeader))
+		a.NoError(auth.HMACAuth{SecretKey: []byte("secret")}.Check(string(body), r.Header.Get(SignatureHeader)))
+
+		switch r.URL.Path {
+		case "/flaky":
+			if atomic.AddInt32(&calls, 1) == 1 {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+			w.Write([]byte("ok"))
+		case "/gone":
+			w.WriteHeader(http.StatusGone)
+		default:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	// 失败后等待重试，到期后重试成功
+	{
+		cache.Set("setting_webhook_retry_interval", "60", 0)
+		delivery := newDelivery(a, &model.Webhook{URL: server.URL + "/flaky", Secret: "secret"})
+		deliver(delivery.ID)
+		res, err := model.GetWebhookDeliveryByID(delivery.ID)
+		a.NoError(err)
+		a.Equal(model.WebhookDeliveryPending, res.Status)
+		a.Equal(1, res.Attempts)
+		a.Equal(http.StatusBadGateway, res.ResponseCode)
+		a.NotNil(res.NextAttemptAt)
+		a.True(res.NextAttemptAt.After(time.Now().Add(50 * time.Second)))
+
+		// 未到重试时间不推送
+		deliver(delivery.ID)
+		a.EqualValues(1, atomic.LoadInt32(&calls))
+
+		cache.Set("setting_webhook_retry_interval", "0", 0)
+		model.DB.Model(res).Update("next_attempt_at", time.Now().Add(-time.Second))
+		deliver(delivery.ID)
+		res, err = model.GetWebhookDeliveryByID(delivery.ID)
+		a.NoError(err)
+		a.Equal(model.WebhookDeliverySuccess, res.Status)
+		a.Equal(2, res.Attempts)
+		a.Equal(200, res.ResponseCode)
+		a.Equal("ok", res.Response)
+		a.Empty(res.Error)
+		a.NotNil(res.DeliveredAt)
+		a.Nil(res.NextAttemptAt)
+
+		// 已完成的推送不会重复推送
+		deliver(delivery.ID)
+		a.EqualValues(2, atomic.LoadInt32(&calls))
+	}
+
+	// 4xx 响应不重试
+	{
+		delivery := newDelivery(a, &model.Webhook{URL: server.URL + "/gone", Secret: "secret"})
+		deliver(delivery.ID)
+		res, _ := model.GetWebhookDeliveryByID(delivery.ID)
+		a.Equal(model.WebhookDeliveryFailed, res.Status)
+		a.Equal(1, res.Attempts)
+		a.Equal(http.StatusGone, res.ResponseCode)
+	}
+
+	// 重试次数用尽
+	{
+		delivery := newDelivery(a, &model.Webhook{URL: server.URL + "/down", Secret: "secret"})
+		for i := 0; i < 4; i++ {
+			deliver(delivery.ID)
+		}
+		res, _ := model.GetWebhookDeliveryByID(delivery.ID)
+		a.Equal(model.WebhookDeliveryFailed, res.Status)
+		a.Equal(3, res.Attempts)
+		a.Contains(res.Error, "503")
+	}
+
+	// 用户级订阅不能推送至内网地址
+	{
+		delivery := newDelivery(a, &model.Webhook{UserID: 1, URL: server.URL + "/flaky", Secret: "secret"})
+		deliver(delivery.ID)
+		res, _ := model.GetWebhookDeliveryByID(delivery.ID)
+		a.Equal(model.WebhookDeliveryFailed, res.Status)
+		a.Equal(1, res.Attempts)
+		a.Contains(res.Error, ErrPrivateAddress.Error())
+		a.EqualValues(2, atomic.LoadInt32(&calls))
+	}
+
+	// 订阅不存在
+	{
+		delivery := &model.WebhookDelivery{WebhookID: 9999, Status: model.WebhookDeliveryPending}
+		a.NoError(delivery.Create())
+		deliver(delivery.ID)
+		res, _ := model.GetWebhookDeliveryByID(delivery.ID)
+		a.Equal(model.WebhookDeliveryFailed, res.Status)
+		a.Equal(0, res.Attempts)
+	}
+}
+
+func TestDenyPrivateAddress(t *testing.T) {
+	a := assert.New(t)
+	for _, addr := range []string{"127.0.0.1:80", "10.0.0.1:443", "192.168.1.1:80", "[::1]:80", "169.254.169.254:80", "0.0.0.0:80"} {
+		a.ErrorIs(denyPrivateAddress("tcp", addr, nil), ErrPrivateAddress, addr)
+	}
+	a.NoError(denyPrivateAddress("tcp", "1.1.1.1:443", nil))
+	a.Error(denyPrivateAddress("tcp", "invalid", nil))
+}
+
+func TestRequeue(t *testing.T) {
+	a := assert.New(t)
+	defer setupDB(t)()
+
+	// 队列已满时推迟的推送由扫描重新加入队列
+	hook := &model.Webhook{URL: "http://localhost", Secret: "secret"}
+	delivery := newDelivery(a, hook)
+	later := &model.WebhookDelivery{WebhookID: hook.ID, Status: model.WebhookDeliveryPending}
+	next := time.Now().Add(time.Hour)
+	later.NextAttemptAt = &next
+	a.NoError(later.Create())
+
+	for len(deliveries) < queueSize {
+		deliveries <- 0
+	}
+	enqueue(delivery.ID)
+	_, ok := queued.Load(delivery.ID)
+	a.False(ok)
+	for len(deliveries) > 0 {
+		<-deliveries
+	}
+
+	requeue()
+	a.Len(deliveries, 1)
+	a.Equal(delivery.ID, <-deliveries)
+
+	// 已在队列中的推送不会重复加入
+	requeue()
+	a.Len(deliveries, 0)
+	queued.Delete(delivery.ID)
+}
+
+func TestPublishAndReplay(t *testing.T) {
+	a := assert.New(t)
+	defer setupDB(t)()
+	defer atomic.StoreInt32(&enabled, 0)
+
+	received := make(chan Payload, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload Payload
+		a.NoError(json.NewDecoder(r.Body).Decode(&payload))
+		received <- payload
+	}))
+	defer server.Close()
+
+	siteHook := &model.Webhook{URL: server.URL, Secret: "secret", Events: EventShareCreated, Enabled: true}
+	a.NoError(siteHook.Create())
+
+	// 未启用时不发布
+	Publish(context.Background(), EventShareCreated, 1, nil)
+	a.Len(events, 0)
+
+	cache.Set("setting_webhook_enabled", "1", 0)
+	Init()
+	a.True(Enabled())
+
+	Publish(context.Background(), EventFileDeleted, 1, nil)
+	Publish(context.Background(), EventShareCreated, 1, map[string]string{"id": "share"})
+	Flush()
+
+	var payload Payload
+	select {
+	case payload = <-received:
+	case <-time.After(5 * time.Second):
+		a.FailNow("webhook is not delivered")
+	}
+	a.Equal(EventShareCreated, payload.Event)
+	a.NotEmpty(payload.ID)
+	a.NotEmpty(payload.User)
+	a.Equal(map[string]interface{}{"id": "share"}, payload.Data)
+
+	deliveries, total, err := model.ListWebhookDeliveries(siteHook.ID, 1, 10)
+	a.NoError(err)
+	a.Equal(1, total)
+	a.Eventually(func() bool { return succeeded(deliveries[0].ID) }, 5*time.Second, 10*time.Millisecond)
+
+	// 重放时消息体不变
+	replayed, err := Replay(&deliveries[0])
+	a.NoError(err)
+	a.Equal(deliveries[0].ID, replayed.ReplayOf)
+	select {
+	case replayedPayload := <-received:
+		a.Equal(payload.ID, replayedPayload.ID)
+	case <-time.After(5 * time.Second):
+		a.FailNow("webhook is not replayed")
+	}
+	a.Eventually(func() bool { return succeeded(replayed.ID) }, 5*time.Second, 10*time.Millisecond)
+}
+
+func succeeded(id uint) bool {
+	delivery, err := model.GetWebhookDeliveryByID(id)
+	return err == nil && delivery.Status == model.WebhookDeliverySuccess
+}
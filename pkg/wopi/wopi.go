@@ -25,6 +25,7 @@ type Client interface {
 
 var (
 	ErrActionNotSupported = errors.New("action not supported by current wopi endpoint")
+	ErrLockMismatch       = errors.New("lock mismatch")
 
 	Default   Client
 	DefaultMu sync.Mutex
@@ -51,12 +52,18 @@ const (
 	OverwriteHeader     = wopiHeaderPrefix + "Override"
 	ServerErrorHeader   = wopiHeaderPrefix + "ServerError"
 	RenameRequestHeader = wopiHeaderPrefix + "RequestedName"
+	LockHeader          = wopiHeaderPrefix + "Lock"
+	OldLockHeader       = wopiHeaderPrefix + "OldLock"
 
 	MethodLock        = "LOCK"
+	MethodGetLock     = "GET_LOCK"
 	MethodUnlock      = "UNLOCK"
 	MethodRefreshLock = "REFRESH_LOCK"
 	MethodRename      = "RENAME_FILE"
 
+	// LockDuration is how long a WOPI lock lasts without being refreshed.
+	LockDuration = 30 * time.Minute
+
 	wopiSrcPlaceholder    = "WOPI_SOURCE"
 	wopiSrcParamDefault   = "WOPISrc"
 	languageParamDefault  = "lang"
@@ -3,15 +3,21 @@ package controllers
 import (
 	"github.com/cloudreve/Cloudreve/v3/pkg/cluster"
 	"github.com/cloudreve/Cloudreve/v3/pkg/mq"
+	"github.com/cloudreve/Cloudreve/v3/pkg/oidc"
 	"io"
 
 	model "github.com/cloudreve/Cloudreve/v3/models"
 	"github.com/cloudreve/Cloudreve/v3/pkg/aria2"
+	"github.com/cloudreve/Cloudreve/v3/pkg/audit"
+	"github.com/cloudreve/Cloudreve/v3/pkg/authprovider"
 	"github.com/cloudreve/Cloudreve/v3/pkg/email"
 	"github.com/cloudreve/Cloudreve/v3/pkg/request"
+	"github.com/cloudreve/Cloudreve/v3/pkg/search"
 	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/cloudreve/Cloudreve/v3/pkg/webhook"
 	"github.com/cloudreve/Cloudreve/v3/pkg/wopi"
 	"github.com/cloudreve/Cloudreve/v3/service/admin"
+	"github.com/cloudreve/Cloudreve/v3/service/setting"
 	"github.com/gin-gonic/gin"
 )
 
@@ -43,7 +49,7 @@ func AdminNews(c *gin.Context) {
 func AdminChangeSetting(c *gin.Context) {
 	var service admin.BatchSettingChangeService
 	if err := c.ShouldBindJSON(&service); err == nil {
-		res := service.Change()
+		res := service.Change(c)
 		c.JSON(200, res)
 	} else {
 		c.JSON(200, ErrorResponse(err))
@@ -82,6 +88,16 @@ func AdminReloadService(c *gin.Context) {
 		aria2.Init(true, cluster.Default, mq.GlobalMQ)
 	case "wopi":
 		wopi.Init()
+	case "search":
+		search.Init()
+	case "oidc":
+		oidc.Init()
+	case "ldap":
+		authprovider.Init()
+	case "audit":
+		audit.Init()
+	case "webhook":
+		webhook.Init()
 	}
 
 	c.JSON(200, serializer.Response{})
@@ -163,7 +179,7 @@ func AdminTestSlave(c *gin.Context) {
 func AdminAddPolicy(c *gin.Context) {
 	var service admin.AddPolicyService
 	if err := c.ShouldBindJSON(&service); err == nil {
-		res := service.Add()
+		res := service.Add(c)
 		c.JSON(200, res)
 	} else {
 		c.JSON(200, ErrorResponse(err))
@@ -220,7 +236,7 @@ func AdminGetPolicy(c *gin.Context) {
 func AdminDeletePolicy(c *gin.Context) {
 	var service admin.PolicyService
 	if err := c.ShouldBindUri(&service); err == nil {
-		res := service.Delete()
+		res := service.Delete(c)
 		c.JSON(200, res)
 	} else {
 		c.JSON(200, ErrorResponse(err))
@@ -242,7 +258,7 @@ func AdminListGroup(c *gin.Context) {
 func AdminAddGroup(c *gin.Context) {
 	var service admin.AddGroupService
 	if err := c.ShouldBindJSON(&service); err == nil {
-		res := service.Add()
+		res := service.Add(c)
 		c.JSON(200, res)
 	} else {
 		c.JSON(200, ErrorResponse(err))
@@ -253,7 +269,7 @@ func AdminAddGroup(c *gin.Context) {
 func AdminDeleteGroup(c *gin.Context) {
 	var service admin.GroupService
 	if err := c.ShouldBindUri(&service); err == nil {
-		res := service.Delete()
+		res := service.Delete(c)
 		c.JSON(200, res)
 	} else {
 		c.JSON(200, ErrorResponse(err))
@@ -286,7 +302,7 @@ func AdminListUser(c *gin.Context) {
 func AdminAddUser(c *gin.Context) {
 	var service admin.AddUserService
 	if err := c.ShouldBindJSON(&service); err == nil {
-		res := service.Add()
+		res := service.Add(c)
 		c.JSON(200, res)
 	} else {
 		c.JSON(200, ErrorResponse(err))
@@ -308,7 +324,7 @@ func AdminGetUser(c *gin.Context) {
 func AdminDeleteUser(c *gin.Context) {
 	var service admin.UserBatchService
 	if err := c.ShouldBindJSON(&service); err == nil {
-		res := service.Delete()
+		res := service.Delete(c)
 		c.JSON(200, res)
 	} else {
 		c.JSON(200, ErrorResponse(err))
@@ -319,7 +335,7 @@ func AdminDeleteUser(c *gin.Context) {
 func AdminBanUser(c *gin.Context) {
 	var service admin.UserService
 	if err := c.ShouldBindUri(&service); err == nil {
-		res := service.Ban()
+		res := service.Ban(c)
 		c.JSON(200, res)
 	} else {
 		c.JSON(200, ErrorResponse(err))
@@ -389,6 +405,28 @@ func AdminDeleteShare(c *gin.Context) {
 	}
 }
 
+// AdminListShareAccess 列出分享访问记录
+func AdminListShareAccess(c *gin.Context) {
+	var service admin.ShareAccessListService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.List()
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// AdminGetShareAccessDaily 按天统计全部分享的访问
+func AdminGetShareAccessDaily(c *gin.Context) {
+	var service admin.ShareAccessDailyService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Daily()
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
 // AdminListDownload 列出离线下载任务
 func AdminListDownload(c *gin.Context) {
 	var service admin.AdminListService
@@ -433,6 +471,28 @@ func AdminDeleteTask(c *gin.Context) {
 	}
 }
 
+// AdminCancelTask 批量取消任务
+func AdminCancelTask(c *gin.Context) {
+	var service admin.TaskBatchService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Cancel(c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// AdminRetryTask 批量重试任务
+func AdminRetryTask(c *gin.Context) {
+	var service admin.TaskBatchService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Retry(c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
 // AdminCreateImportTask 新建文件导入任务
 func AdminCreateImportTask(c *gin.Context) {
 	var service admin.ImportTaskService
@@ -444,6 +504,17 @@ func AdminCreateImportTask(c *gin.Context) {
 	}
 }
 
+// AdminCreateMigrateTask 新建存储策略迁移任务
+func AdminCreateMigrateTask(c *gin.Context) {
+	var service admin.MigrateTaskService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Create(c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
 // AdminListFolders 列出用户或外部文件系统目录
 func AdminListFolders(c *gin.Context) {
 	var service admin.ListFolderService
@@ -470,7 +541,7 @@ func AdminListNodes(c *gin.Context) {
 func AdminAddNode(c *gin.Context) {
 	var service admin.AddNodeService
 	if err := c.ShouldBindJSON(&service); err == nil {
-		res := service.Add()
+		res := service.Add(c)
 		c.JSON(200, res)
 	} else {
 		c.JSON(200, ErrorResponse(err))
@@ -492,7 +563,7 @@ func AdminToggleNode(c *gin.Context) {
 func AdminDeleteNode(c *gin.Context) {
 	var service admin.NodeService
 	if err := c.ShouldBindUri(&service); err == nil {
-		res := service.Delete()
+		res := service.Delete(c)
 		c.JSON(200, res)
 	} else {
 		c.JSON(200, ErrorResponse(err))
@@ -509,3 +580,83 @@ func AdminGetNode(c *gin.Context) {
 		c.JSON(200, ErrorResponse(err))
 	}
 }
+
+// AdminListAuditLog 列出审计日志
+func AdminListAuditLog(c *gin.Context) {
+	var service admin.AuditLogListService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.List()
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// AdminExportAuditLog 以 JSON Lines 格式导出审计日志
+func AdminExportAuditLog(c *gin.Context) {
+	var service admin.AuditLogFilter
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Export(c)
+		if res.Code != 0 {
+			c.JSON(200, res)
+		}
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// AdminListWebhooks 列出站点级事件推送订阅
+func AdminListWebhooks(c *gin.Context) {
+	var service setting.WebhookListService
+	res := service.Webhooks(c, 0)
+	c.JSON(200, res)
+}
+
+// AdminSaveWebhook 创建或更新站点级事件推送订阅
+func AdminSaveWebhook(c *gin.Context) {
+	var service setting.WebhookSaveService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Save(c, 0)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// AdminDeleteWebhook 删除站点级事件推送订阅
+func AdminDeleteWebhook(c *gin.Context) {
+	var service setting.WebhookService
+	if err := c.ShouldBindUri(&service); err == nil {
+		res := service.Delete(c, 0)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// AdminListWebhookDeliveries 列出站点级订阅的事件推送记录
+func AdminListWebhookDeliveries(c *gin.Context) {
+	var service setting.WebhookDeliveryListService
+	if err := c.ShouldBindUri(&service); err != nil {
+		c.JSON(200, ErrorResponse(err))
+		return
+	}
+
+	if err := c.ShouldBindQuery(&service); err == nil {
+		res := service.Deliveries(c, 0)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// AdminReplayWebhookDelivery 重新推送站点级订阅的事件
+func AdminReplayWebhookDelivery(c *gin.Context) {
+	var service setting.WebhookDeliveryService
+	if err := c.ShouldBindUri(&service); err == nil {
+		res := service.Replay(c, 0)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/cloudreve/Cloudreve/v3/pkg/aria2/common"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
 	"github.com/cloudreve/Cloudreve/v3/service/aria2"
 	"github.com/cloudreve/Cloudreve/v3/service/explorer"
 	"github.com/gin-gonic/gin"
@@ -34,7 +35,7 @@ func SelectAria2File(c *gin.Context) {
 // AddAria2Torrent 添加离线下载种子
 func AddAria2Torrent(c *gin.Context) {
 	// 创建上下文
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(util.RequestContext(c))
 	defer cancel()
 
 	var service explorer.FileIDService
@@ -8,6 +8,7 @@ import (
 	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
 	"github.com/cloudreve/Cloudreve/v3/pkg/util"
 	"github.com/cloudreve/Cloudreve/v3/service/callback"
+	"github.com/cloudreve/Cloudreve/v3/service/user"
 	"github.com/gin-gonic/gin"
 )
 
@@ -56,7 +57,7 @@ func UpyunCallback(c *gin.Context) {
 	var callbackBody callback.UpyunCallbackService
 	if err := c.ShouldBind(&callbackBody); err == nil {
 		if callbackBody.Code != 200 {
-			util.Log().Debug(
+			util.Log().WithContext(c).Debug(
 				"Upload callback returned error code:%d, message: %s",
 				callbackBody.Code,
 				callbackBody.Message,
@@ -81,6 +82,13 @@ func OneDriveCallback(c *gin.Context) {
 	}
 }
 
+// GoogleDriveCallback Google Drive上传完成客户端回调
+func GoogleDriveCallback(c *gin.Context) {
+	var callbackBody callback.GoogleDriveCallback
+	res := callbackBody.PreProcess(c)
+	c.JSON(200, res)
+}
+
 // OneDriveOAuth OneDrive 授权回调
 func OneDriveOAuth(c *gin.Context) {
 	var callbackBody callback.OauthService
@@ -138,3 +146,28 @@ func S3Callback(c *gin.Context) {
 		c.JSON(200, ErrorResponse(err))
 	}
 }
+
+// OIDCCallback OpenID Connect 登录授权回调
+func OIDCCallback(c *gin.Context) {
+	var callbackBody user.OIDCCallbackService
+	if err := c.ShouldBindQuery(&callbackBody); err == nil {
+		res, linking := callbackBody.Callback(c)
+		redirect := model.GetSiteURL()
+		switch {
+		case linking:
+			redirect.Path = path.Join(redirect.Path, "/setting")
+		case res.Code == 0:
+			redirect.Path = path.Join(redirect.Path, "/home")
+		default:
+			redirect.Path = path.Join(redirect.Path, "/login")
+		}
+		queries := redirect.Query()
+		queries.Add("code", strconv.Itoa(res.Code))
+		queries.Add("msg", res.Msg)
+		queries.Add("err", res.Error)
+		redirect.RawQuery = queries.Encode()
+		c.Redirect(303, redirect.String())
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/request"
 	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
 	"github.com/cloudreve/Cloudreve/v3/pkg/util"
 	"github.com/cloudreve/Cloudreve/v3/service/share"
@@ -45,6 +46,57 @@ func ListShare(c *gin.Context) {
 	}
 }
 
+// ListReceivedShare 列出他人授权给我的分享
+func ListReceivedShare(c *gin.Context) {
+	var service share.ReceivedShareListService
+	if err := c.ShouldBindQuery(&service); err == nil {
+		res := service.List(c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// ListShareAccess 列出我的分享的访问记录
+func ListShareAccess(c *gin.Context) {
+	var service share.AccessListService
+	if err := c.ShouldBindQuery(&service); err == nil {
+		res := service.List(c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// GetShareAccessDaily 按天统计我的分享的访问
+func GetShareAccessDaily(c *gin.Context) {
+	var service share.AccessDailyService
+	if err := c.ShouldBindQuery(&service); err == nil {
+		res := service.Daily(c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// ListShareGrants 列出内部分享的授权
+func ListShareGrants(c *gin.Context) {
+	var service share.ShareGrantService
+	res := service.List(c)
+	c.JSON(200, res)
+}
+
+// UpdateShareGrants 设置内部分享的授权
+func UpdateShareGrants(c *gin.Context) {
+	var service share.ShareGrantService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Update(c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
 // SearchShare 搜索分享
 func SearchShare(c *gin.Context) {
 	var service share.ShareListService
@@ -92,7 +144,7 @@ func GetShareDownload(c *gin.Context) {
 // PreviewShare 预览分享文件内容
 func PreviewShare(c *gin.Context) {
 	// 创建上下文
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(util.RequestContext(c))
 	defer cancel()
 
 	var service share.Service
@@ -115,7 +167,7 @@ func PreviewShare(c *gin.Context) {
 // PreviewShareText 预览文本文件
 func PreviewShareText(c *gin.Context) {
 	// 创建上下文
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(util.RequestContext(c))
 	defer cancel()
 
 	var service share.Service
@@ -133,7 +185,7 @@ func PreviewShareText(c *gin.Context) {
 // PreviewShareReadme 预览文本自述文件
 func PreviewShareReadme(c *gin.Context) {
 	// 创建上下文
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(util.RequestContext(c))
 	defer cancel()
 
 	var service share.Service
@@ -212,6 +264,102 @@ func ArchiveShare(c *gin.Context) {
 	}
 }
 
+// SaveShare 转存分享
+func SaveShare(c *gin.Context) {
+	var service share.SaveService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Save(c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// CreateShareUploadSession 访客创建向收集文件分享上传的会话
+func CreateShareUploadSession(c *gin.Context) {
+	ctx, cancel := context.WithCancel(util.RequestContext(c))
+	defer cancel()
+
+	var service share.UploadSessionService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Create(ctx, c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// ShareUpload 访客向收集文件分享上传本机策略分片
+func ShareUpload(c *gin.Context) {
+	ctx, cancel := context.WithCancel(util.RequestContext(c))
+	defer cancel()
+
+	var service share.UploadService
+	if err := c.ShouldBindUri(&service); err == nil {
+		res := service.Upload(ctx, c)
+		c.JSON(200, res)
+		request.BlackHole(c.Request.Body)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// DeleteShareUploadSession 访客取消向收集文件分享的上传
+func DeleteShareUploadSession(c *gin.Context) {
+	ctx, cancel := context.WithCancel(util.RequestContext(c))
+	defer cancel()
+
+	var service share.UploadSessionDeleteService
+	if err := c.ShouldBindUri(&service); err == nil {
+		res := service.Delete(ctx, c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// CreateSharedDirectory 在分享目录中创建目录
+func CreateSharedDirectory(c *gin.Context) {
+	ctx, cancel := context.WithCancel(util.RequestContext(c))
+	defer cancel()
+
+	var service share.DirectoryService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.CreateDirectory(ctx, c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// RenameSharedObject 重命名分享目录中的对象
+func RenameSharedObject(c *gin.Context) {
+	ctx, cancel := context.WithCancel(util.RequestContext(c))
+	defer cancel()
+
+	var service share.RenameService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Rename(ctx, c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// DeleteSharedObjects 删除分享目录中的对象
+func DeleteSharedObjects(c *gin.Context) {
+	ctx, cancel := context.WithCancel(util.RequestContext(c))
+	defer cancel()
+
+	var service share.ObjectService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Delete(ctx, c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
 // ShareThumb 获取分享目录下文件的缩略图
 func ShareThumb(c *gin.Context) {
 	var service share.Service
@@ -29,6 +29,8 @@ func SiteConfig(c *gin.Context) {
 		"captcha_TCaptcha_CaptchaAppId",
 		"register_enabled",
 		"show_app_promotion",
+		"oidc_enabled",
+		"oidc_display_name",
 	)
 
 	var wopiExts []string
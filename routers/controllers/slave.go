@@ -5,6 +5,7 @@ import (
 
 	"github.com/cloudreve/Cloudreve/v3/pkg/request"
 	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
 	"github.com/cloudreve/Cloudreve/v3/service/admin"
 	"github.com/cloudreve/Cloudreve/v3/service/aria2"
 	"github.com/cloudreve/Cloudreve/v3/service/explorer"
@@ -15,7 +16,7 @@ import (
 // SlaveUpload 从机文件上传
 func SlaveUpload(c *gin.Context) {
 	// 创建上下文
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(util.RequestContext(c))
 	defer cancel()
 
 	var service explorer.UploadService
@@ -31,7 +32,7 @@ func SlaveUpload(c *gin.Context) {
 // SlaveGetUploadSession 从机创建上传会话
 func SlaveGetUploadSession(c *gin.Context) {
 	// 创建上下文
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(util.RequestContext(c))
 	defer cancel()
 
 	var service explorer.SlaveCreateUploadSessionService
@@ -46,7 +47,7 @@ func SlaveGetUploadSession(c *gin.Context) {
 // SlaveDeleteUploadSession 从机删除上传会话
 func SlaveDeleteUploadSession(c *gin.Context) {
 	// 创建上下文
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(util.RequestContext(c))
 	defer cancel()
 
 	var service explorer.UploadSessionService
@@ -61,7 +62,7 @@ func SlaveDeleteUploadSession(c *gin.Context) {
 // SlaveDownload 从机文件下载,此请求返回的HTTP状态码不全为200
 func SlaveDownload(c *gin.Context) {
 	// 创建上下文
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(util.RequestContext(c))
 	defer cancel()
 
 	var service explorer.SlaveDownloadService
@@ -78,7 +79,7 @@ func SlaveDownload(c *gin.Context) {
 // SlavePreview 从机文件预览
 func SlavePreview(c *gin.Context) {
 	// 创建上下文
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(util.RequestContext(c))
 	defer cancel()
 
 	var service explorer.SlaveDownloadService
@@ -95,7 +96,7 @@ func SlavePreview(c *gin.Context) {
 // SlaveThumb 从机文件缩略图
 func SlaveThumb(c *gin.Context) {
 	// 创建上下文
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(util.RequestContext(c))
 	defer cancel()
 
 	var service explorer.SlaveFileService
@@ -112,7 +113,7 @@ func SlaveThumb(c *gin.Context) {
 // SlaveDelete 从机删除
 func SlaveDelete(c *gin.Context) {
 	// 创建上下文
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(util.RequestContext(c))
 	defer cancel()
 
 	var service explorer.SlaveFilesService
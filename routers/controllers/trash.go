@@ -0,0 +1,46 @@
+package controllers
+
+import (
+	"context"
+
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+	"github.com/cloudreve/Cloudreve/v3/service/explorer"
+	"github.com/gin-gonic/gin"
+)
+
+// ListTrash 列出回收站中的对象
+func ListTrash(c *gin.Context) {
+	var service explorer.TrashListService
+	res := service.List(c, CurrentUser(c))
+	c.JSON(200, res)
+}
+
+// RestoreTrash 还原回收站中的对象
+func RestoreTrash(c *gin.Context) {
+	// 创建上下文
+	ctx, cancel := context.WithCancel(util.RequestContext(c))
+	defer cancel()
+
+	var service explorer.TrashRestoreService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Restore(ctx, c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// DeleteTrash 彻底删除回收站中的对象
+func DeleteTrash(c *gin.Context) {
+	// 创建上下文
+	ctx, cancel := context.WithCancel(util.RequestContext(c))
+	defer cancel()
+
+	var service explorer.TrashService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Delete(ctx, c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
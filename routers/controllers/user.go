@@ -10,6 +10,7 @@ import (
 	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
 	"github.com/cloudreve/Cloudreve/v3/pkg/thumb"
 	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+	"github.com/cloudreve/Cloudreve/v3/service/setting"
 	"github.com/cloudreve/Cloudreve/v3/service/user"
 	"github.com/duo-labs/webauthn/webauthn"
 	"github.com/gin-gonic/gin"
@@ -244,6 +245,28 @@ func UserTasks(c *gin.Context) {
 	}
 }
 
+// CancelTask 取消任务
+func CancelTask(c *gin.Context) {
+	var service user.TaskService
+	if err := c.ShouldBindUri(&service); err == nil {
+		res := service.Cancel(c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// RetryTask 重试任务
+func RetryTask(c *gin.Context) {
+	var service user.TaskService
+	if err := c.ShouldBindUri(&service); err == nil {
+		res := service.Retry(c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
 // UserSetting 获取用户设定
 func UserSetting(c *gin.Context) {
 	var service user.SettingService
@@ -378,6 +401,91 @@ func UserInit2FA(c *gin.Context) {
 	}
 }
 
+// ListAccessTokens 列出个人访问令牌
+func ListAccessTokens(c *gin.Context) {
+	var service setting.AccessTokenListService
+	res := service.Tokens(c, CurrentUser(c))
+	c.JSON(200, res)
+}
+
+// CreateAccessToken 创建个人访问令牌
+func CreateAccessToken(c *gin.Context) {
+	var service setting.AccessTokenCreateService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Create(c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// DeleteAccessToken 撤销个人访问令牌
+func DeleteAccessToken(c *gin.Context) {
+	var service setting.AccessTokenService
+	if err := c.ShouldBindUri(&service); err == nil {
+		res := service.Delete(c, CurrentUser(c))
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// ListWebhooks 列出事件推送订阅
+func ListWebhooks(c *gin.Context) {
+	var service setting.WebhookListService
+	res := service.Webhooks(c, CurrentUser(c).ID)
+	c.JSON(200, res)
+}
+
+// SaveWebhook 创建或更新事件推送订阅
+func SaveWebhook(c *gin.Context) {
+	var service setting.WebhookSaveService
+	if err := c.ShouldBindJSON(&service); err == nil {
+		res := service.Save(c, CurrentUser(c).ID)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// DeleteWebhook 删除事件推送订阅
+func DeleteWebhook(c *gin.Context) {
+	var service setting.WebhookService
+	if err := c.ShouldBindUri(&service); err == nil {
+		res := service.Delete(c, CurrentUser(c).ID)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// ListWebhookDeliveries 列出事件推送记录
+func ListWebhookDeliveries(c *gin.Context) {
+	var service setting.WebhookDeliveryListService
+	if err := c.ShouldBindUri(&service); err != nil {
+		c.JSON(200, ErrorResponse(err))
+		return
+	}
+
+	if err := c.ShouldBindQuery(&service); err == nil {
+		res := service.Deliveries(c, CurrentUser(c).ID)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// ReplayWebhookDelivery 重新推送事件
+func ReplayWebhookDelivery(c *gin.Context) {
+	var service setting.WebhookDeliveryService
+	if err := c.ShouldBindUri(&service); err == nil {
+		res := service.Replay(c, CurrentUser(c).ID)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
 // UserPrepareCopySession generates URL for copy session
 func UserPrepareCopySession(c *gin.Context) {
 	var service user.CopySessionService
@@ -396,3 +504,31 @@ func UserPerformCopySession(c *gin.Context) {
 		c.JSON(200, ErrorResponse(err))
 	}
 }
+
+// StartOIDCLogin 获取 OpenID Connect 登录地址
+func StartOIDCLogin(c *gin.Context) {
+	var service user.OIDCLoginService
+	res := service.Login(c)
+	c.JSON(200, res)
+}
+
+// StartOIDCLink 获取绑定外部身份的授权地址
+func StartOIDCLink(c *gin.Context) {
+	var service user.OIDCLoginService
+	res := service.Link(c, CurrentUser(c))
+	c.JSON(200, res)
+}
+
+// ListOIDCIdentities 列出已绑定的外部身份
+func ListOIDCIdentities(c *gin.Context) {
+	var service user.OIDCIdentityService
+	res := service.List(c, CurrentUser(c))
+	c.JSON(200, res)
+}
+
+// UnlinkOIDCIdentity 解除外部身份绑定
+func UnlinkOIDCIdentity(c *gin.Context) {
+	var service user.OIDCIdentityService
+	res := service.Unlink(c, CurrentUser(c))
+	c.JSON(200, res)
+}
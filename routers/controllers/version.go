@@ -0,0 +1,102 @@
+package controllers
+
+import (
+	"context"
+
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+	"github.com/cloudreve/Cloudreve/v3/service/explorer"
+	"github.com/gin-gonic/gin"
+)
+
+// ListFileVersions 列出文件的历史版本
+func ListFileVersions(c *gin.Context) {
+	var service explorer.FileVersionListService
+	res := service.List(c, CurrentUser(c))
+	c.JSON(200, res)
+}
+
+// CreateVersionDownloadSession 创建历史版本下载会话
+func CreateVersionDownloadSession(c *gin.Context) {
+	// 创建上下文
+	ctx, cancel := context.WithCancel(util.RequestContext(c))
+	defer cancel()
+
+	var service explorer.FileVersionService
+	if err := c.ShouldBindUri(&service); err == nil {
+		res := service.CreateDownloadSession(ctx, c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// PreviewFileVersion 预览历史版本
+func PreviewFileVersion(c *gin.Context) {
+	// 创建上下文
+	ctx, cancel := context.WithCancel(util.RequestContext(c))
+	defer cancel()
+
+	var service explorer.FileVersionService
+	if err := c.ShouldBindUri(&service); err == nil {
+		res := service.Preview(ctx, c, false)
+		// 是否需要重定向
+		if res.Code == -301 {
+			c.Redirect(302, res.Data.(string))
+			return
+		}
+		// 是否有错误发生
+		if res.Code != 0 {
+			c.JSON(200, res)
+		}
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// PreviewFileVersionText 获取历史版本的文本内容
+func PreviewFileVersionText(c *gin.Context) {
+	// 创建上下文
+	ctx, cancel := context.WithCancel(util.RequestContext(c))
+	defer cancel()
+
+	var service explorer.FileVersionService
+	if err := c.ShouldBindUri(&service); err == nil {
+		res := service.Preview(ctx, c, true)
+		// 是否有错误发生
+		if res.Code != 0 {
+			c.JSON(200, res)
+		}
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// RestoreFileVersion 将文件还原为历史版本
+func RestoreFileVersion(c *gin.Context) {
+	// 创建上下文
+	ctx, cancel := context.WithCancel(util.RequestContext(c))
+	defer cancel()
+
+	var service explorer.FileVersionService
+	if err := c.ShouldBindUri(&service); err == nil {
+		res := service.Restore(ctx, c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
+
+// DeleteFileVersion 删除历史版本
+func DeleteFileVersion(c *gin.Context) {
+	// 创建上下文
+	ctx, cancel := context.WithCancel(util.RequestContext(c))
+	defer cancel()
+
+	var service explorer.FileVersionService
+	if err := c.ShouldBindUri(&service); err == nil {
+		res := service.Delete(ctx, c)
+		c.JSON(200, res)
+	} else {
+		c.JSON(200, ErrorResponse(err))
+	}
+}
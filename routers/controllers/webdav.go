@@ -10,7 +10,7 @@ import (
 	"github.com/cloudreve/Cloudreve/v3/service/setting"
 	"github.com/gin-gonic/gin"
 	"net/http"
-	"sync"
+	"path"
 )
 
 var handler *webdav.Handler
@@ -18,8 +18,7 @@ var handler *webdav.Handler
 func init() {
 	handler = &webdav.Handler{
 		Prefix:     "/dav",
-		LockSystem: make(map[uint]webdav.LockSystem),
-		Mutex:      &sync.Mutex{},
+		LockSystem: webdav.NewDBLS,
 	}
 }
 
@@ -27,33 +26,115 @@ func init() {
 func ServeWebDAV(c *gin.Context) {
 	fs, err := filesystem.NewFileSystemFromContext(c)
 	if err != nil {
-		util.Log().Warning("Failed to initialize filesystem for WebDAV，%s", err)
+		util.Log().WithContext(c).Warning("Failed to initialize filesystem for WebDAV，%s", err)
 		return
 	}
 
+	var application *model.Webdav
+	if webdavCtx, ok := c.Get("webdav"); ok {
+		application = webdavCtx.(*model.Webdav)
+	}
+
+	serveWebDAV(c, handler, fs, application)
+}
+
+// ServeSharedWebDAV 将他人授权给当前用户的目录分享挂载为 WebDAV，请求由分享者的文件系统处理，
+// 并按授权的权限级别限制可用的方法
+func ServeSharedWebDAV(c *gin.Context) {
+	user := CurrentUser(c)
+	share := model.GetShareByHashID(c.Param("id"))
+	if share == nil || !share.IsDir || !share.Internal || !share.IsAvailable() {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	permission := share.PermissionOf(user)
+	if permission == model.SharePermissionNone {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	folder := share.SourceFolder()
+	if err := folder.TraceRoot(); err != nil {
+		util.Log().WithContext(c).Warning("Failed to trace shared folder for WebDAV，%s", err)
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	fs, err := filesystem.NewFileSystem(share.Creator())
+	if err != nil {
+		util.Log().WithContext(c).Warning("Failed to initialize filesystem for WebDAV，%s", err)
+		return
+	}
+
+	// 以分享目录为根目录，仅有浏览权限时只能列目录
+	mount := &model.Webdav{
+		Name:     "Share",
+		Root:     path.Join(folder.Position, folder.Name),
+		Readonly: permission < model.SharePermissionEdit,
+	}
+	if permission < model.SharePermissionDownload {
+		mount.UploadOnly = true
+	}
 	if webdavCtx, ok := c.Get("webdav"); ok {
 		application := webdavCtx.(*model.Webdav)
+		mount.Name = application.Name
+		mount.UseProxy = application.UseProxy
+		mount.Readonly = mount.Readonly || application.Readonly
+		mount.NoDelete = application.NoDelete
+		mount.UploadOnly = mount.UploadOnly || application.UploadOnly
+	}
 
-		// 重定根目录
+	sharedHandler := &webdav.Handler{
+		Prefix:     "/dav-shared/" + c.Param("id"),
+		LockSystem: webdav.NewDBLS,
+	}
+	serveWebDAV(c, sharedHandler, fs, mount)
+}
+
+// serveWebDAV 按 WebDAV 应用的设置重定根目录、检查请求方法后处理请求
+func serveWebDAV(c *gin.Context, handler *webdav.Handler, fs *filesystem.FileSystem, application *model.Webdav) {
+	if application != nil {
+		// 重定根目录，根目录不存在时拒绝请求，避免回退到用户的整个网盘
 		if application.Root != "/" {
-			if exist, root := fs.IsPathExist(application.Root); exist {
-				root.Position = ""
-				root.Name = "/"
-				fs.Root = root
+			exist, root := fs.IsPathExist(application.Root)
+			if !exist {
+				c.Status(http.StatusNotFound)
+				return
 			}
+
+			root.Position = ""
+			root.Name = "/"
+			fs.Root = root
 		}
 
-		// 检查是否只读
+		// 检查是否只读，锁会持久化在所有者名下，只读时同样不允许加锁
 		if application.Readonly {
 			switch c.Request.Method {
-			case "DELETE", "PUT", "MKCOL", "COPY", "MOVE":
+			case "DELETE", "PUT", "MKCOL", "COPY", "MOVE", "LOCK", "PROPPATCH":
+				c.Status(http.StatusForbidden)
+				return
+			}
+		}
+
+		// 检查是否禁止删除
+		if application.NoDelete && c.Request.Method == "DELETE" {
+			c.Status(http.StatusForbidden)
+			return
+		}
+
+		// 检查是否仅允许上传，可以浏览目录结构和上传文件，但不能下载、删除或移动文件
+		if application.UploadOnly {
+			switch c.Request.Method {
+			case "GET", "HEAD", "POST", "DELETE", "COPY", "MOVE", "PROPPATCH":
 				c.Status(http.StatusForbidden)
 				return
 			}
 		}
 
 		// 更新Context
-		c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), fsctx.WebDAVCtx, application))
+		ctx := context.WithValue(c.Request.Context(), fsctx.WebDAVCtx, application)
+		c.Request = c.Request.WithContext(context.WithValue(ctx, fsctx.GinCtx, c))
 	}
 
 	handler.ServeHTTP(c.Writer, c.Request, fs)
@@ -3,6 +3,7 @@ package controllers
 import (
 	"context"
 	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
 	"github.com/cloudreve/Cloudreve/v3/pkg/wopi"
 	"github.com/cloudreve/Cloudreve/v3/service/explorer"
 	"github.com/gin-gonic/gin"
@@ -35,9 +36,15 @@ func GetFile(c *gin.Context) {
 
 // PutFile Puts file content
 func PutFile(c *gin.Context) {
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(util.RequestContext(c))
 	defer cancel()
 
+	var wopiService explorer.WopiService
+	if current, err := wopiService.CheckLock(c); err != nil {
+		wopiLockResponse(c, current, err)
+		return
+	}
+
 	service := &explorer.FileIDService{}
 	res := service.PutContent(ctx, c)
 	switch res.Code {
@@ -59,8 +66,23 @@ func PutFile(c *gin.Context) {
 func ModifyFile(c *gin.Context) {
 	action := c.GetHeader(wopi.OverwriteHeader)
 	switch action {
-	case wopi.MethodLock, wopi.MethodRefreshLock, wopi.MethodUnlock:
-		c.Status(http.StatusOK)
+	case wopi.MethodLock, wopi.MethodGetLock, wopi.MethodRefreshLock, wopi.MethodUnlock:
+		var (
+			service explorer.WopiService
+			current string
+			err     error
+		)
+		switch action {
+		case wopi.MethodLock:
+			current, err = service.Lock(c)
+		case wopi.MethodGetLock:
+			current, err = service.GetLock(c)
+		case wopi.MethodRefreshLock:
+			current, err = service.RefreshLock(c)
+		default:
+			current, err = service.Unlock(c)
+		}
+		wopiLockResponse(c, current, err)
 		return
 	case wopi.MethodRename:
 		var service explorer.WopiService
@@ -75,3 +97,20 @@ func ModifyFile(c *gin.Context) {
 		return
 	}
 }
+
+// wopiLockResponse writes the result of lock operations, current lock ID is
+// always reported even if it is empty.
+func wopiLockResponse(c *gin.Context, current string, err error) {
+	switch err {
+	case nil:
+		c.Status(http.StatusOK)
+	case wopi.ErrLockMismatch:
+		c.Status(http.StatusConflict)
+	default:
+		c.Status(http.StatusInternalServerError)
+		c.Header(wopi.ServerErrorHeader, err.Error())
+		return
+	}
+
+	c.Writer.Header().Set(wopi.LockHeader, current)
+}
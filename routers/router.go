@@ -2,11 +2,13 @@ package routers
 
 import (
 	"github.com/cloudreve/Cloudreve/v3/middleware"
+	model "github.com/cloudreve/Cloudreve/v3/models"
 	"github.com/cloudreve/Cloudreve/v3/pkg/auth"
 	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
 	"github.com/cloudreve/Cloudreve/v3/pkg/cluster"
 	"github.com/cloudreve/Cloudreve/v3/pkg/conf"
 	"github.com/cloudreve/Cloudreve/v3/pkg/hashid"
+	"github.com/cloudreve/Cloudreve/v3/pkg/metrics"
 	"github.com/cloudreve/Cloudreve/v3/pkg/util"
 	wopi2 "github.com/cloudreve/Cloudreve/v3/pkg/wopi"
 	"github.com/cloudreve/Cloudreve/v3/routers/controllers"
@@ -28,7 +30,12 @@ func InitRouter() *gin.Engine {
 
 // InitSlaveRouter 初始化从机模式路由
 func InitSlaveRouter() *gin.Engine {
-	r := gin.Default()
+	r := gin.New()
+	InitProxy(r)
+	// 请求 ID 及访问日志
+	r.Use(middleware.RequestID(), middleware.AccessLog(), gin.Recovery())
+	// 监控指标
+	InitMetrics(r)
 	// 跨域相关
 	InitCORS(r)
 	v3 := r.Group("/api/v3/slave")
@@ -95,6 +102,14 @@ func InitSlaveRouter() *gin.Engine {
 	return r
 }
 
+// InitProxy 初始化可信反向代理，未配置时客户端 IP 始终取自连接地址，不信任任何请求头
+func InitProxy(router *gin.Engine) {
+	router.RemoteIPHeaders = []string{conf.SystemConfig.ProxyHeader}
+	if err := router.SetTrustedProxies(conf.SystemConfig.TrustedProxies); err != nil {
+		util.Log().Panic("Failed to parse trusted proxies: %s", err)
+	}
+}
+
 // InitCORS 初始化跨域配置
 func InitCORS(router *gin.Engine) {
 	if conf.CORSConfig.AllowOrigins[0] != "UNSET" {
@@ -114,9 +129,32 @@ func InitCORS(router *gin.Engine) {
 	}
 }
 
+// InitMetrics 初始化监控指标，未配置独立监听地址时在 /metrics 提供指标。
+// 既未配置访问令牌也未配置独立监听地址时不提供指标，避免公开未鉴权的指标
+func InitMetrics(router *gin.Engine) {
+	if !conf.MetricsConfig.Enabled {
+		return
+	}
+
+	if conf.MetricsConfig.Token == "" && conf.MetricsConfig.Listen == "" {
+		util.Log().Warning("Metrics is enabled but neither Token nor Listen is set, metrics endpoint is not mounted.")
+		return
+	}
+
+	router.Use(middleware.Metrics())
+	if conf.MetricsConfig.Listen == "" {
+		router.GET("metrics", gin.WrapH(metrics.Handler(conf.MetricsConfig.Token)))
+	}
+}
+
 // InitMasterRouter 初始化主机模式路由
 func InitMasterRouter() *gin.Engine {
-	r := gin.Default()
+	r := gin.New()
+	InitProxy(r)
+	// 请求 ID 及访问日志
+	r.Use(middleware.RequestID(), middleware.AccessLog(), gin.Recovery())
+	// 监控指标，需在静态资源中间件前注册
+	InitMetrics(r)
 
 	/*
 		静态资源
@@ -139,6 +177,8 @@ func InitMasterRouter() *gin.Engine {
 	}
 	// 用户会话
 	v3.Use(middleware.CurrentUser())
+	// 个人访问令牌
+	v3.Use(middleware.AccessTokenAuth())
 
 	// 禁止缓存
 	v3.Use(middleware.CacheControl())
@@ -200,6 +240,11 @@ func InitMasterRouter() *gin.Engine {
 				middleware.IsFunctionEnabled("authn_enabled"),
 				controllers.FinishLoginAuthn,
 			)
+			// OpenID Connect 登录初始化
+			user.GET("oidc",
+				middleware.IsFunctionEnabled("oidc_enabled"),
+				controllers.StartOIDCLogin,
+			)
 			// 获取用户主页展示用分享
 			user.GET("profile/:id",
 				middleware.HashID(hashid.UserID),
@@ -313,6 +358,13 @@ func InitMasterRouter() *gin.Engine {
 			// Google Drive related
 			gdrive := callback.Group("googledrive")
 			{
+				// 文件上传完成
+				gdrive.POST(
+					"finish/:sessionID",
+					middleware.UseUploadSession("googledrive"),
+					middleware.GoogleDriveCallbackAuth(),
+					controllers.GoogleDriveCallback,
+				)
 				// OAuth 完成
 				gdrive.GET(
 					"auth",
@@ -331,16 +383,24 @@ func InitMasterRouter() *gin.Engine {
 				middleware.UseUploadSession("s3"),
 				controllers.S3Callback,
 			)
+			// OpenID Connect 登录回调
+			callback.GET(
+				"oidc",
+				middleware.IsFunctionEnabled("oidc_enabled"),
+				controllers.OIDCCallback,
+			)
 		}
 
 		// 分享相关
 		share := v3.Group("share", middleware.ShareAvailable())
 		{
 			// 获取分享
-			share.GET("info/:id", controllers.GetShare)
+			share.GET("info/:id", middleware.ShareUnlockCaptcha(), controllers.GetShare)
 			// 创建文件下载会话
 			share.PUT("download/:id",
 				middleware.CheckShareUnlocked(),
+				middleware.ShareContentVisible(),
+				middleware.SharePermission(model.SharePermissionDownload),
 				middleware.BeforeShareDownload(),
 				controllers.GetShareDownload,
 			)
@@ -348,6 +408,7 @@ func InitMasterRouter() *gin.Engine {
 			share.GET("preview/:id",
 				middleware.CSRFCheck(),
 				middleware.CheckShareUnlocked(),
+				middleware.ShareContentVisible(),
 				middleware.ShareCanPreview(),
 				middleware.BeforeShareDownload(),
 				controllers.PreviewShare,
@@ -355,6 +416,7 @@ func InitMasterRouter() *gin.Engine {
 			// 取得Office文档预览地址
 			share.GET("doc/:id",
 				middleware.CheckShareUnlocked(),
+				middleware.ShareContentVisible(),
 				middleware.ShareCanPreview(),
 				middleware.BeforeShareDownload(),
 				controllers.GetShareDocPreview,
@@ -362,36 +424,85 @@ func InitMasterRouter() *gin.Engine {
 			// 获取文本文件内容
 			share.GET("content/:id",
 				middleware.CheckShareUnlocked(),
+				middleware.ShareContentVisible(),
 				middleware.BeforeShareDownload(),
 				controllers.PreviewShareText,
 			)
 			// 分享目录列文件
 			share.GET("list/:id/*path",
 				middleware.CheckShareUnlocked(),
+				middleware.ShareContentVisible(),
 				controllers.ListSharedFolder,
 			)
 			// 分享目录搜索
 			share.GET("search/:id/:type/:keywords",
 				middleware.CheckShareUnlocked(),
+				middleware.ShareContentVisible(),
 				controllers.SearchSharedFolder,
 			)
 			// 归档打包下载
 			share.POST("archive/:id",
 				middleware.CheckShareUnlocked(),
+				middleware.ShareContentVisible(),
+				middleware.SharePermission(model.SharePermissionDownload),
 				middleware.BeforeShareDownload(),
 				controllers.ArchiveShare,
 			)
+			// 转存分享
+			share.POST("save/:id",
+				middleware.ShareCanSave(),
+				middleware.CheckShareUnlocked(),
+				middleware.ShareContentVisible(),
+				middleware.SharePermission(model.SharePermissionDownload),
+				middleware.BeforeShareDownload(),
+				controllers.SaveShare,
+			)
 			// 获取README文本文件内容
 			share.GET("readme/:id",
 				middleware.CheckShareUnlocked(),
+				middleware.ShareContentVisible(),
 				controllers.PreviewShareReadme,
 			)
 			// 获取缩略图
 			share.GET("thumb/:id/:file",
 				middleware.CheckShareUnlocked(),
+				middleware.ShareContentVisible(),
 				middleware.ShareCanPreview(),
 				controllers.ShareThumb,
 			)
+			// 创建向收集文件分享上传的会话
+			share.PUT("upload/:id",
+				middleware.CheckShareUnlocked(),
+				middleware.ShareCanUpload(),
+				controllers.CreateShareUploadSession,
+			)
+			// 向收集文件分享上传本机策略分片
+			share.POST("upload/:id/:sessionId/:index",
+				middleware.CheckShareUnlocked(),
+				middleware.ShareCanUpload(),
+				controllers.ShareUpload,
+			)
+			// 取消向收集文件分享的上传
+			share.DELETE("upload/:id/:sessionId",
+				middleware.CheckShareUnlocked(),
+				middleware.ShareCanUpload(),
+				controllers.DeleteShareUploadSession,
+			)
+			// 在分享目录中创建目录
+			share.PUT("directory/:id",
+				middleware.SharePermission(model.SharePermissionEdit),
+				controllers.CreateSharedDirectory,
+			)
+			// 重命名分享目录中的对象
+			share.POST("rename/:id",
+				middleware.SharePermission(model.SharePermissionEdit),
+				controllers.RenameSharedObject,
+			)
+			// 删除分享目录中的对象
+			share.DELETE("object/:id",
+				middleware.SharePermission(model.SharePermissionEdit),
+				controllers.DeleteSharedObjects,
+			)
 			// 搜索公共分享
 			v3.Group("share").GET("search", controllers.SearchShare)
 		}
@@ -523,6 +634,10 @@ func InitMasterRouter() *gin.Engine {
 					share.POST("list", controllers.AdminListShare)
 					// 删除
 					share.POST("delete", controllers.AdminDeleteShare)
+					// 列出访问记录
+					share.POST("access", controllers.AdminListShareAccess)
+					// 每日访问统计
+					share.POST("access/daily", controllers.AdminGetShareAccessDaily)
 				}
 
 				download := admin.Group("download")
@@ -539,8 +654,14 @@ func InitMasterRouter() *gin.Engine {
 					task.POST("list", controllers.AdminListTask)
 					// 删除
 					task.POST("delete", controllers.AdminDeleteTask)
+					// 取消
+					task.POST("cancel", controllers.AdminCancelTask)
+					// 重试
+					task.POST("retry", controllers.AdminRetryTask)
 					// 新建文件导入任务
 					task.POST("import", controllers.AdminCreateImportTask)
+					// 新建存储策略迁移任务
+					task.POST("migrate", controllers.AdminCreateMigrateTask)
 				}
 
 				node := admin.Group("node")
@@ -559,6 +680,28 @@ func InitMasterRouter() *gin.Engine {
 					node.GET(":id", controllers.AdminGetNode)
 				}
 
+				audit := admin.Group("audit")
+				{
+					// 列出审计日志
+					audit.POST("list", controllers.AdminListAuditLog)
+					// 导出审计日志
+					audit.POST("export", controllers.AdminExportAuditLog)
+				}
+
+				webhook := admin.Group("webhook")
+				{
+					// 列出站点级事件推送订阅
+					webhook.GET("", controllers.AdminListWebhooks)
+					// 创建或更新站点级事件推送订阅
+					webhook.POST("", controllers.AdminSaveWebhook)
+					// 删除站点级事件推送订阅
+					webhook.DELETE(":id", controllers.AdminDeleteWebhook)
+					// 列出事件推送记录
+					webhook.GET(":id/deliveries", controllers.AdminListWebhookDeliveries)
+					// 重新推送事件
+					webhook.POST(":id/deliveries/:delivery/replay", controllers.AdminReplayWebhookDelivery)
+				}
+
 			}
 
 			// 用户
@@ -587,6 +730,10 @@ func InitMasterRouter() *gin.Engine {
 				{
 					// 任务队列
 					setting.GET("tasks", controllers.UserTasks)
+					// 取消任务
+					setting.POST("tasks/:id/cancel", controllers.CancelTask)
+					// 重试任务
+					setting.POST("tasks/:id/retry", controllers.RetryTask)
 					// 获取当前用户设定
 					setting.GET("", controllers.UserSetting)
 					// 从文件上传头像
@@ -597,6 +744,38 @@ func InitMasterRouter() *gin.Engine {
 					setting.PATCH(":option", controllers.UpdateOption)
 					// 获得二步验证初始化信息
 					setting.GET("2fa", controllers.UserInit2FA)
+					// 列出个人访问令牌
+					setting.GET("tokens", controllers.ListAccessTokens)
+					// 创建个人访问令牌
+					setting.POST("tokens", controllers.CreateAccessToken)
+					// 撤销个人访问令牌
+					setting.DELETE("tokens/:id", controllers.DeleteAccessToken)
+
+					webhooks := setting.Group("webhooks",
+						middleware.IsFunctionEnabled("webhook_enabled"))
+					{
+						// 列出事件推送订阅
+						webhooks.GET("", controllers.ListWebhooks)
+						// 创建或更新事件推送订阅
+						webhooks.POST("", controllers.SaveWebhook)
+						// 删除事件推送订阅
+						webhooks.DELETE(":id", controllers.DeleteWebhook)
+						// 列出事件推送记录
+						webhooks.GET(":id/deliveries", controllers.ListWebhookDeliveries)
+						// 重新推送事件
+						webhooks.POST(":id/deliveries/:delivery/replay", controllers.ReplayWebhookDelivery)
+					}
+
+					oidc := setting.Group("oidc",
+						middleware.IsFunctionEnabled("oidc_enabled"))
+					{
+						// 列出已绑定的外部身份
+						oidc.GET("", controllers.ListOIDCIdentities)
+						// 绑定外部身份
+						oidc.PUT("", controllers.StartOIDCLink)
+						// 解除外部身份绑定
+						oidc.DELETE("", controllers.UnlinkOIDCIdentity)
+					}
 				}
 			}
 
@@ -639,6 +818,23 @@ func InitMasterRouter() *gin.Engine {
 				file.POST("decompress", controllers.Decompress)
 				// 创建文件解压缩任务
 				file.GET("search/:type/:keywords", controllers.SearchFile)
+
+				// 历史版本
+				version := file.Group("version/:id")
+				{
+					// 列出文件的历史版本
+					version.GET("", controllers.ListFileVersions)
+					// 创建历史版本下载会话
+					version.PUT(":version/download", controllers.CreateVersionDownloadSession)
+					// 预览历史版本
+					version.GET(":version/preview", middleware.Sandbox(), controllers.PreviewFileVersion)
+					// 获取历史版本的文本内容
+					version.GET(":version/content", middleware.Sandbox(), controllers.PreviewFileVersionText)
+					// 还原为历史版本
+					version.POST(":version/restore", controllers.RestoreFileVersion)
+					// 删除历史版本
+					version.DELETE(":version", controllers.DeleteFileVersion)
+				}
 			}
 
 			// 离线下载任务
@@ -682,6 +878,17 @@ func InitMasterRouter() *gin.Engine {
 				object.GET("property/:id", controllers.GetProperty)
 			}
 
+			// 回收站
+			trash := auth.Group("trash")
+			{
+				// 列出回收站中的对象
+				trash.GET("", controllers.ListTrash)
+				// 还原对象
+				trash.POST("restore", controllers.RestoreTrash)
+				// 彻底删除对象
+				trash.DELETE("", controllers.DeleteTrash)
+			}
+
 			// 分享
 			share := auth.Group("share")
 			{
@@ -689,6 +896,24 @@ func InitMasterRouter() *gin.Engine {
 				share.POST("", controllers.CreateShare)
 				// 列出我的分享
 				share.GET("", controllers.ListShare)
+				// 列出他人授权给我的分享
+				share.GET("received", controllers.ListReceivedShare)
+				// 列出分享的访问记录
+				share.GET(":id/access", controllers.ListShareAccess)
+				// 按天统计分享的访问
+				share.GET(":id/access/daily", controllers.GetShareAccessDaily)
+				// 列出内部分享的授权
+				share.GET(":id/grants",
+					middleware.ShareAvailable(),
+					middleware.ShareOwner(),
+					controllers.ListShareGrants,
+				)
+				// 设置内部分享的授权
+				share.PUT(":id/grants",
+					middleware.ShareAvailable(),
+					middleware.ShareOwner(),
+					controllers.UpdateShareGrants,
+				)
 				// 更新分享属性
 				share.PATCH(":id",
 					middleware.ShareAvailable(),
@@ -730,25 +955,26 @@ func InitMasterRouter() *gin.Engine {
 	}
 
 	// 初始化WebDAV相关路由
-	initWebDAV(r.Group("dav"))
+	initWebDAV(r.Group("dav"), controllers.ServeWebDAV)
+	initWebDAV(r.Group("dav-shared/:id"), controllers.ServeSharedWebDAV)
 	return r
 }
 
 // initWebDAV 初始化WebDAV相关路由
-func initWebDAV(group *gin.RouterGroup) {
+func initWebDAV(group *gin.RouterGroup, serve gin.HandlerFunc) {
 	{
 		group.Use(middleware.WebDAVAuth())
 
-		group.Any("/*path", controllers.ServeWebDAV)
-		group.Any("", controllers.ServeWebDAV)
-		group.Handle("PROPFIND", "/*path", controllers.ServeWebDAV)
-		group.Handle("PROPFIND", "", controllers.ServeWebDAV)
-		group.Handle("MKCOL", "/*path", controllers.ServeWebDAV)
-		group.Handle("LOCK", "/*path", controllers.ServeWebDAV)
-		group.Handle("UNLOCK", "/*path", controllers.ServeWebDAV)
-		group.Handle("PROPPATCH", "/*path", controllers.ServeWebDAV)
-		group.Handle("COPY", "/*path", controllers.ServeWebDAV)
-		group.Handle("MOVE", "/*path", controllers.ServeWebDAV)
+		group.Any("/*path", serve)
+		group.Any("", serve)
+		group.Handle("PROPFIND", "/*path", serve)
+		group.Handle("PROPFIND", "", serve)
+		group.Handle("MKCOL", "/*path", serve)
+		group.Handle("LOCK", "/*path", serve)
+		group.Handle("UNLOCK", "/*path", serve)
+		group.Handle("PROPPATCH", "/*path", serve)
+		group.Handle("COPY", "/*path", serve)
+		group.Handle("MOVE", "/*path", serve)
 
 	}
 }
@@ -7,6 +7,7 @@ import (
 	"testing"
 
 	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/gin-gonic/gin"
 	"github.com/jinzhu/gorm"
 	"github.com/stretchr/testify/assert"
 )
@@ -249,3 +250,53 @@ func TestSiteConfigRoute(t *testing.T) {
 		},
 	}).UpdateColumn("name", "siteName")
 }
+
+func TestInitProxy(t *testing.T) {
+	asserts := assert.New(t)
+	defer func() {
+		conf.SystemConfig.TrustedProxies = nil
+	}()
+
+	clientIP := func() string {
+		var ip string
+		r := gin.New()
+		InitProxy(r)
+		r.GET("/", func(c *gin.Context) { ip = c.ClientIP() })
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		req.Header.Set("X-Forwarded-For", "1.2.3.4")
+		r.ServeHTTP(httptest.NewRecorder(), req)
+		return ip
+	}
+
+	// 未配置可信代理时不信任请求头
+	asserts.Equal("10.0.0.1", clientIP())
+
+	// 来自可信代理
+	conf.SystemConfig.TrustedProxies = []string{"10.0.0.0/8"}
+	asserts.Equal("1.2.3.4", clientIP())
+}
+
+func TestInitMetrics(t *testing.T) {
+	asserts := assert.New(t)
+	defer func() {
+		conf.MetricsConfig.Enabled = false
+		conf.MetricsConfig.Token = ""
+	}()
+
+	status := func() int {
+		r := gin.New()
+		InitMetrics(r)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+		return w.Code
+	}
+
+	// 未配置令牌及独立监听地址时不挂载
+	conf.MetricsConfig.Enabled = true
+	asserts.Equal(http.StatusNotFound, status())
+
+	// 配置令牌
+	conf.MetricsConfig.Token = "secret"
+	asserts.Equal(http.StatusUnauthorized, status())
+}
@@ -0,0 +1,138 @@
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+// auditLogExportBatch 导出审计日志时每次查询的条目数
+const auditLogExportBatch = 1000
+
+var (
+	// auditLogConditionFields 可精确匹配的审计日志字段
+	auditLogConditionFields = map[string]bool{
+		"user_id": true, "actor": true, "ip": true, "action": true,
+		"target_type": true, "target_id": true, "result": true,
+	}
+	// auditLogSearchFields 可模糊搜索的审计日志字段
+	auditLogSearchFields = map[string]bool{
+		"actor": true, "ip": true, "action": true, "target": true, "detail": true, "user_agent": true,
+	}
+)
+
+// AuditLogFilter 审计日志筛选条件
+type AuditLogFilter struct {
+	Conditions map[string]string `json:"conditions"`
+	Searches   map[string]string `json:"searches"`
+	From       *time.Time        `json:"from"`
+	To         *time.Time        `json:"to"`
+}
+
+// AuditLogListService 审计日志列表服务
+type AuditLogListService struct {
+	AuditLogFilter
+	Page     int `json:"page" binding:"min=1,required"`
+	PageSize int `json:"page_size" binding:"min=1,max=1000,required"`
+}
+
+// query 根据筛选条件构建查询
+func (filter *AuditLogFilter) query() (*gorm.DB, error) {
+	tx := model.DB.Model(&model.AuditLog{})
+
+	for k, v := range filter.Conditions {
+		if !auditLogConditionFields[k] {
+			return nil, fmt.Errorf("unknown condition field %q", k)
+		}
+		tx = tx.Where(k+" = ?", v)
+	}
+
+	if len(filter.Searches) > 0 {
+		search := make([]string, 0, len(filter.Searches))
+		args := make([]interface{}, 0, len(filter.Searches))
+		for k, v := range filter.Searches {
+			if !auditLogSearchFields[k] {
+				return nil, fmt.Errorf("unknown search field %q", k)
+			}
+			search = append(search, k+" like ?")
+			args = append(args, "%"+v+"%")
+		}
+		tx = tx.Where(strings.Join(search, " OR "), args...)
+	}
+
+	if filter.From != nil {
+		tx = tx.Where("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		tx = tx.Where("created_at < ?", *filter.To)
+	}
+
+	return tx, nil
+}
+
+// List 列出审计日志，新的在前
+func (service *AuditLogListService) List() serializer.Response {
+	tx, err := service.query()
+	if err != nil {
+		return serializer.ParamErr(err.Error(), nil)
+	}
+
+	// 计算总数用于分页
+	total := 0
+	tx.Count(&total)
+
+	var res []model.AuditLog
+	if err := tx.Order("id desc").Limit(service.PageSize).Offset((service.Page - 1) * service.PageSize).
+		Find(&res).Error; err != nil {
+		return serializer.DBErr("Failed to list audit logs", err)
+	}
+
+	return serializer.Response{Data: map[string]interface{}{
+		"total": total,
+		"items": res,
+	}}
+}
+
+// Export 以 JSON Lines 格式导出符合条件的全部审计日志，旧的在前
+func (filter *AuditLogFilter) Export(c *gin.Context) serializer.Response {
+	tx, err := filter.query()
+	if err != nil {
+		return serializer.ParamErr(err.Error(), nil)
+	}
+
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="audit-%s.jsonl"`, time.Now().Format("20060102150405")))
+	c.Status(200)
+
+	encoder := json.NewEncoder(c.Writer)
+	var lastID uint
+	for {
+		var batch []model.AuditLog
+		if err := tx.Where("id > ?", lastID).Order("id").Limit(auditLogExportBatch).Find(&batch).Error; err != nil {
+			// 响应已开始发送，只能中断输出
+			util.Log().WithContext(c).Warning("Failed to export audit logs: %s", err)
+			break
+		}
+
+		for _, log := range batch {
+			if err := encoder.Encode(log); err != nil {
+				return serializer.Response{}
+			}
+		}
+
+		if len(batch) < auditLogExportBatch {
+			break
+		}
+		lastID = batch[len(batch)-1].ID
+		c.Writer.Flush()
+	}
+
+	return serializer.Response{}
+}
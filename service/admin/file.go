@@ -2,12 +2,16 @@ package admin
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"strings"
 
 	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/audit"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
 	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
 	"github.com/cloudreve/Cloudreve/v3/service/explorer"
 	"github.com/gin-gonic/gin"
 )
@@ -92,6 +96,14 @@ func (service *FileBatchService) Delete(c *gin.Context) serializer.Response {
 		return serializer.DBErr("Failed to list files for deleting", err)
 	}
 
+	target, _ := json.Marshal(map[string][]uint{"files": service.ID})
+	audit.Record(c, audit.Event{
+		Action:     audit.ActionAdminFileDelete,
+		TargetType: audit.TargetObjects,
+		Target:     string(target),
+		Detail:     fmt.Sprintf("force=%t unlink=%t", service.Force, service.UnlinkOnly),
+	})
+
 	// 根据用户分组
 	userFile := make(map[uint][]model.File)
 	for i := 0; i < len(files); i++ {
@@ -102,6 +114,7 @@ func (service *FileBatchService) Delete(c *gin.Context) serializer.Response {
 	}
 
 	// 异步执行删除
+	ctx := util.RequestContext(c)
 	go func(files map[uint][]model.File) {
 		for uid, file := range files {
 			var (
@@ -129,7 +142,7 @@ func (service *FileBatchService) Delete(c *gin.Context) serializer.Response {
 			}
 
 			// 执行删除
-			fs.Delete(context.Background(), []uint{}, ids, service.Force, service.UnlinkOnly)
+			fs.Delete(ctx, []uint{}, ids, service.Force, service.UnlinkOnly)
 			fs.Recycle()
 		}
 	}(userFile)
@@ -146,7 +159,7 @@ func (service *FileService) Get(c *gin.Context) serializer.Response {
 		return serializer.Err(serializer.CodeFileNotFound, "", err)
 	}
 
-	ctx := context.WithValue(context.Background(), fsctx.FileModelCtx, &file[0])
+	ctx := context.WithValue(util.RequestContext(c), fsctx.FileModelCtx, &file[0])
 	var subService explorer.FileIDService
 	res := subService.PreviewContent(ctx, c, false)
 
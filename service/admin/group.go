@@ -2,7 +2,9 @@ package admin
 
 import (
 	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/audit"
 	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/gin-gonic/gin"
 	"strconv"
 )
 
@@ -27,7 +29,7 @@ func (service *GroupService) Get() serializer.Response {
 }
 
 // Delete 删除用户组
-func (service *GroupService) Delete() serializer.Response {
+func (service *GroupService) Delete(c *gin.Context) serializer.Response {
 	// 查找用户组
 	group, err := model.GetGroupByID(service.ID)
 	if err != nil {
@@ -49,12 +51,18 @@ func (service *GroupService) Delete() serializer.Response {
 	}
 
 	model.DB.Delete(&group)
+	audit.Record(c, audit.Event{
+		Action:     audit.ActionAdminGroupDelete,
+		TargetType: audit.TargetGroup,
+		TargetID:   group.ID,
+		Target:     group.Name,
+	})
 
 	return serializer.Response{}
 }
 
 // Add 添加用户组
-func (service *AddGroupService) Add() serializer.Response {
+func (service *AddGroupService) Add(c *gin.Context) serializer.Response {
 	if service.Group.ID > 0 {
 		if err := model.DB.Save(&service.Group).Error; err != nil {
 			return serializer.DBErr("Failed to save group record", err)
@@ -65,6 +73,13 @@ func (service *AddGroupService) Add() serializer.Response {
 		}
 	}
 
+	audit.Record(c, audit.Event{
+		Action:     audit.ActionAdminGroupSave,
+		TargetType: audit.TargetGroup,
+		TargetID:   service.Group.ID,
+		Target:     service.Group.Name,
+	})
+
 	return serializer.Response{Data: service.Group.ID}
 }
 
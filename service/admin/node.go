@@ -2,8 +2,10 @@ package admin
 
 import (
 	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/audit"
 	"github.com/cloudreve/Cloudreve/v3/pkg/cluster"
 	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/gin-gonic/gin"
 	"strings"
 )
 
@@ -13,7 +15,7 @@ type AddNodeService struct {
 }
 
 // Add 添加节点
-func (service *AddNodeService) Add() serializer.Response {
+func (service *AddNodeService) Add(c *gin.Context) serializer.Response {
 	if service.Node.ID > 0 {
 		if err := model.DB.Save(&service.Node).Error; err != nil {
 			return serializer.DBErr("Failed to save node record", err)
@@ -28,6 +30,13 @@ func (service *AddNodeService) Add() serializer.Response {
 		cluster.Default.Add(&service.Node)
 	}
 
+	audit.Record(c, audit.Event{
+		Action:     audit.ActionAdminNodeSave,
+		TargetType: audit.TargetNode,
+		TargetID:   service.Node.ID,
+		Target:     service.Node.Name,
+	})
+
 	return serializer.Response{Data: service.Node.ID}
 }
 
@@ -111,7 +120,7 @@ type NodeService struct {
 }
 
 // Delete 删除节点
-func (service *NodeService) Delete() serializer.Response {
+func (service *NodeService) Delete(c *gin.Context) serializer.Response {
 	// 查找用户组
 	node, err := model.GetNodeByID(service.ID)
 	if err != nil {
@@ -128,6 +137,13 @@ func (service *NodeService) Delete() serializer.Response {
 		return serializer.DBErr("Failed to delete node record", err)
 	}
 
+	audit.Record(c, audit.Event{
+		Action:     audit.ActionAdminNodeDelete,
+		TargetType: audit.TargetNode,
+		TargetID:   node.ID,
+		Target:     node.Name,
+	})
+
 	return serializer.Response{}
 }
 
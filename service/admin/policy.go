@@ -2,7 +2,6 @@ package admin
 
 import (
 	"bytes"
-	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/googledrive"
@@ -15,6 +14,7 @@ import (
 	"time"
 
 	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/audit"
 	"github.com/cloudreve/Cloudreve/v3/pkg/auth"
 	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
 	"github.com/cloudreve/Cloudreve/v3/pkg/conf"
@@ -57,7 +57,7 @@ type PolicyService struct {
 }
 
 // Delete 删除存储策略
-func (service *PolicyService) Delete() serializer.Response {
+func (service *PolicyService) Delete(c *gin.Context) serializer.Response {
 	// 禁止删除默认策略
 	if service.ID == 1 {
 		return serializer.Err(serializer.CodeDeleteDefaultPolicy, "", nil)
@@ -90,6 +90,12 @@ func (service *PolicyService) Delete() serializer.Response {
 
 	model.DB.Delete(&policy)
 	policy.ClearCache()
+	audit.Record(c, audit.Event{
+		Action:     audit.ActionAdminPolicyDelete,
+		TargetType: audit.TargetPolicy,
+		TargetID:   policy.ID,
+		Target:     policy.Name,
+	})
 
 	return serializer.Response{}
 }
@@ -123,7 +129,7 @@ func (service *PolicyService) GetOAuth(c *gin.Context, policyType string) serial
 			return serializer.Err(serializer.CodeInternalSetting, "Failed to initialize OneDrive client", err)
 		}
 
-		redirect = client.OAuthURL(context.Background(), []string{
+		redirect = client.OAuthURL(util.RequestContext(c), []string{
 			"offline_access",
 			"files.readwrite.all",
 		})
@@ -133,7 +139,7 @@ func (service *PolicyService) GetOAuth(c *gin.Context, policyType string) serial
 			return serializer.Err(serializer.CodeInternalSetting, "Failed to initialize Google Drive client", err)
 		}
 
-		redirect = client.OAuthURL(context.Background(), googledrive.RequiredScope)
+		redirect = client.OAuthURL(util.RequestContext(c), googledrive.RequiredScope)
 	}
 
 	// Delete token cache
@@ -275,7 +281,7 @@ func (service *SlaveTestService) Test() serializer.Response {
 }
 
 // Add 添加存储策略
-func (service *AddPolicyService) Add() serializer.Response {
+func (service *AddPolicyService) Add(c *gin.Context) serializer.Response {
 	if service.Policy.Type != "local" && service.Policy.Type != "remote" {
 		service.Policy.DirNameRule = strings.TrimPrefix(service.Policy.DirNameRule, "/")
 	}
@@ -291,6 +297,12 @@ func (service *AddPolicyService) Add() serializer.Response {
 	}
 
 	service.Policy.ClearCache()
+	audit.Record(c, audit.Event{
+		Action:     audit.ActionAdminPolicySave,
+		TargetType: audit.TargetPolicy,
+		TargetID:   service.Policy.ID,
+		Target:     service.Policy.Name,
+	})
 
 	return serializer.Response{Data: service.Policy.ID}
 }
@@ -1,9 +1,11 @@
 package admin
 
 import (
+	"encoding/json"
 	"strings"
 
 	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/audit"
 	"github.com/cloudreve/Cloudreve/v3/pkg/hashid"
 	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
 	"github.com/gin-gonic/gin"
@@ -19,6 +21,13 @@ func (service *ShareBatchService) Delete(c *gin.Context) serializer.Response {
 	if err := model.DB.Where("id in (?)", service.ID).Delete(&model.Share{}).Error; err != nil {
 		return serializer.DBErr("Failed to delete share record", err)
 	}
+
+	target, _ := json.Marshal(service.ID)
+	audit.Record(c, audit.Event{
+		Action:     audit.ActionAdminShareDelete,
+		TargetType: audit.TargetShare,
+		Target:     string(target),
+	})
 	return serializer.Response{}
 }
 
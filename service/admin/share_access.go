@@ -0,0 +1,66 @@
+package admin
+
+import (
+	"fmt"
+	"time"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/cloudreve/Cloudreve/v3/service/share"
+)
+
+// shareAccessConditionFields 可精确匹配的分享访问记录字段
+var shareAccessConditionFields = map[string]bool{
+	"share_id": true, "user_id": true, "file_id": true, "ip": true, "country": true, "action": true,
+}
+
+// ShareAccessListService 分享访问记录列表服务
+type ShareAccessListService struct {
+	Page       int               `json:"page" binding:"min=1,required"`
+	PageSize   int               `json:"page_size" binding:"min=1,max=1000,required"`
+	Conditions map[string]string `json:"conditions"`
+	From       *time.Time        `json:"from"`
+	To         *time.Time        `json:"to"`
+}
+
+// ShareAccessDailyService 全部分享的每日访问统计服务
+type ShareAccessDailyService struct {
+	Days int `json:"days" binding:"min=1,max=366,required"`
+}
+
+// List 列出分享访问记录，新的在前
+func (service *ShareAccessListService) List() serializer.Response {
+	tx := model.DB.Model(&model.ShareAccess{})
+	for k, v := range service.Conditions {
+		if !shareAccessConditionFields[k] {
+			return serializer.ParamErr(fmt.Sprintf("unknown condition field %q", k), nil)
+		}
+		tx = tx.Where(k+" = ?", v)
+	}
+	if service.From != nil {
+		tx = tx.Where("created_at >= ?", *service.From)
+	}
+	if service.To != nil {
+		tx = tx.Where("created_at < ?", *service.To)
+	}
+
+	// 计算总数用于分页
+	total := 0
+	tx.Count(&total)
+
+	var res []model.ShareAccess
+	if err := tx.Order("id desc").Limit(service.PageSize).Offset((service.Page - 1) * service.PageSize).
+		Find(&res).Error; err != nil {
+		return serializer.DBErr("Failed to list share accesses", err)
+	}
+
+	return serializer.Response{Data: map[string]interface{}{
+		"total": total,
+		"items": res,
+	}}
+}
+
+// Daily 统计全部分享最近若干天的访问
+func (service *ShareAccessDailyService) Daily() serializer.Response {
+	return share.ShareAccessDaily(0, service.Days)
+}
@@ -2,9 +2,11 @@ package admin
 
 import (
 	"encoding/gob"
+	"strings"
 	"time"
 
 	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/audit"
 	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
 	"github.com/cloudreve/Cloudreve/v3/pkg/conf"
 	"github.com/cloudreve/Cloudreve/v3/pkg/email"
@@ -58,7 +60,7 @@ func (service *BatchSettingGet) Get() serializer.Response {
 }
 
 // Change 批量更改站点设定
-func (service *BatchSettingChangeService) Change() serializer.Response {
+func (service *BatchSettingChangeService) Change(c *gin.Context) serializer.Response {
 	cacheClean := make([]string, 0, len(service.Options))
 	tx := model.DB.Begin()
 
@@ -78,6 +80,11 @@ func (service *BatchSettingChangeService) Change() serializer.Response {
 	}
 
 	cache.Deletes(cacheClean, "setting_")
+	audit.Record(c, audit.Event{
+		Action:     audit.ActionAdminSettingChange,
+		TargetType: audit.TargetSetting,
+		Target:     strings.Join(cacheClean, ","),
+	})
 
 	return serializer.Response{}
 }
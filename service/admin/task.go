@@ -30,7 +30,40 @@ func (service *ImportTaskService) Create(c *gin.Context, user *model.User) seria
 	if err != nil {
 		return serializer.DBErr("Failed to create task record.", err)
 	}
-	task.TaskPoll.Submit(job)
+	task.Submit(c, task.TaskPoll, job)
+	return serializer.Response{}
+}
+
+// MigrateTaskService 存储策略迁移任务
+type MigrateTaskService struct {
+	SrcPolicyID uint `json:"src_policy_id" binding:"required"`
+	DstPolicyID uint `json:"dst_policy_id" binding:"required,nefield=SrcPolicyID"`
+	UserID      uint `json:"user_id" binding:"required_with=FolderID"`
+	GroupID     uint `json:"group_id"`
+	FolderID    uint `json:"folder_id"`
+	VerifyHash  bool `json:"verify_hash"`
+}
+
+// Create 新建存储策略迁移任务
+func (service *MigrateTaskService) Create(c *gin.Context, user *model.User) serializer.Response {
+	for _, id := range []uint{service.SrcPolicyID, service.DstPolicyID} {
+		if _, err := model.GetPolicyByID(id); err != nil {
+			return serializer.Err(serializer.CodePolicyNotExist, "", err)
+		}
+	}
+
+	job, err := task.NewMigrateTask(user.ID, task.MigrateProps{
+		SrcPolicyID: service.SrcPolicyID,
+		DstPolicyID: service.DstPolicyID,
+		UserID:      service.UserID,
+		GroupID:     service.GroupID,
+		FolderID:    service.FolderID,
+		VerifyHash:  service.VerifyHash,
+	})
+	if err != nil {
+		return serializer.DBErr("Failed to create task record.", err)
+	}
+	task.Submit(c, task.TaskPoll, job)
 	return serializer.Response{}
 }
 
@@ -50,6 +83,37 @@ func (service *TaskBatchService) DeleteGeneral(c *gin.Context) serializer.Respon
 	return serializer.Response{}
 }
 
+// Cancel 批量取消尚未开始执行的常规任务
+func (service *TaskBatchService) Cancel(c *gin.Context) serializer.Response {
+	return service.each(func(record *model.Task) error {
+		return task.Cancel(record)
+	})
+}
+
+// Retry 批量重试失败或已取消的常规任务
+func (service *TaskBatchService) Retry(c *gin.Context) serializer.Response {
+	return service.each(func(record *model.Task) error {
+		return task.Retry(task.TaskPoll, record)
+	})
+}
+
+// each 对每个任务执行操作，返回操作失败的任务
+func (service *TaskBatchService) each(fn func(record *model.Task) error) serializer.Response {
+	var tasks []model.Task
+	if err := model.DB.Where("id in (?)", service.ID).Find(&tasks).Error; err != nil {
+		return serializer.DBErr("Failed to query task records", err)
+	}
+
+	failed := make(map[uint]string)
+	for i := range tasks {
+		if err := fn(&tasks[i]); err != nil {
+			failed[tasks[i].ID] = err.Error()
+		}
+	}
+
+	return serializer.Response{Data: failed}
+}
+
 // Tasks 列出常规任务
 func (service *AdminListService) Tasks() serializer.Response {
 	var res []model.Task
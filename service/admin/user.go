@@ -1,12 +1,15 @@
 package admin
 
 import (
-	"context"
+	"fmt"
 	"strings"
 
 	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/audit"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem"
 	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+	"github.com/gin-gonic/gin"
 )
 
 // AddUserService 用户添加服务
@@ -26,7 +29,7 @@ type UserBatchService struct {
 }
 
 // Ban 封禁/解封用户
-func (service *UserService) Ban() serializer.Response {
+func (service *UserService) Ban(c *gin.Context) serializer.Response {
 	user, err := model.GetUserByID(service.ID)
 	if err != nil {
 		return serializer.Err(serializer.CodeUserNotFound, "", err)
@@ -42,11 +45,19 @@ func (service *UserService) Ban() serializer.Response {
 		user.SetStatus(model.Active)
 	}
 
+	audit.Record(c, audit.Event{
+		Action:     audit.ActionAdminUserBan,
+		TargetType: audit.TargetUser,
+		TargetID:   user.ID,
+		Target:     user.Email,
+		Detail:     fmt.Sprintf("status=%d", user.Status),
+	})
+
 	return serializer.Response{Data: user.Status}
 }
 
 // Delete 删除用户
-func (service *UserBatchService) Delete() serializer.Response {
+func (service *UserBatchService) Delete(c *gin.Context) serializer.Response {
 	for _, uid := range service.ID {
 		user, err := model.GetUserByID(uid)
 		if err != nil {
@@ -61,12 +72,17 @@ func (service *UserBatchService) Delete() serializer.Response {
 		// 删除与此用户相关的所有资源
 
 		fs, err := filesystem.NewFileSystem(&user)
+		// 清空回收站
+		if trashes, err := model.GetTrashByUID(uid); err == nil {
+			fs.PurgeTrash(util.RequestContext(c), trashes)
+		}
+
 		// 删除所有文件
 		root, err := fs.User.Root()
 		if err != nil {
 			return serializer.Err(serializer.CodeInternalSetting, "User's root folder not exist", err)
 		}
-		fs.Delete(context.Background(), []uint{root.ID}, []uint{}, false, false)
+		fs.Delete(util.RequestContext(c), []uint{root.ID}, []uint{}, false, false)
 
 		// 删除相关任务
 		model.DB.Where("user_id = ?", uid).Delete(&model.Download{})
@@ -78,8 +94,23 @@ func (service *UserBatchService) Delete() serializer.Response {
 		// 删除WebDAV账号
 		model.DB.Where("user_id = ?", uid).Delete(&model.Webdav{})
 
+		// 删除个人访问令牌
+		model.DB.Where("user_id = ?", uid).Delete(&model.AccessToken{})
+
+		// 删除事件推送订阅
+		model.DeleteWebhooksByUserID(uid)
+
+		// 删除外部身份绑定
+		model.DB.Unscoped().Where("user_id = ?", uid).Delete(&model.UserIdentity{})
+
 		// 删除此用户
 		model.DB.Unscoped().Delete(user)
+		audit.Record(c, audit.Event{
+			Action:     audit.ActionAdminUserDelete,
+			TargetType: audit.TargetUser,
+			TargetID:   user.ID,
+			Target:     user.Email,
+		})
 
 	}
 	return serializer.Response{}
@@ -96,7 +127,7 @@ func (service *UserService) Get() serializer.Response {
 }
 
 // Add 添加用户
-func (service *AddUserService) Add() serializer.Response {
+func (service *AddUserService) Add(c *gin.Context) serializer.Response {
 	if service.User.ID > 0 {
 
 		user, _ := model.GetUserByID(service.User.ID)
@@ -131,6 +162,13 @@ func (service *AddUserService) Add() serializer.Response {
 		}
 	}
 
+	audit.Record(c, audit.Event{
+		Action:     audit.ActionAdminUserSave,
+		TargetType: audit.TargetUser,
+		TargetID:   service.User.ID,
+		Target:     service.User.Email,
+	})
+
 	return serializer.Response{Data: service.User.ID}
 }
 
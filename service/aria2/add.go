@@ -11,6 +11,7 @@ import (
 	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
 	"github.com/cloudreve/Cloudreve/v3/pkg/util"
 	"github.com/gin-gonic/gin"
+	"strconv"
 )
 
 // AddURLService 添加URL离线下载服务
@@ -143,7 +144,13 @@ func Add(c *gin.Context, service *serializer.SlaveAria2Call) serializer.Response
 	siteID, _ := c.Get("MasterSiteID")
 	mq.GlobalMQ.SubscribeCallback(gid, func(message mq.Message) {
 		if err := cluster.DefaultController.SendNotification(siteID.(string), message.TriggeredBy, message); err != nil {
-			util.Log().Warning("Failed to send remote download task status change notifications: %s", err)
+			util.Log().WithContext(c).Warning("Failed to send remote download task status change notifications: %s", err)
+		}
+
+		// 任务结束后不再有后续事件，移除回调
+		switch message.Event {
+		case strconv.Itoa(common.Complete), strconv.Itoa(common.Error), strconv.Itoa(common.Canceled):
+			mq.GlobalMQ.UnsubscribeCallback(gid)
 		}
 	})
 
@@ -1,17 +1,19 @@
 package callback
 
 import (
-	"context"
+	"encoding/hex"
 	"fmt"
 	model "github.com/cloudreve/Cloudreve/v3/models"
 	"strings"
 
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/cos"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/googledrive"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/onedrive"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/driver/s3"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
 	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
 	"github.com/gin-gonic/gin"
 )
 
@@ -53,6 +55,11 @@ type OneDriveCallback struct {
 	Meta *onedrive.FileInfo
 }
 
+// GoogleDriveCallback Google Drive 客户端回调正文
+type GoogleDriveCallback struct {
+	Meta *googledrive.FileInfo
+}
+
 // COSCallback COS 客户端回调正文
 type COSCallback struct {
 	Bucket string `form:"bucket"`
@@ -91,6 +98,17 @@ func (service OneDriveCallback) GetBody() serializer.UploadCallback {
 	}
 }
 
+// GetBody 返回回调正文
+func (service GoogleDriveCallback) GetBody() serializer.UploadCallback {
+	var picInfo = "0,0"
+	if service.Meta.ImageMediaMetadata != nil {
+		picInfo = fmt.Sprintf("%d,%d", service.Meta.ImageMediaMetadata.Width, service.Meta.ImageMediaMetadata.Height)
+	}
+	return serializer.UploadCallback{
+		PicInfo: picInfo,
+	}
+}
+
 // GetBody 返回回调正文
 func (service COSCallback) GetBody() serializer.UploadCallback {
 	return serializer.UploadCallback{
@@ -135,6 +153,14 @@ func ProcessCallback(service CallbackProcessService, c *gin.Context) serializer.
 		LastModified: uploadSession.LastModified,
 	}
 
+	// 仅信任从机计算的内容摘要
+	hash := ""
+	if fs.Policy.Type == "remote" && len(callbackBody.Hash) == 64 {
+		if _, err := hex.DecodeString(callbackBody.Hash); err == nil {
+			hash = strings.ToLower(callbackBody.Hash)
+		}
+	}
+
 	// 占位符未扣除容量需要校验和扣除
 	if !fs.Policy.IsUploadPlaceholderWithSize() {
 		fs.Use("AfterUpload", filesystem.HookValidateCapacity)
@@ -142,8 +168,13 @@ func ProcessCallback(service CallbackProcessService, c *gin.Context) serializer.
 	}
 
 	fs.Use("AfterUpload", filesystem.HookPopPlaceholderToFile(callbackBody.PicInfo))
+	fs.Use("AfterUpload", filesystem.HookIndexBlob(uploadSession.Key, hash))
+	fs.Use("AfterUpload", filesystem.HookIndexContent)
+	if uploadSession.ShareID != 0 {
+		fs.Use("AfterUpload", filesystem.HookShareUploaded(uploadSession.ShareID))
+	}
 	fs.Use("AfterValidateFailed", filesystem.HookDeleteTempFile)
-	err = fs.Upload(context.Background(), &fileData)
+	err = fs.Upload(util.RequestContext(c), &fileData)
 	if err != nil {
 		return serializer.Err(serializer.CodeUploadFailed, err.Error(), err)
 	}
@@ -164,7 +195,7 @@ func (service *OneDriveCallback) PreProcess(c *gin.Context) serializer.Response
 	uploadSession := c.MustGet(filesystem.UploadSessionCtx).(*serializer.UploadSession)
 
 	// 获取文件信息
-	info, err := fs.Handler.(onedrive.Driver).Client.Meta(context.Background(), "", uploadSession.SavePath)
+	info, err := fs.Handler.(onedrive.Driver).Client.Meta(util.RequestContext(c), "", uploadSession.SavePath)
 	if err != nil {
 		return serializer.Err(serializer.CodeQueryMetaFailed, "", err)
 	}
@@ -180,7 +211,35 @@ func (service *OneDriveCallback) PreProcess(c *gin.Context) serializer.Response
 	}
 
 	if isSizeCheckFailed || !strings.EqualFold(info.GetSourcePath(), actualPath) {
-		fs.Handler.(onedrive.Driver).Client.Delete(context.Background(), []string{info.GetSourcePath()})
+		fs.Handler.(onedrive.Driver).Client.Delete(util.RequestContext(c), []string{info.GetSourcePath()})
+		return serializer.Err(serializer.CodeMetaMismatch, "", err)
+	}
+	service.Meta = info
+	return ProcessCallback(service, c)
+}
+
+// PreProcess 对Google Drive客户端回调进行预处理验证
+func (service *GoogleDriveCallback) PreProcess(c *gin.Context) serializer.Response {
+	// 创建文件系统
+	fs, err := filesystem.NewFileSystemFromCallback(c)
+	if err != nil {
+		return serializer.Err(serializer.CodeCreateFSError, "", err)
+	}
+	defer fs.Recycle()
+
+	// 获取回调会话
+	uploadSession := c.MustGet(filesystem.UploadSessionCtx).(*serializer.UploadSession)
+
+	// 获取文件信息
+	client := fs.Handler.(googledrive.Driver).Client
+	info, err := client.Meta(util.RequestContext(c), uploadSession.SavePath)
+	if err != nil {
+		return serializer.Err(serializer.CodeQueryMetaFailed, "", err)
+	}
+
+	// 验证与回调会话中是否一致
+	if uploadSession.Size != info.Size {
+		client.Delete(util.RequestContext(c), []string{uploadSession.SavePath})
 		return serializer.Err(serializer.CodeMetaMismatch, "", err)
 	}
 	service.Meta = info
@@ -200,7 +259,7 @@ func (service *COSCallback) PreProcess(c *gin.Context) serializer.Response {
 	uploadSession := c.MustGet(filesystem.UploadSessionCtx).(*serializer.UploadSession)
 
 	// 获取文件信息
-	info, err := fs.Handler.(cos.Driver).Meta(context.Background(), uploadSession.SavePath)
+	info, err := fs.Handler.(cos.Driver).Meta(util.RequestContext(c), uploadSession.SavePath)
 	if err != nil {
 		return serializer.Err(serializer.CodeMetaMismatch, "", err)
 	}
@@ -226,7 +285,7 @@ func (service *S3Callback) PreProcess(c *gin.Context) serializer.Response {
 	uploadSession := c.MustGet(filesystem.UploadSessionCtx).(*serializer.UploadSession)
 
 	// 获取文件信息
-	info, err := fs.Handler.(*s3.Driver).Meta(context.Background(), uploadSession.SavePath)
+	info, err := fs.Handler.(*s3.Driver).Meta(util.RequestContext(c), uploadSession.SavePath)
 	if err != nil {
 		return serializer.Err(serializer.CodeMetaMismatch, "", err)
 	}
@@ -253,7 +312,7 @@ func (service *UploadCallbackService) PreProcess(c *gin.Context) serializer.Resp
 
 	// 验证文件大小
 	if uploadSession.Size != service.Size {
-		fs.Handler.Delete(context.Background(), []string{uploadSession.SavePath})
+		fs.Handler.Delete(util.RequestContext(c), []string{uploadSession.SavePath})
 		return serializer.Err(serializer.CodeMetaMismatch, "", err)
 	}
 
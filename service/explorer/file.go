@@ -17,6 +17,7 @@ import (
 	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/metrics"
 	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
 	"github.com/cloudreve/Cloudreve/v3/pkg/wopi"
 	"github.com/gin-gonic/gin"
@@ -57,7 +58,7 @@ func (service *SingleFileService) Create(c *gin.Context) serializer.Response {
 	defer fs.Recycle()
 
 	// 上下文
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(util.RequestContext(c))
 	defer cancel()
 
 	// 给文件系统分配钩子
@@ -89,7 +90,7 @@ func (service *SlaveListService) List(c *gin.Context) serializer.Response {
 	}
 	defer fs.Recycle()
 
-	objects, err := fs.Handler.List(context.Background(), service.Path, service.Recursive)
+	objects, err := fs.Handler.List(util.RequestContext(c), service.Path, service.Recursive)
 	if err != nil {
 		return serializer.Err(serializer.CodeIOFailed, "Cannot list files", err)
 	}
@@ -157,7 +158,7 @@ func (service *FileAnonymousGetService) Download(ctx context.Context, c *gin.Con
 	}
 
 	// 发送文件
-	http.ServeContent(c.Writer, c.Request, service.Name, fs.FileTarget[0].UpdatedAt, rs)
+	http.ServeContent(metrics.ServedWriter(c.Writer, fs.PolicyType()), c.Request, service.Name, fs.FileTarget[0].UpdatedAt, rs)
 
 	return serializer.Response{
 		Code: 0,
@@ -338,7 +339,7 @@ func (service *DownloadService) Download(ctx context.Context, c *gin.Context) se
 	}
 
 	// 发送文件
-	http.ServeContent(c.Writer, c.Request, fs.FileTarget[0].Name, fs.FileTarget[0].UpdatedAt, rs)
+	http.ServeContent(metrics.ServedWriter(c.Writer, fs.PolicyType()), c.Request, fs.FileTarget[0].Name, fs.FileTarget[0].UpdatedAt, rs)
 
 	return serializer.Response{
 		Code: 0,
@@ -397,7 +398,7 @@ func (service *FileIDService) PreviewContent(ctx context.Context, c *gin.Context
 		c.Header("Cache-Control", "no-cache")
 	}
 
-	http.ServeContent(c.Writer, c.Request, fs.FileTarget[0].Name, fs.FileTarget[0].UpdatedAt, resp.Content)
+	http.ServeContent(metrics.ServedWriter(c.Writer, fs.PolicyType()), c.Request, fs.FileTarget[0].Name, fs.FileTarget[0].UpdatedAt, resp.Content)
 
 	return serializer.Response{
 		Code: 0,
@@ -407,7 +408,7 @@ func (service *FileIDService) PreviewContent(ctx context.Context, c *gin.Context
 // PutContent 更新文件内容
 func (service *FileIDService) PutContent(ctx context.Context, c *gin.Context) serializer.Response {
 	// 创建上下文
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(util.RequestContext(c))
 	defer cancel()
 
 	// 取得文件大小
@@ -439,26 +440,29 @@ func (service *FileIDService) PutContent(ctx context.Context, c *gin.Context) se
 	}
 	fileData.Name = originFile[0].Name
 
-	// 检查此文件是否有软链接
-	fileList, err := model.RemoveFilesWithSoftLinks([]model.File{originFile[0]})
-	if err == nil && len(fileList) == 0 {
-		// 如果包含软连接，应重新生成新文件副本，并更新source_name
-		originFile[0].SourceName = fs.GenerateSavePath(uploadCtx, &fileData)
-		fileData.Mode &= ^fsctx.Overwrite
-		fs.Use("AfterUpload", filesystem.HookUpdateSourceName)
-		fs.Use("AfterUploadCanceled", filesystem.HookUpdateSourceName)
-		fs.Use("AfterUploadCanceled", filesystem.HookCleanFileContent)
-		fs.Use("AfterUploadCanceled", filesystem.HookClearFileSize)
-		fs.Use("AfterValidateFailed", filesystem.HookUpdateSourceName)
-		fs.Use("AfterValidateFailed", filesystem.HookCleanFileContent)
-		fs.Use("AfterValidateFailed", filesystem.HookClearFileSize)
-	}
+	// 保留历史版本时，钩子由文件系统分配，原有内容被其他文件共用时同样保留
+	if !fs.UseVersionHooks(uploadCtx, &originFile[0], &fileData) {
+		// 检查此文件是否有软链接
+		fileList, err := model.RemoveFilesWithSoftLinks([]model.File{originFile[0]})
+		if err == nil && len(fileList) == 0 {
+			// 如果包含软连接，应重新生成新文件副本，并更新source_name
+			originFile[0].SourceName = fs.GenerateSavePath(uploadCtx, &fileData)
+			fileData.Mode &= ^fsctx.Overwrite
+			fs.Use("AfterUpload", filesystem.HookUpdateSourceName)
+			fs.Use("AfterUploadCanceled", filesystem.HookUpdateSourceName)
+			fs.Use("AfterUploadCanceled", filesystem.HookCleanFileContent)
+			fs.Use("AfterUploadCanceled", filesystem.HookClearFileSize)
+			fs.Use("AfterValidateFailed", filesystem.HookUpdateSourceName)
+			fs.Use("AfterValidateFailed", filesystem.HookCleanFileContent)
+			fs.Use("AfterValidateFailed", filesystem.HookClearFileSize)
+		}
 
-	// 给文件系统分配钩子
-	fs.Use("BeforeUpload", filesystem.HookResetPolicy)
-	fs.Use("BeforeUpload", filesystem.HookValidateFile)
-	fs.Use("BeforeUpload", filesystem.HookValidateCapacityDiff)
-	fs.Use("AfterUpload", filesystem.GenericAfterUpdate)
+		// 给文件系统分配钩子
+		fs.Use("BeforeUpload", filesystem.HookResetPolicy)
+		fs.Use("BeforeUpload", filesystem.HookValidateFile)
+		fs.Use("BeforeUpload", filesystem.HookValidateCapacityDiff)
+		fs.Use("AfterUpload", filesystem.GenericAfterUpdate)
+	}
 
 	// 执行上传
 	uploadCtx = context.WithValue(uploadCtx, fsctx.FileModelCtx, originFile[0])
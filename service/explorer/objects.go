@@ -13,6 +13,7 @@ import (
 	"github.com/cloudreve/Cloudreve/v3/pkg/auth"
 	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
 	"github.com/cloudreve/Cloudreve/v3/pkg/hashid"
 	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
 	"github.com/cloudreve/Cloudreve/v3/pkg/task"
@@ -150,7 +151,7 @@ func (service *ItemDecompressService) CreateDecompressTask(c *gin.Context) seria
 	if err != nil {
 		return serializer.Err(serializer.CodeCreateTaskError, "", err)
 	}
-	task.TaskPoll.Submit(job)
+	task.Submit(c, task.TaskPoll, job)
 
 	return serializer.Response{}
 
@@ -184,10 +185,10 @@ func (service *ItemCompressService) CreateCompressTask(c *gin.Context) serialize
 	}
 
 	// 检查文件名合法性
-	if !fs.ValidateLegalName(context.Background(), service.Name) {
+	if !fs.ValidateLegalName(util.RequestContext(c), service.Name) {
 		return serializer.Err(serializer.CodeIllegalObjectName, "", nil)
 	}
-	if !fs.ValidateExtension(context.Background(), service.Name) {
+	if !fs.ValidateExtension(util.RequestContext(c), service.Name) {
 		return serializer.Err(serializer.CodeFileTypeNotAllowed, "", nil)
 	}
 
@@ -228,7 +229,7 @@ func (service *ItemCompressService) CreateCompressTask(c *gin.Context) serialize
 	if err != nil {
 		return serializer.Err(serializer.CodeCreateTaskError, "", err)
 	}
-	task.TaskPoll.Submit(job)
+	task.Submit(c, task.TaskPoll, job)
 
 	return serializer.Response{}
 
@@ -280,9 +281,14 @@ func (service *ItemIDService) Delete(ctx context.Context, c *gin.Context) serial
 		unlink = service.UnlinkOnly
 	}
 
-	// 删除对象
+	// 删除对象，非强制删除时移入回收站
+	ctx = context.WithValue(ctx, fsctx.GinCtx, c)
 	items := service.Raw()
-	err = fs.Delete(ctx, items.Dirs, items.Items, force, unlink)
+	if force || unlink {
+		err = fs.Delete(ctx, items.Dirs, items.Items, force, unlink)
+	} else {
+		err = fs.Trash(ctx, items.Dirs, items.Items)
+	}
 	if err != nil {
 		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
 	}
@@ -303,6 +309,7 @@ func (service *ItemMoveService) Move(ctx context.Context, c *gin.Context) serial
 	defer fs.Recycle()
 
 	// 移动对象
+	ctx = context.WithValue(ctx, fsctx.GinCtx, c)
 	items := service.Src.Raw()
 	err = fs.Move(ctx, items.Dirs, items.Items, service.SrcDir, service.Dst)
 	if err != nil {
@@ -330,6 +337,7 @@ func (service *ItemMoveService) Copy(ctx context.Context, c *gin.Context) serial
 	defer fs.Recycle()
 
 	// 复制对象
+	ctx = context.WithValue(ctx, fsctx.GinCtx, c)
 	err = fs.Copy(ctx, service.Src.Raw().Dirs, service.Src.Raw().Items, service.SrcDir, service.Dst)
 	if err != nil {
 		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
@@ -356,6 +364,7 @@ func (service *ItemRenameService) Rename(ctx context.Context, c *gin.Context) se
 	defer fs.Recycle()
 
 	// 重命名对象
+	ctx = context.WithValue(ctx, fsctx.GinCtx, c)
 	err = fs.Rename(ctx, service.Src.Raw().Dirs, service.Src.Raw().Items, service.NewName)
 	if err != nil {
 		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
@@ -2,20 +2,30 @@ package explorer
 
 import (
 	"context"
-	"strings"
+	"errors"
 
 	model "github.com/cloudreve/Cloudreve/v3/models"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
 	"github.com/cloudreve/Cloudreve/v3/pkg/hashid"
+	"github.com/cloudreve/Cloudreve/v3/pkg/search/query"
 	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
 	"github.com/gin-gonic/gin"
 )
 
+// defaultSearchPageSize 分页搜索时默认的每页结果数
+const defaultSearchPageSize = 50
+
 // ItemSearchService 文件搜索服务
 type ItemSearchService struct {
 	Type     string `uri:"type" binding:"required"`
 	Keywords string `uri:"keywords" binding:"required"`
 	Path     string `form:"path"`
+	Page     int    `form:"page" binding:"min=0"`
+	PageSize int    `form:"page_size" binding:"min=0,max=1000"`
+	OrderBy  string `form:"order_by" binding:"omitempty,eq=name|eq=size|eq=created_at|eq=updated_at"`
+	Order    string `form:"order" binding:"omitempty,eq=asc|eq=desc"`
 }
 
 // Search 执行搜索
@@ -36,44 +46,104 @@ func (service *ItemSearchService) Search(c *gin.Context) serializer.Response {
 		fs.Root = parent
 	}
 
+	// 上下文
+	ctx, cancel := context.WithCancel(util.RequestContext(c))
+	defer cancel()
+
+	return service.SearchIn(ctx, fs)
+}
+
+// SearchIn 在给定的文件系统中执行搜索，在分享中搜索时不能使用分类标签
+func (service *ItemSearchService) SearchIn(ctx context.Context, fs *filesystem.FileSystem) serializer.Response {
+	var expr query.Expr
 	switch service.Type {
 	case "keywords":
-		return service.SearchKeywords(c, fs, "%"+service.Keywords+"%")
+		expr = query.Name{Glob: service.Keywords, Contains: true}
 	case "image":
-		return service.SearchKeywords(c, fs, "%.bmp", "%.iff", "%.png", "%.gif", "%.jpg", "%.jpeg", "%.psd", "%.svg", "%.webp")
+		expr = query.Ext{"bmp", "iff", "png", "gif", "jpg", "jpeg", "psd", "svg", "webp"}
 	case "video":
-		return service.SearchKeywords(c, fs, "%.mp4", "%.flv", "%.avi", "%.wmv", "%.mkv", "%.rm", "%.rmvb", "%.mov", "%.ogv")
+		expr = query.Ext{"mp4", "flv", "avi", "wmv", "mkv", "rm", "rmvb", "mov", "ogv"}
 	case "audio":
-		return service.SearchKeywords(c, fs, "%.mp3", "%.flac", "%.ape", "%.wav", "%.acc", "%.ogg", "%.midi", "%.mid")
+		expr = query.Ext{"mp3", "flac", "ape", "wav", "acc", "ogg", "midi", "mid"}
 	case "doc":
-		return service.SearchKeywords(c, fs, "%.txt", "%.md", "%.pdf", "%.doc", "%.docx", "%.ppt", "%.pptx", "%.xls", "%.xlsx", "%.pub")
+		expr = query.Ext{"txt", "md", "pdf", "doc", "docx", "ppt", "pptx", "xls", "xlsx", "pub"}
+	case "query":
+		var err error
+		if expr, err = query.Parse(service.Keywords); err != nil {
+			return serializer.ParamErr(err.Error(), err)
+		}
+	case "content":
+		return service.SearchContent(ctx, fs)
 	case "tag":
-		if tid, err := hashid.DecodeHashID(service.Keywords, hashid.TagID); err == nil {
-			if tag, err := model.GetTagsByID(tid, fs.User.ID); err == nil {
-				if tag.Type == model.FileTagType {
-					exp := strings.Split(tag.Expression, "\n")
-					expInput := make([]interface{}, len(exp))
-					for i := 0; i < len(exp); i++ {
-						expInput[i] = exp[i]
-					}
-					return service.SearchKeywords(c, fs, expInput...)
-				}
-			}
+		tag, err := service.filterTag(ctx, fs)
+		if err != nil {
+			return serializer.Err(serializer.CodeNotFound, "", err)
+		}
+		if expr, err = tag.Query(); err != nil {
+			return serializer.ParamErr(err.Error(), err)
 		}
-		return serializer.Err(serializer.CodeNotFound, "", nil)
 	default:
 		return serializer.ParamErr("Unknown search type", nil)
 	}
+
+	return service.SearchQuery(ctx, fs, expr)
 }
 
-// SearchKeywords 根据关键字搜索文件
-func (service *ItemSearchService) SearchKeywords(c *gin.Context, fs *filesystem.FileSystem, keywords ...interface{}) serializer.Response {
-	// 上下文
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+// filterTag 获取要搜索的文件分类标签
+func (service *ItemSearchService) filterTag(ctx context.Context, fs *filesystem.FileSystem) (*model.Tag, error) {
+	if ctx.Value(fsctx.ShareKeyCtx) != nil {
+		return nil, errors.New("tags are not available in shares")
+	}
+
+	tid, err := hashid.DecodeHashID(service.Keywords, hashid.TagID)
+	if err != nil {
+		return nil, err
+	}
+
+	tag, err := model.GetTagsByID(tid, fs.User.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if tag.Type != model.FileTagType {
+		return nil, errors.New("not a filter tag")
+	}
+
+	return tag, nil
+}
+
+// SearchQuery 根据查询条件搜索文件
+func (service *ItemSearchService) SearchQuery(ctx context.Context, fs *filesystem.FileSystem, expr query.Expr) serializer.Response {
+	opts := model.FileSearchOptions{
+		OrderBy: service.OrderBy,
+		Desc:    service.Order == "desc",
+		Page:    service.Page,
+	}
+	if opts.Page > 0 {
+		opts.PageSize = service.PageSize
+		if opts.PageSize == 0 {
+			opts.PageSize = defaultSearchPageSize
+		}
+	}
+
+	objects, total, err := fs.SearchQuery(ctx, expr, opts)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+
+	return serializer.Response{
+		Code: 0,
+		Data: map[string]interface{}{
+			"parent":  0,
+			"objects": objects,
+			"total":   total,
+		},
+	}
+}
 
-	// 获取子项目
-	objects, err := fs.Search(ctx, keywords...)
+// SearchContent 根据文件内容搜索文件
+func (service *ItemSearchService) SearchContent(ctx context.Context, fs *filesystem.FileSystem) serializer.Response {
+	objects, err := fs.SearchContent(ctx, service.Keywords)
 	if err != nil {
 		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
 	}
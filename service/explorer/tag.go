@@ -1,9 +1,6 @@
 package explorer
 
 import (
-	"fmt"
-	"strings"
-
 	model "github.com/cloudreve/Cloudreve/v3/models"
 	"github.com/cloudreve/Cloudreve/v3/pkg/hashid"
 	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
@@ -59,13 +56,17 @@ func (service *LinkTagCreateService) Create(c *gin.Context, user *model.User) se
 
 // Create 创建标签
 func (service *FilterTagCreateService) Create(c *gin.Context, user *model.User) serializer.Response {
-	// 分割表达式，将通配符转换为SQL内的%
-	expressions := strings.Split(service.Expression, "\n")
-	for i := 0; i < len(expressions); i++ {
-		expressions[i] = strings.ReplaceAll(expressions[i], "*", "%")
-		if expressions[i] == "" {
-			return serializer.ParamErr(fmt.Sprintf("The %d line contains an empty match expression", i+1), nil)
-		}
+	// 旧版客户端提交多行文件名匹配模式，转换为查询语法后保存
+	expression := model.Tag{Expression: service.Expression}
+	expr, err := expression.Query()
+	if err != nil {
+		return serializer.ParamErr(err.Error(), err)
+	}
+
+	// 规范化的查询中通配符均为 *，仍包含 % 时无法与旧版表达式区分
+	canonical := expr.String()
+	if model.IsLegacyExpression(canonical) {
+		return serializer.ParamErr("Filter expression cannot contain \"%\"", nil)
 	}
 
 	// 创建标签
@@ -74,7 +75,7 @@ func (service *FilterTagCreateService) Create(c *gin.Context, user *model.User)
 		Icon:       service.Icon,
 		Color:      service.Color,
 		Type:       model.FileTagType,
-		Expression: strings.Join(expressions, "\n"),
+		Expression: canonical,
 		UserID:     user.ID,
 	}
 	id, err := tag.Create()
@@ -0,0 +1,89 @@
+package explorer
+
+import (
+	"context"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/hashid"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/gin-gonic/gin"
+)
+
+// TrashListService 回收站列表服务
+type TrashListService struct {
+}
+
+// TrashService 回收站对象操作服务
+type TrashService struct {
+	Items []string `json:"items"`
+}
+
+// TrashRestoreService 回收站对象还原服务
+type TrashRestoreService struct {
+	Items []string `json:"items" binding:"required,min=1"`
+	Dst   string   `json:"dst" binding:"max=65535"`
+}
+
+// decodeTrashIDs 批量解码回收站对象的HashID
+func decodeTrashIDs(items []string) []uint {
+	res := make([]uint, 0, len(items))
+	for _, item := range items {
+		if id, err := hashid.DecodeHashID(item, hashid.TrashID); err == nil {
+			res = append(res, id)
+		}
+	}
+	return res
+}
+
+// List 列出回收站中的对象
+func (service *TrashListService) List(c *gin.Context, user *model.User) serializer.Response {
+	trashes, err := model.GetTrashByUID(user.ID)
+	if err != nil {
+		return serializer.DBErr("Failed to list trash", err)
+	}
+
+	return serializer.BuildTrashList(trashes, &user.Group)
+}
+
+// Restore 还原回收站中的对象
+func (service *TrashRestoreService) Restore(ctx context.Context, c *gin.Context) serializer.Response {
+	fs, err := filesystem.NewFileSystemFromContext(c)
+	if err != nil {
+		return serializer.Err(serializer.CodeCreateFSError, "", err)
+	}
+	defer fs.Recycle()
+
+	ctx = context.WithValue(ctx, fsctx.GinCtx, c)
+	if err := fs.RestoreTrash(ctx, decodeTrashIDs(service.Items), service.Dst); err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+
+	return serializer.Response{}
+}
+
+// Delete 彻底删除回收站中的对象，未指定对象时清空回收站
+func (service *TrashService) Delete(ctx context.Context, c *gin.Context) serializer.Response {
+	fs, err := filesystem.NewFileSystemFromContext(c)
+	if err != nil {
+		return serializer.Err(serializer.CodeCreateFSError, "", err)
+	}
+	defer fs.Recycle()
+
+	var trashes []model.Trash
+	if len(service.Items) == 0 {
+		trashes, err = model.GetTrashByUID(fs.User.ID)
+	} else {
+		trashes, err = model.GetTrashByIDs(decodeTrashIDs(service.Items), fs.User.ID)
+	}
+	if err != nil {
+		return serializer.DBErr("Failed to list trash", err)
+	}
+
+	if err := fs.PurgeTrash(ctx, trashes); err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+
+	return serializer.Response{}
+}
@@ -27,6 +27,10 @@ type CreateUploadSessionService struct {
 	PolicyID     string `json:"policy_id" binding:"required"`
 	LastModified int64  `json:"last_modified"`
 	MimeType     string `json:"mime_type"`
+	// 秒传相关，Challenge 为空时请求秒传挑战，否则提交挑战的应答，未能秒传时创建普通上传会话
+	Hash      string `json:"hash" binding:"omitempty,len=64,hexadecimal"`
+	Challenge string `json:"challenge"`
+	Proof     string `json:"proof"`
 }
 
 // Create 创建新的上传会话
@@ -58,6 +62,32 @@ func (service *CreateUploadSessionService) Create(ctx context.Context, c *gin.Co
 		lastModified := time.UnixMilli(service.LastModified)
 		file.LastModified = &lastModified
 	}
+
+	// 尝试秒传
+	if service.Hash != "" {
+		hash := strings.ToLower(service.Hash)
+		var (
+			credential *serializer.UploadCredential
+			err        error
+		)
+		if service.Challenge != "" {
+			credential, err = fs.InstantUpload(ctx, file, hash, service.Challenge, strings.ToLower(service.Proof))
+		} else {
+			credential, err = fs.CreateUploadChallenge(ctx, file, hash)
+		}
+
+		if err != nil {
+			return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+		}
+
+		if credential != nil {
+			return serializer.Response{
+				Code: 0,
+				Data: credential,
+			}
+		}
+	}
+
 	credential, err := fs.CreateUploadSession(ctx, file)
 	if err != nil {
 		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
@@ -120,7 +150,7 @@ func (service *UploadService) LocalUpload(ctx context.Context, c *gin.Context) s
 	}
 
 	if expectedSizeStart > actualSizeStart {
-		util.Log().Info("Trying to overwrite chunk[%d] Start=%d", service.Index, actualSizeStart)
+		util.Log().WithContext(ctx).Info("Trying to overwrite chunk[%d] Start=%d", service.Index, actualSizeStart)
 	}
 
 	return processChunkUpload(ctx, c, fs, &uploadSession, service.Index, file, fsctx.Append)
@@ -189,6 +219,8 @@ func processChunkUpload(ctx context.Context, c *gin.Context, fs *filesystem.File
 	}
 
 	// 给文件系统分配钩子
+	fs.Use("BeforeUpload", filesystem.HookResumeChunkHash(session.Key, index))
+	fs.Use("AfterUpload", filesystem.HookSaveChunkHash(session.Key))
 	fs.Use("AfterUploadCanceled", filesystem.HookTruncateFileTo(fileData.AppendStart))
 	fs.Use("AfterValidateFailed", filesystem.HookTruncateFileTo(fileData.AppendStart))
 
@@ -198,7 +230,12 @@ func processChunkUpload(ctx context.Context, c *gin.Context, fs *filesystem.File
 		fs.Use("AfterValidateFailed", filesystem.HookChunkUploadFailed)
 		if isLastChunk {
 			fs.Use("AfterUpload", filesystem.HookPopPlaceholderToFile(""))
+			fs.Use("AfterUpload", filesystem.HookIndexBlob(session.Key, ""))
+			fs.Use("AfterUpload", filesystem.HookIndexContent)
 			fs.Use("AfterUpload", filesystem.HookDeleteUploadSession(session.Key))
+			if session.ShareID != 0 {
+				fs.Use("AfterUpload", filesystem.HookShareUploaded(session.ShareID))
+			}
 		}
 	} else {
 		if isLastChunk {
@@ -0,0 +1,138 @@
+package explorer
+
+import (
+	"context"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/hashid"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/gin-gonic/gin"
+)
+
+// FileVersionListService 文件历史版本列表服务
+type FileVersionListService struct {
+}
+
+// FileVersionService 文件历史版本操作服务
+type FileVersionService struct {
+	Version string `uri:"version" binding:"required"`
+}
+
+// List 列出文件的历史版本
+func (service *FileVersionListService) List(c *gin.Context, user *model.User) serializer.Response {
+	fileID, _ := c.Get("object_id")
+	files, err := model.GetFilesByIDs([]uint{fileID.(uint)}, user.ID)
+	if err != nil || len(files) == 0 {
+		return serializer.Err(serializer.CodeFileNotFound, "", err)
+	}
+
+	versions, err := model.GetVersionsByFileID(files[0].ID, user.ID)
+	if err != nil {
+		return serializer.DBErr("Failed to list file versions", err)
+	}
+
+	return serializer.BuildFileVersionList(versions, &user.Group)
+}
+
+// getVersion 获取当前请求指定的文件及其历史版本
+func (service *FileVersionService) getVersion(c *gin.Context, user *model.User) (*model.File, *model.FileVersion, error) {
+	fileID, _ := c.Get("object_id")
+	files, err := model.GetFilesByIDs([]uint{fileID.(uint)}, user.ID)
+	if err != nil || len(files) == 0 {
+		return nil, nil, serializer.NewError(serializer.CodeFileNotFound, "", err)
+	}
+
+	versionID, err := hashid.DecodeHashID(service.Version, hashid.FileVersionID)
+	if err != nil {
+		return nil, nil, serializer.NewError(serializer.CodeNotFound, "Version not exist", err)
+	}
+
+	version, err := model.GetFileVersionByID(versionID, files[0].ID, user.ID)
+	if err != nil {
+		return nil, nil, serializer.NewError(serializer.CodeNotFound, "Version not exist", err)
+	}
+
+	return &files[0], version, nil
+}
+
+// CreateDownloadSession 创建历史版本的下载会话
+func (service *FileVersionService) CreateDownloadSession(ctx context.Context, c *gin.Context) serializer.Response {
+	fs, err := filesystem.NewFileSystemFromContext(c)
+	if err != nil {
+		return serializer.Err(serializer.CodeCreateFSError, "", err)
+	}
+	defer fs.Recycle()
+
+	file, version, err := service.getVersion(c, fs.User)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+
+	// 以历史版本的内容作为下载目标
+	fs.FileTarget = []model.File{version.AsFile(file)}
+	downloadURL, err := fs.GetDownloadURL(ctx, 0, "download_timeout")
+	if err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+
+	return serializer.Response{
+		Code: 0,
+		Data: downloadURL,
+	}
+}
+
+// Preview 预览历史版本
+func (service *FileVersionService) Preview(ctx context.Context, c *gin.Context, isText bool) serializer.Response {
+	userCtx, _ := c.Get("user")
+	file, version, err := service.getVersion(c, userCtx.(*model.User))
+	if err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+
+	versionFile := version.AsFile(file)
+	ctx = context.WithValue(ctx, fsctx.FileModelCtx, &versionFile)
+	subService := FileIDService{}
+	return subService.PreviewContent(ctx, c, isText)
+}
+
+// Restore 将文件还原为历史版本
+func (service *FileVersionService) Restore(ctx context.Context, c *gin.Context) serializer.Response {
+	fs, err := filesystem.NewFileSystemFromContext(c)
+	if err != nil {
+		return serializer.Err(serializer.CodeCreateFSError, "", err)
+	}
+	defer fs.Recycle()
+
+	file, version, err := service.getVersion(c, fs.User)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+
+	if err := fs.RestoreVersion(ctx, file, version); err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+
+	return serializer.Response{}
+}
+
+// Delete 删除历史版本
+func (service *FileVersionService) Delete(ctx context.Context, c *gin.Context) serializer.Response {
+	fs, err := filesystem.NewFileSystemFromContext(c)
+	if err != nil {
+		return serializer.Err(serializer.CodeCreateFSError, "", err)
+	}
+	defer fs.Recycle()
+
+	_, version, err := service.getVersion(c, fs.User)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+
+	if err := fs.DeleteVersions(ctx, []model.FileVersion{*version}); err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+
+	return serializer.Response{}
+}
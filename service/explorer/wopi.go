@@ -7,10 +7,13 @@ import (
 	model "github.com/cloudreve/Cloudreve/v3/models"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem"
 	"github.com/cloudreve/Cloudreve/v3/pkg/hashid"
+	"github.com/cloudreve/Cloudreve/v3/pkg/metrics"
 	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
 	"github.com/cloudreve/Cloudreve/v3/pkg/wopi"
 	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
 	"net/http"
+	"path"
 	"time"
 )
 
@@ -50,7 +53,7 @@ func (service *WopiService) GetFile(c *gin.Context) error {
 	defer resp.Content.Close()
 
 	c.Header("Cache-Control", "no-cache")
-	http.ServeContent(c.Writer, c.Request, fs.FileTarget[0].Name, fs.FileTarget[0].UpdatedAt, resp.Content)
+	http.ServeContent(metrics.ServedWriter(c.Writer, fs.PolicyType()), c.Request, fs.FileTarget[0].Name, fs.FileTarget[0].UpdatedAt, resp.Content)
 	return nil
 }
 
@@ -116,6 +119,192 @@ func (service *WopiService) FileInfo(c *gin.Context) (*serializer.WopiFileInfo,
 	return info, nil
 }
 
+// Lock locks the file, or replaces the lock given in old lock header. The
+// current lock ID is returned if the file is locked by someone else.
+func (service *WopiService) Lock(c *gin.Context) (string, error) {
+	fs, _, err := service.prepareFs(c)
+	if err != nil {
+		return "", err
+	}
+
+	defer fs.Recycle()
+
+	lockID, oldLockID := c.GetHeader(wopi.LockHeader), c.GetHeader(wopi.OldLockHeader)
+	if lockID == "" {
+		return "", errors.New("missing lock id")
+	}
+
+	name, current, err := service.lockState(fs)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	timeout := int64(wopi.LockDuration / time.Second)
+	if oldLockID != "" {
+		// Unlock and relock with the new lock ID
+		if !matchWopiLock(current, oldLockID) {
+			return wopiLockID(current), wopi.ErrLockMismatch
+		}
+
+		if err := current.UpdateOwner(lockID); err != nil {
+			return "", err
+		}
+
+		return "", current.Refresh(timeout, now)
+	}
+
+	if current == nil {
+		lock := &model.Lock{
+			Token:     "opaquelocktoken:" + uuid.Must(uuid.NewV4()).String(),
+			UserID:    fs.User.ID,
+			Type:      model.LockTypeWopi,
+			Path:      name,
+			ZeroDepth: true,
+			Owner:     lockID,
+			Timeout:   timeout,
+		}
+		if err := lock.Create(now); err != model.ErrLockConflict {
+			return "", err
+		}
+
+		// The file is locked by another client in the meantime
+		_, current, err = service.lockState(fs)
+		if err != nil {
+			return "", err
+		}
+
+		return wopiLockID(current), wopi.ErrLockMismatch
+	}
+
+	if !matchWopiLock(current, lockID) {
+		return wopiLockID(current), wopi.ErrLockMismatch
+	}
+
+	return "", current.Refresh(timeout, now)
+}
+
+// GetLock returns the current lock ID of the file.
+func (service *WopiService) GetLock(c *gin.Context) (string, error) {
+	fs, _, err := service.prepareFs(c)
+	if err != nil {
+		return "", err
+	}
+
+	defer fs.Recycle()
+
+	_, current, err := service.lockState(fs)
+	if err != nil {
+		return "", err
+	}
+
+	return wopiLockID(current), nil
+}
+
+// RefreshLock extends the expiry of the lock given in lock header.
+func (service *WopiService) RefreshLock(c *gin.Context) (string, error) {
+	fs, _, err := service.prepareFs(c)
+	if err != nil {
+		return "", err
+	}
+
+	defer fs.Recycle()
+
+	_, current, err := service.lockState(fs)
+	if err != nil {
+		return "", err
+	}
+
+	if !matchWopiLock(current, c.GetHeader(wopi.LockHeader)) {
+		return wopiLockID(current), wopi.ErrLockMismatch
+	}
+
+	return "", current.Refresh(int64(wopi.LockDuration/time.Second), time.Now())
+}
+
+// Unlock releases the lock given in lock header.
+func (service *WopiService) Unlock(c *gin.Context) (string, error) {
+	fs, _, err := service.prepareFs(c)
+	if err != nil {
+		return "", err
+	}
+
+	defer fs.Recycle()
+
+	_, current, err := service.lockState(fs)
+	if err != nil {
+		return "", err
+	}
+
+	if !matchWopiLock(current, c.GetHeader(wopi.LockHeader)) {
+		return wopiLockID(current), wopi.ErrLockMismatch
+	}
+
+	return "", current.Delete()
+}
+
+// CheckLock validates if the file can be overwritten with the lock given in
+// lock header. Files not locked by anyone can always be overwritten.
+func (service *WopiService) CheckLock(c *gin.Context) (string, error) {
+	fs, _, err := service.prepareFs(c)
+	if err != nil {
+		return "", err
+	}
+
+	defer fs.Recycle()
+
+	_, current, err := service.lockState(fs)
+	if err != nil {
+		return "", err
+	}
+
+	if current != nil && !matchWopiLock(current, c.GetHeader(wopi.LockHeader)) {
+		return wopiLockID(current), wopi.ErrLockMismatch
+	}
+
+	return "", nil
+}
+
+// lockState returns the full path of the target file and the lock applied to
+// it, including infinite depth locks on its parent folders held by WebDAV.
+func (service *WopiService) lockState(fs *filesystem.FileSystem) (string, *model.Lock, error) {
+	parent, err := model.GetFoldersByIDs([]uint{fs.FileTarget[0].FolderID}, fs.User.ID)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if len(parent) == 0 {
+		return "", nil, fmt.Errorf("failed to find parent folder")
+	}
+
+	if err := parent[0].TraceRoot(); err != nil {
+		return "", nil, err
+	}
+
+	name := path.Join(parent[0].Position, parent[0].Name, fs.FileTarget[0].Name)
+	locks, err := model.GetLocksByPath(fs.User.ID, name, time.Now())
+	if err != nil || len(locks) == 0 {
+		return name, nil, err
+	}
+
+	return name, &locks[0], nil
+}
+
+// matchWopiLock returns whether the lock is held by WOPI client with given lock ID.
+func matchWopiLock(lock *model.Lock, lockID string) bool {
+	return lock != nil && lockID != "" && lock.Type == model.LockTypeWopi && lock.Owner == lockID
+}
+
+// wopiLockID returns the lock ID reported to WOPI client. Locks held by other
+// protocols are reported as empty ID.
+func wopiLockID(lock *model.Lock) string {
+	if lock == nil || lock.Type != model.LockTypeWopi {
+		return ""
+	}
+
+	return lock.Owner
+}
+
 func (service *WopiService) prepareFs(c *gin.Context) (*filesystem.FileSystem, *wopi.SessionCache, error) {
 	// 创建文件系统
 	fs, err := filesystem.NewFileSystemFromContext(c)
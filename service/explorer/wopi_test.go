@@ -0,0 +1,123 @@
+package explorer
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cloudreve/Cloudreve/v3/middleware"
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
+	"github.com/cloudreve/Cloudreve/v3/pkg/wopi"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func wopiContext(user *model.User, file *model.File, lockID, oldLockID string) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("POST", "/", nil)
+	if lockID != "" {
+		c.Request.Header.Set(wopi.LockHeader, lockID)
+	}
+	if oldLockID != "" {
+		c.Request.Header.Set(wopi.OldLockHeader, oldLockID)
+	}
+	c.Set("user", user)
+	c.Set(middleware.WopiSessionCtx, &wopi.SessionCache{FileID: file.ID, UserID: user.ID})
+	return c
+}
+
+func TestWopiService_Lock(t *testing.T) {
+	a := assert.New(t)
+	mockDB := model.DB
+	model.DB, _ = gorm.Open("sqlite", ":memory:")
+	defer func() {
+		model.DB = mockDB
+	}()
+	model.DB.AutoMigrate(&model.User{}, &model.Folder{}, &model.File{}, &model.Lock{})
+	cache.Set("setting_maxEditSize", "0", 0)
+
+	user := &model.User{Email: "wopi@cloudreve.org"}
+	a.NoError(model.DB.Create(user).Error)
+	user.Policy = model.Policy{Type: "local"}
+	root := &model.Folder{Name: "/", OwnerID: user.ID}
+	a.NoError(model.DB.Create(root).Error)
+	file := &model.File{Name: "a.docx", UserID: user.ID, FolderID: root.ID}
+	a.NoError(model.DB.Create(file).Error)
+
+	service := &WopiService{}
+	call := func(method func(*gin.Context) (string, error), lockID, oldLockID string) (string, error) {
+		return method(wopiContext(user, file, lockID, oldLockID))
+	}
+
+	// 未锁定
+	current, err := call(service.GetLock, "", "")
+	a.NoError(err)
+	a.Empty(current)
+	_, err = call(service.CheckLock, "l1", "")
+	a.NoError(err)
+	_, err = call(service.Lock, "", "")
+	a.Error(err)
+
+	// 加锁
+	_, err = call(service.Lock, "l1", "")
+	a.NoError(err)
+	current, err = call(service.GetLock, "", "")
+	a.NoError(err)
+	a.Equal("l1", current)
+	locks, err := model.GetLocksByPath(user.ID, "/a.docx", time.Now())
+	a.NoError(err)
+	a.Len(locks, 1)
+	a.Equal(model.LockTypeWopi, locks[0].Type)
+
+	// 已被其他客户端锁定
+	current, err = call(service.Lock, "l2", "")
+	a.Equal(wopi.ErrLockMismatch, err)
+	a.Equal("l1", current)
+	current, err = call(service.CheckLock, "l2", "")
+	a.Equal(wopi.ErrLockMismatch, err)
+	a.Equal("l1", current)
+	current, err = call(service.RefreshLock, "l2", "")
+	a.Equal(wopi.ErrLockMismatch, err)
+	a.Equal("l1", current)
+
+	// 持有者可重复加锁、刷新及写入
+	_, err = call(service.Lock, "l1", "")
+	a.NoError(err)
+	_, err = call(service.RefreshLock, "l1", "")
+	a.NoError(err)
+	_, err = call(service.CheckLock, "l1", "")
+	a.NoError(err)
+
+	// 更换锁 ID
+	current, err = call(service.Lock, "l3", "l2")
+	a.Equal(wopi.ErrLockMismatch, err)
+	a.Equal("l1", current)
+	_, err = call(service.Lock, "l3", "l1")
+	a.NoError(err)
+	current, _ = call(service.GetLock, "", "")
+	a.Equal("l3", current)
+
+	// 解锁
+	current, err = call(service.Unlock, "l1", "")
+	a.Equal(wopi.ErrLockMismatch, err)
+	a.Equal("l3", current)
+	_, err = call(service.Unlock, "l3", "")
+	a.NoError(err)
+	current, _ = call(service.GetLock, "", "")
+	a.Empty(current)
+
+	// WebDAV 客户端锁定父目录时，报告的锁 ID 为空
+	a.NoError((&model.Lock{Token: "dav", UserID: user.ID, Type: model.LockTypeWebDAV, Path: "/", Timeout: -1}).Create(time.Now()))
+	current, err = call(service.GetLock, "", "")
+	a.NoError(err)
+	a.Empty(current)
+	current, err = call(service.Lock, "l1", "")
+	a.Equal(wopi.ErrLockMismatch, err)
+	a.Empty(current)
+	_, err = call(service.CheckLock, "l1", "")
+	a.Equal(wopi.ErrLockMismatch, err)
+	_, err = call(service.Unlock, "l1", "")
+	a.Equal(wopi.ErrLockMismatch, err)
+}
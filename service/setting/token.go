@@ -0,0 +1,107 @@
+package setting
+
+import (
+	"strings"
+	"time"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+	"github.com/gin-gonic/gin"
+)
+
+// maxAccessTokens 每个用户最多可创建的个人访问令牌数量
+const maxAccessTokens = 50
+
+// AccessTokenListService 个人访问令牌列表服务
+type AccessTokenListService struct {
+}
+
+// AccessTokenService 个人访问令牌管理服务
+type AccessTokenService struct {
+	ID uint `uri:"id" binding:"required,min=1"`
+}
+
+// AccessTokenCreateService 个人访问令牌创建服务
+type AccessTokenCreateService struct {
+	Name    string   `json:"name" binding:"required,min=1,max=255"`
+	Scopes  []string `json:"scopes" binding:"required,min=1"`
+	Expires int64    `json:"expires" binding:"min=0"` // 有效期（秒），0 表示永不过期
+}
+
+// Create 创建个人访问令牌
+func (service *AccessTokenCreateService) Create(c *gin.Context, user *model.User) serializer.Response {
+	scopes := make([]string, 0, len(service.Scopes))
+	for _, scope := range service.Scopes {
+		if !util.ContainsString(model.AccessTokenScopes, scope) {
+			return serializer.ParamErr("Unknown scope "+scope, nil)
+		}
+		if scope == model.ScopeAdmin && user.Group.ID != 1 && user.ID != 1 {
+			return serializer.Err(serializer.CodeNoPermissionErr, "Only administrators can create tokens with admin scope", nil)
+		}
+		if !util.ContainsString(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	if model.CountAccessTokens(user.ID) >= maxAccessTokens {
+		return serializer.ParamErr("Too many access tokens", nil)
+	}
+
+	token := model.AccessToken{
+		Name:   service.Name,
+		UserID: user.ID,
+		Scopes: strings.Join(scopes, ","),
+	}
+	if service.Expires > 0 {
+		expires := time.Now().Add(time.Duration(service.Expires) * time.Second)
+		token.ExpiresAt = &expires
+	}
+
+	plain, err := token.Create()
+	if err != nil {
+		return serializer.DBErr("Failed to create access token", err)
+	}
+
+	return serializer.Response{
+		Data: map[string]interface{}{
+			"id":         token.ID,
+			"token":      plain,
+			"scopes":     scopes,
+			"expires_at": token.ExpiresAt,
+			"created_at": token.CreatedAt,
+		},
+	}
+}
+
+// Delete 撤销个人访问令牌
+func (service *AccessTokenService) Delete(c *gin.Context, user *model.User) serializer.Response {
+	if err := model.DeleteAccessTokenByID(service.ID, user.ID); err != nil {
+		return serializer.Err(serializer.CodeNotFound, "", err)
+	}
+	return serializer.Response{}
+}
+
+// Tokens 列出个人访问令牌
+func (service *AccessTokenListService) Tokens(c *gin.Context, user *model.User) serializer.Response {
+	tokens, err := model.ListAccessTokens(user.ID)
+	if err != nil {
+		return serializer.DBErr("Failed to list access tokens", err)
+	}
+
+	res := make([]map[string]interface{}, len(tokens))
+	for i, token := range tokens {
+		res[i] = map[string]interface{}{
+			"id":           token.ID,
+			"name":         token.Name,
+			"scopes":       token.ScopeList(),
+			"expires_at":   token.ExpiresAt,
+			"last_used_at": token.LastUsedAt,
+			"created_at":   token.CreatedAt,
+		}
+	}
+
+	return serializer.Response{Data: map[string]interface{}{
+		"tokens": res,
+	}}
+}
@@ -1,6 +1,10 @@
 package setting
 
 import (
+	"fmt"
+	"net"
+	"strings"
+
 	model "github.com/cloudreve/Cloudreve/v3/models"
 	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
 	"github.com/cloudreve/Cloudreve/v3/pkg/util"
@@ -22,11 +26,14 @@ type WebDAVAccountCreateService struct {
 	Name string `json:"name" binding:"required,min=1,max=255"`
 }
 
-// WebDAVAccountUpdateService WebDAV 修改只读性和是否使用代理服务
+// WebDAVAccountUpdateService WebDAV 修改账户权限范围和是否使用代理服务
 type WebDAVAccountUpdateService struct {
-	ID       uint  `json:"id" binding:"required,min=1"`
-	Readonly *bool `json:"readonly" binding:"required_without=UseProxy"`
-	UseProxy *bool `json:"use_proxy" binding:"required_without=Readonly"`
+	ID          uint    `json:"id" binding:"required,min=1"`
+	Readonly    *bool   `json:"readonly"`
+	UseProxy    *bool   `json:"use_proxy"`
+	NoDelete    *bool   `json:"no_delete"`
+	UploadOnly  *bool   `json:"upload_only"`
+	IPAllowlist *string `json:"ip_allowlist" binding:"omitempty,max=65535"`
 }
 
 // WebDAVMountCreateService WebDAV 挂载创建服务
@@ -37,12 +44,13 @@ type WebDAVMountCreateService struct {
 
 // Create 创建WebDAV账户
 func (service *WebDAVAccountCreateService) Create(c *gin.Context, user *model.User) serializer.Response {
+	password := util.RandStringRunes(32)
 	account := model.Webdav{
-		Name:     service.Name,
-		Password: util.RandStringRunes(32),
-		UserID:   user.ID,
-		Root:     service.Path,
+		Name:   service.Name,
+		UserID: user.ID,
+		Root:   service.Path,
 	}
+	account.SetPassword(password)
 
 	if _, err := account.Create(); err != nil {
 		return serializer.Err(serializer.CodeDBError, "创建失败", err)
@@ -51,7 +59,7 @@ func (service *WebDAVAccountCreateService) Create(c *gin.Context, user *model.Us
 	return serializer.Response{
 		Data: map[string]interface{}{
 			"id":         account.ID,
-			"password":   account.Password,
+			"password":   password,
 			"created_at": account.CreatedAt,
 		},
 	}
@@ -63,7 +71,7 @@ func (service *WebDAVAccountService) Delete(c *gin.Context, user *model.User) se
 	return serializer.Response{}
 }
 
-// Update 修改WebDAV账户只读性和是否使用代理服务
+// Update 修改WebDAV账户权限范围和是否使用代理服务
 func (service *WebDAVAccountUpdateService) Update(c *gin.Context, user *model.User) serializer.Response {
 	var updates = make(map[string]interface{})
 	if service.Readonly != nil {
@@ -72,6 +80,30 @@ func (service *WebDAVAccountUpdateService) Update(c *gin.Context, user *model.Us
 	if service.UseProxy != nil {
 		updates["use_proxy"] = *service.UseProxy
 	}
+	if service.NoDelete != nil {
+		updates["no_delete"] = *service.NoDelete
+	}
+	if service.UploadOnly != nil {
+		updates["upload_only"] = *service.UploadOnly
+	}
+	if service.IPAllowlist != nil {
+		rules := make([]string, 0)
+		for _, rule := range strings.Split(*service.IPAllowlist, ",") {
+			rule = strings.TrimSpace(rule)
+			if rule == "" {
+				continue
+			}
+			if _, _, err := net.ParseCIDR(rule); err != nil && net.ParseIP(rule) == nil {
+				return serializer.ParamErr(fmt.Sprintf("Invalid IP address or CIDR %q", rule), err)
+			}
+			rules = append(rules, rule)
+		}
+		updates["ip_allowlist"] = strings.Join(rules, ",")
+	}
+
+	if len(updates) == 0 {
+		return serializer.ParamErr("Nothing to update", nil)
+	}
 	model.UpdateWebDAVAccountByID(service.ID, user.ID, updates)
 	return serializer.Response{Data: updates}
 }
@@ -0,0 +1,207 @@
+package setting
+
+import (
+	"net/url"
+	"strings"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+	"github.com/cloudreve/Cloudreve/v3/pkg/webhook"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// maxWebhooks 每个用户最多可创建的事件推送订阅数量
+	maxWebhooks = 20
+	// webhookSecretLength 签名密钥长度
+	webhookSecretLength = 32
+	// maxDeliveryPageSize 推送记录单页最大条目数
+	maxDeliveryPageSize = 100
+)
+
+// 以下服务同时用于用户及站点级订阅，uid 为 0 时操作站点级订阅
+
+// WebhookListService 事件推送订阅列表服务
+type WebhookListService struct {
+}
+
+// WebhookService 事件推送订阅管理服务
+type WebhookService struct {
+	ID uint `uri:"id" binding:"required,min=1"`
+}
+
+// WebhookSaveService 事件推送订阅创建及更新服务
+type WebhookSaveService struct {
+	ID           uint     `json:"id"` // 为0时创建新订阅
+	Name         string   `json:"name" binding:"required,min=1,max=255"`
+	URL          string   `json:"url" binding:"required,url,max=2048"`
+	Events       []string `json:"events" binding:"required,min=1"`
+	Enabled      bool     `json:"enabled"`
+	RotateSecret bool     `json:"rotate_secret"` // 更新时是否重新生成签名密钥
+}
+
+// WebhookDeliveryListService 事件推送记录列表服务
+type WebhookDeliveryListService struct {
+	ID       uint `uri:"id" binding:"required,min=1"`
+	Page     int  `form:"page"`
+	PageSize int  `form:"page_size"`
+}
+
+// WebhookDeliveryService 事件推送记录管理服务
+type WebhookDeliveryService struct {
+	ID         uint `uri:"id" binding:"required,min=1"`
+	DeliveryID uint `uri:"delivery" binding:"required,min=1"`
+}
+
+// getWebhook 查找属于给定用户的订阅
+func getWebhook(id, uid uint) (*model.Webhook, serializer.Response) {
+	hook, err := model.GetWebhookByID(id)
+	if err != nil || hook.UserID != uid {
+		return nil, serializer.Err(serializer.CodeNotFound, "", err)
+	}
+	return hook, serializer.Response{}
+}
+
+// Save 创建或更新事件推送订阅，创建或重新生成时返回签名密钥
+func (service *WebhookSaveService) Save(c *gin.Context, uid uint) serializer.Response {
+	target, err := url.Parse(service.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") {
+		return serializer.ParamErr("URL must use http or https scheme", err)
+	}
+
+	events := make([]string, 0, len(service.Events))
+	for _, event := range service.Events {
+		if !util.ContainsString(webhook.Events, event) {
+			return serializer.ParamErr("Unknown event "+event, nil)
+		}
+		if !util.ContainsString(events, event) {
+			events = append(events, event)
+		}
+	}
+
+	secret := ""
+	if service.ID == 0 {
+		if uid != 0 && model.CountWebhooks(uid) >= maxWebhooks {
+			return serializer.ParamErr("Too many webhooks", nil)
+		}
+
+		secret = util.RandStringRunes(webhookSecretLength)
+		hook := model.Webhook{
+			UserID:  uid,
+			Name:    service.Name,
+			URL:     service.URL,
+			Secret:  secret,
+			Events:  strings.Join(events, ","),
+			Enabled: service.Enabled,
+		}
+		if err := hook.Create(); err != nil {
+			return serializer.DBErr("Failed to create webhook", err)
+		}
+
+		service.ID = hook.ID
+	} else {
+		hook, res := getWebhook(service.ID, uid)
+		if hook == nil {
+			return res
+		}
+
+		props := map[string]interface{}{
+			"name":    service.Name,
+			"url":     service.URL,
+			"events":  strings.Join(events, ","),
+			"enabled": service.Enabled,
+		}
+		if service.RotateSecret {
+			secret = util.RandStringRunes(webhookSecretLength)
+			props["secret"] = secret
+		}
+
+		if err := hook.Update(props); err != nil {
+			return serializer.DBErr("Failed to update webhook", err)
+		}
+	}
+
+	data := map[string]interface{}{"id": service.ID}
+	if secret != "" {
+		data["secret"] = secret
+	}
+
+	return serializer.Response{Data: data}
+}
+
+// Delete 删除事件推送订阅
+func (service *WebhookService) Delete(c *gin.Context, uid uint) serializer.Response {
+	if err := model.DeleteWebhookByID(service.ID, uid); err != nil {
+		return serializer.Err(serializer.CodeNotFound, "", err)
+	}
+	return serializer.Response{}
+}
+
+// Webhooks 列出事件推送订阅及可订阅的事件
+func (service *WebhookListService) Webhooks(c *gin.Context, uid uint) serializer.Response {
+	hooks, err := model.ListWebhooks(uid)
+	if err != nil {
+		return serializer.DBErr("Failed to list webhooks", err)
+	}
+
+	res := make([]map[string]interface{}, len(hooks))
+	for i, hook := range hooks {
+		res[i] = map[string]interface{}{
+			"id":         hook.ID,
+			"name":       hook.Name,
+			"url":        hook.URL,
+			"events":     hook.EventList(),
+			"enabled":    hook.Enabled,
+			"created_at": hook.CreatedAt,
+		}
+	}
+
+	return serializer.Response{Data: map[string]interface{}{
+		"webhooks": res,
+		"events":   webhook.Events,
+	}}
+}
+
+// Deliveries 分页列出订阅的推送记录
+func (service *WebhookDeliveryListService) Deliveries(c *gin.Context, uid uint) serializer.Response {
+	if hook, res := getWebhook(service.ID, uid); hook == nil {
+		return res
+	}
+
+	if service.Page < 1 {
+		service.Page = 1
+	}
+	if service.PageSize < 1 || service.PageSize > maxDeliveryPageSize {
+		service.PageSize = maxDeliveryPageSize
+	}
+
+	deliveries, total, err := model.ListWebhookDeliveries(service.ID, service.Page, service.PageSize)
+	if err != nil {
+		return serializer.DBErr("Failed to list webhook deliveries", err)
+	}
+
+	return serializer.Response{Data: map[string]interface{}{
+		"total": total,
+		"items": deliveries,
+	}}
+}
+
+// Replay 以原消息体重新推送给定的推送记录
+func (service *WebhookDeliveryService) Replay(c *gin.Context, uid uint) serializer.Response {
+	if hook, res := getWebhook(service.ID, uid); hook == nil {
+		return res
+	}
+
+	delivery, err := model.GetWebhookDeliveryByID(service.DeliveryID)
+	if err != nil || delivery.WebhookID != service.ID {
+		return serializer.Err(serializer.CodeNotFound, "", err)
+	}
+
+	replayed, err := webhook.Replay(delivery)
+	if err != nil {
+		return serializer.DBErr("Failed to create webhook delivery", err)
+	}
+
+	return serializer.Response{Data: replayed.ID}
+}
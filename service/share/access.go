@@ -0,0 +1,68 @@
+package share
+
+import (
+	"time"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/gin-gonic/gin"
+)
+
+// AccessListService 列出分享访问记录的服务
+type AccessListService struct {
+	Page     int    `form:"page" binding:"required,min=1"`
+	PageSize int    `form:"page_size" binding:"required,min=1,max=100"`
+	Action   string `form:"action" binding:"omitempty,eq=view|eq=download|eq=preview|eq=archive|eq=save|eq=denied"`
+}
+
+// AccessDailyService 按天统计分享访问的服务
+type AccessDailyService struct {
+	Days int `form:"days" binding:"required,min=1,max=366"`
+}
+
+// ownedShare 取得当前用户创建的分享，已过期的分享也可查看统计
+func ownedShare(c *gin.Context, user *model.User) (*model.Share, error) {
+	share := model.GetShareByHashID(c.Param("id"))
+	if share == nil || share.UserID != user.ID {
+		return nil, serializer.NewError(serializer.CodeShareLinkNotFound, "", nil)
+	}
+
+	return share, nil
+}
+
+// List 列出分享的访问记录
+func (service *AccessListService) List(c *gin.Context, user *model.User) serializer.Response {
+	share, err := ownedShare(c, user)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+
+	accesses, total, err := model.ListShareAccesses(share.ID, service.Action, service.Page, service.PageSize)
+	if err != nil {
+		return serializer.DBErr("Failed to list share accesses", err)
+	}
+
+	return serializer.BuildShareAccessList(accesses, total)
+}
+
+// Daily 统计分享最近若干天的访问
+func (service *AccessDailyService) Daily(c *gin.Context, user *model.User) serializer.Response {
+	share, err := ownedShare(c, user)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+
+	return ShareAccessDaily(share.ID, service.Days)
+}
+
+// ShareAccessDaily 统计最近 days 天的分享访问，shareID 为0时统计全部分享
+func ShareAccessDaily(shareID uint, days int) serializer.Response {
+	now := time.Now()
+	since := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()).AddDate(0, 0, 1-days)
+	daily, err := model.GetShareAccessDaily(shareID, since)
+	if err != nil {
+		return serializer.DBErr("Failed to count share accesses", err)
+	}
+
+	return serializer.Response{Data: daily}
+}
@@ -0,0 +1,125 @@
+package share
+
+import (
+	"context"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/gin-gonic/gin"
+)
+
+// ObjectService 对分享目录中 Path 下的对象进行操作的服务，Items 及 Dirs 为对象的 HashID
+type ObjectService struct {
+	Path  string   `json:"path" binding:"required,max=65535"`
+	Items []string `json:"items"`
+	Dirs  []string `json:"dirs"`
+}
+
+// RenameService 重命名分享目录中对象的服务
+type RenameService struct {
+	ObjectService
+	NewName string `json:"new_name" binding:"required,min=1,max=255"`
+}
+
+// DirectoryService 在分享目录中创建目录的服务，Path 为分享目录下的相对路径
+type DirectoryService struct {
+	Path string `json:"path" binding:"required,min=1,max=65535"`
+}
+
+// Delete 将分享目录中的对象移入分享者的回收站
+func (service *ObjectService) Delete(ctx context.Context, c *gin.Context) serializer.Response {
+	shareCtx, _ := c.Get("share")
+	share := shareCtx.(*model.Share)
+
+	dirs, files, err := sharedObjects(share, service.Path, service.Items, service.Dirs)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+
+	fs, err := filesystem.NewFileSystem(share.Creator())
+	if err != nil {
+		return serializer.Err(serializer.CodeCreateFSError, "", err)
+	}
+	defer fs.Recycle()
+
+	ctx = context.WithValue(ctx, fsctx.GinCtx, c)
+	if err := fs.Trash(ctx, folderIDs(dirs), fileIDs(files)); err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+
+	return serializer.Response{}
+}
+
+// Rename 重命名分享目录中的对象
+func (service *RenameService) Rename(ctx context.Context, c *gin.Context) serializer.Response {
+	// 重命名作只能对一个目录或文件对象进行操作
+	if len(service.Items)+len(service.Dirs) != 1 {
+		return filesystem.ErrOneObjectOnly
+	}
+
+	shareCtx, _ := c.Get("share")
+	share := shareCtx.(*model.Share)
+
+	dirs, files, err := sharedObjects(share, service.Path, service.Items, service.Dirs)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+
+	fs, err := filesystem.NewFileSystem(share.Creator())
+	if err != nil {
+		return serializer.Err(serializer.CodeCreateFSError, "", err)
+	}
+	defer fs.Recycle()
+
+	ctx = context.WithValue(ctx, fsctx.GinCtx, c)
+	if err := fs.Rename(ctx, folderIDs(dirs), fileIDs(files), service.NewName); err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+
+	return serializer.Response{}
+}
+
+// CreateDirectory 在分享目录中创建目录
+func (service *DirectoryService) CreateDirectory(ctx context.Context, c *gin.Context) serializer.Response {
+	shareCtx, _ := c.Get("share")
+	share := shareCtx.(*model.Share)
+
+	if !share.IsDir {
+		return serializer.ParamErr("Only folder shares can be edited", nil)
+	}
+
+	dst, err := sharedPath(share, service.Path)
+	if err != nil {
+		return serializer.DBErr("Failed to trace shared folder", err)
+	}
+
+	fs, err := filesystem.NewFileSystem(share.Creator())
+	if err != nil {
+		return serializer.Err(serializer.CodeCreateFSError, "", err)
+	}
+	defer fs.Recycle()
+
+	if _, err := fs.CreateDirectory(ctx, dst); err != nil {
+		return serializer.Err(serializer.CodeCreateFolderFailed, err.Error(), err)
+	}
+
+	return serializer.Response{}
+}
+
+func folderIDs(folders []model.Folder) []uint {
+	ids := make([]uint, len(folders))
+	for i := range folders {
+		ids[i] = folders[i].ID
+	}
+	return ids
+}
+
+func fileIDs(files []model.File) []uint {
+	ids := make([]uint, len(files))
+	for i := range files {
+		ids[i] = files[i].ID
+	}
+	return ids
+}
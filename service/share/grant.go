@@ -0,0 +1,128 @@
+package share
+
+import (
+	"strconv"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/gin-gonic/gin"
+)
+
+// ShareGrantOption 内部分享的授权，Target 为用户的 Email 或用户组ID
+type ShareGrantOption struct {
+	Type       string `json:"type" binding:"required,eq=user|eq=group"`
+	Target     string `json:"target" binding:"required"`
+	Permission string `json:"permission" binding:"required,eq=view|eq=download|eq=edit"`
+}
+
+// ShareGrantService 设置内部分享授权的服务。授权为空时分享仍为内部分享，
+// 仅当 Public 为真时才转为任何人可通过链接访问的普通分享
+type ShareGrantService struct {
+	Grants []ShareGrantOption `json:"grants" binding:"max=100,dive"`
+	Public bool               `json:"public"`
+}
+
+// ReceivedShareListService 列出他人授权给我的分享
+type ReceivedShareListService struct {
+	Page    uint   `form:"page" binding:"required,min=1"`
+	OrderBy string `form:"order_by" binding:"required,eq=created_at|eq=downloads|eq=views"`
+	Order   string `form:"order" binding:"required,eq=DESC|eq=ASC"`
+}
+
+// permissionLevels 授权级别名称
+var permissionLevels = map[string]int{
+	"view":     model.SharePermissionView,
+	"download": model.SharePermissionDownload,
+	"edit":     model.SharePermissionEdit,
+}
+
+// buildGrants 解析授权对象，仅目录分享可授予编辑权限
+func buildGrants(options []ShareGrantOption, isDir bool) ([]model.ShareGrant, error) {
+	grants := make([]model.ShareGrant, 0, len(options))
+	for _, option := range options {
+		grant := model.ShareGrant{
+			TargetType: option.Type,
+			Permission: permissionLevels[option.Permission],
+		}
+
+		if grant.Permission == model.SharePermissionEdit && !isDir {
+			return nil, serializer.NewError(serializer.CodeParamErr, "Only folder shares can be edited", nil)
+		}
+
+		switch option.Type {
+		case model.ShareGrantUser:
+			user, err := model.GetActiveUserByEmail(option.Target)
+			if err != nil {
+				return nil, serializer.NewError(serializer.CodeUserNotFound, "", err)
+			}
+			grant.TargetID = user.ID
+		case model.ShareGrantGroup:
+			id, err := strconv.ParseUint(option.Target, 10, 32)
+			if err != nil {
+				return nil, serializer.NewError(serializer.CodeGroupNotFound, "", err)
+			}
+			group, err := model.GetGroupByID(id)
+			if err != nil {
+				return nil, serializer.NewError(serializer.CodeGroupNotFound, "", err)
+			}
+			grant.TargetID = group.ID
+		}
+
+		grants = append(grants, grant)
+	}
+
+	return grants, nil
+}
+
+// List 列出分享的授权
+func (service *ShareGrantService) List(c *gin.Context) serializer.Response {
+	shareCtx, _ := c.Get("share")
+	share := shareCtx.(*model.Share)
+
+	grants, err := share.Grants()
+	if err != nil {
+		return serializer.DBErr("Failed to list share grants", err)
+	}
+
+	return serializer.BuildShareGrants(grants)
+}
+
+// Update 替换分享的全部授权
+func (service *ShareGrantService) Update(c *gin.Context) serializer.Response {
+	shareCtx, _ := c.Get("share")
+	share := shareCtx.(*model.Share)
+
+	if service.Public && len(service.Grants) > 0 {
+		return serializer.ParamErr("Public shares cannot have grants", nil)
+	}
+
+	grants, err := buildGrants(service.Grants, share.IsDir)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+
+	if err := share.SetGrants(grants); err != nil {
+		return serializer.DBErr("Failed to update share grants", err)
+	}
+
+	// 内部分享不使用密码，撤销全部授权后仍保持为内部分享，避免分享被意外公开
+	props := map[string]interface{}{"internal": !service.Public}
+	if !service.Public {
+		props["password"] = ""
+	}
+	if err := share.Update(props); err != nil {
+		return serializer.DBErr("Failed to update share record", err)
+	}
+
+	return serializer.BuildShareGrants(grants)
+}
+
+// List 列出他人授权给当前用户的分享
+func (service *ReceivedShareListService) List(c *gin.Context, user *model.User) serializer.Response {
+	shares, total := model.ListReceivedShares(user, int(service.Page), 18, service.OrderBy+" "+service.Order)
+	for i := 0; i < len(shares); i++ {
+		shares[i].Source()
+	}
+
+	return serializer.BuildReceivedShareList(shares, total, user)
+}
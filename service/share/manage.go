@@ -2,11 +2,14 @@ package share
 
 import (
 	"net/url"
+	"strings"
 	"time"
 
 	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/audit"
 	"github.com/cloudreve/Cloudreve/v3/pkg/hashid"
 	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/cloudreve/Cloudreve/v3/pkg/webhook"
 	"github.com/gin-gonic/gin"
 )
 
@@ -18,11 +21,24 @@ type ShareCreateService struct {
 	RemainDownloads int    `json:"downloads"`
 	Expire          int    `json:"expire"`
 	Preview         bool   `json:"preview"`
+	// Upload 不为空时创建允许访客上传的收集文件分享，仅目录可用
+	Upload *ShareUploadOptions `json:"upload"`
+	// Grants 不为空时创建仅授权用户及用户组可访问的内部分享
+	Grants []ShareGrantOption `json:"grants" binding:"max=100,dive"`
+}
+
+// ShareUploadOptions 收集文件分享的上传限制
+type ShareUploadOptions struct {
+	MaxSize      uint64 `json:"max_size"`
+	MaxFiles     int    `json:"max_files" binding:"min=0"`
+	Exts         string `json:"exts" binding:"max=255"`
+	HideContents bool   `json:"hide_contents"`
+	Notify       bool   `json:"notify"`
 }
 
 // ShareUpdateService 分享更新服务
 type ShareUpdateService struct {
-	Prop  string `json:"prop" binding:"required,eq=password|eq=preview_enabled"`
+	Prop  string `json:"prop" binding:"required,eq=password|eq=preview_enabled|eq=upload_enabled|eq=upload_hide_contents|eq=upload_notify"`
 	Value string `json:"value" binding:"max=255"`
 }
 
@@ -47,13 +63,21 @@ func (service *ShareUpdateService) Update(c *gin.Context) serializer.Response {
 
 	switch service.Prop {
 	case "password":
-		err := share.Update(map[string]interface{}{"password": service.Value})
+		if err := share.SetPassword(service.Value); err != nil {
+			return serializer.Err(serializer.CodeEncryptError, "Failed to hash share password", err)
+		}
+		err := share.Update(map[string]interface{}{"password": share.Password})
 		if err != nil {
 			return serializer.DBErr("Failed to update share record", err)
 		}
-	case "preview_enabled":
+	case "upload_enabled":
+		if !share.IsDir {
+			return serializer.ParamErr("Only folder shares can accept uploads", nil)
+		}
+		fallthrough
+	case "preview_enabled", "upload_hide_contents", "upload_notify":
 		value := service.Value == "true"
-		err := share.Update(map[string]interface{}{"preview_enabled": value})
+		err := share.Update(map[string]interface{}{service.Prop: value})
 		if err != nil {
 			return serializer.DBErr("Failed to update share record", err)
 		}
@@ -112,8 +136,16 @@ func (service *ShareCreateService) Create(c *gin.Context) serializer.Response {
 		return serializer.Err(serializer.CodeNotFound, "", nil)
 	}
 
+	if service.Upload != nil && !service.IsDir {
+		return serializer.ParamErr("Only folder shares can accept uploads", nil)
+	}
+
+	grants, err := buildGrants(service.Grants, service.IsDir)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+
 	newShare := model.Share{
-		Password:        service.Password,
 		IsDir:           service.IsDir,
 		UserID:          user.ID,
 		SourceID:        sourceID,
@@ -129,18 +161,63 @@ func (service *ShareCreateService) Create(c *gin.Context) serializer.Response {
 		newShare.Expires = &expires
 	}
 
+	// 内部分享不使用密码
+	if len(grants) > 0 {
+		newShare.Internal = true
+	} else if err := newShare.SetPassword(service.Password); err != nil {
+		return serializer.Err(serializer.CodeEncryptError, "Failed to hash share password", err)
+	}
+
+	// 收集文件分享
+	if service.Upload != nil {
+		newShare.UploadEnabled = true
+		newShare.UploadMaxSize = service.Upload.MaxSize
+		newShare.UploadMaxFiles = service.Upload.MaxFiles
+		newShare.UploadExts = normalizeExts(service.Upload.Exts)
+		newShare.UploadHideContents = service.Upload.HideContents
+		newShare.UploadNotify = service.Upload.Notify
+
+		// 收集文件分享不限制下载次数时也可设置过期时间
+		if newShare.Expires == nil && service.Expire > 0 {
+			expires := time.Now().Add(time.Duration(service.Expire) * time.Second)
+			newShare.Expires = &expires
+		}
+	}
+
 	// 创建分享
 	id, err := newShare.Create()
 	if err != nil {
 		return serializer.DBErr("Failed to create share link record", err)
 	}
 
+	if len(grants) > 0 {
+		if err := newShare.SetGrants(grants); err != nil {
+			newShare.Delete()
+			return serializer.DBErr("Failed to create share grants", err)
+		}
+	}
+
 	// 获取分享的唯一id
 	uid := hashid.HashID(id, hashid.ShareID)
+	audit.Record(c, audit.Event{
+		Action:     audit.ActionShareCreate,
+		User:       user,
+		TargetType: audit.TargetShare,
+		TargetID:   id,
+		Target:     uid,
+		Detail:     sourceName,
+	})
 	// 最终得到分享链接
 	siteURL := model.GetSiteURL()
 	sharePath, _ := url.Parse("/s/" + uid)
 	shareURL := siteURL.ResolveReference(sharePath)
+	webhook.Publish(c, webhook.EventShareCreated, user.ID, map[string]interface{}{
+		"id":          uid,
+		"url":         shareURL.String(),
+		"source_name": sourceName,
+		"is_dir":      newShare.IsDir,
+		"expires":     newShare.Expires,
+	})
 
 	return serializer.Response{
 		Code: 0,
@@ -148,3 +225,16 @@ func (service *ShareCreateService) Create(c *gin.Context) serializer.Response {
 	}
 
 }
+
+// normalizeExts 规范化以逗号分隔的扩展名列表
+func normalizeExts(exts string) string {
+	res := make([]string, 0)
+	for _, ext := range strings.Split(exts, ",") {
+		ext = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(ext), "."))
+		if ext != "" {
+			res = append(res, ext)
+		}
+	}
+
+	return strings.Join(res, ",")
+}
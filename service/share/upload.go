@@ -0,0 +1,145 @@
+package share
+
+import (
+	"context"
+	"io/ioutil"
+	"path"
+	"strings"
+	"time"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem"
+	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/cloudreve/Cloudreve/v3/service/explorer"
+	"github.com/gin-gonic/gin"
+)
+
+// UploadSessionService 访客向收集文件分享创建上传会话的服务，Path 为分享目录下的相对路径
+type UploadSessionService struct {
+	Path         string `json:"path" binding:"required,max=65535"`
+	Size         uint64 `json:"size" binding:"min=0"`
+	Name         string `json:"name" binding:"required"`
+	LastModified int64  `json:"last_modified"`
+	MimeType     string `json:"mime_type"`
+}
+
+// UploadService 访客向收集文件分享上传本机策略分片的服务
+type UploadService struct {
+	ID    string `uri:"sessionId" binding:"required"`
+	Index int    `uri:"index" binding:"min=0"`
+}
+
+// UploadSessionDeleteService 访客取消向收集文件分享上传的服务
+type UploadSessionDeleteService struct {
+	ID string `uri:"sessionId" binding:"required"`
+}
+
+// Create 以分享创建者的身份创建上传会话，上传的文件计入创建者的容量
+func (service *UploadSessionService) Create(ctx context.Context, c *gin.Context) serializer.Response {
+	shareCtx, _ := c.Get("share")
+	share := shareCtx.(*model.Share)
+	limited := isUploadLimited(c)
+
+	if limited && !share.CanUploadFile(service.Name) {
+		return serializer.Err(serializer.CodeShareUploadNotAllowed, "File type is not allowed", nil)
+	}
+
+	// 将访客提交的相对路径转换为创建者的完整路径
+	dst, err := sharedPath(share, service.Path)
+	if err != nil {
+		return serializer.DBErr("Failed to trace shared folder", err)
+	}
+
+	// 检查分享的上传配额，配额在上传完成后才计入，未完成的上传不会占用配额
+	if limited && !share.HasUploadQuota(service.Size) {
+		return serializer.Err(serializer.CodeShareUploadQuotaExceeded, "", nil)
+	}
+
+	fs, err := filesystem.NewFileSystem(share.Creator())
+	if err != nil {
+		return serializer.Err(serializer.CodeCreateFSError, "", err)
+	}
+	defer fs.Recycle()
+
+	file := &fsctx.FileStream{
+		Size:        service.Size,
+		Name:        service.Name,
+		VirtualPath: dst,
+		File:        ioutil.NopCloser(strings.NewReader("")),
+		MimeType:    service.MimeType,
+	}
+	if service.LastModified > 0 {
+		lastModified := time.UnixMilli(service.LastModified)
+		file.LastModified = &lastModified
+	}
+
+	credential, err := fs.CreateUploadSession(context.WithValue(ctx, fsctx.UploadShareCtx, share.ID), file)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+
+	return serializer.Response{
+		Code: 0,
+		Data: credential,
+	}
+}
+
+// Upload 处理访客上传的本机策略分片
+func (service *UploadService) Upload(ctx context.Context, c *gin.Context) serializer.Response {
+	shareCtx, _ := c.Get("share")
+	share := shareCtx.(*model.Share)
+
+	if _, ok := shareUploadSession(share, service.ID); !ok {
+		return serializer.Err(serializer.CodeUploadSessionExpired, "", nil)
+	}
+
+	// 后续以分享创建者的身份处理上传
+	c.Set("user", share.Creator())
+	upload := &explorer.UploadService{ID: service.ID, Index: service.Index}
+	return upload.LocalUpload(ctx, c)
+}
+
+// Delete 取消访客发起的上传
+func (service *UploadSessionDeleteService) Delete(ctx context.Context, c *gin.Context) serializer.Response {
+	shareCtx, _ := c.Get("share")
+	share := shareCtx.(*model.Share)
+
+	if _, ok := shareUploadSession(share, service.ID); !ok {
+		return serializer.Err(serializer.CodeUploadSessionExpired, "", nil)
+	}
+
+	c.Set("user", share.Creator())
+	return (&explorer.UploadSessionService{ID: service.ID}).Delete(ctx, c)
+}
+
+// isUploadLimited 返回当前用户的上传是否受收集文件分享的限制，有编辑权限的用户不受限制
+func isUploadLimited(c *gin.Context) bool {
+	return c.GetInt("share_permission") < model.SharePermissionEdit
+}
+
+// sharedPath 将分享目录下的相对路径转换为分享者文件系统中的完整路径
+func sharedPath(share *model.Share, p string) (string, error) {
+	folder := share.SourceFolder()
+	if err := folder.TraceRoot(); err != nil {
+		return "", err
+	}
+
+	return path.Join(folder.Position, folder.Name, path.Clean("/"+p)), nil
+}
+
+// shareUploadSession 查找由给定分享发起的上传会话
+func shareUploadSession(share *model.Share, id string) (*serializer.UploadSession, bool) {
+	sessionRaw, ok := cache.Get(filesystem.UploadSessionCachePrefix + id)
+	if !ok {
+		return nil, false
+	}
+
+	session := sessionRaw.(serializer.UploadSession)
+	if session.ShareID != share.ID || session.UID != share.UserID {
+		return nil, false
+	}
+
+	return &session, true
+}
@@ -2,16 +2,20 @@ package share
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"path"
 
 	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/audit"
+	"github.com/cloudreve/Cloudreve/v3/pkg/email"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem"
 	"github.com/cloudreve/Cloudreve/v3/pkg/filesystem/fsctx"
 	"github.com/cloudreve/Cloudreve/v3/pkg/hashid"
 	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
 	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+	"github.com/cloudreve/Cloudreve/v3/pkg/webhook"
 	"github.com/cloudreve/Cloudreve/v3/service/explorer"
 	"github.com/gin-gonic/gin"
 )
@@ -40,6 +44,15 @@ type ArchiveService struct {
 	Dirs  []string `json:"dirs"`
 }
 
+// SaveService 分享转存服务，Items 及 Dirs 为分享目录中 Path 下要转存的对象，
+// 均为空时转存整个分享；Dst 为转存至的目录
+type SaveService struct {
+	Path  string   `json:"path" binding:"max=65535"`
+	Items []string `json:"items"`
+	Dirs  []string `json:"dirs"`
+	Dst   string   `json:"dst" binding:"required,min=1,max=65535"`
+}
+
 // ShareListService 列出分享
 type ShareListService struct {
 	Page     uint   `form:"page" binding:"required,min=1"`
@@ -126,20 +139,32 @@ func (service *ShareGetService) Get(c *gin.Context) serializer.Response {
 		unlocked = util.GetSession(c, sessionKey) != nil
 		if !unlocked && service.Password != "" {
 			// 如果未解锁，且指定了密码，则尝试解锁
-			if service.Password == share.Password {
+			if share.UnlockBlocked(c.ClientIP()) {
+				return serializer.Err(serializer.CodeShareUnlockLimited, "Too many failed attempts, please try again later", nil)
+			}
+
+			if share.CheckPassword(service.Password) {
 				unlocked = true
 				util.SetSession(c, map[string]interface{}{sessionKey: true})
+			} else {
+				unlockFailed(c, share)
 			}
 		}
 	}
 
 	if unlocked {
 		share.Viewed()
+		recordAccess(c, share, model.ShareAccessView, nil, "")
+	}
+
+	res := serializer.BuildShareResponse(share, unlocked)
+	if unlocked {
+		res.Permission = serializer.SharePermissionNames[c.GetInt("share_permission")]
 	}
 
 	return serializer.Response{
 		Code: 0,
-		Data: serializer.BuildShareResponse(share, unlocked),
+		Data: res,
 	}
 }
 
@@ -163,7 +188,7 @@ func (service *Service) CreateDownloadSession(c *gin.Context) serializer.Respons
 		return serializer.Err(serializer.CodeFileNotFound, "", err)
 	}
 
-	ctx := context.Background()
+	ctx := util.RequestContext(c)
 
 	// 重设根目录
 	if share.IsDir {
@@ -182,6 +207,8 @@ func (service *Service) CreateDownloadSession(c *gin.Context) serializer.Respons
 		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
 	}
 
+	recordAccess(c, share, model.ShareAccessDownload, &fs.FileTarget[0], service.Path)
+
 	return serializer.Response{
 		Code: 0,
 		Data: downloadURL,
@@ -203,7 +230,16 @@ func (service *Service) PreviewContent(ctx context.Context, c *gin.Context, isTe
 	}
 	subService := explorer.FileIDService{}
 
-	return subService.PreviewContent(ctx, c, isText)
+	res := subService.PreviewContent(ctx, c, isText)
+	if res.Code <= 0 {
+		var file *model.File
+		if !share.IsDir {
+			file = share.SourceFile()
+		}
+		recordAccess(c, share, model.ShareAccessPreview, file, service.Path)
+	}
+
+	return res
 }
 
 // CreateDocPreviewSession 创建Office预览会话，返回预览地址
@@ -212,7 +248,7 @@ func (service *Service) CreateDocPreviewSession(c *gin.Context) serializer.Respo
 	share := shareCtx.(*model.Share)
 
 	// 用于调下层service
-	ctx := context.Background()
+	ctx := util.RequestContext(c)
 	if share.IsDir {
 		ctx = context.WithValue(ctx, fsctx.FolderModelCtx, share.Source())
 		ctx = context.WithValue(ctx, fsctx.PathCtx, service.Path)
@@ -245,7 +281,7 @@ func (service *Service) List(c *gin.Context) serializer.Response {
 	defer fs.Recycle()
 
 	// 上下文
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(util.RequestContext(c))
 	defer cancel()
 
 	// 重设根目录
@@ -292,7 +328,7 @@ func (service *Service) Thumb(c *gin.Context) serializer.Response {
 		return serializer.Err(serializer.CodeParentNotExist, "", nil)
 	}
 
-	ctx := context.WithValue(context.Background(), fsctx.LimitParentCtx, parent)
+	ctx := context.WithValue(util.RequestContext(c), fsctx.LimitParentCtx, parent)
 
 	// 获取文件ID
 	fileID, err := hashid.DecodeHashID(c.Param("file"), hashid.FileID)
@@ -352,7 +388,7 @@ func (service *ArchiveService) Archive(c *gin.Context) serializer.Response {
 	}
 
 	// 限制操作范围为父目录下
-	ctx := context.WithValue(context.Background(), fsctx.LimitParentCtx, parent)
+	ctx := context.WithValue(util.RequestContext(c), fsctx.LimitParentCtx, parent)
 
 	// 用于调下层service
 	tempUser := share.Creator()
@@ -364,7 +400,98 @@ func (service *ArchiveService) Archive(c *gin.Context) serializer.Response {
 		Items: service.Items,
 	}
 
-	return subService.Archive(ctx, c)
+	res := subService.Archive(ctx, c)
+	if res.Code == 0 {
+		recordAccess(c, share, model.ShareAccessArchive, nil, service.Path)
+	}
+
+	return res
+}
+
+// Save 将分享的文件或目录转存至当前用户的目录下
+func (service *SaveService) Save(c *gin.Context) serializer.Response {
+	shareCtx, _ := c.Get("share")
+	share := shareCtx.(*model.Share)
+	userCtx, _ := c.Get("user")
+	user := userCtx.(*model.User)
+
+	// 创建文件系统
+	fs, err := filesystem.NewFileSystem(user)
+	if err != nil {
+		return serializer.Err(serializer.CodeCreateFSError, "", err)
+	}
+	defer fs.Recycle()
+
+	// 未选定对象时转存整个分享
+	if len(service.Items) == 0 && len(service.Dirs) == 0 {
+		err = fs.SetTargetByInterface(share.Source())
+	} else {
+		err = service.setTargets(fs, share)
+	}
+	if err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+
+	if err := fs.SaveTo(util.RequestContext(c), service.Dst); err != nil {
+		return serializer.Err(serializer.CodeNotSet, err.Error(), err)
+	}
+
+	recordAccess(c, share, model.ShareAccessSave, nil, service.Path)
+	return serializer.Response{}
+}
+
+// setTargets 将分享目录中 Path 下选定的对象设为转存目标
+func (service *SaveService) setTargets(fs *filesystem.FileSystem, share *model.Share) error {
+	dirs, files, err := sharedObjects(share, service.Path, service.Items, service.Dirs)
+	if err != nil {
+		return err
+	}
+
+	fs.SetTargetDir(&dirs)
+	fs.SetTargetFile(&files)
+	return nil
+}
+
+// sharedObjects 查找分享目录中 parentPath 下选定的目录及文件，Items 及 Dirs 为对象的 HashID
+func sharedObjects(share *model.Share, parentPath string, items, dirIDs []string) ([]model.Folder, []model.File, error) {
+	if !share.IsDir {
+		return nil, nil, serializer.NewError(serializer.CodeParamErr, "Only items in a shared folder can be selected", nil)
+	}
+
+	// 在分享者的文件系统中找到选定对象的父目录
+	src := &filesystem.FileSystem{User: share.Creator()}
+	src.Root = share.Source().(*model.Folder)
+	exist, parent := src.IsPathExist(parentPath)
+	if !exist {
+		return nil, nil, filesystem.ErrPathNotExist
+	}
+
+	ids := (&explorer.ItemIDService{Items: items, Dirs: dirIDs}).Raw()
+	dirs, err := model.GetFoldersByIDs(ids.Dirs, share.UserID)
+	if err != nil {
+		return nil, nil, filesystem.ErrDBListObjects.WithError(err)
+	}
+	files, err := model.GetFilesByIDs(ids.Items, share.UserID)
+	if err != nil {
+		return nil, nil, filesystem.ErrDBListObjects.WithError(err)
+	}
+
+	// 选定对象须位于父目录下
+	if len(dirs) != len(dirIDs) || len(files) != len(items) {
+		return nil, nil, filesystem.ErrObjectNotExist
+	}
+	for _, dir := range dirs {
+		if dir.ParentID == nil || *dir.ParentID != parent.ID {
+			return nil, nil, filesystem.ErrObjectNotExist
+		}
+	}
+	for _, file := range files {
+		if file.FolderID != parent.ID {
+			return nil, nil, filesystem.ErrObjectNotExist
+		}
+	}
+
+	return dirs, files, nil
 }
 
 // SearchService 对分享的目录进行搜索
@@ -393,7 +520,7 @@ func (service *SearchService) Search(c *gin.Context) serializer.Response {
 	defer fs.Recycle()
 
 	// 上下文
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(util.RequestContext(c))
 	defer cancel()
 
 	// 重设根目录
@@ -411,5 +538,47 @@ func (service *SearchService) Search(c *gin.Context) serializer.Response {
 	// 分享Key上下文
 	ctx = context.WithValue(ctx, fsctx.ShareKeyCtx, hashid.HashID(share.ID, hashid.ShareID))
 
-	return service.SearchKeywords(c, fs, "%"+service.Keywords+"%")
+	return service.SearchIn(ctx, fs)
+}
+
+// recordAccess 记录当前访问者对分享的访问
+func recordAccess(c *gin.Context, share *model.Share, action string, file *model.File, path string) {
+	var user *model.User
+	if userCtx, ok := c.Get("user"); ok {
+		user, _ = userCtx.(*model.User)
+	}
+
+	share.RecordAccess(c, user, action, file, path)
+}
+
+// unlockFailed 记录错误的分享密码尝试，分享被频繁尝试时通知创建者
+func unlockFailed(c *gin.Context, share *model.Share) {
+	shareKey := hashid.HashID(share.ID, hashid.ShareID)
+	audit.Record(c, audit.Event{
+		Action:     audit.ActionShareAccess,
+		TargetType: audit.TargetShare,
+		TargetID:   share.ID,
+		Target:     shareKey,
+		Err:        errors.New("wrong share password"),
+	})
+
+	attempts, alert := share.RecordUnlockFailure(c.ClientIP())
+	if !alert {
+		return
+	}
+
+	webhook.Publish(c, webhook.EventShareAttacked, share.UserID, map[string]interface{}{
+		"id":          shareKey,
+		"source_name": share.SourceName,
+		"attempts":    attempts,
+		"ip":          c.ClientIP(),
+	})
+
+	owner := share.Creator()
+	title, body := email.NewShareAttackEmail(owner.Nick, share.SourceName, c.ClientIP(), attempts)
+	go func() {
+		if err := email.Send(owner.Email, title, body); err != nil {
+			util.Log().Warning("Failed to send share attack notification to %q: %s", owner.Email, err)
+		}
+	}()
 }
@@ -1,9 +1,12 @@
 package user
 
 import (
+	"errors"
 	"fmt"
 	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/audit"
 	"github.com/cloudreve/Cloudreve/v3/pkg/auth"
+	"github.com/cloudreve/Cloudreve/v3/pkg/authprovider"
 	"github.com/cloudreve/Cloudreve/v3/pkg/cache"
 	"github.com/cloudreve/Cloudreve/v3/pkg/email"
 	"github.com/cloudreve/Cloudreve/v3/pkg/hashid"
@@ -18,7 +21,7 @@ import (
 // UserLoginService 管理用户登录的服务
 type UserLoginService struct {
 	//TODO 细致调整验证规则
-	UserName string `form:"userName" json:"userName" binding:"required,email"`
+	UserName string `form:"userName" json:"userName" binding:"required,max=255"` // 邮箱，启用目录服务时也可以是目录中的登录名
 	Password string `form:"Password" json:"Password" binding:"required,min=4,max=64"`
 }
 
@@ -55,7 +58,15 @@ func (service *UserResetService) Reset(c *gin.Context) serializer.Response {
 	}
 
 	user.SetPassword(service.Password)
-	if err := user.Update(map[string]interface{}{"password": user.Password}); err != nil {
+	err = user.Update(map[string]interface{}{"password": user.Password})
+	audit.Record(c, audit.Event{
+		Action:     audit.ActionPasswordReset,
+		User:       &user,
+		TargetType: audit.TargetUser,
+		TargetID:   user.ID,
+		Err:        err,
+	})
+	if err != nil {
 		return serializer.DBErr("Failed to reset password", err)
 	}
 
@@ -108,7 +119,8 @@ func (service *Enable2FA) Login(c *gin.Context) serializer.Response {
 
 		// 验证二步验证代码
 		if !totp.Validate(service.Code, expectedUser.TwoFactor) {
-			return serializer.Err(serializer.Code2FACodeErr, "2FA code not correct", nil)
+			return auditLogin(c, audit.ActionLogin2FA, &expectedUser, "",
+				serializer.Err(serializer.Code2FACodeErr, "2FA code not correct", nil))
 		}
 
 		//登陆成功，清空并设置session
@@ -117,7 +129,7 @@ func (service *Enable2FA) Login(c *gin.Context) serializer.Response {
 			"user_id": expectedUser.ID,
 		})
 
-		return serializer.BuildUserResponse(expectedUser)
+		return auditLogin(c, audit.ActionLogin2FA, &expectedUser, "", serializer.BuildUserResponse(expectedUser))
 	}
 
 	return serializer.Err(serializer.CodeLoginSessionNotExist, "Login session not exist", nil)
@@ -125,19 +137,23 @@ func (service *Enable2FA) Login(c *gin.Context) serializer.Response {
 
 // Login 用户登录函数
 func (service *UserLoginService) Login(c *gin.Context) serializer.Response {
-	expectedUser, err := model.GetUserByEmail(service.UserName)
+	user, res := service.login(c)
+	return auditLogin(c, audit.ActionLogin, user, service.UserName, res)
+}
+
+// login 验证用户凭据并设置session，返回登录的用户及响应
+func (service *UserLoginService) login(c *gin.Context) (*model.User, serializer.Response) {
+	user, err := authprovider.Login().Authenticate(c, service.UserName, service.Password)
 	// 一系列校验
 	if err != nil {
-		return serializer.Err(serializer.CodeCredentialInvalid, "Wrong password or email address", err)
-	}
-	if authOK, _ := expectedUser.CheckPassword(service.Password); !authOK {
-		return serializer.Err(serializer.CodeCredentialInvalid, "Wrong password or email address", nil)
+		return nil, serializer.Err(serializer.CodeCredentialInvalid, "Wrong password or email address", err)
 	}
+	expectedUser := *user
 	if expectedUser.Status == model.Baned || expectedUser.Status == model.OveruseBaned {
-		return serializer.Err(serializer.CodeUserBaned, "This account has been blocked", nil)
+		return user, serializer.Err(serializer.CodeUserBaned, "This account has been blocked", nil)
 	}
 	if expectedUser.Status == model.NotActivicated {
-		return serializer.Err(serializer.CodeUserNotActivated, "This account is not activated", nil)
+		return user, serializer.Err(serializer.CodeUserNotActivated, "This account is not activated", nil)
 	}
 
 	if expectedUser.TwoFactor != "" {
@@ -145,7 +161,7 @@ func (service *UserLoginService) Login(c *gin.Context) serializer.Response {
 		util.SetSession(c, map[string]interface{}{
 			"2fa_user_id": expectedUser.ID,
 		})
-		return serializer.Response{Code: 203}
+		return user, serializer.Response{Code: 203}
 	}
 
 	//登陆成功，清空并设置session
@@ -153,8 +169,28 @@ func (service *UserLoginService) Login(c *gin.Context) serializer.Response {
 		"user_id": expectedUser.ID,
 	})
 
-	return serializer.BuildUserResponse(expectedUser)
+	return user, serializer.BuildUserResponse(expectedUser)
+
+}
+
+// auditLogin 记录登录事件，需要二步验证时记录为成功，其他非成功响应记录为失败
+func auditLogin(c *gin.Context, action string, user *model.User, actor string, res serializer.Response) serializer.Response {
+	event := audit.Event{Action: action, User: user, Actor: actor}
+	if user != nil {
+		event.TargetType = audit.TargetUser
+		event.TargetID = user.ID
+	}
+
+	switch res.Code {
+	case 0:
+	case 203:
+		event.Detail = "2FA required"
+	default:
+		event.Err = errors.New(res.Msg)
+	}
 
+	audit.Record(c, event)
+	return res
 }
 
 // CopySessionService service for copy user session
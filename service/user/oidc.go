@@ -0,0 +1,307 @@
+package user
+
+import (
+	"encoding/json"
+	"strings"
+
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/audit"
+	"github.com/cloudreve/Cloudreve/v3/pkg/oidc"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/cloudreve/Cloudreve/v3/pkg/util"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+// OIDCLoginService 发起 OpenID Connect 登录或绑定的服务
+type OIDCLoginService struct {
+}
+
+// OIDCCallbackService OpenID Connect 授权回调服务
+type OIDCCallbackService struct {
+	Code     string `form:"code"`
+	State    string `form:"state"`
+	Error    string `form:"error"`
+	ErrorMsg string `form:"error_description"`
+}
+
+// OIDCIdentityService 外部身份绑定管理服务
+type OIDCIdentityService struct {
+}
+
+// oidcGroupMapping 外部身份的用户组映射规则
+type oidcGroupMapping struct {
+	Value string `json:"value"` // 声明值
+	Group uint   `json:"group"` // 对应的用户组ID
+}
+
+// Login 生成 OpenID Connect 登录地址
+func (service *OIDCLoginService) Login(c *gin.Context) serializer.Response {
+	return service.authURL(c, 0)
+}
+
+// Link 生成绑定外部身份的授权地址
+func (service *OIDCLoginService) Link(c *gin.Context, user *model.User) serializer.Response {
+	return service.authURL(c, user.ID)
+}
+
+func (service *OIDCLoginService) authURL(c *gin.Context, linkUID uint) serializer.Response {
+	client := oidc.Current()
+	if client == nil {
+		return serializer.Err(serializer.CodeFeatureNotEnabled, "OIDC login is not enabled", nil)
+	}
+
+	state, nonce, verifier := oidc.RandomString(), oidc.RandomString(), oidc.RandomString()
+	res, err := client.AuthURL(c, state, nonce, verifier)
+	if err != nil {
+		return serializer.Err(serializer.CodeInternalSetting, "Failed to build OIDC authorization URL", err)
+	}
+
+	util.SetSession(c, map[string]interface{}{
+		"oidc_state":    state,
+		"oidc_nonce":    nonce,
+		"oidc_verifier": verifier,
+		"oidc_link_uid": linkUID,
+	})
+
+	return serializer.Response{Data: res}
+}
+
+// Callback 处理授权回调，登录、绑定或创建用户。
+// 返回值中的 bool 表示本次请求是否为绑定操作
+func (service *OIDCCallbackService) Callback(c *gin.Context) (serializer.Response, bool) {
+	state, _ := util.GetSession(c, "oidc_state").(string)
+	nonce, _ := util.GetSession(c, "oidc_nonce").(string)
+	verifier, _ := util.GetSession(c, "oidc_verifier").(string)
+	linkUID, _ := util.GetSession(c, "oidc_link_uid").(uint)
+	util.DeleteSession(c, "oidc_state")
+	util.DeleteSession(c, "oidc_nonce")
+	util.DeleteSession(c, "oidc_verifier")
+	util.DeleteSession(c, "oidc_link_uid")
+	linking := linkUID != 0
+
+	if state == "" || service.State != state {
+		return serializer.Err(serializer.CodeLoginSessionNotExist, "Login session not exist", nil), linking
+	}
+
+	if service.Error != "" {
+		return serializer.Err(serializer.CodeCredentialInvalid, service.ErrorMsg, nil), linking
+	}
+
+	client := oidc.Current()
+	if client == nil {
+		return serializer.Err(serializer.CodeFeatureNotEnabled, "OIDC login is not enabled", nil), linking
+	}
+
+	claims, err := client.Exchange(c, service.Code, verifier, nonce)
+	if err != nil {
+		return serializer.Err(serializer.CodeCredentialInvalid, "Failed to verify external identity", err), linking
+	}
+
+	if linking {
+		return linkIdentity(linkUID, client.Issuer(), claims), linking
+	}
+
+	actor := claims.Email
+	if actor == "" {
+		actor = claims.Subject
+	}
+
+	user, res := loginWithIdentity(c, client.Issuer(), claims)
+	return auditLogin(c, audit.ActionLogin, user, actor, res), linking
+}
+
+// linkIdentity 将外部身份绑定到已登录的用户
+func linkIdentity(uid uint, issuer string, claims *oidc.Claims) serializer.Response {
+	if identity, err := model.GetUserIdentity(issuer, claims.Subject); err == nil {
+		if identity.UserID == uid {
+			return serializer.Response{}
+		}
+		return serializer.Err(serializer.CodeIdentityBindOtherAccount, "This identity is linked to another account", nil)
+	}
+
+	identities, err := model.ListUserIdentities(uid)
+	if err != nil {
+		return serializer.DBErr("Failed to list linked identities", err)
+	}
+	for _, identity := range identities {
+		if identity.Issuer == issuer {
+			return serializer.Err(serializer.CodeIdentityBindConflict, "Another identity is already linked", nil)
+		}
+	}
+
+	identity := &model.UserIdentity{
+		UserID:  uid,
+		Issuer:  issuer,
+		Subject: claims.Subject,
+		Email:   claims.Email,
+	}
+	if err := identity.Create(); err != nil {
+		return serializer.DBErr("Failed to link identity", err)
+	}
+
+	return serializer.Response{}
+}
+
+// loginWithIdentity 使用外部身份登录，未绑定时自动创建用户
+func loginWithIdentity(c *gin.Context, issuer string, claims *oidc.Claims) (*model.User, serializer.Response) {
+	options := model.GetSettingByNames("oidc_auto_register", "oidc_group_claim", "oidc_group_mapping")
+
+	var user model.User
+	identity, err := model.GetUserIdentity(issuer, claims.Subject)
+	if err == nil {
+		user, err = model.GetUserByID(identity.UserID)
+		if err != nil {
+			return nil, serializer.Err(serializer.CodeUserNotFound, "User not found", err)
+		}
+	} else if gorm.IsRecordNotFoundError(err) {
+		if !model.IsTrueVal(options["oidc_auto_register"]) {
+			return nil, serializer.Err(serializer.CodeIdentityNotLinked, "This identity is not linked to any account", nil)
+		}
+
+		user, err = registerWithIdentity(issuer, claims)
+		if err != nil {
+			return nil, serializer.DBErr("Failed to create user", err)
+		}
+	} else {
+		return nil, serializer.DBErr("Failed to find linked identity", err)
+	}
+
+	if user.Status == model.Baned || user.Status == model.OveruseBaned {
+		return &user, serializer.Err(serializer.CodeUserBaned, "This account has been blocked", nil)
+	}
+	if user.Status == model.NotActivicated {
+		return &user, serializer.Err(serializer.CodeUserNotActivated, "This account is not activated", nil)
+	}
+
+	// 根据身份提供方的声明同步用户组
+	if group := mapIdentityGroup(claims, options["oidc_group_claim"], options["oidc_group_mapping"]); group != 0 &&
+		group != user.GroupID && user.ID != 1 {
+		if _, err := model.GetGroupByID(group); err == nil {
+			if err := user.Update(map[string]interface{}{"group_id": group}); err != nil {
+				return &user, serializer.DBErr("Failed to update user group", err)
+			}
+			user, _ = model.GetUserByID(user.ID)
+		} else {
+			util.Log().WithContext(c).Warning("OIDC group mapping refers to a nonexistent group %d.", group)
+		}
+	}
+
+	if user.TwoFactor != "" {
+		// 需要二步验证
+		util.SetSession(c, map[string]interface{}{
+			"2fa_user_id": user.ID,
+		})
+		return &user, serializer.Response{Code: 203}
+	}
+
+	util.SetSession(c, map[string]interface{}{
+		"user_id": user.ID,
+	})
+
+	return &user, serializer.BuildUserResponse(user)
+}
+
+// registerWithIdentity 为外部身份创建新用户并绑定
+func registerWithIdentity(issuer string, claims *oidc.Claims) (model.User, error) {
+	if claims.Email == "" {
+		return model.User{}, serializer.NewError(serializer.CodeParamErr, "External identity has no email", nil)
+	}
+	if claims.EmailVerified != nil && !*claims.EmailVerified {
+		return model.User{}, serializer.NewError(serializer.CodeParamErr, "Email of external identity is not verified", nil)
+	}
+
+	// 已有同邮箱的本地账号时，需登录后手动绑定，避免账号被接管
+	if _, err := model.GetUserByEmail(claims.Email); err == nil {
+		return model.User{}, serializer.NewError(serializer.CodeEmailExisted, "Email already in use, please login and link this identity in settings", nil)
+	}
+
+	user := model.NewUser()
+	user.Email = claims.Email
+	user.Nick = claims.Name
+	if user.Nick == "" {
+		user.Nick = claims.PreferredUsername
+	}
+	if user.Nick == "" {
+		user.Nick = strings.Split(claims.Email, "@")[0]
+	}
+	user.SetPassword(util.RandStringRunes(32))
+	user.Status = model.Active
+	user.GroupID = uint(model.GetIntSetting("oidc_default_group", 2))
+
+	tx := model.DB.Begin()
+	if err := tx.Create(&user).Error; err != nil {
+		tx.Rollback()
+		return model.User{}, err
+	}
+	identity := &model.UserIdentity{
+		UserID:  user.ID,
+		Issuer:  issuer,
+		Subject: claims.Subject,
+		Email:   claims.Email,
+	}
+	if err := tx.Create(identity).Error; err != nil {
+		tx.Rollback()
+		return model.User{}, err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return model.User{}, err
+	}
+
+	return model.GetUserByID(user.ID)
+}
+
+// mapIdentityGroup 返回声明匹配的第一条映射规则对应的用户组，无匹配时返回0
+func mapIdentityGroup(claims *oidc.Claims, claim, mapping string) uint {
+	if claim == "" {
+		return 0
+	}
+
+	var rules []oidcGroupMapping
+	if err := json.Unmarshal([]byte(mapping), &rules); err != nil {
+		util.Log().Warning("Failed to parse OIDC group mapping: %s", err)
+		return 0
+	}
+
+	values := claims.Values(claim)
+	for _, rule := range rules {
+		if util.ContainsString(values, rule.Value) {
+			return rule.Group
+		}
+	}
+
+	return 0
+}
+
+// List 列出已绑定的外部身份
+func (service *OIDCIdentityService) List(c *gin.Context, user *model.User) serializer.Response {
+	identities, err := model.ListUserIdentities(user.ID)
+	if err != nil {
+		return serializer.DBErr("Failed to list linked identities", err)
+	}
+
+	res := make([]map[string]interface{}, len(identities))
+	for i, identity := range identities {
+		res[i] = map[string]interface{}{
+			"issuer":     identity.Issuer,
+			"email":      identity.Email,
+			"created_at": identity.CreatedAt,
+		}
+	}
+
+	return serializer.Response{Data: res}
+}
+
+// Unlink 解除当前身份提供方的外部身份绑定
+func (service *OIDCIdentityService) Unlink(c *gin.Context, user *model.User) serializer.Response {
+	client := oidc.Current()
+	if client == nil {
+		return serializer.Err(serializer.CodeFeatureNotEnabled, "OIDC login is not enabled", nil)
+	}
+
+	if err := model.DeleteUserIdentity(user.ID, client.Issuer()); err != nil {
+		return serializer.Err(serializer.CodeIdentityNotLinked, "No identity is linked", err)
+	}
+
+	return serializer.Response{}
+}
@@ -2,6 +2,7 @@ package user
 
 import (
 	"crypto/md5"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -10,6 +11,7 @@ import (
 	"strings"
 
 	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/audit"
 	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
 	"github.com/cloudreve/Cloudreve/v3/pkg/util"
 	"github.com/gin-gonic/gin"
@@ -94,6 +96,7 @@ func (service *DeleteWebAuthn) Update(c *gin.Context, user *model.User) serializ
 
 // Update 更改二步验证设定
 func (service *Enable2FA) Update(c *gin.Context, user *model.User) serializer.Response {
+	event := audit.Event{User: user, TargetType: audit.TargetUser, TargetID: user.ID}
 	if user.TwoFactor == "" {
 		// 开启2FA
 		secret, ok := util.GetSession(c, "2fa_init").(string)
@@ -101,7 +104,10 @@ func (service *Enable2FA) Update(c *gin.Context, user *model.User) serializer.Re
 			return serializer.Err(serializer.CodeInternalSetting, "You have not initiated 2FA session", nil)
 		}
 
+		event.Action = audit.Action2FAEnable
 		if !totp.Validate(service.Code, secret) {
+			event.Err = errors.New("incorrect 2FA code")
+			audit.Record(c, event)
 			return serializer.ParamErr("Incorrect 2FA code", nil)
 		}
 
@@ -111,7 +117,10 @@ func (service *Enable2FA) Update(c *gin.Context, user *model.User) serializer.Re
 
 	} else {
 		// 关闭2FA
+		event.Action = audit.Action2FADisable
 		if !totp.Validate(service.Code, user.TwoFactor) {
+			event.Err = errors.New("incorrect 2FA code")
+			audit.Record(c, event)
 			return serializer.ParamErr("Incorrect 2FA code", nil)
 		}
 
@@ -120,6 +129,8 @@ func (service *Enable2FA) Update(c *gin.Context, user *model.User) serializer.Re
 		}
 	}
 
+	audit.Record(c, event)
+
 	return serializer.Response{}
 }
 
@@ -139,8 +150,17 @@ func (service *SettingService) Init2FA(c *gin.Context, user *model.User) seriali
 
 // Update 更改密码
 func (service *PasswordChange) Update(c *gin.Context, user *model.User) serializer.Response {
+	event := audit.Event{
+		Action:     audit.ActionPasswordChange,
+		User:       user,
+		TargetType: audit.TargetUser,
+		TargetID:   user.ID,
+	}
+
 	// 验证老密码
 	if ok, _ := user.CheckPassword(service.Old); !ok {
+		event.Err = errors.New("incorrect old password")
+		audit.Record(c, event)
 		return serializer.Err(serializer.CodeIncorrectPassword, "", nil)
 	}
 
@@ -150,6 +170,8 @@ func (service *PasswordChange) Update(c *gin.Context, user *model.User) serializ
 		return serializer.DBErr("Failed to update password", err)
 	}
 
+	audit.Record(c, event)
+
 	return serializer.Response{}
 }
 
@@ -0,0 +1,50 @@
+package user
+
+import (
+	model "github.com/cloudreve/Cloudreve/v3/models"
+	"github.com/cloudreve/Cloudreve/v3/pkg/serializer"
+	"github.com/cloudreve/Cloudreve/v3/pkg/task"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+// TaskService 任务操作服务
+type TaskService struct {
+	ID uint `uri:"id" binding:"required"`
+}
+
+// Cancel 取消用户尚未开始执行的任务
+func (service *TaskService) Cancel(c *gin.Context, user *model.User) serializer.Response {
+	record, err := service.get(user)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotFound, "Task not exist", err)
+	}
+
+	if err := task.Cancel(record); err != nil {
+		return serializer.Err(serializer.CodeConflict, err.Error(), err)
+	}
+
+	return serializer.Response{}
+}
+
+// Retry 重试用户失败或已取消的任务
+func (service *TaskService) Retry(c *gin.Context, user *model.User) serializer.Response {
+	record, err := service.get(user)
+	if err != nil {
+		return serializer.Err(serializer.CodeNotFound, "Task not exist", err)
+	}
+
+	if err := task.Retry(task.TaskPoll, record); err != nil {
+		return serializer.Err(serializer.CodeConflict, err.Error(), err)
+	}
+
+	return serializer.Response{}
+}
+
+func (service *TaskService) get(user *model.User) (*model.Task, error) {
+	record, err := model.GetTasksByID(service.ID)
+	if err == nil && record.UserID != user.ID {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return record, err
+}